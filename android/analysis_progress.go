@@ -0,0 +1,118 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/blueprint"
+)
+
+// The analysis progress of soong_build is reported to soong_ui through a status file that is
+// passed with --progress_file.  soong_build appends a line to the file for every mutator or build
+// action phase it starts, and, on a sampling basis, a line with the number of modules processed
+// in the current phase and the package of the module being processed:
+//
+//	phase <name>
+//	modules <phase> <count> <package>
+//	done
+//
+// soong_ui tails the file while soong_build runs and renders the last line.  The counters are
+// atomic and only one module in analysisProgressSampleRate writes to the file, so the hooks do not
+// slow down the analysis measurably.
+
+// analysisProgressSampleRate is the number of modules processed between two progress lines.
+const analysisProgressSampleRate = 1024
+
+type analysisProgress struct {
+	// lock protects writes to file and phase transitions.
+	lock  sync.Mutex
+	file  *os.File
+	phase atomic.Value // string
+
+	// count is the number of modules processed in the current phase.
+	count int64
+}
+
+func newAnalysisProgress(path string) (*analysisProgress, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &analysisProgress{file: file}
+	p.phase.Store("")
+	return p, nil
+}
+
+// moduleProcessed records that a module in the package pkg is processed in the phase.  It is
+// called for every module by the mutator wrappers and by ModuleBase.GenerateBuildActions, and is a
+// no-op when the progress is not reported.
+func (p *analysisProgress) moduleProcessed(phase, pkg string) {
+	if p == nil {
+		return
+	}
+	if p.phase.Load().(string) != phase {
+		p.startPhase(phase)
+	}
+	if count := atomic.AddInt64(&p.count, 1); count%analysisProgressSampleRate == 0 {
+		p.write("modules %s %d %s\n", phase, count, pkg)
+	}
+}
+
+func (p *analysisProgress) startPhase(phase string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// Another module of the phase may have started it while waiting for the lock.
+	if p.phase.Load().(string) == phase {
+		return
+	}
+	atomic.StoreInt64(&p.count, 0)
+	p.phase.Store(phase)
+	fmt.Fprintf(p.file, "phase %s\n", phase)
+}
+
+func (p *analysisProgress) write(format string, args ...interface{}) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Fprintf(p.file, format, args...)
+}
+
+// finish records the end of the analysis and closes the status file.
+func (p *analysisProgress) finish() error {
+	if p == nil {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	fmt.Fprintln(p.file, "done")
+	return p.file.Close()
+}
+
+// FinishAnalysisProgress records the end of the analysis in the status file passed with
+// --progress_file, if any.
+func (c Config) FinishAnalysisProgress() error {
+	return c.analysisProgress.finish()
+}
+
+// recordModuleProgress records that the module of ctx is processed in the phase.
+func recordModuleProgress(config Config, phase string, ctx blueprint.BaseModuleContext) {
+	if config.analysisProgress == nil {
+		return
+	}
+	config.analysisProgress.moduleProcessed(phase, ctx.ModuleDir())
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalysisProgress(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+		}
+
+		test {
+			name: "bar",
+		}
+	`
+
+	progressFile := filepath.Join(t.TempDir(), "progress")
+
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test", mutatorTestModuleFactory)
+			ctx.PreArchMutators(func(ctx RegisterMutatorsContext) {
+				ctx.BottomUp("progress_pre_arch", func(ctx BottomUpMutatorContext) {})
+			})
+			ctx.PostDepsMutators(func(ctx RegisterMutatorsContext) {
+				ctx.TopDown("progress_post_deps", func(ctx TopDownMutatorContext) {})
+			})
+		}),
+		FixtureModifyConfig(func(config Config) {
+			progress, err := newAnalysisProgress(progressFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.analysisProgress = progress
+		}),
+		FixtureWithRootAndroidBp(bp),
+	).RunTest(t)
+
+	if err := result.Config.FinishAnalysisProgress(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(progressFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+
+	// Every phase is started exactly once, in the order the phases run in.
+	seen := make(map[string]bool)
+	for _, line := range lines {
+		if seen[line] {
+			t.Errorf("phase started more than once: %q", line)
+		}
+		seen[line] = true
+	}
+
+	expected := []string{
+		"phase progress_pre_arch",
+		"phase progress_post_deps",
+		"phase generate_build_actions",
+		"done",
+	}
+	next := 0
+	for _, line := range lines {
+		if next < len(expected) && line == expected[next] {
+			next++
+		}
+	}
+	if next < len(expected) {
+		t.Errorf("expected the status file to contain, in order:\n%s\ngot:\n%s",
+			strings.Join(expected, "\n"), string(content))
+	}
+	AssertDeepEquals(t, "last lines", expected[2:], lines[len(lines)-2:])
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
 	"testing"
@@ -789,4 +790,208 @@ func TestGetDistContributions(t *testing.T) {
 			},
 		},
 	})
+
+	testHelper(t, "same-tag-to-different-goals-and-names", `
+			custom {
+				name: "foo",
+				dist: {
+					targets: ["my_goal"],
+					tag: ".another-tag",
+				},
+				dists: [
+					{
+						targets: ["my_second_goal"],
+						tag: ".another-tag",
+						dest: "renamed.out",
+					},
+					{
+						targets: ["my_third_goal"],
+						tag: ".another-tag",
+						dir: "some/dir",
+						dest: "other.out",
+					},
+				],
+			}
+`, &distContributions{
+		copiesForGoals: []*copiesForGoals{
+			{
+				goals: "my_second_goal",
+				copies: []distCopy{
+					distCopyForTest("another.out", "renamed.out"),
+				},
+			},
+			{
+				goals: "my_third_goal",
+				copies: []distCopy{
+					distCopyForTest("another.out", "some/dir/other.out"),
+				},
+			},
+			{
+				goals: "my_goal",
+				copies: []distCopy{
+					distCopyForTest("another.out", "another.out"),
+				},
+			},
+		},
+	})
+}
+
+func TestDistPropertyErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "invalid tag in dists",
+			bp: `
+				custom {
+					name: "foo",
+					default_dist_files: "none",
+					dists: [
+						{
+							targets: ["my_goal"],
+							tag: ".another-tag",
+						},
+						{
+							targets: ["my_second_goal"],
+							tag: ".unknown",
+						},
+					],
+				}`,
+			error: `module "foo": dists[1].tag: unsupported module reference tag ".unknown"`,
+		},
+		{
+			name: "invalid tag in dist",
+			bp: `
+				custom {
+					name: "foo",
+					default_dist_files: "none",
+					dist: {
+						targets: ["my_goal"],
+						tag: ".unknown",
+					},
+				}`,
+			error: `module "foo": dist.tag: unsupported module reference tag ".unknown"`,
+		},
+		{
+			name: "dest for multiple files",
+			bp: `
+				custom {
+					name: "foo",
+					default_dist_files: "none",
+					dists: [
+						{
+							targets: ["my_goal"],
+							tag: ".multiple",
+							dest: "renamed.out",
+						},
+					],
+				}`,
+			error: `module "foo": dists[0]: cannot apply dest or suffix to the 2 files of tag ".multiple"`,
+		},
+	}
+
+	// default_dist_files: "none" stops the custom module from calling GenerateTaggedDistFiles a
+	// second time and reporting each error twice.
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				PrepareForTestWithAndroidMk,
+				FixtureRegisterWithContext(func(ctx RegistrationContext) {
+					ctx.RegisterModuleType("custom", customModuleFactory)
+				}),
+				FixtureWithRootAndroidBp(tc.bp),
+			).
+				ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(regexp.QuoteMeta(tc.error))).
+				RunTest(t)
+		})
+	}
+}
+
+type androidMkEntriesTestModule struct {
+	ModuleBase
+	output Path
+}
+
+func androidMkEntriesTestModuleFactory() Module {
+	module := &androidMkEntriesTestModule{}
+	InitAndroidModule(module)
+	return module
+}
+
+func (m *androidMkEntriesTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	output := PathForModuleOut(ctx, ctx.ModuleName()+".txt")
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: output,
+	})
+	m.output = output
+}
+
+func (m *androidMkEntriesTestModule) AndroidMkEntries() []AndroidMkEntries {
+	return []AndroidMkEntries{
+		{
+			Class:      "ETC",
+			OutputFile: OptionalPathForPath(m.output),
+			ExtraEntries: []AndroidMkExtraEntriesFunc{
+				func(ctx AndroidMkExtraEntriesContext, entries *AndroidMkEntries) {
+					entries.SetPath("LOCAL_EXTRA_FILE", m.output)
+				},
+			},
+		},
+		{
+			Class:      "FAKE",
+			SubName:    ".extra",
+			OutputFile: OptionalPathForPath(m.output),
+		},
+	}
+}
+
+func TestAssertAndroidMkEntry(t *testing.T) {
+	result := GroupFixturePreparers(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test", androidMkEntriesTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(`
+			test {
+				name: "foo",
+			}
+		`),
+	).RunTest(t)
+
+	foo := result.Module("foo", "")
+	result.AssertAndroidMkEntry(t, "class", foo, PrimaryAndroidMkEntries, "LOCAL_MODULE_CLASS", []string{"ETC"})
+	result.AssertAndroidMkEntry(t, "extra file", foo, PrimaryAndroidMkEntries, "LOCAL_EXTRA_FILE",
+		[]string{"out/soong/.intermediates/foo/foo.txt"})
+	result.AssertAndroidMkEntry(t, "named", foo, AndroidMkEntriesNamed("foo.extra"), "LOCAL_MODULE_CLASS", []string{"FAKE"})
+	result.AssertAndroidMkEntry(t, "index", foo, AndroidMkEntriesAt(1), "LOCAL_PREBUILT_MODULE_FILE",
+		[]string{"out/soong/.intermediates/foo/foo.txt"})
+	result.AssertAndroidMkEntryAbsent(t, "absent", foo, AndroidMkEntriesAt(1), "LOCAL_EXTRA_FILE")
+
+	reporter := &fakeErrorReporter{}
+	assertAndroidMkEntry(reporter, "class", result.TestContext, foo, PrimaryAndroidMkEntries, "LOCAL_MODULE_CLASS",
+		[]string{"APPS"}, false)
+	AssertArrayString(t, "different values", []string{`class: expected LOCAL_MODULE_CLASS to be ["APPS"], actual ["ETC"]`},
+		reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	assertAndroidMkEntry(reporter, "absent", result.TestContext, foo, PrimaryAndroidMkEntries, "LOCAL_EXTRA_FILE", nil, true)
+	AssertArrayString(t, "unexpected entry",
+		[]string{`absent: expected LOCAL_EXTRA_FILE not to be set, actual ["out/soong/.intermediates/foo/foo.txt"]`},
+		reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	assertAndroidMkEntry(reporter, "missing", result.TestContext, foo, PrimaryAndroidMkEntries, "LOCAL_MISSING", nil, false)
+	AssertIntEquals(t, "missing entry errors", 1, len(reporter.errors))
+	AssertStringDoesContain(t, "missing entry", reporter.errors[0],
+		"missing: LOCAL_MISSING is not set in the AndroidMkEntries of foo, the entries are:\n")
+	AssertStringDoesContain(t, "missing entry keys", reporter.errors[0], "\n    LOCAL_EXTRA_FILE\n")
+	AssertStringDoesContain(t, "missing entry keys", reporter.errors[0], "\n    LOCAL_MODULE_CLASS")
+
+	reporter = &fakeErrorReporter{}
+	assertAndroidMkEntry(reporter, "selector", result.TestContext, foo, AndroidMkEntriesNamed("foo.other"), "LOCAL_MODULE_CLASS",
+		[]string{"ETC"}, false)
+	AssertArrayString(t, "missing entries", []string{`selector: module "foo" has no AndroidMkEntries named "foo.other", ` +
+		"its AndroidMkEntries are:\n    0: foo\n    1: foo.extra"}, reporter.errors)
 }
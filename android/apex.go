@@ -161,8 +161,7 @@ type DepIsInSameApex interface {
 }
 
 func IsDepInSameApex(ctx BaseModuleContext, module, dep Module) bool {
-	depTag := ctx.OtherModuleDependencyTag(dep)
-	if _, ok := depTag.(ExcludeFromApexContentsTag); ok {
+	if ctx.OtherModuleDependencyTagCapabilities(dep).ExcludeFromApexContents {
 		// The tag defines a dependency that never requires the child module to be part of the same
 		// apex as the parent.
 		return false
@@ -197,6 +196,10 @@ type ApexModule interface {
 	// this after apex.apexMutator is run.
 	InAnyApex() bool
 
+	// Returns the APEXes that this module is available to, from its apex_available property.
+	// Modules without apex_available are available to the platform only.
+	ApexAvailable() []string
+
 	// Returns true if this module is directly in any APEX. Call this AFTER apex.apexMutator is
 	// run.
 	DirectlyInAnyApex() bool
@@ -678,7 +681,7 @@ func UpdateDirectlyInAnyApex(mctx BottomUpMutatorContext, am ApexModule) {
 	// Copy DirectlyInAnyApex and InAnyApex from any direct dependencies with a
 	// CopyDirectlyInAnyApexTag dependency tag.
 	mctx.VisitDirectDeps(func(dep Module) {
-		if _, ok := mctx.OtherModuleDependencyTag(dep).(CopyDirectlyInAnyApexTag); ok {
+		if mctx.OtherModuleDependencyTagCapabilities(dep).CopyDirectlyInAnyApex {
 			depBase := dep.(ApexModule).apexModuleBase()
 			depBase.ApexProperties.DirectlyInAnyApex = base.ApexProperties.DirectlyInAnyApex
 			depBase.ApexProperties.InAnyApex = base.ApexProperties.InAnyApex
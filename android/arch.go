@@ -458,6 +458,10 @@ func osMutator(bpctx blueprint.BottomUpMutatorContext) {
 		osNames[i] = os.String()
 	}
 
+	// Record the OsTypes of the variants, so that each variant can find the others through
+	// OsVariantsInfoProvider.
+	osVariantsInfo := OsVariantsInfo{OsTypes: append([]OsType(nil), moduleOSList...)}
+
 	createCommonOSVariant := base.commonProperties.CreateCommonOSVariant
 	if createCommonOSVariant {
 		// A CommonOS variant was requested so add it to the list of OS variants to
@@ -476,6 +480,7 @@ func osMutator(bpctx blueprint.BottomUpMutatorContext) {
 	for i, m := range modules {
 		m.base().commonProperties.CompileOS = moduleOSList[i]
 		m.base().setOSProperties(mctx)
+		mctx.SetVariationProvider(m, OsVariantsInfoProvider, osVariantsInfo)
 	}
 
 	if createCommonOSVariant {
@@ -522,6 +527,88 @@ func GetOsSpecificVariantsOfCommonOSVariant(mctx BaseModuleContext) []Module {
 	return variants
 }
 
+// OsVariantsInfo is provided by every variant of an architecture-specific module, and lists the
+// OsTypes that the os mutator created variants of the module for.  It links the host and device
+// variants of a module that supports both, e.g. a host_supported cc_library, see HostVariantOf and
+// DeviceVariantOf.
+type OsVariantsInfo struct {
+	// The OsTypes of the variants of the module, in the order the os mutator created them, not
+	// including CommonOS.
+	OsTypes []OsType
+}
+
+var OsVariantsInfoProvider = blueprint.NewMutatorProvider(OsVariantsInfo{}, "os")
+
+// HostVariantOf returns the variant of module for the build OS, or for the first host OsType of
+// the module if it does not support the build OS, with the same architecture multilib and the same
+// other variations as module.  It returns nil if module has no such variant.
+func HostVariantOf(ctx SingletonContext, module Module) Module {
+	return osVariantOf(ctx.Config(), module, Host, ctx.ModuleProvider(module, OsVariantsInfoProvider),
+		func(visit func(Module)) { ctx.VisitAllModuleVariants(module, visit) })
+}
+
+// DeviceVariantOf returns the device variant of module with the same architecture multilib and
+// the same other variations as module, or nil if module has no such variant.
+func DeviceVariantOf(ctx SingletonContext, module Module) Module {
+	return osVariantOf(ctx.Config(), module, Device, ctx.ModuleProvider(module, OsVariantsInfoProvider),
+		func(visit func(Module)) { ctx.VisitAllModuleVariants(module, visit) })
+}
+
+// osVariantOf implements HostVariantOf and DeviceVariantOf for the contexts that can visit all
+// the variants of a module.  info is the OsVariantsInfoProvider value of module.
+func osVariantOf(config Config, module Module, class OsClass, info interface{},
+	visitAllVariants func(func(Module))) Module {
+
+	var os OsType
+	for _, t := range info.(OsVariantsInfo).OsTypes {
+		if t.Class == class && (os.Name == "" || t == config.BuildOS) {
+			os = t
+		}
+	}
+	if os.Name == "" {
+		return nil
+	}
+
+	target := module.Target()
+
+	var found, foundOtherMultilib Module
+	visitAllVariants(func(variant Module) {
+		variantTarget := variant.Target()
+		if variantTarget.Os != os || variantTarget.NativeBridge != target.NativeBridge ||
+			!sameOtherVariations(variant, module) {
+			return
+		}
+		if variantTarget.Arch.ArchType.Multilib == target.Arch.ArchType.Multilib {
+			if found == nil {
+				found = variant
+			}
+		} else if foundOtherMultilib == nil {
+			foundOtherMultilib = variant
+		}
+	})
+	if found == nil {
+		return foundOtherMultilib
+	}
+	return found
+}
+
+// sameOtherVariations returns true if the variations of a and b that were not created by the os
+// and arch mutators, e.g. the image or link variations, have the same values.  The os and arch
+// mutators do not record their variations in DebugMutators, they are compared through the Target
+// instead.  As for TestContext.ModuleVariantForTests, a variation that only one of them has is
+// ignored.
+func sameOtherVariations(a, b Module) bool {
+	aProps, bProps := &a.base().commonProperties, &b.base().commonProperties
+	for i, mutator := range aProps.DebugMutators {
+		for j, otherMutator := range bProps.DebugMutators {
+			if otherMutator == mutator && bProps.DebugVariations[j] != aProps.DebugVariations[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 var DarwinUniversalVariantTag = archDepTag{name: "darwin universal binary"}
 
 // archMutator splits a module into a variant for each Target requested by the module.  Target selection
@@ -1040,6 +1127,25 @@ func initArchModule(m Module) {
 
 }
 
+// PropertiesWithArchVariants returns the supplied property structs of the module followed by the
+// architecture-specific property structs that initArchModule added to the module for them, which
+// hold the arch, multilib and target variants of the properties until the arch mutator squashes
+// them.  Passing the result to CreateModule from a load hook creates a module with the same arch
+// variant properties as m.
+func PropertiesWithArchVariants(m Module, props ...interface{}) []interface{} {
+	base := m.base()
+	ret := append([]interface{}(nil), props...)
+	// m.archProperties[i] corresponds to m.GetProperties()[i].
+	for i, moduleProps := range m.GetProperties()[:len(base.archProperties)] {
+		for _, p := range props {
+			if p == moduleProps {
+				ret = append(ret, base.archProperties[i]...)
+			}
+		}
+	}
+	return ret
+}
+
 func maybeBlueprintEmbed(src reflect.Value) reflect.Value {
 	// If the value of the field is a struct (as opposed to a pointer to a struct) then step
 	// into the BlueprintEmbed field.
@@ -1217,7 +1217,7 @@ func (context *mixedBuildBazelContext) runAquery(config Config, ctx invokeBazelC
 	if Bool(config.productVariables.ClangCoverage) {
 		extraFlags = append(extraFlags, "--collect_code_coverage")
 		paths := make([]string, 0, 2)
-		if p := config.productVariables.NativeCoveragePaths; len(p) > 0 {
+		if p := config.nativeCoveragePaths(); len(p) > 0 {
 			for i := range p {
 				// TODO(b/259404593) convert path wildcard to regex values
 				if p[i] == "*" {
@@ -1226,7 +1226,7 @@ func (context *mixedBuildBazelContext) runAquery(config Config, ctx invokeBazelC
 			}
 			paths = append(paths, JoinWithPrefixAndSeparator(p, "+", ","))
 		}
-		if p := config.productVariables.NativeCoverageExcludePaths; len(p) > 0 {
+		if p := config.nativeCoverageExcludePaths(); len(p) > 0 {
 			paths = append(paths, JoinWithPrefixAndSeparator(p, "-", ","))
 		}
 		if len(paths) > 0 {
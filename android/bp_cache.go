@@ -0,0 +1,233 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/blueprint/parser"
+)
+
+// Soong parses some Android.bp files itself on every run of soong_build, like the files that
+// soong_config_module_type_import imports module types from.  The bp cache keeps the parsed and
+// evaluated ASTs of these files in out/soong/bp_cache, keyed by the hash of their contents and the
+// version of soong_build, so that the files that did not change skip the parser and the
+// construction of their scope in the next runs.  The whole cache is invalidated when the set of
+// registered module types changes.
+//
+// Setting SOONG_DISABLE_BP_CACHE=true disables the cache.  Setting SOONG_CHECK_BP_CACHE=true
+// parses the files that are found in the cache too, and reports an error if the cached AST is
+// different from the parsed one.
+//
+// The Android.bp files in the module list are parsed by blueprint, which does not use the cache.
+
+const (
+	disableBpCacheEnvVar = "SOONG_DISABLE_BP_CACHE"
+	checkBpCacheEnvVar   = "SOONG_CHECK_BP_CACHE"
+
+	// bpCacheFormatVersion must be changed when the format of the cache entries changes.
+	bpCacheFormatVersion = "1"
+
+	// bpCacheRegistrationFile holds the hash of the registered module types that the entries in
+	// the cache were parsed with.
+	bpCacheRegistrationFile = "registration"
+)
+
+func init() {
+	// The concrete types of the definitions and expressions in a parser.File.
+	gob.Register(&parser.Module{})
+	gob.Register(&parser.Assignment{})
+	gob.Register(&parser.String{})
+	gob.Register(&parser.Int64{})
+	gob.Register(&parser.Bool{})
+	gob.Register(&parser.List{})
+	gob.Register(&parser.Map{})
+	gob.Register(&parser.Variable{})
+	gob.Register(&parser.Operator{})
+}
+
+type bpCache struct {
+	dir     string
+	version string
+	check   bool
+
+	hits   int64
+	misses int64
+}
+
+// newBpCache returns a cache of the ASTs of Android.bp files in dir, for the version of
+// soong_build and the names of the registered module types.  It removes the entries in dir that
+// were parsed with a different set of module types.
+func newBpCache(dir, version string, moduleTypes []string, check bool) (*bpCache, error) {
+	registration := hashStrings(SortedUniqueStrings(moduleTypes))
+	registrationFile := filepath.Join(dir, bpCacheRegistrationFile)
+	if previous, err := os.ReadFile(registrationFile); err != nil || string(previous) != registration {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(registrationFile, []byte(registration), 0666); err != nil {
+			return nil, err
+		}
+	}
+	return &bpCache{
+		dir:     dir,
+		version: bpCacheFormatVersion + " " + version,
+		check:   check,
+	}, nil
+}
+
+// bpCacheForConfig returns the bp cache of soong_build, or nil if the cache is disabled.
+func bpCacheForConfig(config Config, moduleTypes []string) *bpCache {
+	return config.Once(bpCacheOnceKey, func() interface{} {
+		// Tests don't write to the out directory.
+		if config.captureBuild || config.IsEnvTrue(disableBpCacheEnvVar) {
+			return (*bpCache)(nil)
+		}
+		version, err := soongBuildVersion()
+		if err != nil {
+			return (*bpCache)(nil)
+		}
+		cache, err := newBpCache(filepath.Join(config.SoongOutDir(), "bp_cache"), version, moduleTypes,
+			config.IsEnvTrue(checkBpCacheEnvVar))
+		if err != nil {
+			return (*bpCache)(nil)
+		}
+		return cache
+	}).(*bpCache)
+}
+
+var bpCacheOnceKey = NewOnceKey("bpCache")
+
+// soongBuildVersion identifies the soong_build binary by its path, size and modification time,
+// which is much cheaper than hashing it.
+func soongBuildVersion() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(executable)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %d", executable, info.Size(), info.ModTime().UnixNano()), nil
+}
+
+func hashStrings(s []string) string {
+	h := sha256.Sum256([]byte(strings.Join(s, "\n")))
+	return hex.EncodeToString(h[:])
+}
+
+// parseAndEval returns the evaluated AST of the Android.bp file filename with the given contents,
+// from the cache if the file was parsed before with the same version of soong_build.  It parses
+// the file if the cache is nil.
+func (c *bpCache) parseAndEval(filename string, contents []byte) (*parser.File, []error) {
+	parse := func() (*parser.File, []error) {
+		return parser.ParseAndEval(filename, bytes.NewReader(contents), parser.NewScope(nil))
+	}
+	if c == nil {
+		return parse()
+	}
+
+	entry := c.entry(filename, contents)
+	if file, ok := c.load(entry); ok {
+		atomic.AddInt64(&c.hits, 1)
+		if c.check {
+			parsed, errs := parse()
+			if len(errs) > 0 {
+				return nil, errs
+			}
+			if !sameBlueprintFile(file, parsed) {
+				return nil, []error{fmt.Errorf("the cached AST of %s is different from the parsed one, "+
+					"remove %s and set %s=true to bypass the cache", filename, c.dir, disableBpCacheEnvVar)}
+			}
+		}
+		return file, nil
+	}
+
+	atomic.AddInt64(&c.misses, 1)
+	file, errs := parse()
+	if len(errs) == 0 {
+		// The cache is an optimization, failing to write an entry only costs a parse in the next
+		// run.
+		c.store(entry, file)
+	}
+	return file, errs
+}
+
+// entry returns the path of the cache entry of an Android.bp file.
+func (c *bpCache) entry(filename string, contents []byte) string {
+	return filepath.Join(c.dir, hashStrings([]string{c.version, filename, string(contents)}))
+}
+
+func (c *bpCache) load(entry string) (*parser.File, bool) {
+	f, err := os.Open(entry)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	var file parser.File
+	if err := gob.NewDecoder(f).Decode(&file); err != nil {
+		return nil, false
+	}
+	return &file, true
+}
+
+func (c *bpCache) store(entry string, file *parser.File) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(file); err != nil {
+		return
+	}
+	// Write the entry atomically, as soong_build parses files concurrently.
+	tmp, err := os.CreateTemp(c.dir, filepath.Base(entry)+".tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), entry)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// stats returns the number of files that were found in the cache and that were parsed.
+func (c *bpCache) stats() (hits, misses int64) {
+	return atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+}
+
+// sameBlueprintFile returns true if two ASTs of an Android.bp file print the same.
+func sameBlueprintFile(a, b *parser.File) bool {
+	printedA, errA := parser.Print(a)
+	printedB, errB := parser.Print(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return a.Name == b.Name && bytes.Equal(printedA, printedB)
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/blueprint/parser"
+)
+
+const bpCacheTestFile = `
+variables = ["board"]
+
+soong_config_module_type {
+    name: "acme_cc_defaults",
+    module_type: "cc_defaults",
+    config_namespace: "acme",
+    variables: variables,
+    properties: ["cflags"],
+}
+
+soong_config_string_variable {
+    name: "board",
+    values: ["soc_a", "soc_b"],
+}
+`
+
+var bpCacheTestModuleTypes = []string{"cc_defaults", "soong_config_module_type", "soong_config_string_variable"}
+
+// parseBpCacheTestFile parses contents as the file foo/Android.bp through a new cache in dir, and
+// returns the printed AST and the number of hits and misses of the cache.
+func parseBpCacheTestFile(t *testing.T, dir, version string, moduleTypes []string, check bool,
+	contents string) (string, int64, int64) {
+	t.Helper()
+	cache, err := newBpCache(dir, version, moduleTypes, check)
+	if err != nil {
+		t.Fatalf("failed to create the cache: %s", err)
+	}
+	file, errs := cache.parseAndEval("foo/Android.bp", []byte(contents))
+	if len(errs) > 0 {
+		t.Fatalf("failed to parse: %q", errs)
+	}
+	printed, err := parser.Print(file)
+	if err != nil {
+		t.Fatalf("failed to print: %s", err)
+	}
+	hits, misses := cache.stats()
+	return string(printed), hits, misses
+}
+
+func TestBpCacheHit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bp_cache")
+
+	parsed, hits, misses := parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, false, bpCacheTestFile)
+	AssertIntEquals(t, "first run hits", 0, int(hits))
+	AssertIntEquals(t, "first run misses", 1, int(misses))
+
+	cached, hits, misses := parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, false, bpCacheTestFile)
+	AssertIntEquals(t, "second run hits", 1, int(hits))
+	AssertIntEquals(t, "second run misses", 0, int(misses))
+	AssertStringEquals(t, "cached AST", parsed, cached)
+
+	// The consistency check parses the file too, and accepts the cached AST.
+	_, hits, _ = parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, true, bpCacheTestFile)
+	AssertIntEquals(t, "checked run hits", 1, int(hits))
+}
+
+func TestBpCacheContentChange(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bp_cache")
+	parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, false, bpCacheTestFile)
+
+	changed := bpCacheTestFile + `
+soong_config_bool_variable {
+    name: "feature",
+}
+`
+	parsed, hits, misses := parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, false, changed)
+	AssertIntEquals(t, "changed contents hits", 0, int(hits))
+	AssertIntEquals(t, "changed contents misses", 1, int(misses))
+	AssertStringDoesContain(t, "changed contents AST", parsed, "soong_config_bool_variable")
+
+	// A new version of soong_build does not use the entries of the previous one.
+	_, hits, misses = parseBpCacheTestFile(t, dir, "2", bpCacheTestModuleTypes, false, changed)
+	AssertIntEquals(t, "new version hits", 0, int(hits))
+	AssertIntEquals(t, "new version misses", 1, int(misses))
+}
+
+func TestBpCacheRegistrationChange(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bp_cache")
+	parseBpCacheTestFile(t, dir, "1", bpCacheTestModuleTypes, false, bpCacheTestFile)
+
+	moduleTypes := append([]string{"cc_library"}, bpCacheTestModuleTypes...)
+	cache, err := newBpCache(dir, "1", moduleTypes, false)
+	if err != nil {
+		t.Fatalf("failed to create the cache: %s", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertIntEquals(t, "files after the registration changed", 1, len(entries))
+	AssertStringEquals(t, "remaining file", bpCacheRegistrationFile, entries[0].Name())
+
+	cache.parseAndEval("foo/Android.bp", []byte(bpCacheTestFile))
+	hits, misses := cache.stats()
+	AssertIntEquals(t, "new registration hits", 0, int(hits))
+	AssertIntEquals(t, "new registration misses", 1, int(misses))
+}
+
+func TestBpCacheCheckMismatch(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "bp_cache")
+	cache, err := newBpCache(dir, "1", bpCacheTestModuleTypes, true)
+	if err != nil {
+		t.Fatalf("failed to create the cache: %s", err)
+	}
+
+	// Store the AST of other contents in the entry of the file.
+	other, errs := parser.ParseAndEval("foo/Android.bp", strings.NewReader(`variables = ["other"]`), parser.NewScope(nil))
+	if len(errs) > 0 {
+		t.Fatalf("failed to parse: %q", errs)
+	}
+	cache.store(cache.entry("foo/Android.bp", []byte(bpCacheTestFile)), other)
+
+	_, errs = cache.parseAndEval("foo/Android.bp", []byte(bpCacheTestFile))
+	if len(errs) != 1 {
+		t.Fatalf("expected one error, got %q", errs)
+	}
+	AssertStringDoesContain(t, "error", errs[0].Error(), "the cached AST of foo/Android.bp is different")
+}
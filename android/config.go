@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/blueprint"
 	"github.com/google/blueprint/bootstrap"
@@ -91,6 +92,7 @@ type CmdArgs struct {
 	ModuleGraphFile     string
 	ModuleActionsFile   string
 	DocFile             string
+	SoongQueryFile      string
 
 	MultitreeBuild bool
 
@@ -102,6 +104,9 @@ type CmdArgs struct {
 	UseBazelProxy bool
 
 	BuildFromTextStub bool
+
+	// ProgressFile is the status file that the analysis progress is reported to, if set.
+	ProgressFile string
 }
 
 // Build modes that soong_build can run as.
@@ -129,6 +134,9 @@ const (
 	// Generate a documentation file for module type definitions and exit.
 	GenerateDocFile
 
+	// Answer the query in SOONG_QUERY over the module graph and exit.
+	GenerateSoongQuery
+
 	// Use bazel during analysis of many allowlisted build modules. The allowlist
 	// is considered a "developer mode" allowlist, as some modules may be
 	// allowlisted on an experimental basis.
@@ -181,6 +189,19 @@ func (c Config) MaxPageSizeSupported() string {
 	return String(c.config.productVariables.DeviceMaxPageSizeSupported)
 }
 
+// CheckPrebuiltMaxPageSize returns true if the ELF segment alignment of prebuilt shared libraries
+// and binaries should be checked against their max_page_size, like it is for the modules built
+// from source.
+func (c Config) CheckPrebuiltMaxPageSize() bool {
+	return Bool(c.config.productVariables.CheckPrebuiltMaxPageSize)
+}
+
+// DefaultBuildIdStyle returns the GNU build-id of the native shared libraries and binaries that
+// don't set the build_id property, or "" to use the build-id of the toolchain.
+func (c Config) DefaultBuildIdStyle() string {
+	return String(c.config.productVariables.DefaultBuildIdStyle)
+}
+
 // A DeviceConfig object represents the configuration for a particular device
 // being built. For now there will only be one of these, but in the future there
 // may be multiple devices being built.
@@ -239,6 +260,13 @@ type config struct {
 	// runs standalone.
 	katiEnabled bool
 
+	// Whether the intermediates directories of modules are sharded by hashed subdirectories, see
+	// moduleIntermediatesDir.
+	hashedIntermediates bool
+
+	// The time that the build started at, see Now.
+	buildDate time.Time
+
 	captureBuild      bool // true for tests, saves build parameters for each module
 	ignoreEnvironment bool // true for tests, returns empty from all Getenv calls
 
@@ -285,6 +313,14 @@ type config struct {
 	// If buildFromTextStub is true then the Java API stubs are
 	// built from the signature text files, not the source Java files.
 	buildFromTextStub bool
+
+	// analysisProgress reports the analysis progress to the status file passed with
+	// --progress_file, or is nil if the progress is not reported.
+	analysisProgress *analysisProgress
+
+	// moduleTracker records the modules being processed in tests with a timeout, or is nil otherwise,
+	// see FixtureWithTimeout and trackModuleForTests.
+	moduleTracker *moduleTracker
 }
 
 type deviceConfig struct {
@@ -319,9 +355,7 @@ func loadFromConfigFile(configurable *productVariables, filename string) error {
 	} else if err != nil {
 		return fmt.Errorf("config file: could not open %s: %s", filename, err.Error())
 	} else {
-		// Make a decoder for it
-		jsonDecoder := json.NewDecoder(configFileReader)
-		err = jsonDecoder.Decode(configurable)
+		err = decodeProductVariables(configFileReader, configurable)
 		if err != nil {
 			return fmt.Errorf("config file: %s did not parse correctly: %s", filename, err.Error())
 		}
@@ -412,7 +446,11 @@ func saveToBazelConfigFile(config *productVariables, outDir string) error {
 		return fmt.Errorf("cannot marshal arch variant product variable data: %s", err.Error())
 	}
 
-	configJson, err := json.MarshalIndent(&config, "", "    ")
+	configWithLazySections, err := productVariablesForJSON(config)
+	if err != nil {
+		return fmt.Errorf("cannot marshal config data: %s", err.Error())
+	}
+	configJson, err := json.MarshalIndent(configWithLazySections, "", "    ")
 	if err != nil {
 		return fmt.Errorf("cannot marshal config data: %s", err.Error())
 	}
@@ -512,6 +550,13 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 		return Config{}, fmt.Errorf("Build dir must not contain source directory")
 	}
 
+	if cmdArgs.ProgressFile != "" {
+		config.analysisProgress, err = newAnalysisProgress(absolutePath(cmdArgs.ProgressFile))
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
 	// Load any configurable options from the configuration file
 	err = loadConfig(config)
 	if err != nil {
@@ -523,6 +568,13 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 		config.katiEnabled = true
 	}
 
+	config.hashedIntermediates = config.IsEnvTrue(hashedIntermediatesEnvVar)
+
+	config.buildDate, err = readBuildDate(config.Getenv("BUILD_DATETIME_FILE"))
+	if err != nil {
+		return Config{}, err
+	}
+
 	determineBuildOS(config)
 
 	// Sets up the map of target OSes to the finer grained compilation targets
@@ -595,6 +647,7 @@ func NewConfig(cmdArgs CmdArgs, availableEnv map[string]string) (Config, error)
 	setBuildMode(cmdArgs.BazelApiBp2buildDir, ApiBp2build)
 	setBuildMode(cmdArgs.ModuleGraphFile, GenerateModuleGraph)
 	setBuildMode(cmdArgs.DocFile, GenerateDocFile)
+	setBuildMode(cmdArgs.SoongQueryFile, GenerateSoongQuery)
 	setBazelMode(cmdArgs.BazelModeDev, "--bazel-mode-dev", BazelDevMode)
 	setBazelMode(cmdArgs.BazelMode, "--bazel-mode", BazelProdMode)
 	setBazelMode(cmdArgs.BazelModeStaging, "--bazel-mode-staging", BazelStagingMode)
@@ -625,7 +678,7 @@ func (c *config) mockFileSystem(bp string, fs map[string][]byte) {
 	pathsToParse := []string{}
 	for candidate := range mockFS {
 		base := filepath.Base(candidate)
-		if base == "Android.bp" {
+		if base == "Android.bp" && !strings.Contains(candidate, mockFSSymlinkSeparator) {
 			pathsToParse = append(pathsToParse, candidate)
 		}
 	}
@@ -787,6 +840,43 @@ func (c *config) KatiEnabled() bool {
 	return c.katiEnabled
 }
 
+// HashedIntermediates returns true if the intermediates directories of modules are sharded by
+// hashed subdirectories rather than mirroring the source tree.
+func (c *config) HashedIntermediates() bool {
+	return c.hashedIntermediates
+}
+
+// readBuildDate returns the time that the build started at from the file that soong_ui writes the
+// BUILD_DATETIME to as seconds since the epoch, or the current time if there is no such file.
+func readBuildDate(buildDateTimeFile string) (time.Time, error) {
+	if buildDateTimeFile == "" {
+		return time.Now(), nil
+	}
+	data, err := os.ReadFile(absolutePath(buildDateTimeFile))
+	if os.IsNotExist(err) {
+		return time.Now(), nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid build date in %s: %s", buildDateTimeFile, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// Now returns the time that the build started at.  All the date dependent behavior must use it
+// instead of time.Now, so that the whole build agrees on the date and tests can set it with
+// FixtureSetBuildDate.
+func (c *config) Now() time.Time {
+	return c.buildDate
+}
+
+// BuildDateEpoch returns the time that the build started at as seconds since the epoch.
+func (c *config) BuildDateEpoch() int64 {
+	return c.buildDate.Unix()
+}
+
 func (c *config) BuildId() string {
 	return String(c.productVariables.BuildId)
 }
@@ -1169,6 +1259,17 @@ func (c *config) EmitXrefRules() bool {
 	return c.XrefCorpusName() != ""
 }
 
+// FramePointers returns true if native device code should keep the frame pointers.  It is opt-in
+// for products, usually for profiling builds, because of the cost in performance and code size.
+func (c *config) FramePointers() bool {
+	return Bool(c.productVariables.FramePointers)
+}
+
+// ProductPackages returns the modules in PRODUCT_PACKAGES.
+func (c *config) ProductPackages() []string {
+	return c.productPackages()
+}
+
 func (c *config) ClangTidy() bool {
 	return Bool(c.productVariables.ClangTidy)
 }
@@ -1180,6 +1281,18 @@ func (c *config) TidyChecks() string {
 	return *c.productVariables.TidyChecks
 }
 
+// TidyChecksAsErrorsForDir returns the clang-tidy checks that the product enables as errors for the
+// modules in dir, from all the path prefixes that dir is under.
+func (c *config) TidyChecksAsErrorsForDir(dir string) []string {
+	var checks []string
+	for _, prefix := range SortedKeys(c.productVariables.TidyChecksAsErrorsForPath) {
+		if strings.HasPrefix(dir+"/", strings.TrimSuffix(prefix, "/")+"/") {
+			checks = append(checks, c.productVariables.TidyChecksAsErrorsForPath[prefix]...)
+		}
+	}
+	return FirstUniqueStrings(checks)
+}
+
 func (c *config) LibartImgHostBaseAddress() string {
 	return "0x60000000"
 }
@@ -1227,6 +1340,21 @@ func (c *config) IncludeTags() []string {
 	return c.productVariables.IncludeTags
 }
 
+// Experiments returns the experiments that build extra variants of modules, see experiment.go.
+func (c *config) Experiments() []ExperimentConfig {
+	return c.productVariables.Experiments
+}
+
+// Experiment returns the experiment with the given name, or the zero value if there is none.
+func (c *config) Experiment(name string) ExperimentConfig {
+	for _, e := range c.productVariables.Experiments {
+		if e.Name == name {
+			return e
+		}
+	}
+	return ExperimentConfig{}
+}
+
 func (c *config) HostStaticBinaries() bool {
 	return Bool(c.productVariables.HostStaticBinaries)
 }
@@ -1377,6 +1505,12 @@ func (c *deviceConfig) DeviceKernelHeaderDirs() []string {
 	return c.config.productVariables.DeviceKernelHeaders
 }
 
+// KernelHeadersVersion returns the kernel version, e.g. android13-5.15, whose headers are exported
+// by kernel_headers modules that list multiple versions.
+func (c *deviceConfig) KernelHeadersVersion() string {
+	return String(c.config.productVariables.KernelHeadersVersion)
+}
+
 // JavaCoverageEnabledForPath returns whether Java code coverage is enabled for
 // path. Coverage is enabled by default when the product variable
 // JavaCoveragePaths is empty. If JavaCoveragePaths is not empty, coverage is
@@ -1385,13 +1519,14 @@ func (c *deviceConfig) DeviceKernelHeaderDirs() []string {
 // represents any path.
 func (c *deviceConfig) JavaCoverageEnabledForPath(path string) bool {
 	coverage := false
-	if len(c.config.productVariables.JavaCoveragePaths) == 0 ||
-		InList("*", c.config.productVariables.JavaCoveragePaths) ||
-		HasAnyPrefix(path, c.config.productVariables.JavaCoveragePaths) {
+	javaCoveragePaths := c.config.javaCoveragePaths()
+	if len(javaCoveragePaths) == 0 ||
+		InList("*", javaCoveragePaths) ||
+		HasAnyPrefix(path, javaCoveragePaths) {
 		coverage = true
 	}
-	if coverage && len(c.config.productVariables.JavaCoverageExcludePaths) > 0 {
-		if HasAnyPrefix(path, c.config.productVariables.JavaCoverageExcludePaths) {
+	if excludePaths := c.config.javaCoverageExcludePaths(); coverage && len(excludePaths) > 0 {
+		if HasAnyPrefix(path, excludePaths) {
 			coverage = false
 		}
 	}
@@ -1423,18 +1558,19 @@ func (c *deviceConfig) GcovCoverageEnabled() bool {
 // NativeCoveragePaths represents any path.
 func (c *deviceConfig) NativeCoverageEnabledForPath(path string) bool {
 	coverage := false
-	if len(c.config.productVariables.NativeCoveragePaths) > 0 {
-		if InList("*", c.config.productVariables.NativeCoveragePaths) || HasAnyPrefix(path, c.config.productVariables.NativeCoveragePaths) {
+	if nativeCoveragePaths := c.config.nativeCoveragePaths(); len(nativeCoveragePaths) > 0 {
+		if InList("*", nativeCoveragePaths) || HasAnyPrefix(path, nativeCoveragePaths) {
 			coverage = true
 		}
 	}
-	if coverage && len(c.config.productVariables.NativeCoverageExcludePaths) > 0 {
+	excludePaths := c.config.nativeCoverageExcludePaths()
+	if coverage && len(excludePaths) > 0 {
 		// Workaround coverage boot failure.
 		// http://b/269981180
 		if strings.HasPrefix(path, "external/protobuf") {
 			coverage = false
 		}
-		if HasAnyPrefix(path, c.config.productVariables.NativeCoverageExcludePaths) {
+		if HasAnyPrefix(path, excludePaths) {
 			coverage = false
 		}
 	}
@@ -1445,6 +1581,12 @@ func (c *deviceConfig) PgoAdditionalProfileDirs() []string {
 	return c.config.productVariables.PgoAdditionalProfileDirs
 }
 
+// RustPgoProfileGenDir returns the directory on the device that the rust modules built with
+// profile generation instrumentation write their profiles to.
+func (c *deviceConfig) RustPgoProfileGenDir() string {
+	return StringDefault(c.config.productVariables.RustPgoProfileGenDir, "/data/local/tmp")
+}
+
 // AfdoProfile returns fully qualified path associated to the given module name
 func (c *deviceConfig) AfdoProfile(name string) (*string, error) {
 	for _, afdoProfile := range c.config.productVariables.AfdoProfiles {
@@ -1460,6 +1602,12 @@ func (c *deviceConfig) AfdoProfile(name string) (*string, error) {
 	return nil, nil
 }
 
+// AfdoProfileDirs returns the directories that are searched, in order, for the afdo profiles of the
+// modules that are not in AfdoProfiles.
+func (c *deviceConfig) AfdoProfileDirs() []string {
+	return c.config.productVariables.AfdoProfileDirs
+}
+
 func (c *deviceConfig) VendorSepolicyDirs() []string {
 	return c.config.productVariables.BoardVendorSepolicyDirs
 }
@@ -1571,10 +1719,58 @@ func (c *config) HWASanEnabledForPath(path string) bool {
 	return HasAnyPrefix(path, c.productVariables.HWASanIncludePaths)
 }
 
+func (c *config) GenruleStrictSandboxEnabledForPath(path string) bool {
+	if len(c.productVariables.GenruleStrictSandboxPaths) == 0 {
+		return false
+	}
+	return HasAnyPrefix(path, c.productVariables.GenruleStrictSandboxPaths)
+}
+
+// SubpackageSrcsAllowedForPath returns true if modules in the given directory may use sources in
+// the directories of other packages below it.
+func (c *config) SubpackageSrcsAllowedForPath(path string) bool {
+	return HasAnyPrefix(path, c.productVariables.SubpackageSrcsAllowedPaths)
+}
+
+// AnyApexAllowedForPath returns true if modules in the given directory may use
+// apex_available: ["//apex_available:anyapex"] when the apex_available audit is strict.
+func (c *config) AnyApexAllowedForPath(path string) bool {
+	return HasAnyPrefix(path, c.productVariables.AnyApexAllowedPaths)
+}
+
+// RustSecondaryVersion returns the version of the secondary rust toolchain, which the modules in
+// the directories returned by RustSecondaryVersionPaths are built with instead of the default
+// toolchain, or "" if there is no secondary toolchain.
+func (c *config) RustSecondaryVersion() string {
+	return String(c.productVariables.RustSecondaryVersion)
+}
+
+// RustSecondaryVersionEnabledForPath returns true if the rust modules in the given directory are
+// built with the secondary rust toolchain.
+func (c *config) RustSecondaryVersionEnabledForPath(path string) bool {
+	return c.RustSecondaryVersion() != "" && HasAnyPrefix(path, c.productVariables.RustSecondaryVersionPaths)
+}
+
+// PrebuiltProductTypes returns the install directories of the prebuilt_product_type module types
+// defined by the product, by the name of the type.
+func (c *config) PrebuiltProductTypes() map[string]string {
+	return c.productVariables.PrebuiltProductTypes
+}
+
 func (c *config) VendorConfig(name string) VendorConfig {
 	return soongconfig.Config(c.productVariables.VendorVars[name])
 }
 
+// BoolProductVariable returns the value of the boolean product variable with the given name, e.g.
+// "Debuggable".  ok is false if there is no boolean product variable with that name.
+func (c *config) BoolProductVariable(name string) (value bool, ok bool) {
+	v := reflect.ValueOf(c.productVariables).FieldByName(name)
+	if !v.IsValid() || v.Type() != reflect.TypeOf((*bool)(nil)) {
+		return false, false
+	}
+	return !v.IsNil() && v.Elem().Bool(), true
+}
+
 func (c *config) NdkAbis() bool {
 	return Bool(c.productVariables.Ndk_abis)
 }
@@ -1862,6 +2058,10 @@ func (c *deviceConfig) BuildBrokenInputDir(name string) bool {
 	return InList(name, c.config.productVariables.BuildBrokenInputDirModules)
 }
 
+func (c *deviceConfig) BuildBrokenUnbuildableProductPackages() bool {
+	return c.config.productVariables.BuildBrokenUnbuildableProductPackages
+}
+
 func (c *deviceConfig) BuildBrokenDepfile() bool {
 	return Bool(c.config.productVariables.BuildBrokenDepfile)
 }
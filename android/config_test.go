@@ -16,10 +16,12 @@ package android
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func validateConfigAnnotations(configurable jsonConfigurable) (err error) {
@@ -180,3 +182,94 @@ func TestConfiguredJarList(t *testing.T) {
 		assertStringEquals(t, "apex1:jarA", list5.String())
 	})
 }
+
+// buildDateTestModule reports an error once the build date reaches its expiry date, like the
+// date dependent checks that must use Config.Now.
+type buildDateTestModule struct {
+	ModuleBase
+	props struct {
+		Expires string
+	}
+}
+
+func buildDateTestModuleFactory() Module {
+	m := &buildDateTestModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	return m
+}
+
+func (m *buildDateTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	expires, err := time.Parse("2006-01-02", m.props.Expires)
+	if err != nil {
+		ctx.PropertyErrorf("expires", "%s", err)
+	} else if !ctx.Config().Now().Before(expires) {
+		ctx.PropertyErrorf("expires", "expired on %s", m.props.Expires)
+	}
+}
+
+func TestBuildDate(t *testing.T) {
+	preparer := GroupFixturePreparers(
+		prepareForModuleTests,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("build_date_test", buildDateTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(`
+			build_date_test {
+				name: "foo",
+				expires: "2024-06-01",
+			}
+		`),
+	)
+
+	t.Run("default", func(t *testing.T) {
+		result := preparer.RunTest(t)
+		AssertDeepEquals(t, "build date", defaultTestBuildDate, result.Config.Now())
+	})
+
+	t.Run("before expiry", func(t *testing.T) {
+		date := time.Date(2024, time.May, 31, 23, 0, 0, 0, time.UTC)
+		result := GroupFixturePreparers(preparer, FixtureSetBuildDate(date)).RunTest(t)
+		AssertDeepEquals(t, "build date", date, result.Config.Now())
+		AssertIntEquals(t, "build date epoch", int(date.Unix()), int(result.Config.BuildDateEpoch()))
+	})
+
+	t.Run("after expiry", func(t *testing.T) {
+		GroupFixturePreparers(
+			preparer,
+			FixtureSetBuildDate(time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)),
+		).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`^expires: expired on 2024-06-01$`,
+		)).RunTest(t)
+	})
+}
+
+func TestReadBuildDate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "build_date.txt")
+	if err := os.WriteFile(file, []byte("1700000000\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	date, err := readBuildDate(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertIntEquals(t, "build date epoch", 1700000000, int(date.Unix()))
+
+	// The build date defaults to the current time without a file.
+	before := time.Now()
+	date, err = readBuildDate(filepath.Join(dir, "missing.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if date.Before(before) {
+		t.Errorf("expected the current time, got %s", date)
+	}
+
+	if err := os.WriteFile(file, []byte("yesterday"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readBuildDate(file); err == nil {
+		t.Errorf("expected an error for an invalid build date")
+	}
+}
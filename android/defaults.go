@@ -27,6 +27,20 @@ type defaultsDependencyTag struct {
 
 var DefaultsDepTag defaultsDependencyTag
 
+// The defaults only add properties to the modules that use them, their licenses are applied to
+// the modules through the properties rather than through the dependency.
+func (defaultsDependencyTag) PropagatesLicenses() bool {
+	return false
+}
+
+var _ LicensePropagatingDependencyTag = DefaultsDepTag
+
+func (defaultsDependencyTag) DependencyProperty() string {
+	return "defaults"
+}
+
+var _ PropertyDependencyTag = DefaultsDepTag
+
 type defaultsProperties struct {
 	Defaults []string
 }
@@ -38,12 +52,24 @@ type DefaultableModuleBase struct {
 
 	// The optional hook to call after any defaults have been applied.
 	hook DefaultableHook
+
+	// The defaults modules that were applied, used to find where inherited properties are defined.
+	appliedDefaults []appliedDefaults
+}
+
+// appliedDefaults identifies a defaults module that was applied to a defaultable module.
+type appliedDefaults struct {
+	module Defaults
 }
 
 func (d *DefaultableModuleBase) defaults() *defaultsProperties {
 	return &d.defaultsProperties
 }
 
+func (d *DefaultableModuleBase) defaultsApplied() []appliedDefaults {
+	return d.appliedDefaults
+}
+
 func (d *DefaultableModuleBase) setProperties(props []interface{}, variableProperties interface{}) {
 	d.defaultableProperties = props
 	d.defaultableVariableProperties = variableProperties
@@ -71,6 +97,9 @@ type Defaultable interface {
 	// setProperties(...).
 	applyDefaults(TopDownMutatorContext, []Defaults)
 
+	// Get the defaults modules that were applied by applyDefaults, nearest first.
+	defaultsApplied() []appliedDefaults
+
 	// Set the hook to be called after any defaults have been applied.
 	//
 	// Should be used in preference to a AddLoadHook when the behavior of the load
@@ -272,6 +301,9 @@ func (defaultable *DefaultableModuleBase) applyDefaults(ctx TopDownMutatorContex
 	defaultsList []Defaults) {
 
 	for _, defaults := range defaultsList {
+		defaultable.appliedDefaults = append(defaultable.appliedDefaults, appliedDefaults{
+			module: defaults,
+		})
 		if ctx.Config().BuildMode == Bp2build {
 			applyNamespacedVariableDefaults(defaults, ctx)
 		}
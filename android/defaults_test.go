@@ -15,11 +15,15 @@
 package android
 
 import (
+	"regexp"
 	"testing"
 )
 
 type defaultsTestProperties struct {
 	Foo []string
+
+	// Reports an error if set to "error" or "error_with_pos".
+	Bar *string
 }
 
 type defaultsTestModule struct {
@@ -29,6 +33,12 @@ type defaultsTestModule struct {
 }
 
 func (d *defaultsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	switch String(d.properties.Bar) {
+	case "error":
+		ctx.PropertyErrorf("bar", "bad value")
+	case "error_with_pos":
+		ctx.PropertyErrorWithPosf("bar", "bad value")
+	}
 	ctx.Build(pctx, BuildParams{
 		Rule:   Touch,
 		Output: PathForModuleOut(ctx, "out"),
@@ -130,3 +140,64 @@ func TestDefaultsAllowMissingDependencies(t *testing.T) {
 	// TODO: missing transitive defaults is currently not handled
 	_ = missingTransitiveDefaults
 }
+
+func TestDefaultsPropertyErrorPosition(t *testing.T) {
+	defaultsBp := `
+		defaults {
+			name: "error_defaults",
+			bar: "error",
+		}
+
+		defaults {
+			name: "error_with_pos_defaults",
+			bar: "error_with_pos",
+		}
+	`
+
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "inherited",
+			bp: `
+				test {
+					name: "foo",
+					defaults: ["error_defaults"],
+				}`,
+			error: `Android.bp:2:5: module "foo": bar: bad value (inherited from "error_defaults")`,
+		},
+		{
+			name: "inherited with pos",
+			bp: `
+				test {
+					name: "foo",
+					defaults: ["error_with_pos_defaults"],
+				}`,
+			error: `defaults/Android.bp:9:7: module "error_with_pos_defaults": bar: inherited by module "foo": bad value`,
+		},
+		{
+			name: "overridden",
+			bp: `
+				test {
+					name: "foo",
+					defaults: ["error_with_pos_defaults"],
+					bar: "error",
+				}`,
+			error: `Android.bp:5:9: module "foo": bar: bad value`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			GroupFixturePreparers(
+				prepareForDefaultsTest,
+				FixtureAddTextFile("defaults/Android.bp", defaultsBp),
+				FixtureWithRootAndroidBp(tc.bp),
+			).
+				ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(regexp.QuoteMeta(tc.error))).
+				RunTest(t)
+		})
+	}
+}
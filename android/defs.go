@@ -237,6 +237,10 @@ func ContentFromFileRuleForTests(t *testing.T, params TestingBuildParams) string
 		return ""
 	}
 
+	return contentFromFileRule(params)
+}
+
+func contentFromFileRule(params TestingBuildParams) string {
 	content := params.Args["content"]
 	content = shellUnescape(content)
 	content = echoUnescaper.Replace(content)
@@ -0,0 +1,91 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+// This file implements StringsDepSet, a thin type-safe wrapper around depSet that contains strings,
+// for providers that aggregate flags or other strings from transitive dependencies.
+
+// A StringsDepSet efficiently stores strings from transitive dependencies without copying. It is
+// stored as a DAG of StringsDepSet nodes, each of which has some direct contents and a list of
+// dependency StringsDepSet nodes.  The DAG is only flattened when ToList() is called.
+//
+// A StringsDepSet has the same orders as DepSet: POSTORDER, PREORDER, or TOPOLOGICAL.  A module
+// that exports the strings of its dependencies after its own, in the order that it visited them,
+// should use PREORDER, which returns exactly FirstUniqueStrings of the direct contents followed by
+// the flattened contents of each transitive StringsDepSet from left to right.
+//
+// A StringsDepSet is created by NewStringsDepSet or NewStringsDepSetBuilder.Build from the strings
+// for direct contents and the *StringsDepSets of dependencies. A StringsDepSet is immutable once
+// created.
+type StringsDepSet struct {
+	depSet
+}
+
+// StringsDepSetBuilder is used to create an immutable StringsDepSet.
+type StringsDepSetBuilder struct {
+	depSetBuilder
+}
+
+// NewStringsDepSet returns an immutable StringsDepSet with the given order, direct and transitive
+// contents.
+func NewStringsDepSet(order DepSetOrder, direct []string, transitive []*StringsDepSet) *StringsDepSet {
+	return &StringsDepSet{*newDepSet(order, direct, transitive)}
+}
+
+// NewStringsDepSetBuilder returns a StringsDepSetBuilder to create an immutable StringsDepSet with
+// the given order.
+func NewStringsDepSetBuilder(order DepSetOrder) *StringsDepSetBuilder {
+	return &StringsDepSetBuilder{*newDepSetBuilder(order, []string(nil))}
+}
+
+// Direct adds direct contents to the StringsDepSet being built by a StringsDepSetBuilder. Newly
+// added direct contents are to the right of any existing direct contents.
+func (b *StringsDepSetBuilder) Direct(direct ...string) *StringsDepSetBuilder {
+	b.depSetBuilder.DirectSlice(direct)
+	return b
+}
+
+// Transitive adds transitive contents to the StringsDepSet being built by a StringsDepSetBuilder.
+// Newly added transitive contents are to the right of any existing transitive contents.
+func (b *StringsDepSetBuilder) Transitive(transitive ...*StringsDepSet) *StringsDepSetBuilder {
+	b.depSetBuilder.Transitive(transitive)
+	return b
+}
+
+// Returns the StringsDepSet being built by this StringsDepSetBuilder.  The StringsDepSetBuilder
+// retains its contents for creating more StringsDepSets.
+func (b *StringsDepSetBuilder) Build() *StringsDepSet {
+	return &StringsDepSet{*b.depSetBuilder.Build()}
+}
+
+// ToList returns the StringsDepSet flattened to a list, with duplicates removed.  The order in the
+// list is based on the order of the StringsDepSet, as for DepSet.ToList.
+func (d *StringsDepSet) ToList() []string {
+	if d == nil {
+		return nil
+	}
+	return d.toList(func(strings interface{}) interface{} {
+		return FirstUniqueStrings(strings.([]string))
+	}).([]string)
+}
+
+// ToSortedList returns the direct and transitive contents of a StringsDepSet in lexically sorted
+// order with duplicates removed.
+func (d *StringsDepSet) ToSortedList() []string {
+	if d == nil {
+		return nil
+	}
+	return SortedUniqueStrings(d.ToList())
+}
@@ -62,6 +62,16 @@ func ExampleDepSet_ToSortedList() {
 	// Output: [a b c d]
 }
 
+func ExampleStringsDepSet_ToList_preordered() {
+	a := NewStringsDepSetBuilder(PREORDER).Direct("a").Build()
+	b := NewStringsDepSetBuilder(PREORDER).Direct("b").Transitive(a).Build()
+	c := NewStringsDepSetBuilder(PREORDER).Direct("c").Transitive(a).Build()
+	d := NewStringsDepSetBuilder(PREORDER).Direct("d").Transitive(b, c).Build()
+
+	fmt.Println(d.ToList())
+	// Output: [d b a c]
+}
+
 // Tests based on Bazel's ExpanderTestBase.java to ensure compatibility
 // https://github.com/bazelbuild/bazel/blob/master/src/test/java/com/google/devtools/build/lib/collect/nestedset/ExpanderTestBase.java
 func TestDepSet(t *testing.T) {
@@ -388,3 +398,79 @@ func Benchmark_firstUnique(b *testing.B) {
 		})
 	}
 }
+
+func TestStringsDepSet(t *testing.T) {
+	a := NewStringsDepSet(TOPOLOGICAL, []string{"-a"}, nil)
+	b := NewStringsDepSet(TOPOLOGICAL, []string{"-b", "-a"}, []*StringsDepSet{a})
+	c := NewStringsDepSet(TOPOLOGICAL, []string{"-c"}, []*StringsDepSet{a})
+	d := NewStringsDepSetBuilder(TOPOLOGICAL).Direct("-d").Transitive(b, c).Build()
+
+	AssertArrayString(t, "topological", []string{"-d", "-b", "-c", "-a"}, d.ToList())
+	AssertArrayString(t, "sorted", []string{"-a", "-b", "-c", "-d"}, d.ToSortedList())
+	AssertArrayString(t, "nil", nil, (*StringsDepSet)(nil).ToList())
+
+	// Providers that used to append the flattened lists of their dependencies and their own
+	// strings in the order they visited them, and then call FirstUniqueStrings, keep the same
+	// order by wrapping each group of strings in its own PREORDER StringsDepSet.
+	e := NewStringsDepSet(PREORDER, []string{"-e", "-a"}, nil)
+	f := NewStringsDepSet(PREORDER, []string{"-f"}, []*StringsDepSet{e})
+	g := NewStringsDepSet(PREORDER, []string{"-g", "-e"}, []*StringsDepSet{e})
+	visited := []*StringsDepSet{
+		g,
+		NewStringsDepSet(PREORDER, []string{"-a", "-h"}, nil),
+		f,
+	}
+	var appended []string
+	appended = append(appended, g.ToList()...)
+	appended = append(appended, "-a", "-h")
+	appended = append(appended, f.ToList()...)
+
+	got := NewStringsDepSet(PREORDER, []string{"-i", "-g"}, visited).ToList()
+	AssertArrayString(t, "preorder", FirstUniqueStrings(append([]string{"-i", "-g"}, appended...)), got)
+	AssertArrayString(t, "preorder", []string{"-i", "-g", "-e", "-a", "-h", "-f"}, got)
+}
+
+// Benchmark_transitiveStrings compares aggregating the strings of a deep graph of dependencies,
+// in which every module depends on the previous few modules, by appending the flattened lists of
+// the dependencies to a list of strings and removing the duplicates, like the providers used to,
+// with aggregating them in a StringsDepSet.
+func Benchmark_transitiveStrings(b *testing.B) {
+	const fanout = 4
+
+	for n := 16; n <= 1024; n <<= 2 {
+		direct := make([][]string, n)
+		for i := range direct {
+			direct[i] = []string{"-L" + strconv.Itoa(i), "-lcommon"}
+		}
+
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			b.Run("append", func(b *testing.B) {
+				b.ReportAllocs()
+				for iter := 0; iter < b.N; iter++ {
+					lists := make([][]string, n)
+					for i := range lists {
+						list := append([]string(nil), direct[i]...)
+						for j := i - 1; j >= 0 && j >= i-fanout; j-- {
+							list = append(list, lists[j]...)
+						}
+						lists[i] = FirstUniqueStrings(list)
+					}
+				}
+			})
+			b.Run("depset", func(b *testing.B) {
+				b.ReportAllocs()
+				for iter := 0; iter < b.N; iter++ {
+					depSets := make([]*StringsDepSet, n)
+					for i := range depSets {
+						builder := NewStringsDepSetBuilder(PREORDER).Direct(direct[i]...)
+						for j := i - 1; j >= 0 && j >= i-fanout; j-- {
+							builder.Transitive(depSets[j])
+						}
+						depSets[i] = builder.Build()
+					}
+					depSets[n-1].ToList()
+				}
+			})
+		})
+	}
+}
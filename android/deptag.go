@@ -14,7 +14,11 @@
 
 package android
 
-import "github.com/google/blueprint"
+import (
+	"fmt"
+
+	"github.com/google/blueprint"
+)
 
 // Dependency tags can implement this interface and return true from InstallDepNeeded to annotate
 // that the installed files of the parent should depend on the installed files of the child.
@@ -43,3 +47,96 @@ func IsInstallDepNeededTag(tag blueprint.DependencyTag) bool {
 	}
 	return false
 }
+
+// Dependency tags can implement this interface to describe the dependency in terms of the property
+// that it was added for, so that dependency paths in error messages can be acted on by users.
+type PropertyDependencyTag interface {
+	// DependencyProperty returns a description of the property the dependency was added for.
+	DependencyProperty() string
+}
+
+// DependencyTagDescription returns the property that a dependency with the given tag was added
+// for, or the type of the tag if it does not implement PropertyDependencyTag.
+func DependencyTagDescription(tag blueprint.DependencyTag) string {
+	if p, ok := tag.(PropertyDependencyTag); ok {
+		return p.DependencyProperty()
+	}
+	return fmt.Sprintf("%T", tag)
+}
+
+// Dependency tags can implement this interface and return false from PropagatesLicenses to
+// annotate that the license metadata of the parent does not depend on the license metadata of the
+// child, e.g. for dependencies that only contribute properties to the parent.
+type LicensePropagatingDependencyTag interface {
+	// If PropagatesLicenses returns false then the license metadata of the child will not be
+	// propagated to the parent.
+	PropagatesLicenses() bool
+}
+
+// DependencyTagCapabilities describes how a dependency is treated by the code that walks the
+// dependencies of modules, as annotated by the capability interfaces implemented by its dependency
+// tag.  Checking the capabilities instead of comparing against the concrete types of dependency
+// tags allows tags defined in any package to opt into or out of the behaviors.
+type DependencyTagCapabilities struct {
+	// InstallDepNeeded is true if the installed files of the parent depend on the installed files
+	// of the child, see InstallNeededDependencyTag.
+	InstallDepNeeded bool
+
+	// PackagingItem is true if the child is packaged by a packaging module parent, see
+	// PackagingItem.
+	PackagingItem bool
+
+	// PropagatesLicenses is true if the license metadata of the child propagates to the parent,
+	// see LicensePropagatingDependencyTag.
+	PropagatesLicenses bool
+
+	// LicenseAnnotations are the annotations of the license metadata dependency, see
+	// LicenseAnnotationsDependencyTag.
+	LicenseAnnotations []LicenseAnnotation
+
+	// ExcludeFromApexContents is true if the child is never part of the same apex as the parent,
+	// see ExcludeFromApexContentsTag.
+	ExcludeFromApexContents bool
+
+	// AlwaysRequireApexVariant is true if the child needs an apex variant for every apex variant
+	// of the parent, see AlwaysRequireApexVariantTag.
+	AlwaysRequireApexVariant bool
+
+	// CopyDirectlyInAnyApex is true if the child inherits the DirectlyInAnyApex state of the
+	// parent, see CopyDirectlyInAnyApexTag.
+	CopyDirectlyInAnyApex bool
+
+	// ExcludeFromVisibilityEnforcement is true if the dependency is not checked against the
+	// visibility rules of the child, see ExcludeFromVisibilityEnforcementTag.
+	ExcludeFromVisibilityEnforcement bool
+}
+
+// DependencyTagCapabilitiesOf returns the capabilities of the dependency tag.
+func DependencyTagCapabilitiesOf(tag blueprint.DependencyTag) DependencyTagCapabilities {
+	c := DependencyTagCapabilities{
+		InstallDepNeeded:   IsInstallDepNeededTag(tag),
+		PropagatesLicenses: true,
+	}
+	if pi, ok := tag.(PackagingItem); ok {
+		c.PackagingItem = pi.IsPackagingItem()
+	}
+	if lp, ok := tag.(LicensePropagatingDependencyTag); ok {
+		c.PropagatesLicenses = lp.PropagatesLicenses()
+	}
+	if la, ok := tag.(LicenseAnnotationsDependencyTag); ok {
+		c.LicenseAnnotations = la.LicenseAnnotations()
+	}
+	if _, ok := tag.(ExcludeFromApexContentsTag); ok {
+		c.ExcludeFromApexContents = true
+	}
+	if ar, ok := tag.(AlwaysRequireApexVariantTag); ok {
+		c.AlwaysRequireApexVariant = ar.AlwaysRequireApexVariant()
+	}
+	if _, ok := tag.(CopyDirectlyInAnyApexTag); ok {
+		c.CopyDirectlyInAnyApex = true
+	}
+	if _, ok := tag.(ExcludeFromVisibilityEnforcementTag); ok {
+		c.ExcludeFromVisibilityEnforcement = true
+	}
+	return c
+}
@@ -132,3 +132,32 @@ func TestInstallDependencyTag(t *testing.T) {
 		t.Errorf("expected no device dependency %q, got %q", w, g)
 	}
 }
+
+type capabilitiesTestDepTag struct {
+	blueprint.BaseDependencyTag
+	InstallAlwaysNeededDependencyTag
+	PackagingItemAlwaysDepTag
+	LicenseAnnotationToolchainDependencyTag
+}
+
+func (capabilitiesTestDepTag) ExcludeFromApexContents() {}
+
+func (capabilitiesTestDepTag) PropagatesLicenses() bool {
+	return false
+}
+
+func TestDependencyTagCapabilities(t *testing.T) {
+	AssertDeepEquals(t, "tag without capabilities", DependencyTagCapabilities{
+		PropagatesLicenses: true,
+	}, DependencyTagCapabilitiesOf(blueprint.BaseDependencyTag{}))
+
+	AssertDeepEquals(t, "tag with capabilities", DependencyTagCapabilities{
+		InstallDepNeeded:        true,
+		PackagingItem:           true,
+		LicenseAnnotations:      []LicenseAnnotation{LicenseAnnotationToolchain},
+		ExcludeFromApexContents: true,
+	}, DependencyTagCapabilitiesOf(capabilitiesTestDepTag{}))
+
+	AssertBoolEquals(t, "defaults propagate licenses", false,
+		DependencyTagCapabilitiesOf(DefaultsDepTag).PropagatesLicenses)
+}
@@ -0,0 +1,272 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// Experiments build an extra variant of the modules in some paths with extra flags, for example a
+// new optimization flag or compiler option, next to the normal variant of the modules so that the
+// two can be compared in a single build without changing any Android.bp files.  They are listed in
+// the Experiments section of soong.variables.
+//
+// The experiment mutator creates an experiment-<name> variant of every module that implements
+// ExperimentModule in the paths of the experiment.  The experiment variants are not installed and
+// not exported to Make, and are built by the experiment-<name>-<module> phony targets, which also
+// build the normal variants they are compared with.  The dependencies of an experiment variant
+// use the experiment variant of the dependency if it has one, and its normal variant otherwise.
+//
+// The experiment-<name> phony target builds all the modules of the experiment and
+// out/soong/experiments/<name>.json, which lists the outputs of the normal and experiment variants
+// of every module of the experiment.
+
+// ExperimentConfig is an experiment in the Experiments section of soong.variables.
+type ExperimentConfig struct {
+	// Name is the name of the experiment, used in the names of its variants and phony targets.
+	Name string
+
+	// Paths are the directories of the modules that are built in the experiment, including their
+	// subdirectories, or "*" for all directories.
+	Paths []string
+
+	// Cflags are added to the flags of the C and C++ compiles of the experiment variants.
+	Cflags []string `json:",omitempty"`
+
+	// Rustflags are added to the flags of the rustc compiles of the experiment variants.
+	Rustflags []string `json:",omitempty"`
+}
+
+// matchesPath returns true if path is one of the paths of the experiment or one of their
+// subdirectories.
+func (e ExperimentConfig) matchesPath(path string) bool {
+	for _, p := range e.Paths {
+		p = strings.TrimSuffix(p, "/")
+		if p == "*" || path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// duplicateExperimentNames returns the names of the experiments that are listed more than once.
+func duplicateExperimentNames(experiments []ExperimentConfig) []string {
+	seen := make(map[string]bool)
+	var duplicates []string
+	for _, e := range experiments {
+		if seen[e.Name] && !InList(e.Name, duplicates) {
+			duplicates = append(duplicates, e.Name)
+		}
+		seen[e.Name] = true
+	}
+	return duplicates
+}
+
+// ExperimentModule is implemented by modules that can be built in an experiment variant.
+type ExperimentModule interface {
+	Module
+
+	// ExperimentSupported returns true if the module is compiled with flags that an experiment can
+	// change.
+	ExperimentSupported() bool
+
+	// SetExperiment is called on the experiment variants of the module with the name of their
+	// experiment.  It must prevent the variant from being installed and exported to Make.
+	SetExperiment(name string)
+
+	// Experiment returns the name of the experiment of the variant, or "" for the normal variant.
+	Experiment() string
+
+	// OutputFile returns the output of the variant that is compared between the normal and the
+	// experiment variants.
+	OutputFile() OptionalPath
+}
+
+const experimentVariationPrefix = "experiment-"
+
+type experimentDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+// ExperimentBaselineTag is the tag of the dependency of an experiment variant on the normal variant
+// of the same module that it is compared with.  It does not affect the way that the experiment
+// variant is built.
+var ExperimentBaselineTag = experimentDependencyTag{}
+
+func init() {
+	RegisterExperimentBuildComponents(InitRegistrationContext)
+}
+
+func RegisterExperimentBuildComponents(ctx RegistrationContext) {
+	ctx.PostDepsMutators(func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("experiment", experimentMutator).Parallel()
+	})
+	ctx.RegisterSingletonType("experiments", experimentSingletonFactory)
+}
+
+var PrepareForTestWithExperiments = FixtureRegisterWithContext(RegisterExperimentBuildComponents)
+
+// FixtureAddExperiment adds an experiment to the product variables.
+func FixtureAddExperiment(experiment ExperimentConfig) FixturePreparer {
+	return FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		variables.Experiments = append(variables.Experiments, experiment)
+	})
+}
+
+func experimentMutator(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(ExperimentModule)
+	if !ok || !m.Enabled() || !m.ExperimentSupported() {
+		return
+	}
+
+	var experiments []string
+	for _, e := range ctx.Config().Experiments() {
+		// The experiments listed more than once are reported by the experiments singleton.
+		if e.matchesPath(ctx.ModuleDir()) && !InList(e.Name, experiments) {
+			experiments = append(experiments, e.Name)
+		}
+	}
+	if len(experiments) == 0 {
+		return
+	}
+
+	// Dependencies of experiment variants on modules that are not built in the same experiment use
+	// the normal variant.
+	defaultVariation := ""
+	ctx.SetDefaultDependencyVariation(&defaultVariation)
+
+	variations := []string{defaultVariation}
+	for _, name := range experiments {
+		variations = append(variations, experimentVariationPrefix+name)
+	}
+	modules := ctx.CreateVariations(variations...)
+	for i, name := range experiments {
+		modules[i+1].(ExperimentModule).SetExperiment(name)
+		ctx.AddInterVariantDependency(ExperimentBaselineTag, modules[i+1], modules[0])
+	}
+}
+
+// ExperimentInfo is provided by the experiment variants of modules.
+type ExperimentInfo struct {
+	// Experiment is the name of the experiment of the variant.
+	Experiment string
+
+	// Output is the output of the experiment variant.
+	Output Path
+
+	// Baseline is the output of the normal variant that the experiment variant is compared with.
+	Baseline Path
+}
+
+var ExperimentInfoProvider = blueprint.NewProvider(ExperimentInfo{})
+
+// setExperimentInfo sets the ExperimentInfoProvider of the experiment variants of modules, after
+// the outputs of the variant and of its normal variant have been generated.
+func setExperimentInfo(ctx ModuleContext) {
+	m, ok := ctx.Module().(ExperimentModule)
+	if !ok || m.Experiment() == "" || !m.OutputFile().Valid() {
+		return
+	}
+
+	var baseline OptionalPath
+	ctx.VisitDirectDepsWithTag(ExperimentBaselineTag, func(dep Module) {
+		baseline = dep.(ExperimentModule).OutputFile()
+	})
+	if !baseline.Valid() {
+		return
+	}
+
+	ctx.SetProvider(ExperimentInfoProvider, ExperimentInfo{
+		Experiment: m.Experiment(),
+		Output:     m.OutputFile().Path(),
+		Baseline:   baseline.Path(),
+	})
+}
+
+// ExperimentPhonyName returns the name of the phony target that builds the experiment variant of a
+// module and the normal variant it is compared with.
+func ExperimentPhonyName(experiment, module string) string {
+	return experimentVariationPrefix + experiment + "-" + module
+}
+
+type experimentReportPair struct {
+	Module     string `json:"module"`
+	Variant    string `json:"variant"`
+	Baseline   string `json:"baseline"`
+	Experiment string `json:"experiment"`
+}
+
+func experimentSingletonFactory() Singleton {
+	return &experimentSingleton{}
+}
+
+type experimentSingleton struct{}
+
+func (s *experimentSingleton) GenerateBuildActions(ctx SingletonContext) {
+	experiments := ctx.Config().Experiments()
+	if len(experiments) == 0 {
+		return
+	}
+	if duplicates := duplicateExperimentNames(experiments); len(duplicates) > 0 {
+		for _, name := range duplicates {
+			ctx.Errorf("experiment %q is listed more than once in the Experiments product variable", name)
+		}
+		return
+	}
+
+	pairs := make(map[string][]experimentReportPair)
+	outputs := make(map[string]Paths)
+	ctx.VisitAllModules(func(module Module) {
+		if !ctx.ModuleHasProvider(module, ExperimentInfoProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, ExperimentInfoProvider).(ExperimentInfo)
+		name := ctx.ModuleName(module)
+		ctx.Phony(ExperimentPhonyName(info.Experiment, name), info.Baseline, info.Output)
+		pairs[info.Experiment] = append(pairs[info.Experiment], experimentReportPair{
+			Module:     name,
+			Variant:    ctx.ModuleSubDir(module),
+			Baseline:   info.Baseline.String(),
+			Experiment: info.Output.String(),
+		})
+		outputs[info.Experiment] = append(outputs[info.Experiment], info.Baseline, info.Output)
+	})
+
+	for _, e := range experiments {
+		list := pairs[e.Name]
+		if list == nil {
+			list = []experimentReportPair{}
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Module != list[j].Module {
+				return list[i].Module < list[j].Module
+			}
+			return list[i].Variant < list[j].Variant
+		})
+
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			ctx.Errorf("failed to marshal the report of experiment %q: %s", e.Name, err)
+			return
+		}
+		report := PathForOutput(ctx, "experiments", e.Name+".json")
+		WriteFileRule(ctx, report, string(data))
+		ctx.Phony(experimentVariationPrefix+e.Name, append(Paths{report}, outputs[e.Name]...)...)
+	}
+}
@@ -0,0 +1,248 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type experimentTestModule struct {
+	ModuleBase
+	properties struct {
+		Deps []string
+
+		Experiment string `blueprint:"mutated"`
+	}
+	outputFile OptionalPath
+}
+
+var experimentTestDepTag = struct {
+	blueprint.BaseDependencyTag
+}{}
+
+func experimentTestModuleFactory() Module {
+	module := &experimentTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, DeviceSupported, MultilibFirst)
+	return module
+}
+
+func (m *experimentTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddDependency(ctx.Module(), experimentTestDepTag, m.properties.Deps...)
+}
+
+func (m *experimentTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	var deps Paths
+	ctx.VisitDirectDepsWithTag(experimentTestDepTag, func(dep Module) {
+		deps = append(deps, dep.(*experimentTestModule).outputFile.Path())
+	})
+
+	out := PathForModuleOut(ctx, ctx.ModuleName())
+	ctx.Build(pctx, BuildParams{
+		Rule:      Touch,
+		Output:    out,
+		Implicits: deps,
+	})
+	m.outputFile = OptionalPathForPath(out)
+}
+
+func (m *experimentTestModule) ExperimentSupported() bool {
+	return true
+}
+
+func (m *experimentTestModule) SetExperiment(name string) {
+	m.properties.Experiment = name
+	m.HideFromMake()
+	m.SkipInstall()
+}
+
+func (m *experimentTestModule) Experiment() string {
+	return m.properties.Experiment
+}
+
+func (m *experimentTestModule) OutputFile() OptionalPath {
+	return m.outputFile
+}
+
+var prepareForExperimentTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	PrepareForTestWithExperiments,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("test", experimentTestModuleFactory)
+	}),
+	FixtureAddExperiment(ExperimentConfig{
+		Name:  "foo",
+		Paths: []string{"exp"},
+	}),
+	FixtureAddTextFile("exp/Android.bp", `
+		test {
+			name: "lib",
+		}
+
+		test {
+			name: "bin",
+			deps: ["lib", "other"],
+		}
+	`),
+	FixtureAddTextFile("Android.bp", `
+		test {
+			name: "other",
+		}
+	`),
+)
+
+func TestExperimentVariants(t *testing.T) {
+	result := prepareForExperimentTest.RunTest(t)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	AssertArrayString(t, "lib variants", []string{variant, experimentVariant},
+		result.ModuleVariantsForTests("lib"))
+	AssertArrayString(t, "other variants", []string{variant},
+		result.ModuleVariantsForTests("other"))
+
+	lib := result.ModuleForTests("lib", variant).Module().(*experimentTestModule)
+	AssertStringEquals(t, "normal variant experiment", "", lib.Experiment())
+	AssertBoolEquals(t, "normal variant hidden from make", false, lib.IsHideFromMake())
+
+	libExperiment := result.ModuleForTests("lib", experimentVariant).Module().(*experimentTestModule)
+	AssertStringEquals(t, "experiment variant experiment", "foo", libExperiment.Experiment())
+	AssertBoolEquals(t, "experiment variant hidden from make", true, libExperiment.IsHideFromMake())
+	AssertBoolEquals(t, "experiment variant skips install", true, libExperiment.IsSkipInstall())
+}
+
+func TestExperimentPaths(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForExperimentTest,
+		FixtureAddTextFile("exp/sub/Android.bp", `
+			test {
+				name: "sub",
+			}
+		`),
+		FixtureAddTextFile("experimental/Android.bp", `
+			test {
+				name: "experimental",
+			}
+		`),
+	).RunTest(t)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	// The paths of an experiment match directories, not prefixes of their names.
+	AssertArrayString(t, "sub variants", []string{variant, experimentVariant},
+		result.ModuleVariantsForTests("sub"))
+	AssertArrayString(t, "experimental variants", []string{variant},
+		result.ModuleVariantsForTests("experimental"))
+}
+
+func TestExperimentDuplicateNames(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForExperimentTest,
+		FixtureAddExperiment(ExperimentConfig{
+			Name:  "foo",
+			Paths: []string{"exp"},
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`experiment "foo" is listed more than once in the Experiments product variable`,
+	)).RunTest(t)
+}
+
+func TestExperimentDependencies(t *testing.T) {
+	result := prepareForExperimentTest.RunTest(t)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	bin := result.ModuleForTests("bin", variant).Output("bin")
+	AssertPathsRelativeToTopEquals(t, "normal variant deps", []string{
+		"out/soong/.intermediates/exp/lib/" + variant + "/lib",
+		"out/soong/.intermediates/other/" + variant + "/other",
+	}, bin.Implicits)
+
+	// The experiment variant uses the experiment variant of lib, and the normal variant of other
+	// which is not in the paths of the experiment.
+	binExperiment := result.ModuleForTests("bin", experimentVariant).Output("bin")
+	AssertPathsRelativeToTopEquals(t, "experiment variant deps", []string{
+		"out/soong/.intermediates/exp/lib/" + experimentVariant + "/lib",
+		"out/soong/.intermediates/other/" + variant + "/other",
+	}, binExperiment.Implicits)
+}
+
+func TestExperimentPhonyTargets(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForExperimentTest,
+		FixtureAddExperiment(ExperimentConfig{
+			Name:  "bar",
+			Paths: []string{"unused"},
+		}),
+	).RunTest(t)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	phonies := getPhonyMap(result.Config)
+	AssertPathsRelativeToTopEquals(t, "experiment-foo-bin", []string{
+		"out/soong/.intermediates/exp/bin/" + variant + "/bin",
+		"out/soong/.intermediates/exp/bin/" + experimentVariant + "/bin",
+	}, phonies["experiment-foo-bin"])
+	AssertPathsRelativeToTopEquals(t, "experiment-foo-lib", []string{
+		"out/soong/.intermediates/exp/lib/" + variant + "/lib",
+		"out/soong/.intermediates/exp/lib/" + experimentVariant + "/lib",
+	}, phonies["experiment-foo-lib"])
+	if _, exists := phonies["experiment-foo-other"]; exists {
+		t.Errorf("unexpected experiment-foo-other target: %q", phonies["experiment-foo-other"].Strings())
+	}
+
+	singleton := result.SingletonForTests("experiments")
+	report := singleton.Output("experiments/foo.json")
+	var pairs []experimentReportPair
+	if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, report)), &pairs); err != nil {
+		t.Fatalf("failed to parse the report: %s", err)
+	}
+	for i := range pairs {
+		pairs[i].Baseline = StringPathRelativeToTop(result.Config.SoongOutDir(), pairs[i].Baseline)
+		pairs[i].Experiment = StringPathRelativeToTop(result.Config.SoongOutDir(), pairs[i].Experiment)
+	}
+	AssertDeepEquals(t, "report", []experimentReportPair{
+		{
+			Module:     "bin",
+			Variant:    experimentVariant,
+			Baseline:   "out/soong/.intermediates/exp/bin/" + variant + "/bin",
+			Experiment: "out/soong/.intermediates/exp/bin/" + experimentVariant + "/bin",
+		},
+		{
+			Module:     "lib",
+			Variant:    experimentVariant,
+			Baseline:   "out/soong/.intermediates/exp/lib/" + variant + "/lib",
+			Experiment: "out/soong/.intermediates/exp/lib/" + experimentVariant + "/lib",
+		},
+	}, pairs)
+	AssertPathsRelativeToTopEquals(t, "experiment-foo", []string{
+		"out/soong/.intermediates/exp/bin/" + variant + "/bin",
+		"out/soong/.intermediates/exp/bin/" + experimentVariant + "/bin",
+		"out/soong/.intermediates/exp/lib/" + variant + "/lib",
+		"out/soong/.intermediates/exp/lib/" + experimentVariant + "/lib",
+		"out/soong/experiments/foo.json",
+	}, SortedUniquePaths(phonies["experiment-foo"]))
+
+	// An experiment without modules still has an empty report.
+	AssertStringEquals(t, "experiment-bar report", "[]",
+		ContentFromFileRuleForTests(t, singleton.Output("experiments/bar.json")))
+}
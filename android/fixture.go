@@ -16,9 +16,14 @@ package android
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/blueprint"
 )
 
 // Provides support for creating test fixtures on which tests can be run. Reduces duplication
@@ -51,7 +56,9 @@ import (
 //
 // They should not overlap, e.g. the same module type should not be registered by different
 // FixturePreparers as using them both would cause a build error. In that case the preparer should
-// be split into separate parts and combined together using FixturePreparers(...).
+// be split into separate parts and combined together using FixturePreparers(...). A module type or
+// mutator that is registered again with the same factory is ignored, and
+// PrepareForTestWithRegistrationValidation reports the ones registered with different factories.
 //
 // e.g. attempting to use AllPreparers in preparing a Fixture would break as it would attempt to
 // register module bar twice:
@@ -167,6 +174,20 @@ import (
 // A set of mock files to add to the mock file system.
 type MockFS map[string][]byte
 
+// Remove removes the file or symlink with the given path from this map.
+//
+// Fails if there is no such file, listing the most similar paths in the map.
+func (fs MockFS) Remove(path string) {
+	for p := range fs {
+		if p == path || strings.HasPrefix(p, path+mockFSSymlinkSeparator) {
+			delete(fs, p)
+			return
+		}
+	}
+	panic(fmt.Errorf("attempted to remove file %s from the mock filesystem but it does not exist, similar paths:\n  %s",
+		path, strings.Join(closestStrings(path, SortedStringKeys(fs), 5), "\n  ")))
+}
+
 // Merge adds the extra entries from the supplied map to this one.
 //
 // Fails if the supplied map files with the same paths are present in both of them.
@@ -180,9 +201,16 @@ func (fs MockFS) Merge(extra map[string][]byte) {
 	}
 }
 
+// mockFSSymlinkSeparator separates the path of a symlink from its target in the paths of a MockFS,
+// e.g. "prebuilts/foo/current -> 1.0".
+const mockFSSymlinkSeparator = " -> "
+
 // Ensure that tests cannot add paths into the mock file system which would not be allowed in the
 // runtime, e.g. absolute paths, paths relative to the 'out/' directory.
 func validateFixtureMockFSPath(path string) {
+	// The target of a symlink is resolved like a real symlink, so only its path needs to be valid.
+	path = strings.SplitN(path, mockFSSymlinkSeparator, 2)[0]
+
 	// This uses validateSafePath rather than validatePath because the latter prevents adding files
 	// that include a $ but there are tests that allow files with a $ to be used, albeit only by
 	// globbing.
@@ -279,15 +307,44 @@ func FixtureRegisterWithContext(registeringFunc func(ctx RegistrationContext)) F
 	return FixtureModifyContext(func(ctx *TestContext) { registeringFunc(ctx) })
 }
 
+// FixtureRegisterSingleton registers the singleton type name, e.g. a toy singleton that tests how
+// modules interact with singletons without the setup that the real singleton requires.  The
+// singleton's build actions can be checked through TestResult.SingletonForTests(name).
+//
+// The singleton is only registered once when several preparers register it with the same factory,
+// e.g. preparers that are grouped together, and it panics if they use different factories.
+func FixtureRegisterSingleton(name string, factory SingletonFactory) FixturePreparer {
+	return FixtureModifyContext(func(ctx *TestContext) {
+		for _, c := range ctx.singletons {
+			if s := c.(singleton); s.name == name {
+				if !sameFunc(s.factory, factory) {
+					panic(fmt.Errorf("singleton %q is already registered with a different factory", name))
+				}
+				return
+			}
+		}
+		ctx.RegisterSingletonType(name, factory)
+	})
+}
+
 // Modify the mock filesystem
 func FixtureModifyMockFS(mutator func(fs MockFS)) FixturePreparer {
 	return newSimpleFixturePreparer(func(f *fixture) {
-		mutator(f.mockFS)
+		f.modifyMockFS(mutator)
+	})
+}
 
-		// Make sure that invalid paths were not added to the mock filesystem.
-		for p, _ := range f.mockFS {
-			validateFixtureMockFSPath(p)
-		}
+// Modify the mock filesystem after all the files have been added to it.
+//
+// Unlike FixtureModifyMockFS and the preparers that add files to the mock filesystem, e.g.
+// FixtureAddFile and FixtureMergeMockFs, which are applied in order, the mutator is only called
+// once all the preparers have been applied and before the test runs, so it is passed every file
+// that the preparers added wherever they appear in the list and can replace or delete them, e.g.
+// with MockFS.Remove.  The mutators of several FixtureModifyFinalMockFS preparers are called in
+// the order the preparers are applied.
+func FixtureModifyFinalMockFS(mutator func(fs MockFS)) FixturePreparer {
+	return newSimpleFixturePreparer(func(f *fixture) {
+		f.finalMockFSMutators = append(f.finalMockFSMutators, mutator)
 	})
 }
 
@@ -300,6 +357,51 @@ func FixtureMergeMockFs(mockFS MockFS) FixturePreparer {
 	})
 }
 
+// Add the files in a directory under the testdata directory of the test's package to the mock
+// filesystem, recursively, at the same paths relative to mockRoot.
+//
+// Paths that already exist in the mock file system are overridden. Fail if the directory does not
+// exist.
+func FixtureAddDirectoryFromTestdata(mockRoot, testdataDir string) FixturePreparer {
+	return FixtureAddFilteredDirectoryFromTestdata(mockRoot, testdataDir, nil)
+}
+
+// Add the files in a directory under the testdata directory of the test's package to the mock
+// filesystem as for FixtureAddDirectoryFromTestdata, except for those for which filter returns
+// false when called with their slash separated path relative to testdataDir.
+func FixtureAddFilteredDirectoryFromTestdata(mockRoot, testdataDir string, filter func(path string) bool) FixturePreparer {
+	return FixtureModifyMockFS(func(fs MockFS) {
+		dir := filepath.Join("testdata", testdataDir)
+		files := MockFS{}
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if filter != nil && !filter(rel) {
+				return nil
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			files[filepath.Join(mockRoot, rel)] = contents
+			return nil
+		})
+		if err != nil {
+			panic(fmt.Errorf("could not add directory %s to the mock filesystem: %s", dir, err))
+		}
+		for path, contents := range files {
+			validateFixtureMockFSPath(path)
+			fs[path] = contents
+		}
+	})
+}
+
 // Add a file to the mock filesystem
 //
 // Fail if the filesystem already contains a file with that path, use FixtureOverrideFile instead.
@@ -313,6 +415,23 @@ func FixtureAddFile(path string, contents []byte) FixturePreparer {
 	})
 }
 
+// Add a symlink to the mock filesystem
+//
+// The target is resolved relative to the directory containing the symlink, as for a real symlink,
+// and may not exist, to test the handling of dangling symlinks. Fail if the filesystem already
+// contains a file or symlink with that path.
+func FixtureAddSymlink(path, target string) FixturePreparer {
+	return FixtureModifyMockFS(func(fs MockFS) {
+		validateFixtureMockFSPath(path)
+		for p := range fs {
+			if p == path || strings.HasPrefix(p, path+mockFSSymlinkSeparator) {
+				panic(fmt.Errorf("attempted to add symlink %s to the mock filesystem but it already exists", path))
+			}
+		}
+		fs[path+mockFSSymlinkSeparator+target] = nil
+	})
+}
+
 // Add a text file to the mock filesystem
 //
 // Fail if the filesystem already contains a file with that path.
@@ -367,6 +486,25 @@ func FixtureModifyEnv(mutator func(env map[string]string)) FixturePreparer {
 	})
 }
 
+// FixtureWithTimeout fails the test if parsing the blueprint files and processing the modules take
+// longer than the timeout, listing the module variants that are still being processed and the
+// mutators that are processing them, instead of letting a module type that does not terminate hang
+// the test until the go test timeout.
+func FixtureWithTimeout(timeout time.Duration) FixturePreparer {
+	return newSimpleFixturePreparer(func(f *fixture) {
+		f.timeout = timeout
+		f.config.moduleTracker = newModuleTracker()
+	})
+}
+
+// FixtureSetBuildDate sets the time that the build started at, as returned by Config.Now and
+// Config.BuildDateEpoch, so that tests can control the date dependent behavior.
+func FixtureSetBuildDate(date time.Time) FixturePreparer {
+	return FixtureModifyConfig(func(config Config) {
+		config.buildDate = date
+	})
+}
+
 // Allow access to the product variables when preparing the fixture.
 type FixtureProductVariables struct {
 	*productVariables
@@ -395,11 +533,22 @@ var PrepareForDebug_DO_NOT_SUBMIT = newSimpleFixturePreparer(func(fixture *fixtu
 	fixture.debug = true
 })
 
+// PrepareForTestWithRegistrationValidation reports an error when a module type or mutator name is
+// registered with different factories, instead of only using the first factory for the mutators
+// or panicking for the module types.
+//
+// Module types are checked as they are registered, so it must be applied before the preparers that
+// register them.
+var PrepareForTestWithRegistrationValidation = FixtureModifyContext(func(ctx *TestContext) {
+	ctx.validateRegistrations = true
+})
+
 // GroupFixturePreparers creates a composite FixturePreparer that is equivalent to applying each of
 // the supplied FixturePreparer instances in order.
 //
 // Before preparing the fixture the list of preparers is flattened by replacing each
-// instance of GroupFixturePreparers with its contents.
+// instance of GroupFixturePreparers with its contents, and deduped by identity while preserving
+// the order in which each preparer first appears, so each preparer is applied at most once.
 func GroupFixturePreparers(preparers ...FixturePreparer) FixturePreparer {
 	all := dedupAndFlattenPreparers(nil, preparers)
 	return newFixturePreparer(all)
@@ -567,6 +716,10 @@ func newSimpleFixturePreparer(preparer func(fixture *fixture)) FixturePreparer {
 //     FixtureExpectsAtLeastOneErrorMatchingPattern
 //   - Fail the test if any unexpected errors are reported.
 //
+// The handlers that match errors against regular expressions match each error both as is and with
+// its "file:line:col: " positions and module prefixes stripped, see StripErrorPositions, so that the
+// patterns do not depend on where the modules are defined in the bp files.
+//
 // Although at the moment all the error handlers are implemented as simply a wrapper around a
 // function this is defined as an interface to allow future enhancements, e.g. provide different
 // ways other than patterns to match an error and to combine handlers together.
@@ -650,6 +803,29 @@ func FixtureExpectsAllErrorsToMatchAPattern(patterns []string) FixtureErrorHandl
 	})
 }
 
+// FixtureExpectsErrorsMatchingInAnyOrder returns an error handler that will cause the test to fail
+// unless the errors and the patterns match, regardless of the order in which the errors are
+// reported.
+//
+// The test will be failed if:
+// * One or more of the patterns does not match an error.
+// * One or more of the reported errors do not match a pattern.
+//
+// The test will not fail if:
+// * A pattern matches more than one error, or an error matches more than one pattern.
+//
+// Use FixtureExpectsAtLeastOneErrorMatchingPattern instead if the test only cares about one of the
+// errors.
+//
+// If the test fails this handler will call `result.FailNow()` which will exit the goroutine within
+// which the test is being run which means that the RunTest() method will not return.
+func FixtureExpectsErrorsMatchingInAnyOrder(patterns ...string) FixtureErrorHandler {
+	return FixtureCustomErrorHandler(func(t *testing.T, result *TestResult) {
+		t.Helper()
+		CheckErrorsMatchPatternsInAnyOrder(t, result.Errs, patterns)
+	})
+}
+
 // FixtureExpectsOneErrorPattern returns an error handler that will cause the test to fail
 // if there is more than one error or the error does not match the pattern.
 //
@@ -768,6 +944,11 @@ func createFixture(t *testing.T, buildDir string, preparers []*simpleFixturePrep
 		preparer.function(fixture)
 	}
 
+	// Modify the mock filesystem once all the files have been added to it.
+	for _, mutator := range fixture.finalMockFSMutators {
+		fixture.modifyMockFS(mutator)
+	}
+
 	return fixture
 }
 
@@ -846,11 +1027,28 @@ type fixture struct {
 	// The mock filesystem prepared for this fixture.
 	mockFS MockFS
 
+	// The mutators of the mock filesystem that are applied after all the preparers, see
+	// FixtureModifyFinalMockFS.
+	finalMockFSMutators []func(fs MockFS)
+
 	// The error handler used to check the errors, if any, that are reported.
 	errorHandler FixtureErrorHandler
 
 	// Debug mode status
 	debug bool
+
+	// The time after which the test fails if the modules have not all been processed, or 0 for no
+	// timeout, see FixtureWithTimeout.
+	timeout time.Duration
+}
+
+func (f *fixture) modifyMockFS(mutator func(fs MockFS)) {
+	mutator(f.mockFS)
+
+	// Make sure that invalid paths were not added to the mock filesystem.
+	for p, _ := range f.mockFS {
+		validateFixtureMockFSPath(p)
+	}
 }
 
 func (f *fixture) Config() Config {
@@ -917,15 +1115,27 @@ func (f *fixture) RunTest() CustomTestResult {
 
 	// Do any last minute preparation before parsing the blueprint files.
 	customResult := f.testRunner.FinalPreparer(result)
+	result.Errs = append(result.Errs, ctx.registrationErrs...)
+
+	analyze := func() {
+		if len(result.Errs) == 0 {
+			// Parse the blueprint files adding the information to the result.
+			extraNinjaDeps, errs := ctx.ParseBlueprintsFiles("ignored")
+			result.NinjaDeps = append(result.NinjaDeps, extraNinjaDeps...)
+			result.Errs = append(result.Errs, errs...)
+		}
 
-	// Parse the blueprint files adding the information to the result.
-	extraNinjaDeps, errs := ctx.ParseBlueprintsFiles("ignored")
-	result.NinjaDeps = append(result.NinjaDeps, extraNinjaDeps...)
-	result.Errs = append(result.Errs, errs...)
-
-	if len(result.Errs) == 0 {
-		// If parsing the blueprint files was successful then perform any additional processing.
-		f.testRunner.PostParseProcessor(customResult)
+		if len(result.Errs) == 0 {
+			// If parsing the blueprint files was successful then perform any additional processing.
+			f.testRunner.PostParseProcessor(customResult)
+		}
+	}
+	if f.timeout > 0 {
+		if err := runWithTimeout(f.config.moduleTracker, f.timeout, analyze); err != nil {
+			f.t.Fatalf("%s", err)
+		}
+	} else {
+		analyze()
 	}
 
 	f.errorHandler.CheckErrors(f.t, result)
@@ -1042,6 +1252,110 @@ func (r *TestResult) Module(name string, variant string) Module {
 	return r.ModuleForTests(name, variant).Module()
 }
 
+// HostVariantOf returns the host variant of the supplied module that pairs with it, as for
+// android.HostVariantOf, or nil if it has none.
+func (r *TestResult) HostVariantOf(module Module) Module {
+	return r.osVariantOf(module, Host)
+}
+
+// DeviceVariantOf returns the device variant of the supplied module that pairs with it, as for
+// android.DeviceVariantOf, or nil if it has none.
+func (r *TestResult) DeviceVariantOf(module Module) Module {
+	return r.osVariantOf(module, Device)
+}
+
+// ForEachDeviceVariant runs test in a subtest named after the variant for each device variant of
+// the named module, for assertions that apply to every target the module is built for.  The
+// callback can use m.Target() to compute the expected values that depend on the architecture.
+func (r *TestResult) ForEachDeviceVariant(t *testing.T, name string, test func(t *testing.T, variant string, m TestingModule)) {
+	t.Helper()
+	r.forEachOsClassVariant(t, name, Device, test)
+}
+
+// ForEachHostVariant is the equivalent of ForEachDeviceVariant for the host variants of the named
+// module.
+func (r *TestResult) ForEachHostVariant(t *testing.T, name string, test func(t *testing.T, variant string, m TestingModule)) {
+	t.Helper()
+	r.forEachOsClassVariant(t, name, Host, test)
+}
+
+func (r *TestResult) forEachOsClassVariant(t *testing.T, name string, class OsClass, test func(t *testing.T, variant string, m TestingModule)) {
+	t.Helper()
+	var allVariants []string
+	var modules []Module
+	r.VisitAllModules(func(m blueprint.Module) {
+		if r.ModuleName(m) != name {
+			return
+		}
+		allVariants = append(allVariants, r.ModuleSubDir(m))
+		if module, ok := m.(Module); ok && module.Target().Os.Class == class {
+			modules = append(modules, module)
+		}
+	})
+	if len(modules) == 0 {
+		t.Errorf("module %q has no %s variants, all variants:\n  %s", name, class, strings.Join(allVariants, "\n  "))
+		return
+	}
+
+	for _, module := range modules {
+		variant := r.ModuleSubDir(module)
+		t.Run(variant, func(t *testing.T) {
+			test(t, variant, newTestingModuleWithVariants(r.Config, module, variant, allVariants))
+		})
+	}
+}
+
+// AssertStringEqualsGoldenFile checks that the actual value is equal to a golden file, as for
+// android.AssertStringEqualsGoldenFile, except that a golden file that was added to the mock
+// filesystem of the fixture at goldenPath is used in preference to the one in the testdata
+// directory.  Golden files in the mock filesystem cannot be updated with
+// SOONG_UPDATE_GOLDEN_FILES=true.
+func (r *TestResult) AssertStringEqualsGoldenFile(t *testing.T, message, goldenPath, actual string) {
+	t.Helper()
+	contents, ok := r.fixture.mockFS[goldenPath]
+	if !ok {
+		AssertStringEqualsGoldenFile(t, message, goldenPath, actual)
+		return
+	}
+	var write func([]byte) error
+	if updateGoldenFiles() {
+		write = func([]byte) error {
+			return fmt.Errorf("it is in the mock filesystem of the fixture")
+		}
+	}
+	assertStringEqualsGoldenFile(t, message, goldenPath, actual, func() ([]byte, error) {
+		return contents, nil
+	}, write)
+}
+
+// AssertAndroidMkEntry checks that the entry key of the selected AndroidMkEntries of the module,
+// usually PrimaryAndroidMkEntries, is set to the expected values.  The paths in the values are
+// relative to the top of the tree, and all the keys of the entries are listed if the key is not
+// set.
+func (r *TestResult) AssertAndroidMkEntry(t *testing.T, message string, module Module,
+	selector AndroidMkEntriesSelector, key string, expected []string) {
+	t.Helper()
+	assertAndroidMkEntry(t, message, r.TestContext, module, selector, key, expected, false)
+}
+
+// AssertAndroidMkEntryAbsent checks that the entry key of the selected AndroidMkEntries of the
+// module is not set.
+func (r *TestResult) AssertAndroidMkEntryAbsent(t *testing.T, message string, module Module,
+	selector AndroidMkEntriesSelector, key string) {
+	t.Helper()
+	assertAndroidMkEntry(t, message, r.TestContext, module, selector, key, nil, true)
+}
+
+func (r *TestResult) osVariantOf(module Module, class OsClass) Module {
+	visitAllVariants := func(visit func(Module)) {
+		r.VisitAllModuleVariants(module, func(m blueprint.Module) {
+			visit(m.(Module))
+		})
+	}
+	return osVariantOf(r.Config, module, class, r.ModuleProvider(module, OsVariantsInfoProvider),
+		visitAllVariants)
+}
+
 // CollateErrs adds additional errors to the result and returns true if there is more than one
 // error in the result.
 func (r *TestResult) CollateErrs(errs []error) bool {
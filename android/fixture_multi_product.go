@@ -0,0 +1,140 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A MultiProductFixture runs the same test against several products, each of which is configured
+// by a FixturePreparer, e.g. one created by FixtureModifyProductVariables, so that a test can
+// check how a behavior differs between the products without repeating the rest of its setup:
+//
+//	result := android.NewMultiProductFixture(prepareForTest,
+//		android.FixtureProduct{Name: "eng", Preparer: android.FixtureModifyProductVariables(...)},
+//		android.FixtureProduct{Name: "user", Preparer: android.FixtureModifyProductVariables(...)},
+//	).RunTestWithBp(t, bp)
+//
+//	result.AssertProductValues(t, "cflags", func(result *android.TestResult) interface{} {
+//		return result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("cc").Args["cFlags"]
+//	}, map[string]interface{}{
+//		"eng":  "...",
+//		"user": "...",
+//	})
+type MultiProductFixture struct {
+	preparer FixturePreparer
+	products []FixtureProduct
+}
+
+// FixtureProduct is a product that a MultiProductFixture runs the test against.
+type FixtureProduct struct {
+	// The name of the product, used to report its values.
+	Name string
+
+	// The preparer that configures the product, applied after the preparers common to all the
+	// products.
+	Preparer FixturePreparer
+}
+
+// NewMultiProductFixture returns a MultiProductFixture that runs the tests prepared by the
+// supplied preparer against each of the products.
+func NewMultiProductFixture(preparer FixturePreparer, products ...FixtureProduct) *MultiProductFixture {
+	names := make(map[string]bool)
+	for _, product := range products {
+		if names[product.Name] {
+			panic(fmt.Errorf("duplicate product %q", product.Name))
+		}
+		names[product.Name] = true
+	}
+	return &MultiProductFixture{preparer: preparer, products: products}
+}
+
+// RunTest runs the test against each of the products, in order.
+func (f *MultiProductFixture) RunTest(t *testing.T) *MultiProductTestResult {
+	t.Helper()
+	result := &MultiProductTestResult{results: make(map[string]*TestResult)}
+	for _, product := range f.products {
+		result.Products = append(result.Products, product.Name)
+		result.results[product.Name] = GroupFixturePreparers(f.preparer, product.Preparer).RunTest(t)
+	}
+	return result
+}
+
+// RunTestWithBp runs the test with the supplied Android.bp file against each of the products.
+func (f *MultiProductFixture) RunTestWithBp(t *testing.T, bp string) *MultiProductTestResult {
+	t.Helper()
+	return (&MultiProductFixture{
+		preparer: GroupFixturePreparers(f.preparer, FixtureWithRootAndroidBp(bp)),
+		products: f.products,
+	}).RunTest(t)
+}
+
+// MultiProductTestResult is the result of running a MultiProductFixture.
+type MultiProductTestResult struct {
+	// The names of the products, in the order they were passed to NewMultiProductFixture.
+	Products []string
+
+	results map[string]*TestResult
+}
+
+// Result returns the result of the test for the named product.
+func (r *MultiProductTestResult) Result(product string) *TestResult {
+	result, ok := r.results[product]
+	if !ok {
+		panic(fmt.Errorf("unknown product %q, expected one of %q", product, r.Products))
+	}
+	return result
+}
+
+// AssertProductValues checks that the value returned by get for the result of each product, e.g.
+// the flags of a rule or a field of a provider, is equal to the expected value for the product
+// using reflect.DeepEqual. If any of them are not then it reports an error prefixed with the
+// supplied message and listing the values of every product.
+func (r *MultiProductTestResult) AssertProductValues(t *testing.T, message string,
+	get func(result *TestResult) interface{}, expected map[string]interface{}) {
+
+	t.Helper()
+	r.assertProductValues(t, message, get, expected)
+}
+
+func (r *MultiProductTestResult) assertProductValues(t errorReporter, message string,
+	get func(result *TestResult) interface{}, expected map[string]interface{}) {
+
+	t.Helper()
+	for product := range expected {
+		if !InList(product, r.Products) {
+			t.Errorf("%s: unknown product %q, expected one of %q", message, product, r.Products)
+			return
+		}
+	}
+
+	var lines, mismatched []string
+	for _, product := range r.Products {
+		actual := get(r.results[product])
+		if reflect.DeepEqual(expected[product], actual) {
+			lines = append(lines, fmt.Sprintf("%s: %#v", product, actual))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: expected %#v, actual %#v", product, expected[product], actual))
+			mismatched = append(mismatched, product)
+		}
+	}
+	if len(mismatched) > 0 {
+		t.Errorf("%s: unexpected values for the products %s:\n  %s",
+			message, strings.Join(mismatched, ", "), strings.Join(lines, "\n  "))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+)
+
+type multiProductTestModule struct {
+	ModuleBase
+	properties struct {
+		Cflags []string
+	}
+}
+
+func (m *multiProductTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+}
+
+func multiProductTestModuleFactory() Module {
+	m := &multiProductTestModule{}
+	m.AddProperties(&m.properties)
+	m.variableProperties = testProductVariableProperties
+	InitAndroidModule(m)
+	return m
+}
+
+func TestMultiProductFixture(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+			cflags: ["-DFOO"],
+			product_variables: {
+				eng: {
+					cflags: ["-DENG"],
+				},
+			},
+		}
+	`
+
+	fixture := NewMultiProductFixture(
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("test", multiProductTestModuleFactory)
+			ctx.PreDepsMutators(func(ctx RegisterMutatorsContext) {
+				ctx.BottomUp("variable", VariableMutator).Parallel()
+			})
+		}),
+		FixtureProduct{
+			Name: "eng",
+			Preparer: FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.Eng = proptools.BoolPtr(true)
+			}),
+		},
+		FixtureProduct{
+			Name: "user",
+			Preparer: FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.Eng = proptools.BoolPtr(false)
+			}),
+		},
+	)
+	result := fixture.RunTestWithBp(t, bp)
+
+	cflags := func(result *TestResult) interface{} {
+		return result.Module("foo", "").(*multiProductTestModule).properties.Cflags
+	}
+
+	AssertArrayString(t, "products", []string{"eng", "user"}, result.Products)
+	result.AssertProductValues(t, "cflags", cflags, map[string]interface{}{
+		"eng":  []string{"-DFOO", "-DENG"},
+		"user": []string{"-DFOO"},
+	})
+
+	reporter := &fakeErrorReporter{}
+	result.assertProductValues(reporter, "cflags", cflags, map[string]interface{}{
+		"eng":  []string{"-DFOO", "-DENG"},
+		"user": []string{"-DFOO", "-DENG"},
+	})
+	AssertArrayString(t, "errors", []string{
+		"cflags: unexpected values for the products user:\n" +
+			`  eng: []string{"-DFOO", "-DENG"}` + "\n" +
+			`  user: expected []string{"-DFOO", "-DENG"}, actual []string{"-DFOO"}`,
+	}, reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	result.assertProductValues(reporter, "cflags", cflags, map[string]interface{}{
+		"userdebug": []string{"-DFOO"},
+	})
+	AssertArrayString(t, "errors", []string{
+		`cflags: unknown product "userdebug", expected one of ["eng" "user"]`,
+	}, reporter.errors)
+}
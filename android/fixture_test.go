@@ -15,7 +15,11 @@
 package android
 
 import (
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 // Make sure that FixturePreparer instances are only called once per fixture and in the order in
@@ -80,3 +84,486 @@ func TestFixtureValidateMockFS(t *testing.T) {
 		})
 	})
 }
+
+func TestFixtureAddDirectoryFromTestdata(t *testing.T) {
+	t.Run("all files", func(t *testing.T) {
+		fs := GroupFixturePreparers(
+			FixtureAddTextFile("prebuilts/sdk/foo.txt", "overridden\n"),
+			FixtureAddDirectoryFromTestdata("prebuilts/sdk", "fixture_directory"),
+			FixtureOverrideTextFile("prebuilts/sdk/sub/bar.txt", "overridden\n"),
+			FixtureMergeMockFs(MockFS{
+				"other/Android.bp": nil,
+			}),
+		).Fixture(t).(*fixture).mockFS
+
+		AssertStringEquals(t, "foo.txt", "foo\n", string(fs["prebuilts/sdk/foo.txt"]))
+		AssertStringEquals(t, "sub/bar.txt", "overridden\n", string(fs["prebuilts/sdk/sub/bar.txt"]))
+		AssertStringEquals(t, "sub/skip.txt", "skipped\n", string(fs["prebuilts/sdk/sub/skip.txt"]))
+		AssertDeepEquals(t, "sub/profile.bin", []byte{0, 1, 2, 0xfe, 0xff, '\r', '\n', 0}, fs["prebuilts/sdk/sub/profile.bin"])
+		AssertDeepEquals(t, "other/Android.bp", []byte(nil), fs["other/Android.bp"])
+	})
+
+	t.Run("filtered", func(t *testing.T) {
+		fs := FixtureAddFilteredDirectoryFromTestdata("prebuilts/sdk", "fixture_directory", func(path string) bool {
+			return path != "sub/skip.txt"
+		}).Fixture(t).(*fixture).mockFS
+
+		_, exists := fs["prebuilts/sdk/sub/skip.txt"]
+		AssertBoolEquals(t, "sub/skip.txt exists", false, exists)
+		AssertStringEquals(t, "sub/bar.txt", "bar\n", string(fs["prebuilts/sdk/sub/bar.txt"]))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		AssertPanicMessageContains(t, "missing directory", "could not add directory testdata/missing to the mock filesystem", func() {
+			FixtureAddDirectoryFromTestdata("prebuilts/sdk", "missing").Fixture(t)
+		})
+	})
+}
+
+func TestFixtureModifyMockFS(t *testing.T) {
+	profile := "toolchain/pgo-profiles/sampling/foo.afdo"
+
+	// The shared preparer that an individual test needs to tweak.
+	sharedPreparer := GroupFixturePreparers(
+		FixtureAddTextFile(profile, "shared profile"),
+		FixtureAddTextFile("toolchain/pgo-profiles/sampling/bar.afdo", "bar profile"),
+	)
+
+	t.Run("in order", func(t *testing.T) {
+		var seen []string
+		fs := GroupFixturePreparers(
+			// The mutator only sees the files added by the preparers before it.
+			FixtureAddTextFile("foo/Android.bp", "added"),
+			FixtureModifyMockFS(func(fs MockFS) {
+				seen = SortedStringKeys(fs)
+				fs["foo/Android.bp"] = append(fs["foo/Android.bp"], []byte(" and modified")...)
+			}),
+			sharedPreparer,
+		).Fixture(t).MockFS()
+
+		AssertDeepEquals(t, "files seen by the mutator", []string{"foo/Android.bp"}, seen)
+		AssertStringEquals(t, "foo/Android.bp", "added and modified", string(fs["foo/Android.bp"]))
+		AssertStringEquals(t, "profile", "shared profile", string(fs[profile]))
+	})
+
+	t.Run("final", func(t *testing.T) {
+		var seen []string
+		fs := GroupFixturePreparers(
+			// The mutators run after all the preparers that add files, even those that follow them.
+			FixtureModifyFinalMockFS(func(fs MockFS) {
+				seen = SortedStringKeys(fs)
+				fs[profile] = []byte("modified profile")
+				fs.Remove("toolchain/pgo-profiles/sampling/bar.afdo")
+			}),
+			sharedPreparer,
+			FixtureMergeMockFs(MockFS{
+				"foo/Android.bp": []byte("merged"),
+			}),
+			// Later mutators see the changes of the earlier ones.
+			FixtureModifyFinalMockFS(func(fs MockFS) {
+				fs["foo/Android.bp"] = append(fs["foo/Android.bp"], []byte(" and modified")...)
+				fs["foo/modified.txt"] = fs[profile]
+			}),
+		).Fixture(t).MockFS()
+
+		AssertDeepEquals(t, "files seen by the mutator", []string{
+			"foo/Android.bp",
+			"toolchain/pgo-profiles/sampling/bar.afdo",
+			"toolchain/pgo-profiles/sampling/foo.afdo",
+		}, seen)
+		AssertDeepEquals(t, "files", []string{
+			"foo/Android.bp",
+			"foo/modified.txt",
+			"toolchain/pgo-profiles/sampling/foo.afdo",
+		}, SortedStringKeys(fs))
+		AssertStringEquals(t, "profile", "modified profile", string(fs[profile]))
+		AssertStringEquals(t, "foo/Android.bp", "merged and modified", string(fs["foo/Android.bp"]))
+		AssertStringEquals(t, "foo/modified.txt", "modified profile", string(fs["foo/modified.txt"]))
+	})
+
+	t.Run("remove missing file", func(t *testing.T) {
+		AssertPanicMessageContains(t, "missing file",
+			"attempted to remove file toolchain/pgo-profiles/sampling/baz.afdo from the mock filesystem but it does not exist, similar paths:\n"+
+				"  toolchain/pgo-profiles/sampling/bar.afdo\n"+
+				"  toolchain/pgo-profiles/sampling/foo.afdo", func() {
+				GroupFixturePreparers(
+					sharedPreparer,
+					FixtureModifyMockFS(func(fs MockFS) {
+						fs.Remove("toolchain/pgo-profiles/sampling/baz.afdo")
+					}),
+				).Fixture(t)
+			})
+	})
+}
+
+func TestFixtureExpectsErrorsMatchingInAnyOrder(t *testing.T) {
+	errs := []error{
+		errors.New(`"foo" depends on undefined module "a"`),
+		errors.New(`"bar" depends on undefined module "b"`),
+	}
+
+	testCases := []struct {
+		name     string
+		patterns []string
+		expected []string
+	}{
+		{
+			name:     "reordered",
+			patterns: []string{`undefined module "b"`, `undefined module "a"`},
+		},
+		{
+			name:     "pattern matching several errors",
+			patterns: []string{`depends on undefined module`},
+		},
+		{
+			name:     "unmatched and unclaimed",
+			patterns: []string{`undefined module "a"`, `undefined module "c"`},
+			expected: []string{"errors do not match the expected patterns (checked 2 pattern(s) against 2 error(s))\n" +
+				"  unmatched patterns:\n" +
+				`    "undefined module \"c\""` + "\n" +
+				"  unclaimed errors:\n" +
+				`    "\"bar\" depends on undefined module \"b\""`},
+		},
+		{
+			name:     "no patterns",
+			patterns: nil,
+			expected: []string{"errors do not match the expected patterns (checked 0 pattern(s) against 2 error(s))\n" +
+				"  unmatched patterns:\n" +
+				"    (none)\n" +
+				"  unclaimed errors:\n" +
+				`    "\"foo\" depends on undefined module \"a\""` + "\n" +
+				`    "\"bar\" depends on undefined module \"b\""`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			matched := checkErrorsMatchPatternsInAnyOrder(reporter, errs, tc.patterns)
+			AssertBoolEquals(t, "matched", tc.expected == nil, matched)
+			AssertArrayString(t, "errors", tc.expected, reporter.errors)
+		})
+	}
+
+	t.Run("fixture", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForModuleTests,
+			FixtureWithRootAndroidBp(`
+				deps {
+					name: "foo",
+					deps: ["a"],
+				}
+
+				deps {
+					name: "bar",
+					deps: ["b"],
+				}
+			`),
+		).ExtendWithErrorHandler(FixtureExpectsErrorsMatchingInAnyOrder(
+			`"bar" depends on undefined module "b"`,
+			`"foo" depends on undefined module "a"`,
+		)).RunTest(t)
+	})
+
+	t.Run("anchored patterns", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForModuleTests,
+			FixtureWithRootAndroidBp(`
+				deps {
+					name: "foo",
+					deps: ["a"],
+				}
+			`),
+		).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`^"foo" depends on undefined module "a"`,
+		)).RunTest(t)
+	})
+}
+
+// fixtureTestSingleton lists the names of all the modules.
+type fixtureTestSingleton struct{}
+
+func (s *fixtureTestSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var names []string
+	ctx.VisitAllModules(func(module Module) {
+		names = append(names, module.Name())
+	})
+	WriteFileRule(ctx, PathForOutput(ctx, "all_modules.txt"), strings.Join(SortedUniqueStrings(names), "\n"))
+}
+
+func fixtureTestSingletonFactory() Singleton {
+	return &fixtureTestSingleton{}
+}
+
+func TestFixtureRegisterSingleton(t *testing.T) {
+	preparer := GroupFixturePreparers(
+		prepareForModuleTests,
+		FixtureRegisterSingleton("all_modules", fixtureTestSingletonFactory),
+		FixtureWithRootAndroidBp(`
+			deps {
+				name: "foo",
+			}
+
+			deps {
+				name: "bar",
+			}
+		`),
+	)
+
+	// Registering the same singleton again from another preparer is a no-op.
+	result := GroupFixturePreparers(
+		preparer,
+		FixtureRegisterSingleton("all_modules", fixtureTestSingletonFactory),
+	).RunTest(t)
+
+	allModules := result.SingletonForTests("all_modules").Output("all_modules.txt")
+	AssertStringEquals(t, "all_modules.txt", "bar\nfoo", ContentFromFileRuleForTests(t, allModules))
+
+	AssertPanicMessageContains(t, "conflicting factories",
+		`singleton "all_modules" is already registered with a different factory`, func() {
+			GroupFixturePreparers(
+				preparer,
+				FixtureRegisterSingleton("all_modules", func() Singleton { return &fixtureTestSingleton{} }),
+			).RunTest(t)
+		})
+}
+
+func TestFixtureDedupRegistrations(t *testing.T) {
+	bp := FixtureWithRootAndroidBp(`
+		deps {
+			name: "foo",
+		}
+	`)
+
+	// mutatorRuns counts the runs of the counting mutator on foo.
+	mutatorRuns := 0
+	registerCountingMutator := func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("counting", func(ctx BottomUpMutatorContext) {
+			if ctx.ModuleName() == "foo" {
+				mutatorRuns++
+			}
+		})
+	}
+
+	t.Run("overlapping groups", func(t *testing.T) {
+		registrations := 0
+		registerDeps := FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			registrations++
+			ctx.RegisterModuleType("deps", depsModuleFactory)
+		})
+
+		groupA := GroupFixturePreparers(registerDeps, bp)
+		groupB := GroupFixturePreparers(bp, registerDeps)
+		GroupFixturePreparers(groupA, GroupFixturePreparers(groupB, registerDeps), groupA).RunTest(t)
+
+		AssertIntEquals(t, "registrations", 1, registrations)
+	})
+
+	t.Run("distinct preparers with the same factories", func(t *testing.T) {
+		mutatorRuns = 0
+		registerComponents := func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("deps", depsModuleFactory)
+			ctx.PreArchMutators(registerCountingMutator)
+		}
+
+		result := GroupFixturePreparers(
+			PrepareForTestWithRegistrationValidation,
+			FixtureRegisterWithContext(registerComponents),
+			FixtureRegisterWithContext(registerComponents),
+			prepareForModuleTests,
+			bp,
+		).RunTest(t)
+
+		AssertIntEquals(t, "counting mutator runs", 1, mutatorRuns)
+		AssertIntEquals(t, "counting mutators", 1, len(FilterListPred(result.mutatorOrder, func(s string) bool {
+			return s == "counting"
+		})))
+	})
+
+	t.Run("different factories", func(t *testing.T) {
+		GroupFixturePreparers(
+			PrepareForTestWithRegistrationValidation,
+			prepareForModuleTests,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("deps", func() Module { return depsModuleFactory() })
+				ctx.PreArchMutators(registerCountingMutator)
+				ctx.PreArchMutators(func(ctx RegisterMutatorsContext) {
+					ctx.BottomUp("counting", func(BottomUpMutatorContext) {})
+				})
+			}),
+			bp,
+		).ExtendWithErrorHandler(FixtureExpectsErrorsMatchingInAnyOrder(
+			regexp.QuoteMeta(`module type "deps" is registered with both android/soong/android.depsModuleFactory and `),
+			regexp.QuoteMeta(`mutator "counting" is registered by both `),
+		)).RunTest(t)
+	})
+}
+
+func TestForEachDeviceVariant(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("component", componentTestModuleFactory)
+		}),
+		FixtureWithRootAndroidBp(`
+			component {
+				name: "foo",
+			}
+		`),
+	).RunTest(t)
+
+	archTypes := make(map[string]string)
+	result.ForEachDeviceVariant(t, "foo", func(t *testing.T, variant string, m TestingModule) {
+		AssertStringEquals(t, "subtest name", "TestForEachDeviceVariant/"+variant, t.Name())
+		AssertStringEquals(t, "module variant", variant, result.ModuleSubDir(m.Module()))
+		archTypes[variant] = m.Target().Arch.ArchType.String()
+	})
+	AssertDeepEquals(t, "device variants", map[string]string{
+		"android_arm64_armv8-a":    "arm64",
+		"android_arm_armv7-a-neon": "arm",
+	}, archTypes)
+
+	hostVariants := 0
+	result.ForEachHostVariant(t, "foo", func(t *testing.T, variant string, m TestingModule) {
+		AssertStringEquals(t, "host os class", "host", m.Target().Os.Class.String())
+		hostVariants++
+	})
+	if hostVariants == 0 {
+		t.Errorf("expected host variants of foo")
+	}
+}
+
+func TestFixtureWithTimeout(t *testing.T) {
+	t.Run("timeout", func(t *testing.T) {
+		tracker := newModuleTracker()
+		tracker.start(`mutator "deps" for module "foo" variant "android_common"`)
+		tracker.start(`GenerateAndroidBuildActions for module "bar" variant "android_common"`)
+		tracker.start(`mutator "arch" for module "baz" variant ""`)
+		tracker.finish(`mutator "arch" for module "baz" variant ""`)
+
+		// The analysis stops at the next module variant once the tracker has been cancelled.
+		err := runWithTimeout(tracker, 10*time.Millisecond, func() {
+			for {
+				tracker.lock.Lock()
+				cancelled := tracker.cancelled
+				tracker.lock.Unlock()
+				if cancelled {
+					tracker.start(`mutator "deps" for module "qux" variant "android_common"`)
+				}
+				time.Sleep(time.Millisecond)
+			}
+		})
+		AssertErrorMessageEquals(t, "timeout", "timed out after 10ms, still processing:\n"+
+			`    GenerateAndroidBuildActions for module "bar" variant "android_common"`+"\n"+
+			`    mutator "deps" for module "foo" variant "android_common"`, err)
+	})
+
+	t.Run("not stopped", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		err := runWithTimeout(newModuleTracker(), 10*time.Millisecond, func() {
+			<-release
+		})
+		AssertErrorMessageEquals(t, "timeout", "timed out after 10ms without processing any module\n"+
+			"the analysis could not be stopped", err)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		tracker := newModuleTracker()
+		tracker.cancel()
+		AssertPanicMessageContains(t, "start after cancel", "analysis cancelled after timing out", func() {
+			tracker.start(`mutator "deps" for module "foo" variant "android_common"`)
+		})
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		AssertPanicMessageContains(t, "panic", "boom", func() {
+			runWithTimeout(newModuleTracker(), time.Minute, func() {
+				panic("boom")
+			})
+		})
+	})
+
+	t.Run("fixture", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModuleTests,
+			FixtureWithTimeout(time.Minute),
+			FixtureWithRootAndroidBp(`
+				deps {
+					name: "foo",
+				}
+			`),
+		).RunTest(t)
+		result.ModuleForTests("foo", "android_common")
+		AssertArrayString(t, "modules in progress", nil, result.Config.moduleTracker.modulesInProgress())
+	})
+
+	t.Run("no timeout", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			prepareForModuleTests,
+			FixtureWithRootAndroidBp(`
+				deps {
+					name: "foo",
+				}
+			`),
+		).RunTest(t)
+		if result.Config.moduleTracker != nil {
+			t.Errorf("expected no module tracker without a timeout")
+		}
+	})
+}
+
+func TestFixtureAnnotatesModulePanics(t *testing.T) {
+	preparer := GroupFixturePreparers(
+		prepareForModuleTests,
+		FixtureWithRootAndroidBp(`
+			deps {
+				name: "foo",
+			}
+		`),
+	)
+
+	t.Run("mutator", func(t *testing.T) {
+		GroupFixturePreparers(
+			preparer,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.PostDepsMutators(func(ctx RegisterMutatorsContext) {
+					ctx.BottomUp("panicky", func(ctx BottomUpMutatorContext) {
+						panic(errors.New("boom"))
+					})
+				})
+			}),
+		).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`panic in mutator "panicky" for module "foo" variant "android_common": boom`),
+		)).RunTest(t)
+	})
+
+	t.Run("GenerateAndroidBuildActions", func(t *testing.T) {
+		GroupFixturePreparers(
+			preparer,
+			FixtureRegisterWithContext(func(ctx RegistrationContext) {
+				ctx.RegisterModuleType("panicky", func() Module {
+					m := &panickyTestModule{}
+					InitAndroidModule(m)
+					return m
+				})
+			}),
+			FixtureAddTextFile("panicky/Android.bp", `
+				panicky {
+					name: "bar",
+				}
+			`),
+		).ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`panic in GenerateAndroidBuildActions for module "bar" variant "": boom`),
+		)).RunTest(t)
+	})
+}
+
+// panickyTestModule panics in GenerateAndroidBuildActions.
+type panickyTestModule struct {
+	ModuleBase
+}
+
+func (m *panickyTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	panic("boom")
+}
@@ -17,8 +17,10 @@ package android
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/google/blueprint"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -32,7 +34,19 @@ func RegisterGenNoticeBuildComponents(ctx RegistrationContext) {
 	ctx.RegisterModuleType("gen_notice", GenNoticeFactory)
 }
 
-type genNoticeBuildRules struct{}
+var (
+	_ = pctx.HostBinToolVariable("checkNoticeSizeCmd", "check_notice_size")
+
+	checkNoticeSizeRule = pctx.AndroidStaticRule("checkNoticeSize", blueprint.RuleParams{
+		Command:     "${checkNoticeSizeCmd} --notice $in --budget $budget --contributors $contributors -o $out",
+		CommandDeps: []string{"${checkNoticeSizeCmd}"},
+	}, "budget", "contributors")
+)
+
+type genNoticeBuildRules struct {
+	// noticeSizeReports are the reports of the checks of the gen_notice modules with a size_budget.
+	noticeSizeReports Paths
+}
 
 func (s *genNoticeBuildRules) GenerateBuildActions(ctx SingletonContext) {
 	ctx.VisitAllModules(func(m Module) {
@@ -44,11 +58,11 @@ func (s *genNoticeBuildRules) GenerateBuildActions(ctx SingletonContext) {
 			missingReferencesRule(ctx, gm)
 			return
 		}
-		out := BuildNoticeTextOutputFromLicenseMetadata
+		tool, ruleName := "textnotice", "text_notice_"
 		if proptools.Bool(gm.properties.Xml) {
-			out = BuildNoticeXmlOutputFromLicenseMetadata
+			tool, ruleName = "xmlnotice", "xml_notice_"
 		} else if proptools.Bool(gm.properties.Html) {
-			out = BuildNoticeHtmlOutputFromLicenseMetadata
+			tool, ruleName = "htmlnotice", "html_notice_"
 		}
 		defaultName := ""
 		if len(gm.properties.For) > 0 {
@@ -71,14 +85,69 @@ func (s *genNoticeBuildRules) GenerateBuildActions(ctx SingletonContext) {
 		if ctx.Failed() {
 			return
 		}
-		out(ctx, gm.output, ctx.ModuleName(gm),
+		var validations Paths
+		if gm.properties.Size_budget != nil {
+			validations = append(validations, s.noticeSizeCheck(ctx, gm, modules))
+		}
+		buildNoticeOutputFromLicenseMetadata(ctx, tool, ruleName+ctx.ModuleName(gm), gm.output,
 			proptools.StringDefault(gm.properties.ArtifactName, defaultName),
 			[]string{
 				filepath.Join(ctx.Config().OutDir(), "target", "product", ctx.Config().DeviceName()) + "/",
 				ctx.Config().OutDir() + "/",
 				ctx.Config().SoongOutDir() + "/",
-			}, modules...)
+			}, validations, modules...)
+	})
+
+	if len(s.noticeSizeReports) > 0 {
+		ctx.Phony("compliance", s.noticeSizeReports...)
+	}
+}
+
+func (s *genNoticeBuildRules) MakeVars(ctx MakeVarsContext) {
+	if len(s.noticeSizeReports) > 0 {
+		ctx.DistForGoal("compliance", s.noticeSizeReports...)
+	}
+}
+
+// noticeSizeCheck builds the check that the notice file of the gen_notice module fits in its
+// size_budget, and returns its report.  The report lists the largest license texts of the modules
+// in the notice file and of their dependencies, which are the top contributors to its size.
+func (s *genNoticeBuildRules) noticeSizeCheck(ctx SingletonContext, gm *genNoticeModule, modules []Module) Path {
+	var contributors []string
+	var licenseTexts Paths
+	addContributor := func(m Module) {
+		if _, ok := m.(*licenseModule); ok {
+			// The license texts of license modules are attributed to the modules that use them.
+			return
+		}
+		for _, text := range m.EffectiveLicenseFiles() {
+			contributors = append(contributors, ctx.ModuleName(m)+"\t"+text.String())
+			licenseTexts = append(licenseTexts, text)
+		}
+	}
+	for _, m := range modules {
+		addContributor(m)
+		ctx.VisitDepsDepthFirst(m, addContributor)
+	}
+
+	name := ctx.ModuleName(gm)
+	contributorsFile := PathForOutput(ctx, "compliance", name+"_notice_contributors.txt")
+	WriteFileRule(ctx, contributorsFile, strings.Join(SortedUniqueStrings(contributors), "\n"))
+
+	report := PathForOutput(ctx, "compliance", name+"_notice_size.txt")
+	ctx.Build(pctx, BuildParams{
+		Rule:        checkNoticeSizeRule,
+		Description: "check notice size " + name,
+		Input:       gm.output,
+		Implicits:   append(Paths{contributorsFile}, SortedUniquePaths(licenseTexts)...),
+		Output:      report,
+		Args: map[string]string{
+			"budget":       strconv.FormatInt(*gm.properties.Size_budget, 10),
+			"contributors": contributorsFile.String(),
+		},
 	})
+	s.noticeSizeReports = append(s.noticeSizeReports, report)
+	return report
 }
 
 func GenNoticeBuildRulesFactory() Singleton {
@@ -103,6 +172,10 @@ type genNoticeProperties struct {
 	Suffix *string
 	// Visibility specifies where this license can be used
 	Visibility []string
+	// Size_budget is the maximum size in bytes of the output file, e.g. the budget of the notice
+	// file of the partition it is installed on.  A validation of the output file fails the build
+	// if it is larger, and lists the license texts that contribute the most to its size.
+	Size_budget *int64
 }
 
 type genNoticeModule struct {
@@ -174,6 +247,9 @@ func (m *genNoticeModule) GenerateAndroidBuildActions(ctx ModuleContext) {
 		m.missing = append(m.missing, ctx.GetMissingDependencies()...)
 		m.missing = FirstUniqueStrings(m.missing)
 	}
+	if m.properties.Size_budget != nil && *m.properties.Size_budget <= 0 {
+		ctx.PropertyErrorf("size_budget", "must be a positive number of bytes, got %d", *m.properties.Size_budget)
+	}
 	out := m.getStem() + m.getSuffix()
 	m.output = PathForModuleOut(ctx, out).OutputPath
 }
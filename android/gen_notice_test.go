@@ -162,3 +162,83 @@ func (j *mockGenruleModule) DepsMutator(ctx BottomUpMutatorContext) {
 
 func (p *mockGenruleModule) GenerateAndroidBuildActions(ModuleContext) {
 }
+
+func TestGenNoticeSizeBudget(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithGenNotice,
+		PrepareForTestWithLicenses,
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterModuleType("mock_genrule", newMockGenruleModule)
+		}),
+		FixtureAddTextFile("top/Android.bp", `
+			license_kind {
+				name: "SPDX-license-identifier-Apache-2.0",
+				conditions: ["notice"],
+			}
+
+			license {
+				name: "top_apache",
+				license_kinds: ["SPDX-license-identifier-Apache-2.0"],
+				license_text: ["LICENSE"],
+			}
+
+			license {
+				name: "top_large",
+				license_kinds: ["SPDX-license-identifier-Apache-2.0"],
+				license_text: ["LARGE_LICENSE"],
+			}
+
+			gen_notice {
+				name: "top_notice",
+				for: ["top_rule"],
+				size_budget: 4096,
+			}
+
+			mock_genrule {
+				name: "top_rule",
+				licenses: ["top_apache"],
+				dep: ["top_dep"],
+			}
+
+			mock_genrule {
+				name: "top_dep",
+				licenses: ["top_large"],
+			}
+		`),
+		FixtureAddFile("top/LICENSE", nil),
+		FixtureAddFile("top/LARGE_LICENSE", make([]byte, 8192)),
+	).RunTest(t)
+
+	singleton := result.SingletonForTests("gen_notice_build_rules")
+
+	contributors := singleton.Output("compliance/top_notice_notice_contributors.txt")
+	AssertStringEquals(t, "contributors", "top_dep\ttop/LARGE_LICENSE\ntop_rule\ttop/LICENSE",
+		ContentFromFileRuleForTests(t, contributors))
+
+	check := singleton.Rule("checkNoticeSize")
+	AssertPathRelativeToTopEquals(t, "notice", "out/soong/.intermediates/top/top_notice/android_common/top_notice", check.Input)
+	AssertPathsRelativeToTopEquals(t, "implicits", []string{
+		"out/soong/compliance/top_notice_notice_contributors.txt",
+		"top/LARGE_LICENSE",
+		"top/LICENSE",
+	}, check.Implicits)
+	AssertPathRelativeToTopEquals(t, "report", "out/soong/compliance/top_notice_notice_size.txt", check.Output)
+	AssertStringEquals(t, "budget", "4096", check.Args["budget"])
+
+	notice := singleton.Rule("text_notice_top_notice")
+	AssertPathsRelativeToTopEquals(t, "validations", []string{"out/soong/compliance/top_notice_notice_size.txt"},
+		notice.Validations)
+}
+
+func TestGenNoticeInvalidSizeBudget(t *testing.T) {
+	GroupFixturePreparers(
+		PrepareForTestWithGenNotice,
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`size_budget: must be a positive number of bytes, got 0`,
+	)).RunTestWithBp(t, `
+		gen_notice {
+			name: "top_notice",
+			size_budget: 0,
+		}
+	`)
+}
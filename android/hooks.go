@@ -93,14 +93,48 @@ func (l *loadHookContext) createModule(factory blueprint.ModuleFactory, name str
 	return l.bp.CreateModule(factory, name, props...)
 }
 
+func (l *loadHookContext) creatorHook() string {
+	return "LoadHook"
+}
+
+// ModuleCreator identifies the module and hook that created a module programmatically, rather than
+// from a module definition in an Android.bp file.
+type ModuleCreator struct {
+	// The name and module type of the module whose hook created the module.
+	Module     string
+	ModuleType string
+
+	// The hook that created the module, e.g. "LoadHook" or "mutator <name>".
+	Hook string
+}
+
+func (c ModuleCreator) String() string {
+	return fmt.Sprintf("%s of %s %s", c.Hook, c.ModuleType, c.Module)
+}
+
 type createModuleContext interface {
 	Module() Module
+	ModuleType() string
 	createModule(blueprint.ModuleFactory, string, ...interface{}) blueprint.Module
+	creatorHook() string
 }
 
 func createModule(ctx createModuleContext, factory ModuleFactory, ext string, props ...interface{}) Module {
 	inherited := []interface{}{&ctx.Module().base().commonProperties}
 
+	creator := &ModuleCreator{
+		Module:     ctx.Module().Name(),
+		ModuleType: ctx.ModuleType(),
+		Hook:       ctx.creatorHook(),
+	}
+	// Record the creator in the factory rather than after the module has been created so that it is
+	// available when the module is registered, and in all of its variants.
+	creatorFactory := func() (blueprint.Module, []interface{}) {
+		module := factory()
+		module.base().createdBy = creator
+		return module, module.GetProperties()
+	}
+
 	var typeName string
 	if typeNameLookup, ok := ModuleTypeByFactory()[reflect.ValueOf(factory)]; ok {
 		typeName = typeNameLookup
@@ -112,7 +146,7 @@ func createModule(ctx createModuleContext, factory ModuleFactory, ext string, pr
 	}
 	typeName = typeName + "_" + ext
 
-	module := ctx.createModule(ModuleFactoryAdaptor(factory), typeName, append(inherited, props...)...).(Module)
+	module := ctx.createModule(creatorFactory, typeName, append(inherited, props...)...).(Module)
 
 	if ctx.Module().base().variableProperties != nil && module.base().variableProperties != nil {
 		src := ctx.Module().base().variableProperties
@@ -0,0 +1,111 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"testing"
+)
+
+type hookParentModule struct {
+	ModuleBase
+	props struct {
+		Child_name *string
+		Child_deps []string
+	}
+}
+
+func (m *hookParentModule) GenerateAndroidBuildActions(ModuleContext) {
+}
+
+func hookParentModuleFactory() Module {
+	m := &hookParentModule{}
+	m.AddProperties(&m.props)
+	InitAndroidModule(m)
+	AddLoadHook(m, func(ctx LoadHookContext) {
+		ctx.CreateModule(depsModuleFactory, &struct {
+			Name *string
+			Deps []string
+		}{m.props.Child_name, m.props.Child_deps})
+	})
+	return m
+}
+
+var prepareForHookTests = GroupFixturePreparers(
+	prepareForModuleTests,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("hook_parent", hookParentModuleFactory)
+	}),
+)
+
+func TestCreatedModuleCreator(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForHookTests,
+		FixtureWithRootAndroidBp(`
+			hook_parent {
+				name: "foo",
+				child_name: "foo_child",
+			}
+		`),
+	).RunTest(t)
+
+	creator, created := result.ModuleForTests("foo_child", "").CreatedBy()
+	AssertBoolEquals(t, "foo_child created", true, created)
+	AssertDeepEquals(t, "foo_child creator", ModuleCreator{
+		Module:     "foo",
+		ModuleType: "hook_parent",
+		Hook:       "LoadHook",
+	}, creator)
+	AssertStringEquals(t, "foo_child creator string", "LoadHook of hook_parent foo", creator.String())
+
+	_, created = result.ModuleForTests("foo", "").CreatedBy()
+	AssertBoolEquals(t, "foo created", false, created)
+}
+
+func TestCreatedModuleErrors(t *testing.T) {
+	t.Run("duplicate name", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForHookTests,
+			FixtureWithRootAndroidBp(`
+				hook_parent {
+					name: "foo",
+					child_name: "bar",
+				}
+
+				deps {
+					name: "bar",
+				}
+			`),
+		).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			regexp.QuoteMeta("created by LoadHook of hook_parent foo"),
+		)).RunTest(t)
+	})
+
+	t.Run("missing dependency", func(t *testing.T) {
+		GroupFixturePreparers(
+			prepareForHookTests,
+			FixtureWithRootAndroidBp(`
+				hook_parent {
+					name: "foo",
+					child_name: "foo_child",
+					child_deps: ["missing"],
+				}
+			`),
+		).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(regexp.QuoteMeta(
+			`"foo_child" depends on undefined module "missing".` + "\n" +
+				`Module "foo_child" was created by LoadHook of hook_parent foo`,
+		))).RunTest(t)
+	})
+}
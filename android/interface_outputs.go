@@ -0,0 +1,189 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// Make rules that consume the outputs of Soong modules used to hard-code their paths, which broke
+// silently whenever a module or its outputs were renamed.  Instead, modules register the outputs
+// that Make may consume as interface outputs, each with a stable name, by setting the
+// InterfaceOutputsProvider.  The interface_outputs singleton collects them from all the modules,
+// reports an error if two modules register the same name, and writes:
+//
+//   out/soong/interface_outputs<make suffix>.mk, which Make includes, and which defines
+//     $(call soong-interface-output,<name>) to return the path of an interface output, and
+//     $(call soong-output-path,<path>) to return a hard-coded path with a deprecation warning if
+//     it is not a registered interface output.
+//   out/soong/interface_outputs.json, the inventory of all the outputs that Make consumes.
+//
+// The API files of droidstubs modules and the configs of platform_compat_config modules are
+// registered as interface outputs.  After Kati has run, soong_ui also warns about the hard-coded
+// Soong output paths in the Android.mk files that are not registered, see
+// ui/build/interface_outputs.go.
+
+func init() {
+	RegisterInterfaceOutputsBuildComponents(InitRegistrationContext)
+}
+
+func RegisterInterfaceOutputsBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("interface_outputs", interfaceOutputsSingletonFactory)
+}
+
+// InterfaceOutputsInfo is provided by modules that have outputs that Make rules consume.
+type InterfaceOutputsInfo struct {
+	// Outputs maps the stable name of each interface output of the module to its path.
+	Outputs map[string]Path
+}
+
+var InterfaceOutputsProvider = blueprint.NewProvider(InterfaceOutputsInfo{})
+
+// interfaceOutputNameRegexp matches the names that can be used in make variable names.
+var interfaceOutputNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func interfaceOutputsSingletonFactory() Singleton {
+	return &interfaceOutputsSingleton{}
+}
+
+type interfaceOutputsSingleton struct {
+	mkForTesting   string
+	jsonForTesting string
+}
+
+// interfaceOutput is an entry of the interface outputs inventory.
+type interfaceOutput struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Module  string `json:"module"`
+	Variant string `json:"variant,omitempty"`
+}
+
+func (s *interfaceOutputsSingleton) GenerateBuildActions(ctx SingletonContext) {
+	outputs := make(map[string]interfaceOutput)
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || !ctx.ModuleHasProvider(module, InterfaceOutputsProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, InterfaceOutputsProvider).(InterfaceOutputsInfo)
+		for _, name := range SortedKeys(info.Outputs) {
+			if !interfaceOutputNameRegexp.MatchString(name) {
+				ctx.ModuleErrorf(module, "invalid interface output name %q, it must only contain "+
+					"letters, digits, '_', '.' or '-'", name)
+				continue
+			}
+			output := interfaceOutput{
+				Name:    name,
+				Path:    info.Outputs[name].String(),
+				Module:  ctx.ModuleName(module),
+				Variant: ctx.ModuleSubDir(module),
+			}
+			if existing, ok := outputs[name]; ok {
+				// The variants of a module may share their outputs, like the common outputs of a
+				// java library.
+				if existing.Module != output.Module || existing.Path != output.Path {
+					ctx.ModuleErrorf(module, "interface output %q with path %s collides with the "+
+						"interface output with path %s registered by %s", name, output.Path,
+						existing.Path, existing.description())
+				}
+				continue
+			}
+			outputs[name] = output
+		}
+	})
+
+	if ctx.Failed() {
+		return
+	}
+
+	var sorted []interfaceOutput
+	for _, name := range SortedKeys(outputs) {
+		sorted = append(sorted, outputs[name])
+	}
+
+	mk := interfaceOutputsMakefile(sorted)
+	inventory, err := json.MarshalIndent(sorted, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal the interface outputs: %s", err)
+		return
+	}
+
+	if ctx.Config().RunningInsideUnitTest() {
+		s.mkForTesting = mk
+		s.jsonForTesting = string(inventory)
+	}
+
+	if !ctx.Config().KatiEnabled() {
+		return
+	}
+
+	mkFile := PathForOutput(ctx,
+		"interface_outputs"+proptools.String(ctx.Config().productVariables.Make_suffix)+".mk")
+	if err := WriteFileToOutputDir(mkFile, []byte(mk), 0666); err != nil {
+		ctx.Errorf(err.Error())
+	}
+	if err := WriteFileToOutputDir(PathForOutput(ctx, "interface_outputs.json"), inventory, 0666); err != nil {
+		ctx.Errorf(err.Error())
+	}
+}
+
+func (o interfaceOutput) description() string {
+	if o.Variant == "" {
+		return fmt.Sprintf("module %q", o.Module)
+	}
+	return fmt.Sprintf("module %q variant %q", o.Module, o.Variant)
+}
+
+// interfaceOutputsMakefile returns the makefile that exports the interface outputs to Make.
+func interfaceOutputsMakefile(outputs []interfaceOutput) string {
+	var names, paths []string
+	for _, output := range outputs {
+		names = append(names, output.Name)
+		paths = append(paths, output.Path)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "# Autogenerated file")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "SOONG_INTERFACE_OUTPUTS :=", strings.Join(names, " "))
+	fmt.Fprintln(buf, "SOONG_INTERFACE_OUTPUT_PATHS :=", strings.Join(FirstUniqueStrings(paths), " "))
+	fmt.Fprintln(buf)
+	for _, output := range outputs {
+		fmt.Fprintf(buf, "SOONG_INTERFACE_OUTPUT.%s := %s\n", output.Name, output.Path)
+		fmt.Fprintf(buf, "SOONG_INTERFACE_OUTPUT_MODULE.%s := %s\n", output.Name, output.Module)
+	}
+	fmt.Fprintln(buf)
+
+	// The messages must not contain commas, which would split the arguments of $(if).
+	fmt.Fprint(buf, `# $(call soong-interface-output,<name>) returns the path of the Soong interface output <name>.
+define soong-interface-output
+$(if $(filter $(strip $(1)),$(SOONG_INTERFACE_OUTPUTS)),$(SOONG_INTERFACE_OUTPUT.$(strip $(1))),$(error Soong interface output "$(strip $(1))" is not registered))
+endef
+
+# $(call soong-output-path,<path>) returns <path>, and warns if <path> is not a registered Soong
+# interface output.
+define soong-output-path
+$(if $(filter $(strip $(1)),$(SOONG_INTERFACE_OUTPUT_PATHS)),,$(warning Make consumes $(strip $(1)) which is not a registered Soong interface output. This is deprecated: hard-coded Soong output paths break silently when they are renamed. Register the output in the InterfaceOutputsProvider of its module and use soong-interface-output instead.))$(strip $(1))
+endef
+`)
+	return buf.String()
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type interfaceOutputsTestModule struct {
+	ModuleBase
+	properties struct {
+		// The names of the interface outputs of the module.
+		Interface_outputs []string
+	}
+}
+
+func interfaceOutputsTestModuleFactory() Module {
+	module := &interfaceOutputsTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidModule(module)
+	return module
+}
+
+func (m *interfaceOutputsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	outputs := make(map[string]Path)
+	for _, name := range m.properties.Interface_outputs {
+		out := PathForModuleOut(ctx, name+".txt")
+		ctx.Build(pctx, BuildParams{
+			Rule:   Touch,
+			Output: out,
+		})
+		outputs[name] = out
+	}
+	ctx.SetProvider(InterfaceOutputsProvider, InterfaceOutputsInfo{Outputs: outputs})
+}
+
+var prepareForInterfaceOutputsTest = GroupFixturePreparers(
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("test", interfaceOutputsTestModuleFactory)
+	}),
+	FixtureRegisterWithContext(RegisterInterfaceOutputsBuildComponents),
+	FixtureModifyConfig(SetKatiEnabledForTests),
+)
+
+func TestInterfaceOutputs(t *testing.T) {
+	result := prepareForInterfaceOutputsTest.RunTestWithBp(t, `
+		test {
+			name: "foo",
+			interface_outputs: ["foo_symbols", "foo.proguard_map"],
+		}
+
+		test {
+			name: "bar",
+			interface_outputs: ["bar_symbols"],
+		}
+
+		test {
+			name: "baz",
+		}
+	`)
+
+	singleton := result.SingletonForTests("interface_outputs").Singleton().(*interfaceOutputsSingleton)
+
+	mk := StringRelativeToTop(result.Config, singleton.mkForTesting)
+	AssertStringDoesContain(t, "names", mk,
+		"SOONG_INTERFACE_OUTPUTS := bar_symbols foo.proguard_map foo_symbols\n")
+	AssertStringDoesContain(t, "paths", mk,
+		"SOONG_INTERFACE_OUTPUT_PATHS := out/soong/.intermediates/bar/bar_symbols.txt "+
+			"out/soong/.intermediates/foo/foo.proguard_map.txt out/soong/.intermediates/foo/foo_symbols.txt\n")
+	AssertStringDoesContain(t, "foo_symbols", mk,
+		"SOONG_INTERFACE_OUTPUT.foo_symbols := out/soong/.intermediates/foo/foo_symbols.txt\n"+
+			"SOONG_INTERFACE_OUTPUT_MODULE.foo_symbols := foo\n")
+	AssertStringDoesContain(t, "bar_symbols", mk,
+		"SOONG_INTERFACE_OUTPUT.bar_symbols := out/soong/.intermediates/bar/bar_symbols.txt\n"+
+			"SOONG_INTERFACE_OUTPUT_MODULE.bar_symbols := bar\n")
+	AssertStringDoesContain(t, "lookup function", mk, "define soong-interface-output\n")
+	AssertStringDoesContain(t, "deprecation function", mk, "define soong-output-path\n")
+
+	AssertStringEquals(t, "inventory", `[
+  {
+    "name": "bar_symbols",
+    "path": "out/soong/.intermediates/bar/bar_symbols.txt",
+    "module": "bar"
+  },
+  {
+    "name": "foo.proguard_map",
+    "path": "out/soong/.intermediates/foo/foo.proguard_map.txt",
+    "module": "foo"
+  },
+  {
+    "name": "foo_symbols",
+    "path": "out/soong/.intermediates/foo/foo_symbols.txt",
+    "module": "foo"
+  }
+]`, StringRelativeToTop(result.Config, singleton.jsonForTesting))
+}
+
+func TestInterfaceOutputsErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bp            string
+		expectedError string
+	}{
+		{
+			name: "collision",
+			bp: `
+				test {
+					name: "foo",
+					interface_outputs: ["symbols"],
+				}
+
+				test {
+					name: "bar",
+					interface_outputs: ["symbols"],
+				}
+			`,
+			expectedError: `interface output "symbols" with path .*/symbols.txt collides with the ` +
+				`interface output with path .*/symbols.txt registered by module "(foo|bar)"`,
+		},
+		{
+			name: "invalid name",
+			bp: `
+				test {
+					name: "foo",
+					interface_outputs: ["foo symbols"],
+				}
+			`,
+			expectedError: `invalid interface output name "foo symbols"`,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			prepareForInterfaceOutputsTest.
+				ExtendWithErrorHandler(FixtureExpectsAtLeastOneErrorMatchingPattern(tt.expectedError)).
+				RunTestWithBp(t, tt.bp)
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// By default the intermediates directory of a module mirrors the source tree:
+//
+//   .intermediates/<module dir>/<module name>/<variant>
+//
+// which puts every module of a large project under a single directory.  Setting
+// SOONG_HASHED_INTERMEDIATES=true shards the intermediates directories by a hash of the module
+// directory and name instead:
+//
+//   .intermediates/<h[0:2]>/<h[2:4]>/<module name>-<h[4:16]>/<variant>
+//
+// The module name is kept in the path so that the directories remain recognizable, and the
+// .intermediates/hashed_layout.txt file maps each hashed directory back to its module.  The layout
+// that an output directory was first built with is recorded by soong_build in
+// .intermediates_layout, next to the intermediates directory so that it cannot collide with the
+// intermediates of a module directory, and switching layouts without cleaning the output directory
+// is an error, as it would leave stale copies of every intermediate file behind.
+
+const hashedIntermediatesEnvVar = "SOONG_HASHED_INTERMEDIATES"
+
+const (
+	defaultIntermediatesLayout = "default"
+	hashedIntermediatesLayout  = "hashed"
+)
+
+func init() {
+	RegisterIntermediatesLayoutBuildComponents(InitRegistrationContext)
+}
+
+func RegisterIntermediatesLayoutBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("intermediates_layout", intermediatesLayoutSingletonFactory)
+}
+
+// moduleIntermediatesDir returns the path relative to the output directory of the intermediates
+// directory of the variant subDir of the module name in moduleDir.  All the paths under the
+// intermediates directories of modules must be computed through this function.
+func moduleIntermediatesDir(config Config, moduleDir, name, subDir string) string {
+	if !config.HashedIntermediates() {
+		return filepath.Join(".intermediates", moduleDir, name, subDir)
+	}
+	hash := sha256.Sum256([]byte(moduleDir + "/" + name))
+	h := hex.EncodeToString(hash[:])
+	return filepath.Join(".intermediates", h[0:2], h[2:4], name+"-"+h[4:16], subDir)
+}
+
+func intermediatesLayoutName(hashed bool) string {
+	if hashed {
+		return hashedIntermediatesLayout
+	}
+	return defaultIntermediatesLayout
+}
+
+// CheckIntermediatesLayout returns an error if the intermediates directory was created with a
+// different layout than the one the configuration selects, as recorded in layoutFile, and
+// otherwise records the layout in layoutFile for the following builds.
+func CheckIntermediatesLayout(config Config, layoutFile string) error {
+	return checkIntermediatesLayout(absolutePath(layoutFile), config.HashedIntermediates())
+}
+
+func checkIntermediatesLayout(layoutFile string, hashed bool) error {
+	layout := intermediatesLayoutName(hashed)
+	if data, err := os.ReadFile(layoutFile); err == nil {
+		if existing := strings.TrimSpace(string(data)); existing != layout {
+			return fmt.Errorf("%s was built with the %s intermediates layout, but %s selects the %s layout.\n"+
+				"Mixing the layouts would leave stale intermediates behind, clean the output directory first.",
+				filepath.Dir(layoutFile), existing, hashedIntermediatesEnvVar, layout)
+		}
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(layoutFile), 0777); err != nil {
+		return err
+	}
+	return os.WriteFile(layoutFile, []byte(layout+"\n"), 0666)
+}
+
+func intermediatesLayoutSingletonFactory() Singleton {
+	return &intermediatesLayoutSingleton{}
+}
+
+type intermediatesLayoutSingleton struct{}
+
+func (s *intermediatesLayoutSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !ctx.Config().HashedIntermediates() {
+		return
+	}
+
+	// Each line maps the hashed intermediates directory of a module variant to the module.
+	var lines []string
+	ctx.VisitAllModules(func(module Module) {
+		moduleDir := ctx.ModuleDir(module)
+		name := ctx.ModuleName(module)
+		subDir := ctx.ModuleSubDir(module)
+		line := fmt.Sprintf("%s //%s:%s", moduleIntermediatesDir(ctx.Config(), moduleDir, name, subDir), moduleDir, name)
+		if subDir != "" {
+			line += " " + subDir
+		}
+		lines = append(lines, line)
+	})
+	sort.Strings(lines)
+
+	WriteFileRule(ctx, PathForIntermediates(ctx, "hashed_layout.txt"), strings.Join(lines, "\n"))
+}
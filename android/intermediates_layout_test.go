@@ -0,0 +1,139 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleIntermediatesDir(t *testing.T) {
+	modules := []struct {
+		moduleDir, name, subDir string
+	}{
+		{"a", "foo", "android_common"},
+		{"a", "foo", "android_arm64_armv8-a"},
+		{"b", "foo", "android_common"},
+		{"a", "bar", "android_common"},
+		{"a/foo", "foo", "android_common"},
+		{"", "foo", ""},
+	}
+
+	for _, layout := range []struct {
+		name     string
+		hashed   bool
+		expected []string
+	}{
+		{
+			name: "default",
+			expected: []string{
+				".intermediates/a/foo/android_common",
+				".intermediates/a/foo/android_arm64_armv8-a",
+				".intermediates/b/foo/android_common",
+				".intermediates/a/bar/android_common",
+				".intermediates/a/foo/foo/android_common",
+				".intermediates/foo",
+			},
+		},
+		{
+			name:   "hashed",
+			hashed: true,
+			expected: []string{
+				".intermediates/60/88/foo-d0f845e4ecf4/android_common",
+				".intermediates/60/88/foo-d0f845e4ecf4/android_arm64_armv8-a",
+				".intermediates/6c/c2/foo-52daa1af2244/android_common",
+			},
+		},
+	} {
+		t.Run(layout.name, func(t *testing.T) {
+			config := TestConfig(t.TempDir(), nil, "", nil)
+			if layout.hashed {
+				SetHashedIntermediatesForTests(config)
+			}
+
+			dirs := make(map[string]bool)
+			for i, m := range modules {
+				dir := moduleIntermediatesDir(config, m.moduleDir, m.name, m.subDir)
+				if i < len(layout.expected) {
+					AssertStringEquals(t, "intermediates dir of "+m.moduleDir+":"+m.name, layout.expected[i], dir)
+				}
+				if dirs[dir] {
+					t.Errorf("intermediates dir %q of %s:%s %s collides with another module", dir, m.moduleDir, m.name, m.subDir)
+				}
+				dirs[dir] = true
+
+				// The intermediates dirs of the variants of a module share a parent, which must not
+				// be the intermediates dir of any other module variant.
+				if m.subDir != "" && dirs[filepath.Dir(dir)] {
+					t.Errorf("intermediates dir %q of %s:%s is nested in the intermediates dir of another module", dir, m.moduleDir, m.name)
+				}
+			}
+		})
+	}
+}
+
+func TestHashedIntermediates(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		FixtureRegisterWithContext(RegisterIntermediatesLayoutBuildComponents),
+		FixtureModifyConfig(SetHashedIntermediatesForTests),
+		FixtureAddTextFile("a/Android.bp", `
+			deps {
+				name: "foo",
+			}
+		`),
+		FixtureAddTextFile("b/Android.bp", `
+			deps {
+				name: "bar",
+			}
+		`),
+	).RunTest(t)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	AssertPathRelativeToTopEquals(t, "foo output",
+		"out/soong/.intermediates/60/88/foo-d0f845e4ecf4/android_common/foo", foo.Output("foo").Output)
+
+	layout := result.SingletonForTests("intermediates_layout").Output("hashed_layout.txt")
+	content := ContentFromFileRuleForTests(t, layout)
+	AssertStringDoesContain(t, "foo mapping", content,
+		".intermediates/60/88/foo-d0f845e4ecf4/android_common //a:foo android_common")
+	AssertStringDoesContain(t, "bar mapping", content,
+		".intermediates/fd/64/bar-7ee1c33d1988/android_common //b:bar android_common")
+}
+
+func TestCheckIntermediatesLayout(t *testing.T) {
+	soongOutDir := t.TempDir()
+	layoutFile := filepath.Join(soongOutDir, ".intermediates_layout")
+
+	// The first build records its layout, which the following builds must match.
+	if err := checkIntermediatesLayout(layoutFile, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	marker, err := os.ReadFile(layoutFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertStringEquals(t, "layout marker", "hashed\n", string(marker))
+
+	if err := checkIntermediatesLayout(layoutFile, true); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = checkIntermediatesLayout(layoutFile, false)
+	AssertErrorMessageEquals(t, "mixed layouts",
+		soongOutDir+" was built with the hashed intermediates layout, but SOONG_HASHED_INTERMEDIATES selects the default layout.\n"+
+			"Mixing the layouts would leave stale intermediates behind, clean the output directory first.", err)
+}
@@ -0,0 +1,274 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync/atomic"
+)
+
+// A few sections of soong.variables are large lists that only some parts of the build read, like
+// PRODUCT_PACKAGES and the coverage paths.  loadFromConfigFile keeps the JSON of these lazy
+// sections undecoded, and each of them is decoded into productVariables the first time that its
+// accessor on config is called.  soong_build writes the number of accesses to each lazy section
+// to out/soong/lazy_product_variables.txt, to find the sections that are not used by a build.
+//
+// The fields of productVariables of the lazy sections must only be read through their accessors,
+// and must be lists of strings, which loadFromConfigFile validates without decoding them so that
+// their accessors cannot fail.
+
+// lazyProductVariableSections are the names of the fields of productVariables that are decoded
+// lazily.
+var lazyProductVariableSections = []string{
+	"ProductPackages",
+	"JavaCoveragePaths",
+	"JavaCoverageExcludePaths",
+	"NativeCoveragePaths",
+	"NativeCoverageExcludePaths",
+}
+
+var lazyProductVariableOnceKeys = func() map[string]OnceKey {
+	keys := make(map[string]OnceKey, len(lazyProductVariableSections))
+	for _, section := range lazyProductVariableSections {
+		keys[section] = NewOnceKey("lazyProductVariable" + section)
+	}
+	return keys
+}()
+
+// productVariablesJSONType is the type of the JSON representation of productVariables, a copy of
+// its exported fields in the same order and with the same tags, except that the fields of the lazy
+// sections are json.RawMessage.
+var productVariablesJSONType = func() reflect.Type {
+	t := reflect.TypeOf(productVariables{})
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if InList(f.Name, lazyProductVariableSections) {
+			f.Type = reflect.TypeOf(json.RawMessage{})
+		}
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+	}
+	return reflect.StructOf(fields)
+}()
+
+// lazyProductVariables holds the undecoded lazy sections of a soong.variables file.
+type lazyProductVariables struct {
+	// raw is the JSON of each lazy section that was present in the file.  It is not modified after
+	// the file is loaded.
+	raw map[string]json.RawMessage
+
+	// accesses counts the accesses to each lazy section.
+	accesses map[string]*int64
+}
+
+// decodeProductVariables decodes the JSON of a soong.variables file into v, except for the lazy
+// sections that are kept in v.lazy until they are accessed.
+func decodeProductVariables(r io.Reader, v *productVariables) error {
+	// Start from the current values of v, like decoding into v directly would.
+	j := reflect.New(productVariablesJSONType)
+	vValue := reflect.ValueOf(v).Elem()
+	for i := 0; i < productVariablesJSONType.NumField(); i++ {
+		if name := productVariablesJSONType.Field(i).Name; !InList(name, lazyProductVariableSections) {
+			j.Elem().Field(i).Set(vValue.FieldByName(name))
+		}
+	}
+	if err := json.NewDecoder(r).Decode(j.Interface()); err != nil {
+		return err
+	}
+	j = j.Elem()
+
+	lazy := &lazyProductVariables{
+		raw:      make(map[string]json.RawMessage),
+		accesses: make(map[string]*int64, len(lazyProductVariableSections)),
+	}
+	for i := 0; i < productVariablesJSONType.NumField(); i++ {
+		name := productVariablesJSONType.Field(i).Name
+		if !InList(name, lazyProductVariableSections) {
+			vValue.FieldByName(name).Set(j.Field(i))
+			continue
+		}
+
+		lazy.accesses[name] = new(int64)
+		raw := j.Field(i).Interface().(json.RawMessage)
+		if len(raw) == 0 {
+			continue
+		}
+		if trimmed := bytes.TrimSpace(raw); bytes.Equal(trimmed, []byte("[]")) || bytes.Equal(trimmed, []byte("null")) {
+			// Empty sections are cheap to decode, decode them now so that they are omitted when the
+			// product variables are encoded again like they would be if they were not lazy.
+			if err := json.Unmarshal(raw, lazyProductVariableField(v, name)); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			continue
+		}
+		if !isJSONStringList(raw) {
+			return fmt.Errorf("%s: expected a list of strings", name)
+		}
+		lazy.raw[name] = raw
+	}
+	v.lazy = lazy
+	return nil
+}
+
+// isJSONStringList returns true if raw, which must be valid JSON, is a list of strings.  It only
+// scans raw, which is much faster than decoding it.
+func isJSONStringList(raw []byte) bool {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '[' {
+		return false
+	}
+	// In valid JSON, an element that is not a string starts with something other than a quote
+	// where a value is expected, after the opening bracket or a comma outside of the strings.
+	expectValue := true
+	inString := false
+	for i := 1; i < len(raw)-1; i++ {
+		b := raw[i]
+		switch {
+		case inString && b == '\\':
+			i++
+		case inString:
+			inString = b != '"'
+		case b == ' ' || b == '\t' || b == '\n' || b == '\r':
+		case b == '"':
+			inString = true
+			expectValue = false
+		case expectValue:
+			return false
+		case b == ',':
+			expectValue = true
+		}
+	}
+	return true
+}
+
+// productVariablesForJSON returns the JSON representation of v, which passes the undecoded lazy
+// sections through as is.
+func productVariablesForJSON(v *productVariables) (interface{}, error) {
+	j := reflect.New(productVariablesJSONType)
+	jValue := j.Elem()
+	vValue := reflect.ValueOf(v).Elem()
+	for i := 0; i < productVariablesJSONType.NumField(); i++ {
+		name := productVariablesJSONType.Field(i).Name
+		field := vValue.FieldByName(name)
+		if !InList(name, lazyProductVariableSections) {
+			jValue.Field(i).Set(field)
+			continue
+		}
+
+		var raw json.RawMessage
+		if v.lazy != nil {
+			raw = v.lazy.raw[name]
+		}
+		if raw == nil && field.Len() > 0 {
+			var err error
+			if raw, err = json.Marshal(field.Interface()); err != nil {
+				return nil, err
+			}
+		}
+		jValue.Field(i).Set(reflect.ValueOf(raw))
+	}
+	return j.Interface(), nil
+}
+
+// lazyProductVariableField returns a pointer to the field of v for a lazy section.
+func lazyProductVariableField(v *productVariables, section string) interface{} {
+	return reflect.ValueOf(v).Elem().FieldByName(section).Addr().Interface()
+}
+
+// decodeLazyProductVariable decodes a lazy section into c.productVariables the first time it is
+// accessed, and counts the access.  Concurrent first accesses wait for the section to be decoded.
+func (c *config) decodeLazyProductVariable(section string) {
+	lazy := c.productVariables.lazy
+	if lazy == nil {
+		// The product variables were not loaded from a file, e.g. in tests.
+		return
+	}
+	atomic.AddInt64(lazy.accesses[section], 1)
+
+	raw, ok := lazy.raw[section]
+	if !ok {
+		return
+	}
+	c.Once(lazyProductVariableOnceKeys[section], func() interface{} {
+		// The section was validated when the config file was loaded, so it cannot fail to decode.
+		if err := json.Unmarshal(raw, lazyProductVariableField(&c.productVariables, section)); err != nil {
+			panic(fmt.Errorf("%s was validated but did not decode: %s", section, err))
+		}
+		return true
+	})
+}
+
+func (c *config) productPackages() []string {
+	c.decodeLazyProductVariable("ProductPackages")
+	return c.productVariables.ProductPackages
+}
+
+func (c *config) javaCoveragePaths() []string {
+	c.decodeLazyProductVariable("JavaCoveragePaths")
+	return c.productVariables.JavaCoveragePaths
+}
+
+func (c *config) javaCoverageExcludePaths() []string {
+	c.decodeLazyProductVariable("JavaCoverageExcludePaths")
+	return c.productVariables.JavaCoverageExcludePaths
+}
+
+func (c *config) nativeCoveragePaths() []string {
+	c.decodeLazyProductVariable("NativeCoveragePaths")
+	return c.productVariables.NativeCoveragePaths
+}
+
+func (c *config) nativeCoverageExcludePaths() []string {
+	c.decodeLazyProductVariable("NativeCoverageExcludePaths")
+	return c.productVariables.NativeCoverageExcludePaths
+}
+
+// lazyProductVariableAccesses returns the number of accesses to each lazy section, or nil if the
+// product variables were not loaded from a file.
+func (c *config) lazyProductVariableAccesses() map[string]int64 {
+	lazy := c.productVariables.lazy
+	if lazy == nil {
+		return nil
+	}
+	accesses := make(map[string]int64, len(lazy.accesses))
+	for section, count := range lazy.accesses {
+		accesses[section] = atomic.LoadInt64(count)
+	}
+	return accesses
+}
+
+// WriteLazyProductVariableAccesses writes the number of accesses to each lazy section of the
+// product variables during this run to accessesFile, one "<section> <accesses>" line per section.
+func WriteLazyProductVariableAccesses(config Config, accessesFile string) error {
+	accesses := config.lazyProductVariableAccesses()
+	if accesses == nil {
+		return nil
+	}
+
+	var lines []string
+	for _, section := range lazyProductVariableSections {
+		lines = append(lines, fmt.Sprintf("%s %d\n", section, accesses[section]))
+	}
+	return ioutil.WriteFile(absolutePath(accessesFile), []byte(strings.Join(lines, "")), 0666)
+}
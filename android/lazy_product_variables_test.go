@@ -0,0 +1,214 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"android/soong/bazel"
+)
+
+const lazyProductVariablesTestConfig = `{
+	"Platform_sdk_version": 33,
+	"ProductPackages": ["foo", "bar"],
+	"JavaCoveragePaths": ["frameworks"],
+	"JavaCoverageExcludePaths": ["frameworks/base/tests"],
+	"NativeCoveragePaths": [],
+	"NativeCoverageExcludePaths": null
+}
+`
+
+// loadLazyProductVariablesTestConfig returns a config with the product variables loaded from a
+// soong.variables file with the given contents.
+func loadLazyProductVariablesTestConfig(t *testing.T, contents string) *config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "soong.variables")
+	if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+		t.Fatal(err)
+	}
+	c := &config{}
+	if err := loadFromConfigFile(&c.productVariables, path); err != nil {
+		t.Fatalf("Couldn't load product config: %s", err)
+	}
+	return c
+}
+
+func TestLazyProductVariables(t *testing.T) {
+	c := loadLazyProductVariablesTestConfig(t, lazyProductVariablesTestConfig)
+
+	// Other sections are decoded when the file is loaded.
+	AssertIntEquals(t, "Platform_sdk_version", 33, *c.productVariables.Platform_sdk_version)
+
+	// Lazy sections are only decoded on their first access.
+	AssertDeepEquals(t, "ProductPackages before access", []string(nil), c.productVariables.ProductPackages)
+	AssertDeepEquals(t, "ProductPackages", []string{"foo", "bar"}, c.ProductPackages())
+	AssertDeepEquals(t, "ProductPackages after access", []string{"foo", "bar"}, c.productVariables.ProductPackages)
+	AssertDeepEquals(t, "ProductPackages again", []string{"foo", "bar"}, c.ProductPackages())
+
+	dc := &deviceConfig{config: c}
+	AssertBoolEquals(t, "frameworks/base coverage", true, dc.JavaCoverageEnabledForPath("frameworks/base"))
+	AssertBoolEquals(t, "frameworks/base/tests coverage", false, dc.JavaCoverageEnabledForPath("frameworks/base/tests"))
+	AssertBoolEquals(t, "external coverage", false, dc.JavaCoverageEnabledForPath("external/foo"))
+
+	// Empty sections are decoded when the file is loaded.
+	AssertDeepEquals(t, "NativeCoveragePaths", []string{}, c.productVariables.NativeCoveragePaths)
+	AssertDeepEquals(t, "NativeCoverageExcludePaths", []string(nil), c.productVariables.NativeCoverageExcludePaths)
+	AssertBoolEquals(t, "native coverage", false, dc.NativeCoverageEnabledForPath("frameworks/base"))
+
+	AssertDeepEquals(t, "accesses", map[string]int64{
+		"ProductPackages":            2,
+		"JavaCoveragePaths":          3,
+		"JavaCoverageExcludePaths":   3,
+		"NativeCoveragePaths":        1,
+		"NativeCoverageExcludePaths": 1,
+	}, c.lazyProductVariableAccesses())
+}
+
+func TestLazyProductVariablesConcurrentAccess(t *testing.T) {
+	c := loadLazyProductVariablesTestConfig(t, lazyProductVariablesTestConfig)
+
+	const accessors = 16
+	var start, done sync.WaitGroup
+	start.Add(1)
+	results := make([][]string, accessors)
+	for i := 0; i < accessors; i++ {
+		done.Add(1)
+		go func(i int) {
+			defer done.Done()
+			start.Wait()
+			results[i] = c.ProductPackages()
+		}(i)
+	}
+	start.Done()
+	done.Wait()
+
+	for i, result := range results {
+		AssertDeepEquals(t, fmt.Sprintf("ProductPackages of accessor %d", i), []string{"foo", "bar"}, result)
+	}
+	AssertIntEquals(t, "ProductPackages accesses", accessors, int(c.lazyProductVariableAccesses()["ProductPackages"]))
+}
+
+func TestLazyProductVariablesInvalidSection(t *testing.T) {
+	for _, section := range []string{
+		`{"foo": true}`,
+		`"foo"`,
+		`[1]`,
+		`["foo", 1]`,
+		`["foo", ["bar"]]`,
+		`["foo", {"bar": "baz"}]`,
+	} {
+		path := filepath.Join(t.TempDir(), "soong.variables")
+		if err := os.WriteFile(path, []byte(`{"ProductPackages": `+section+`}`), 0666); err != nil {
+			t.Fatal(err)
+		}
+		err := loadFromConfigFile(&productVariables{}, path)
+		if err == nil {
+			t.Errorf("expected an error for %s", section)
+			continue
+		}
+		AssertStringDoesContain(t, section, err.Error(), "ProductPackages: expected a list of strings")
+	}
+}
+
+func TestIsJSONStringList(t *testing.T) {
+	for _, raw := range []string{`[]`, ` [ ] `, `["foo"]`, `["foo", "b\"a,r", "[1]"]`, "[\n\t\"foo\"\n]"} {
+		AssertBoolEquals(t, raw, true, isJSONStringList([]byte(raw)))
+	}
+	for _, raw := range []string{`null`, `{}`, `"foo"`, `[1]`, `[true]`, `["foo", null]`} {
+		AssertBoolEquals(t, raw, false, isJSONStringList([]byte(raw)))
+	}
+}
+
+// The product variables written for Bazel must not depend on whether the sections were decoded.
+func TestLazyProductVariablesBazelConfig(t *testing.T) {
+	c := loadLazyProductVariablesTestConfig(t, lazyProductVariablesTestConfig)
+
+	eager := productVariables{}
+	if err := json.Unmarshal([]byte(lazyProductVariablesTestConfig), &eager); err != nil {
+		t.Fatal(err)
+	}
+	eagerDir := t.TempDir()
+	if err := saveToBazelConfigFile(&eager, eagerDir); err != nil {
+		t.Fatal(err)
+	}
+	lazyDir := t.TempDir()
+	if err := saveToBazelConfigFile(&c.productVariables, lazyDir); err != nil {
+		t.Fatal(err)
+	}
+
+	bzl := filepath.Join(bazel.SoongInjectionDirName, "product_config", "product_variables.bzl")
+	expected, err := os.ReadFile(filepath.Join(eagerDir, bzl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := os.ReadFile(filepath.Join(lazyDir, bzl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	AssertStringEquals(t, "product_variables.bzl", string(expected), string(actual))
+	AssertStringDoesContain(t, "product_variables.bzl", string(actual), `"ProductPackages": [`)
+
+	// The product variables have not been decoded by writing them.
+	AssertDeepEquals(t, "ProductPackages", []string(nil), c.productVariables.ProductPackages)
+}
+
+// largeProductVariablesConfig returns the contents of a soong.variables file with large lazy
+// sections, like the ones of a full product.
+func largeProductVariablesConfig(b *testing.B) []byte {
+	v := productVariables{}
+	v.SetDefaultConfig()
+	for i := 0; i < 20000; i++ {
+		v.ProductPackages = append(v.ProductPackages, fmt.Sprintf("module_%d", i))
+	}
+	for i := 0; i < 5000; i++ {
+		v.NativeCoveragePaths = append(v.NativeCoveragePaths, fmt.Sprintf("external/project_%d", i))
+		v.JavaCoveragePaths = append(v.JavaCoveragePaths, fmt.Sprintf("packages/apps/project_%d", i))
+	}
+	data, err := json.MarshalIndent(&v, "", "    ")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecodeProductVariables(b *testing.B) {
+	data := largeProductVariablesConfig(b)
+
+	b.Run("eager", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := productVariables{}
+			if err := json.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("lazy", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := productVariables{}
+			if err := decodeProductVariables(bytes.NewReader(data), &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,106 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The notice files only list the licenses of the modules that have license metadata, so a module
+// installed on a device partition without licenses is silently missing from them.  The
+// license_completeness singleton lists the installed modules whose effective licenses are empty,
+// or only have placeholder license kinds like legacy_unknown, in
+// out/soong/compliance/license_completeness.txt, which is built and dist'ed by the compliance goal.
+// Setting SOONG_ENFORCE_LICENSE_COMPLETENESS=true makes them an error instead.
+
+const enforceLicenseCompletenessEnvVar = "SOONG_ENFORCE_LICENSE_COMPLETENESS"
+
+// placeholderLicenseKinds are the license kinds that do not identify the license of a module.
+var placeholderLicenseKinds = []string{"legacy_unknown"}
+
+func init() {
+	RegisterLicenseCompletenessBuildComponents(InitRegistrationContext)
+}
+
+func RegisterLicenseCompletenessBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("license_completeness", licenseCompletenessSingletonFactory)
+}
+
+func licenseCompletenessSingletonFactory() Singleton {
+	return &licenseCompletenessSingleton{}
+}
+
+type licenseCompletenessSingleton struct {
+	report WritablePath
+}
+
+// incompleteLicenses returns why the licenses of the module are incomplete, or an empty string if
+// they are not.
+func incompleteLicenses(module Module) string {
+	if len(module.base().commonProperties.Effective_licenses) == 0 {
+		return "no licenses"
+	}
+	for _, kind := range module.EffectiveLicenseKinds() {
+		if !InList(kind, placeholderLicenseKinds) {
+			return ""
+		}
+	}
+	return "only " + strings.Join(placeholderLicenseKinds, ", ") + " license kinds"
+}
+
+func (s *licenseCompletenessSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var lines []string
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || module.Target().Os.Class != Device {
+			return
+		}
+		var partitions []string
+		for _, installed := range module.FilesToInstall() {
+			if installed.Partition() != "" {
+				partitions = append(partitions, installed.Partition())
+			}
+		}
+		if len(partitions) == 0 {
+			return
+		}
+		if reason := incompleteLicenses(module); reason != "" {
+			for _, partition := range SortedUniqueStrings(partitions) {
+				lines = append(lines, fmt.Sprintf("%s //%s:%s: %s", partition, ctx.ModuleDir(module),
+					ctx.ModuleName(module), reason))
+			}
+		}
+	})
+	lines = FirstUniqueStrings(lines)
+	sort.Strings(lines)
+
+	if len(lines) > 0 && ctx.Config().IsEnvTrue(enforceLicenseCompletenessEnvVar) {
+		ctx.Errorf("%d installed modules are missing from the notice files because they have no "+
+			"licenses, set their licenses property or the default_applicable_licenses property of "+
+			"their package:\n    %s", len(lines), strings.Join(lines, "\n    "))
+		return
+	}
+
+	s.report = PathForOutput(ctx, "compliance", "license_completeness.txt")
+	WriteFileRule(ctx, s.report, strings.Join(lines, "\n"))
+	ctx.Phony("compliance", s.report)
+}
+
+func (s *licenseCompletenessSingleton) MakeVars(ctx MakeVarsContext) {
+	if s.report != nil {
+		ctx.DistForGoal("compliance", s.report)
+	}
+}
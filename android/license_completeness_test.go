@@ -0,0 +1,90 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+var prepareForLicenseCompletenessTest = GroupFixturePreparers(
+	prepareForModuleTests,
+	PrepareForTestWithArchMutator,
+	PrepareForTestWithLicenses,
+	FixtureRegisterWithContext(RegisterLicenseCompletenessBuildComponents),
+	FixtureAddTextFile("a/Android.bp", `
+		license_kind {
+			name: "SPDX-license-identifier-Apache-2.0",
+			conditions: ["notice"],
+		}
+
+		license_kind {
+			name: "legacy_unknown",
+			conditions: ["by_exception_only"],
+		}
+
+		license {
+			name: "apache",
+			license_kinds: ["SPDX-license-identifier-Apache-2.0"],
+			license_text: ["LICENSE"],
+		}
+
+		license {
+			name: "unknown",
+			license_kinds: ["legacy_unknown"],
+		}
+
+		deps {
+			name: "foo",
+			licenses: ["apache"],
+		}
+
+		deps {
+			name: "bar",
+			vendor: true,
+		}
+
+		deps {
+			name: "baz",
+			licenses: ["unknown"],
+		}
+
+		deps {
+			name: "qux",
+			enabled: false,
+		}
+	`),
+	FixtureAddFile("a/LICENSE", nil),
+)
+
+func TestLicenseCompleteness(t *testing.T) {
+	result := prepareForLicenseCompletenessTest.RunTest(t)
+
+	report := result.SingletonForTests("license_completeness").Output("compliance/license_completeness.txt")
+	AssertStringEquals(t, "report", ""+
+		"system //a:baz: only legacy_unknown license kinds\n"+
+		"vendor //a:bar: no licenses",
+		ContentFromFileRuleForTests(t, report))
+}
+
+func TestEnforceLicenseCompleteness(t *testing.T) {
+	GroupFixturePreparers(
+		prepareForLicenseCompletenessTest,
+		FixtureMergeEnv(map[string]string{enforceLicenseCompletenessEnvVar: "true"}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`2 installed modules are missing from the notice files because they have no licenses, .*\n` +
+			`    system //a:baz: only legacy_unknown license kinds\n` +
+			`    vendor //a:bar: no licenses`,
+	)).RunTest(t)
+}
@@ -66,8 +66,10 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 			return
 		}
 
+		capabilities := ctx.OtherModuleDependencyTagCapabilities(dep)
+
 		// Defaults add properties and dependencies that get processed on their own.
-		if ctx.OtherModuleDependencyTag(dep) == DefaultsDepTag {
+		if !capabilities.PropagatesLicenses {
 			return
 		}
 
@@ -78,7 +80,7 @@ func buildLicenseMetadata(ctx ModuleContext, licenseMetadataFile WritablePath) {
 				allDepMetadataDepSets = append(allDepMetadataDepSets, info.LicenseMetadataDepSet)
 			}
 
-			depAnnotations := licenseAnnotationsFromTag(ctx.OtherModuleDependencyTag(dep))
+			depAnnotations := licenseAnnotationsString(capabilities.LicenseAnnotations)
 
 			allDepMetadataArgs = append(allDepMetadataArgs, info.LicenseMetadataPath.String()+depAnnotations)
 
@@ -201,18 +203,15 @@ type LicenseMetadataInfo struct {
 	LicenseMetadataDepSet *PathsDepSet
 }
 
-// licenseAnnotationsFromTag returns the LicenseAnnotations for a tag (if any) converted into
-// a string, or an empty string if there are none.
-func licenseAnnotationsFromTag(tag blueprint.DependencyTag) string {
-	if annoTag, ok := tag.(LicenseAnnotationsDependencyTag); ok {
-		annos := annoTag.LicenseAnnotations()
-		if len(annos) > 0 {
-			annoStrings := make([]string, len(annos))
-			for i, s := range annos {
-				annoStrings[i] = string(s)
-			}
-			return ":" + strings.Join(annoStrings, ",")
+// licenseAnnotationsString returns the LicenseAnnotations of a dependency converted into a string,
+// or an empty string if there are none.
+func licenseAnnotationsString(annos []LicenseAnnotation) string {
+	if len(annos) > 0 {
+		annoStrings := make([]string, len(annos))
+		for i, s := range annos {
+			annoStrings[i] = string(s)
 		}
+		return ":" + strings.Join(annoStrings, ",")
 	}
 	return ""
 }
@@ -15,9 +15,11 @@
 package android
 
 import (
+	"fmt"
 	"io/ioutil"
 	"runtime"
 	"sort"
+	"strings"
 
 	"github.com/google/blueprint/metrics"
 	"google.golang.org/protobuf/proto"
@@ -30,6 +32,9 @@ var soongMetricsOnceKey = NewOnceKey("soong metrics")
 type SoongMetrics struct {
 	Modules  int
 	Variants int
+
+	// The number of modules of each module type.
+	ModuleTypes map[string]int
 }
 
 func readSoongMetrics(config Config) (SoongMetrics, bool) {
@@ -50,10 +55,11 @@ func soongMetricsSingletonFactory() Singleton { return soongMetricsSingleton{} }
 type soongMetricsSingleton struct{}
 
 func (soongMetricsSingleton) GenerateBuildActions(ctx SingletonContext) {
-	metrics := SoongMetrics{}
+	metrics := SoongMetrics{ModuleTypes: make(map[string]int)}
 	ctx.VisitAllModules(func(m Module) {
 		if ctx.PrimaryModule(m) == m {
 			metrics.Modules++
+			metrics.ModuleTypes[ctx.ModuleType(m)]++
 		}
 		metrics.Variants++
 	})
@@ -121,3 +127,19 @@ func WriteMetrics(config Config, eventHandler *metrics.EventHandler, metricsFile
 
 	return nil
 }
+
+// WriteModuleTypeCounts writes the number of modules of each module type to countsFile, one
+// "<module type> <count>" line per module type, for soong_ui to estimate the disk space that the
+// build requires before the next build starts.
+func WriteModuleTypeCounts(config Config, countsFile string) error {
+	soongMetrics, ok := readSoongMetrics(config)
+	if !ok {
+		return nil
+	}
+
+	var lines []string
+	for _, moduleType := range SortedStringKeys(soongMetrics.ModuleTypes) {
+		lines = append(lines, fmt.Sprintf("%s %d\n", moduleType, soongMetrics.ModuleTypes[moduleType]))
+	}
+	return ioutil.WriteFile(absolutePath(countsFile), []byte(strings.Join(lines, "")), 0666)
+}
@@ -131,6 +131,8 @@ type EarlyModuleContext interface {
 	ModuleErrorf(fmt string, args ...interface{})
 
 	// PropertyErrorf reports an error at the line number of a property in the module definition.
+	// If the property is inherited from a defaults module the error is reported at the module and
+	// the name of the defaults module is appended to the message.
 	PropertyErrorf(property, fmt string, args ...interface{})
 
 	// Failed returns true if any errors have been reported.  In most cases the module can continue with generating
@@ -179,6 +181,10 @@ type BaseModuleContext interface {
 
 	blueprintBaseModuleContext() blueprint.BaseModuleContext
 
+	// PropertyErrorWithPosf is like PropertyErrorf, but reports an error for a property that is
+	// inherited from a defaults module at the line number of the property in the defaults module.
+	PropertyErrorWithPosf(property, fmt string, args ...interface{})
+
 	// OtherModuleName returns the name of another Module.  See BaseModuleContext.ModuleName for more information.
 	// It is intended for use inside the visit functions of Visit* and WalkDeps.
 	OtherModuleName(m blueprint.Module) string
@@ -196,6 +202,10 @@ type BaseModuleContext interface {
 	// dependencies on the module being visited, it returns the dependency tag used for the current dependency.
 	OtherModuleDependencyTag(m blueprint.Module) blueprint.DependencyTag
 
+	// OtherModuleDependencyTagCapabilities returns the capabilities of the dependency tag returned by
+	// OtherModuleDependencyTag, see DependencyTagCapabilities.
+	OtherModuleDependencyTagCapabilities(m blueprint.Module) DependencyTagCapabilities
+
 	// OtherModuleExists returns true if a module with the specified name exists, as determined by the NameInterface
 	// passed to Context.SetNameInterface, or SimpleNameInterface if it was not called.
 	OtherModuleExists(name string) bool
@@ -530,6 +540,7 @@ type Module interface {
 	ImageVariation() blueprint.Variation
 
 	Owner() string
+	Team() string
 	InstallInData() bool
 	InstallInTestcases() bool
 	InstallInSanitizerDir() bool
@@ -812,6 +823,10 @@ type commonProperties struct {
 	// vendor who owns this module
 	Owner *string
 
+	// team that owns this module, used to attribute the size of its installed files in
+	// out/soong/size_attribution.csv.  Defaults to owner.
+	Team *string
+
 	// whether this module is specific to an SoC (System-On-a-Chip). When set to true,
 	// it is installed into /vendor (or /system/vendor if vendor partition does not exist).
 	// Use `soc_specific` instead for better meaning.
@@ -866,6 +881,10 @@ type commonProperties struct {
 	// names of other modules to install on target if this module is installed
 	Target_required []string `android:"arch_variant"`
 
+	// names of other modules whose checks, like the abi check of a library, must pass for this
+	// module to build, without this module depending on their outputs.
+	Validation_deps []string `android:"arch_variant"`
+
 	// The OsType of artifacts that this module variant is responsible for creating.
 	//
 	// Set by osMutator
@@ -1516,6 +1535,9 @@ type ModuleBase struct {
 
 	// The path to the generated license metadata file for the module.
 	licenseMetadataFile WritablePath
+
+	// The module and hook that created this module, or nil if it was defined in an Android.bp file.
+	createdBy *ModuleCreator
 }
 
 // A struct containing all relevant information about a Bazel target converted via bp2build.
@@ -1797,7 +1819,7 @@ func (m *ModuleBase) Dists() []Dist {
 
 func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFiles {
 	var distFiles TaggedDistFiles
-	for _, dist := range m.Dists() {
+	addDistFiles := func(property string, dist Dist) {
 		// If no tag is specified then it means to use the default dist paths so use
 		// the special tag name which represents that.
 		tag := proptools.StringDefault(dist.Tag, DefaultDistTag)
@@ -1810,7 +1832,14 @@ func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFi
 			// Failing to find paths for DefaultDistTag is not an error. It just means
 			// that the module type requires the legacy behavior.
 			if err != nil && tag != DefaultDistTag {
-				ctx.PropertyErrorf("dist.tag", "%s", err.Error())
+				ctx.PropertyErrorf(property+".tag", "%s", err.Error())
+			}
+
+			// A dest or suffix can only rename a single file, check that here rather than when
+			// writing the dist rules so that the error points at the property.
+			if len(distFilesForTag) > 1 && (dist.Dest != nil || dist.Suffix != nil) {
+				ctx.PropertyErrorf(property, "cannot apply dest or suffix to the %d files of tag %q: %s",
+					len(distFilesForTag), tag, distFilesForTag)
 			}
 
 			distFiles = distFiles.addPathsForTag(tag, distFilesForTag...)
@@ -1818,11 +1847,20 @@ func (m *ModuleBase) GenerateTaggedDistFiles(ctx BaseModuleContext) TaggedDistFi
 			// If the tag was specified then it is an error if the module does not
 			// implement OutputFileProducer because there is no other way of accessing
 			// the paths for the specified tag.
-			ctx.PropertyErrorf("dist.tag",
+			ctx.PropertyErrorf(property+".tag",
 				"tag %s not supported because the module does not implement OutputFileProducer", tag)
 		}
 	}
 
+	// The same tag may be dist'd by several entries, e.g. to different goals or under different
+	// names, its paths are only added once.
+	for i, dist := range m.distProperties.Dists {
+		addDistFiles(fmt.Sprintf("dists[%d]", i), dist)
+	}
+	if len(m.distProperties.Dist.Targets) > 0 {
+		addDistFiles("dist", m.distProperties.Dist)
+	}
+
 	return distFiles
 }
 
@@ -2136,6 +2174,14 @@ func (m *ModuleBase) Owner() string {
 	return String(m.commonProperties.Owner)
 }
 
+// Team returns the team property of the module, or its owner property if team is not set.
+func (m *ModuleBase) Team() string {
+	if m.commonProperties.Team != nil {
+		return *m.commonProperties.Team
+	}
+	return m.Owner()
+}
+
 func (m *ModuleBase) setImageVariation(variant string) {
 	m.commonProperties.ImageVariation = variant
 }
@@ -2330,6 +2376,9 @@ func (m *ModuleBase) baseModuleContextFactory(ctx blueprint.BaseModuleContext) b
 }
 
 func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext) {
+	recordModuleProgress(blueprintCtx.Config().(Config), "generate_build_actions", blueprintCtx)
+	defer trackModuleForTests(blueprintCtx.Config().(Config), "GenerateAndroidBuildActions", "", blueprintCtx)()
+
 	ctx := &moduleContext{
 		module:            m.module,
 		bp:                blueprintCtx,
@@ -2396,6 +2445,7 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 		})
 
 		licensesPropertyFlattener(ctx)
+		ctx.validations = validationDepsPaths(ctx)
 		if ctx.Failed() {
 			return
 		}
@@ -2405,6 +2455,7 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 		} else {
 			m.module.GenerateAndroidBuildActions(ctx)
 		}
+		ctx.attachValidations()
 		if ctx.Failed() {
 			return
 		}
@@ -2430,6 +2481,8 @@ func (m *ModuleBase) GenerateBuildActions(blueprintCtx blueprint.ModuleContext)
 			return
 		}
 
+		setExperimentInfo(ctx)
+
 		m.installFiles = append(m.installFiles, ctx.installFiles...)
 		m.checkbuildFiles = append(m.checkbuildFiles, ctx.checkbuildFiles...)
 		m.packagingSpecs = append(m.packagingSpecs, ctx.packagingSpecs...)
@@ -2502,6 +2555,10 @@ type earlyModuleContext struct {
 	config Config
 }
 
+func (e *earlyModuleContext) PropertyErrorf(property, format string, args ...interface{}) {
+	propertyErrorf(e, e.EarlyModuleContext.PropertyErrorf, property, format, args...)
+}
+
 func (e *earlyModuleContext) Glob(globPattern string, excludes []string) Paths {
 	return Glob(e, globPattern, excludes)
 }
@@ -2587,6 +2644,10 @@ type baseModuleContext struct {
 func (b *baseModuleContext) isBazelConversionMode() bool {
 	return b.bazelConversionMode
 }
+func (b *baseModuleContext) PropertyErrorWithPosf(property, format string, args ...interface{}) {
+	propertyErrorWithPosf(b, property, format, args...)
+}
+
 func (b *baseModuleContext) OtherModuleName(m blueprint.Module) string {
 	return b.bp.OtherModuleName(m)
 }
@@ -2597,6 +2658,9 @@ func (b *baseModuleContext) OtherModuleErrorf(m blueprint.Module, fmt string, ar
 func (b *baseModuleContext) OtherModuleDependencyTag(m blueprint.Module) blueprint.DependencyTag {
 	return b.bp.OtherModuleDependencyTag(m)
 }
+func (b *baseModuleContext) OtherModuleDependencyTagCapabilities(m blueprint.Module) DependencyTagCapabilities {
+	return DependencyTagCapabilitiesOf(b.bp.OtherModuleDependencyTag(m))
+}
 func (b *baseModuleContext) OtherModuleExists(name string) bool { return b.bp.OtherModuleExists(name) }
 func (b *baseModuleContext) OtherModuleDependencyVariantExists(variations []blueprint.Variation, name string) bool {
 	return b.bp.OtherModuleDependencyVariantExists(variations, name)
@@ -2646,6 +2710,13 @@ type moduleContext struct {
 	katiInstalls []katiInstall
 	katiSymlinks []katiInstall
 
+	// The outputs of the checks of the validation_deps, which are attached as validations to the
+	// build statements of the primary outputs of the module.  The build statements are held in
+	// pendingBuilds until the module has generated its build actions and its primary outputs are
+	// known.
+	validations   Paths
+	pendingBuilds []pendingBuild
+
 	// For tests
 	buildParams []BuildParams
 	ruleParams  map[blueprint.Rule]blueprint.RuleParams
@@ -2818,6 +2889,14 @@ func (m *moduleContext) Rule(pctx PackageContext, name string, params blueprint.
 }
 
 func (m *moduleContext) Build(pctx PackageContext, params BuildParams) {
+	if len(m.validations) > 0 {
+		m.pendingBuilds = append(m.pendingBuilds, pendingBuild{pctx, params})
+		return
+	}
+	m.build(pctx, params)
+}
+
+func (m *moduleContext) build(pctx PackageContext, params BuildParams) {
 	if params.Description != "" {
 		params.Description = "${moduleDesc}" + params.Description + "${moduleDescSuffix}"
 	}
@@ -3558,6 +3637,15 @@ func sourceOrOutputDepTag(moduleName, tag string) blueprint.DependencyTag {
 	return sourceOrOutputDependencyTag{moduleName: moduleName, tag: tag}
 }
 
+func (t sourceOrOutputDependencyTag) DependencyProperty() string {
+	if t.tag != "" {
+		return fmt.Sprintf(`path property reference ":%s{%s}"`, t.moduleName, t.tag)
+	}
+	return fmt.Sprintf(`path property reference ":%s"`, t.moduleName)
+}
+
+var _ PropertyDependencyTag = sourceOrOutputDependencyTag{}
+
 // IsSourceDepTagWithOutputTag returns true if the supplied blueprint.DependencyTag is one that was
 // used to add dependencies by either ExtractSourceDeps, ExtractSourcesDeps or automatically for
 // properties tagged with `android:"path"` AND it was added using a module reference of
@@ -3798,20 +3886,22 @@ func (c *buildTargetSingleton) GenerateBuildActions(ctx SingletonContext) {
 	// Create a top-level checkbuild target that depends on all modules
 	ctx.Phony("checkbuild"+suffix, checkbuildDeps...)
 
-	// Make will generate the MODULES-IN-* targets
-	if ctx.Config().KatiEnabled() {
-		return
-	}
-
 	dirs, _ := AddAncestors(ctx, modulesInDir, mmTarget)
 
 	// Create a MODULES-IN-<directory> target that depends on all modules in a directory, and
 	// depends on the MODULES-IN-* targets of all of its subdirectories that contain Android.bp
-	// files.
+	// files.  When Kati is enabled the phony targets are written to Make as prerequisites, which
+	// Make merges with the MODULES-IN-* targets it generates for the directories that contain
+	// modules exported to it, so `m <dir>` builds the modules of both.
 	for _, dir := range dirs {
 		ctx.Phony(mmTarget(dir), modulesInDir[dir]...)
 	}
 
+	// Make will generate the remaining targets
+	if ctx.Config().KatiEnabled() {
+		return
+	}
+
 	// Create (host|host-cross|target)-<OS> phony rules to build a reduced checkbuild.
 	type osAndCross struct {
 		os        OsType
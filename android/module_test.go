@@ -560,6 +560,29 @@ func TestInstallKatiEnabled(t *testing.T) {
 	assertOrderOnlys(symlinkRule("foo"))
 }
 
+func TestModulesInDirKatiEnabled(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureModifyConfig(SetKatiEnabledForTests),
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			ctx.RegisterSingletonType("buildtarget", BuildTargetSingleton)
+		}),
+		FixtureAddTextFile("a/Android.bp", `deps { name: "foo" }`),
+		FixtureAddTextFile("a/b/Android.bp", `deps { name: "bar" }`),
+	).RunTest(t)
+
+	phonies := getPhonyMap(result.Config)
+
+	// The MODULES-IN-* targets of directories include the MODULES-IN-* targets of their
+	// subdirectories, and use the names soong_ui builds for `m <dir>` so that they are merged
+	// with the targets Make generates.
+	AssertArrayString(t, "MODULES-IN-a", []string{"MODULES-IN-a-b", "foo-checkbuild", "foo-install"},
+		SortedUniquePaths(phonies["MODULES-IN-a"]).Strings())
+	AssertArrayString(t, "MODULES-IN-a-b", []string{"bar-checkbuild", "bar-install"},
+		SortedUniquePaths(phonies["MODULES-IN-a-b"]).Strings())
+}
+
 type PropsTestModuleEmbedded struct {
 	Embedded_prop *string
 }
@@ -0,0 +1,184 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/blueprint"
+)
+
+// In tests a panic in the mutators and GenerateAndroidBuildActions is annotated with the module
+// variant and the mutator that it happened in.  Tests with a timeout, see FixtureWithTimeout, also
+// record which module variants are being processed in a moduleTracker, so that a test that hangs can
+// be failed with the list of the modules that are still being processed.  Outside of tests the hooks
+// do nothing.
+
+// errModuleTrackerCancelled is the panic raised by the module variants that start being processed
+// after the analysis timed out, to stop the analysis.
+var errModuleTrackerCancelled = errors.New("analysis cancelled after timing out")
+
+// moduleTracker records the module variants that are being processed.
+type moduleTracker struct {
+	lock sync.Mutex
+
+	// inProgress counts the module variants being processed by their description.
+	inProgress map[string]int
+
+	// cancelled is set when the analysis timed out, see cancel.
+	cancelled bool
+}
+
+func newModuleTracker() *moduleTracker {
+	return &moduleTracker{inProgress: make(map[string]int)}
+}
+
+func (t *moduleTracker) start(desc string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.cancelled {
+		panic(errModuleTrackerCancelled)
+	}
+	t.inProgress[desc]++
+}
+
+// cancel makes the module variants that start being processed afterwards panic, so that an analysis
+// that timed out stops at the next module variant instead of running on in the background.
+func (t *moduleTracker) cancel() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.cancelled = true
+}
+
+func (t *moduleTracker) finish(desc string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.inProgress[desc]--; t.inProgress[desc] <= 0 {
+		delete(t.inProgress, desc)
+	}
+}
+
+// modulesInProgress returns the sorted descriptions of the module variants being processed.
+func (t *moduleTracker) modulesInProgress() []string {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	modules := make([]string, 0, len(t.inProgress))
+	for desc := range t.inProgress {
+		modules = append(modules, desc)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// modulePanic annotates a panic with the module variant and the phase in which it happened.
+type modulePanic struct {
+	in    string
+	value interface{}
+}
+
+func (p *modulePanic) Error() string {
+	return fmt.Sprintf("panic in %s: %v", p.in, p.value)
+}
+
+func (p *modulePanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+// trackModuleForTests records that the module variant of ctx is being processed in the phase, e.g.
+// "mutator" with the name of the mutator, if the config tracks modules.  The returned function must
+// be deferred, in tests it records the end of the processing and re-raises any panic annotated with
+// the module variant and the phase.
+func trackModuleForTests(config Config, phase, name string, ctx blueprint.BaseModuleContext) func() {
+	tracker := config.moduleTracker
+	if tracker == nil && !config.captureBuild {
+		return noopTrackModuleEnd
+	}
+	if name != "" {
+		phase = fmt.Sprintf("%s %q", phase, name)
+	}
+	desc := fmt.Sprintf("%s for module %q variant %q", phase, ctx.ModuleName(), ctx.ModuleSubDir())
+	if tracker != nil {
+		tracker.start(desc)
+	}
+	return func() {
+		if tracker != nil {
+			tracker.finish(desc)
+		}
+		if r := recover(); r != nil {
+			if _, ok := r.(*modulePanic); ok || r == errModuleTrackerCancelled {
+				// The panic has already been annotated by a nested phase, or stops the analysis.
+				panic(r)
+			}
+			panic(&modulePanic{in: desc, value: r})
+		}
+	}
+}
+
+func noopTrackModuleEnd() {}
+
+// runWithTimeout runs f, and returns an error listing the module variants still being processed if
+// f does not return within the timeout.  A panic in f is re-raised in the calling goroutine.
+//
+// When f times out the tracker is cancelled, and runWithTimeout waits for up to another timeout for
+// f to stop at the start of the next module variant, so that the analysis does not keep running in
+// the background of the following tests.  A module variant that never returns cannot be stopped,
+// in which case the error says so.
+func runWithTimeout(tracker *moduleTracker, timeout time.Duration, f func()) error {
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		f()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r != nil {
+			panic(r)
+		}
+		return nil
+	case <-timer.C:
+	}
+
+	modules := tracker.modulesInProgress()
+	var err error
+	if len(modules) == 0 {
+		err = fmt.Errorf("timed out after %s without processing any module", timeout)
+	} else {
+		err = fmt.Errorf("timed out after %s, still processing:\n    %s", timeout, strings.Join(modules, "\n    "))
+	}
+
+	tracker.cancel()
+	timer.Reset(timeout)
+	select {
+	case <-done:
+		// The panics of a cancelled analysis are dropped, the timeout is the failure.
+		return err
+	case <-timer.C:
+		return fmt.Errorf("%w\nthe analysis could not be stopped", err)
+	}
+}
@@ -72,11 +72,22 @@ func collateGloballyRegisteredMutators() sortableComponents {
 
 // collateRegisteredMutators constructs a single list of mutators from the separate lists.
 func collateRegisteredMutators(preArch, preDeps, postDeps, finalDeps []RegisterMutatorFunc) sortableComponents {
+	return collateFilteredMutators(preArch, preDeps, postDeps, finalDeps, nil)
+}
+
+// collateFilteredMutators is collateRegisteredMutators with an optional filter, which is passed the
+// mutators registered by each RegisterMutatorFunc and returns a new list of the ones to keep.
+func collateFilteredMutators(preArch, preDeps, postDeps, finalDeps []RegisterMutatorFunc,
+	filter func(f RegisterMutatorFunc, mutators sortableComponents) sortableComponents) sortableComponents {
 	mctx := &registerMutatorsContext{}
 
 	register := func(funcs []RegisterMutatorFunc) {
 		for _, f := range funcs {
+			start := len(mctx.mutators)
 			f(mctx)
+			if filter != nil {
+				mctx.mutators = append(mctx.mutators[:start], filter(f, mctx.mutators[start:])...)
+			}
 		}
 	}
 
@@ -191,10 +202,16 @@ func registerArchMutator(ctx RegisterMutatorsContext) {
 
 var preDeps = []RegisterMutatorFunc{
 	registerArchMutator,
+
+	// Gather the required modules of each device module for packaging modules that follow them.
+	//
+	// This must come after the arch mutator as the required properties are arch specific.
+	RegisterPackagingRequiredDepsGatherer,
 }
 
 var postDeps = []RegisterMutatorFunc{
 	registerPathDepsMutator,
+	registerValidationDepsMutator,
 	RegisterPrebuiltsPostDepsMutators,
 	RegisterVisibilityRuleEnforcer,
 	RegisterLicensesDependencyChecker,
@@ -422,12 +439,15 @@ func bottomUpMutatorContextFactory(ctx blueprint.BottomUpMutatorContext, a Modul
 func (x *registerMutatorsContext) BottomUp(name string, m BottomUpMutator) MutatorHandle {
 	finalPhase := x.finalPhase
 	bazelConversionMode := x.bazelConversionMode
+	mutatorName := x.mutatorName(name)
 	f := func(ctx blueprint.BottomUpMutatorContext) {
 		if a, ok := ctx.Module().(Module); ok {
+			recordModuleProgress(ctx.Config().(Config), mutatorName, ctx)
+			defer trackModuleForTests(ctx.Config().(Config), "mutator", mutatorName, ctx)()
 			m(bottomUpMutatorContextFactory(ctx, a, finalPhase, bazelConversionMode))
 		}
 	}
-	mutator := &mutator{name: x.mutatorName(name), bottomUpMutator: f}
+	mutator := &mutator{name: mutatorName, bottomUpMutator: f}
 	x.mutators = append(x.mutators, mutator)
 	return mutator
 }
@@ -622,8 +642,11 @@ func (x *registerMutatorsContext) mutatorName(name string) string {
 }
 
 func (x *registerMutatorsContext) TopDown(name string, m TopDownMutator) MutatorHandle {
+	mutatorName := x.mutatorName(name)
 	f := func(ctx blueprint.TopDownMutatorContext) {
 		if a, ok := ctx.Module().(Module); ok {
+			recordModuleProgress(ctx.Config().(Config), mutatorName, ctx)
+			defer trackModuleForTests(ctx.Config().(Config), "mutator", mutatorName, ctx)()
 			moduleContext := a.base().baseModuleContextFactory(ctx)
 			moduleContext.bazelConversionMode = x.bazelConversionMode
 			actx := &topDownMutatorContext{
@@ -633,7 +656,7 @@ func (x *registerMutatorsContext) TopDown(name string, m TopDownMutator) Mutator
 			m(actx)
 		}
 	}
-	mutator := &mutator{name: x.mutatorName(name), topDownMutator: f}
+	mutator := &mutator{name: mutatorName, topDownMutator: f}
 	x.mutators = append(x.mutators, mutator)
 	return mutator
 }
@@ -807,6 +830,10 @@ func (t *topDownMutatorContext) createModule(factory blueprint.ModuleFactory, na
 	return t.bp.CreateModule(factory, name, props...)
 }
 
+func (t *topDownMutatorContext) creatorHook() string {
+	return "mutator " + t.MutatorName()
+}
+
 func (t *topDownMutatorContext) CreateModule(factory ModuleFactory, props ...interface{}) Module {
 	return createModule(t, factory, "_topDownMutatorModule", props...)
 }
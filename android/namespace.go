@@ -213,17 +213,37 @@ func (r *NameResolver) NewModule(ctx blueprint.NamespaceContext, moduleGroup blu
 	// if this module is not a namespace, then save it into the appropriate namespace
 	ns := r.findNamespaceFromCtx(ctx)
 
+	var creator *ModuleCreator
+	amod, ok := module.(Module)
+	if ok {
+		creator = amod.base().createdBy
+	}
+
 	_, errs = ns.moduleContainer.NewModule(ctx, moduleGroup, module)
 	if len(errs) > 0 {
+		// Report which hooks created the conflicting modules, as their definitions are not in the
+		// Android.bp files.
+		previous, previousCreated := ns.moduleCreators[module.Name()]
+		for i, err := range errs {
+			if previousCreated {
+				err = fmt.Errorf("%s\n       previous definition created by %s", err, previous)
+			}
+			if creator != nil {
+				err = fmt.Errorf("%s\n       created by %s", err, creator)
+			}
+			errs[i] = err
+		}
 		return nil, errs
 	}
 
-	amod, ok := module.(Module)
 	if ok {
 		// inform the module whether its namespace is one that we want to export to Make
 		amod.base().commonProperties.NamespaceExportedToMake = ns.exportToKati
 		amod.base().commonProperties.DebugName = module.Name()
 	}
+	if creator != nil {
+		ns.moduleCreators[module.Name()] = *creator
+	}
 
 	return ns, nil
 }
@@ -299,7 +319,13 @@ func (r *NameResolver) ModuleFromName(name string, namespace blueprint.Namespace
 }
 
 func (r *NameResolver) Rename(oldName string, newName string, namespace blueprint.Namespace) []error {
-	return namespace.(*Namespace).moduleContainer.Rename(oldName, newName, namespace)
+	ns := namespace.(*Namespace)
+	errs := ns.moduleContainer.Rename(oldName, newName, namespace)
+	if creator, found := ns.moduleCreators[oldName]; found && len(errs) == 0 {
+		delete(ns.moduleCreators, oldName)
+		ns.moduleCreators[newName] = creator
+	}
+	return errs
 }
 
 // resolve each element of namespace.importedNamespaceNames and put the result in namespace.visibleNamespaces
@@ -330,6 +356,11 @@ func (r *NameResolver) chooseId(namespace *Namespace) {
 
 func (r *NameResolver) MissingDependencyError(depender string, dependerNamespace blueprint.Namespace, depName string, guess []string) (err error) {
 	text := fmt.Sprintf("%q depends on undefined module %q.", depender, depName)
+	if dependerNs, ok := dependerNamespace.(*Namespace); ok {
+		if creator, found := dependerNs.moduleCreators[depender]; found {
+			text += fmt.Sprintf("\nModule %q was created by %s", depender, creator)
+		}
+	}
 
 	_, _, isAbs := r.parseFullyQualifiedName(depName)
 	if isAbs {
@@ -406,10 +437,17 @@ type Namespace struct {
 	exportToKati bool
 
 	moduleContainer blueprint.NameInterface
+
+	// the hooks that created the modules in this namespace that are not defined in Android.bp files
+	moduleCreators map[string]ModuleCreator
 }
 
 func NewNamespace(path string) *Namespace {
-	return &Namespace{Path: path, moduleContainer: blueprint.NewSimpleNameInterface()}
+	return &Namespace{
+		Path:            path,
+		moduleContainer: blueprint.NewSimpleNameInterface(),
+		moduleCreators:  make(map[string]ModuleCreator),
+	}
 }
 
 var _ blueprint.Namespace = (*Namespace)(nil)
@@ -60,6 +60,7 @@ func init() {
 	AddNeverAllowRules(createProhibitFrameworkAccessRules()...)
 	AddNeverAllowRules(createBp2BuildRule())
 	AddNeverAllowRules(createCcStubsRule())
+	AddNeverAllowRules(createRustExternRlibsRule())
 }
 
 // Add a NeverAllow rule to the set of rules to apply.
@@ -179,6 +180,19 @@ func createJavaDeviceForHostRules() []Rule {
 	}
 }
 
+func createRustExternRlibsRule() Rule {
+	// extern_rlibs points rustc at rlib files that are not described by any module, which is
+	// only acceptable while bringing up a new target.
+	rustExternRlibsAllowedList := []string{
+		"prebuilts/rust",
+	}
+
+	return NeverAllow().
+		NotIn(rustExternRlibsAllowedList...).
+		WithMatcher("extern_rlibs.crate_name", isSetMatcherInstance).
+		Because("extern_rlibs can only be used to bring up new targets in allowed directories, use a rust_prebuilt_rlib module instead")
+}
+
 func createCcSdkVariantRules() []Rule {
 	sdkVersionOnlyAllowedList := []string{
 		// derive_sdk_prefer32 has stem: "derive_sdk" which conflicts with the derive_sdk.
@@ -279,9 +293,19 @@ func neverallowMutator(ctx BottomUpMutatorContext) {
 
 	osClass := ctx.Module().Target().Os.Class
 
-	for _, r := range neverallowRules(ctx.Config()) {
+	// In report mode violations are recorded for the neverallow report instead of failing the
+	// build, and modules that are only allowed because of an exemption path are recorded too.
+	report := neverallowReportEnabled(ctx.Config())
+	var reportInfo neverallowReportInfo
+
+	for i, r := range neverallowRules(ctx.Config()) {
 		n := r.(*rule)
-		if !n.appliesToPath(dir) {
+		if !n.appliesToIncludedPath(dir) {
+			continue
+		}
+
+		exemptions := n.exemptionsForPath(dir)
+		if len(exemptions) > 0 && !report {
 			continue
 		}
 
@@ -301,8 +325,22 @@ func neverallowMutator(ctx BottomUpMutatorContext) {
 			continue
 		}
 
+		if len(exemptions) > 0 {
+			reportInfo.exempted = append(reportInfo.exempted, neverallowReportMatch{rule: i, exemptions: exemptions})
+			continue
+		}
+
+		if report {
+			reportInfo.violations = append(reportInfo.violations, neverallowReportMatch{rule: i})
+			continue
+		}
+
 		ctx.ModuleErrorf("violates " + n.String())
 	}
+
+	if report {
+		ctx.SetProvider(neverallowReportProvider, reportInfo)
+	}
 }
 
 type ValueMatcher interface {
@@ -566,10 +604,19 @@ func (r *rule) String() string {
 	return strings.Join(s, "\n\t")
 }
 
-func (r *rule) appliesToPath(dir string) bool {
-	includePath := len(r.paths) == 0 || HasAnyPrefix(dir, r.paths)
-	excludePath := HasAnyPrefix(dir, r.unlessPaths)
-	return includePath && !excludePath
+func (r *rule) appliesToIncludedPath(dir string) bool {
+	return len(r.paths) == 0 || HasAnyPrefix(dir, r.paths)
+}
+
+// exemptionsForPath returns the NotIn paths that exempt dir from this rule.
+func (r *rule) exemptionsForPath(dir string) []string {
+	var exemptions []string
+	for _, path := range r.unlessPaths {
+		if strings.HasPrefix(dir, path) {
+			exemptions = append(exemptions, path)
+		}
+	}
+	return exemptions
 }
 
 func (r *rule) appliesToDirectDeps(ctx BottomUpMutatorContext) bool {
@@ -666,28 +713,42 @@ func hasAllProperties(properties []interface{}, props []ruleProperty) bool {
 
 func hasProperty(properties []interface{}, prop ruleProperty) bool {
 	for _, propertyStruct := range properties {
-		propertiesValue := reflect.ValueOf(propertyStruct).Elem()
-		for _, v := range prop.fields {
-			if !propertiesValue.IsValid() {
-				break
-			}
-			propertiesValue = propertiesValue.FieldByName(v)
-		}
-		if !propertiesValue.IsValid() {
-			continue
-		}
-
 		check := func(value string) bool {
 			return prop.matcher.Test(value)
 		}
 
-		if matchValue(propertiesValue, check) {
-			return true
+		for _, propertiesValue := range propertyValues(reflect.ValueOf(propertyStruct).Elem(), prop.fields) {
+			if matchValue(propertiesValue, check) {
+				return true
+			}
 		}
 	}
 	return false
 }
 
+// propertyValues returns the values of the property with the given field names in a property
+// struct.  A list of property structs along the way, e.g. in "extern_rlibs.crate_name", returns
+// the value of the property in each of them.
+func propertyValues(v reflect.Value, fields []string) []reflect.Value {
+	if len(fields) == 0 {
+		return []reflect.Value{v}
+	}
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+		var values []reflect.Value
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, propertyValues(v.Index(i), fields)...)
+		}
+		return values
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	return propertyValues(v.FieldByName(fields[0]), fields[1:])
+}
+
 func matchValue(value reflect.Value, check func(string) bool) bool {
 	if !value.IsValid() {
 		return false
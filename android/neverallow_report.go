@@ -0,0 +1,172 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// The neverallow report lists, for every neverallow rule, the modules that violate it, the modules
+// that are only allowed because of one of its exemption (NotIn) paths, and the exemption paths that
+// no module uses any more and so can be removed.  It is written to out/soong/neverallow_report.json
+// when SOONG_NEVERALLOW_REPORT is set to true, in which case neverallow violations are recorded in
+// the report instead of failing the build.
+
+const NeverallowReportEnv = "SOONG_NEVERALLOW_REPORT"
+
+func init() {
+	RegisterNeverallowReportBuildComponents(InitRegistrationContext)
+}
+
+func RegisterNeverallowReportBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("neverallow_report", neverallowReportSingletonFactory)
+}
+
+func neverallowReportEnabled(config Config) bool {
+	return config.IsEnvTrue(NeverallowReportEnv)
+}
+
+// neverallowReportMatch is a rule, identified by its index in the neverallow rules, that matched a
+// module.
+type neverallowReportMatch struct {
+	rule       int
+	exemptions []string
+}
+
+type neverallowReportInfo struct {
+	violations []neverallowReportMatch
+	exempted   []neverallowReportMatch
+}
+
+var neverallowReportProvider = blueprint.NewMutatorProvider(neverallowReportInfo{}, "neverallow")
+
+type neverallowReportModule struct {
+	Name       string   `json:"name"`
+	Dir        string   `json:"dir"`
+	Type       string   `json:"type"`
+	Exemptions []string `json:"exemptions,omitempty"`
+}
+
+type neverallowReportTotals struct {
+	Violations       int `json:"violations"`
+	Exempted         int `json:"exempted"`
+	Exemptions       int `json:"exemptions"`
+	UnusedExemptions int `json:"unused_exemptions"`
+}
+
+type neverallowRuleReport struct {
+	Rule             string                   `json:"rule"`
+	Violations       []neverallowReportModule `json:"violations"`
+	Exempted         []neverallowReportModule `json:"exempted"`
+	UnusedExemptions []string                 `json:"unused_exemptions"`
+	Totals           neverallowReportTotals   `json:"totals"`
+}
+
+func neverallowReportSingletonFactory() Singleton {
+	return &neverallowReportSingleton{}
+}
+
+type neverallowReportSingleton struct{}
+
+func (s *neverallowReportSingleton) GenerateBuildActions(ctx SingletonContext) {
+	if !neverallowReportEnabled(ctx.Config()) {
+		return
+	}
+
+	rules := neverallowRules(ctx.Config())
+	reports := make([]neverallowRuleReport, len(rules))
+	usedExemptions := make([]map[string]bool, len(rules))
+	for i, r := range rules {
+		usedExemptions[i] = make(map[string]bool)
+		reports[i] = neverallowRuleReport{
+			Rule:             r.(*rule).String(),
+			Violations:       []neverallowReportModule{},
+			Exempted:         []neverallowReportModule{},
+			UnusedExemptions: []string{},
+		}
+	}
+
+	// Modules with multiple variants are only reported once per rule.
+	type seenKey struct {
+		rule      int
+		violation bool
+		dir, name string
+	}
+	seen := make(map[seenKey]bool)
+	record := func(list *[]neverallowReportModule, key seenKey, module neverallowReportModule) {
+		if !seen[key] {
+			seen[key] = true
+			*list = append(*list, module)
+		}
+	}
+
+	ctx.VisitAllModulesBlueprint(func(module blueprint.Module) {
+		if !ctx.ModuleHasProvider(module, neverallowReportProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, neverallowReportProvider).(neverallowReportInfo)
+		name, dir := ctx.ModuleName(module), ctx.ModuleDir(module)
+		newModule := func(exemptions []string) neverallowReportModule {
+			return neverallowReportModule{
+				Name:       name,
+				Dir:        dir,
+				Type:       ctx.ModuleType(module),
+				Exemptions: exemptions,
+			}
+		}
+		for _, match := range info.violations {
+			record(&reports[match.rule].Violations, seenKey{match.rule, true, dir, name}, newModule(nil))
+		}
+		for _, match := range info.exempted {
+			record(&reports[match.rule].Exempted, seenKey{match.rule, false, dir, name}, newModule(match.exemptions))
+			for _, exemption := range match.exemptions {
+				usedExemptions[match.rule][exemption] = true
+			}
+		}
+	})
+
+	for i, r := range rules {
+		report := &reports[i]
+		for _, exemption := range r.(*rule).unlessPaths {
+			if !usedExemptions[i][exemption] {
+				report.UnusedExemptions = append(report.UnusedExemptions, exemption)
+			}
+		}
+		for _, list := range [][]neverallowReportModule{report.Violations, report.Exempted} {
+			sort.Slice(list, func(i, j int) bool {
+				if list[i].Dir != list[j].Dir {
+					return list[i].Dir < list[j].Dir
+				}
+				return list[i].Name < list[j].Name
+			})
+		}
+		report.Totals = neverallowReportTotals{
+			Violations:       len(report.Violations),
+			Exempted:         len(report.Exempted),
+			Exemptions:       len(r.(*rule).unlessPaths),
+			UnusedExemptions: len(report.UnusedExemptions),
+		}
+	}
+
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal neverallow report: %s", err)
+		return
+	}
+	WriteFileRule(ctx, PathForOutput(ctx, "neverallow_report.json"), string(data))
+}
@@ -15,6 +15,7 @@
 package android
 
 import (
+	"encoding/json"
 	"regexp"
 	"testing"
 
@@ -508,3 +509,58 @@ func newMockMakefileGoalModule() Module {
 
 func (p *mockMakefileGoalModule) GenerateAndroidBuildActions(ModuleContext) {
 }
+
+func TestNeverallowReport(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForNeverAllowTest,
+		PrepareForTestWithNeverallowRules([]Rule{
+			NeverAllow().
+				ModuleType("cc_library").
+				With("vendor_available", "true").
+				NotIn("exempt", "unused_exempt").
+				Because("testing"),
+		}),
+		FixtureRegisterWithContext(RegisterNeverallowReportBuildComponents),
+		FixtureMergeEnv(map[string]string{
+			NeverallowReportEnv: "true",
+		}),
+		MockFS{
+			"exempt/Android.bp": []byte(`
+				cc_library {
+					name: "libexempt",
+					vendor_available: true,
+				}`),
+			"other/Android.bp": []byte(`
+				cc_library {
+					name: "libviolator",
+					vendor_available: true,
+				}
+
+				cc_library {
+					name: "liballowed",
+				}`),
+		}.AddToFixture(),
+	).RunTest(t)
+
+	output := result.SingletonForTests("neverallow_report").Output("neverallow_report.json")
+	var reports []neverallowRuleReport
+	if err := json.Unmarshal([]byte(ContentFromFileRuleForTests(t, output)), &reports); err != nil {
+		t.Fatalf("failed to parse neverallow report: %s", err)
+	}
+
+	AssertIntEquals(t, "number of rules", 1, len(reports))
+	report := reports[0]
+	AssertDeepEquals(t, "violations", []neverallowReportModule{
+		{Name: "libviolator", Dir: "other", Type: "cc_library"},
+	}, report.Violations)
+	AssertDeepEquals(t, "exempted", []neverallowReportModule{
+		{Name: "libexempt", Dir: "exempt", Type: "cc_library", Exemptions: []string{"exempt/"}},
+	}, report.Exempted)
+	AssertDeepEquals(t, "unused exemptions", []string{"unused_exempt/"}, report.UnusedExemptions)
+	AssertDeepEquals(t, "totals", neverallowReportTotals{
+		Violations:       1,
+		Exempted:         1,
+		Exemptions:       2,
+		UnusedExemptions: 1,
+	}, report.Totals)
+}
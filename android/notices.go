@@ -46,10 +46,11 @@ func modulesLicenseMetadata(ctx BuilderContext, modules ...Module) Paths {
 	return result
 }
 
-// buildNoticeOutputFromLicenseMetadata writes out a notice file.
+// buildNoticeOutputFromLicenseMetadata writes out a notice file, with the checks of the notice file
+// as validations.
 func buildNoticeOutputFromLicenseMetadata(
 	ctx BuilderContext, tool, ruleName string, outputFile WritablePath,
-	libraryName string, stripPrefix []string, modules ...Module) {
+	libraryName string, stripPrefix []string, validations Paths, modules ...Module) {
 	depsFile := outputFile.ReplaceExtension(ctx, strings.TrimPrefix(outputFile.Ext()+".d", "."))
 	rule := NewRuleBuilder(pctx, ctx)
 	if len(modules) == 0 {
@@ -77,6 +78,7 @@ func buildNoticeOutputFromLicenseMetadata(
 		cmd = cmd.FlagWithArg("--product ", libraryName)
 	}
 	cmd = cmd.Inputs(modulesLicenseMetadata(ctx, modules...))
+	cmd = cmd.Validations(validations)
 	rule.Build(ruleName, "container notice file")
 }
 
@@ -87,7 +89,7 @@ func BuildNoticeTextOutputFromLicenseMetadata(
 	ctx BuilderContext, outputFile WritablePath, ruleName, libraryName string,
 	stripPrefix []string, modules ...Module) {
 	buildNoticeOutputFromLicenseMetadata(ctx, "textnotice", "text_notice_"+ruleName,
-		outputFile, libraryName, stripPrefix, modules...)
+		outputFile, libraryName, stripPrefix, nil, modules...)
 }
 
 // BuildNoticeHtmlOutputFromLicenseMetadata writes out a notice text file based
@@ -97,7 +99,7 @@ func BuildNoticeHtmlOutputFromLicenseMetadata(
 	ctx BuilderContext, outputFile WritablePath, ruleName, libraryName string,
 	stripPrefix []string, modules ...Module) {
 	buildNoticeOutputFromLicenseMetadata(ctx, "htmlnotice", "html_notice_"+ruleName,
-		outputFile, libraryName, stripPrefix, modules...)
+		outputFile, libraryName, stripPrefix, nil, modules...)
 }
 
 // BuildNoticeXmlOutputFromLicenseMetadata writes out a notice text file based
@@ -107,5 +109,5 @@ func BuildNoticeXmlOutputFromLicenseMetadata(
 	ctx BuilderContext, outputFile WritablePath, ruleName, libraryName string,
 	stripPrefix []string, modules ...Module) {
 	buildNoticeOutputFromLicenseMetadata(ctx, "xmlnotice", "xml_notice_"+ruleName,
-		outputFile, libraryName, stripPrefix, modules...)
+		outputFile, libraryName, stripPrefix, nil, modules...)
 }
@@ -253,6 +253,12 @@ type overrideBaseDependencyTag struct {
 
 var overrideBaseDepTag overrideBaseDependencyTag
 
+func (overrideBaseDependencyTag) DependencyProperty() string {
+	return "base"
+}
+
+var _ PropertyDependencyTag = overrideBaseDepTag
+
 // Adds dependency on the base module to the overriding module so that they can be visited in the
 // next phase.
 func overrideModuleDepsMutator(ctx BottomUpMutatorContext) {
@@ -38,6 +38,10 @@ type packageProperties struct {
 	Default_visibility []string
 	// Specifies the default license terms for all modules defined in this package.
 	Default_applicable_licenses []string
+	// Specifies the packages whose modules may use the files of this package directly in their
+	// srcs, e.g. "//frameworks/base" for a package below frameworks/base.  A package grants access to
+	// its subpackages too.  Other modules have to use a filegroup defined in this package.
+	Allow_external_srcs_from []string
 }
 
 type bazelPackageAttributes struct {
@@ -109,6 +113,7 @@ func PackageFactory() Module {
 	// which is in a LoadHook.
 	AddLoadHook(module, func(ctx LoadHookContext) {
 		module.nameProperties.Name = proptools.StringPtr("//" + ctx.ModuleDir())
+		registerPackageSrcsGrants(ctx, module.properties.Allow_external_srcs_from)
 	})
 
 	// The default_visibility property needs to be checked and parsed by the visibility module during
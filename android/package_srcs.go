@@ -0,0 +1,116 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/blueprint/pathtools"
+)
+
+// The sources of a module must belong to its own package.  Paths that leave the module directory
+// with ".." are always rejected by validatePath, but a path can also reach into a subdirectory that
+// has an Android.bp file of its own and so is a different package, which breaks the ownership,
+// visibility and license attribution of the files.  This applies to every path resolved relative to
+// the module directory, i.e. to directories like local_include_dirs and export_include_dirs as well
+// as to srcs.  Such paths are reported unless the package
+// that contains the files lists the package of the module in its allow_external_srcs_from
+// property, or the module directory is in the SubpackageSrcsAllowedPaths product variable.  The
+// sanctioned way of using the files of another package is a filegroup defined in that package.
+
+var packageSrcsGrantsKey = NewOnceKey("packageSrcsGrants")
+
+// packageSrcsGrants maps the directory of each package module to its allow_external_srcs_from
+// property.
+func packageSrcsGrants(config Config) *sync.Map {
+	return config.Once(packageSrcsGrantsKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+var packageDirsKey = NewOnceKey("packageDirs")
+
+// packageDirs caches whether each directory that was checked contains an Android.bp file.
+func packageDirs(config Config) *sync.Map {
+	return config.Once(packageDirsKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+// registerPackageSrcsGrants records the allow_external_srcs_from property of a package module.  It
+// is called from a load hook so all the grants are known before any module resolves its sources.
+func registerPackageSrcsGrants(ctx LoadHookContext, grants []string) {
+	for _, grant := range grants {
+		if !strings.HasPrefix(grant, "//") {
+			ctx.PropertyErrorf("allow_external_srcs_from", "%q is not a package, expected //<path>", grant)
+		}
+	}
+	packageSrcsGrants(ctx.Config()).Store(ctx.ModuleDir(), grants)
+}
+
+// isPackageDir returns true if dir contains an Android.bp file.
+func isPackageDir(config Config, dir string) bool {
+	if v, ok := packageDirs(config).Load(dir); ok {
+		return v.(bool)
+	}
+	exists, _, _ := config.fs.Exists(filepath.Join(dir, "Android.bp"))
+	packageDirs(config).Store(dir, exists)
+	return exists
+}
+
+// packageSrcsGranted returns true if the package in pkgDir allows modules in moduleDir to use its
+// files.
+func packageSrcsGranted(config Config, pkgDir, moduleDir string) bool {
+	v, ok := packageSrcsGrants(config).Load(pkgDir)
+	if !ok {
+		return false
+	}
+	for _, grant := range v.([]string) {
+		grantDir := strings.TrimPrefix(grant, "//")
+		if moduleDir == grantDir || strings.HasPrefix(moduleDir, grantDir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSrcInPackage reports an error if the source path, relative to the module directory, is in
+// a subdirectory that belongs to another package.  Only the directories before the first glob
+// component of the path are checked.
+func checkSrcInPackage(ctx EarlyModulePathContext, path string) {
+	moduleDir := ctx.ModuleDir()
+	if ctx.Config().SubpackageSrcsAllowedForPath(moduleDir) {
+		return
+	}
+
+	dir := moduleDir
+	for _, component := range strings.Split(filepath.Clean(path), "/") {
+		if component == "." || component == ".." || pathtools.IsGlob(component) {
+			return
+		}
+		dir = filepath.Join(dir, component)
+		if !isPackageDir(ctx.Config(), dir) {
+			continue
+		}
+		if !packageSrcsGranted(ctx.Config(), dir, moduleDir) {
+			ReportPathErrorf(ctx, "%q is in the package %q, not in the package of the module; use a "+
+				"filegroup defined in %q instead, or add %q to allow_external_srcs_from of its package module",
+				path, dir, dir, "//"+moduleDir)
+		}
+		return
+	}
+}
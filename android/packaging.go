@@ -17,8 +17,12 @@ package android
 import (
 	"fmt"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 // PackagingSpec abstracts a request to place a built artifact at a certain path in a package. A
@@ -126,6 +130,11 @@ type PackagingProperties struct {
 	Deps     []string                    `android:"arch_variant"`
 	Multilib packagingMultilibProperties `android:"arch_variant"`
 	Arch     packagingArchProperties
+
+	// If set to true, the modules listed in the required and target_required properties of the
+	// device modules in deps are packaged as well, transitively, the way Make installs them.
+	// Required modules that don't have a device variant are skipped.
+	Include_required_deps *bool
 }
 
 func InitPackageModule(p PackageModule) {
@@ -218,13 +227,126 @@ func (p *PackagingBase) AddDeps(ctx BottomUpMutatorContext, depTag blueprint.Dep
 			ctx.AddFarVariationDependencies(t.Variations(), depTag, dep)
 		}
 	}
+	if proptools.Bool(p.properties.Include_required_deps) {
+		p.addRequiredDeps(ctx)
+	}
+}
+
+// packagingRequiredDepTag is the dependency tag of the modules that are packaged because a
+// packaged module requires them.
+type packagingRequiredDepTag struct {
+	blueprint.BaseDependencyTag
+	PackagingItemAlwaysDepTag
+
+	// The name of the module whose required or target_required property lists the dependency.
+	requiredBy string
+}
+
+// addRequiredDeps adds dependencies on the modules that the modules in deps require, transitively.
+// The required properties are allowed to form cycles, so the modules are visited at most once.
+func (p *PackagingBase) addRequiredDeps(ctx BottomUpMutatorContext) {
+	type requiredModule struct {
+		name   string
+		target Target
+	}
+	visited := make(map[string]bool)
+	var queue []requiredModule
+	for _, t := range p.getSupportedTargets(ctx) {
+		for _, dep := range p.getDepsForArch(ctx, t.Arch.ArchType) {
+			visited[packagingRequiredDepsKey(dep, t)] = true
+			queue = append(queue, requiredModule{dep, t})
+		}
+	}
+
+	requiredDepsMap := packagingRequiredDepsMap(ctx.Config())
+	for len(queue) > 0 {
+		module := queue[0]
+		queue = queue[1:]
+		required, ok := requiredDepsMap.Load(packagingRequiredDepsKey(module.name, module.target))
+		if !ok {
+			continue
+		}
+		for _, dep := range required.([]string) {
+			if dep == ctx.ModuleName() {
+				continue
+			}
+			target, ok := p.requiredDepTarget(ctx, dep, module.target)
+			if !ok || visited[packagingRequiredDepsKey(dep, target)] {
+				continue
+			}
+			visited[packagingRequiredDepsKey(dep, target)] = true
+			ctx.AddFarVariationDependencies(target.Variations(), packagingRequiredDepTag{requiredBy: module.name}, dep)
+			queue = append(queue, requiredModule{dep, target})
+		}
+	}
+}
+
+// requiredDepTarget returns the target of the variant of the required module dep that is packaged
+// for a module of the given target: the same target if the module has it, or else the common
+// architecture of the same OS.
+func (p *PackagingBase) requiredDepTarget(ctx BottomUpMutatorContext, dep string, target Target) (Target, bool) {
+	if !ctx.OtherModuleExists(dep) {
+		// Let the missing dependency be reported like any other.
+		return target, !p.IgnoreMissingDependencies
+	}
+	if ctx.OtherModuleFarDependencyVariantExists(target.Variations(), dep) {
+		return target, true
+	}
+	common := Target{Os: target.Os, Arch: Arch{ArchType: Common}}
+	if ctx.OtherModuleFarDependencyVariantExists(common.Variations(), dep) {
+		return common, true
+	}
+	return Target{}, false
+}
+
+var packagingRequiredDepsMapKey = NewOnceKey("packagingRequiredDepsMap")
+
+// The map from the name and target of a device module to the modules in its required and
+// target_required properties.  The modules in host_required are installed on the host, so they
+// are never packaged.
+func packagingRequiredDepsMap(config Config) *sync.Map {
+	return config.Once(packagingRequiredDepsMapKey, func() interface{} {
+		return &sync.Map{}
+	}).(*sync.Map)
+}
+
+func packagingRequiredDepsKey(name string, target Target) string {
+	return name + "#" + target.String()
+}
+
+// Registers the function that gathers the required modules of each device module, which packaging
+// modules with include_required_deps follow in their deps mutator.
+//
+// The required properties are arch specific so this must be registered after the arch mutator.
+func RegisterPackagingRequiredDepsGatherer(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("packaging_required_deps_gatherer", packagingRequiredDepsGatherer).Parallel()
+}
+
+var PrepareForTestWithPackagingRequiredDeps = FixtureRegisterWithContext(func(ctx RegistrationContext) {
+	ctx.PreDepsMutators(RegisterPackagingRequiredDepsGatherer)
+})
+
+func packagingRequiredDepsGatherer(ctx BottomUpMutatorContext) {
+	m, ok := ctx.Module().(Module)
+	if !ok || !ctx.Device() {
+		return
+	}
+	// Packaging modules depend on the core variant of their deps.
+	if m.base().commonProperties.ImageVariation != CoreVariation {
+		return
+	}
+	required := append(CopyOf(m.RequiredModuleNames()), m.TargetRequiredModuleNames()...)
+	if len(required) == 0 {
+		return
+	}
+	packagingRequiredDepsMap(ctx.Config()).Store(packagingRequiredDepsKey(ctx.ModuleName(), ctx.Target()), FirstUniqueStrings(required))
 }
 
 // See PackageModule.GatherPackagingSpecs
 func (p *PackagingBase) GatherPackagingSpecs(ctx ModuleContext) map[string]PackagingSpec {
 	m := make(map[string]PackagingSpec)
 	ctx.VisitDirectDeps(func(child Module) {
-		if pi, ok := ctx.OtherModuleDependencyTag(child).(PackagingItem); !ok || !pi.IsPackagingItem() {
+		if !ctx.OtherModuleDependencyTagCapabilities(child).PackagingItem {
 			return
 		}
 		for _, ps := range child.TransitivePackagingSpecs() {
@@ -236,6 +358,46 @@ func (p *PackagingBase) GatherPackagingSpecs(ctx ModuleContext) map[string]Packa
 	return m
 }
 
+// WriteRequiredDepsReport writes out/soong/.intermediates/<module>/required_deps.txt, which lists
+// the entries of specs that are only packaged because of include_required_deps, along with the
+// module that required each of them.  specs is the result of GatherPackagingSpecs after any
+// filtering by the packaging module.
+func (p *PackagingBase) WriteRequiredDepsReport(ctx ModuleContext, specs map[string]PackagingSpec) {
+	if !proptools.Bool(p.properties.Include_required_deps) {
+		return
+	}
+	hardDeps := make(map[string]bool)
+	ctx.VisitDirectDeps(func(child Module) {
+		if _, ok := ctx.OtherModuleDependencyTag(child).(packagingRequiredDepTag); ok {
+			return
+		}
+		if !ctx.OtherModuleDependencyTagCapabilities(child).PackagingItem {
+			return
+		}
+		for _, ps := range child.TransitivePackagingSpecs() {
+			hardDeps[ps.relPathInPackage] = true
+		}
+	})
+
+	reported := make(map[string]bool)
+	var lines []string
+	ctx.VisitDirectDeps(func(child Module) {
+		tag, ok := ctx.OtherModuleDependencyTag(child).(packagingRequiredDepTag)
+		if !ok {
+			return
+		}
+		for _, ps := range child.TransitivePackagingSpecs() {
+			if _, ok := specs[ps.relPathInPackage]; !ok || hardDeps[ps.relPathInPackage] || reported[ps.relPathInPackage] {
+				continue
+			}
+			reported[ps.relPathInPackage] = true
+			lines = append(lines, fmt.Sprintf("%s: %s required by %s", ps.relPathInPackage, ctx.OtherModuleName(child), tag.requiredBy))
+		}
+	})
+	sort.Strings(lines)
+	WriteFileRule(ctx, PathForModuleOut(ctx, "required_deps.txt"), strings.Join(lines, "\n"))
+}
+
 // CopySpecsToDir is a helper that will add commands to the rule builder to copy the PackagingSpec
 // entries into the specified directory.
 func (p *PackagingBase) CopySpecsToDir(ctx ModuleContext, builder *RuleBuilder, specs map[string]PackagingSpec, dir WritablePath) (entries []string) {
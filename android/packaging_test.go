@@ -63,6 +63,11 @@ type packageTestModule struct {
 	PackagingBase
 	properties struct {
 		Install_deps []string `android:`
+
+		// Deps added with a tag that implements PackagingItem itself rather than by embedding
+		// PackagingItemAlwaysDepTag, packaged or not depending on the tag.
+		Custom_packaged_deps   []string
+		Custom_unpackaged_deps []string
 	}
 	entries []string
 }
@@ -88,9 +93,22 @@ type packagingDepTag struct {
 	PackagingItemAlwaysDepTag
 }
 
+// customPackagingDepTag is a dep tag that decides whether the dependency is packaged, as a tag
+// defined in another package would.
+type customPackagingDepTag struct {
+	blueprint.BaseDependencyTag
+	packaged bool
+}
+
+func (t customPackagingDepTag) IsPackagingItem() bool {
+	return t.packaged
+}
+
 func (m *packageTestModule) DepsMutator(ctx BottomUpMutatorContext) {
 	m.AddDeps(ctx, packagingDepTag{})
 	ctx.AddDependency(ctx.Module(), installDepTag{}, m.properties.Install_deps...)
+	ctx.AddDependency(ctx.Module(), customPackagingDepTag{packaged: true}, m.properties.Custom_packaged_deps...)
+	ctx.AddDependency(ctx.Module(), customPackagingDepTag{packaged: false}, m.properties.Custom_unpackaged_deps...)
 }
 
 func (m *packageTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
@@ -398,3 +416,28 @@ func TestPackagingWithSkipInstallDeps(t *testing.T) {
 		}
 		`, []string{"lib64/foo", "lib64/bar", "lib64/baz"})
 }
+
+func TestPackagingWithCustomDepTag(t *testing.T) {
+	multiTarget := false
+	runPackagingTest(t, multiTarget,
+		`
+		component {
+			name: "foo",
+			deps: ["bar"],
+		}
+
+		component {
+			name: "bar",
+		}
+
+		component {
+			name: "baz",
+		}
+
+		package_module {
+			name: "package",
+			custom_packaged_deps: ["foo"],
+			custom_unpackaged_deps: ["baz"],
+		}
+		`, []string{"lib64/foo", "lib64/bar"})
+}
@@ -0,0 +1,356 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// A partial ninja file only contains the build statements needed to build a few modules, which
+// makes it much faster for ninja to load when iterating on those modules.  When
+// SOONG_PARTIAL_NINJA_TARGETS is set to a space separated list of module names soong_build writes
+// out/soong/partial.ninja instead of the full ninja file.  It contains every rule, pool and
+// variable of the full file, but only the build statements that the phony targets of the named
+// modules depend on, transitively through their inputs, implicit inputs, order-only inputs and
+// validations.
+//
+// The dependencies between build statements are read from the full ninja file as blueprint writes
+// it, so they include the build statements that were not generated through Soong's contexts, like
+// the ones blueprint's bootstrap generates for the Go tools.  The full ninja file is written twice
+// without being stored: once to find the needed build statements, and once to write them out.
+
+const PartialNinjaTargetsEnv = "SOONG_PARTIAL_NINJA_TARGETS"
+
+// partialNinjaGraph maps the outputs of the build statements of a ninja file to the outputs of
+// their build statement and to the paths they depend on.
+type partialNinjaGraph struct {
+	outputs map[string][]string
+	inputs  map[string][]string
+}
+
+func newPartialNinjaGraph() *partialNinjaGraph {
+	return &partialNinjaGraph{
+		outputs: make(map[string][]string),
+		inputs:  make(map[string][]string),
+	}
+}
+
+// addDecl records the dependencies of a declaration of a ninja file if it is a build statement.
+func (g *partialNinjaGraph) addDecl(decl string) error {
+	if !strings.HasPrefix(decl, "build ") {
+		return nil
+	}
+	outputs, inputs := parseNinjaBuild(strings.TrimPrefix(decl, "build "))
+	for _, output := range outputs {
+		g.outputs[output] = outputs
+		g.inputs[output] = inputs
+	}
+	return nil
+}
+
+// neededOutputs returns the outputs of all the build statements needed to build targets, which
+// are either outputs of build statements or phony targets that were not written to the ninja
+// file, which happens when they are exported to Make.
+func (g *partialNinjaGraph) neededOutputs(phonies phonyMap, targets []string) (map[string]bool, error) {
+	needed := make(map[string]bool)
+	var queue []string
+	for _, target := range targets {
+		if _, ok := g.outputs[target]; ok {
+			queue = append(queue, target)
+		} else if deps, ok := phonies[target]; ok {
+			queue = append(queue, deps.Strings()...)
+		} else {
+			return nil, fmt.Errorf("%s: unknown target %q", PartialNinjaTargetsEnv, target)
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0]
+		queue = queue[1:]
+		// Paths that are not produced by any build statement are source files.
+		if outputs, ok := g.outputs[path]; ok && !needed[path] {
+			for _, output := range outputs {
+				needed[output] = true
+			}
+			queue = append(queue, g.inputs[path]...)
+		}
+	}
+	return needed, nil
+}
+
+// WritePartialNinjaFile writes the build statements needed to build targets to partialFile.
+// writeNinja writes the full ninja file, usually with blueprint.Context.WriteBuildFile, and must
+// only be called after the build actions of all modules and singletons have been generated.  It is
+// called twice.
+func WritePartialNinjaFile(config Config, partialFile string, targets []string,
+	writeNinja func(w blueprint.StringWriterWriter) error) error {
+
+	graph := newPartialNinjaGraph()
+	reader := newNinjaDeclWriter(graph.addDecl)
+	if err := writeNinja(reader); err != nil {
+		return err
+	}
+	if err := reader.close(); err != nil {
+		return err
+	}
+
+	phonies := getPhonyMap(config)
+	needed, err := graph.neededOutputs(phonies, targets)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(partialFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := bufio.NewWriter(f)
+
+	w := newPartialNinjaWriter(buf, graph, needed)
+	if err := writeNinja(w); err != nil {
+		return err
+	}
+	if err := w.finish(phonies, targets); err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// ninjaDeclWriter splits a ninja file into its declarations as it is written, and passes each
+// complete declaration, including its variable bindings, to handle.
+type ninjaDeclWriter struct {
+	handle func(decl string) error
+
+	// The incomplete last line written so far.
+	line strings.Builder
+	// The declaration the last complete line belongs to.
+	decl strings.Builder
+	// Whether the last complete line ended with a line continuation.
+	continued bool
+}
+
+var _ blueprint.StringWriterWriter = (*ninjaDeclWriter)(nil)
+
+func newNinjaDeclWriter(handle func(decl string) error) *ninjaDeclWriter {
+	return &ninjaDeclWriter{handle: handle}
+}
+
+func (d *ninjaDeclWriter) Write(b []byte) (int, error) {
+	return d.WriteString(string(b))
+}
+
+func (d *ninjaDeclWriter) WriteString(s string) (int, error) {
+	n := len(s)
+	for {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			d.line.WriteString(s)
+			return n, nil
+		}
+		d.line.WriteString(s[:i+1])
+		s = s[i+1:]
+		line := d.line.String()
+		d.line.Reset()
+		if err := d.addLine(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// addLine adds a complete line to the current declaration, or starts a new declaration after
+// handling the current one.  Indented lines after a declaration are its variable bindings.
+func (d *ninjaDeclWriter) addLine(line string) error {
+	indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+	if !d.continued && !(indented && strings.TrimSpace(line) != "" && d.decl.Len() > 0) {
+		if err := d.flushDecl(); err != nil {
+			return err
+		}
+	}
+	d.decl.WriteString(line)
+
+	trimmed := strings.TrimRight(line, "\r\n")
+	dollars := len(trimmed) - len(strings.TrimRight(trimmed, "$"))
+	d.continued = dollars%2 == 1
+	return nil
+}
+
+func (d *ninjaDeclWriter) flushDecl() error {
+	decl := d.decl.String()
+	d.decl.Reset()
+	if decl == "" {
+		return nil
+	}
+	return d.handle(decl)
+}
+
+// close handles the last declaration, which may not end with a newline.
+func (d *ninjaDeclWriter) close() error {
+	if d.line.Len() > 0 {
+		if err := d.addLine(d.line.String()); err != nil {
+			return err
+		}
+		d.line.Reset()
+	}
+	return d.flushDecl()
+}
+
+// partialNinjaWriter filters the declarations of a ninja file as it is written, dropping the build
+// statements that are not needed and the default targets.
+type partialNinjaWriter struct {
+	*ninjaDeclWriter
+
+	w      *bufio.Writer
+	graph  *partialNinjaGraph
+	needed map[string]bool
+}
+
+func newPartialNinjaWriter(w *bufio.Writer, graph *partialNinjaGraph, needed map[string]bool) *partialNinjaWriter {
+	p := &partialNinjaWriter{w: w, graph: graph, needed: needed}
+	p.ninjaDeclWriter = newNinjaDeclWriter(p.writeDecl)
+	return p
+}
+
+// writeDecl writes out a declaration unless it is dropped from the partial ninja file.
+func (p *partialNinjaWriter) writeDecl(decl string) error {
+	// The default targets of the full file are replaced with the requested targets.
+	if strings.HasPrefix(decl, "default ") {
+		return nil
+	}
+	if strings.HasPrefix(decl, "build ") && !p.neededBuild(strings.TrimPrefix(decl, "build ")) {
+		return nil
+	}
+	_, err := p.w.WriteString(decl)
+	return err
+}
+
+// neededBuild returns whether any output of a build statement is needed, given the part of the
+// statement after "build ".
+func (p *partialNinjaWriter) neededBuild(statement string) bool {
+	outputs, _ := parseNinjaBuild(statement)
+	for _, output := range outputs {
+		if p.needed[output] {
+			return true
+		}
+	}
+	return false
+}
+
+// finish writes out the last declaration followed by phony build statements for the targets that
+// are not built by a statement of the full file and the default statement.
+func (p *partialNinjaWriter) finish(phonies phonyMap, targets []string) error {
+	if err := p.close(); err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		if _, ok := p.graph.outputs[target]; ok {
+			continue
+		}
+		if deps, ok := phonies[target]; ok {
+			fmt.Fprintf(p.w, "\nbuild %s: phony", escapeNinjaPath(target))
+			for _, dep := range deps {
+				fmt.Fprintf(p.w, " %s", escapeNinjaPath(dep.String()))
+			}
+			fmt.Fprintln(p.w)
+		}
+	}
+	fmt.Fprintf(p.w, "\ndefault")
+	for _, target := range targets {
+		fmt.Fprintf(p.w, " %s", escapeNinjaPath(target))
+	}
+	_, err := fmt.Fprintln(p.w)
+	return err
+}
+
+// parseNinjaBuild returns the unescaped outputs of a build statement, including its implicit
+// outputs, and its inputs, including its implicit and order-only inputs and its validations, given
+// the part of the statement after "build ".
+func parseNinjaBuild(statement string) (outputs, inputs []string) {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+scan:
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case c == '$' && i+1 < len(statement) && (statement[i+1] == '\n' || statement[i+1] == '\r'):
+			// A line continuation, skip the newline and the indentation of the next line.
+			for i+1 < len(statement) && strings.IndexByte("\r\n \t", statement[i+1]) >= 0 {
+				i++
+			}
+			flush()
+		case c == '$' && i+1 < len(statement):
+			current.WriteByte(c)
+			current.WriteByte(statement[i+1])
+			i++
+		case c == '\n':
+			// The variable bindings of the statement follow the first line.
+			break scan
+		case c == ' ' || c == '\t' || c == '\r':
+			flush()
+		case c == ':':
+			// The unescaped colon separates the outputs from the rule.
+			flush()
+			tokens = append(tokens, ":")
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+
+	afterColon, afterRule := false, false
+	for _, token := range tokens {
+		switch {
+		case token == ":" && !afterColon:
+			afterColon = true
+		case token == "|" || token == "||" || token == "|@":
+			// The implicit outputs, implicit inputs, order-only inputs and validations follow
+			// separators.
+		case !afterColon:
+			outputs = append(outputs, unescapeNinjaPath(token))
+		case !afterRule:
+			afterRule = true
+		default:
+			inputs = append(inputs, unescapeNinjaPath(token))
+		}
+	}
+	return outputs, inputs
+}
+
+var ninjaPathEscaper = strings.NewReplacer("$", "$$", " ", "$ ", ":", "$:")
+var ninjaPathUnescaper = strings.NewReplacer("$$", "$", "$ ", " ", "$:", ":")
+
+// escapeNinjaPath escapes a path the way it appears in a ninja build statement.
+func escapeNinjaPath(path string) string {
+	return ninjaPathEscaper.Replace(path)
+}
+
+// unescapeNinjaPath reverses escapeNinjaPath.
+func unescapeNinjaPath(path string) string {
+	return ninjaPathUnescaper.Replace(path)
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const partialNinjaTestFile = `ninja_required_version = 1.7.0
+
+g.cc = clang
+
+rule cc
+    command = ${g.cc} -c $in -o $out
+
+rule link
+    command = ${g.cc} $in -o $out
+
+build out/foo.o: cc foo.c | out/gen/foo.h || out/gen_done
+    description = compile foo
+
+build out/gen/foo.h | out/gen/foo.d: cc foo.h.in
+
+build out/foo$ bin: link out/foo.o $
+        out/libbase.a || out/host/bin/soong_zip |@ out/foo.lint
+
+build out/host/bin/soong_zip: g.bootstrap.link out/soong_zip.a
+
+build out/bar: link bar.c
+
+build out/unused: link unused.c
+
+build bar: phony out/bar
+
+default foo bar
+`
+
+// partialNinjaTestGraph returns the graph of partialNinjaTestFile.
+func partialNinjaTestGraph(t *testing.T) *partialNinjaGraph {
+	graph := newPartialNinjaGraph()
+	w := newNinjaDeclWriter(graph.addDecl)
+	if _, err := w.WriteString(partialNinjaTestFile); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return graph
+}
+
+func TestPartialNinjaNeededOutputs(t *testing.T) {
+	graph := partialNinjaTestGraph(t)
+	phonies := phonyMap{"foo": Paths{PathForTesting("out/foo bin")}}
+
+	needed, err := graph.neededOutputs(phonies, []string{"foo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertArrayString(t, "needed outputs", []string{
+		"out/foo bin",
+		"out/foo.o",
+		"out/gen/foo.d",
+		"out/gen/foo.h",
+		"out/host/bin/soong_zip",
+	}, SortedKeys(needed))
+
+	needed, err = graph.neededOutputs(phonies, []string{"bar"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssertArrayString(t, "needed outputs", []string{"bar", "out/bar"}, SortedKeys(needed))
+
+	_, err = graph.neededOutputs(phonies, []string{"baz"})
+	AssertErrorMessageEquals(t, "error", `SOONG_PARTIAL_NINJA_TARGETS: unknown target "baz"`, err)
+}
+
+func TestParseNinjaBuild(t *testing.T) {
+	outputs, inputs := parseNinjaBuild("out/a$ b | out/c$:d: cc in$$1 $\n        in2 | imp || order |@ valid\n    description = x: y\n")
+	AssertArrayString(t, "outputs", []string{"out/a b", "out/c:d"}, outputs)
+	AssertArrayString(t, "inputs", []string{"in$1", "in2", "imp", "order", "valid"}, inputs)
+}
+
+func TestPartialNinjaWriter(t *testing.T) {
+	sb := &strings.Builder{}
+	buf := bufio.NewWriter(sb)
+	w := newPartialNinjaWriter(buf, partialNinjaTestGraph(t), map[string]bool{
+		"out/foo.o":              true,
+		"out/gen/foo.d":          true,
+		"out/foo bin":            true,
+		"out/host/bin/soong_zip": true,
+		"bar":                    true,
+		"out/bar":                true,
+	})
+
+	// Write the ninja file in pieces that do not line up with its lines.
+	for i := 0; i < len(partialNinjaTestFile); i += 7 {
+		end := i + 7
+		if end > len(partialNinjaTestFile) {
+			end = len(partialNinjaTestFile)
+		}
+		if _, err := w.WriteString(partialNinjaTestFile[i:end]); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	phonies := phonyMap{"foo": Paths{PathForTesting("out/foo bin")}}
+	if err := w.finish(phonies, []string{"bar", "foo"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	buf.Flush()
+	partial := sb.String()
+
+	for _, expected := range []string{
+		"ninja_required_version = 1.7.0\n",
+		"g.cc = clang\n",
+		"rule cc\n    command = ${g.cc} -c $in -o $out\n",
+		"build out/foo.o: cc foo.c | out/gen/foo.h || out/gen_done\n    description = compile foo\n",
+		"build out/gen/foo.h | out/gen/foo.d: cc foo.h.in\n",
+		"build out/foo$ bin: link out/foo.o $\n        out/libbase.a || out/host/bin/soong_zip |@ out/foo.lint\n",
+		"build out/host/bin/soong_zip: g.bootstrap.link out/soong_zip.a\n",
+		"build out/bar: link bar.c\n",
+		"build bar: phony out/bar\n",
+		"\nbuild foo: phony out/foo$ bin\n",
+		"\ndefault bar foo\n",
+	} {
+		AssertStringDoesContain(t, "partial ninja file", partial, expected)
+	}
+
+	for _, unexpected := range []string{
+		"build out/unused:",
+		"default foo bar",
+	} {
+		AssertStringDoesNotContain(t, "partial ninja file", partial, unexpected)
+	}
+
+	// bar is built by a phony statement of the full file, which must not be duplicated.
+	AssertIntEquals(t, "phony statements for bar", 1, strings.Count(partial, "build bar:"))
+}
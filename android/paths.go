@@ -659,6 +659,7 @@ func expandOneSrcPath(input sourcePathInput) (Paths, error) {
 		}
 	} else {
 		p := pathForModuleSrc(input.context, input.path)
+		checkSrcInPackage(input.context, input.path)
 		if pathtools.IsGlob(input.path) {
 			paths := GlobFiles(input.context, p.String(), input.expandedExcludes)
 			return PathsWithModuleSrcSubDir(input.context, paths, ""), nil
@@ -666,7 +667,7 @@ func expandOneSrcPath(input sourcePathInput) (Paths, error) {
 			if exists, _, err := input.context.Config().fs.Exists(p.String()); err != nil {
 				ReportPathErrorf(input.context, "%s: %s", p, err.Error())
 			} else if !exists && !input.context.Config().TestAllowNonExistentPaths {
-				ReportPathErrorf(input.context, "module source path %q does not exist", p)
+				ReportPathErrorf(input.context, "module source path %q %s", p, missingPathReason(input.context, p.String()))
 			} else if !input.includeDirs {
 				if isDir, err := input.context.Config().fs.IsDir(p.String()); exists && err != nil {
 					ReportPathErrorf(input.context, "%s: %s", p, err.Error())
@@ -1122,6 +1123,18 @@ func existsWithDependencies(ctx PathGlobContext, path SourcePath) (exists bool,
 	return len(files) > 0, nil
 }
 
+// missingPathReason describes why a path that does not exist is missing, which is either because it
+// does not exist at all or because it is a symlink to a target that does not exist.
+func missingPathReason(ctx PathContext, path string) string {
+	fs := ctx.Config().fs
+	if isSymlink, err := fs.IsSymlink(path); err == nil && isSymlink {
+		if target, err := fs.Readlink(path); err == nil {
+			return fmt.Sprintf("is a dangling symlink to %q", target)
+		}
+	}
+	return "does not exist"
+}
+
 // PathForSource joins the provided path components and validates that the result
 // neither escapes the source dir nor is in the out dir.
 // On error, it will return a usable, but invalid SourcePath, and report a ModuleError.
@@ -1146,7 +1159,7 @@ func PathForSource(ctx PathContext, pathComponents ...string) SourcePath {
 	} else if exists, _, err := ctx.Config().fs.Exists(path.String()); err != nil {
 		ReportPathErrorf(ctx, "%s: %s", path, err.Error())
 	} else if !exists && !ctx.Config().TestAllowNonExistentPaths {
-		ReportPathErrorf(ctx, "source path %q does not exist", path)
+		ReportPathErrorf(ctx, "source path %q %s", path, missingPathReason(ctx, path.String()))
 	}
 	return path
 }
@@ -1171,7 +1184,7 @@ func PathForSourceRelaxed(ctx PathContext, pathComponents ...string) SourcePath
 	} else if exists, _, err := ctx.Config().fs.Exists(path.String()); err != nil {
 		ReportPathErrorf(ctx, "%s: %s", path, err.Error())
 	} else if !exists {
-		ReportPathErrorf(ctx, "source path %s does not exist", path)
+		ReportPathErrorf(ctx, "source path %s %s", path, missingPathReason(ctx, path.String()))
 	}
 	return path
 }
@@ -1214,7 +1227,7 @@ func ExistentPathForSource(ctx PathGlobContext, pathComponents ...string) Option
 		return OptionalPath{}
 	}
 	if !exists {
-		return InvalidOptionalPath(path.String() + " does not exist")
+		return InvalidOptionalPath(path.String() + " " + missingPathReason(ctx, path.String()))
 	}
 	return OptionalPathForPath(path)
 }
@@ -1485,6 +1498,46 @@ func OptionalPathForModuleSrc(ctx ModuleMissingDepsPathContext, p *string) Optio
 	return OptionalPathForPath(PathForModuleSrc(ctx, *p))
 }
 
+// PathForModuleSrcOrModule returns the Path for the value of a property that accepts either a
+// source file relative to the module's local source directory or a reference to the output file
+// of a module.  An explicit ":module" or "//dir:module" reference always refers to the module, and
+// requires the property to be annotated with android:"path" so that the dependency is added.  Any
+// other value refers to the file, and is an error if it is also the name of a module, since it is
+// unclear which one was meant.  A value starting with "./" always refers to the file.
+func PathForModuleSrcOrModule(ctx ModuleContext, path string) Path {
+	if module, _ := SrcIsModuleWithTag(path); module == "" && !strings.HasPrefix(path, "./") {
+		CheckSrcOrModuleReference(ctx, path, pathForModuleSrc(ctx, path))
+	}
+	return PathForModuleSrc(ctx, path)
+}
+
+// OptionalPathForModuleSrcOrModule returns an OptionalPath for the value of a property that
+// accepts either a source file or a module reference, as described by PathForModuleSrcOrModule.
+// The OptionalPath is invalid if p is nil.
+func OptionalPathForModuleSrcOrModule(ctx ModuleContext, p *string) OptionalPath {
+	if p == nil {
+		return OptionalPath{}
+	}
+	return OptionalPathForPath(PathForModuleSrcOrModule(ctx, *p))
+}
+
+// CheckSrcOrModuleReference reports an error if path, the value of a property that accepts either
+// a file or a ":module" reference, is not a module reference but is the name of a module.  file is
+// the file that path refers to.  It returns false if an error was reported.
+func CheckSrcOrModuleReference(ctx ModuleContext, path string, file SourcePath) bool {
+	if module, _ := SrcIsModuleWithTag(path); module != "" || !ctx.OtherModuleExists(path) {
+		return true
+	}
+	if ExistentPathForSource(ctx, file.String()).Valid() {
+		ctx.ModuleErrorf("%q refers to both the file %q and the module %q, use \":%s\" to refer to the module or rename the file",
+			path, file.String(), path, path)
+	} else {
+		ctx.ModuleErrorf("%q is not a file but is the name of a module, use \":%s\" to refer to the module",
+			path, path)
+	}
+	return false
+}
+
 func (p SourcePath) genPathWithExt(ctx ModuleOutPathContext, subdir, ext string) ModuleGenPath {
 	return PathForModuleGen(ctx, subdir, pathtools.ReplaceExtension(p.path, ext))
 }
@@ -1525,7 +1578,7 @@ type ModuleOutPathContext interface {
 }
 
 func pathForModuleOut(ctx ModuleOutPathContext) OutputPath {
-	return PathForOutput(ctx, ".intermediates", ctx.ModuleDir(), ctx.ModuleName(), ctx.ModuleSubDir())
+	return PathForOutput(ctx, moduleIntermediatesDir(ctx.Config(), ctx.ModuleDir(), ctx.ModuleName(), ctx.ModuleSubDir()))
 }
 
 // PathForModuleOut returns a Path representing the paths... under the module's
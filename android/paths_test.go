@@ -18,10 +18,12 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint/pathtools"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -1041,6 +1043,8 @@ type pathForModuleSrcTestModule struct {
 
 		Src *string `android:"path"`
 
+		Src_or_module *string `android:"path"`
+
 		Module_handles_missing_deps bool
 	}
 
@@ -1081,6 +1085,14 @@ func (p *pathForModuleSrcTestModule) GenerateAndroidBuildActions(ctx ModuleConte
 		}
 	}
 
+	if p.props.Src_or_module != nil {
+		src := PathForModuleSrcOrModule(ctx, *p.props.Src_or_module)
+		if src != nil {
+			p.src = src.String()
+			p.rel = src.Rel()
+		}
+	}
+
 	if !p.props.Module_handles_missing_deps {
 		p.missingDeps = ctx.GetMissingDependencies()
 	}
@@ -1293,11 +1305,65 @@ func TestPathsForModuleSrc(t *testing.T) {
 			srcs: []string{"foo/src_special/$"},
 			rels: []string{"src_special/$"},
 		},
+		{
+			name: "glob through symlink",
+			bp: `
+			test {
+				name: "foo",
+				srcs: ["src_link/*"],
+			}`,
+			preparer: FixtureAddSymlink("foo/src_link", "src/e"),
+			srcs:     []string{"foo/src_link/e"},
+			rels:     []string{"src_link/e"},
+		},
 	}
 
 	testPathForModuleSrc(t, tests)
 }
 
+// existentPathForSourceTestContext is a PathGlobContext that globs the mock filesystem of the
+// config directly.
+type existentPathForSourceTestContext struct {
+	config Config
+}
+
+func (ctx existentPathForSourceTestContext) Config() Config {
+	return ctx.config
+}
+
+func (existentPathForSourceTestContext) AddNinjaFileDeps(...string) {}
+
+func (ctx existentPathForSourceTestContext) GlobWithDeps(pattern string, excludes []string) ([]string, error) {
+	result, err := ctx.config.fs.Glob(pattern, excludes, pathtools.FollowSymlinks)
+	return result.Matches, err
+}
+
+func TestExistentPathForSourceSymlinks(t *testing.T) {
+	mockFS := MockFS{
+		"a/file":                nil,
+		"b/file":                nil,
+		"a/file_link -> file":   nil,
+		"a/dir_link -> ../b":    nil,
+		"a/dangling -> missing": nil,
+	}
+	ctx := existentPathForSourceTestContext{config: TestConfig("out", nil, "", mockFS)}
+
+	path := ExistentPathForSource(ctx, "a/file_link")
+	AssertBoolEquals(t, "symlink to a file is valid", true, path.Valid())
+	AssertStringEquals(t, "symlink to a file", "a/file_link", path.String())
+
+	path = ExistentPathForSource(ctx, "a/dir_link/file")
+	AssertBoolEquals(t, "file in a symlinked directory is valid", true, path.Valid())
+	AssertStringEquals(t, "file in a symlinked directory", "a/dir_link/file", path.String())
+
+	path = ExistentPathForSource(ctx, "a/dangling")
+	AssertBoolEquals(t, "dangling symlink is valid", false, path.Valid())
+	AssertStringEquals(t, "dangling symlink", `a/dangling is a dangling symlink to "missing"`, path.InvalidReason())
+
+	path = ExistentPathForSource(ctx, "a/missing")
+	AssertStringEquals(t, "missing file", "a/missing does not exist", path.InvalidReason())
+}
+
 func TestPathForModuleSrc(t *testing.T) {
 	tests := []pathForModuleSrcTestCase{
 		{
@@ -1432,6 +1498,169 @@ func TestPathForModuleSrc(t *testing.T) {
 	testPathForModuleSrc(t, tests)
 }
 
+func TestPathForModuleSrcOrModule(t *testing.T) {
+	tests := []pathForModuleSrcTestCase{
+		{
+			name: "file",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: "src/b",
+			}`,
+			src: "foo/src/b",
+			rel: "src/b",
+		},
+		{
+			name: "module",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: "a",
+			}`,
+			src: "foo/a",
+			rel: "a",
+			errorHandler: FixtureExpectsOneErrorPattern(
+				`"a" is not a file but is the name of a module, use ":a" to refer to the module`),
+		},
+		{
+			name: "file and module",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: "a",
+			}`,
+			preparer: FixtureAddFile("foo/a", nil),
+			src:      "foo/a",
+			rel:      "a",
+			errorHandler: FixtureExpectsOneErrorPattern(
+				`"a" refers to both the file "foo/a" and the module "a", use ":a" to refer to the module or rename the file`),
+		},
+		{
+			name: "neither file nor module",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: "missing",
+			}`,
+			preparer:     PrepareForTestDisallowNonExistentPaths,
+			errorHandler: FixtureExpectsOneErrorPattern(`module source path "foo/missing" does not exist`),
+		},
+		{
+			name: "symlink",
+			bp: `
+			test {
+				name: "foo",
+				src: "b_link",
+			}`,
+			preparer: GroupFixturePreparers(
+				PrepareForTestDisallowNonExistentPaths,
+				FixtureAddSymlink("foo/b_link", "src/b"),
+			),
+			src: "foo/b_link",
+			rel: "b_link",
+		},
+		{
+			name: "dangling symlink",
+			bp: `
+			test {
+				name: "foo",
+				src: "missing_link",
+			}`,
+			preparer: GroupFixturePreparers(
+				PrepareForTestDisallowNonExistentPaths,
+				FixtureAddSymlink("foo/missing_link", "src/missing"),
+			),
+			errorHandler: FixtureExpectsOneErrorPattern(
+				`module source path "foo/missing_link" is a dangling symlink to "src/missing"`),
+		},
+		{
+			name: "explicit module",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: ":a",
+			}`,
+			preparer: FixtureAddFile("foo/a", nil),
+			src:      "fg/src/a",
+			rel:      "src/a",
+		},
+		{
+			name: "explicit file",
+			bp: `
+			test {
+				name: "foo",
+				src_or_module: "./a",
+			}`,
+			preparer: FixtureAddFile("foo/a", nil),
+			src:      "foo/a",
+			rel:      "a",
+		},
+	}
+
+	testPathForModuleSrc(t, tests)
+}
+
+func TestPathsForModuleSrcInSubpackage(t *testing.T) {
+	bp := `
+		test {
+			name: "foo",
+			srcs: ["src/b"],
+		}`
+
+	tests := []pathForModuleSrcTestCase{
+		{
+			name:     "subpackage",
+			bp:       bp,
+			preparer: FixtureAddTextFile("foo/src/Android.bp", ""),
+			srcs:     []string{"foo/src/b"},
+			rels:     []string{"src/b"},
+			errorHandler: FixtureExpectsOneErrorPattern(regexp.QuoteMeta(
+				`"src/b" is in the package "foo/src", not in the package of the module; use a filegroup defined in "foo/src" instead, or add "//foo" to allow_external_srcs_from of its package module`)),
+		},
+		{
+			name: "granted",
+			bp:   bp,
+			preparer: GroupFixturePreparers(
+				PrepareForTestWithPackageModule,
+				FixtureAddTextFile("foo/src/Android.bp", `
+					package {
+						allow_external_srcs_from: ["//foo"],
+					}`),
+			),
+			srcs: []string{"foo/src/b"},
+			rels: []string{"src/b"},
+		},
+		{
+			name: "granted to another package",
+			bp:   bp,
+			preparer: GroupFixturePreparers(
+				PrepareForTestWithPackageModule,
+				FixtureAddTextFile("foo/src/Android.bp", `
+					package {
+						allow_external_srcs_from: ["//bar"],
+					}`),
+			),
+			srcs:         []string{"foo/src/b"},
+			rels:         []string{"src/b"},
+			errorHandler: FixtureExpectsOneErrorPattern(`"src/b" is in the package "foo/src"`),
+		},
+		{
+			name: "allowlisted",
+			bp:   bp,
+			preparer: GroupFixturePreparers(
+				FixtureAddTextFile("foo/src/Android.bp", ""),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.SubpackageSrcsAllowedPaths = []string{"foo"}
+				}),
+			),
+			srcs: []string{"foo/src/b"},
+			rels: []string{"src/b"},
+		},
+	}
+
+	testPathForModuleSrc(t, tests)
+}
+
 func TestPathsForModuleSrc_AllowMissingDependencies(t *testing.T) {
 	bp := `
 		test {
@@ -404,6 +404,12 @@ func PrebuiltRenameMutator(ctx BottomUpMutatorContext) {
 	}
 }
 
+func (prebuiltDependencyTag) DependencyProperty() string {
+	return "prebuilt replacing the source module"
+}
+
+var _ PropertyDependencyTag = PrebuiltDepTag
+
 // PrebuiltSourceDepsMutator adds dependencies to the prebuilt module from the
 // corresponding source module, if one exists for the same variant.
 func PrebuiltSourceDepsMutator(ctx BottomUpMutatorContext) {
@@ -413,6 +419,13 @@ func PrebuiltSourceDepsMutator(ctx BottomUpMutatorContext) {
 	if p := GetEmbeddedPrebuilt(m); p != nil && m.Enabled() && !p.properties.PrebuiltRenamedToSource {
 		name := m.base().BaseModuleName()
 		if ctx.OtherModuleReverseDependencyVariantExists(name) {
+			if path := prebuiltPathToSource(ctx, name); path != nil {
+				ctx.ModuleErrorf("prebuilt %q depends on its source module %q:\n    %s\n"+
+					"The source module depends on the prebuilt so that the prebuilt can replace it when "+
+					"prefer is set, so this creates a dependency cycle. Remove one of the dependencies above.",
+					ctx.ModuleName(), name, strings.Join(path, " "))
+				return
+			}
 			ctx.AddReverseDependency(ctx.Module(), PrebuiltDepTag, name)
 			p.properties.SourceExists = true
 		}
@@ -464,6 +477,53 @@ func PrebuiltPostDepsMutator(ctx BottomUpMutatorContext) {
 	}
 }
 
+// prebuiltPathToSource returns the dependency path, alternating module names and dependency
+// descriptions, from the prebuilt to its source module, or nil if there is none.  The path may
+// end in an override module of the source module, whose dependency on its base is added later.
+// Adding the dependency from the source module to the prebuilt would then create a cycle that
+// blueprint can only report in terms of internal variant names.  Only the dependencies of the
+// prebuilt are walked, once, as each module is visited at most once.
+func prebuiltPathToSource(ctx BottomUpMutatorContext, sourceName string) []string {
+	type edge struct {
+		parent Module
+		tag    blueprint.DependencyTag
+	}
+	edges := make(map[Module]edge)
+	var source Module
+	viaOverride := false
+	ctx.WalkDeps(func(child, parent Module) bool {
+		if source != nil {
+			return false
+		}
+		if _, visited := edges[child]; visited {
+			return false
+		}
+		edges[child] = edge{parent, ctx.OtherModuleDependencyTag(child)}
+		if GetEmbeddedPrebuilt(child) == nil && child.base().BaseModuleName() == sourceName {
+			source = child
+			return false
+		}
+		if o, ok := child.(OverrideModule); ok && String(o.getOverrideModuleProperties().Base) == sourceName {
+			source = child
+			viaOverride = true
+			return false
+		}
+		return true
+	})
+	if source == nil {
+		return nil
+	}
+
+	var path []string
+	if viaOverride {
+		path = []string{"-[" + overrideBaseDepTag.DependencyProperty() + "]->", sourceName}
+	}
+	for m := source; m != ctx.Module(); m = edges[m].parent {
+		path = append([]string{"-[" + DependencyTagDescription(edges[m].tag) + "]->", ctx.OtherModuleName(m)}, path...)
+	}
+	return append([]string{ctx.ModuleName()}, path...)
+}
+
 // usePrebuilt returns true if a prebuilt should be used instead of the source module.  The prebuilt
 // will be used if it is marked "prefer" or if the source module is disabled.
 func (p *Prebuilt) usePrebuilt(ctx TopDownMutatorContext, source Module, prebuilt Module) bool {
@@ -16,6 +16,7 @@ package android
 
 import (
 	"fmt"
+	"regexp"
 	"testing"
 
 	"github.com/google/blueprint"
@@ -497,6 +498,113 @@ func TestPrebuilts(t *testing.T) {
 	}
 }
 
+func TestPrebuiltPreferredCycleError(t *testing.T) {
+	GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithPrebuilts,
+		PrepareForTestWithOverrides,
+		FixtureRegisterWithContext(registerTestPrebuiltModules),
+		MockFS{
+			"prebuilt_file": nil,
+			"source_file":   nil,
+		}.AddToFixture(),
+	).
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			regexp.QuoteMeta(`prebuilt "prebuilt_foo" depends on its source module "foo":
+    prebuilt_foo -[path property reference ":bar"]-> bar -[path property reference ":foo"]-> foo
+The source module depends on the prebuilt so that the prebuilt can replace it when prefer is set, so this creates a dependency cycle.`),
+		})).
+		RunTestWithBp(t, `
+			prebuilt {
+				name: "foo",
+				prefer: true,
+				srcs: [":bar"],
+			}
+
+			source {
+				name: "foo",
+			}
+
+			source {
+				name: "bar",
+				deps: [":foo"],
+			}
+		`)
+}
+
+func TestPrebuiltPreferredOverrideCycleError(t *testing.T) {
+	GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithPrebuilts,
+		PrepareForTestWithOverrides,
+		FixtureRegisterWithContext(registerTestPrebuiltModules),
+		MockFS{
+			"prebuilt_file": nil,
+			"source_file":   nil,
+		}.AddToFixture(),
+	).
+		ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			regexp.QuoteMeta(`prebuilt "prebuilt_foo" depends on its source module "foo":
+    prebuilt_foo -[path property reference ":bar"]-> bar -[base]-> foo
+The source module depends on the prebuilt so that the prebuilt can replace it when prefer is set, so this creates a dependency cycle.`),
+		})).
+		RunTestWithBp(t, `
+			prebuilt {
+				name: "foo",
+				prefer: true,
+				srcs: [":bar"],
+			}
+
+			source {
+				name: "foo",
+			}
+
+			override_source {
+				name: "bar",
+				base: "foo",
+			}
+		`)
+}
+
+func TestPrebuiltPreferredWithoutCycle(t *testing.T) {
+	result := GroupFixturePreparers(
+		PrepareForTestWithArchMutator,
+		PrepareForTestWithPrebuilts,
+		PrepareForTestWithOverrides,
+		FixtureRegisterWithContext(registerTestPrebuiltModules),
+		MockFS{
+			"prebuilt_file": nil,
+			"source_file":   nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		prebuilt {
+			name: "foo",
+			prefer: true,
+			srcs: ["prebuilt_file"],
+		}
+
+		source {
+			name: "foo",
+			deps: [":bar"],
+		}
+
+		source {
+			name: "bar",
+		}
+
+		override_source {
+			name: "baz",
+			base: "foo",
+		}
+	`)
+
+	// The override of the source module is not a dependency of the prebuilt, so the prebuilt
+	// replaces the source module.
+	prebuilt := GetEmbeddedPrebuilt(result.ModuleForTests("prebuilt_foo", "android_common").Module())
+	AssertBoolEquals(t, "prebuilt_foo source exists", true, prebuilt.properties.SourceExists)
+	AssertBoolEquals(t, "prebuilt_foo used", true, prebuilt.UsePrebuilt())
+}
+
 func registerTestPrebuiltBuildComponents(ctx RegistrationContext) {
 	registerTestPrebuiltModules(ctx)
 
@@ -0,0 +1,180 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The product packages check reports the Soong modules that the product asks to install, in
+// PRODUCT_PACKAGES or in the required properties of those modules, but that have no enabled
+// device variant, e.g. a module with compile_multilib: "32" on a product with only 64-bit device
+// architectures.  Make silently ignores them, so they would otherwise disappear from the product.
+//
+// Every such module is listed, with the reason it cannot be built, in
+// out/soong/unbuildable_product_packages.txt, which is built by the unbuildable-product-packages
+// phony target.  Analysis fails if the list is not empty, unless
+// BUILD_BROKEN_UNBUILDABLE_PRODUCT_PACKAGES is set.
+
+func init() {
+	RegisterProductPackagesCheckBuildComponents(InitRegistrationContext)
+}
+
+func RegisterProductPackagesCheckBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("product_packages_check", productPackagesCheckSingletonFactory)
+}
+
+func productPackagesCheckSingletonFactory() Singleton {
+	return &productPackagesCheckSingleton{}
+}
+
+type productPackagesCheckSingleton struct{}
+
+func (s *productPackagesCheckSingleton) GenerateBuildActions(ctx SingletonContext) {
+	packages := ctx.Config().ProductPackages()
+	if len(packages) == 0 {
+		return
+	}
+
+	variantsByName := make(map[string][]Module)
+	ctx.VisitAllModules(func(module Module) {
+		variantsByName[module.Name()] = append(variantsByName[module.Name()], module)
+	})
+
+	// Follow the required properties of the device variants of the requested modules, as Make
+	// installs the required modules along with them.
+	requiredBy := make(map[string]string)
+	queue := FirstUniqueStrings(packages)
+	for _, name := range queue {
+		requiredBy[name] = ""
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, variant := range variantsByName[name] {
+			if variant.Target().Os.Class != Device || !variant.Enabled() {
+				continue
+			}
+			for _, dep := range variant.RequiredModuleNames() {
+				if _, seen := requiredBy[dep]; !seen {
+					requiredBy[dep] = name
+					queue = append(queue, dep)
+				}
+			}
+		}
+	}
+
+	var lines []string
+	for _, name := range SortedKeys(requiredBy) {
+		variants, exists := variantsByName[name]
+		if !exists {
+			// Modules that are not defined in Soong are left to Make.
+			continue
+		}
+		reason := unbuildableOnDeviceReason(ctx.Config(), variants)
+		if reason == "" {
+			continue
+		}
+		line := name + ": " + reason
+		if requiredBy[name] != "" {
+			line += fmt.Sprintf(" (required by %s)", requiredBy[name])
+		}
+		lines = append(lines, line)
+	}
+
+	report := PathForOutput(ctx, "unbuildable_product_packages.txt")
+	WriteFileRuleVerbatim(ctx, report, strings.Join(lines, "\n")+"\n")
+	ctx.Phony("unbuildable-product-packages", report)
+	if len(lines) > 0 && !ctx.DeviceConfig().BuildBrokenUnbuildableProductPackages() {
+		ctx.Errorf("%d modules requested by the product cannot be built for the device:\n    %s\n"+
+			"Remove them from the product, or set BUILD_BROKEN_UNBUILDABLE_PRODUCT_PACKAGES := true to only "+
+			"report them in %s.", len(lines), strings.Join(lines, "\n    "), report)
+	}
+}
+
+// unbuildableOnDeviceReason returns why none of the variants of a module can be installed on the
+// device, or "" if one of them can.
+func unbuildableOnDeviceReason(config Config, variants []Module) string {
+	var hasDeviceVariant, hasHostVariant bool
+	var propertyDisabledArches, forcedDisabledArches []string
+	var multilib string
+	for _, variant := range variants {
+		if variant.Target().Os.Class != Device {
+			hasHostVariant = hasHostVariant || variant.Target().Os.Class == Host
+			continue
+		}
+		hasDeviceVariant = true
+		if variant.Enabled() {
+			return ""
+		}
+		arch := variant.Target().Arch.ArchType
+		if arch.Name == "" {
+			// The arch mutator disables the device variant when none of the device architectures
+			// match the multilib of the module.
+			multilib, _ = decodeMultilib(variant.base(), variant.Target().Os, config.IgnorePrefer32OnDevice())
+		} else if variant.base().commonProperties.ForcedDisabled {
+			forcedDisabledArches = append(forcedDisabledArches, arch.Name)
+		} else {
+			propertyDisabledArches = append(propertyDisabledArches, arch.Name)
+		}
+	}
+
+	if !hasDeviceVariant {
+		if hasHostVariant {
+			return "only built for the host"
+		}
+		return "not supported on the device"
+	}
+
+	var reasons []string
+	if len(propertyDisabledArches) > 0 {
+		reasons = append(reasons, "enabled: false"+forDeviceArches(propertyDisabledArches))
+	}
+	if len(forcedDisabledArches) > 0 {
+		reasons = append(reasons, "disabled by Soong"+forDeviceArches(forcedDisabledArches))
+	}
+	if len(reasons) > 0 {
+		return strings.Join(reasons, ", ")
+	}
+
+	var deviceArches []string
+	for _, target := range config.Targets[Android] {
+		deviceArches = append(deviceArches, target.Arch.ArchType.Name)
+	}
+	switch multilib {
+	case "32", "lib32":
+		return fmt.Sprintf("lib32 only (compile_multilib: %q), but the device architectures are %s",
+			multilib, strings.Join(deviceArches, ", "))
+	case "64", "lib64":
+		return fmt.Sprintf("lib64 only (compile_multilib: %q), but the device architectures are %s",
+			multilib, strings.Join(deviceArches, ", "))
+	default:
+		return fmt.Sprintf("no device architecture matches compile_multilib: %q, the device architectures are %s",
+			multilib, strings.Join(deviceArches, ", "))
+	}
+}
+
+// forDeviceArches returns the suffix of a reason that only applies to the variants of some device
+// architectures, or "" if the variant is not architecture specific.
+func forDeviceArches(arches []string) string {
+	arches = FirstUniqueStrings(arches)
+	if len(arches) == 1 && arches[0] == Common.Name {
+		return ""
+	}
+	sort.Strings(arches)
+	return " for the device architectures " + strings.Join(arches, ", ")
+}
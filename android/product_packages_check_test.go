@@ -0,0 +1,116 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestProductPackagesCheck(t *testing.T) {
+	bp := `
+		deps {
+			name: "lib32_only",
+			compile_multilib: "32",
+		}
+
+		deps {
+			name: "requires",
+			required: ["arm64_disabled"],
+		}
+
+		deps {
+			name: "arm64_disabled",
+			compile_multilib: "64",
+			arch: {
+				arm64: {
+					enabled: false,
+				},
+			},
+		}
+
+		deps {
+			name: "disabled",
+			enabled: false,
+		}
+
+		deps {
+			name: "host_only",
+			host_supported: true,
+			device_supported: false,
+		}
+
+		deps {
+			name: "forced_disabled",
+		}
+
+		deps {
+			name: "not_requested",
+			compile_multilib: "32",
+		}
+	`
+
+	expectedReport := "" +
+		"arm64_disabled: enabled: false for the device architectures arm64 (required by requires)\n" +
+		"disabled: enabled: false\n" +
+		"forced_disabled: disabled by Soong\n" +
+		"host_only: only built for the host\n" +
+		"lib32_only: lib32 only (compile_multilib: \"32\"), but the device architectures are arm64\n"
+
+	preparer := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(RegisterProductPackagesCheckBuildComponents),
+		FixtureRegisterWithContext(func(ctx RegistrationContext) {
+			// A variant disabled by a mutator rather than by its properties.
+			ctx.PostDepsMutators(func(ctx RegisterMutatorsContext) {
+				ctx.BottomUp("force_disable", func(ctx BottomUpMutatorContext) {
+					if ctx.ModuleName() == "forced_disabled" {
+						ctx.Module().Disable()
+					}
+				})
+			})
+		}),
+		// A product with only 64-bit device architectures.
+		FixtureModifyConfig(func(config Config) {
+			config.Targets[Android] = config.Targets[Android][:1]
+		}),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			// Modules that are not defined in Soong are left to Make.
+			variables.ProductPackages = []string{"lib32_only", "requires", "disabled", "host_only",
+				"forced_disabled", "make_only"}
+		}),
+		FixtureWithRootAndroidBp(bp),
+	)
+
+	t.Run("error", func(t *testing.T) {
+		preparer.ExtendWithErrorHandler(FixtureExpectsAllErrorsToMatchAPattern([]string{
+			regexp.QuoteMeta("5 modules requested by the product cannot be built for the device:\n    " +
+				"arm64_disabled: enabled: false for the device architectures arm64 (required by requires)\n    "),
+		})).RunTest(t)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			preparer,
+			FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+				variables.BuildBrokenUnbuildableProductPackages = true
+			}),
+		).RunTest(t)
+
+		report := result.SingletonForTests("product_packages_check").Output("unbuildable_product_packages.txt")
+		AssertStringEquals(t, "report", expectedReport, ContentFromFileRuleForTests(t, report))
+	})
+}
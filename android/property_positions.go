@@ -0,0 +1,102 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+)
+
+// Blueprint reports property errors at the position of the property in the module definition, but
+// a property that the module inherits from a defaults module is not in the module definition, so
+// the error is reported at the module instead.  The functions below find the defaults module that
+// defines such a property, so that the error can name it, or be reported at the position blueprint
+// recorded for the property in the defaults module.
+
+// inheritedPropertyDefaults returns the defaults module that the module of ctx inherits a property
+// from, which may be nested like "target.android.srcs".
+func inheritedPropertyDefaults(ctx EarlyModuleContext, property string) (Defaults, bool) {
+	if ctx.ContainsProperty(property) {
+		return nil, false
+	}
+	defaultable, ok := ctx.Module().(Defaultable)
+	if !ok {
+		return nil, false
+	}
+	names := strings.Split(property, ".")
+	for _, defaults := range defaultable.defaultsApplied() {
+		for _, props := range defaults.module.properties() {
+			if propertyIsSet(reflect.ValueOf(props), names) {
+				return defaults.module, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// propertyIsSet returns true if the property with the given name components is set in the
+// property struct v.
+func propertyIsSet(v reflect.Value, names []string) bool {
+	if len(names) == 0 {
+		return v.IsValid() && !v.IsZero()
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous {
+			if propertyIsSet(v.Field(i), names) {
+				return true
+			}
+		} else if field.IsExported() && proptools.PropertyNameForField(field.Name) == names[0] {
+			return propertyIsSet(v.Field(i), names[1:])
+		}
+	}
+	return false
+}
+
+// propertyErrorf implements EarlyModuleContext.PropertyErrorf, naming the defaults module that
+// defines the property when it is inherited.
+func propertyErrorf(ctx EarlyModuleContext, report func(property, format string, args ...interface{}),
+	property, format string, args ...interface{}) {
+
+	msg := fmt.Sprintf(format, args...)
+	if defaults, ok := inheritedPropertyDefaults(ctx, property); ok {
+		msg = fmt.Sprintf("%s (inherited from %q)", msg, defaults.(blueprint.Module).Name())
+	}
+	report(property, "%s", msg)
+}
+
+// propertyErrorWithPosf implements BaseModuleContext.PropertyErrorWithPosf.
+func propertyErrorWithPosf(ctx BaseModuleContext, property, format string, args ...interface{}) {
+	defaults, ok := inheritedPropertyDefaults(ctx, property)
+	if !ok {
+		ctx.PropertyErrorf(property, format, args...)
+		return
+	}
+	ctx.blueprintBaseModuleContext().OtherModulePropertyErrorf(defaults.(blueprint.Module), property,
+		"inherited by module %q: %s", ctx.ModuleName(), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,286 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint"
+)
+
+// The soong query facility answers questions about the module graph without generating a ninja
+// file.  `m soong_query` runs a separate soong_build invocation that stops after the mutators,
+// runs the query in SOONG_QUERY over the in-memory module graph and writes the answer to
+// out/soong/query_result.txt.  The build.ninja file of the main invocation is not affected.
+//
+// Supported queries:
+//
+//	deps(A, B[, N]): up to N (default 10) dependency paths from module A to module B, with the
+//	                 dependency tags of each edge.
+//	rdeps(A):        the modules with a direct dependency on module A.
+//	variants(A):     the variants of module A.
+
+const SoongQueryEnv = "SOONG_QUERY"
+
+const defaultSoongQueryMaxPaths = 10
+
+func init() {
+	RegisterSoongQueryBuildComponents(InitRegistrationContext)
+}
+
+func RegisterSoongQueryBuildComponents(ctx RegistrationContext) {
+	ctx.FinalDepsMutators(func(ctx RegisterMutatorsContext) {
+		ctx.BottomUp("soong_query_deps", soongQueryDepsMutator).Parallel()
+	})
+}
+
+// soongQueryDep is a dependency edge recorded for soong query.
+type soongQueryDep struct {
+	module blueprint.Module
+	tag    string
+}
+
+type soongQueryDepsInfo struct {
+	deps []soongQueryDep
+}
+
+var soongQueryDepsProvider = blueprint.NewMutatorProvider(soongQueryDepsInfo{}, "soong_query_deps")
+
+// soongQueryDepsMutator records the dependency tags of each module's direct dependencies, which are
+// not otherwise available once the mutators have finished.
+func soongQueryDepsMutator(ctx BottomUpMutatorContext) {
+	if ctx.Config().BuildMode != GenerateSoongQuery {
+		return
+	}
+	var info soongQueryDepsInfo
+	ctx.VisitDirectDepsBlueprint(func(dep blueprint.Module) {
+		info.deps = append(info.deps, soongQueryDep{
+			module: dep,
+			tag:    fmt.Sprintf("%T", ctx.OtherModuleDependencyTag(dep)),
+		})
+	})
+	ctx.SetProvider(soongQueryDepsProvider, info)
+}
+
+// soongQueryGraph is the subset of blueprint.Context used to answer queries.
+type soongQueryGraph interface {
+	VisitAllModules(visit func(blueprint.Module))
+	ModuleName(module blueprint.Module) string
+	ModuleSubDir(module blueprint.Module) string
+	ModuleProvider(module blueprint.Module, provider blueprint.ProviderKey) interface{}
+}
+
+type soongQuery struct {
+	function string
+	args     []string
+	maxPaths int
+}
+
+var soongQueryRegexp = regexp.MustCompile(`^\s*(\w+)\s*\((.*)\)\s*$`)
+
+// parseSoongQuery parses a query of the form function(arg, ...).
+func parseSoongQuery(query string) (soongQuery, error) {
+	matches := soongQueryRegexp.FindStringSubmatch(query)
+	if matches == nil {
+		return soongQuery{}, fmt.Errorf("invalid query %q, expected deps(A, B), rdeps(A) or variants(A)", query)
+	}
+	q := soongQuery{function: matches[1], maxPaths: defaultSoongQueryMaxPaths}
+	for _, arg := range strings.Split(matches[2], ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			q.args = append(q.args, arg)
+		}
+	}
+
+	switch q.function {
+	case "deps":
+		if len(q.args) == 3 {
+			max, err := strconv.Atoi(q.args[2])
+			if err != nil || max < 1 {
+				return soongQuery{}, fmt.Errorf("invalid maximum number of paths %q in query %q", q.args[2], query)
+			}
+			q.maxPaths = max
+			q.args = q.args[:2]
+		}
+		if len(q.args) != 2 {
+			return soongQuery{}, fmt.Errorf("deps() expects 2 or 3 arguments, got %d", len(q.args))
+		}
+	case "rdeps", "variants":
+		if len(q.args) != 1 {
+			return soongQuery{}, fmt.Errorf("%s() expects 1 argument, got %d", q.function, len(q.args))
+		}
+	default:
+		return soongQuery{}, fmt.Errorf("unknown query function %q, expected deps, rdeps or variants", q.function)
+	}
+	return q, nil
+}
+
+// RunSoongQuery runs the query in the SOONG_QUERY environment variable over the module graph and
+// returns the result as text.
+func RunSoongQuery(ctx *Context) (string, error) {
+	return runSoongQuery(ctx.Context, ctx.Config().Getenv(SoongQueryEnv))
+}
+
+func runSoongQuery(graph soongQueryGraph, query string) (string, error) {
+	q, err := parseSoongQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	byName := make(map[string][]blueprint.Module)
+	var modules []blueprint.Module
+	graph.VisitAllModules(func(module blueprint.Module) {
+		name := graph.ModuleName(module)
+		byName[name] = append(byName[name], module)
+		modules = append(modules, module)
+	})
+
+	lookup := func(name string) ([]blueprint.Module, error) {
+		if variants, ok := byName[name]; ok {
+			return variants, nil
+		}
+		return nil, fmt.Errorf("module %q not found", name)
+	}
+
+	describe := func(module blueprint.Module) string {
+		return fmt.Sprintf("%s (%s)", graph.ModuleName(module), graph.ModuleSubDir(module))
+	}
+
+	deps := func(module blueprint.Module) []soongQueryDep {
+		return graph.ModuleProvider(module, soongQueryDepsProvider).(soongQueryDepsInfo).deps
+	}
+
+	sb := &strings.Builder{}
+	switch q.function {
+	case "variants":
+		variants, err := lookup(q.args[0])
+		if err != nil {
+			return "", err
+		}
+		var lines []string
+		for _, variant := range variants {
+			lines = append(lines, graph.ModuleSubDir(variant))
+		}
+		sort.Strings(lines)
+		fmt.Fprintf(sb, "%d variants of %s:\n", len(lines), q.args[0])
+		for _, line := range lines {
+			fmt.Fprintf(sb, "  %q\n", line)
+		}
+
+	case "rdeps":
+		variants, err := lookup(q.args[0])
+		if err != nil {
+			return "", err
+		}
+		isTarget := make(map[blueprint.Module]bool)
+		for _, variant := range variants {
+			isTarget[variant] = true
+		}
+		var lines []string
+		for _, module := range modules {
+			for _, dep := range deps(module) {
+				if isTarget[dep.module] {
+					lines = append(lines, fmt.Sprintf("%s -[%s]-> %s", describe(module), dep.tag, describe(dep.module)))
+				}
+			}
+		}
+		lines = SortedUniqueStrings(lines)
+		fmt.Fprintf(sb, "%d reverse dependencies of %s:\n", len(lines), q.args[0])
+		for _, line := range lines {
+			fmt.Fprintf(sb, "  %s\n", line)
+		}
+
+	case "deps":
+		from, err := lookup(q.args[0])
+		if err != nil {
+			return "", err
+		}
+		to, err := lookup(q.args[1])
+		if err != nil {
+			return "", err
+		}
+
+		// Find all modules that can reach the target so that the path search below only walks
+		// edges that lead somewhere.
+		reverse := make(map[blueprint.Module][]blueprint.Module)
+		for _, module := range modules {
+			for _, dep := range deps(module) {
+				reverse[dep.module] = append(reverse[dep.module], module)
+			}
+		}
+		reachesTarget := make(map[blueprint.Module]bool)
+		queue := append([]blueprint.Module(nil), to...)
+		for _, module := range to {
+			reachesTarget[module] = true
+		}
+		for len(queue) > 0 {
+			module := queue[0]
+			queue = queue[1:]
+			for _, parent := range reverse[module] {
+				if !reachesTarget[parent] {
+					reachesTarget[parent] = true
+					queue = append(queue, parent)
+				}
+			}
+		}
+
+		isTarget := make(map[blueprint.Module]bool)
+		for _, module := range to {
+			isTarget[module] = true
+		}
+
+		var paths []string
+		onPath := make(map[blueprint.Module]bool)
+		var walk func(module blueprint.Module, path string)
+		walk = func(module blueprint.Module, path string) {
+			if len(paths) >= q.maxPaths {
+				return
+			}
+			if isTarget[module] {
+				paths = append(paths, path)
+				return
+			}
+			onPath[module] = true
+			defer delete(onPath, module)
+			for _, dep := range deps(module) {
+				if reachesTarget[dep.module] && !onPath[dep.module] {
+					walk(dep.module, path+" -["+dep.tag+"]-> "+describe(dep.module))
+				}
+			}
+		}
+		sort.Slice(from, func(i, j int) bool {
+			return graph.ModuleSubDir(from[i]) < graph.ModuleSubDir(from[j])
+		})
+		for _, module := range from {
+			if reachesTarget[module] {
+				walk(module, describe(module))
+			}
+		}
+
+		fmt.Fprintf(sb, "%d dependency paths from %s to %s", len(paths), q.args[0], q.args[1])
+		if len(paths) >= q.maxPaths {
+			fmt.Fprintf(sb, " (limited to %d)", q.maxPaths)
+		}
+		fmt.Fprintln(sb, ":")
+		for _, path := range paths {
+			fmt.Fprintf(sb, "  %s\n", path)
+		}
+	}
+
+	return sb.String(), nil
+}
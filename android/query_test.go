@@ -0,0 +1,162 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+type soongQueryTestModule struct {
+	ModuleBase
+	properties struct {
+		Deps     []string
+		Lib_deps []string
+	}
+}
+
+type soongQueryTestDepTag struct {
+	blueprint.BaseDependencyTag
+}
+
+type soongQueryTestLibDepTag struct {
+	blueprint.BaseDependencyTag
+}
+
+func soongQueryTestModuleFactory() Module {
+	module := &soongQueryTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, HostAndDeviceSupported, MultilibCommon)
+	return module
+}
+
+func (m *soongQueryTestModule) DepsMutator(ctx BottomUpMutatorContext) {
+	ctx.AddVariationDependencies(nil, soongQueryTestDepTag{}, m.properties.Deps...)
+	ctx.AddVariationDependencies(nil, soongQueryTestLibDepTag{}, m.properties.Lib_deps...)
+}
+
+func (m *soongQueryTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {}
+
+var prepareForSoongQueryTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("test_module", soongQueryTestModuleFactory)
+		RegisterSoongQueryBuildComponents(ctx)
+	}),
+	FixtureModifyConfig(func(config Config) {
+		config.BuildMode = GenerateSoongQuery
+	}),
+	FixtureWithRootAndroidBp(`
+		test_module {
+			name: "a",
+			deps: ["b", "c"],
+		}
+
+		test_module {
+			name: "b",
+			lib_deps: ["d"],
+		}
+
+		test_module {
+			name: "c",
+			deps: ["d"],
+		}
+
+		test_module {
+			name: "d",
+		}
+	`),
+)
+
+func TestSoongQuery(t *testing.T) {
+	result := prepareForSoongQueryTest.RunTest(t)
+
+	testCases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:  "deps",
+			query: "deps(a, d)",
+			expected: "4 dependency paths from a to d:\n" +
+				"  a (android_common) -[android.soongQueryTestDepTag]-> b (android_common) -[android.soongQueryTestLibDepTag]-> d (android_common)\n" +
+				"  a (android_common) -[android.soongQueryTestDepTag]-> c (android_common) -[android.soongQueryTestDepTag]-> d (android_common)\n" +
+				"  a (linux_glibc_common) -[android.soongQueryTestDepTag]-> b (linux_glibc_common) -[android.soongQueryTestLibDepTag]-> d (linux_glibc_common)\n" +
+				"  a (linux_glibc_common) -[android.soongQueryTestDepTag]-> c (linux_glibc_common) -[android.soongQueryTestDepTag]-> d (linux_glibc_common)\n",
+		},
+		{
+			name:  "deps limited",
+			query: "deps(a, d, 1)",
+			expected: "1 dependency paths from a to d (limited to 1):\n" +
+				"  a (android_common) -[android.soongQueryTestDepTag]-> b (android_common) -[android.soongQueryTestLibDepTag]-> d (android_common)\n",
+		},
+		{
+			name:     "no deps",
+			query:    "deps(d, a)",
+			expected: "0 dependency paths from d to a:\n",
+		},
+		{
+			name:  "rdeps",
+			query: "rdeps(d)",
+			expected: "4 reverse dependencies of d:\n" +
+				"  b (android_common) -[android.soongQueryTestLibDepTag]-> d (android_common)\n" +
+				"  b (linux_glibc_common) -[android.soongQueryTestLibDepTag]-> d (linux_glibc_common)\n" +
+				"  c (android_common) -[android.soongQueryTestDepTag]-> d (android_common)\n" +
+				"  c (linux_glibc_common) -[android.soongQueryTestDepTag]-> d (linux_glibc_common)\n",
+		},
+		{
+			name:  "variants",
+			query: "variants(a)",
+			expected: "2 variants of a:\n" +
+				"  \"android_common\"\n" +
+				"  \"linux_glibc_common\"\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := runSoongQuery(result.TestContext, tc.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			AssertStringEquals(t, "query result", tc.expected, actual)
+		})
+	}
+}
+
+func TestSoongQueryErrors(t *testing.T) {
+	result := prepareForSoongQueryTest.RunTest(t)
+
+	testCases := []struct {
+		query string
+		err   string
+	}{
+		{"deps(a)", "deps() expects 2 or 3 arguments, got 1"},
+		{"deps(a, b, zero)", `invalid maximum number of paths "zero" in query "deps(a, b, zero)"`},
+		{"rdeps(a, b)", "rdeps() expects 1 argument, got 2"},
+		{"why(a)", `unknown query function "why", expected deps, rdeps or variants`},
+		{"a -> b", `invalid query "a -> b", expected deps(A, B), rdeps(A) or variants(A)`},
+		{"variants(missing)", `module "missing" not found`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			_, err := runSoongQuery(result.TestContext, tc.query)
+			AssertErrorMessageEquals(t, "error", tc.err, err)
+		})
+	}
+}
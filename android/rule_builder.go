@@ -18,6 +18,7 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -57,6 +58,10 @@ type RuleBuilder struct {
 	sboxInputs       bool
 	sboxManifestPath WritablePath
 	missingDeps      []string
+
+	strictInputs          bool
+	strictInputsModuleDir string
+	strictInputsAllowlist []string
 }
 
 // NewRuleBuilder returns a newly created RuleBuilder.
@@ -181,6 +186,33 @@ func (r *RuleBuilder) SandboxInputs() *RuleBuilder {
 	return r
 }
 
+// StrictInputs makes sbox track the files read by the rule's commands and fail the rule if any
+// file in the source or output tree was read without being declared as an input or tool.
+// moduleDir is used to suggest the srcs entries to add, and allowlist lists source paths that
+// may be read without being declared.  Tracking relies on strace, so it is silently skipped for
+// builds on hosts other than Linux and for rules that run remotely.
+func (r *RuleBuilder) StrictInputs(moduleDir string, allowlist []string) *RuleBuilder {
+	if !r.sbox {
+		panic("StrictInputs() must be called after Sbox()")
+	}
+	r.strictInputs = true
+	r.strictInputsModuleDir = moduleDir
+	r.strictInputsAllowlist = allowlist
+	return r
+}
+
+// strictInputsSupported returns true if sbox can track the files read by the rule's commands,
+// which requires running them locally on a Linux host.
+func (r *RuleBuilder) strictInputsSupported() bool {
+	if r.ctx.Config().BuildOS != Linux {
+		return false
+	}
+	if r.rbeParams != nil || (r.ctx.Config().UseRBE() && r.remoteable.RBE) {
+		return false
+	}
+	return true
+}
+
 // Install associates an output of the rule with an install location, which can be retrieved later using
 // RuleBuilder.Installs.
 func (r *RuleBuilder) Install(from Path, to string) {
@@ -580,6 +612,15 @@ func (r *RuleBuilder) Build(name string, desc string) {
 			command.Chdir = proto.Bool(true)
 		}
 
+		// If strict inputs is enabled, list the declared inputs in the manifest so that sbox can
+		// verify that the commands didn't read anything else.
+		if r.strictInputs && r.strictInputsSupported() {
+			command.StrictInputs = proto.Bool(true)
+			command.DeclaredInputs = append(inputs.Strings(), tools.Strings()...)
+			command.AllowedUndeclaredInputs = r.strictInputsAllowlist
+			command.ModuleDir = proto.String(r.strictInputsModuleDir)
+		}
+
 		// Add copy rules to the manifest to copy each output file from the sbox directory.
 		// to the output directory after running the commands.
 		sboxOutputs := make([]string, len(outputs))
@@ -1307,6 +1348,57 @@ func RuleBuilderSboxProtoForTests(t *testing.T, params TestingBuildParams) *sbox
 	return &manifest
 }
 
+var sboxManifestArgRegexp = regexp.MustCompile(`--manifest (\S+)`)
+
+// SboxCommands returns the commands in the sbox manifest of a RuleBuilder rule that uses sbox, with
+// the contents of the rsp files inlined in place of the "@<rsp file>" arguments as in
+// ExpandedCommand.  The paths listed in the rsp files are mapped into the sandbox the same way sbox
+// maps them.
+func (p TestingBuildParams) SboxCommands(t *testing.T) []string {
+	t.Helper()
+	match := sboxManifestArgRegexp.FindStringSubmatch(p.ExpandedCommand())
+	if match == nil {
+		t.Fatalf("%s is not a sbox rule", p.Rule)
+		return nil
+	}
+	manifestParams, _ := p.component.maybeBuildParamsFromOutput(match[1])
+	if manifestParams.Rule == nil {
+		t.Fatalf("couldn't find the rule that writes the sbox manifest %s", match[1])
+		return nil
+	}
+	manifest := RuleBuilderSboxProtoForTests(t, manifestParams)
+
+	var commands []string
+	for _, command := range manifest.Commands {
+		// Maps the paths of the rsp files on the command line back to the rsp files, and the paths
+		// listed in them into the sandbox.
+		sandboxRspFiles := make(map[string]string)
+		var mappings []*sbox_proto.PathMapping
+		for _, rspFile := range command.RspFiles {
+			for _, mapping := range rspFile.PathMappings {
+				if rel, isRel, _ := maybeRelErr(mapping.GetFrom(), rspFile.GetFile()); isRel {
+					sandboxRspFiles[filepath.Join(sboxSandboxBaseDir, mapping.GetTo(), rel)] = rspFile.GetFile()
+					break
+				}
+			}
+			mappings = append(mappings, rspFile.PathMappings...)
+		}
+		mapRspFile := func(path string) string {
+			if rspFile, ok := sandboxRspFiles[path]; ok {
+				return rspFile
+			}
+			for _, mapping := range mappings {
+				if rel, isRel, _ := maybeRelErr(mapping.GetFrom(), path); isRel {
+					return filepath.Join(mapping.GetTo(), rel)
+				}
+			}
+			return path
+		}
+		commands = append(commands, p.inlineRspFiles(command.GetCommand(), mapRspFile))
+	}
+	return commands
+}
+
 func ninjaNameEscape(s string) string {
 	b := []byte(s)
 	escaped := false
@@ -720,6 +720,52 @@ func TestRuleBuilder_Build(t *testing.T) {
 	})
 }
 
+func TestRuleBuilderExpandedCommand(t *testing.T) {
+	bp := `
+		rule_builder_test {
+			name: "foo",
+			srcs: ["in"],
+		}
+		rule_builder_test {
+			name: "foo_sbox",
+			srcs: ["in"],
+			sbox: true,
+		}
+		rule_builder_test {
+			name: "foo_sbox_inputs",
+			srcs: ["in"],
+			sbox: true,
+			sbox_inputs: true,
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForRuleBuilderTest,
+		FixtureWithRootAndroidBp(bp),
+		MockFS{"in": nil, "cp": nil}.AddToFixture(),
+	).RunTest(t)
+
+	t.Run("module", func(t *testing.T) {
+		command := result.ModuleForTests("foo", "").Rule("rule").ExpandedCommand()
+		AssertStringDoesContain(t, "expanded command", command,
+			"cp in out/soong/.intermediates/foo/gen/foo rsp_in rsp_in2")
+	})
+
+	t.Run("sbox", func(t *testing.T) {
+		params := result.ModuleForTests("foo_sbox", "").Output("gen/foo_sbox")
+		AssertStringDoesContain(t, "expanded command", params.ExpandedCommand(),
+			"--manifest out/soong/.intermediates/foo_sbox/sbox.textproto")
+		AssertArrayString(t, "sbox commands",
+			[]string{"cp in __SBOX_SANDBOX_DIR__/out/foo_sbox rsp_in rsp_in2"}, params.SboxCommands(t))
+	})
+
+	t.Run("sbox_inputs", func(t *testing.T) {
+		params := result.ModuleForTests("foo_sbox_inputs", "").Output("gen/foo_sbox_inputs")
+		AssertArrayString(t, "sbox commands",
+			[]string{"cp in __SBOX_SANDBOX_DIR__/out/foo_sbox_inputs rsp_in rsp_in2"}, params.SboxCommands(t))
+	})
+}
+
 func TestRuleBuilderHashInputs(t *testing.T) {
 	// The basic idea here is to verify that the command (in the case of a
 	// non-sbox rule) or the sbox textproto manifest contain a hash of the
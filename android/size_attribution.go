@@ -0,0 +1,155 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/blueprint"
+)
+
+// The size attribution report maps every file that Soong installs on a device partition to the
+// module that installs it, the team that owns the module and the partition, so that the size of the
+// images can be budgeted per team.  Files inside an apex are attributed to the team of the module
+// they come from, or to the team of the apex if that module has no team, instead of the apex file
+// itself.
+//
+// Analysis only writes out/soong/size_attribution_files.csv, which lists for every file the path
+// whose size is attributed to it.  The sizes are read at ninja time by the size_attribution tool,
+// which writes out/soong/size_attribution.csv with the size of every file and
+// out/soong/size_attribution_summary.csv with the total size per team and partition.  Both are
+// built by the size_attribution phony target.
+
+func init() {
+	RegisterSizeAttributionBuildComponents(InitRegistrationContext)
+}
+
+func RegisterSizeAttributionBuildComponents(ctx RegistrationContext) {
+	ctx.RegisterSingletonType("size_attribution", sizeAttributionSingletonFactory)
+}
+
+var (
+	_ = pctx.HostBinToolVariable("sizeAttributionCmd", "size_attribution")
+
+	sizeAttributionRule = pctx.AndroidStaticRule("sizeAttribution", blueprint.RuleParams{
+		Command:     "${sizeAttributionCmd} --files $in --summary $summary -o $out",
+		CommandDeps: []string{"${sizeAttributionCmd}"},
+	}, "summary")
+)
+
+// SizeAttributionContent is a file inside a SizeAttributionContainer.
+type SizeAttributionContent struct {
+	// Path is the path of the file inside the container.
+	Path string
+
+	// BuiltFile is the file whose size is attributed.
+	BuiltFile Path
+
+	// Name is the name of the module that the file comes from.
+	Name string
+
+	// Module is the module that the file comes from, or nil if the file is generated by the
+	// container.
+	Module Module
+}
+
+// SizeAttributionContainer is implemented by modules whose installed file, like an apex, contains
+// the files of other modules.
+type SizeAttributionContainer interface {
+	// SizeAttributionContents returns the installed file that contains the files of other modules,
+	// and the files it contains.
+	SizeAttributionContents() (InstallPath, []SizeAttributionContent)
+}
+
+func sizeAttributionSingletonFactory() Singleton {
+	return &sizeAttributionSingleton{}
+}
+
+type sizeAttributionSingleton struct{}
+
+// sizeAttributionFile is a line of the size attribution report.
+type sizeAttributionFile struct {
+	file, module, team, partition string
+	source                        Path
+}
+
+func (s *sizeAttributionSingleton) GenerateBuildActions(ctx SingletonContext) {
+	var files []sizeAttributionFile
+	ctx.VisitAllModules(func(module Module) {
+		if !module.Enabled() || module.Target().Os.Class != Device {
+			return
+		}
+		name, team := ctx.ModuleName(module), module.Team()
+
+		var container InstallPath
+		var contents []SizeAttributionContent
+		if c, ok := module.(SizeAttributionContainer); ok {
+			container, contents = c.SizeAttributionContents()
+		}
+
+		for _, installed := range module.FilesToInstall() {
+			if installed.Partition() == "" {
+				continue
+			}
+			file := "/" + filepath.Join(installed.Partition(), installed.Rel())
+			if len(contents) == 0 || installed.String() != container.String() {
+				files = append(files, sizeAttributionFile{file, name, team, installed.Partition(), installed})
+				continue
+			}
+			for _, content := range contents {
+				contentTeam := team
+				if content.Module != nil && content.Module.Team() != "" {
+					contentTeam = content.Module.Team()
+				}
+				files = append(files, sizeAttributionFile{file + "!/" + content.Path, content.Name,
+					contentTeam, installed.Partition(), content.BuiltFile})
+			}
+		}
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].file < files[j].file
+	})
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write([]string{"file", "module", "team", "partition", "source"})
+	var sources Paths
+	for _, f := range files {
+		w.Write([]string{f.file, f.module, f.team, f.partition, f.source.String()})
+		sources = append(sources, f.source)
+	}
+	w.Flush()
+
+	filesCsv := PathForOutput(ctx, "size_attribution_files.csv")
+	WriteFileRule(ctx, filesCsv, buf.String())
+
+	sizeAttribution := PathForOutput(ctx, "size_attribution.csv")
+	summary := PathForOutput(ctx, "size_attribution_summary.csv")
+	ctx.Build(pctx, BuildParams{
+		Rule:           sizeAttributionRule,
+		Description:    "size attribution",
+		Input:          filesCsv,
+		Implicits:      FirstUniquePaths(sources),
+		Output:         sizeAttribution,
+		ImplicitOutput: summary,
+		Args: map[string]string{
+			"summary": summary.String(),
+		},
+	})
+	ctx.Phony("size_attribution", sizeAttribution, summary)
+}
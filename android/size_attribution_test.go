@@ -0,0 +1,74 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+func TestSizeAttribution(t *testing.T) {
+	bp := `
+		deps {
+			name: "foo",
+			team: "team_foo",
+			owner: "vendor_foo",
+		}
+
+		deps {
+			name: "bar",
+			owner: "vendor_bar",
+			vendor: true,
+		}
+
+		deps {
+			name: "baz",
+			enabled: false,
+		}
+	`
+
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureRegisterWithContext(RegisterSizeAttributionBuildComponents),
+	).RunTestWithBp(t, bp)
+
+	singleton := result.SingletonForTests("size_attribution")
+
+	// Only the files installed on device partitions are attributed, to the team of their module or
+	// to its owner.
+	filesCsv := singleton.Output("size_attribution_files.csv")
+	AssertStringEquals(t, "size_attribution_files.csv", ""+
+		"file,module,team,partition,source\n"+
+		"/system/foo,foo,team_foo,system,out/soong/target/product/test_device/system/foo\n"+
+		"/system/symlinks/foo,foo,team_foo,system,out/soong/target/product/test_device/system/symlinks/foo\n"+
+		"/vendor/bar,bar,vendor_bar,vendor,out/soong/target/product/test_device/vendor/bar\n"+
+		"/vendor/symlinks/bar,bar,vendor_bar,vendor,out/soong/target/product/test_device/vendor/symlinks/bar\n",
+		StringRelativeToTop(result.Config, ContentFromFileRuleForTests(t, filesCsv)))
+
+	// The sizes are read from the installed files when the report is built.
+	rule := singleton.Rule("sizeAttribution")
+	AssertPathRelativeToTopEquals(t, "input", "out/soong/size_attribution_files.csv", rule.Input)
+	AssertPathsRelativeToTopEquals(t, "implicits", []string{
+		"out/soong/target/product/test_device/system/foo",
+		"out/soong/target/product/test_device/system/symlinks/foo",
+		"out/soong/target/product/test_device/vendor/bar",
+		"out/soong/target/product/test_device/vendor/symlinks/bar",
+	}, rule.Implicits)
+	AssertPathRelativeToTopEquals(t, "output", "out/soong/size_attribution.csv", rule.Output)
+	AssertPathRelativeToTopEquals(t, "summary", "out/soong/size_attribution_summary.csv", rule.ImplicitOutput)
+	AssertStringDoesContain(t, "command", rule.RuleParams.Command, "${sizeAttributionCmd} --files $in --summary $summary -o $out")
+	AssertStringEquals(t, "summary arg", "out/soong/size_attribution_summary.csv",
+		StringPathRelativeToTop(result.Config.SoongOutDir(), rule.Args["summary"]))
+}
@@ -19,8 +19,10 @@ package android
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"text/scanner"
@@ -388,8 +390,24 @@ func loadSoongConfigModuleTypeDefinition(ctx LoadHookContext, from string) map[s
 			return (map[string]blueprint.ModuleFactory)(nil)
 		}
 		defer r.Close()
+		contents, err := io.ReadAll(r)
+		if err != nil {
+			ctx.PropertyErrorf("from", "failed to read %q: %s", from, err)
+			return (map[string]blueprint.ModuleFactory)(nil)
+		}
+
+		globalModuleTypes := ctx.moduleFactories()
+
+		// The files that module types are imported from are parsed in every run, use the bp cache
+		// to skip parsing the files that did not change.
+		cache := bpCacheForConfig(ctx.Config(), SortedKeys(globalModuleTypes))
+		file, errs := cache.parseAndEval(from, contents)
+		if len(errs) > 0 {
+			reportErrors(ctx, from, errs...)
+			return (map[string]blueprint.ModuleFactory)(nil)
+		}
 
-		mtDef, errs := soongconfig.Parse(r, from)
+		mtDef, errs := soongconfig.ParseFile(file)
 		if len(errs) > 0 {
 			reportErrors(ctx, from, errs...)
 			return (map[string]blueprint.ModuleFactory)(nil)
@@ -399,8 +417,6 @@ func loadSoongConfigModuleTypeDefinition(ctx LoadHookContext, from string) map[s
 			ctx.Config().Bp2buildSoongConfigDefinitions.AddVars(mtDef)
 		}
 
-		globalModuleTypes := ctx.moduleFactories()
-
 		factories := make(map[string]blueprint.ModuleFactory)
 
 		for name, moduleType := range mtDef.ModuleTypes {
@@ -499,3 +515,113 @@ func configModuleFactory(factory blueprint.ModuleFactory, moduleType *soongconfi
 		return module, props
 	}
 }
+
+// SoongConfigModuleTypeForTests describes a soong_config_module_type and the variables that it
+// uses for FixtureAddSoongConfigModuleType.
+type SoongConfigModuleTypeForTests struct {
+	// The name of the soong_config_module_type, e.g. "acme_cc_defaults".
+	Name string
+
+	// The module type that the soong_config_module_type wraps, e.g. "cc_defaults".
+	ModuleType string
+
+	// The soong config namespace of the variables.
+	Namespace string
+
+	// The names of the bool variables.
+	BoolVariables []string
+
+	// The string variables, mapped to the values that each of them can take.
+	StringVariables map[string][]string
+
+	// The names of the value variables.
+	ValueVariables []string
+
+	// The properties of ModuleType that the variables can set.
+	Properties []string
+}
+
+// bp returns the declarations of the soong_config_module_type and its string variables.
+func (m SoongConfigModuleTypeForTests) bp() string {
+	quote := func(list []string) string {
+		quoted := make([]string, 0, len(list))
+		for _, s := range list {
+			quoted = append(quoted, fmt.Sprintf("%q", s))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	stringVariables := SortedStringKeys(m.StringVariables)
+	var bp strings.Builder
+	fmt.Fprintf(&bp, "\nsoong_config_module_type {\n")
+	fmt.Fprintf(&bp, "    name: %q,\n", m.Name)
+	fmt.Fprintf(&bp, "    module_type: %q,\n", m.ModuleType)
+	fmt.Fprintf(&bp, "    config_namespace: %q,\n", m.Namespace)
+	fmt.Fprintf(&bp, "    bool_variables: %s,\n", quote(m.BoolVariables))
+	fmt.Fprintf(&bp, "    variables: %s,\n", quote(stringVariables))
+	fmt.Fprintf(&bp, "    value_variables: %s,\n", quote(m.ValueVariables))
+	fmt.Fprintf(&bp, "    properties: %s,\n", quote(m.Properties))
+	fmt.Fprintf(&bp, "}\n")
+	for _, variable := range stringVariables {
+		fmt.Fprintf(&bp, "\nsoong_config_string_variable {\n")
+		fmt.Fprintf(&bp, "    name: %q,\n", variable)
+		fmt.Fprintf(&bp, "    values: %s,\n", quote(m.StringVariables[variable]))
+		fmt.Fprintf(&bp, "}\n")
+	}
+	return bp.String()
+}
+
+// FixtureAddSoongConfigModuleType registers the soong config module types and declares the
+// soong_config_module_type in the root Android.bp file, so that the Android.bp of the test can use
+// it directly.
+func FixtureAddSoongConfigModuleType(moduleType SoongConfigModuleTypeForTests) FixturePreparer {
+	return GroupFixturePreparers(
+		PrepareForTestWithSoongConfigModuleBuildComponents,
+		FixtureModifyMockFS(func(fs MockFS) {
+			// Copy the contents so that a root Android.bp shared with other fixtures is not modified.
+			bp := append([]byte(nil), fs["Android.bp"]...)
+			fs["Android.bp"] = append(bp, moduleType.bp()...)
+		}),
+	)
+}
+
+// FixtureSetSoongConfigVariables sets the values of the variables in the soong config namespace,
+// the same way that the SOONG_CONFIG_<namespace>_<variable> make variables of a product do.
+func FixtureSetSoongConfigVariables(namespace string, vars map[string]string) FixturePreparer {
+	return FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+		vendorVars := make(map[string]map[string]string, len(variables.VendorVars)+1)
+		for ns, nsVars := range variables.VendorVars {
+			vendorVars[ns] = nsVars
+		}
+		nsVars := make(map[string]string, len(vendorVars[namespace])+len(vars))
+		for k, v := range vendorVars[namespace] {
+			nsVars[k] = v
+		}
+		for k, v := range vars {
+			nsVars[k] = v
+		}
+		vendorVars[namespace] = nsVars
+		variables.VendorVars = vendorVars
+	})
+}
+
+// FixtureSetSoongConfigBoolVariables sets the values of the bool variables in the soong config
+// namespace, as for FixtureSetSoongConfigVariables.
+func FixtureSetSoongConfigBoolVariables(namespace string, vars map[string]bool) FixturePreparer {
+	values := make(map[string]string, len(vars))
+	for k, v := range vars {
+		values[k] = strconv.FormatBool(v)
+	}
+	return FixtureSetSoongConfigVariables(namespace, values)
+}
+
+// FixtureSetSoongConfigListVariables sets the values of the list variables in the soong config
+// namespace, as for FixtureSetSoongConfigVariables. The lists are space separated, as they would be
+// when set from make.
+func FixtureSetSoongConfigListVariables(namespace string, vars map[string][]string) FixturePreparer {
+	values := make(map[string]string, len(vars))
+	for k, v := range vars {
+		values[k] = strings.Join(v, " ")
+	}
+	return FixtureSetSoongConfigVariables(namespace, values)
+}
@@ -377,6 +377,108 @@ func TestNonExistentPropertyInSoongConfigModule(t *testing.T) {
 	})).RunTest(t)
 }
 
+func TestSoongConfigFixturePreparers(t *testing.T) {
+	bp := `
+		acme_test {
+			name: "foo",
+			cflags: ["-DGENERIC"],
+			soong_config_variables: {
+				feature: {
+					cflags: ["-DFEATURE"],
+					conditions_default: {
+						cflags: ["-DNO_FEATURE"],
+					},
+				},
+				size: {
+					cflags: ["-DSIZE=%s"],
+					conditions_default: {
+						cflags: ["-DSIZE=DEFAULT"],
+					},
+				},
+				archs: {
+					cflags: ["-DARCHS=%s"],
+				},
+				board: {
+					soc_a: {
+						cflags: ["-DSOC_A"],
+					},
+					conditions_default: {
+						cflags: ["-DSOC_DEFAULT"],
+					},
+				},
+			},
+		}
+	`
+
+	preparer := GroupFixturePreparers(
+		prepareForSoongConfigTestModule,
+		FixtureAddSoongConfigModuleType(SoongConfigModuleTypeForTests{
+			Name:            "acme_test",
+			ModuleType:      "test",
+			Namespace:       "acme",
+			BoolVariables:   []string{"feature"},
+			StringVariables: map[string][]string{"board": {"soc_a", "soc_b"}},
+			ValueVariables:  []string{"size", "archs"},
+			Properties:      []string{"cflags"},
+		}),
+		FixtureWithRootAndroidBp(bp),
+	)
+
+	testCases := []struct {
+		name     string
+		preparer FixturePreparer
+		expected []string
+	}{
+		{
+			name:     "unset",
+			preparer: NullFixturePreparer,
+			expected: []string{"-DGENERIC", "-DNO_FEATURE", "-DSIZE=DEFAULT", "-DSOC_DEFAULT"},
+		},
+		{
+			name: "set",
+			preparer: GroupFixturePreparers(
+				FixtureSetSoongConfigBoolVariables("acme", map[string]bool{"feature": true}),
+				FixtureSetSoongConfigVariables("acme", map[string]string{"size": "42", "board": "soc_a"}),
+				FixtureSetSoongConfigListVariables("acme", map[string][]string{"archs": {"arm", "x86"}}),
+			),
+			expected: []string{"-DGENERIC", "-DFEATURE", "-DSIZE=42", "-DARCHS=arm x86", "-DSOC_A"},
+		},
+		{
+			name: "bool false",
+			preparer: GroupFixturePreparers(
+				FixtureSetSoongConfigBoolVariables("acme", map[string]bool{"feature": false}),
+				FixtureSetSoongConfigVariables("acme", map[string]string{"board": "soc_b"}),
+			),
+			// soc_b has no cflags of its own so it falls back to the conditions_default.
+			expected: []string{"-DGENERIC", "-DNO_FEATURE", "-DSIZE=DEFAULT", "-DSOC_DEFAULT"},
+		},
+		{
+			name:     "other namespace",
+			preparer: FixtureSetSoongConfigBoolVariables("other", map[string]bool{"feature": true}),
+			expected: []string{"-DGENERIC", "-DNO_FEATURE", "-DSIZE=DEFAULT", "-DSOC_DEFAULT"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := GroupFixturePreparers(preparer, tc.preparer).RunTest(t)
+			foo := result.ModuleForTests("foo", "").Module().(*soongConfigTestModule)
+			AssertDeepEquals(t, "foo cflags", tc.expected, foo.props.Cflags)
+		})
+	}
+
+	t.Run("merged namespaces", func(t *testing.T) {
+		result := GroupFixturePreparers(
+			FixtureSetSoongConfigVariables("acme", map[string]string{"board": "soc_a"}),
+			FixtureSetSoongConfigVariables("acme", map[string]string{"size": "42"}),
+			FixtureSetSoongConfigVariables("other", map[string]string{"size": "1"}),
+		).RunTest(t)
+		AssertStringEquals(t, "acme board", "soc_a", result.Config.VendorConfig("acme").String("board"))
+		AssertStringEquals(t, "acme size", "42", result.Config.VendorConfig("acme").String("size"))
+		AssertStringEquals(t, "other size", "1", result.Config.VendorConfig("other").String("size"))
+	})
+}
+
 func TestDuplicateStringValueInSoongConfigStringVariable(t *testing.T) {
 	bp := `
 		soong_config_string_variable {
@@ -503,3 +605,123 @@ func TestSoongConfigModuleSingletonModule(t *testing.T) {
 		})
 	}
 }
+
+func TestSoongConfigModuleEnabledAndVisibility(t *testing.T) {
+	bp := `
+		soong_config_string_variable {
+			name: "board",
+			values: ["soc_a", "soc_b"],
+		}
+
+		soong_config_module_type {
+			name: "acme_test",
+			module_type: "test",
+			config_namespace: "acme",
+			variables: ["board"],
+			properties: ["cflags"],
+		}
+
+		acme_test {
+			name: "foo",
+			cflags: ["-DGENERIC"],
+			soong_config_variables: {
+				board: {
+					soc_a: {
+						cflags: ["-DSOC_A"],
+						visibility: ["//visibility:public"],
+					},
+					soc_b: {
+						enabled: false,
+					},
+					conditions_default: {
+						visibility: ["//visibility:private"],
+					},
+				},
+			},
+		}
+
+		test {
+			name: "bar",
+			required: ["foo"],
+		}
+	`
+
+	for _, test := range []struct {
+		name               string
+		board              map[string]string
+		expectedEnabled    bool
+		expectedVisibility []string
+	}{
+		{
+			name:               "soc_a",
+			board:              map[string]string{"board": "soc_a"},
+			expectedEnabled:    true,
+			expectedVisibility: []string{"//visibility:public"},
+		},
+		{
+			name:            "soc_b",
+			board:           map[string]string{"board": "soc_b"},
+			expectedEnabled: false,
+		},
+		{
+			name:               "conditions_default",
+			board:              map[string]string{},
+			expectedEnabled:    true,
+			expectedVisibility: []string{"//visibility:private"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			result := GroupFixturePreparers(
+				PrepareForTestWithDefaults,
+				PrepareForTestWithSoongConfigModuleBuildComponents,
+				prepareForSoongConfigTestModule,
+				FixtureWithRootAndroidBp(bp),
+				FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+					variables.VendorVars = map[string]map[string]string{"acme": test.board}
+				}),
+			).RunTest(t)
+
+			foo := result.ModuleForTests("foo", "").Module()
+			AssertBoolEquals(t, "foo enabled", test.expectedEnabled, foo.Enabled())
+			AssertDeepEquals(t, "foo visibility", test.expectedVisibility, foo.base().commonProperties.Visibility)
+
+			// The module that requires foo does not fail when foo is disabled.
+			bar := result.ModuleForTests("bar", "").Module()
+			AssertDeepEquals(t, "bar required", []string{"foo"}, bar.RequiredModuleNames())
+		})
+	}
+}
+
+func TestSoongConfigModuleEnabledValueVariable(t *testing.T) {
+	bp := `
+		soong_config_module_type {
+			name: "acme_test",
+			module_type: "test",
+			config_namespace: "acme",
+			value_variables: ["board"],
+			properties: ["cflags"],
+		}
+
+		acme_test {
+			name: "foo",
+			soong_config_variables: {
+				board: {
+					cflags: ["-DBOARD=%s"],
+					enabled: false,
+				},
+			},
+		}
+	`
+
+	GroupFixturePreparers(
+		PrepareForTestWithDefaults,
+		PrepareForTestWithSoongConfigModuleBuildComponents,
+		prepareForSoongConfigTestModule,
+		FixtureWithRootAndroidBp(bp),
+		FixtureModifyProductVariables(func(variables FixtureProductVariables) {
+			variables.VendorVars = map[string]map[string]string{"acme": {"board": "soc_a"}}
+		}),
+	).ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+		`soong_config_variables.board.enabled: enabled is a bool and cannot be set by a value variable`,
+	)).RunTest(t)
+}
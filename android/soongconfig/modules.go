@@ -42,6 +42,12 @@ func Parse(r io.Reader, from string) (*SoongConfigDefinition, []error) {
 		return nil, errs
 	}
 
+	return ParseFile(file)
+}
+
+// ParseFile loads module types from the evaluated AST of an Android.bp file.
+func ParseFile(file *parser.File) (*SoongConfigDefinition, []error) {
+	var errs []error
 	mtDef := &SoongConfigDefinition{
 		ModuleTypes: make(map[string]*ModuleType),
 		variables:   make(map[string]soongConfigVariable),
@@ -547,9 +553,22 @@ type ModuleType struct {
 	variableNames        []string
 }
 
+// implicitAffectableProperties are the properties that every soong config module type can set
+// without listing them in its properties, so that a module can be disabled or hidden for some
+// values of a variable.  They are applied by the load hook, before any dependency is added, so a
+// disabled module does not cause dependency errors in modules that only require it.
+var implicitAffectableProperties = []string{"enabled", "visibility"}
+
 func newModuleType(props *ModuleTypeProperties) (*ModuleType, []error) {
+	affectableProperties := append([]string(nil), props.Properties...)
+	for _, p := range implicitAffectableProperties {
+		if !InList(p, affectableProperties) {
+			affectableProperties = append(affectableProperties, p)
+		}
+	}
+
 	mt := &ModuleType{
-		affectableProperties: props.Properties,
+		affectableProperties: affectableProperties,
 		ConfigNamespace:      props.Config_namespace,
 		BaseModuleType:       props.Module_type,
 		variableNames:        props.Variables,
@@ -778,6 +797,9 @@ func (s *valueVariable) PropertiesToApply(config SoongConfig, values reflect.Val
 	if !values.IsValid() || values.Elem().IsZero() {
 		return nil, nil
 	}
+	if v := values.Elem().Elem(); enabledIsSet(v) || enabledIsSet(conditionsDefaultField(v).Elem()) {
+		return nil, fmt.Errorf("soong_config_variables.%s.enabled: enabled is a bool and cannot be set by a value variable, use a bool or string variable instead", s.variable)
+	}
 	if !config.IsSet(s.variable) {
 		return conditionsDefaultField(values.Elem().Elem()).Interface(), nil
 	}
@@ -820,6 +842,15 @@ func (s *valueVariable) PropertiesToApply(config SoongConfig, values reflect.Val
 	return values.Interface(), nil
 }
 
+// enabledIsSet returns true if v is a struct of affectable properties in which enabled is set.
+func enabledIsSet(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	f := v.FieldByName("Enabled")
+	return f.IsValid() && !f.IsNil()
+}
+
 func printfIntoProperty(propertyValue reflect.Value, configValue string) error {
 	s := propertyValue.String()
 
@@ -16,7 +16,11 @@ package android
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime/debug"
 	"strings"
 	"testing"
 )
@@ -59,18 +63,74 @@ func AssertStringEquals(t *testing.T, message string, expected string, actual st
 	}
 }
 
+// updateGoldenFilesEnv is the environment variable that is set to true when running the tests to
+// rewrite the golden files that AssertStringEqualsGoldenFile compares against instead of failing.
+// It is an environment variable rather than a flag as this file is also built into soong_build.
+const updateGoldenFilesEnv = "SOONG_UPDATE_GOLDEN_FILES"
+
+func updateGoldenFiles() bool {
+	return os.Getenv(updateGoldenFilesEnv) == "true"
+}
+
+// AssertStringEqualsGoldenFile checks if the actual value is equal to the contents of the golden
+// file at goldenPath in the testdata directory of the test's package, ignoring trailing whitespace,
+// and if it is not then it reports an error prefixed with the supplied message and including a
+// unified diff from the golden file to the actual value.  When the test is run with
+// SOONG_UPDATE_GOLDEN_FILES=true it rewrites the golden file with the actual value instead.
+func AssertStringEqualsGoldenFile(t *testing.T, message, goldenPath, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", goldenPath)
+	var write func([]byte) error
+	if updateGoldenFiles() {
+		write = func(contents []byte) error {
+			if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+				return err
+			}
+			return os.WriteFile(path, contents, 0666)
+		}
+	}
+	assertStringEqualsGoldenFile(t, message, path, actual, func() ([]byte, error) {
+		return os.ReadFile(path)
+	}, write)
+}
+
+// AssertPathEquals checks if the expected value is equal to the actual Path, which may also be a
+// WritablePath, normalized by PathRelativeToTop so that it does not depend on the temporary build
+// directory of the test, e.g. out/soong/.intermediates/foo/android_common/foo.jar.  A nil Path is
+// normalized to "<nil path>".  If they are not equal then it reports an error prefixed with the
+// supplied message and including both the normalized and the raw actual path.
+func AssertPathEquals(t *testing.T, message string, expected string, actual Path) {
+	t.Helper()
+	assertPathEquals(t, message, expected, actual)
+}
+
+// AssertOptionalPathEquals checks if the expected value is equal to the Path embedded in the actual
+// OptionalPath as for AssertPathEquals, or if expected is "" then that the OptionalPath is invalid.
+func AssertOptionalPathEquals(t *testing.T, message string, expected string, actual OptionalPath) {
+	t.Helper()
+	assertOptionalPathEquals(t, message, expected, actual)
+}
+
+// AssertPathsEquals checks if the expected values are equal to the actual Paths normalized as for
+// AssertPathEquals.  Nil and empty Paths are both equal to an empty expected list.  WritablePaths
+// can be compared after converting them with WritablePaths.Paths.
+func AssertPathsEquals(t *testing.T, message string, expected []string, actual Paths) {
+	t.Helper()
+	assertPathsEquals(t, message, expected, actual)
+}
+
 // AssertPathRelativeToTopEquals checks if the expected value is equal to the result of calling
-// PathRelativeToTop on the actual Path.
+// PathRelativeToTop on the actual Path.  It is equivalent to AssertPathEquals.
 func AssertPathRelativeToTopEquals(t *testing.T, message string, expected string, actual Path) {
 	t.Helper()
-	AssertStringEquals(t, message, expected, PathRelativeToTop(actual))
+	assertPathEquals(t, message, expected, actual)
 }
 
 // AssertPathsRelativeToTopEquals checks if the expected value is equal to the result of calling
-// PathsRelativeToTop on the actual Paths.
+// PathsRelativeToTop on the actual Paths.  It is equivalent to AssertPathsEquals.
 func AssertPathsRelativeToTopEquals(t *testing.T, message string, expected []string, actual Paths) {
 	t.Helper()
-	AssertDeepEquals(t, message, expected, PathsRelativeToTop(actual))
+	assertPathsEquals(t, message, expected, actual)
 }
 
 // AssertStringPathRelativeToTopEquals checks if the expected value is equal to the result of calling
@@ -99,6 +159,26 @@ func AssertErrorMessageEquals(t *testing.T, message string, expected string, act
 	}
 }
 
+// AssertErrorMessageEqualsIgnoringPosition checks if the error is not nil and has the expected
+// message once the "file:line:col: " positions have been stripped from it as for
+// StripErrorPositions. The expected message may also omit the `module "foo" variant "bar": `
+// prefixes that ModuleErrorf and PropertyErrorf add. If it does not match then this reports an
+// error prefixed with the supplied message and including a reason for why it failed.
+func AssertErrorMessageEqualsIgnoringPosition(t *testing.T, message string, expected string, actual error) {
+	t.Helper()
+	assertErrorMessageEqualsIgnoringPosition(t, message, expected, actual)
+}
+
+// AssertErrorMessageMatches checks if the error is not nil and has a message that matches the
+// regular expression pattern, either as is or once it has been stripped as for
+// StripErrorPositions, so that the pattern can be anchored to the start of the actual message. If
+// it does not match then this reports an error prefixed with the supplied message and including a
+// reason for why it failed.
+func AssertErrorMessageMatches(t *testing.T, message string, pattern string, actual error) {
+	t.Helper()
+	assertErrorMessageMatches(t, message, pattern, actual)
+}
+
 // AssertTrimmedStringEquals checks if the expected and actual values are the same after trimming
 // leading and trailing spaces from them both. If they are not then it reports an error prefixed
 // with the supplied message and including a reason for why it failed.
@@ -151,9 +231,15 @@ func AssertStringListContains(t *testing.T, message string, list []string, s str
 // then it reports an error prefixed with the supplied message and including a reason for why it failed.
 func AssertStringListDoesNotContain(t *testing.T, message string, list []string, s string) {
 	t.Helper()
-	if InList(s, list) {
-		t.Errorf("%s: unexpectedly found %q within %q", message, s, list)
-	}
+	assertStringListDoesNotContain(t, message, list, s)
+}
+
+// AssertStringListContainsAllOf checks if the list of strings contains all the expected strings. If
+// it does not then it reports an error prefixed with the supplied message and listing every missing
+// string.
+func AssertStringListContainsAllOf(t *testing.T, message string, list []string, expected []string) {
+	t.Helper()
+	assertStringListContainsAllOf(t, message, list, expected)
 }
 
 // AssertStringContainsEquals checks if the string contains or does not contain the substring, given
@@ -185,34 +271,519 @@ func AssertArrayString(t *testing.T, message string, expected, actual []string)
 	}
 }
 
+// AssertArrayStringIgnoringOrder checks if the expected and actual values contain the same strings,
+// the same number of times, in any order. If they do not then it reports an error prefixed with the
+// supplied message and listing the strings that are missing from, or unexpected in, actual.
+func AssertArrayStringIgnoringOrder(t *testing.T, message string, expected, actual []string) {
+	t.Helper()
+	assertArrayStringIgnoringOrder(t, message, expected, actual)
+}
+
 // AssertDeepEquals checks if the expected and actual values are equal using reflect.DeepEqual and
 // if they are not then it reports an error prefixed with the supplied message and including a
-// reason for why it failed.
+// reason for why it failed. Differences between structs, slices, arrays, maps and pointers are
+// reported as the paths of the exported fields, elements and map keys that differ, e.g.
+// Properties.Srcs[2]: expected "a.rs", got "b.rs".
 func AssertDeepEquals(t *testing.T, message string, expected interface{}, actual interface{}) {
 	t.Helper()
-	if !reflect.DeepEqual(actual, expected) {
-		t.Errorf("%s: expected:\n  %#v\n got:\n  %#v", message, expected, actual)
-	}
+	assertDeepEquals(t, message, expected, actual)
 }
 
 // AssertPanicMessageContains checks that the supplied function panics as expected and the message
-// obtained by formatting the recovered value as a string contains the expected contents.
+// obtained by formatting the recovered value as a string contains the expected contents. If it does
+// not then it reports an error including the actual panic message and the stack trace of the panic.
 func AssertPanicMessageContains(t *testing.T, message, expectedMessageContents string, funcThatShouldPanic func()) {
 	t.Helper()
-	panicked := false
-	var recovered interface{}
-	func() {
-		defer func() {
-			if recovered = recover(); recovered != nil {
-				panicked = true
+	assertPanicMessageContains(t, message, expectedMessageContents, funcThatShouldPanic)
+}
+
+// AssertPanicMessageMatches checks that the supplied function panics as expected and the message
+// obtained by formatting the recovered value as a string matches the expected regular expression.
+// If it does not then it reports an error including the actual panic message and the stack trace of
+// the panic.
+func AssertPanicMessageMatches(t *testing.T, message, expectedMessagePattern string, funcThatShouldPanic func()) {
+	t.Helper()
+	assertPanicMessageMatches(t, message, expectedMessagePattern, funcThatShouldPanic)
+}
+
+// AssertModuleHasRule checks that the module has a call to ctx.Build with a rule whose name
+// contains the supplied rule name, as for TestingModule.Rule. If it does not then it reports an
+// error listing the rules the module does have.
+func AssertModuleHasRule(t *testing.T, message string, module TestingModule, rule string) {
+	t.Helper()
+	assertHasRule(t, message, module.baseTestingComponent, rule)
+}
+
+// errorReporter is the part of *testing.T used by the assertions that are implemented separately,
+// so that their failure messages can be tested.
+type errorReporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// recoverPanic calls f and returns whether it panicked, the recovered value formatted as a string,
+// and the stack trace of the panic.
+func recoverPanic(f func()) (panicked bool, panicMessage string, stack []byte) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			panicked = true
+			stack = debug.Stack()
+			switch v := recovered.(type) {
+			case error:
+				panicMessage = v.Error()
+			case fmt.Stringer:
+				panicMessage = v.String()
+			case string:
+				panicMessage = v
+			default:
+				panicMessage = fmt.Sprintf("%v", v)
 			}
-		}()
-		funcThatShouldPanic()
+		}
 	}()
+	f()
+	return false, "", nil
+}
+
+// errorPositionPattern matches a "file:line:col: " position that blueprint prefixes errors with,
+// either at the start of the message or of an error nested within it.
+var errorPositionPattern = regexp.MustCompile(`(^|: |\n)[^\s:"]+:\d+(?::\d+)?: `)
+
+// errorModulePattern matches a `module "foo" variant "bar": ` prefix that ModuleErrorf and
+// PropertyErrorf add to errors, either at the start of the message or of an error nested within it.
+var errorModulePattern = regexp.MustCompile(`(^|: |\n)module "[^"]*"(?: variant "[^"]*")?: `)
+
+// StripErrorPositions returns the error message with the "file:line:col: " positions removed from
+// its start and from the start of every error nested within it, so that tests do not depend on
+// where a module was defined within a bp file. If stripModules is true then the
+// `module "foo" variant "bar": ` prefixes are removed as well.
+func StripErrorPositions(message string, stripModules bool) string {
+	for {
+		stripped := errorPositionPattern.ReplaceAllString(message, "$1")
+		if stripModules {
+			stripped = errorModulePattern.ReplaceAllString(stripped, "$1")
+		}
+		if stripped == message {
+			return message
+		}
+		message = stripped
+	}
+}
+
+// errorMessageMatches returns whether the error message matches the regular expression, either as
+// is, with the positions stripped or with both the positions and the modules stripped.
+func errorMessageMatches(matcher *regexp.Regexp, message string) bool {
+	return matcher.MatchString(message) ||
+		matcher.MatchString(StripErrorPositions(message, false)) ||
+		matcher.MatchString(StripErrorPositions(message, true))
+}
+
+func assertErrorMessageEqualsIgnoringPosition(t errorReporter, message string, expected string, actual error) {
+	t.Helper()
+	if actual == nil {
+		t.Errorf("%s: expected error but was nil", message)
+		return
+	}
+	stripped := StripErrorPositions(actual.Error(), false)
+	if stripped != expected && StripErrorPositions(stripped, true) != expected {
+		t.Errorf("%s: expected %q, actual %q (stripped from %q)", message, expected, stripped, actual.Error())
+	}
+}
+
+func assertErrorMessageMatches(t errorReporter, message string, pattern string, actual error) {
+	t.Helper()
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Errorf("%s: failed to compile regular expression %q because %s", message, pattern, err)
+	} else if actual == nil {
+		t.Errorf("%s: expected error matching %q but was nil", message, pattern)
+	} else if !errorMessageMatches(matcher, actual.Error()) {
+		t.Errorf("%s: expected error matching %q, actual %q", message, pattern, actual.Error())
+	}
+}
+
+func assertPanicMessageContains(t errorReporter, message, expectedMessageContents string, funcThatShouldPanic func()) {
+	t.Helper()
+	panicked, panicMessage, stack := recoverPanic(funcThatShouldPanic)
 	if !panicked {
 		t.Errorf("%s: did not panic", message)
+	} else if !strings.Contains(panicMessage, expectedMessageContents) {
+		t.Errorf("%s: expected panic message containing %q, actual %q, panicked at:\n%s",
+			message, expectedMessageContents, panicMessage, stack)
+	}
+}
+
+func assertPanicMessageMatches(t errorReporter, message, expectedMessagePattern string, funcThatShouldPanic func()) {
+	t.Helper()
+	panicked, panicMessage, stack := recoverPanic(funcThatShouldPanic)
+	if !panicked {
+		t.Errorf("%s: did not panic", message)
+	} else if !regexp.MustCompile(expectedMessagePattern).MatchString(panicMessage) {
+		t.Errorf("%s: expected panic message matching %q, actual %q, panicked at:\n%s",
+			message, expectedMessagePattern, panicMessage, stack)
+	}
+}
+
+func assertHasRule(t errorReporter, message string, component baseTestingComponent, rule string) {
+	t.Helper()
+	if p := component.MaybeRule(rule); p.Rule == nil {
+		rules := component.ruleNames()
+		if len(rules) == 0 {
+			t.Errorf("%s: could not find rule %q, there are no rules", message, rule)
+		} else {
+			t.Errorf("%s: could not find rule %q, the rules are:\n    %s", message, rule, strings.Join(rules, "\n    "))
+		}
+	}
+}
+
+// rawPathString returns the string form of the path, without normalizing it.
+func rawPathString(path Path) string {
+	if path == nil {
+		return "<nil path>"
+	}
+	return path.String()
+}
+
+func assertPathEquals(t errorReporter, message string, expected string, actual Path) {
+	t.Helper()
+	if normalized := PathRelativeToTop(actual); normalized != expected {
+		t.Errorf("%s: expected %q, actual %q (raw %q)", message, expected, normalized, rawPathString(actual))
+	}
+}
+
+func assertOptionalPathEquals(t errorReporter, message string, expected string, actual OptionalPath) {
+	t.Helper()
+	if actual.Valid() {
+		assertPathEquals(t, message, expected, actual.Path())
+	} else if expected != "" {
+		t.Errorf("%s: expected %q, actual invalid optional path: %s", message, expected, actual.InvalidReason())
+	}
+}
+
+func assertPathsEquals(t errorReporter, message string, expected []string, actual Paths) {
+	t.Helper()
+	normalized := PathsRelativeToTop(actual)
+	equal := len(normalized) == len(expected)
+	for i := 0; equal && i < len(expected); i++ {
+		equal = normalized[i] == expected[i]
+	}
+	if !equal {
+		raw := make([]string, len(actual))
+		for i, path := range actual {
+			raw[i] = rawPathString(path)
+		}
+		t.Errorf("%s: expected %q, actual %q (raw %q)", message, expected, normalized, raw)
+	}
+}
+
+func assertStringListDoesNotContain(t errorReporter, message string, list []string, s string) {
+	t.Helper()
+	if InList(s, list) {
+		t.Errorf("%s: unexpectedly found %q within %q", message, s, list)
 	}
+}
+
+func assertStringListContainsAllOf(t errorReporter, message string, list []string, expected []string) {
+	t.Helper()
+	var missing []string
+	for _, s := range expected {
+		if !InList(s, list) {
+			missing = append(missing, s)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("%s: could not find %q within %q", message, missing, list)
+	}
+}
+
+func assertArrayStringIgnoringOrder(t errorReporter, message string, expected, actual []string) {
+	t.Helper()
+	counts := make(map[string]int)
+	for _, s := range expected {
+		counts[s]++
+	}
+	for _, s := range actual {
+		counts[s]--
+	}
+
+	// Report the differences in the order of the lists, repeated as many times as they differ.
+	var missing, unexpected []string
+	for _, s := range expected {
+		if counts[s] > 0 {
+			missing = append(missing, s)
+			counts[s]--
+		}
+	}
+	for _, s := range actual {
+		if counts[s] < 0 {
+			unexpected = append(unexpected, s)
+			counts[s]++
+		}
+	}
+	if len(missing) > 0 || len(unexpected) > 0 {
+		t.Errorf("%s: expected %q, actual %q in any order, missing %q, unexpected %q",
+			message, expected, actual, missing, unexpected)
+	}
+}
+
+func assertDeepEquals(t errorReporter, message string, expected interface{}, actual interface{}) {
+	t.Helper()
+	if reflect.DeepEqual(actual, expected) {
+		return
+	}
+	d := &deepEqualsDiffer{visited: make(map[[2]uintptr]bool)}
+	e, a := reflect.ValueOf(expected), reflect.ValueOf(actual)
+	if e.IsValid() && a.IsValid() && e.Type() == a.Type() && isCompositeKind(e.Kind()) {
+		d.diff("", e, a)
+	}
+	if len(d.diffs) == 0 {
+		// Scalars, values of different types, and values that only differ in unexported fields.
+		t.Errorf("%s: expected:\n  %#v\n got:\n  %#v", message, expected, actual)
+		return
+	}
+	t.Errorf("%s: expected and actual differ:\n  %s", message, strings.Join(d.diffs, "\n  "))
+}
 
-	panicMessage := fmt.Sprintf("%s", recovered)
-	AssertStringDoesContain(t, fmt.Sprintf("%s: panic message", message), panicMessage, expectedMessageContents)
+func isCompositeKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map, reflect.Ptr, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// deepEqualsDiffer collects the paths at which two values of the same type differ.
+type deepEqualsDiffer struct {
+	diffs []string
+
+	// The pairs of expected and actual pointers that have already been compared, to stop at cycles.
+	visited map[[2]uintptr]bool
+}
+
+func (d *deepEqualsDiffer) report(path string, format string, args ...interface{}) {
+	if path == "" {
+		path = "<value>"
+	}
+	d.diffs = append(d.diffs, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (d *deepEqualsDiffer) diff(path string, expected, actual reflect.Value) {
+	if expected.Type() != actual.Type() {
+		d.report(path, "expected %#v of type %s, got %#v of type %s",
+			expected.Interface(), expected.Type(), actual.Interface(), actual.Type())
+		return
+	}
+
+	switch expected.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if expected.IsNil() || actual.IsNil() {
+			if expected.IsNil() != actual.IsNil() {
+				d.report(path, "expected %#v, got %#v", expected.Interface(), actual.Interface())
+			}
+			return
+		}
+		if expected.Kind() == reflect.Ptr {
+			pointers := [2]uintptr{expected.Pointer(), actual.Pointer()}
+			if d.visited[pointers] {
+				return
+			}
+			d.visited[pointers] = true
+		}
+		d.diff(path, expected.Elem(), actual.Elem())
+
+	case reflect.Struct:
+		for i := 0; i < expected.NumField(); i++ {
+			field := expected.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			d.diff(fieldPath, expected.Field(i), actual.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		if expected.Kind() == reflect.Slice && expected.IsNil() != actual.IsNil() {
+			d.report(path, "expected %#v, got %#v", expected.Interface(), actual.Interface())
+			return
+		}
+		for i := 0; i < expected.Len() || i < actual.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			if i >= actual.Len() {
+				d.report(elemPath, "expected %#v, missing", expected.Index(i).Interface())
+			} else if i >= expected.Len() {
+				d.report(elemPath, "unexpected %#v", actual.Index(i).Interface())
+			} else {
+				d.diff(elemPath, expected.Index(i), actual.Index(i))
+			}
+		}
+
+	case reflect.Map:
+		if expected.IsNil() != actual.IsNil() {
+			d.report(path, "expected %#v, got %#v", expected.Interface(), actual.Interface())
+			return
+		}
+		// Report the keys in a stable order.
+		keys := make(map[string]reflect.Value)
+		for _, m := range []reflect.Value{expected, actual} {
+			for _, key := range m.MapKeys() {
+				keys[fmt.Sprintf("%#v", key.Interface())] = key
+			}
+		}
+		for _, keyString := range SortedKeys(keys) {
+			key := keys[keyString]
+			elemPath := fmt.Sprintf("%s[%s]", path, keyString)
+			e, a := expected.MapIndex(key), actual.MapIndex(key)
+			if !a.IsValid() {
+				d.report(elemPath, "expected %#v, missing", e.Interface())
+			} else if !e.IsValid() {
+				d.report(elemPath, "unexpected %#v", a.Interface())
+			} else {
+				d.diff(elemPath, e, a)
+			}
+		}
+
+	default:
+		if !reflect.DeepEqual(expected.Interface(), actual.Interface()) {
+			d.report(path, "expected %#v, got %#v", expected.Interface(), actual.Interface())
+		}
+	}
+}
+
+// assertStringEqualsGoldenFile compares actual against the golden file at path, which is read by
+// read.  If write is not nil then it is called to rewrite the golden file instead.
+func assertStringEqualsGoldenFile(t errorReporter, message, path, actual string,
+	read func() ([]byte, error), write func([]byte) error) {
+
+	t.Helper()
+	actual = normalizeGoldenFileContents(actual)
+	if write != nil {
+		if err := write([]byte(actual)); err != nil {
+			t.Errorf("%s: could not update golden file %s: %s", message, path, err)
+		}
+		return
+	}
+
+	golden, err := read()
+	if err != nil {
+		t.Errorf("%s: could not read golden file %s, run the test with "+updateGoldenFilesEnv+"=true to create it: %s",
+			message, path, err)
+		return
+	}
+	if expected := normalizeGoldenFileContents(string(golden)); actual != expected {
+		t.Errorf("%s: does not match golden file %s, run the test with "+updateGoldenFilesEnv+"=true to update it:\n%s",
+			message, path, unifiedLineDiff(path, "actual", expected, actual))
+	}
+}
+
+// normalizeGoldenFileContents removes the trailing whitespace from each line and the trailing
+// empty lines, so that they do not cause spurious differences from golden files.
+func normalizeGoldenFileContents(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	s = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	if s == "" {
+		return ""
+	}
+	return s + "\n"
+}
+
+// unifiedLineDiff returns a unified diff, with 3 lines of context, from the lines of a to the
+// lines of b.
+func unifiedLineDiff(aName, bName, a, b string) string {
+	splitLines := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	}
+	aLines, bLines := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of aLines[i:] and bLines[j:].
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type diffLine struct {
+		op           byte
+		text         string
+		aLine, bLine int
+	}
+	var lines []diffLine
+	for i, j := 0, 0; i < len(aLines) || j < len(bLines); {
+		if i < len(aLines) && j < len(bLines) && aLines[i] == bLines[j] {
+			lines = append(lines, diffLine{' ', aLines[i], i, j})
+			i++
+			j++
+		} else if j < len(bLines) && (i == len(aLines) || lcs[i][j+1] > lcs[i+1][j]) {
+			lines = append(lines, diffLine{'+', bLines[j], i, j})
+			j++
+		} else {
+			lines = append(lines, diffLine{'-', aLines[i], i, j})
+			i++
+		}
+	}
+
+	const context = 3
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].op == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// Merge the changes that are separated by at most twice the context into a single hunk.
+		end := first
+		for k := first; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*context {
+				break
+			}
+		}
+		hunkStart, hunkEnd := first-context, end+context
+		if hunkStart < start {
+			hunkStart = start
+		}
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		aCount, bCount := 0, 0
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.op != '+' {
+				aCount++
+			}
+			if line.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", lines[hunkStart].aLine+1, aCount, lines[hunkStart].bLine+1, bCount)
+		for _, line := range lines[hunkStart:hunkEnd] {
+			sb.WriteByte(line.op)
+			sb.WriteString(line.text)
+			sb.WriteByte('\n')
+		}
+		start = hunkEnd
+	}
+	return sb.String()
 }
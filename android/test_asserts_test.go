@@ -0,0 +1,518 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeErrorReporter records the errors reported by an assertion.
+type fakeErrorReporter struct {
+	errors []string
+}
+
+func (f *fakeErrorReporter) Helper() {}
+
+func (f *fakeErrorReporter) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestListAssertions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		assert   func(t errorReporter)
+		expected []string
+	}{
+		{
+			name: "does not contain",
+			assert: func(t errorReporter) {
+				assertStringListDoesNotContain(t, "flags", []string{"-O2", "-g"}, "-fprofile-sample-use")
+			},
+		},
+		{
+			name: "does not contain failure",
+			assert: func(t errorReporter) {
+				assertStringListDoesNotContain(t, "flags", []string{"-O2", "-fprofile-sample-use"}, "-fprofile-sample-use")
+			},
+			expected: []string{
+				`flags: unexpectedly found "-fprofile-sample-use" within ["-O2" "-fprofile-sample-use"]`,
+			},
+		},
+		{
+			name: "contains all of",
+			assert: func(t errorReporter) {
+				assertStringListContainsAllOf(t, "flags", []string{"-O2", "-g", "-Wall"}, []string{"-Wall", "-O2"})
+			},
+		},
+		{
+			name: "contains all of failure",
+			assert: func(t errorReporter) {
+				assertStringListContainsAllOf(t, "flags", []string{"-O2", "-g"}, []string{"-Wall", "-O2", "-Werror"})
+			},
+			expected: []string{
+				`flags: could not find ["-Wall" "-Werror"] within ["-O2" "-g"]`,
+			},
+		},
+		{
+			name: "ignoring order",
+			assert: func(t errorReporter) {
+				assertArrayStringIgnoringOrder(t, "deps", []string{"a", "b", "a"}, []string{"b", "a", "a"})
+			},
+		},
+		{
+			name: "ignoring order failure",
+			assert: func(t errorReporter) {
+				assertArrayStringIgnoringOrder(t, "deps", []string{"a", "b", "a"}, []string{"c", "a", "b"})
+			},
+			expected: []string{
+				`deps: expected ["a" "b" "a"], actual ["c" "a" "b"] in any order, missing ["a"], unexpected ["c"]`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			tc.assert(reporter)
+			AssertArrayString(t, "errors", tc.expected, reporter.errors)
+		})
+	}
+}
+
+func TestAssertDeepEquals(t *testing.T) {
+	type inner struct {
+		Srcs []string
+		Deps map[string]int
+	}
+	type outer struct {
+		Properties inner
+		Name       *string
+		hidden     int
+	}
+
+	foo, bar := "foo", "bar"
+
+	testCases := []struct {
+		name     string
+		expected interface{}
+		actual   interface{}
+		errors   []string
+	}{
+		{
+			name:     "equal",
+			expected: outer{Properties: inner{Srcs: []string{"a.rs"}}, Name: &foo},
+			actual:   outer{Properties: inner{Srcs: []string{"a.rs"}}, Name: &foo},
+		},
+		{
+			name:     "scalar",
+			expected: 1,
+			actual:   2,
+			errors:   []string{"value: expected:\n  1\n got:\n  2"},
+		},
+		{
+			name: "nested",
+			expected: &outer{
+				Properties: inner{Srcs: []string{"a.rs", "b.rs", "a.rs"}, Deps: map[string]int{"x": 1, "y": 2}},
+				Name:       &foo,
+			},
+			actual: &outer{
+				Properties: inner{Srcs: []string{"a.rs", "b.rs", "b.rs", "c.rs"}, Deps: map[string]int{"x": 3, "z": 2}},
+				Name:       &bar,
+			},
+			errors: []string{"value: expected and actual differ:\n" +
+				`  Properties.Srcs[2]: expected "a.rs", got "b.rs"` + "\n" +
+				`  Properties.Srcs[3]: unexpected "c.rs"` + "\n" +
+				`  Properties.Deps["x"]: expected 1, got 3` + "\n" +
+				`  Properties.Deps["y"]: expected 2, missing` + "\n" +
+				`  Properties.Deps["z"]: unexpected 2` + "\n" +
+				`  Name: expected "foo", got "bar"`},
+		},
+		{
+			name:     "unexported only",
+			expected: outer{hidden: 1},
+			actual:   outer{hidden: 2},
+			errors: []string{"value: expected:\n" +
+				"  android.outer{Properties:android.inner{Srcs:[]string(nil), Deps:map[string]int(nil)}, Name:(*string)(nil), hidden:1}\n" +
+				" got:\n" +
+				"  android.outer{Properties:android.inner{Srcs:[]string(nil), Deps:map[string]int(nil)}, Name:(*string)(nil), hidden:2}"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			assertDeepEquals(reporter, "value", tc.expected, tc.actual)
+			AssertArrayString(t, "errors", tc.errors, reporter.errors)
+		})
+	}
+}
+
+func TestAssertStringEqualsGoldenFile(t *testing.T) {
+	AssertStringEqualsGoldenFile(t, "testdata", "golden_file.txt", "first line  \nsecond line\n\n")
+
+	result := GroupFixturePreparers(
+		FixtureWithRootAndroidBp(""),
+		FixtureAddTextFile("golden/mock.txt", "mock line\n"),
+	).RunTest(t)
+	result.AssertStringEqualsGoldenFile(t, "mock filesystem", "golden/mock.txt", "mock line")
+
+	golden := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	read := func() ([]byte, error) { return []byte(golden), nil }
+
+	t.Run("diff", func(t *testing.T) {
+		reporter := &fakeErrorReporter{}
+		assertStringEqualsGoldenFile(reporter, "output", "golden.txt", "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n", read, nil)
+		AssertArrayString(t, "errors", []string{
+			"output: does not match golden file golden.txt, run the test with SOONG_UPDATE_GOLDEN_FILES=true to update it:\n" +
+				"--- golden.txt\n" +
+				"+++ actual\n" +
+				"@@ -1,5 +1,5 @@\n" +
+				" a\n" +
+				"-b\n" +
+				"+B\n" +
+				" c\n" +
+				" d\n" +
+				" e\n" +
+				"@@ -8,3 +8,4 @@\n" +
+				" h\n" +
+				" i\n" +
+				" j\n" +
+				"+k\n",
+		}, reporter.errors)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "golden.txt")
+		write := func(contents []byte) error { return os.WriteFile(path, contents, 0666) }
+		reporter := &fakeErrorReporter{}
+		assertStringEqualsGoldenFile(reporter, "output", path, "updated \n\n", read, write)
+		AssertArrayString(t, "errors", nil, reporter.errors)
+
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		AssertStringEquals(t, "updated golden file", "updated\n", string(contents))
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		reporter := &fakeErrorReporter{}
+		assertStringEqualsGoldenFile(reporter, "output", "missing.txt", "a\n", func() ([]byte, error) {
+			return nil, fmt.Errorf("not found")
+		}, nil)
+		AssertArrayString(t, "errors", []string{
+			"output: could not read golden file missing.txt, run the test with SOONG_UPDATE_GOLDEN_FILES=true to create it: not found",
+		}, reporter.errors)
+	})
+}
+
+type panicValue struct {
+	code int
+}
+
+func TestAssertPanicMessage(t *testing.T) {
+	testCases := []struct {
+		name    string
+		f       func()
+		pattern string
+
+		// Substrings of the expected error, if any.
+		errorContains []string
+	}{
+		{
+			name:    "string",
+			f:       func() { panic("invalid module name \"foo\"") },
+			pattern: `invalid module name "\w+"`,
+		},
+		{
+			name:    "error",
+			f:       func() { panic(fmt.Errorf("invalid module name %q", "foo")) },
+			pattern: `invalid module name "\w+"`,
+		},
+		{
+			name:    "non-string value",
+			f:       func() { panic(panicValue{code: 3}) },
+			pattern: `\{3\}`,
+		},
+		{
+			name:          "no panic",
+			f:             func() {},
+			pattern:       `invalid module name`,
+			errorContains: []string{"panic: did not panic"},
+		},
+		{
+			name: "other panic",
+			f: func() {
+				var m map[string]int
+				m["foo"] = 1
+			},
+			pattern: `invalid module name`,
+			errorContains: []string{
+				`panic: expected panic message matching "invalid module name", ` +
+					`actual "assignment to entry in nil map", panicked at:`,
+				// The stack trace of the panic.
+				"TestAssertPanicMessage.func",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			assertPanicMessageMatches(reporter, "panic", tc.pattern, tc.f)
+			if len(tc.errorContains) == 0 {
+				AssertArrayString(t, "errors", nil, reporter.errors)
+				return
+			}
+			AssertIntEquals(t, "number of errors", 1, len(reporter.errors))
+			for _, s := range tc.errorContains {
+				AssertStringDoesContain(t, "error", reporter.errors[0], s)
+			}
+		})
+	}
+}
+
+func TestAssertPanicMessageContains(t *testing.T) {
+	reporter := &fakeErrorReporter{}
+	assertPanicMessageContains(reporter, "panic", "module name", func() {
+		panic(fmt.Errorf("invalid module name %q", "foo"))
+	})
+	AssertArrayString(t, "errors", nil, reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	assertPanicMessageContains(reporter, "panic", "module name", func() {
+		panic(panicValue{code: 3})
+	})
+	AssertIntEquals(t, "number of errors", 1, len(reporter.errors))
+	AssertStringDoesContain(t, "error", reporter.errors[0],
+		`panic: expected panic message containing "module name", actual "{3}", panicked at:`)
+}
+
+func TestPathAssertions(t *testing.T) {
+	buildDir := t.TempDir()
+	ctx := PathContextForTesting(TestConfig(buildDir, nil, "", nil))
+	out := PathForOutput(ctx, "foo", "foo.jar")
+	rawOut := filepath.Join(buildDir, "soong", "foo", "foo.jar")
+	src := PathForTesting("foo", "foo.java")
+
+	testCases := []struct {
+		name     string
+		assert   func(t errorReporter)
+		expected []string
+	}{
+		{
+			name: "path",
+			assert: func(t errorReporter) {
+				assertPathEquals(t, "path", "out/soong/foo/foo.jar", out)
+			},
+		},
+		{
+			name: "path failure",
+			assert: func(t errorReporter) {
+				assertPathEquals(t, "path", "out/soong/foo/bar.jar", out)
+			},
+			expected: []string{
+				fmt.Sprintf(`path: expected "out/soong/foo/bar.jar", actual "out/soong/foo/foo.jar" (raw %q)`, rawOut),
+			},
+		},
+		{
+			name: "nil path",
+			assert: func(t errorReporter) {
+				assertPathEquals(t, "path", "<nil path>", nil)
+			},
+		},
+		{
+			name: "optional path",
+			assert: func(t errorReporter) {
+				assertOptionalPathEquals(t, "path", "foo/foo.java", OptionalPathForPath(src))
+			},
+		},
+		{
+			name: "invalid optional path",
+			assert: func(t errorReporter) {
+				assertOptionalPathEquals(t, "path", "", InvalidOptionalPath("not found"))
+			},
+		},
+		{
+			name: "invalid optional path failure",
+			assert: func(t errorReporter) {
+				assertOptionalPathEquals(t, "path", "foo/foo.java", InvalidOptionalPath("not found"))
+			},
+			expected: []string{`path: expected "foo/foo.java", actual invalid optional path: not found`},
+		},
+		{
+			name: "paths",
+			assert: func(t errorReporter) {
+				assertPathsEquals(t, "paths", []string{"foo/foo.java", "out/soong/foo/foo.jar"}, Paths{src, out})
+			},
+		},
+		{
+			name: "nil paths",
+			assert: func(t errorReporter) {
+				assertPathsEquals(t, "paths", []string{}, nil)
+			},
+		},
+		{
+			name: "writable paths",
+			assert: func(t errorReporter) {
+				assertPathsEquals(t, "paths", []string{"out/soong/foo/foo.jar"}, WritablePaths{out}.Paths())
+			},
+		},
+		{
+			name: "paths failure",
+			assert: func(t errorReporter) {
+				assertPathsEquals(t, "paths", []string{"out/soong/foo/foo.jar"}, Paths{src, out})
+			},
+			expected: []string{
+				fmt.Sprintf(`paths: expected ["out/soong/foo/foo.jar"], actual ["foo/foo.java" "out/soong/foo/foo.jar"] (raw ["foo/foo.java" %q])`, rawOut),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			tc.assert(reporter)
+			AssertArrayString(t, "errors", tc.expected, reporter.errors)
+		})
+	}
+}
+
+func TestStripErrorPositions(t *testing.T) {
+	testCases := []struct {
+		name           string
+		message        string
+		expected       string
+		withoutModules string
+	}{
+		{
+			name:           "module error",
+			message:        `Android.bp:12:34: module "foo" variant "android_common": missing dependency`,
+			expected:       `module "foo" variant "android_common": missing dependency`,
+			withoutModules: `missing dependency`,
+		},
+		{
+			name:           "property error",
+			message:        `foo/Android.bp:3:5: module "foo": srcs: file "a.c" does not exist`,
+			expected:       `module "foo": srcs: file "a.c" does not exist`,
+			withoutModules: `srcs: file "a.c" does not exist`,
+		},
+		{
+			name:           "nested errors",
+			message:        `Android.bp:1:1: module "foo": Android.bp:7:3: module "bar" variant "x": invalid`,
+			expected:       `module "foo": module "bar" variant "x": invalid`,
+			withoutModules: `invalid`,
+		},
+		{
+			name:           "multiple lines",
+			message:        "a/Android.bp:1:2: first\nb/Android.bp:3:4: module \"foo\": second",
+			expected:       "first\nmodule \"foo\": second",
+			withoutModules: "first\nsecond",
+		},
+		{
+			name:           "no position",
+			message:        `module source path "a/b.c" does not exist`,
+			expected:       `module source path "a/b.c" does not exist`,
+			withoutModules: `module source path "a/b.c" does not exist`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			AssertStringEquals(t, "stripped", tc.expected, StripErrorPositions(tc.message, false))
+			AssertStringEquals(t, "stripped with modules", tc.withoutModules, StripErrorPositions(tc.message, true))
+		})
+	}
+}
+
+func TestAssertErrorMessageIgnoringPosition(t *testing.T) {
+	err := errors.New(`Android.bp:12:34: module "foo" variant "android_common": srcs: missing`)
+
+	testCases := []struct {
+		name     string
+		assert   func(t errorReporter)
+		expected []string
+	}{
+		{
+			name: "equals",
+			assert: func(t errorReporter) {
+				assertErrorMessageEqualsIgnoringPosition(t, "error", `module "foo" variant "android_common": srcs: missing`, err)
+			},
+		},
+		{
+			name: "equals without module",
+			assert: func(t errorReporter) {
+				assertErrorMessageEqualsIgnoringPosition(t, "error", `srcs: missing`, err)
+			},
+		},
+		{
+			name: "not equal",
+			assert: func(t errorReporter) {
+				assertErrorMessageEqualsIgnoringPosition(t, "error", `srcs: invalid`, err)
+			},
+			expected: []string{
+				`error: expected "srcs: invalid", actual "module \"foo\" variant \"android_common\": srcs: missing" ` +
+					`(stripped from "Android.bp:12:34: module \"foo\" variant \"android_common\": srcs: missing")`,
+			},
+		},
+		{
+			name: "nil error",
+			assert: func(t errorReporter) {
+				assertErrorMessageEqualsIgnoringPosition(t, "error", `srcs: missing`, nil)
+			},
+			expected: []string{"error: expected error but was nil"},
+		},
+		{
+			name: "matches anchored",
+			assert: func(t errorReporter) {
+				assertErrorMessageMatches(t, "error", `^module "foo" variant "\w+": srcs`, err)
+			},
+		},
+		{
+			name: "matches anchored without module",
+			assert: func(t errorReporter) {
+				assertErrorMessageMatches(t, "error", `^srcs: missing$`, err)
+			},
+		},
+		{
+			name: "matches position",
+			assert: func(t errorReporter) {
+				assertErrorMessageMatches(t, "error", `^Android\.bp:12:34: `, err)
+			},
+		},
+		{
+			name: "does not match",
+			assert: func(t errorReporter) {
+				assertErrorMessageMatches(t, "error", `^srcs: invalid`, err)
+			},
+			expected: []string{
+				`error: expected error matching "^srcs: invalid", ` +
+					`actual "Android.bp:12:34: module \"foo\" variant \"android_common\": srcs: missing"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter := &fakeErrorReporter{}
+			tc.assert(reporter)
+			AssertArrayString(t, "errors", tc.expected, reporter.errors)
+		})
+	}
+}
@@ -19,10 +19,14 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"time"
 
 	"github.com/google/blueprint/proptools"
 )
 
+// defaultTestBuildDate is the build date of the test configs.
+var defaultTestBuildDate = time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)
+
 // TestConfig returns a Config object for testing.
 func TestConfig(buildDir string, env map[string]string, bp string, fs map[string][]byte) Config {
 	envCopy := make(map[string]string)
@@ -57,6 +61,10 @@ func TestConfig(buildDir string, env map[string]string, bp string, fs map[string
 		captureBuild: true,
 		env:          envCopy,
 
+		// Tests use a fixed build date so that date dependent behavior is deterministic, see
+		// FixtureSetBuildDate.
+		buildDate: defaultTestBuildDate,
+
 		// Set testAllowNonExistentPaths so that test contexts don't need to specify every path
 		// passed to PathForSource or PathForModuleSrc.
 		TestAllowNonExistentPaths: true,
@@ -18,7 +18,9 @@ import (
 	"bytes"
 	"fmt"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -27,6 +29,7 @@ import (
 	mkparser "android/soong/androidmk/parser"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/pathtools"
 	"github.com/google/blueprint/proptools"
 )
 
@@ -35,7 +38,7 @@ func newTestContextForFixture(config Config) *TestContext {
 		Context: &Context{blueprint.NewContext(), config},
 	}
 
-	ctx.postDeps = append(ctx.postDeps, registerPathDepsMutator)
+	ctx.postDeps = append(ctx.postDeps, registerPathDepsMutator, registerValidationDepsMutator)
 
 	ctx.SetFs(ctx.config.fs)
 	if ctx.config.mockBpList != "" {
@@ -147,6 +150,7 @@ var PrepareForTestWithAndroidBuildComponents = GroupFixturePreparers(
 	PrepareForTestWithFilegroup,
 	PrepareForTestWithOverrides,
 	PrepareForTestWithPackageModule,
+	PrepareForTestWithPackagingRequiredDeps,
 	PrepareForTestWithPrebuilts,
 	PrepareForTestWithVisibility,
 )
@@ -192,6 +196,17 @@ type TestContext struct {
 	// The order in which the pre-singletons, mutators and singletons will be run in this test
 	// context; for debugging.
 	preSingletonOrder, mutatorOrder, singletonOrder []string
+
+	// The factories of the module types registered for the test, used to dedupe the module types
+	// that are registered by several preparers.
+	moduleTypeFactories map[string]interface{}
+
+	// Whether registering a module type or mutator name with different factories is an error, see
+	// PrepareForTestWithRegistrationValidation.
+	validateRegistrations bool
+
+	// The conflicting registrations found while validateRegistrations was set.
+	registrationErrs []error
 }
 
 func (ctx *TestContext) PreArchMutators(f RegisterMutatorFunc) {
@@ -446,7 +461,7 @@ func (ctx *TestContext) Register() {
 	globalOrder.preSingletonOrder.enforceOrdering(ctx.preSingletons)
 	ctx.preSingletons.registerAll(ctx.Context)
 
-	mutators := collateRegisteredMutators(ctx.preArch, ctx.preDeps, ctx.postDeps, ctx.finalDeps)
+	mutators := collateFilteredMutators(ctx.preArch, ctx.preDeps, ctx.postDeps, ctx.finalDeps, ctx.dedupMutators())
 	// Ensure that the mutators used in the test are in the same order as they are used at runtime.
 	globalOrder.mutatorOrder.enforceOrdering(mutators)
 	mutators.registerAll(ctx.Context)
@@ -485,14 +500,76 @@ func (ctx *TestContext) ParseBlueprintsFiles(rootDir string) (deps []string, err
 	return ctx.Context.ParseBlueprintsFiles(rootDir, ctx.config)
 }
 
+// sameFunc returns true if the funcs a and b have the same code.
+func sameFunc(a, b interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func funcName(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// isRegisteredModuleType returns true if the module type name has already been registered with
+// the same factory, e.g. by a preparer that is included in several groups of preparers and whose
+// registration must not be repeated.
+func (ctx *TestContext) isRegisteredModuleType(name string, factory interface{}) bool {
+	if existing, ok := ctx.moduleTypeFactories[name]; ok {
+		if sameFunc(existing, factory) {
+			return true
+		}
+		if ctx.validateRegistrations {
+			ctx.registrationErrs = append(ctx.registrationErrs, fmt.Errorf(
+				"module type %q is registered with both %s and %s", name, funcName(existing), funcName(factory)))
+			return true
+		}
+	}
+	if ctx.moduleTypeFactories == nil {
+		ctx.moduleTypeFactories = make(map[string]interface{})
+	}
+	ctx.moduleTypeFactories[name] = factory
+	return false
+}
+
+// dedupMutators returns a filter for collateFilteredMutators that drops the mutators that are
+// registered more than once by the same RegisterMutatorFunc, and reports the mutator names that
+// are registered by different RegisterMutatorFuncs when validating registrations.
+func (ctx *TestContext) dedupMutators() func(RegisterMutatorFunc, sortableComponents) sortableComponents {
+	registeredBy := make(map[string]RegisterMutatorFunc)
+	return func(f RegisterMutatorFunc, mutators sortableComponents) sortableComponents {
+		var kept sortableComponents
+		for _, component := range mutators {
+			name := component.componentName()
+			if existing, ok := registeredBy[name]; ok {
+				if sameFunc(existing, f) {
+					continue
+				}
+				if ctx.validateRegistrations {
+					ctx.registrationErrs = append(ctx.registrationErrs, fmt.Errorf(
+						"mutator %q is registered by both %s and %s", name, funcName(existing), funcName(f)))
+					continue
+				}
+			}
+			registeredBy[name] = f
+			kept = append(kept, component)
+		}
+		return kept
+	}
+}
+
 func (ctx *TestContext) RegisterModuleType(name string, factory ModuleFactory) {
+	if ctx.isRegisteredModuleType(name, factory) {
+		return
+	}
 	ctx.Context.RegisterModuleType(name, ModuleFactoryAdaptor(factory))
 }
 
 func (ctx *TestContext) RegisterSingletonModuleType(name string, factory SingletonModuleFactory) {
+	if ctx.isRegisteredModuleType(name, factory) {
+		return
+	}
 	s, m := SingletonModuleFactoryAdaptor(name, factory)
 	ctx.RegisterSingletonType(name, s)
-	ctx.RegisterModuleType(name, m)
+	ctx.Context.RegisterModuleType(name, ModuleFactoryAdaptor(m))
 }
 
 func (ctx *TestContext) RegisterSingletonType(name string, factory SingletonFactory) {
@@ -563,36 +640,113 @@ func (ctx *TestContext) ModuleVariantForTests(name string, matchVariations map[s
 	return newTestingModule(ctx.config, modules[0])
 }
 
-func (ctx *TestContext) ModuleForTests(name, variant string) TestingModule {
+// findModuleForTests returns the module with the given name and variant, or nil if there is no
+// such module, and the sorted variants of all the modules with the name.
+func (ctx *TestContext) findModuleForTests(name, variant string) (Module, []string) {
 	var module Module
+	var allVariants []string
 	ctx.VisitAllModules(func(m blueprint.Module) {
-		if ctx.ModuleName(m) == name && ctx.ModuleSubDir(m) == variant {
-			module = m.(Module)
+		if ctx.ModuleName(m) == name {
+			allVariants = append(allVariants, ctx.ModuleSubDir(m))
+			if ctx.ModuleSubDir(m) == variant {
+				module = m.(Module)
+			}
 		}
 	})
+	sort.Strings(allVariants)
+	return module, allVariants
+}
 
-	if module == nil {
-		// find all the modules that do exist
-		var allModuleNames []string
-		var allVariants []string
-		ctx.VisitAllModules(func(m blueprint.Module) {
-			allModuleNames = append(allModuleNames, ctx.ModuleName(m))
-			if ctx.ModuleName(m) == name {
-				allVariants = append(allVariants, ctx.ModuleSubDir(m))
-			}
-		})
-		sort.Strings(allVariants)
+// ModuleForTests returns a TestingModule for the module with the given name and variant.  Panics
+// if there is no such module, listing the variants of the modules with the name and suggesting
+// the closest one to the variant.
+func (ctx *TestContext) ModuleForTests(name, variant string) TestingModule {
+	module, allVariants := ctx.findModuleForTests(name, variant)
 
+	if module == nil {
 		if len(allVariants) == 0 {
+			// find all the modules that do exist
+			var allModuleNames []string
+			ctx.VisitAllModules(func(m blueprint.Module) {
+				allModuleNames = append(allModuleNames, ctx.ModuleName(m))
+			})
 			panic(fmt.Errorf("failed to find module %q. All modules:\n  %s",
 				name, strings.Join(SortedUniqueStrings(allModuleNames), "\n  ")))
 		} else {
-			panic(fmt.Errorf("failed to find module %q variant %q. All variants:\n  %s",
-				name, variant, strings.Join(allVariants, "\n  ")))
+			panic(fmt.Errorf("failed to find module %q variant %q, did you mean %q? All variants:\n  %s",
+				name, variant, closestString(variant, allVariants), strings.Join(allVariants, "\n  ")))
+		}
+	}
+
+	return newTestingModuleWithVariants(ctx.config, module, variant, allVariants)
+}
+
+// MaybeModuleForTests returns a TestingModule for the module with the given name and variant, or
+// a TestingModule whose Module() is nil if there is no such module.
+func (ctx *TestContext) MaybeModuleForTests(name, variant string) TestingModule {
+	module, allVariants := ctx.findModuleForTests(name, variant)
+	if module == nil {
+		return TestingModule{}
+	}
+	return newTestingModuleWithVariants(ctx.config, module, variant, allVariants)
+}
+
+// closestString returns the string in the list with the smallest edit distance to s, preferring
+// the first one in the list if several are equally close.
+func closestString(s string, list []string) string {
+	closest := ""
+	closestDistance := -1
+	for _, candidate := range list {
+		if distance := editDistance(s, candidate); closestDistance == -1 || distance < closestDistance {
+			closest, closestDistance = candidate, distance
 		}
 	}
+	return closest
+}
 
-	return newTestingModule(ctx.config, module)
+// closestStrings returns up to n strings in the list ordered by their edit distance to s, keeping
+// the order of the list for equally close strings.
+func closestStrings(s string, list []string, n int) []string {
+	distances := make(map[string]int, len(list))
+	for _, candidate := range list {
+		distances[candidate] = editDistance(s, candidate)
+	}
+	closest := append([]string(nil), list...)
+	sort.SliceStable(closest, func(i, j int) bool {
+		return distances[closest[i]] < distances[closest[j]]
+	})
+	if len(closest) > n {
+		closest = closest[:n]
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b, i.e. the number of single byte
+// insertions, deletions or substitutions needed to turn a into b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			substitution := previous[j-1]
+			if a[i-1] != b[j-1] {
+				substitution++
+			}
+			current[j] = substitution
+			if deletion := previous[j] + 1; deletion < current[j] {
+				current[j] = deletion
+			}
+			if insertion := current[j-1] + 1; insertion < current[j] {
+				current[j] = insertion
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
 }
 
 func (ctx *TestContext) ModuleVariantsForTests(name string) []string {
@@ -733,6 +887,10 @@ type TestingBuildParams struct {
 	RuleParams blueprint.RuleParams
 
 	config Config
+
+	// component is the module or singleton that created the build params, used to find the other
+	// build params that the command depends on, e.g. the rules that write its rsp files.
+	component baseTestingComponent
 }
 
 // RelativeToTop creates a new instance of this which has had any usages of the current test's
@@ -796,6 +954,7 @@ func (p TestingBuildParams) RelativeToTop() TestingBuildParams {
 	return TestingBuildParams{
 		BuildParams: bparams,
 		RuleParams:  rparams,
+		component:   p.component,
 	}
 }
 
@@ -827,14 +986,145 @@ func (p TestingBuildParams) AllOutputs() []string {
 	return allOutputs(p.BuildParams)
 }
 
+// ExpandedCommand returns the command line that ninja would run for the build params, with the
+// variables of the rule substituted from the Args, $in and $out, and the contents of the rsp files
+// inlined in place of the "@<rsp file>" arguments, for checking the whole command line of rules
+// whose flags are split across variables and rsp files.  Variables that are not defined by the
+// build params, e.g. the variables of a PackageContext, are left unexpanded.
+//
+// For RuleBuilder rules that use sbox this is the command line that runs sbox, SboxCommands returns
+// the commands that run in the sandbox.
+func (p TestingBuildParams) ExpandedCommand() string {
+	return p.inlineRspFiles(expandNinjaString(p.RuleParams.Command, p.ninjaVariables()), nil)
+}
+
+// ninjaVariables returns the values of the variables that ninja defines for the build params.
+func (p TestingBuildParams) ninjaVariables() map[string]string {
+	vars := make(map[string]string, len(p.Args)+3)
+	for name, value := range p.Args {
+		vars[name] = expandNinjaString(value, nil)
+	}
+
+	// Ninja lists the explicit inputs and outputs in the same order as convertBuildParams.
+	inputs := append(Paths(nil), p.Inputs...)
+	if p.Input != nil {
+		inputs = append(inputs, p.Input)
+	}
+	outputs := append(WritablePaths(nil), p.Outputs...)
+	if p.Output != nil {
+		outputs = append(outputs, p.Output)
+	}
+	vars["in"] = strings.Join(inputs.Strings(), " ")
+	vars["in_newline"] = strings.Join(inputs.Strings(), "\n")
+	vars["out"] = strings.Join(outputs.Strings(), " ")
+	return vars
+}
+
+// rspFileContent returns the contents of the rsp file, either the rsp file of the rule itself or
+// one written by a WriteFileRule of the same module or singleton.
+func (p TestingBuildParams) rspFileContent(rspFile string) (string, bool) {
+	vars := p.ninjaVariables()
+	if p.RuleParams.Rspfile != "" && expandNinjaString(p.RuleParams.Rspfile, vars) == rspFile {
+		return expandNinjaString(p.RuleParams.RspfileContent, vars), true
+	}
+	if p.component.provider == nil {
+		return "", false
+	}
+	if params, _ := p.component.maybeBuildParamsFromOutput(rspFile); params.Rule == writeFile {
+		return contentFromFileRule(params), true
+	}
+	return "", false
+}
+
+var rspFileArgRegexp = regexp.MustCompile(`@(\S+)`)
+
+// inlineRspFiles replaces the "@<rsp file>" arguments of the command with the contents of the rsp
+// files.  If it is not nil, mapRspFile maps the paths of the rsp files on the command line and of
+// the files listed in them, e.g. from and to the sbox sandbox.
+func (p TestingBuildParams) inlineRspFiles(command string, mapRspFile func(path string) string) string {
+	if mapRspFile == nil {
+		mapRspFile = func(path string) string { return path }
+	}
+	return rspFileArgRegexp.ReplaceAllStringFunc(command, func(arg string) string {
+		content, ok := p.rspFileContent(mapRspFile(arg[1:]))
+		if !ok {
+			return arg
+		}
+		fields := strings.Fields(content)
+		for i, field := range fields {
+			fields[i] = mapRspFile(field)
+		}
+		return strings.Join(fields, " ")
+	})
+}
+
+// expandNinjaString substitutes the variables in the ninja string s with their values in vars and
+// unescapes the ninja escape sequences.  Variables that are not in vars are left as is.
+func expandNinjaString(s string, vars map[string]string) string {
+	isVarChar := func(c byte) bool {
+		return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+	}
+
+	sb := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; {
+		case c == '$' || c == ' ' || c == ':':
+			sb.WriteByte(c)
+		case c == '\n':
+			// A line continuation, which also skips the indentation of the next line.
+			for i+1 < len(s) && s[i+1] == ' ' {
+				i++
+			}
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end == -1 {
+				sb.WriteString(s[i-1:])
+				return sb.String()
+			}
+			name := s[i+1 : i+end]
+			if value, ok := vars[name]; ok {
+				sb.WriteString(value)
+			} else {
+				sb.WriteString("${" + name + "}")
+			}
+			i += end
+		case isVarChar(c):
+			end := i
+			for end < len(s) && isVarChar(s[end]) {
+				end++
+			}
+			name := s[i:end]
+			if value, ok := vars[name]; ok {
+				sb.WriteString(value)
+			} else {
+				sb.WriteString("$" + name)
+			}
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
 // baseTestingComponent provides functionality common to both TestingModule and TestingSingleton.
 type baseTestingComponent struct {
 	config   Config
 	provider testBuildProvider
+
+	// Appended to the panics of failed lookups of build actions, e.g. to list the other variants of
+	// a module.
+	errorContext string
 }
 
 func newBaseTestingComponent(config Config, provider testBuildProvider) baseTestingComponent {
-	return baseTestingComponent{config, provider}
+	return baseTestingComponent{config: config, provider: provider}
 }
 
 // A function that will normalize a string containing paths, e.g. ninja command, by replacing
@@ -888,6 +1178,7 @@ func (b baseTestingComponent) newTestingBuildParams(bparams BuildParams) Testing
 		config:      b.config,
 		BuildParams: bparams,
 		RuleParams:  b.provider.RuleParamsForTests()[bparams.Rule],
+		component:   b,
 	}.RelativeToTop()
 }
 
@@ -907,7 +1198,7 @@ func (b baseTestingComponent) maybeBuildParamsFromRule(rule string) (TestingBuil
 func (b baseTestingComponent) buildParamsFromRule(rule string) TestingBuildParams {
 	p, searchRules := b.maybeBuildParamsFromRule(rule)
 	if p.Rule == nil {
-		panic(fmt.Errorf("couldn't find rule %q.\nall rules:\n%s", rule, strings.Join(searchRules, "\n")))
+		panic(fmt.Errorf("couldn't find rule %q.\nall rules:\n%s%s", rule, strings.Join(searchRules, "\n"), b.errorContext))
 	}
 	return p
 }
@@ -926,7 +1217,7 @@ func (b baseTestingComponent) maybeBuildParamsFromDescription(desc string) (Test
 func (b baseTestingComponent) buildParamsFromDescription(desc string) TestingBuildParams {
 	p, searchedDescriptions := b.maybeBuildParamsFromDescription(desc)
 	if p.Rule == nil {
-		panic(fmt.Errorf("couldn't find description %q\nall descriptions:\n%s", desc, strings.Join(searchedDescriptions, "\n")))
+		panic(fmt.Errorf("couldn't find description %q\nall descriptions:\n%s%s", desc, strings.Join(searchedDescriptions, "\n"), b.errorContext))
 	}
 	return p
 }
@@ -959,12 +1250,79 @@ func (b baseTestingComponent) maybeBuildParamsFromOutput(file string) (TestingBu
 func (b baseTestingComponent) buildParamsFromOutput(file string) TestingBuildParams {
 	p, searchedOutputs := b.maybeBuildParamsFromOutput(file)
 	if p.Rule == nil {
-		panic(fmt.Errorf("couldn't find output %q.\nall outputs:\n    %s\n",
-			file, strings.Join(searchedOutputs, "\n    ")))
+		panic(fmt.Errorf("couldn't find output %q.\nall outputs:\n    %s\n%s",
+			file, strings.Join(searchedOutputs, "\n    "), b.errorContext))
 	}
 	return p
 }
 
+// buildParamsFromOutputMatching returns the build params of the only output, implicit output or
+// symlink output that matches, or empty build params if none does.  Panics if more than one output
+// matches, or if none does and the output is not optional.
+func (b baseTestingComponent) buildParamsFromOutputMatching(desc string, optional bool,
+	match func(f WritablePath) bool) TestingBuildParams {
+	var found TestingBuildParams
+	var matches, searchedOutputs []string
+	seen := make(map[string]bool)
+	for _, p := range b.provider.BuildParamsForTests() {
+		outputs := append(WritablePaths(nil), p.Outputs...)
+		outputs = append(outputs, p.ImplicitOutputs...)
+		outputs = append(outputs, p.SymlinkOutputs...)
+		if p.Output != nil {
+			outputs = append(outputs, p.Output)
+		}
+		if p.SymlinkOutput != nil {
+			outputs = append(outputs, p.SymlinkOutput)
+		}
+		for _, f := range outputs {
+			formatted := fmt.Sprintf("%s (rel=%s)", PathRelativeToTop(f), f.Rel())
+			if seen[formatted] {
+				continue
+			}
+			seen[formatted] = true
+			searchedOutputs = append(searchedOutputs, formatted)
+			if match(f) {
+				found = b.newTestingBuildParams(p)
+				matches = append(matches, formatted)
+			}
+		}
+	}
+	sort.Strings(searchedOutputs)
+
+	if len(matches) == 1 || (len(matches) == 0 && optional) {
+		return found
+	}
+	if len(matches) == 0 {
+		panic(fmt.Errorf("couldn't find output %s.\nall outputs:\n    %s\n%s",
+			desc, strings.Join(searchedOutputs, "\n    "), b.errorContext))
+	}
+	sort.Strings(matches)
+	panic(fmt.Errorf("found %d outputs %s:\n    %s\nall outputs:\n    %s\n%s",
+		len(matches), desc, strings.Join(matches, "\n    "), strings.Join(searchedOutputs, "\n    "),
+		b.errorContext))
+}
+
+func (b baseTestingComponent) buildParamsFromOutputGlob(glob string, optional bool) TestingBuildParams {
+	return b.buildParamsFromOutputMatching(fmt.Sprintf("matching %q", glob), optional, func(f WritablePath) bool {
+		for _, name := range []string{PathRelativeToTop(f), f.Rel()} {
+			match, err := pathtools.Match(glob, name)
+			if err != nil {
+				panic(fmt.Errorf("invalid output glob %q: %s", glob, err))
+			}
+			if match {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func (b baseTestingComponent) buildParamsFromOutputBasename(basename string, optional bool) TestingBuildParams {
+	return b.buildParamsFromOutputMatching(fmt.Sprintf("with basename %q", basename), optional, func(f WritablePath) bool {
+		return f.Base() == basename
+	})
+}
+
 func (b baseTestingComponent) allOutputs() []string {
 	var outputFullPaths []string
 	for _, p := range b.provider.BuildParamsForTests() {
@@ -1011,11 +1369,94 @@ func (b baseTestingComponent) Output(file string) TestingBuildParams {
 	return b.buildParamsFromOutput(file)
 }
 
+// MaybeOutputMatching finds a call to ctx.Build with an output, implicit output or symlink output
+// whose path relative to the top of the tree or Rel() value matches the glob, e.g.
+// "**/foo.afdo.o".  Returns an empty BuildParams if no output matches, and panics with the list of
+// all the outputs if more than one does.
+func (b baseTestingComponent) MaybeOutputMatching(glob string) TestingBuildParams {
+	return b.buildParamsFromOutputGlob(glob, true)
+}
+
+// OutputMatching is like MaybeOutputMatching, but panics with the list of all the outputs if no
+// output matches.
+func (b baseTestingComponent) OutputMatching(glob string) TestingBuildParams {
+	return b.buildParamsFromOutputGlob(glob, false)
+}
+
+// MaybeOutputWithBasename finds a call to ctx.Build with an output, implicit output or symlink
+// output with the given basename, wherever it is in the output directory.  Returns an empty
+// BuildParams if no output matches, and panics with the list of all the outputs if more than one
+// does.
+func (b baseTestingComponent) MaybeOutputWithBasename(basename string) TestingBuildParams {
+	return b.buildParamsFromOutputBasename(basename, true)
+}
+
+// OutputWithBasename is like MaybeOutputWithBasename, but panics with the list of all the outputs
+// if no output matches.
+func (b baseTestingComponent) OutputWithBasename(basename string) TestingBuildParams {
+	return b.buildParamsFromOutputBasename(basename, false)
+}
+
 // AllOutputs returns all 'BuildParams.Output's and 'BuildParams.Outputs's in their full path string forms.
 func (b baseTestingComponent) AllOutputs() []string {
 	return b.allOutputs()
 }
 
+// DescribeBuildActions returns a description of every call to ctx.Build in the order they were
+// made, with the rule, description, paths relative to a notional top directory and sorted args of
+// each.  The format is stable so that it can be compared against in tests.
+func (b baseTestingComponent) DescribeBuildActions() string {
+	sb := &strings.Builder{}
+	for i, p := range b.provider.BuildParamsForTests() {
+		describeBuildParams(sb, i, b.newTestingBuildParams(p))
+	}
+	return sb.String()
+}
+
+// DumpBuildActions logs the description returned by DescribeBuildActions, for debugging tests.
+func (b baseTestingComponent) DumpBuildActions(t *testing.T) {
+	t.Helper()
+	t.Logf("build actions:\n%s", b.DescribeBuildActions())
+}
+
+func describeBuildParams(sb *strings.Builder, index int, p TestingBuildParams) {
+	fmt.Fprintf(sb, "build %d: %s\n", index, p.Rule)
+	if p.Description != "" {
+		fmt.Fprintf(sb, "  description: %s\n", p.Description)
+	}
+
+	describePaths := func(name string, path Path, paths Paths) {
+		if path != nil {
+			paths = append(Paths{path}, paths...)
+		}
+		if len(paths) > 0 {
+			fmt.Fprintf(sb, "  %s: %s\n", name, strings.Join(paths.Strings(), " "))
+		}
+	}
+	describePaths("outputs", p.Output, p.Outputs.Paths())
+	describePaths("implicit outputs", p.ImplicitOutput, p.ImplicitOutputs.Paths())
+	describePaths("inputs", p.Input, p.Inputs)
+	describePaths("implicits", p.Implicit, p.Implicits)
+	describePaths("order only", nil, p.OrderOnly)
+	describePaths("validations", p.Validation, p.Validations)
+
+	if len(p.Args) > 0 {
+		sb.WriteString("  args:\n")
+		for _, k := range SortedStringKeys(p.Args) {
+			fmt.Fprintf(sb, "    %s: %s\n", k, p.Args[k])
+		}
+	}
+}
+
+// ruleNames returns the names of the rules of every call to ctx.Build.
+func (b baseTestingComponent) ruleNames() []string {
+	var rules []string
+	for _, p := range b.provider.BuildParamsForTests() {
+		rules = append(rules, p.Rule.String())
+	}
+	return rules
+}
+
 // TestingModule is wrapper around an android.Module that provides methods to find information about individual
 // ctx.Build parameters for verification in tests.
 type TestingModule struct {
@@ -1030,11 +1471,34 @@ func newTestingModule(config Config, module Module) TestingModule {
 	}
 }
 
+// newTestingModuleWithVariants returns a TestingModule whose failed lookups of build actions
+// report the variant of the module and all the variants of the modules with the same name.
+func newTestingModuleWithVariants(config Config, module Module, variant string, allVariants []string) TestingModule {
+	m := newTestingModule(config, module)
+	m.errorContext = fmt.Sprintf("\nin module %q variant %q. All variants:\n  %s",
+		module.Name(), variant, strings.Join(allVariants, "\n  "))
+	return m
+}
+
 // Module returns the Module wrapped by the TestingModule.
 func (m TestingModule) Module() Module {
 	return m.module
 }
 
+// Target returns the target of the wrapped module variant.
+func (m TestingModule) Target() Target {
+	return m.module.Target()
+}
+
+// CreatedBy returns the module and hook that created the wrapped module programmatically, or false
+// if the module was defined in an Android.bp file.
+func (m TestingModule) CreatedBy() (ModuleCreator, bool) {
+	if creator := m.module.base().createdBy; creator != nil {
+		return *creator, true
+	}
+	return ModuleCreator{}, false
+}
+
 // VariablesForTestsRelativeToTop returns a copy of the Module.VariablesForTests() with every value
 // having any temporary build dir usages replaced with paths relative to a notional top.
 func (m TestingModule) VariablesForTestsRelativeToTop() map[string]string {
@@ -1092,7 +1556,7 @@ func FailIfNoMatchingErrors(t *testing.T, pattern string, errs []error) bool {
 
 	found := false
 	for _, err := range errs {
-		if matcher.FindStringIndex(err.Error()) != nil {
+		if errorMessageMatches(matcher, err.Error()) {
 			found = true
 			break
 		}
@@ -1130,6 +1594,65 @@ func CheckErrorsAgainstExpectations(t *testing.T, errs []error, expectedErrorPat
 	}
 }
 
+// CheckErrorsMatchPatternsInAnyOrder fails the test immediately unless every pattern matches at
+// least one of the errors and every error matches at least one of the patterns, in any order.
+func CheckErrorsMatchPatternsInAnyOrder(t *testing.T, errs []error, patterns []string) {
+	t.Helper()
+	if !checkErrorsMatchPatternsInAnyOrder(t, errs, patterns) {
+		t.FailNow()
+	}
+}
+
+func checkErrorsMatchPatternsInAnyOrder(t errorReporter, errs []error, patterns []string) bool {
+	t.Helper()
+
+	patternMatched := make([]bool, len(patterns))
+	errorClaimed := make([]bool, len(errs))
+	for i, pattern := range patterns {
+		matcher, err := regexp.Compile(pattern)
+		if err != nil {
+			t.Errorf("failed to compile regular expression %q because %s", pattern, err)
+			return false
+		}
+		for j, err := range errs {
+			if errorMessageMatches(matcher, err.Error()) {
+				patternMatched[i] = true
+				errorClaimed[j] = true
+			}
+		}
+	}
+
+	var unmatchedPatterns, unclaimedErrors []string
+	for i, pattern := range patterns {
+		if !patternMatched[i] {
+			unmatchedPatterns = append(unmatchedPatterns, fmt.Sprintf("%q", pattern))
+		}
+	}
+	for j, err := range errs {
+		if !errorClaimed[j] {
+			unclaimedErrors = append(unclaimedErrors, fmt.Sprintf("%q", err))
+		}
+	}
+	if len(unmatchedPatterns) == 0 && len(unclaimedErrors) == 0 {
+		return true
+	}
+
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "    (none)"
+		}
+		return "    " + strings.Join(items, "\n    ")
+	}
+	t.Errorf("errors do not match the expected patterns (checked %d pattern(s) against %d error(s))\n"+
+		"  unmatched patterns:\n%s\n  unclaimed errors:\n%s",
+		len(patterns), len(errs), list(unmatchedPatterns), list(unclaimedErrors))
+	return false
+}
+
+func SetHashedIntermediatesForTests(config Config) {
+	config.hashedIntermediates = true
+}
+
 func SetKatiEnabledForTests(config Config) {
 	config.katiEnabled = true
 }
@@ -1166,6 +1689,89 @@ func AndroidMkDataForTest(t *testing.T, ctx *TestContext, mod blueprint.Module)
 	return data
 }
 
+// AndroidMkEntriesSelector selects one of the AndroidMkEntries of a module, for the modules like
+// apexes that export more than one module to Make.
+type AndroidMkEntriesSelector struct {
+	index int
+	name  string
+}
+
+// PrimaryAndroidMkEntries selects the first AndroidMkEntries of a module, which are the only ones
+// of most module types.
+var PrimaryAndroidMkEntries = AndroidMkEntriesAt(0)
+
+// AndroidMkEntriesAt selects the AndroidMkEntries of a module at the index.
+func AndroidMkEntriesAt(index int) AndroidMkEntriesSelector {
+	return AndroidMkEntriesSelector{index: index}
+}
+
+// AndroidMkEntriesNamed selects the AndroidMkEntries of a module whose LOCAL_MODULE is name.
+func AndroidMkEntriesNamed(name string) AndroidMkEntriesSelector {
+	return AndroidMkEntriesSelector{index: -1, name: name}
+}
+
+func (s AndroidMkEntriesSelector) String() string {
+	if s.index < 0 {
+		return fmt.Sprintf("named %q", s.name)
+	}
+	return fmt.Sprintf("at index %d", s.index)
+}
+
+func (s AndroidMkEntriesSelector) selectEntries(entriesList []AndroidMkEntries) *AndroidMkEntries {
+	for i := range entriesList {
+		if i == s.index || (s.index < 0 && entriesList[i].EntryMap["LOCAL_MODULE"][0] == s.name) {
+			return &entriesList[i]
+		}
+	}
+	return nil
+}
+
+// assertAndroidMkEntry checks that the entry key of the selected AndroidMkEntries of the module is
+// set to the expected values, with the paths relative to the top of the tree, or that it is not
+// set if absent is true.
+func assertAndroidMkEntry(t errorReporter, message string, ctx *TestContext, module Module,
+	selector AndroidMkEntriesSelector, key string, expected []string, absent bool) {
+	t.Helper()
+	p, ok := module.(AndroidMkEntriesProvider)
+	if !ok {
+		t.Errorf("%s: module %q does not implement AndroidMkEntriesProvider", message, module.Name())
+		return
+	}
+	entriesList := p.AndroidMkEntries()
+	for i := range entriesList {
+		entriesList[i].fillInEntries(ctx, module)
+	}
+
+	entries := selector.selectEntries(entriesList)
+	if entries == nil {
+		var names []string
+		for i, e := range entriesList {
+			names = append(names, fmt.Sprintf("%d: %s", i, e.EntryMap["LOCAL_MODULE"][0]))
+		}
+		t.Errorf("%s: module %q has no AndroidMkEntries %s, its AndroidMkEntries are:\n    %s",
+			message, module.Name(), selector, strings.Join(names, "\n    "))
+		return
+	}
+
+	values, ok := entries.EntryMap[key]
+	if absent {
+		if ok {
+			t.Errorf("%s: expected %s not to be set, actual %q", message, key,
+				normalizeStringArrayRelativeToTop(ctx.config, values))
+		}
+		return
+	}
+	if !ok {
+		t.Errorf("%s: %s is not set in the AndroidMkEntries of %s, the entries are:\n    %s", message, key,
+			entries.EntryMap["LOCAL_MODULE"][0], strings.Join(SortedKeys(entries.EntryMap), "\n    "))
+		return
+	}
+	normalized := normalizeStringArrayRelativeToTop(ctx.config, values)
+	if (len(expected) > 0 || len(normalized) > 0) && !reflect.DeepEqual(expected, normalized) {
+		t.Errorf("%s: expected %s to be %q, actual %q", message, key, expected, normalized)
+	}
+}
+
 // Normalize the path for testing.
 //
 // If the path is relative to the build directory then return the relative path
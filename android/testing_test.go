@@ -0,0 +1,217 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/blueprint"
+)
+
+// fakeBuildProvider provides a fixed list of build params, as if they had been built by a module.
+type fakeBuildProvider struct {
+	buildParams []BuildParams
+}
+
+func (p fakeBuildProvider) BuildParamsForTests() []BuildParams {
+	return p.buildParams
+}
+
+func (p fakeBuildProvider) RuleParamsForTests() map[blueprint.Rule]blueprint.RuleParams {
+	return nil
+}
+
+func testingComponentWithBuildActions(t *testing.T) baseTestingComponent {
+	config := TestConfig(t.TempDir(), nil, "", nil)
+	ctx := PathContextForTesting(config)
+	return newBaseTestingComponent(config, fakeBuildProvider{[]BuildParams{
+		{
+			Rule:        Touch,
+			Description: "touch foo",
+			Output:      PathForOutput(ctx, "foo"),
+		},
+		{
+			Rule:           Cp,
+			Input:          PathForTesting("src/in"),
+			Implicits:      PathsForTesting("src/a", "src/b"),
+			Output:         PathForOutput(ctx, "gen/out"),
+			ImplicitOutput: PathForOutput(ctx, "gen/out.d"),
+			OrderOnly:      PathsForTesting("order"),
+			Validation:     PathForOutput(ctx, "check.stamp"),
+			Args: map[string]string{
+				"extraCmds": " && touch " + filepath.Join(config.soongOutDir, "gen/out.stamp"),
+				"cpFlags":   "-f",
+			},
+		},
+	}})
+}
+
+func TestDescribeBuildActions(t *testing.T) {
+	component := testingComponentWithBuildActions(t)
+	AssertStringEqualsGoldenFile(t, "build actions", "describe_build_actions.txt", component.DescribeBuildActions())
+
+	empty := newBaseTestingComponent(TestConfig(t.TempDir(), nil, "", nil), fakeBuildProvider{})
+	AssertStringEquals(t, "no build actions", "", empty.DescribeBuildActions())
+}
+
+func TestOutputMatching(t *testing.T) {
+	config := TestConfig(t.TempDir(), nil, "", nil)
+	ctx := PathContextForTesting(config)
+	lib := PathForOutput(ctx, "obj/a1b2/libfoo.so")
+	component := newBaseTestingComponent(config, fakeBuildProvider{[]BuildParams{
+		{
+			Rule:           Cp,
+			Output:         PathForOutput(ctx, "obj/a1b2/foo.afdo.o"),
+			ImplicitOutput: PathForOutput(ctx, "obj/a1b2/foo.afdo.o.d"),
+		},
+		{
+			Rule:          Touch,
+			Output:        PathForOutput(ctx, "obj/bar.o"),
+			SymlinkOutput: lib,
+		},
+		{
+			Rule:   Touch,
+			Output: PathForOutput(ctx, "gen/foo.afdo.o.d"),
+		},
+	}})
+
+	AssertStringEquals(t, "glob", "out/soong/obj/a1b2/foo.afdo.o",
+		component.OutputMatching("**/*.afdo.o").Output.String())
+	AssertStringEquals(t, "implicit output glob", "out/soong/obj/a1b2/foo.afdo.o",
+		component.OutputMatching("obj/*/*.afdo.o.d").Output.String())
+	AssertStringEquals(t, "basename", "out/soong/obj/a1b2/foo.afdo.o",
+		component.OutputWithBasename("foo.afdo.o").Output.String())
+	AssertStringEquals(t, "symlink output", "out/soong/obj/bar.o",
+		component.OutputWithBasename("libfoo.so").Output.String())
+
+	if p := component.MaybeOutputMatching("**/*.rlib"); p.Rule != nil {
+		t.Errorf("expected no output matching **/*.rlib, found %s", p.Output)
+	}
+	if p := component.MaybeOutputWithBasename("foo.rlib"); p.Rule != nil {
+		t.Errorf("expected no output with basename foo.rlib, found %s", p.Output)
+	}
+
+	allOutputs := "all outputs:\n" +
+		"    out/soong/gen/foo.afdo.o.d (rel=gen/foo.afdo.o.d)\n" +
+		"    out/soong/obj/a1b2/foo.afdo.o (rel=obj/a1b2/foo.afdo.o)\n" +
+		"    out/soong/obj/a1b2/foo.afdo.o.d (rel=obj/a1b2/foo.afdo.o.d)\n" +
+		"    out/soong/obj/a1b2/libfoo.so (rel=obj/a1b2/libfoo.so)\n" +
+		"    out/soong/obj/bar.o (rel=obj/bar.o)\n"
+	AssertPanicMessageContains(t, "no match", "couldn't find output matching \"**/*.rlib\".\n"+allOutputs, func() {
+		component.OutputMatching("**/*.rlib")
+	})
+	AssertPanicMessageContains(t, "multiple matches", "found 2 outputs with basename \"foo.afdo.o.d\":\n"+
+		"    out/soong/gen/foo.afdo.o.d (rel=gen/foo.afdo.o.d)\n"+
+		"    out/soong/obj/a1b2/foo.afdo.o.d (rel=obj/a1b2/foo.afdo.o.d)\n"+allOutputs, func() {
+		component.MaybeOutputWithBasename("foo.afdo.o.d")
+	})
+}
+
+func TestAssertModuleHasRule(t *testing.T) {
+	component := testingComponentWithBuildActions(t)
+
+	reporter := &fakeErrorReporter{}
+	assertHasRule(reporter, "touch", component, "Touch")
+	AssertArrayString(t, "found rule", nil, reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	assertHasRule(reporter, "missing", component, "ld")
+	AssertArrayString(t, "missing rule", []string{"missing: could not find rule \"ld\", the rules are:\n" +
+		"    android/soong/android.Touch\n" +
+		"    android/soong/android.Cp"}, reporter.errors)
+
+	reporter = &fakeErrorReporter{}
+	empty := newBaseTestingComponent(TestConfig(t.TempDir(), nil, "", nil), fakeBuildProvider{})
+	assertHasRule(reporter, "empty", empty, "ld")
+	AssertArrayString(t, "no rules", []string{`empty: could not find rule "ld", there are no rules`}, reporter.errors)
+}
+
+func TestModuleForTestsMissingVariant(t *testing.T) {
+	result := GroupFixturePreparers(
+		prepareForModuleTests,
+		PrepareForTestWithArchMutator,
+		FixtureWithRootAndroidBp(`
+			deps {
+				name: "foo",
+			}
+		`),
+	).RunTest(t)
+
+	AssertPanicMessageContains(t, "missing variant",
+		`failed to find module "foo" variant "android_commom", did you mean "android_common"? All variants:`,
+		func() {
+			result.ModuleForTests("foo", "android_commom")
+		})
+
+	AssertPanicMessageContains(t, "missing module", `failed to find module "bar". All modules:`, func() {
+		result.ModuleForTests("bar", "android_common")
+	})
+
+	if m := result.MaybeModuleForTests("foo", "android_commom"); m.Module() != nil {
+		t.Errorf("expected no module for a missing variant, found %s", m.Module())
+	}
+	AssertStringEquals(t, "existing variant", "foo", result.MaybeModuleForTests("foo", "android_common").Module().Name())
+
+	AssertPanicMessageContains(t, "missing output",
+		"in module \"foo\" variant \"android_common\". All variants:\n  android_common", func() {
+			result.ModuleForTests("foo", "android_common").Output("missing")
+		})
+}
+
+func TestEditDistance(t *testing.T) {
+	AssertIntEquals(t, "identical", 0, editDistance("android_common", "android_common"))
+	AssertIntEquals(t, "substitution", 1, editDistance("android_commom", "android_common"))
+	AssertIntEquals(t, "kitten", 3, editDistance("kitten", "sitting"))
+	AssertIntEquals(t, "empty", 3, editDistance("", "abc"))
+	AssertStringEquals(t, "closest", "android_arm64_armv8-a_shared", closestString("android_arm64_armv8-a",
+		[]string{"android_arm64_armv8-a_shared", "android_arm_armv7-a-neon_shared"}))
+}
+
+func TestExpandNinjaString(t *testing.T) {
+	vars := map[string]string{"in": "a b", "out": "o", "flags": "-x"}
+	for s, expected := range map[string]string{
+		"cc $flags -o $out $in":             "cc -x -o o a b",
+		"${flags}y $$HOME $ x$:y":           "-xy $HOME  x:y",
+		"a $\n    b":                        "a b",
+		"${config.Cc} $undefined @$out.rsp": "${config.Cc} $undefined @o.rsp",
+	} {
+		AssertStringEquals(t, s, expected, expandNinjaString(s, vars))
+	}
+}
+
+func TestExpandedCommand(t *testing.T) {
+	config := TestConfig(t.TempDir(), nil, "", nil)
+	ctx := PathContextForTesting(config)
+	out := PathForOutput(ctx, "gen/out")
+
+	params := TestingBuildParams{
+		BuildParams: BuildParams{
+			Inputs: PathsForTesting("a.rs", "b.rs"),
+			Output: out,
+			Args: map[string]string{
+				"rustcFlags": "-C opt-level=2 --cfg 'feature=\"$$x\"'",
+			},
+		},
+		RuleParams: blueprint.RuleParams{
+			Command:        "${config.Rustc} $rustcFlags -o $out @$out.rsp",
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		},
+	}
+	AssertStringEquals(t, "expanded command",
+		"${config.Rustc} -C opt-level=2 --cfg 'feature=\"$x\"' -o "+out.String()+" a.rs b.rs",
+		params.ExpandedCommand())
+}
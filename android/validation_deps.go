@@ -0,0 +1,132 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"github.com/google/blueprint"
+)
+
+// This file implements the validation_deps property, which makes the build of a module fail if the
+// checks of other modules, like the abi check of a library or the api lint of a java library,
+// fail, without making the module depend on the outputs of those modules.  The outputs of the
+// checks are attached as ninja validations to the build statement of the primary output of the
+// module, the output of OutputFiles(""), so they are run whenever the module is built but do not
+// delay or rebuild it.
+
+// ValidationsInfo is provided by modules that run checks that other modules can list in their
+// validation_deps property.
+type ValidationsInfo struct {
+	// Validations is the list of outputs of the checks of the module.
+	Validations Paths
+}
+
+var ValidationsProvider = blueprint.NewProvider(ValidationsInfo{})
+
+type validationDepTagType struct {
+	blueprint.BaseDependencyTag
+}
+
+var validationDepTag = validationDepTagType{}
+
+func registerValidationDepsMutator(ctx RegisterMutatorsContext) {
+	ctx.BottomUp("validation_deps", validationDepsMutator).Parallel()
+}
+
+// validationDepsMutator adds a dependency on each module listed in the validation_deps property,
+// on the variant for the same target.
+func validationDepsMutator(ctx BottomUpMutatorContext) {
+	for _, dep := range ctx.Module().base().commonProperties.Validation_deps {
+		variations := ctx.Target().Variations()
+		// Libraries only run their checks, like the abi check, in their shared variant.
+		shared := append(ctx.Target().Variations(), blueprint.Variation{Mutator: "link", Variation: "shared"})
+		if ctx.OtherModuleFarDependencyVariantExists(shared, dep) {
+			variations = shared
+		}
+		ctx.AddFarVariationDependencies(variations, validationDepTag, dep)
+	}
+}
+
+// validationDepsPaths returns the outputs of the checks of the modules listed in the
+// validation_deps property.
+func validationDepsPaths(ctx ModuleContext) Paths {
+	var validations Paths
+	ctx.VisitDirectDepsWithTag(validationDepTag, func(dep Module) {
+		if !ctx.OtherModuleHasProvider(dep, ValidationsProvider) {
+			ctx.PropertyErrorf("validation_deps", "module %q does not provide any validations",
+				ctx.OtherModuleName(dep))
+			return
+		}
+		info := ctx.OtherModuleProvider(dep, ValidationsProvider).(ValidationsInfo)
+		validations = append(validations, info.Validations...)
+	})
+	return FirstUniquePaths(validations)
+}
+
+// pendingBuild is a build statement of a module with validation_deps that is held back until the
+// primary outputs of the module are known.
+type pendingBuild struct {
+	pctx   PackageContext
+	params BuildParams
+}
+
+// attachValidations writes out the build statements of a module with validation_deps, attaching
+// the outputs of the checks of the validation_deps as validations to the build statements of its
+// primary outputs.
+func (m *moduleContext) attachValidations() {
+	validations, pendingBuilds := m.validations, m.pendingBuilds
+	m.validations, m.pendingBuilds = nil, nil
+	if len(validations) == 0 {
+		return
+	}
+
+	var primaryOutputs Paths
+	if producer, ok := m.module.(OutputFileProducer); ok {
+		primaryOutputs, _ = producer.OutputFiles("")
+	}
+
+	attached := false
+	for _, pending := range pendingBuilds {
+		params := pending.params
+		if buildsAnyOf(params, primaryOutputs) {
+			params.Validations = append(append(Paths(nil), params.Validations...), validations...)
+			attached = true
+		}
+		m.build(pending.pctx, params)
+	}
+	if !attached && !m.Failed() {
+		m.PropertyErrorf("validation_deps", "module does not build a primary output to attach the validations to")
+	}
+}
+
+// buildsAnyOf returns true if the build statement has any of paths as an output.
+func buildsAnyOf(params BuildParams, paths Paths) bool {
+	var outputs WritablePaths
+	if params.Output != nil {
+		outputs = append(outputs, params.Output)
+	}
+	if params.ImplicitOutput != nil {
+		outputs = append(outputs, params.ImplicitOutput)
+	}
+	outputs = append(outputs, params.Outputs...)
+	outputs = append(outputs, params.ImplicitOutputs...)
+	for _, output := range outputs {
+		for _, path := range paths {
+			if output.String() == path.String() {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package android
+
+import (
+	"testing"
+)
+
+type validationDepsTestModule struct {
+	ModuleBase
+	properties struct {
+		// Whether the module runs a check that other modules can list in validation_deps.
+		Check *bool
+
+		// Whether the module has no primary output.
+		No_output *bool
+	}
+	outputFile Path
+}
+
+func validationDepsTestModuleFactory() Module {
+	module := &validationDepsTestModule{}
+	module.AddProperties(&module.properties)
+	InitAndroidArchModule(module, DeviceSupported, MultilibFirst)
+	return module
+}
+
+func (m *validationDepsTestModule) GenerateAndroidBuildActions(ctx ModuleContext) {
+	obj := PathForModuleOut(ctx, ctx.ModuleName()+".o")
+	ctx.Build(pctx, BuildParams{
+		Rule:   Touch,
+		Output: obj,
+	})
+
+	if !Bool(m.properties.No_output) {
+		out := PathForModuleOut(ctx, ctx.ModuleName())
+		ctx.Build(pctx, BuildParams{
+			Rule:   Touch,
+			Input:  obj,
+			Output: out,
+		})
+		m.outputFile = out
+	}
+
+	if Bool(m.properties.Check) {
+		check := PathForModuleOut(ctx, ctx.ModuleName()+".check")
+		ctx.Build(pctx, BuildParams{
+			Rule:   Touch,
+			Output: check,
+		})
+		ctx.SetProvider(ValidationsProvider, ValidationsInfo{Validations: Paths{check}})
+	}
+}
+
+func (m *validationDepsTestModule) OutputFiles(tag string) (Paths, error) {
+	if tag != "" || m.outputFile == nil {
+		return nil, nil
+	}
+	return Paths{m.outputFile}, nil
+}
+
+var prepareForValidationDepsTest = GroupFixturePreparers(
+	PrepareForTestWithArchMutator,
+	FixtureRegisterWithContext(func(ctx RegistrationContext) {
+		ctx.RegisterModuleType("test", validationDepsTestModuleFactory)
+	}),
+)
+
+func TestValidationDeps(t *testing.T) {
+	result := prepareForValidationDepsTest.RunTestWithBp(t, `
+		test {
+			name: "foo",
+			validation_deps: ["bar", "baz"],
+		}
+
+		test {
+			name: "bar",
+			check: true,
+		}
+
+		test {
+			name: "baz",
+			check: true,
+			validation_deps: ["bar"],
+		}
+	`)
+
+	// The checks are attached as validations to the primary output of the module.
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	out := foo.Output("foo")
+	AssertPathsRelativeToTopEquals(t, "validations", []string{
+		"out/soong/.intermediates/bar/android_arm64_armv8-a/bar.check",
+		"out/soong/.intermediates/baz/android_arm64_armv8-a/baz.check",
+	}, out.Validations)
+
+	// The outputs of the validation_deps are not inputs of the module.
+	AssertArrayString(t, "implicits", nil, out.Implicits.Strings())
+	AssertArrayString(t, "order only", nil, out.OrderOnly.Strings())
+
+	// The other build statements of the module are not delayed by the checks.
+	AssertArrayString(t, "intermediate validations", nil, foo.Output("foo.o").Validations.Strings())
+
+	// A module that runs checks can list the checks of other modules too, they are attached to its
+	// primary output but not to its own checks.
+	baz := result.ModuleForTests("baz", "android_arm64_armv8-a")
+	AssertPathsRelativeToTopEquals(t, "validations",
+		[]string{"out/soong/.intermediates/bar/android_arm64_armv8-a/bar.check"},
+		baz.Output("baz").Validations)
+	AssertArrayString(t, "check validations", nil, baz.Output("baz.check").Validations.Strings())
+}
+
+func TestValidationDepsWithoutPrimaryOutput(t *testing.T) {
+	prepareForValidationDepsTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`validation_deps: module does not build a primary output to attach the validations to`)).
+		RunTestWithBp(t, `
+			test {
+				name: "foo",
+				no_output: true,
+				validation_deps: ["bar"],
+			}
+
+			test {
+				name: "bar",
+				check: true,
+			}
+		`)
+}
+
+func TestValidationDepsWithoutValidations(t *testing.T) {
+	prepareForValidationDepsTest.
+		ExtendWithErrorHandler(FixtureExpectsOneErrorPattern(
+			`validation_deps: module "bar" does not provide any validations`)).
+		RunTestWithBp(t, `
+			test {
+				name: "foo",
+				validation_deps: ["bar"],
+			}
+
+			test {
+				name: "bar",
+			}
+		`)
+}
@@ -219,6 +219,8 @@ type productVariables struct {
 	DeviceCurrentApiLevelForVendorModules *string  `json:",omitempty"`
 	DeviceSystemSdkVersions               []string `json:",omitempty"`
 	DeviceMaxPageSizeSupported            *string  `json:",omitempty"`
+	CheckPrebuiltMaxPageSize              *bool    `json:",omitempty"`
+	DefaultBuildIdStyle                   *string  `json:",omitempty"`
 
 	RecoverySnapshotVersion *string `json:",omitempty"`
 
@@ -309,14 +311,37 @@ type productVariables struct {
 
 	HWASanIncludePaths []string `json:",omitempty"`
 
+	GenruleStrictSandboxPaths []string `json:",omitempty"`
+
+	SubpackageSrcsAllowedPaths []string `json:",omitempty"`
+
+	AnyApexAllowedPaths []string `json:",omitempty"`
+
+	RustSecondaryVersion      *string  `json:",omitempty"`
+	RustSecondaryVersionPaths []string `json:",omitempty"`
+
+	PrebuiltProductTypes map[string]string `json:",omitempty"`
+
+	// The modules in PRODUCT_PACKAGES, which are checked for having a device variant.
+	ProductPackages []string `json:",omitempty"`
+
 	VendorPath    *string `json:",omitempty"`
 	OdmPath       *string `json:",omitempty"`
 	ProductPath   *string `json:",omitempty"`
 	SystemExtPath *string `json:",omitempty"`
 
+	// Keep the frame pointers in native device code, so that profilers like simpleperf can unwind
+	// the stack with them.  Off by default, as it costs a register and code size in every
+	// function.
+	FramePointers *bool `json:",omitempty"`
+
 	ClangTidy  *bool   `json:",omitempty"`
 	TidyChecks *string `json:",omitempty"`
 
+	// Maps path prefixes to the clang-tidy checks that are enabled and treated as errors in the
+	// modules under them.
+	TidyChecksAsErrorsForPath map[string][]string `json:",omitempty"`
+
 	JavaCoveragePaths        []string `json:",omitempty"`
 	JavaCoverageExcludePaths []string `json:",omitempty"`
 
@@ -340,13 +365,15 @@ type productVariables struct {
 
 	Override_rs_driver *string `json:",omitempty"`
 
-	DeviceKernelHeaders []string `json:",omitempty"`
+	DeviceKernelHeaders  []string `json:",omitempty"`
+	KernelHeadersVersion *string  `json:",omitempty"`
 
 	ExtraVndkVersions []string `json:",omitempty"`
 
 	NamespacesToExport []string `json:",omitempty"`
 
 	PgoAdditionalProfileDirs []string `json:",omitempty"`
+	RustPgoProfileGenDir     *string  `json:",omitempty"`
 
 	VndkUseCoreVariant         *bool `json:",omitempty"`
 	VndkSnapshotBuildArtifacts *bool `json:",omitempty"`
@@ -450,6 +477,8 @@ type productVariables struct {
 	BuildBrokenVendorPropertyNamespace bool     `json:",omitempty"`
 	BuildBrokenInputDirModules         []string `json:",omitempty"`
 
+	BuildBrokenUnbuildableProductPackages bool `json:",omitempty"`
+
 	BuildDebugfsRestrictionsEnabled bool `json:",omitempty"`
 
 	RequiresInsecureExecmemForSwiftshader bool `json:",omitempty"`
@@ -468,11 +497,18 @@ type productVariables struct {
 	IncludeTags    []string `json:",omitempty"`
 	SourceRootDirs []string `json:",omitempty"`
 
-	AfdoProfiles []string `json:",omitempty"`
+	AfdoProfiles    []string `json:",omitempty"`
+	AfdoProfileDirs []string `json:",omitempty"`
+
+	Experiments []ExperimentConfig `json:",omitempty"`
 
 	ProductManufacturer string   `json:",omitempty"`
 	ProductBrand        string   `json:",omitempty"`
 	BuildVersionTags    []string `json:",omitempty"`
+
+	// lazy holds the sections of the config file that are decoded on their first access, see
+	// lazy_product_variables.go.
+	lazy *lazyProductVariables
 }
 
 func boolPtr(v bool) *bool {
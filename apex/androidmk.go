@@ -299,6 +299,8 @@ func (a *apexBundle) writeRequiredModules(w io.Writer, moduleNames []string) {
 	var targetRequired []string
 	var hostRequired []string
 	required = append(required, a.RequiredModuleNames()...)
+	// Companion files are installed outside of the APEX whenever the APEX is installed.
+	required = append(required, a.properties.Companion_files...)
 	targetRequired = append(targetRequired, a.TargetRequiredModuleNames()...)
 	hostRequired = append(hostRequired, a.HostRequiredModuleNames()...)
 	for _, fi := range a.filesInfo {
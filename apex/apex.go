@@ -55,6 +55,8 @@ func registerApexBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("override_apex", OverrideApexFactory)
 	ctx.RegisterModuleType("apex_set", apexSetFactory)
 
+	ctx.RegisterSingletonType("apex_available_audit", apexAvailableAuditSingletonFactory)
+
 	ctx.PreArchMutators(registerPreArchMutators)
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
 	ctx.PostDepsMutators(RegisterPostDepsMutators)
@@ -137,6 +139,11 @@ type apexBundleProperties struct {
 	// List of filesystem images that are embedded inside this APEX bundle.
 	Filesystems []string
 
+	// List of device modules that are installed outside of this APEX bundle, e.g. on /system,
+	// whenever this APEX bundle is installed. Used for files that cannot live in the APEX, like
+	// init rc or permissions XML files. The modules must not be in the payload of this APEX.
+	Companion_files []string
+
 	// The minimum SDK version that this APEX must support at minimum. This is usually set to
 	// the SDK version that the APEX was first introduced.
 	Min_sdk_version *string
@@ -706,6 +713,19 @@ var (
 	shBinaryTag     = &dependencyTag{name: "shBinary", payload: true}
 )
 
+// companionFileDependencyTag is the dependency tag of the modules in companion_files. They are
+// installed along with the APEX, and never become part of its payload.
+type companionFileDependencyTag struct {
+	blueprint.BaseDependencyTag
+	android.InstallAlwaysNeededDependencyTag
+}
+
+func (companionFileDependencyTag) ExcludeFromApexContents() {}
+
+var _ android.ExcludeFromApexContentsTag = companionFileDependencyTag{}
+
+var companionFileTag = companionFileDependencyTag{}
+
 // TODO(jiyong): shorten this function signature
 func addDependenciesForNativeModules(ctx android.BottomUpMutatorContext, nativeModules ApexNativeDependencies, target android.Target, imageVariation string) {
 	binVariations := target.Variations()
@@ -877,6 +897,19 @@ func (a *apexBundle) DepsMutator(ctx android.BottomUpMutatorContext) {
 	ctx.AddFarVariationDependencies(commonVariation, javaLibTag, a.properties.Java_libs...)
 	ctx.AddFarVariationDependencies(commonVariation, fsTag, a.properties.Filesystems...)
 	ctx.AddFarVariationDependencies(commonVariation, compatConfigTag, a.properties.Compat_configs...)
+
+	// Companion files are installed on the device outside of the APEX, from the variant for the
+	// primary device architecture, or else from the common architecture variant.
+	if ctx.Device() && len(targets) > 0 {
+		for _, companion := range a.properties.Companion_files {
+			variations := targets[0].Variations()
+			if !ctx.OtherModuleFarDependencyVariantExists(variations, companion) &&
+				ctx.OtherModuleFarDependencyVariantExists(commonVariation, companion) {
+				variations = commonVariation
+			}
+			ctx.AddFarVariationDependencies(variations, companionFileTag, companion)
+		}
+	}
 }
 
 // DepsMutator for the overridden properties.
@@ -1012,12 +1045,11 @@ func (a *apexBundle) ApexInfoMutator(mctx android.TopDownMutatorContext) {
 		if !ok || !am.CanHaveApexVariants() {
 			return false
 		}
-		depTag := mctx.OtherModuleDependencyTag(child)
 
 		// Check to see if the tag always requires that the child module has an apex variant for every
 		// apex variant of the parent module. If it does not then it is still possible for something
 		// else, e.g. the DepIsInSameApex(...) method to decide that a variant is required.
-		if required, ok := depTag.(android.AlwaysRequireApexVariantTag); ok && required.AlwaysRequireApexVariant() {
+		if mctx.OtherModuleDependencyTagCapabilities(child).AlwaysRequireApexVariant {
 			return true
 		}
 		if !android.IsDepInSameApex(mctx, parent, child) {
@@ -1482,6 +1514,30 @@ func (a *apexBundle) OutputFiles(tag string) (android.Paths, error) {
 	}
 }
 
+var _ android.SizeAttributionContainer = (*apexBundle)(nil)
+
+// SizeAttributionContents implements android.SizeAttributionContainer.  The files of a flattened
+// apex are installed on their own, so only the contents of image apexes are returned.
+func (a *apexBundle) SizeAttributionContents() (android.InstallPath, []android.SizeAttributionContent) {
+	if a.properties.ApexType != imageApex {
+		return android.InstallPath{}, nil
+	}
+	var contents []android.SizeAttributionContent
+	for _, fi := range a.filesInfo {
+		name := fi.androidMkModuleName
+		if fi.module != nil {
+			name = fi.module.Name()
+		}
+		contents = append(contents, android.SizeAttributionContent{
+			Path:      fi.path(),
+			BuiltFile: fi.builtFile,
+			Name:      name,
+			Module:    fi.module,
+		})
+	}
+	return a.installedFile, contents
+}
+
 var _ multitree.Exportable = (*apexBundle)(nil)
 
 func (a *apexBundle) Exportable() bool {
@@ -1905,10 +1961,10 @@ func (a *apexBundle) WalkPayloadDeps(ctx android.ModuleContext, do android.Paylo
 		}
 
 		// Filter-out unwanted depedendencies
-		depTag := ctx.OtherModuleDependencyTag(child)
-		if _, ok := depTag.(android.ExcludeFromApexContentsTag); ok {
+		if ctx.OtherModuleDependencyTagCapabilities(child).ExcludeFromApexContents {
 			return false
 		}
+		depTag := ctx.OtherModuleDependencyTag(child)
 		if dt, ok := depTag.(*dependencyTag); ok && !dt.payload {
 			return false
 		}
@@ -2214,7 +2270,7 @@ func (vctx *visitorContext) normalizeFileInfo(mctx android.ModuleContext) {
 
 func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext, child, parent blueprint.Module) bool {
 	depTag := ctx.OtherModuleDependencyTag(child)
-	if _, ok := depTag.(android.ExcludeFromApexContentsTag); ok {
+	if ctx.OtherModuleDependencyTagCapabilities(child).ExcludeFromApexContents {
 		return false
 	}
 	if mod, ok := child.(android.Module); ok && !mod.Enabled() {
@@ -2241,6 +2297,10 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 				fi := apexFileForRustLibrary(ctx, ch)
 				fi.isJniLib = isJniLib
 				vctx.filesInfo = append(vctx.filesInfo, fi)
+				// Rust libraries with stubs provide them like cc libraries.
+				if ch.HasStubsVariants() && !a.vndkApex {
+					vctx.provideNativeLibs = append(vctx.provideNativeLibs, fi.stem())
+				}
 				return true // track transitive dependencies
 			default:
 				propertyName := "native_shared_libs"
@@ -2474,6 +2534,22 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 		} else if rm, ok := child.(*rust.Module); ok {
 			af := apexFileForRustLibrary(ctx, rm)
 			af.transitiveDep = true
+
+			abInfo := ctx.Provider(ApexBundleInfoProvider).(ApexBundleInfo)
+			if !a.Host() && !abInfo.Contents.DirectlyInApex(depName) && rm.HasStubsVariants() {
+				// As for cc libraries with stubs, don't include the library in this APEX but
+				// make sure that it is installed on the device.
+				if !am.DirectlyInAnyApex() && !ctx.Config().UnbundledBuild() {
+					name := rm.BaseModuleName() + rm.Properties.SubName
+					if !android.InList(name, a.makeModulesToInstall) {
+						a.makeModulesToInstall = append(a.makeModulesToInstall, name)
+					}
+				}
+				vctx.requireNativeLibs = append(vctx.requireNativeLibs, af.stem())
+				// Don't track further
+				return false
+			}
+
 			vctx.filesInfo = append(vctx.filesInfo, af)
 			return true // track transitive dependencies
 		}
@@ -2540,7 +2616,7 @@ func (a *apexBundle) depVisitor(vctx *visitorContext, ctx android.ModuleContext,
 			ctx.PropertyErrorf("systemserverclasspath_fragments",
 				"systemserverclasspath_fragment content %q of type %q is not supported", depName, ctx.OtherModuleType(child))
 		}
-	} else if _, ok := depTag.(android.CopyDirectlyInAnyApexTag); ok {
+	} else if ctx.OtherModuleDependencyTagCapabilities(child).CopyDirectlyInAnyApex {
 		// nothing
 	} else if depTag == android.DarwinUniversalVariantTag {
 		// nothing
@@ -2603,6 +2679,7 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	// 3) some fields in apexBundle struct are configured
 	a.installDir = android.PathForModuleInstall(ctx, "apex")
 	a.filesInfo = vctx.filesInfo
+	a.checkCompanionFiles(ctx)
 
 	a.setApexTypeAndSuffix(ctx)
 	a.setPayloadFsType(ctx)
@@ -3058,21 +3135,29 @@ func (a *apexBundle) checkJavaStableSdkVersion(ctx android.ModuleContext) {
 
 // checkApexAvailability ensures that the all the dependencies are marked as available for this APEX.
 func (a *apexBundle) checkApexAvailability(ctx android.ModuleContext) {
+	check := true
+
 	// Let's be practical. Availability for test, host, and the VNDK apex isn't important
 	if ctx.Host() || a.testApex || a.vndkApex {
-		return
+		check = false
 	}
 
 	// Because APEXes targeting other than system/system_ext partitions can't set
 	// apex_available, we skip checks for these APEXes
 	if a.SocSpecific() || a.DeviceSpecific() || (a.ProductSpecific() && ctx.Config().EnforceProductPartitionInterface()) {
-		return
+		check = false
 	}
 
 	// Coverage build adds additional dependencies for the coverage-only runtime libraries.
 	// Requiring them and their transitive depencies with apex_available is not right
 	// because they just add noise.
 	if ctx.Config().IsEnvTrue("EMMA_INSTRUMENT") || a.IsNativeCoverageNeeded(ctx) {
+		check = false
+	}
+
+	// The apex_available audit still needs the contents of the APEXes that are not checked, as
+	// replacing the wildcard with a list that misses them would drop the modules from them.
+	if !check && !apexAvailableAuditEnabled(ctx.Config()) {
 		return
 	}
 
@@ -3096,7 +3181,10 @@ func (a *apexBundle) checkApexAvailability(ctx android.ModuleContext) {
 			return false
 		}
 
-		if to.AvailableFor(apexName) || baselineApexAvailable(apexName, toName) {
+		if android.InList(android.AvailableToAnyApex, to.ApexAvailable()) {
+			recordAnyApexInclusion(ctx, toName, apexName)
+		}
+		if !check || to.AvailableFor(apexName) || baselineApexAvailable(apexName, toName) {
 			return true
 		}
 		ctx.ModuleErrorf("%q requires %q that doesn't list the APEX under 'apex_available'."+
@@ -3131,6 +3219,31 @@ func (a *apexBundle) checkStaticExecutables(ctx android.ModuleContext) {
 	})
 }
 
+// checkCompanionFiles ensures that the modules in companion_files are not also in the payload of
+// the APEX, where they would be installed twice.
+func (a *apexBundle) checkCompanionFiles(ctx android.ModuleContext) {
+	if len(a.properties.Companion_files) == 0 {
+		return
+	}
+	if ctx.Host() {
+		ctx.PropertyErrorf("companion_files", "is only supported for device APEXes")
+		return
+	}
+
+	var payloadNames []string
+	for _, fi := range a.filesInfo {
+		if fi.module != nil {
+			payloadNames = append(payloadNames, fi.module.Name())
+		}
+	}
+	for _, companion := range android.FirstUniqueStrings(a.properties.Companion_files) {
+		if android.InList(companion, payloadNames) {
+			ctx.PropertyErrorf("companion_files", "%q is also in the payload of the APEX, "+
+				"companion files must be installed outside of the APEX", companion)
+		}
+	}
+}
+
 // A small list of exceptions where static executables are allowed in APEXes.
 func isStaticExecutableAllowed(apex string, exec string) bool {
 	m := map[string][]string{
@@ -0,0 +1,130 @@
+// Copyright (C) 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apex
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"android/soong/android"
+)
+
+// The apex_available audit finds the modules that use apex_available: ["//apex_available:anyapex"]
+// and suggests the minimal list of apexes that would replace it, i.e. the apexes that include the
+// module in the current build.  It is enabled by setting SOONG_APEX_AVAILABLE_AUDIT to true, which
+// writes the suggestions to out/soong/apex_available_suggestions.txt, or to strict, which also
+// reports an error for every module outside of the AnyApexAllowedPaths product variable that still
+// uses the wildcard.
+//
+// The apexes that include each module are recorded while checkApexAvailability walks the payload
+// of each apex, so the audit does not walk the dependencies again.  The walk covers the test,
+// vendor and coverage apexes whose availability is not checked too, so the suggestions list every
+// apex that includes the module.
+
+const apexAvailableAuditEnv = "SOONG_APEX_AVAILABLE_AUDIT"
+
+func apexAvailableAuditEnabled(config android.Config) bool {
+	return config.IsEnvTrue(apexAvailableAuditEnv) || apexAvailableAuditStrict(config)
+}
+
+func apexAvailableAuditStrict(config android.Config) bool {
+	return config.Getenv(apexAvailableAuditEnv) == "strict"
+}
+
+// anyApexInclusions records the apexes that include each module that is available to any apex.
+type anyApexInclusions struct {
+	lock   sync.Mutex
+	apexes map[string][]string
+}
+
+var anyApexInclusionsKey = android.NewOnceKey("anyApexInclusions")
+
+func getAnyApexInclusions(config android.Config) *anyApexInclusions {
+	return config.Once(anyApexInclusionsKey, func() interface{} {
+		return &anyApexInclusions{apexes: make(map[string][]string)}
+	}).(*anyApexInclusions)
+}
+
+// recordAnyApexInclusion records that the module named module, which is available to any apex, is
+// included in the apex named apexName.
+func recordAnyApexInclusion(ctx android.ModuleContext, module, apexName string) {
+	if !apexAvailableAuditEnabled(ctx.Config()) {
+		return
+	}
+	inclusions := getAnyApexInclusions(ctx.Config())
+	inclusions.lock.Lock()
+	defer inclusions.lock.Unlock()
+	if !android.InList(apexName, inclusions.apexes[module]) {
+		inclusions.apexes[module] = append(inclusions.apexes[module], apexName)
+	}
+}
+
+func apexAvailableAuditSingletonFactory() android.Singleton {
+	return &apexAvailableAuditSingleton{}
+}
+
+type apexAvailableAuditSingleton struct{}
+
+func (s *apexAvailableAuditSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !apexAvailableAuditEnabled(ctx.Config()) {
+		return
+	}
+	inclusions := getAnyApexInclusions(ctx.Config())
+	strict := apexAvailableAuditStrict(ctx.Config())
+
+	seen := make(map[string]bool)
+	var lines []string
+	ctx.VisitAllModules(func(module android.Module) {
+		apexModule, ok := module.(android.ApexModule)
+		if !ok || !android.InList(android.AvailableToAnyApex, apexModule.ApexAvailable()) {
+			return
+		}
+		name := ctx.ModuleName(module)
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+
+		available := android.SortedUniqueStrings(inclusions.apexes[name])
+		if android.InList(android.AvailableToPlatform, apexModule.ApexAvailable()) {
+			available = append([]string{android.AvailableToPlatform}, available...)
+		}
+		suggestion := "not included in any apex, remove apex_available"
+		if len(available) > 0 {
+			suggestion = fmt.Sprintf("apex_available: [%s]", strings.Join(quoteStrings(available), ", "))
+		}
+		dir := ctx.ModuleDir(module)
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", name, dir, suggestion))
+
+		if strict && !ctx.Config().AnyApexAllowedForPath(dir) {
+			ctx.ModuleErrorf(module, "uses %q outside of the allowed paths, replace it with %s",
+				android.AvailableToAnyApex, suggestion)
+		}
+	})
+	sort.Strings(lines)
+
+	android.WriteFileRule(ctx, android.PathForOutput(ctx, "apex_available_suggestions.txt"),
+		strings.Join(lines, "\n"))
+}
+
+func quoteStrings(s []string) []string {
+	ret := make([]string, len(s))
+	for i, str := range s {
+		ret[i] = fmt.Sprintf("%q", str)
+	}
+	return ret
+}
@@ -1010,6 +1010,84 @@ func TestApexWithStubs(t *testing.T) {
 	ensureListContains(t, names(apexManifestRule.Args["requireNativeLibs"]), "libfoo.shared_from_rust.so")
 }
 
+func TestApexWithRustFfiStubs(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["libfoo_ffi"],
+			updatable: false,
+		}
+
+		apex {
+			name: "otherapex",
+			key: "myapex.key",
+			native_shared_libs: ["libbar"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		rust_ffi_shared {
+			name: "libfoo_ffi",
+			srcs: ["foo.rs"],
+			crate_name: "foo_ffi",
+			apex_available: ["myapex"],
+			stubs: {
+				symbol_file: "libfoo_ffi.map.txt",
+				versions: ["29", "30"],
+			},
+		}
+
+		cc_library {
+			name: "libbar",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["libfoo_ffi"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["otherapex"],
+		}
+
+		cc_binary {
+			name: "mybin",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["libfoo_ffi"],
+			system_shared_libs: [],
+			stl: "none",
+		}
+	`, withFiles(map[string][]byte{
+		"libfoo_ffi.map.txt": nil,
+	}))
+
+	// The stubs of the rust library are generated by ndkstubgen as for cc libraries, with --apex
+	// as the library is only available to an APEX.
+	ensureContains(t, ctx.ModuleForTests("libfoo_ffi.stubs", "android_arm64_armv8-a_shared_30").Rule("genStubSrc").Args["flags"], "--apex")
+
+	// Ensure that the platform binary links against the latest stubs of the rust library
+	mybinLdFlags := ctx.ModuleForTests("mybin", "android_arm64_armv8-a").Rule("ld").Args["libFlags"]
+	ensureContains(t, mybinLdFlags, "libfoo_ffi.stubs/android_arm64_armv8-a_shared_current/libfoo_ffi.so")
+	ensureNotContains(t, mybinLdFlags, "libfoo_ffi/android_arm64_armv8-a_shared/libfoo_ffi.so")
+
+	// Ensure that a library in another APEX links against the stubs too
+	libbarLdFlags := ctx.ModuleForTests("libbar", "android_arm64_armv8-a_shared_apex10000").Rule("ld").Args["libFlags"]
+	ensureContains(t, libbarLdFlags, "libfoo_ffi.stubs/android_arm64_armv8-a_shared_current/libfoo_ffi.so")
+
+	// The rust library is in myapex, which provides it, and not in otherapex, which requires it.
+	copyCmds := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexRule").Args["copy_commands"]
+	ensureContains(t, copyCmds, "image.apex/lib64/libfoo_ffi.so")
+	apexManifestRule := ctx.ModuleForTests("myapex", "android_common_myapex_image").Rule("apexManifestRule")
+	ensureListContains(t, names(apexManifestRule.Args["provideNativeLibs"]), "libfoo_ffi.so")
+
+	copyCmds = ctx.ModuleForTests("otherapex", "android_common_otherapex_image").Rule("apexRule").Args["copy_commands"]
+	ensureNotContains(t, copyCmds, "image.apex/lib64/libfoo_ffi.so")
+	apexManifestRule = ctx.ModuleForTests("otherapex", "android_common_otherapex_image").Rule("apexManifestRule")
+	ensureListContains(t, names(apexManifestRule.Args["requireNativeLibs"]), "libfoo_ffi.so")
+}
+
 func TestApexCanUsePrivateApis(t *testing.T) {
 	ctx := testApex(t, `
 		apex {
@@ -6482,6 +6560,128 @@ func TestApexAvailable_IndirectDep(t *testing.T) {
 	}`)
 }
 
+var apexAvailableAuditTestBp = `
+	apex {
+		name: "myapex",
+		key: "myapex.key",
+		native_shared_libs: ["libfoo"],
+		updatable: false,
+	}
+
+	apex {
+		name: "otherapex",
+		key: "myapex.key",
+		native_shared_libs: ["libfoo", "libbar"],
+		updatable: false,
+	}
+
+	apex_key {
+		name: "myapex.key",
+		public_key: "testkey.avbpubkey",
+		private_key: "testkey.pem",
+	}
+
+	cc_library {
+		name: "libfoo",
+		stl: "none",
+		system_shared_libs: [],
+		apex_available: ["//apex_available:anyapex"],
+	}
+
+	cc_library {
+		name: "libbar",
+		stl: "none",
+		system_shared_libs: [],
+		apex_available: ["//apex_available:platform", "//apex_available:anyapex"],
+	}
+
+	cc_library {
+		name: "libbaz",
+		stl: "none",
+		system_shared_libs: [],
+		apex_available: ["//apex_available:anyapex"],
+	}
+`
+
+func TestApexAvailableAudit(t *testing.T) {
+	ctx := testApex(t, apexAvailableAuditTestBp, android.FixtureMergeEnv(map[string]string{
+		"SOONG_APEX_AVAILABLE_AUDIT": "true",
+	}))
+
+	suggestions := android.ContentFromFileRuleForTests(t,
+		ctx.SingletonForTests("apex_available_audit").Output("apex_available_suggestions.txt"))
+	// The test fixture defines other modules that are available to any apex, only check the
+	// modules of this test.
+	android.AssertStringDoesContain(t, "libfoo suggestion", suggestions,
+		`libfoo (.): apex_available: ["myapex", "otherapex"]`+"\n")
+	android.AssertStringDoesContain(t, "libbar suggestion", suggestions,
+		`libbar (.): apex_available: ["//apex_available:platform", "otherapex"]`+"\n")
+	android.AssertStringDoesContain(t, "libbaz suggestion", suggestions,
+		`libbaz (.): not included in any apex, remove apex_available`+"\n")
+}
+
+func TestApexAvailableAuditUncheckedApexes(t *testing.T) {
+	// The availability is not checked for test and vendor apexes, but they are still audited.
+	ctx := testApex(t, `
+		apex_test {
+			name: "mytestapex",
+			key: "myapex.key",
+			native_shared_libs: ["libfoo"],
+			updatable: false,
+		}
+
+		apex {
+			name: "myvendorapex",
+			key: "myapex.key",
+			native_shared_libs: ["libvendor"],
+			vendor: true,
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "libfoo",
+			stl: "none",
+			system_shared_libs: [],
+			apex_available: ["//apex_available:anyapex"],
+		}
+
+		cc_library {
+			name: "libvendor",
+			proprietary: true,
+			stl: "none",
+			system_shared_libs: [],
+			apex_available: ["//apex_available:anyapex"],
+		}
+	`, android.FixtureMergeEnv(map[string]string{
+		"SOONG_APEX_AVAILABLE_AUDIT": "true",
+	}))
+
+	suggestions := android.ContentFromFileRuleForTests(t,
+		ctx.SingletonForTests("apex_available_audit").Output("apex_available_suggestions.txt"))
+	android.AssertStringDoesContain(t, "libfoo suggestion", suggestions,
+		`libfoo (.): apex_available: ["mytestapex"]`)
+	android.AssertStringDoesContain(t, "libvendor suggestion", suggestions,
+		`libvendor (.): apex_available: ["myvendorapex"]`)
+}
+
+func TestApexAvailableAuditStrict(t *testing.T) {
+	testApexError(t, `module "libfoo".*: uses "//apex_available:anyapex" outside of the allowed paths, replace it with apex_available: \["myapex", "otherapex"\]`,
+		apexAvailableAuditTestBp,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_APEX_AVAILABLE_AUDIT": "strict",
+		}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AnyApexAllowedPaths = []string{"vendor"}
+		}),
+	)
+}
+
 func TestApexAvailable_InvalidApexName(t *testing.T) {
 	testApexError(t, "\"otherapex\" is not a valid module name", `
 	apex {
@@ -7358,6 +7558,77 @@ func TestCarryRequiredModuleNames(t *testing.T) {
 	ensureContains(t, androidMk, "LOCAL_TARGET_REQUIRED_MODULES := e f\n")
 }
 
+func TestApexCompanionFiles(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			companion_files: ["myapex.rc"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: [ "myapex" ],
+		}
+
+		prebuilt_etc {
+			name: "myapex.rc",
+			src: "myapex.rc",
+			sub_dir: "init",
+		}
+	`)
+
+	module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+	apexBundle := module.Module().(*apexBundle)
+
+	// The companion file is installed outside of the APEX, along with it.
+	install := module.Output("out/soong/target/product/test_device/system/apex/myapex.apex")
+	android.AssertPathsRelativeToTopEquals(t, "install deps",
+		[]string{"out/soong/target/product/test_device/system/etc/init/myapex.rc"}, install.OrderOnly)
+	ensureNotContains(t, module.Rule("apexRule").Args["copy_commands"], "myapex.rc")
+
+	data := android.AndroidMkDataForTest(t, ctx, apexBundle)
+	name := apexBundle.BaseModuleName()
+	var builder strings.Builder
+	data.Custom(&builder, name, "TARGET_", "", data)
+	androidMk := builder.String()
+	ensureContains(t, androidMk, "LOCAL_REQUIRED_MODULES := mylib.myapex:64 apex_manifest.pb.myapex apex_pubkey.myapex myapex.rc\n")
+}
+
+func TestApexCompanionFilesInPayload(t *testing.T) {
+	testApexError(t, `companion_files: "myapex.rc" is also in the payload of the APEX`, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			prebuilts: ["myapex.rc"],
+			companion_files: ["myapex.rc"],
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		prebuilt_etc {
+			name: "myapex.rc",
+			src: "myapex.rc",
+		}
+	`)
+}
+
 func TestSymlinksFromApexToSystem(t *testing.T) {
 	bp := `
 		apex {
@@ -10473,3 +10744,49 @@ func TestFileSystemShouldSkipApexLibraries(t *testing.T) {
 		inputs.Strings(),
 		"out/soong/.intermediates/libbar/android_arm64_armv8-a_shared/libbar.so")
 }
+
+func TestApexSizeAttribution(t *testing.T) {
+	ctx := testApex(t, `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			java_libs: ["myjar"],
+			team: "team_apex",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			team: "team_lib",
+			apex_available: ["myapex"],
+		}
+
+		java_library {
+			name: "myjar",
+			srcs: ["foo/bar/MyClass.java"],
+			sdk_version: "none",
+			system_modules: "none",
+			apex_available: ["myapex"],
+		}
+	`, android.FixtureRegisterWithContext(android.RegisterSizeAttributionBuildComponents))
+
+	content := android.ContentFromFileRuleForTests(t,
+		ctx.SingletonForTests("size_attribution").Output("size_attribution_files.csv"))
+
+	// The files in the apex are attributed to the team of their module, or to the team of the
+	// apex, instead of the apex file itself.
+	ensureContains(t, content, "/system/apex/myapex.apex!/lib64/mylib.so,mylib,team_lib,system,")
+	ensureContains(t, content, "/system/apex/myapex.apex!/javalib/myjar.jar,myjar,team_apex,system,")
+	ensureContains(t, content, "/system/apex/myapex.apex!/apex_manifest.pb,apex_manifest.pb,team_apex,system,")
+	ensureNotContains(t, content, "/system/apex/myapex.apex,")
+}
@@ -627,7 +627,7 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		}
 
 		if a.properties.AndroidManifest != nil {
-			androidManifestFile := android.PathForModuleSrc(ctx, proptools.String(a.properties.AndroidManifest))
+			androidManifestFile := android.PathForModuleSrcOrModule(ctx, proptools.String(a.properties.AndroidManifest))
 
 			if a.testApex {
 				androidManifestFile = markManifestTestOnly(ctx, androidManifestFile)
@@ -35,16 +35,6 @@ func TestJavaProto(t *testing.T) {
 		javaLibraryType          string
 		javaLibraryNameExtension string
 	}{
-		{
-			protoType:                "nano",
-			javaLibraryType:          "java_nano_proto_library",
-			javaLibraryNameExtension: "java_proto_nano",
-		},
-		{
-			protoType:                "micro",
-			javaLibraryType:          "java_micro_proto_library",
-			javaLibraryNameExtension: "java_proto_micro",
-		},
 		{
 			protoType:                "lite",
 			javaLibraryType:          "java_lite_proto_library",
@@ -97,6 +87,22 @@ func TestJavaProto(t *testing.T) {
 	}
 }
 
+func TestJavaProtoRemovedTypes(t *testing.T) {
+	for _, protoType := range []string{"nano", "micro"} {
+		runJavaProtoTestCase(t, Bp2buildTestCase{
+			Description: fmt.Sprintf("java_proto %s", protoType),
+			Blueprint: fmt.Sprintf(`java_library_static {
+    name: "java-protos",
+    proto: {
+        type: "%s",
+    },
+    srcs: ["a.proto"],
+}`, protoType),
+			ExpectedErr: fmt.Errorf(`%q java protos are no longer supported, use "lite" instead`, protoType),
+		})
+	}
+}
+
 func TestJavaProtoDefault(t *testing.T) {
 	runJavaProtoTestCase(t, Bp2buildTestCase{
 		Description: "java_library proto default",
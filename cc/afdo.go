@@ -32,12 +32,42 @@ var (
 	}
 )
 
-var afdoProfileProjectsConfigKey = android.NewOnceKey("AfdoProfileProjects")
-
 const afdoCFlagsFormat = "-fprofile-sample-use=%s"
 
-func recordMissingAfdoProfileFile(ctx android.BaseModuleContext, missing string) {
-	getNamedMapForConfig(ctx.Config(), modulesMissingProfileFileKey).Store(missing, true)
+// Machine function splitting moves the code that the profile shows to be cold out of the hot
+// functions, which is where most of the benefit of afdo comes from.
+const splitMachineFunctionsCFlag = "-fsplit-machine-functions"
+
+// The code of LTO modules is generated by the linker, which needs to split the functions too.
+const splitMachineFunctionsLdFlag = "-Wl,-mllvm,-enable-split-machine-functions"
+
+// AfdoProfileFromProfileDirs returns the first <module>_<arch>.afdo or <module>.afdo profile found
+// in the directories set by the product in PRODUCT_AFDO_PROFILE_DIRS, which are searched in order,
+// for the modules that have no fdo_profile in AFDO_PROFILES.  If the product sets directories and
+// none of them has a profile for the module, the module is reported to Make in
+// SOONG_MODULES_MISSING_PGO_PROFILE_FILE.
+func AfdoProfileFromProfileDirs(ctx android.BaseModuleContext) android.OptionalPath {
+	dirs := ctx.DeviceConfig().AfdoProfileDirs()
+	if len(dirs) == 0 {
+		return android.OptionalPath{}
+	}
+
+	name := ctx.ModuleName()
+	profileNames := []string{
+		name + "_" + ctx.Arch().ArchType.String() + ".afdo",
+		name + ".afdo",
+	}
+	for _, dir := range dirs {
+		for _, profileName := range profileNames {
+			if path := android.ExistentPathForSource(ctx, dir, profileName); path.Valid() {
+				return path
+			}
+		}
+	}
+
+	getNamedMapForConfig(ctx.Config(), modulesMissingProfileFileKey).Store(
+		name+".afdo:"+ctx.ModuleDir()+"/Android.bp:"+name, true)
+	return android.OptionalPath{}
 }
 
 type afdoRdep struct {
@@ -50,6 +80,11 @@ type AfdoProperties struct {
 	// automatic feedback-directed optimization using profile data.
 	Afdo bool
 
+	// Whether to split the functions of the module into hot and cold parts when it is built with
+	// an afdo profile.  Only supported on arm64 and x86_64, and not with full LTO.  Defaults to
+	// true.
+	Afdo_split_machine_functions *bool
+
 	FdoProfilePath *string `blueprint:"mutated"`
 
 	AfdoRDeps []afdoRdep `blueprint:"mutated"`
@@ -95,11 +130,40 @@ func (afdo *afdo) flags(ctx ModuleContext, flags Flags) Flags {
 		pathForSrc := android.PathForSource(ctx, *path)
 		flags.CFlagsDeps = append(flags.CFlagsDeps, pathForSrc)
 		flags.LdFlagsDeps = append(flags.LdFlagsDeps, pathForSrc)
+
+		if afdo.splitMachineFunctions(ctx) {
+			flags.Local.CFlags = append(flags.Local.CFlags, splitMachineFunctionsCFlag)
+			flags.Local.LdFlags = append(flags.Local.LdFlags, splitMachineFunctionsLdFlag)
+		}
+	}
+
+	// Machine function splitting doesn't work with the instrumentation of the address sanitizers
+	// and the fuzzer, remove it even if the module asked for it explicitly.
+	if c, ok := ctx.Module().(*Module); ok && c.sanitize != nil &&
+		(c.sanitize.isSanitizerEnabled(Asan) || c.sanitize.isSanitizerEnabled(Hwasan) || c.sanitize.isSanitizerEnabled(Fuzzer)) {
+		_, flags.Local.CFlags = removeFromList(splitMachineFunctionsCFlag, flags.Local.CFlags)
+		_, flags.Local.LdFlags = removeFromList(splitMachineFunctionsLdFlag, flags.Local.LdFlags)
 	}
 
 	return flags
 }
 
+// splitMachineFunctions returns true if the machine functions of a module built with an afdo
+// profile should be split.  Splitting is not supported on 32-bit arm and is of no use with full
+// LTO.
+func (afdo *afdo) splitMachineFunctions(ctx ModuleContext) bool {
+	if !proptools.BoolDefault(afdo.Properties.Afdo_split_machine_functions, true) {
+		return false
+	}
+	if arch := ctx.Arch().ArchType; arch != android.Arm64 && arch != android.X86_64 {
+		return false
+	}
+	if c, ok := ctx.Module().(*Module); ok && c.lto.FullLTO() {
+		return false
+	}
+	return true
+}
+
 func (afdo *afdo) addDep(ctx BaseModuleContext, actx android.BottomUpMutatorContext) {
 	if ctx.Host() {
 		return
@@ -136,6 +200,15 @@ func (c *Module) fdoProfileMutator(ctx android.BottomUpMutatorContext) {
 			c.afdo.Properties.FdoProfilePath = proptools.StringPtr(info.Path.String())
 		}
 	})
+
+	// The modules that addDep would have added an fdo_profile dependency to use the profile in the
+	// profile directories if they have none.
+	if c.afdo.afdoEnabled() && c.afdo.Properties.FdoProfilePath == nil && !ctx.Host() &&
+		(!c.static() || c.staticBinary()) {
+		if profile := AfdoProfileFromProfileDirs(ctx); profile.Valid() {
+			c.afdo.Properties.FdoProfilePath = proptools.StringPtr(profile.Path().String())
+		}
+	}
 }
 
 var _ FdoProfileMutatorInterface = (*Module)(nil)
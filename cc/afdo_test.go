@@ -280,17 +280,82 @@ func TestAfdoEnabledWithMultiArchs(t *testing.T) {
 		}.AddToFixture(),
 	).RunTestWithBp(t, bp)
 
-	fooArm := result.ModuleForTests("foo", "android_arm_armv7-a-neon_shared")
-	fooArmCFlags := fooArm.Rule("cc").Args["cFlags"]
-	if w := "-fprofile-sample-use=afdo_profiles_package/foo_arm.afdo"; !strings.Contains(fooArmCFlags, w) {
-		t.Errorf("Expected 'foo' to enable afdo, but did not find %q in cflags %q", w, fooArmCFlags)
+	// Both the arm and arm64 variants must exist for the per-variant checks below to be meaningful.
+	result.ModuleForTests("foo", "android_arm_armv7-a-neon_shared")
+	result.ModuleForTests("foo", "android_arm64_armv8-a_shared")
+
+	result.ForEachDeviceVariant(t, "foo", func(t *testing.T, variant string, m android.TestingModule) {
+		cFlags := m.Rule("cc").Args["cFlags"]
+		w := "-fprofile-sample-use=afdo_profiles_package/foo_" + m.Target().Arch.ArchType.String() + ".afdo"
+		if !strings.Contains(cFlags, w) {
+			t.Errorf("Expected 'foo' to enable afdo, but did not find %q in cflags %q", w, cFlags)
+		}
+	})
+}
+
+func TestAfdoProfileDirs(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "foo",
+		srcs: ["test.c"],
+		afdo: true,
+		compile_multilib: "both",
 	}
+`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithFdoProfile,
+		prepareForCcTest,
+		android.FixtureAddTextFile("bar/Android.bp", `
+			cc_library_shared {
+				name: "bar",
+				srcs: ["test.c"],
+				afdo: true,
+			}
+		`),
+		// The vendor directory is searched first, its profile for all the arches shadows the arch
+		// specific profile of the device directory.
+		android.FixtureAddTextFile("vendor/afdo_profiles/foo.afdo", ""),
+		android.FixtureAddTextFile("device/afdo_profiles/foo_arm64.afdo", ""),
+		android.FixtureAddTextFile("device/afdo_profiles/foo_arm.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfileDirs = []string{"vendor/afdo_profiles", "device/afdo_profiles"}
+		}),
+	).RunTestWithBp(t, bp)
+
+	// Both the arm and arm64 variants must exist for the per-variant checks below to be meaningful.
+	result.ModuleForTests("foo", "android_arm_armv7-a-neon_shared")
+	result.ModuleForTests("foo", "android_arm64_armv8-a_shared")
+
+	result.ForEachDeviceVariant(t, "foo", func(t *testing.T, variant string, m android.TestingModule) {
+		cFlags := m.Rule("cc").Args["cFlags"]
+		android.AssertStringDoesContain(t, "cFlags", cFlags, "-fprofile-sample-use=vendor/afdo_profiles/foo.afdo")
+	})
 
-	fooArm64 := result.ModuleForTests("foo", "android_arm64_armv8-a_shared")
-	fooArm64CFlags := fooArm64.Rule("cc").Args["cFlags"]
-	if w := "-fprofile-sample-use=afdo_profiles_package/foo_arm64.afdo"; !strings.Contains(fooArm64CFlags, w) {
-		t.Errorf("Expected 'foo' to enable afdo, but did not find %q in cflags %q", w, fooArm64CFlags)
+	barCFlags := result.ModuleForTests("bar", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesNotContain(t, "bar cFlags", barCFlags, "-fprofile-sample-use")
+	android.AssertArrayString(t, "modules missing profiles", []string{"bar.afdo:bar/Android.bp:bar"},
+		ModulesMissingProfileFileForTests(result.Config))
+}
+
+func TestAfdoProfileDirsUnset(t *testing.T) {
+	bp := `
+	cc_library_shared {
+		name: "foo",
+		srcs: ["test.c"],
+		afdo: true,
 	}
+`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithFdoProfile,
+		prepareForCcTest,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/foo.afdo", ""),
+	).RunTestWithBp(t, bp)
+
+	// Without PRODUCT_AFDO_PROFILE_DIRS no directory is searched, and no module is missing a profile.
+	cFlags := result.ModuleForTests("foo", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+	android.AssertStringDoesNotContain(t, "cFlags", cFlags, "-fprofile-sample-use")
+	android.AssertArrayString(t, "modules missing profiles", nil,
+		ModulesMissingProfileFileForTests(result.Config))
 }
 
 func TestMultipleAfdoRDeps(t *testing.T) {
@@ -461,3 +526,133 @@ func TestAfdoDepsWithoutProfile(t *testing.T) {
 		t.Errorf("libFoo missing dependency on non-afdo variant of libBar")
 	}
 }
+
+func TestAfdoSplitMachineFunctions(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_library_shared {
+		name: "foo",
+		srcs: ["test.c"],
+		afdo: true,
+		compile_multilib: "both",
+	}
+
+	cc_library_shared {
+		name: "foo_no_split",
+		srcs: ["test.c"],
+		afdo: true,
+		afdo_split_machine_functions: false,
+	}
+
+	cc_library_shared {
+		name: "foo_full_lto",
+		srcs: ["test.c"],
+		afdo: true,
+		lto: {
+			full: true,
+		},
+	}
+
+	cc_library_shared {
+		name: "foo_asan",
+		srcs: ["test.c"],
+		afdo: true,
+		cflags: ["-fsplit-machine-functions"],
+		sanitize: {
+			address: true,
+		},
+	}
+`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithFdoProfile,
+		prepareForCcTest,
+		prepareForAsanTest,
+		android.FixtureAddTextFile("afdo_profiles_package/foo.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfiles = []string{
+				"foo://afdo_profiles_package:foo_afdo",
+				"foo_no_split://afdo_profiles_package:foo_afdo",
+				"foo_full_lto://afdo_profiles_package:foo_afdo",
+				"foo_asan://afdo_profiles_package:foo_afdo",
+			}
+		}),
+		android.MockFS{
+			"afdo_profiles_package/Android.bp": []byte(`
+				fdo_profile {
+					name: "foo_afdo",
+					profile: "foo.afdo",
+				}
+			`),
+		}.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	checkSplitMachineFunctions := func(name, variant string, expected bool) {
+		t.Helper()
+		m := result.ModuleForTests(name, variant)
+		cFlags := m.Rule("cc").Args["cFlags"]
+		android.AssertStringContainsEquals(t, name+" "+variant+" cFlags", cFlags, "-fsplit-machine-functions", expected)
+		ldFlags := m.Rule("ld").Args["ldFlags"]
+		android.AssertStringContainsEquals(t, name+" "+variant+" ldFlags", ldFlags, "-Wl,-mllvm,-enable-split-machine-functions", expected)
+	}
+
+	checkSplitMachineFunctions("foo", "android_arm64_armv8-a_shared", true)
+	checkSplitMachineFunctions("foo", "android_arm_armv7-a-neon_shared", false)
+	checkSplitMachineFunctions("foo_no_split", "android_arm64_armv8-a_shared", false)
+	checkSplitMachineFunctions("foo_full_lto", "android_arm64_armv8-a_shared", false)
+	checkSplitMachineFunctions("foo_asan", "android_arm64_armv8-a_shared_asan", false)
+}
+
+func TestSymbolOrderingFromProfile(t *testing.T) {
+	t.Parallel()
+	bp := `
+	cc_binary {
+		name: "foo",
+		srcs: ["test.c"],
+		afdo: true,
+		generate_symbol_ordering_from_profile: true,
+	}
+
+	cc_binary {
+		name: "foo_no_profile",
+		srcs: ["test.c"],
+		afdo: true,
+		generate_symbol_ordering_from_profile: true,
+	}
+`
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithFdoProfile,
+		prepareForCcTest,
+		android.FixtureAddTextFile("afdo_profiles_package/foo.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfiles = []string{
+				"foo://afdo_profiles_package:foo_afdo",
+			}
+		}),
+		android.MockFS{
+			"afdo_profiles_package/Android.bp": []byte(`
+				fdo_profile {
+					name: "foo_afdo",
+					profile: "foo.afdo",
+				}
+			`),
+		}.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	symbolOrdering := foo.Output("symbol_ordering.txt")
+	android.AssertPathRelativeToTopEquals(t, "symbol ordering profile", "afdo_profiles_package/foo.afdo", symbolOrdering.Input)
+	android.AssertStringEquals(t, "symbol ordering profile kind", "--sample", symbolOrdering.Args["profileKind"])
+
+	ld := foo.Rule("ld")
+	android.AssertStringDoesContain(t, "foo ldFlags", ld.Args["ldFlags"],
+		"-Wl,--symbol-ordering-file,out/soong/.intermediates/foo/android_arm64_armv8-a/symbol_ordering.txt")
+	android.AssertStringListContains(t, "foo ld implicits", android.PathsRelativeToTop(ld.Implicits),
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/symbol_ordering.txt")
+
+	fooNoProfile := result.ModuleForTests("foo_no_profile", "android_arm64_armv8-a")
+	if fooNoProfile.MaybeOutput("symbol_ordering.txt").Rule != nil {
+		t.Errorf("expected no symbol ordering rule for foo_no_profile")
+	}
+	android.AssertStringDoesNotContain(t, "foo_no_profile ldFlags", fooNoProfile.Rule("ld").Args["ldFlags"],
+		"--symbol-ordering-file")
+}
@@ -204,13 +204,13 @@ func (library *libraryDecorator) androidMkWriteExportedFlags(entries *android.An
 
 	if library.flagExporterInfo != nil {
 		exportedFlags = library.flagExporterInfo.Flags
-		includeDirs = library.flagExporterInfo.IncludeDirs
-		systemIncludeDirs = library.flagExporterInfo.SystemIncludeDirs
+		includeDirs = library.flagExporterInfo.IncludeDirs.ToList()
+		systemIncludeDirs = library.flagExporterInfo.SystemIncludeDirs.ToList()
 		exportedDeps = library.flagExporterInfo.Deps
 	} else {
 		exportedFlags = library.flagExporter.flags
-		includeDirs = library.flagExporter.dirs
-		systemIncludeDirs = library.flagExporter.systemDirs
+		includeDirs = library.flagExporter.exportedDirs()
+		systemIncludeDirs = library.flagExporter.exportedSystemDirs()
 		exportedDeps = library.flagExporter.deps
 	}
 	for _, dir := range includeDirs {
@@ -347,6 +347,9 @@ func (binary *binaryDecorator) AndroidMkEntries(ctx AndroidMkContext, entries *a
 	entries.DistFiles = binary.distFiles
 	entries.ExtraEntries = append(entries.ExtraEntries, func(_ android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
 		entries.SetString("LOCAL_SOONG_UNSTRIPPED_BINARY", binary.unstrippedOutputFile.String())
+		if binary.dwpSymbolsFile != nil {
+			entries.AddPaths("LOCAL_ADDITIONAL_DEPENDENCIES", android.Paths{binary.dwpSymbolsFile})
+		}
 		if len(binary.symlinks) > 0 {
 			entries.AddStrings("LOCAL_MODULE_SYMLINKS", binary.symlinks...)
 		}
@@ -391,7 +394,7 @@ func (test *testBinary) AndroidMkEntries(ctx AndroidMkContext, entries *android.
 	ctx.subAndroidMk(entries, test.testDecorator)
 
 	entries.Class = "NATIVE_TESTS"
-	if Bool(test.Properties.Test_per_src) {
+	if test.testPerSrc() {
 		entries.SubName = "_" + String(test.binaryDecorator.Properties.Stem)
 	}
 	entries.ExtraEntries = append(entries.ExtraEntries, func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
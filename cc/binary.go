@@ -99,6 +99,12 @@ type binaryDecorator struct {
 	// Location of the linked, unstripped binary
 	unstrippedOutputFile android.Path
 
+	// Location of the .dwp debug package of the binary, when it is built with split DWARF
+	dwpFile android.Path
+
+	// Location of the copy of the .dwp debug package in the symbols directory
+	dwpSymbolsFile android.Path
+
 	// Names of symlinks to be installed for use in LOCAL_MODULE_SYMLINKS
 	symlinks []string
 
@@ -185,7 +191,7 @@ func (binary *binaryDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	}
 
 	if !binary.static() && inList("libc", deps.StaticLibs) {
-		ctx.ModuleErrorf("statically linking libc to dynamic executable, please remove libc\n" +
+		ctx.PropertyErrorf("static_libs", "statically linking libc to dynamic executable, please remove libc\n"+
 			"from static libs or set static_executable: true")
 	}
 
@@ -254,6 +260,8 @@ func (binary *binaryDecorator) binary() bool {
 func (binary *binaryDecorator) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	flags = binary.baseLinker.linkerFlags(ctx, flags)
 
+	flags.SplitDwarf = splitDwarfEnabled(ctx, flags)
+
 	// Passing -pie to clang for Windows binaries causes a warning that -pie is unused.
 	if ctx.Host() && !ctx.Windows() && !binary.static() {
 		if !ctx.Config().IsEnvTrue("DISABLE_HOST_PIE") {
@@ -372,6 +380,12 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 
 	binary.unstrippedOutputFile = outputFile
 
+	if flags.SplitDwarf && len(objs.dwoFiles) > 0 {
+		dwpFile := android.PathForModuleOut(ctx, "unstripped", fileName+".dwp")
+		transformDwoToDwp(ctx, objs.dwoFiles, dwpFile)
+		binary.dwpFile = dwpFile
+	}
+
 	if String(binary.Properties.Prefix_symbols) != "" {
 		afterPrefixSymbols := outputFile
 		outputFile = android.PathForModuleOut(ctx, "unprefixed", fileName)
@@ -429,6 +443,7 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 		linkerDeps = append(linkerDeps, ndkSharedLibDeps(ctx)...)
 	}
 
+	validations = append(validations, binary.maxPageSizeValidations(ctx, outputFile)...)
 	validations = append(validations, objs.tidyDepFiles...)
 	linkerDeps = append(linkerDeps, flags.LdFlagsDeps...)
 
@@ -448,6 +463,30 @@ func (binary *binaryDecorator) link(ctx ModuleContext,
 	return ret
 }
 
+// Setting SOONG_SPLIT_DWARF=true builds the binaries with split DWARF.
+const splitDwarfEnvVar = "SOONG_SPLIT_DWARF"
+
+// splitDwarfEnabled returns true if the debug info of the binary should be split into .dwo files,
+// which reduces the memory used by the link, and packaged into a .dwp file copied next to the
+// unstripped binary in the symbols directory.  Only the sources of the binary itself are compiled
+// with split DWARF, the objects of its static library dependencies are shared with other modules
+// and keep their debug info in the binary.  The sources compiled without debug info are skipped
+// by transformSourceToObj.
+func splitDwarfEnabled(ctx ModuleContext, flags Flags) bool {
+	if !ctx.Config().IsEnvTrue(splitDwarfEnvVar) {
+		return false
+	}
+	// Split DWARF is only supported for ELF files.
+	if ctx.Darwin() || ctx.Windows() {
+		return false
+	}
+	// The debug info of LTO modules is generated by the linker.
+	if c, ok := ctx.Module().(*Module); ok && c.lto != nil && c.lto.LTO(ctx) {
+		return false
+	}
+	return true
+}
+
 func (binary *binaryDecorator) unstrippedOutputFilePath() android.Path {
 	return binary.unstrippedOutputFile
 }
@@ -514,6 +553,23 @@ func (binary *binaryDecorator) install(ctx ModuleContext, file android.Path) {
 	}
 	binary.baseInstaller.installExecutable(ctx, file)
 
+	if binary.dwpFile != nil && ctx.Device() {
+		// Copy the .dwp file next to the unstripped binary that Make copies to the symbols
+		// directory, where the symbolizers look for it.  It is not installed with InstallFile,
+		// which would package it into the partition images.
+		installDir := binary.baseInstaller.installDir(ctx)
+		dwpSymbolsFile := android.PathForModuleInPartitionInstall(ctx,
+			filepath.Join("symbols", installDir.Partition()), installDir.Rel(), binary.dwpFile.Base())
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        android.Cp,
+			Description: "copy " + dwpSymbolsFile.Base(),
+			Input:       binary.dwpFile,
+			Output:      dwpSymbolsFile,
+		})
+		ctx.CheckbuildFile(dwpSymbolsFile)
+		binary.dwpSymbolsFile = dwpSymbolsFile
+	}
+
 	var preferredArchSymlinkPath android.OptionalPath
 	for _, symlink := range binary.symlinks {
 		installedSymlink := ctx.InstallSymlink(binary.baseInstaller.installDir(ctx), symlink,
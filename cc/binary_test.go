@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"strings"
 	"testing"
 
 	"android/soong/bazel/cquery"
@@ -120,3 +121,106 @@ func TestBinaryLinkerScripts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for linker_scripts",
 		binFoo.Args["ldFlags"], "-Wl,--script,bar.ld")
 }
+
+func TestBinaryStaticLibcError(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `Android.bp:5:15: module "foo" variant "android_arm64_armv8-a": static_libs: statically linking libc to dynamic executable`, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.c"],
+			static_libs: ["libc"],
+		}`)
+}
+
+func TestBinarySplitDwarf(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{splitDwarfEnvVar: "true"}),
+	).RunTestWithBp(t, `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cc", "bar.c", "baz.S"],
+		}
+
+		cc_binary {
+			name: "nodebug",
+			srcs: ["nodebug.cc"],
+			cflags: ["-g0"],
+		}
+
+		cc_binary {
+			name: "partial",
+			srcs: ["partial.cc", "nodebug.c"],
+			conlyflags: ["-g0"],
+		}`)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	for _, src := range []string{"foo", "bar"} {
+		compile := foo.Output("obj/" + src + ".o")
+		android.AssertStringDoesContain(t, src+" cFlags", compile.Args["cFlags"], "-gsplit-dwarf")
+		android.AssertPathsRelativeToTopEquals(t, src+" implicit outputs",
+			[]string{"out/soong/.intermediates/foo/android_arm64_armv8-a/obj/" + src + ".dwo"},
+			compile.ImplicitOutputs.Paths())
+	}
+	baz := foo.Output("obj/baz.o")
+	android.AssertStringDoesNotContain(t, "baz cFlags", baz.Args["cFlags"], "-gsplit-dwarf")
+
+	dwp := foo.Rule("dwp")
+	android.AssertPathRelativeToTopEquals(t, "dwp output",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/unstripped/foo.dwp", dwp.Output)
+	android.AssertPathsRelativeToTopEquals(t, "dwp inputs", []string{
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/obj/foo.dwo",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/obj/bar.dwo",
+	}, dwp.Inputs)
+
+	// The .dwp file is copied to the symbols directory, but not installed in the partition.
+	dwpSymbols := foo.Output("symbols/system/bin/foo.dwp")
+	android.AssertPathRelativeToTopEquals(t, "dwp symbols output",
+		"out/soong/target/product/test_device/symbols/system/bin/foo.dwp", dwpSymbols.Output)
+	android.AssertPathRelativeToTopEquals(t, "dwp symbols input",
+		"out/soong/.intermediates/foo/android_arm64_armv8-a/unstripped/foo.dwp", dwpSymbols.Input)
+	installed := android.StringsRelativeToTop(result.Config, foo.Module().FilesToInstall().Strings())
+	android.AssertStringListDoesNotContain(t, "installed dwp", installed,
+		"out/soong/target/product/test_device/symbols/system/bin/foo.dwp")
+	for _, spec := range foo.Module().PackagingSpecs() {
+		if strings.HasSuffix(spec.RelPathInPackage(), ".dwp") {
+			t.Errorf("unexpected packaging spec for %q", spec.RelPathInPackage())
+		}
+	}
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, foo.Module())[0]
+	android.AssertStringPathsRelativeToTopEquals(t, "LOCAL_ADDITIONAL_DEPENDENCIES", result.Config,
+		[]string{"out/soong/target/product/test_device/symbols/system/bin/foo.dwp"},
+		entries.EntryMap["LOCAL_ADDITIONAL_DEPENDENCIES"])
+
+	nodebug := result.ModuleForTests("nodebug", "android_arm64_armv8-a")
+	android.AssertStringDoesNotContain(t, "nodebug cFlags",
+		nodebug.Output("obj/nodebug.o").Args["cFlags"], "-gsplit-dwarf")
+	android.AssertBoolEquals(t, "nodebug has dwp rule", false, nodebug.MaybeRule("dwp").Rule != nil)
+
+	// Only the C sources of partial are compiled without debug info, so they have no .dwo files.
+	partial := result.ModuleForTests("partial", "android_arm64_armv8-a")
+	android.AssertStringDoesNotContain(t, "partial nodebug.c cFlags",
+		partial.Output("obj/nodebug.o").Args["cFlags"], "-gsplit-dwarf")
+	android.AssertPathsRelativeToTopEquals(t, "partial dwp inputs", []string{
+		"out/soong/.intermediates/partial/android_arm64_armv8-a/obj/partial.dwo",
+	}, partial.Rule("dwp").Inputs)
+}
+
+func TestDebugInfoEnabled(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		flags    string
+		expected bool
+	}{
+		{flags: "${config.CommonGlobalCflags} -O2", expected: true},
+		{flags: "${config.CommonGlobalCflags} -g0", expected: false},
+		{flags: "-g0 -g", expected: true},
+		{flags: "-g0 -gdwarf-5", expected: true},
+		{flags: "-gline-tables-only -g0", expected: false},
+		{flags: "-g0 -gsplit-dwarf", expected: false},
+	}
+	for _, tc := range testCases {
+		android.AssertBoolEquals(t, tc.flags, tc.expected, debugInfoEnabled(tc.flags))
+	}
+}
@@ -45,10 +45,10 @@ var (
 		blueprint.RuleParams{
 			Depfile:     "${out}.d",
 			Deps:        blueprint.DepsGCC,
-			Command:     "$relPwd ${config.CcWrapper}$ccCmd -c $cFlags -MD -MF ${out}.d -o $out $in",
+			Command:     "$relPwd ${config.CcWrapper}${ccWrapperFlags}$ccCmd -c $cFlags -MD -MF ${out}.d -o $out $in",
 			CommandDeps: []string{"$ccCmd"},
 		},
-		"ccCmd", "cFlags")
+		"ccCmd", "cFlags", "ccWrapperFlags")
 
 	// Rule to invoke gcc with given command and flags, but no dependencies.
 	ccNoDeps = pctx.AndroidStaticRule("ccNoDeps",
@@ -96,6 +96,25 @@ var (
 			Platform:        map[string]string{remoteexec.PoolKey: "${config.RECXXLinksPool}"},
 		}, []string{"ldCmd", "ldFlags"}, []string{"implicitInputs", "inCommaList", "implicitOutputs"})
 
+	// Rules for .o files to combine to other .o files when there are too many to be passed on the
+	// command line, using a .rsp file to list them.  The .rsp file is passed to the linker with
+	// $objListFlag, as not all linkers read it with the same syntax.
+	partialLdRsp, partialLdRspRE = pctx.RemoteStaticRules("partialLdRsp",
+		blueprint.RuleParams{
+			Command:        "$reTemplate$ldCmd -fuse-ld=lld -nostdlib -no-pie -Wl,-r ${objListFlag} -o ${out} ${ldFlags}",
+			CommandDeps:    []string{"$ldCmd"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in_newline}",
+		}, &remoteexec.REParams{
+			Labels:          map[string]string{"type": "link", "tool": "clang"},
+			ExecStrategy:    "${config.RECXXLinksExecStrategy}",
+			Inputs:          []string{"$inCommaList", "${out}.rsp", "$implicitInputs"},
+			RSPFiles:        []string{"${out}.rsp"},
+			OutputFiles:     []string{"${out}", "$implicitOutputs"},
+			ToolchainInputs: []string{"$ldCmd"},
+			Platform:        map[string]string{remoteexec.PoolKey: "${config.RECXXLinksPool}"},
+		}, []string{"ldCmd", "ldFlags", "objListFlag"}, []string{"implicitInputs", "inCommaList", "implicitOutputs"})
+
 	// Rule to invoke `ar` with given cmd and flags, but no static library depenencies.
 	ar = pctx.AndroidStaticRule("ar",
 		blueprint.RuleParams{
@@ -202,6 +221,52 @@ var (
 		},
 		"clangBin", "format")
 
+	_ = pctx.HostBinToolVariable("checkMaxPageSizeCmd", "check_max_page_size")
+
+	// A rule for checking that the load segments of a shared library or binary are aligned to its
+	// max page size.
+	checkMaxPageSize = pctx.AndroidStaticRule("checkMaxPageSize",
+		blueprint.RuleParams{
+			Command: "rm -f $out && $checkMaxPageSizeCmd --readelf ${config.ClangBin}/llvm-readelf " +
+				"--max-page-size $maxPageSize $in && touch $out",
+			CommandDeps: []string{"$checkMaxPageSizeCmd"},
+		},
+		"maxPageSize")
+
+	_ = pctx.HostBinToolVariable("checkLlndkSymbolsCmd", "check_llndk_symbols")
+
+	// A rule for checking that the symbols listed in the llndk.symbol_file of a library are defined
+	// by the implementation library and declared by the headers exported to its LLNDK variant.
+	checkLlndkSymbols = pctx.AndroidStaticRule("checkLlndkSymbols",
+		blueprint.RuleParams{
+			Command: "rm -f $out && $checkLlndkSymbolsCmd --nm ${config.ClangBin}/llvm-nm " +
+				"--symbol-list $symbolList $includeDirs $in && touch $out",
+			CommandDeps: []string{"$checkLlndkSymbolsCmd"},
+		},
+		"symbolList", "includeDirs")
+
+	_ = pctx.HostBinToolVariable("symbolOrderingFromProfileCmd", "symbol_ordering_from_profile")
+
+	// A rule for deriving the order of the hot symbols of a module from its afdo or pgo profile,
+	// to pass to the linker as --symbol-ordering-file.
+	symbolOrderingFromProfile = pctx.AndroidStaticRule("symbolOrderingFromProfile",
+		blueprint.RuleParams{
+			Command: "$symbolOrderingFromProfileCmd --llvm-profdata ${config.ClangBin}/llvm-profdata " +
+				"$profileKind $in > $out",
+			CommandDeps: []string{"$symbolOrderingFromProfileCmd", "${config.ClangBin}/llvm-profdata"},
+		},
+		"profileKind")
+
+	// A rule for packaging the .dwo files of a binary built with split DWARF into a .dwp file.  The
+	// .dwo files of the sources without debug info are empty or missing, and are skipped, so that a
+	// binary without any debug info gets an empty .dwp file.
+	dwp = pctx.AndroidStaticRule("dwp",
+		blueprint.RuleParams{
+			Command: "rm -f $out && dwos=$$(for f in $in; do if [ -s $$f ]; then echo $$f; fi; done) && " +
+				"if [ -n \"$$dwos\" ]; then ${config.ClangBin}/llvm-dwp -o $out $$dwos; else touch $out; fi",
+			CommandDeps: []string{"${config.ClangBin}/llvm-dwp"},
+		})
+
 	// Rules for invoking clang-tidy (a clang-based linter).
 	clangTidy, clangTidyRE = pctx.RemoteStaticRules("clangTidy",
 		blueprint.RuleParams{
@@ -227,6 +292,18 @@ var (
 			Platform: map[string]string{remoteexec.PoolKey: "${config.REClangTidyPool}"},
 		}, []string{"cFlags", "ccCmd", "clangCmd", "tidyCmd", "tidyFlags", "tidyVars"}, []string{})
 
+	// Rule for filtering the findings in the .tidy files of a module against a baseline of known
+	// findings, failing if the findings of checks that are errors are not in the baseline.
+	_ = pctx.HostBinToolVariable("tidyBaselineCmd", "tidy_baseline")
+
+	tidyBaselineFilter = pctx.AndroidStaticRule("tidyBaselineFilter",
+		blueprint.RuleParams{
+			Command: "${tidyBaselineCmd} filter --baseline $baseline --checks-as-errors '$checksAsErrors' " +
+				"$updateFlags -o $out $in",
+			CommandDeps: []string{"${tidyBaselineCmd}"},
+		},
+		"baseline", "checksAsErrors", "updateFlags")
+
 	_ = pctx.SourcePathVariable("yasmCmd", "prebuilts/misc/${config.HostPrebuiltTag}/yasm/yasm")
 
 	// Rule for invoking yasm to compile .asm assembly files.
@@ -381,9 +458,20 @@ type builderFlags struct {
 	gcovCoverage  bool
 	sAbiDump      bool
 	emitXrefs     bool
+	splitDwarf    bool // True if the debug info of C and C++ sources is split into .dwo files.
+
+	tidyBaseline       android.OptionalPath // The baseline of known clang-tidy findings, if any
+	tidyChecksAsErrors string               // Checks whose findings are errors unless in tidyBaseline
 
 	assemblerWithCpp bool // True if .s files should be processed with the c preprocessor.
 
+	// True if the module is built with CFI.  Assembly sources and cfiExcludeSrcs are then compiled
+	// with the local flags that do not contain the CFI flags.
+	cfi               bool
+	cfiExcludeSrcs    android.Paths
+	noCfiLocalCFlags  string
+	noCfiLocalAsFlags string
+
 	systemIncludeFlags string
 
 	proto            android.ProtoFlags
@@ -408,33 +496,39 @@ type StripFlags struct {
 
 // Objects is a collection of file paths corresponding to outputs for C++ related build statements.
 type Objects struct {
-	objFiles      android.Paths
-	tidyFiles     android.Paths
-	tidyDepFiles  android.Paths // link dependent .tidy files
-	coverageFiles android.Paths
-	sAbiDumpFiles android.Paths
-	kytheFiles    android.Paths
+	objFiles            android.Paths
+	tidyFiles           android.Paths
+	tidyDepFiles        android.Paths // link dependent .tidy files
+	tidyBaselineUpdates android.Paths // updated clang-tidy baselines, with TIDY_BASELINE_UPDATE=true
+	coverageFiles       android.Paths
+	sAbiDumpFiles       android.Paths
+	kytheFiles          android.Paths
+	dwoFiles            android.Paths // split DWARF .dwo files, with split DWARF enabled
 }
 
 func (a Objects) Copy() Objects {
 	return Objects{
-		objFiles:      append(android.Paths{}, a.objFiles...),
-		tidyFiles:     append(android.Paths{}, a.tidyFiles...),
-		tidyDepFiles:  append(android.Paths{}, a.tidyDepFiles...),
-		coverageFiles: append(android.Paths{}, a.coverageFiles...),
-		sAbiDumpFiles: append(android.Paths{}, a.sAbiDumpFiles...),
-		kytheFiles:    append(android.Paths{}, a.kytheFiles...),
+		objFiles:            append(android.Paths{}, a.objFiles...),
+		tidyFiles:           append(android.Paths{}, a.tidyFiles...),
+		tidyDepFiles:        append(android.Paths{}, a.tidyDepFiles...),
+		tidyBaselineUpdates: append(android.Paths{}, a.tidyBaselineUpdates...),
+		coverageFiles:       append(android.Paths{}, a.coverageFiles...),
+		sAbiDumpFiles:       append(android.Paths{}, a.sAbiDumpFiles...),
+		kytheFiles:          append(android.Paths{}, a.kytheFiles...),
+		dwoFiles:            append(android.Paths{}, a.dwoFiles...),
 	}
 }
 
 func (a Objects) Append(b Objects) Objects {
 	return Objects{
-		objFiles:      append(a.objFiles, b.objFiles...),
-		tidyFiles:     append(a.tidyFiles, b.tidyFiles...),
-		tidyDepFiles:  append(a.tidyDepFiles, b.tidyDepFiles...),
-		coverageFiles: append(a.coverageFiles, b.coverageFiles...),
-		sAbiDumpFiles: append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
-		kytheFiles:    append(a.kytheFiles, b.kytheFiles...),
+		objFiles:            append(a.objFiles, b.objFiles...),
+		tidyFiles:           append(a.tidyFiles, b.tidyFiles...),
+		tidyDepFiles:        append(a.tidyDepFiles, b.tidyDepFiles...),
+		tidyBaselineUpdates: append(a.tidyBaselineUpdates, b.tidyBaselineUpdates...),
+		coverageFiles:       append(a.coverageFiles, b.coverageFiles...),
+		sAbiDumpFiles:       append(a.sAbiDumpFiles, b.sAbiDumpFiles...),
+		kytheFiles:          append(a.kytheFiles, b.kytheFiles...),
+		dwoFiles:            append(a.dwoFiles, b.dwoFiles...),
 	}
 }
 
@@ -468,6 +562,10 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 	if flags.emitXrefs {
 		kytheFiles = make(android.Paths, 0, len(srcFiles))
 	}
+	var dwoFiles android.Paths
+	if flags.splitDwarf {
+		dwoFiles = make(android.Paths, 0, len(srcFiles))
+	}
 
 	// Produce fully expanded flags for use by C tools, C compiles, C++ tools, C++ compiles, and asm compiles
 	// respectively.
@@ -479,14 +577,6 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		flags.localConlyFlags + " " +
 		flags.systemIncludeFlags
 
-	cflags := flags.globalCommonFlags + " " +
-		flags.globalCFlags + " " +
-		flags.globalConlyFlags + " " +
-		flags.localCommonFlags + " " +
-		flags.localCFlags + " " +
-		flags.localConlyFlags + " " +
-		flags.systemIncludeFlags
-
 	toolingCppflags := flags.globalCommonFlags + " " +
 		flags.globalToolingCFlags + " " +
 		flags.globalToolingCppFlags + " " +
@@ -495,42 +585,72 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		flags.localToolingCppFlags + " " +
 		flags.systemIncludeFlags
 
-	cppflags := flags.globalCommonFlags + " " +
-		flags.globalCFlags + " " +
-		flags.globalCppFlags + " " +
-		flags.localCommonFlags + " " +
-		flags.localCFlags + " " +
-		flags.localCppFlags + " " +
-		flags.systemIncludeFlags
-
-	asflags := flags.globalCommonFlags + " " +
-		flags.globalAsFlags + " " +
-		flags.localCommonFlags + " " +
-		flags.localAsFlags + " " +
-		flags.systemIncludeFlags
+	// expandFlags returns the C, C++ and asm compile flags for the given local C and asm flags, which
+	// differ for the sources excluded from CFI.
+	expandFlags := func(localCFlags, localAsFlags string) (cflags, cppflags, asflags string) {
+		cflags = flags.globalCommonFlags + " " +
+			flags.globalCFlags + " " +
+			flags.globalConlyFlags + " " +
+			flags.localCommonFlags + " " +
+			localCFlags + " " +
+			flags.localConlyFlags + " " +
+			flags.systemIncludeFlags
+
+		cppflags = flags.globalCommonFlags + " " +
+			flags.globalCFlags + " " +
+			flags.globalCppFlags + " " +
+			flags.localCommonFlags + " " +
+			localCFlags + " " +
+			flags.localCppFlags + " " +
+			flags.systemIncludeFlags
+
+		asflags = flags.globalCommonFlags + " " +
+			flags.globalAsFlags + " " +
+			flags.localCommonFlags + " " +
+			localAsFlags + " " +
+			flags.systemIncludeFlags
+
+		cflags += " ${config.NoOverrideGlobalCflags}"
+		cppflags += " ${config.NoOverrideGlobalCflags}"
+		if flags.toolchain.Is64Bit() {
+			cflags += " ${config.NoOverride64GlobalCflags}"
+			cppflags += " ${config.NoOverride64GlobalCflags}"
+		}
+		if android.IsThirdPartyPath(android.PathForModuleSrc(ctx).String()) {
+			cflags += " ${config.NoOverrideExternalGlobalCflags}"
+			cppflags += " ${config.NoOverrideExternalGlobalCflags}"
+		}
+		return cflags, cppflags, asflags
+	}
+	cflags, cppflags, asflags := expandFlags(flags.localCFlags, flags.localAsFlags)
+
+	// Assembly sources and the sources listed in cfi_exclude_srcs are compiled without the CFI
+	// flags in a CFI-enabled module, while the rest of the module and the link keep CFI.
+	var noCfiCflags, noCfiCppflags, noCfiAsflags string
+	cfiExcludeSrcsMap := make(map[string]bool)
+	if flags.cfi {
+		noCfiCflags, noCfiCppflags, noCfiAsflags = expandFlags(flags.noCfiLocalCFlags, flags.noCfiLocalAsFlags)
+		for _, path := range flags.cfiExcludeSrcs {
+			cfiExcludeSrcsMap[path.String()] = true
+		}
+	}
 
 	var sAbiDumpFiles android.Paths
 	if flags.sAbiDump {
 		sAbiDumpFiles = make(android.Paths, 0, len(srcFiles))
 	}
 
-	cflags += " ${config.NoOverrideGlobalCflags}"
 	toolingCflags += " ${config.NoOverrideGlobalCflags}"
-	cppflags += " ${config.NoOverrideGlobalCflags}"
 	toolingCppflags += " ${config.NoOverrideGlobalCflags}"
 
 	if flags.toolchain.Is64Bit() {
-		cflags += " ${config.NoOverride64GlobalCflags}"
 		toolingCflags += " ${config.NoOverride64GlobalCflags}"
-		cppflags += " ${config.NoOverride64GlobalCflags}"
 		toolingCppflags += " ${config.NoOverride64GlobalCflags}"
 	}
 
 	modulePath := android.PathForModuleSrc(ctx).String()
 	if android.IsThirdPartyPath(modulePath) {
-		cflags += " ${config.NoOverrideExternalGlobalCflags}"
 		toolingCflags += " ${config.NoOverrideExternalGlobalCflags}"
-		cppflags += " ${config.NoOverrideExternalGlobalCflags}"
 		toolingCppflags += " ${config.NoOverrideExternalGlobalCflags}"
 	}
 
@@ -593,6 +713,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		dump := flags.sAbiDump
 		rule := cc
 		emitXref := flags.emitXrefs
+		splitDwarf := flags.splitDwarf
 
 		switch srcFile.Ext() {
 		case ".s":
@@ -603,17 +724,27 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 		case ".S":
 			ccCmd = "clang"
 			moduleFlags = asflags
+			if flags.cfi {
+				moduleFlags = noCfiAsflags
+			}
 			tidy = false
 			coverage = false
 			dump = false
 			emitXref = false
+			splitDwarf = false
 		case ".c":
 			ccCmd = "clang"
 			moduleFlags = cflags
+			if cfiExcludeSrcsMap[srcFile.String()] {
+				moduleFlags = noCfiCflags
+			}
 			moduleToolingFlags = toolingCflags
 		case ".cpp", ".cc", ".cxx", ".mm":
 			ccCmd = "clang++"
 			moduleFlags = cppflags
+			if cfiExcludeSrcsMap[srcFile.String()] {
+				moduleFlags = noCfiCppflags
+			}
 			moduleToolingFlags = toolingCppflags
 		case ".h", ".hpp":
 			ctx.PropertyErrorf("srcs", "Header file %s is not supported, instead use export_include_dirs or local_include_dirs.", srcFile)
@@ -635,6 +766,27 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			coverageFiles = append(coverageFiles, gcnoFile)
 		}
 
+		args := map[string]string{
+			"cFlags": shareFlags("cFlags", moduleFlags),
+			"ccCmd":  ccCmd, // short and not shared
+		}
+		if splitDwarf && !debugInfoEnabled(moduleFlags) {
+			// The sources compiled without debug info have nothing to split.
+			splitDwarf = false
+		}
+		if splitDwarf {
+			// clang writes the debug info to a .dwo file next to the object.
+			dwoFile := android.ObjPathWithExt(ctx, subdir, srcFile, "dwo")
+			implicitOutputs = append(implicitOutputs, dwoFile)
+			dwoFiles = append(dwoFiles, dwoFile)
+			args["cFlags"] += " -gsplit-dwarf"
+			if ctx.Config().UseRBE() {
+				// The .dwo file is not named on the command line, so tell rewrapper that it is an
+				// output of the remote compile.
+				args["ccWrapperFlags"] = "--output_files=" + dwoFile.String() + " "
+			}
+		}
+
 		ctx.Build(pctx, android.BuildParams{
 			Rule:            rule,
 			Description:     ccDesc + " " + srcFile.Rel(),
@@ -643,10 +795,7 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 			Input:           srcFile,
 			Implicits:       cFlagsDeps,
 			OrderOnly:       pathDeps,
-			Args: map[string]string{
-				"cFlags": shareFlags("cFlags", moduleFlags),
-				"ccCmd":  ccCmd, // short and not shared
-			},
+			Args:            args,
 		})
 
 		// Register post-process build statements (such as for tidy or kythe).
@@ -729,18 +878,70 @@ func transformSourceToObj(ctx ModuleContext, subdir string, srcFiles, noTidySrcs
 
 	}
 
+	var tidyBaselineUpdates android.Paths
+	if flags.tidyBaseline.Valid() && len(tidyFiles) > 0 {
+		// Replace the .tidy files with the result of filtering their findings against the
+		// baseline, so that the module only depends on the findings being in the baseline.
+		filteredTidyFile := android.PathForModuleOut(ctx, subdir, "tidy_baseline.tidy")
+		args := map[string]string{
+			"baseline":       flags.tidyBaseline.String(),
+			"checksAsErrors": flags.tidyChecksAsErrors,
+		}
+		var implicitOutputs android.WritablePaths
+		if ctx.Config().IsEnvTrue("TIDY_BASELINE_UPDATE") {
+			update := android.PathForModuleOut(ctx, subdir, "tidy_baseline.update")
+			args["updateFlags"] = "--update " + update.String()
+			implicitOutputs = append(implicitOutputs, update)
+			tidyBaselineUpdates = append(tidyBaselineUpdates, update)
+		}
+		ctx.Build(pctx, android.BuildParams{
+			Rule:            tidyBaselineFilter,
+			Description:     "clang-tidy baseline " + flags.tidyBaseline.Path().Rel(),
+			Output:          filteredTidyFile,
+			ImplicitOutputs: implicitOutputs,
+			Inputs:          tidyFiles,
+			Implicit:        flags.tidyBaseline.Path(),
+			Args:            args,
+		})
+		tidyFiles = android.Paths{filteredTidyFile}
+	}
+
 	var tidyDepFiles android.Paths
 	if flags.needTidyFiles {
 		tidyDepFiles = tidyFiles
 	}
 	return Objects{
-		objFiles:      objFiles,
-		tidyFiles:     tidyFiles,
-		tidyDepFiles:  tidyDepFiles,
-		coverageFiles: coverageFiles,
-		sAbiDumpFiles: sAbiDumpFiles,
-		kytheFiles:    kytheFiles,
+		objFiles:            objFiles,
+		tidyFiles:           tidyFiles,
+		tidyDepFiles:        tidyDepFiles,
+		tidyBaselineUpdates: tidyBaselineUpdates,
+		coverageFiles:       coverageFiles,
+		sAbiDumpFiles:       sAbiDumpFiles,
+		kytheFiles:          kytheFiles,
+		dwoFiles:            dwoFiles,
+	}
+}
+
+// Lists of input files that are longer than this are passed to tools in a .rsp file instead of
+// on the command line, so that the command line stays below the limit of all hosts.
+const maxInputListLength = 64 * 1024
+
+// needsRspFile returns true if the list of inputs is too long to be passed on the command line.
+func needsRspFile(inputs android.Paths) bool {
+	length := 0
+	for _, input := range inputs {
+		length += len(input.String()) + 1
 	}
+	return length > maxInputListLength
+}
+
+// objListFlag returns the linker flag that reads the list of object files from rspFile.  The Darwin
+// linker reads a list of files with -filelist, the other linkers read a response file with @.
+func objListFlag(ctx android.ModuleContext, rspFile string) string {
+	if ctx.Darwin() {
+		return "-Wl,-filelist," + rspFile
+	}
+	return "@" + rspFile
 }
 
 // Generate a rule for compiling multiple .o files to a static library (.a)
@@ -769,18 +970,28 @@ func transformObjToStaticLib(ctx android.ModuleContext,
 		})
 
 	} else {
+		arLibs := strings.Join(wholeStaticLibs.Strings(), " ")
+		implicits := deps
+		if needsRspFile(wholeStaticLibs) {
+			// The .rsp file of the rule lists the objects, so the static libraries are listed in
+			// another one.  llvm-ar reads response files with @ on all hosts.
+			libsRspFile := outputFile.InSameDir(ctx, outputFile.Base()+".libs.rsp")
+			android.WriteFileRule(ctx, libsRspFile, strings.Join(wholeStaticLibs.Strings(), "\n"))
+			arLibs = "@" + libsRspFile.String()
+			implicits = append(android.Paths{libsRspFile}, deps...)
+		}
 		ctx.Build(pctx, android.BuildParams{
 			Rule:        arWithLibs,
 			Description: "static link " + outputFile.Base(),
 			Output:      outputFile,
 			Inputs:      append(objFiles, wholeStaticLibs...),
-			Implicits:   deps,
+			Implicits:   implicits,
 			Args: map[string]string{
 				"arCmd":      arCmd,
 				"arObjFlags": "crsPD" + arFlags,
 				"arObjs":     strings.Join(objFiles.Strings(), " "),
 				"arLibFlags": "cqsL" + arFlags,
-				"arLibs":     strings.Join(wholeStaticLibs.Strings(), " "),
+				"arLibs":     arLibs,
 			},
 		})
 	}
@@ -964,19 +1175,74 @@ func TransformSharedObjectToToc(ctx android.ModuleContext, inputFile android.Pat
 	})
 }
 
+// Generate a rule for deriving the order of the hot symbols of a module from its profile, where
+// profileKind is the profile kind flag of llvm-profdata, either "--sample" or "--instr".
+func transformProfileToSymbolOrderingFile(ctx android.ModuleContext, profile android.Path,
+	profileKind string, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        symbolOrderingFromProfile,
+		Description: "generate symbol ordering " + outputFile.Base(),
+		Output:      outputFile,
+		Input:       profile,
+		Args: map[string]string{
+			"profileKind": profileKind,
+		},
+	})
+}
+
+// Generate a rule for checking that the load segments of a shared library or binary are aligned
+// to maxPageSize.  The output is a timestamp meant to be used as a validation of the file.
+func transformBinaryToMaxPageSizeCheck(ctx android.ModuleContext, inputFile android.Path,
+	maxPageSize string, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkMaxPageSize,
+		Description: "check max page size " + inputFile.Base(),
+		Output:      outputFile,
+		Input:       inputFile,
+		Args: map[string]string{
+			"maxPageSize": maxPageSize,
+		},
+	})
+}
+
+// Generate a rule for checking that the symbols in symbolList are defined by the shared library
+// inputFile, and declared by the headers in includeDirs.  The headers are the globbed contents of
+// includeDirs.  The output is a timestamp meant to be used as a validation of the file.
+func transformSharedLibraryToLlndkSymbolsCheck(ctx android.ModuleContext, inputFile, symbolList android.Path,
+	includeDirs []string, headers android.Paths, outputFile android.WritablePath) {
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        checkLlndkSymbols,
+		Description: "check llndk symbols " + inputFile.Base(),
+		Output:      outputFile,
+		Input:       inputFile,
+		Implicits:   append(android.Paths{symbolList}, headers...),
+		Args: map[string]string{
+			"symbolList":  symbolList.String(),
+			"includeDirs": android.JoinWithPrefix(includeDirs, "--include-dir "),
+		},
+	})
+}
+
 // Generate a rule for compiling multiple .o files to a .o using ld partial linking
 func transformObjsToObj(ctx android.ModuleContext, objFiles android.Paths,
 	flags builderFlags, outputFile android.WritablePath, deps android.Paths) {
 
 	ldCmd := "${config.ClangBin}/clang++"
 
-	rule := partialLd
+	rule, ruleRE := partialLd, partialLdRE
 	args := map[string]string{
 		"ldCmd":   ldCmd,
 		"ldFlags": flags.globalLdFlags + " " + flags.localLdFlags,
 	}
+	if needsRspFile(objFiles) {
+		rule, ruleRE = partialLdRsp, partialLdRspRE
+		args["objListFlag"] = objListFlag(ctx, outputFile.String()+".rsp")
+	}
 	if ctx.Config().UseRBE() && ctx.Config().IsEnvTrue("RBE_CXX_LINKS") {
-		rule = partialLdRE
+		rule = ruleRE
 		args["inCommaList"] = strings.Join(objFiles.Strings(), ",")
 		args["implicitInputs"] = strings.Join(deps.Strings(), ",")
 	}
@@ -1008,6 +1274,33 @@ func transformBinaryPrefixSymbols(ctx android.ModuleContext, prefix string, inpu
 	})
 }
 
+// debugInfoEnabled returns true if the compiler flags generate debug info, i.e. if the last of
+// the flags that select the level of debug info is not -g0.  The global cflags enable debug info.
+func debugInfoEnabled(flags string) bool {
+	enabled := true
+	for _, flag := range strings.Fields(flags) {
+		switch {
+		case flag == "-g0":
+			enabled = false
+		case flag == "-g", flag == "-g1", flag == "-g2", flag == "-g3", flag == "-ggdb",
+			flag == "-gline-tables-only", flag == "-gline-directives-only",
+			strings.HasPrefix(flag, "-gdwarf"):
+			enabled = true
+		}
+	}
+	return enabled
+}
+
+// Registers a build statement to package the split DWARF .dwo files of a binary into a .dwp file.
+func transformDwoToDwp(ctx android.ModuleContext, dwoFiles android.Paths, outputFile android.WritablePath) {
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        dwp,
+		Description: "dwp " + outputFile.Base(),
+		Output:      outputFile,
+		Inputs:      dwoFiles,
+	})
+}
+
 // Registers a build statement to invoke `strip` (to discard symbols and data from object files).
 func transformStrip(ctx android.ModuleContext, inputFile android.Path,
 	outputFile android.WritablePath, flags StripFlags) {
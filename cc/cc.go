@@ -167,8 +167,8 @@ type PathDeps struct {
 	LdFlags                    []string
 	IncludeDirs                android.Paths
 	SystemIncludeDirs          android.Paths
-	ReexportedDirs             android.Paths
-	ReexportedSystemDirs       android.Paths
+	ReexportedDirs             *android.DepSet
+	ReexportedSystemDirs       *android.DepSet
 	ReexportedFlags            []string
 	ReexportedGeneratedHeaders android.Paths
 	ReexportedDeps             android.Paths
@@ -224,6 +224,12 @@ type Flags struct {
 	GcovCoverage  bool // True if coverage files should be generated.
 	SAbiDump      bool // True if header abi dumps should be generated.
 	EmitXrefs     bool // If true, generate Ninja rules to generate emitXrefs input files for Kythe
+	SplitDwarf    bool // True if the debug info of C and C++ sources is split into .dwo files.
+
+	TidyBaseline android.OptionalPath // The baseline of known clang-tidy findings, if any.
+	// The checks that are treated as errors when filtering the findings of clang-tidy against
+	// TidyBaseline.
+	TidyChecksAsErrors string
 
 	// The instruction set required for clang ("arm" or "thumb").
 	RequiredInstructionSet string
@@ -236,6 +242,12 @@ type Flags struct {
 	// True if .s files should be processed with the c preprocessor.
 	AssemblerWithCpp bool
 
+	// The flags added to the local flags to enable CFI, which are left out when compiling assembly
+	// sources and cfiExcludeSrcs.
+	cfiCFlags      []string
+	cfiAsFlags     []string
+	cfiExcludeSrcs android.Paths
+
 	proto            android.ProtoFlags
 	protoC           bool // Whether to use C instead of C++
 	protoOptionsFile bool // Whether to look for a .options file next to the .proto
@@ -284,6 +296,9 @@ type BaseProperties struct {
 	PreventInstall            bool     `blueprint:"mutated"`
 	ApexesProvidingSharedLibs []string `blueprint:"mutated"`
 
+	// The name of the experiment that this variant is built for, or "" for the normal variant.
+	Experiment string `blueprint:"mutated"`
+
 	// Set by DepsMutator.
 	AndroidMkSystemSharedLibs []string `blueprint:"mutated"`
 
@@ -750,21 +765,22 @@ type installDependencyTag struct {
 }
 
 var (
-	genSourceDepTag       = dependencyTag{name: "gen source"}
-	genHeaderDepTag       = dependencyTag{name: "gen header"}
-	genHeaderExportDepTag = dependencyTag{name: "gen header export"}
-	objDepTag             = dependencyTag{name: "obj"}
-	dynamicLinkerDepTag   = installDependencyTag{name: "dynamic linker"}
-	reuseObjTag           = dependencyTag{name: "reuse objects"}
-	staticVariantTag      = dependencyTag{name: "static variant"}
-	vndkExtDepTag         = dependencyTag{name: "vndk extends"}
-	dataLibDepTag         = dependencyTag{name: "data lib"}
-	dataBinDepTag         = dependencyTag{name: "data bin"}
-	runtimeDepTag         = installDependencyTag{name: "runtime lib"}
-	testPerSrcDepTag      = dependencyTag{name: "test_per_src"}
-	stubImplDepTag        = dependencyTag{name: "stub_impl"}
-	JniFuzzLibTag         = dependencyTag{name: "jni_fuzz_lib_tag"}
-	FdoProfileTag         = dependencyTag{name: "fdo_profile"}
+	genSourceDepTag        = dependencyTag{name: "gen source"}
+	genHeaderDepTag        = dependencyTag{name: "gen header"}
+	genHeaderExportDepTag  = dependencyTag{name: "gen header export"}
+	objDepTag              = dependencyTag{name: "obj"}
+	dynamicLinkerDepTag    = installDependencyTag{name: "dynamic linker"}
+	reuseObjTag            = dependencyTag{name: "reuse objects"}
+	staticVariantTag       = dependencyTag{name: "static variant"}
+	vndkExtDepTag          = dependencyTag{name: "vndk extends"}
+	dataLibDepTag          = dependencyTag{name: "data lib"}
+	dataBinDepTag          = dependencyTag{name: "data bin"}
+	runtimeDepTag          = installDependencyTag{name: "runtime lib"}
+	testPerSrcDepTag       = dependencyTag{name: "test_per_src"}
+	testPerSrcCommonDepTag = dependencyTag{name: "test_per_src common"}
+	stubImplDepTag         = dependencyTag{name: "stub_impl"}
+	JniFuzzLibTag          = dependencyTag{name: "jni_fuzz_lib_tag"}
+	FdoProfileTag          = dependencyTag{name: "fdo_profile"}
 )
 
 func IsSharedDepTag(depTag blueprint.DependencyTag) bool {
@@ -880,6 +896,11 @@ type Module struct {
 	// Tidy .tidy file output paths for this compilation module
 	tidyFiles android.Paths
 
+	// Updated clang-tidy baselines for this compilation module, with TIDY_BASELINE_UPDATE=true, and
+	// the baseline they update.
+	tidyBaselineUpdates android.Paths
+	tidyBaseline        android.OptionalPath
+
 	// For apex variants, this is set as apex.min_sdk_version
 	apexSdkVersion android.ApiLevel
 
@@ -956,6 +977,22 @@ func (c *Module) HiddenFromMake() bool {
 	return c.Properties.HideFromMake
 }
 
+func (c *Module) ExperimentSupported() bool {
+	return c.compiler != nil && !c.IsPrebuilt()
+}
+
+func (c *Module) SetExperiment(name string) {
+	c.Properties.Experiment = name
+	c.Properties.PreventInstall = true
+	c.Properties.HideFromMake = true
+}
+
+func (c *Module) Experiment() string {
+	return c.Properties.Experiment
+}
+
+var _ android.ExperimentModule = (*Module)(nil)
+
 func (c *Module) RequiredModuleNames() []string {
 	required := android.CopyOf(c.ModuleBase.RequiredModuleNames())
 	if c.ImageVariation().Variation == android.CoreVariation {
@@ -1350,6 +1387,13 @@ func (c *Module) isPgoCompile() bool {
 	return false
 }
 
+func (c *Module) generatedHeadersStrict() bool {
+	if compiler, ok := c.compiler.(interface{ generatedHeadersStrict() bool }); ok {
+		return compiler.generatedHeadersStrict()
+	}
+	return false
+}
+
 func (c *Module) isCfi() bool {
 	if sanitize := c.sanitize; sanitize != nil {
 		return Bool(sanitize.Properties.Sanitize.Cfi)
@@ -2041,6 +2085,10 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	for _, feature := range c.features {
 		flags = feature.flags(ctx, flags)
 	}
+	if c.Properties.Experiment != "" {
+		flags.Local.CFlags = append(flags.Local.CFlags,
+			ctx.Config().Experiment(c.Properties.Experiment).Cflags...)
+	}
 	if ctx.Failed() {
 		return
 	}
@@ -2077,6 +2125,8 @@ func (c *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 		c.kytheFiles = objs.kytheFiles
 		c.objFiles = objs.objFiles
 		c.tidyFiles = objs.tidyFiles
+		c.tidyBaselineUpdates = objs.tidyBaselineUpdates
+		c.tidyBaseline = flags.TidyBaseline
 	}
 
 	if c.linker != nil {
@@ -2318,6 +2368,23 @@ func AddSharedLibDependenciesWithVersions(ctx android.BottomUpMutatorContext, mo
 	}
 }
 
+// lateSharedLibAvailable returns false and reports an error if a late shared library, i.e. one of
+// the system_shared_libs, of a vendor or product variant exists but is not available to the image.
+// Without the check the misconfiguration only shows up as a missing variant.
+func (c *Module) lateSharedLibAvailable(ctx android.BottomUpMutatorContext, variations []blueprint.Variation, lib string) bool {
+	if !c.UseVndk() || !ctx.OtherModuleExists(lib) || ctx.OtherModuleDependencyVariantExists(variations, lib) {
+		return true
+	}
+	image := "vendor"
+	if c.InProduct() {
+		image = "product"
+	}
+	ctx.PropertyErrorf("system_shared_libs", "%q is not available to the %s image, only LLNDK, VNDK "+
+		"and %s_available libraries can be used; set target.%s.system_shared_libs to replace it",
+		lib, image, image, image)
+	return false
+}
+
 func GetApiImports(c LinkableInterface, actx android.BottomUpMutatorContext) multitree.ApiImportInfo {
 	apiImportInfo := multitree.ApiImportInfo{}
 
@@ -2633,6 +2700,9 @@ func (c *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 		variations := []blueprint.Variation{
 			{Mutator: "link", Variation: "shared"},
 		}
+		if !c.lateSharedLibAvailable(actx, variations, lib) {
+			continue
+		}
 		AddSharedLibDependenciesWithVersions(ctx, c, variations, depTag, lib, "", false)
 	}
 
@@ -2937,14 +3007,24 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	var directStaticDeps []StaticLibraryInfo
 	var directSharedDeps []SharedLibraryInfo
 
+	// The include directories reexported from the dependencies, in the order they are visited.
+	var reexportedDirs, reexportedSystemDirs []*android.DepSet
+
 	reexportExporter := func(exporter FlagExporterInfo) {
-		depPaths.ReexportedDirs = append(depPaths.ReexportedDirs, exporter.IncludeDirs...)
-		depPaths.ReexportedSystemDirs = append(depPaths.ReexportedSystemDirs, exporter.SystemIncludeDirs...)
+		if exporter.IncludeDirs != nil {
+			reexportedDirs = append(reexportedDirs, exporter.IncludeDirs)
+		}
+		if exporter.SystemIncludeDirs != nil {
+			reexportedSystemDirs = append(reexportedSystemDirs, exporter.SystemIncludeDirs)
+		}
 		depPaths.ReexportedFlags = append(depPaths.ReexportedFlags, exporter.Flags...)
 		depPaths.ReexportedDeps = append(depPaths.ReexportedDeps, exporter.Deps...)
 		depPaths.ReexportedGeneratedHeaders = append(depPaths.ReexportedGeneratedHeaders, exporter.GeneratedHeaders...)
 	}
 
+	// The shim directories of the generated_headers when generated_headers_strict is set.
+	var generatedHeadersShim *generatedHeadersShim
+
 	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
 	c.apexSdkVersion = findApexSdkVersion(ctx, apexInfo)
 
@@ -3005,6 +3085,10 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			return
 		}
 
+		if depTag == android.ExperimentBaselineTag {
+			return
+		}
+
 		ccDep, ok := dep.(LinkableInterface)
 		if !ok {
 
@@ -3021,15 +3105,26 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				fallthrough
 			case genHeaderDepTag, genHeaderExportDepTag:
 				if genRule, ok := dep.(genrule.SourceFileGenerator); ok {
-					depPaths.GeneratedDeps = append(depPaths.GeneratedDeps,
-						genRule.GeneratedDeps()...)
 					dirs := genRule.GeneratedHeaderDirs()
+					headers := genRule.GeneratedSourceFiles()
+					genDeps := genRule.GeneratedDeps()
+					if c.generatedHeadersStrict() {
+						if generatedHeadersShim == nil {
+							generatedHeadersShim = newGeneratedHeadersShim(ctx)
+						}
+						var dir android.Path
+						headers, dir = generatedHeadersShim.add(ctx, depName, genRule,
+							depTag == genHeaderExportDepTag)
+						dirs = android.Paths{dir}
+						genDeps = append(android.Paths{}, headers...)
+					}
+					depPaths.GeneratedDeps = append(depPaths.GeneratedDeps, genDeps...)
 					depPaths.IncludeDirs = append(depPaths.IncludeDirs, dirs...)
 					if depTag == genHeaderExportDepTag {
-						depPaths.ReexportedDirs = append(depPaths.ReexportedDirs, dirs...)
+						reexportedDirs = append(reexportedDirs, android.NewDepSet(android.PREORDER, dirs, nil))
 						depPaths.ReexportedGeneratedHeaders = append(depPaths.ReexportedGeneratedHeaders,
-							genRule.GeneratedSourceFiles()...)
-						depPaths.ReexportedDeps = append(depPaths.ReexportedDeps, genRule.GeneratedDeps()...)
+							headers...)
+						depPaths.ReexportedDeps = append(depPaths.ReexportedDeps, genDeps...)
 						// Add these re-exported flags to help header-abi-dumper to infer the abi exported by a library.
 						c.sabi.Properties.ReexportedIncludes = append(c.sabi.Properties.ReexportedIncludes, dirs.Strings()...)
 
@@ -3074,6 +3169,15 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			return
 		}
 
+		if depTag == testPerSrcCommonDepTag {
+			// Link the static library compiled from the sources shared by the test_per_src_srcs
+			// tests, see testPerSrcWithCommonSrcs.
+			if commonFile := ccDep.OutputFile(); commonFile.Valid() {
+				depPaths.WholeStaticLibs = append(depPaths.WholeStaticLibs, commonFile.Path())
+			}
+			return
+		}
+
 		linkFile := ccDep.OutputFile()
 
 		if libDepTag, ok := depTag.(libraryDependencyTag); ok {
@@ -3240,8 +3344,8 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				*depPtr = append(*depPtr, dep.Path())
 			}
 
-			depPaths.IncludeDirs = append(depPaths.IncludeDirs, depExporterInfo.IncludeDirs...)
-			depPaths.SystemIncludeDirs = append(depPaths.SystemIncludeDirs, depExporterInfo.SystemIncludeDirs...)
+			depPaths.IncludeDirs = append(depPaths.IncludeDirs, depExporterInfo.IncludeDirs.ToList()...)
+			depPaths.SystemIncludeDirs = append(depPaths.SystemIncludeDirs, depExporterInfo.SystemIncludeDirs.ToList()...)
 			depPaths.GeneratedDeps = append(depPaths.GeneratedDeps, depExporterInfo.Deps...)
 			depPaths.Flags = append(depPaths.Flags, depExporterInfo.Flags...)
 
@@ -3253,7 +3357,7 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				// -isystem headers are not included since for bionic libraries, abi-filtering is taken care of by version
 				// scripts.
 				c.sabi.Properties.ReexportedIncludes = append(
-					c.sabi.Properties.ReexportedIncludes, depExporterInfo.IncludeDirs.Strings()...)
+					c.sabi.Properties.ReexportedIncludes, depExporterInfo.IncludeDirs.ToList().Strings()...)
 			}
 
 			makeLibName := MakeLibName(ctx, c, ccDep, depName) + libDepTag.makeSuffix
@@ -3322,8 +3426,8 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	depPaths.IncludeDirs = android.FirstUniquePaths(depPaths.IncludeDirs)
 	depPaths.SystemIncludeDirs = android.FirstUniquePaths(depPaths.SystemIncludeDirs)
 	depPaths.GeneratedDeps = android.FirstUniquePaths(depPaths.GeneratedDeps)
-	depPaths.ReexportedDirs = android.FirstUniquePaths(depPaths.ReexportedDirs)
-	depPaths.ReexportedSystemDirs = android.FirstUniquePaths(depPaths.ReexportedSystemDirs)
+	depPaths.ReexportedDirs = android.NewDepSet(android.PREORDER, nil, reexportedDirs)
+	depPaths.ReexportedSystemDirs = android.NewDepSet(android.PREORDER, nil, reexportedSystemDirs)
 	depPaths.ReexportedFlags = android.FirstUniqueStrings(depPaths.ReexportedFlags)
 	depPaths.ReexportedDeps = android.FirstUniquePaths(depPaths.ReexportedDeps)
 	depPaths.ReexportedGeneratedHeaders = android.FirstUniquePaths(depPaths.ReexportedGeneratedHeaders)
@@ -3355,7 +3459,7 @@ func ShouldUseStubForApex(ctx android.ModuleContext, dep android.Module) bool {
 
 	useStubs := false
 
-	if lib := moduleLibraryInterface(dep); lib.buildStubs() && useVndk { // LLNDK
+	if lib := moduleLibraryInterface(dep); lib != nil && lib.buildStubs() && useVndk { // LLNDK
 		if !apexInfo.IsForPlatform() {
 			// For platform libraries, use current version of LLNDK
 			// If this is for use_vendor apex we will apply the same rules
@@ -3800,17 +3904,17 @@ func (c *Module) DepIsInSameApex(ctx android.BaseModuleContext, dep android.Modu
 	depTag := ctx.OtherModuleDependencyTag(dep)
 	libDepTag, isLibDepTag := depTag.(libraryDependencyTag)
 
-	if cc, ok := dep.(*Module); ok {
-		if cc.HasStubsVariants() {
-			if isLibDepTag && libDepTag.shared() {
-				// dynamic dep to a stubs lib crosses APEX boundary
-				return false
-			}
-			if IsRuntimeDepTag(depTag) {
-				// runtime dep to a stubs lib also crosses APEX boundary
-				return false
-			}
+	if linkable, ok := dep.(LinkableInterface); ok && linkable.HasStubsVariants() {
+		if isLibDepTag && libDepTag.shared() {
+			// dynamic dep to a stubs lib crosses APEX boundary
+			return false
+		}
+		if IsRuntimeDepTag(depTag) {
+			// runtime dep to a stubs lib also crosses APEX boundary
+			return false
 		}
+	}
+	if cc, ok := dep.(*Module); ok {
 		if cc.IsLlndk() {
 			return false
 		}
@@ -764,6 +764,72 @@ func TestDataLibs(t *testing.T) {
 	}
 }
 
+func TestTestPerSrcSrcs(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.MockFS{
+			"common.cpp":   nil,
+			"foo_test.cpp": nil,
+			"bar_test.cpp": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		cc_test {
+			name: "main_test",
+			srcs: ["common.cpp"],
+			test_per_src_srcs: ["foo_test.cpp", "bar_test.cpp"],
+			test_suites: ["general-tests"],
+			gtest: false,
+		}
+	`)
+
+	common := result.ModuleForTests("main_test", "android_arm64_armv8-a_test_per_src_common")
+	android.AssertPathsRelativeToTopEquals(t, "common compiled sources",
+		[]string{"common.cpp"}, android.Paths{common.Rule("cc").Input})
+	commonLib := common.Output("main_test_test_per_src_common.a")
+	android.AssertPathsRelativeToTopEquals(t, "common archived objects",
+		[]string{"out/soong/.intermediates/main_test/android_arm64_armv8-a_test_per_src_common/obj/common.o"},
+		commonLib.Inputs)
+	if common.MaybeRule("ld").Rule != nil {
+		t.Errorf("expected the common variation of main_test not to be linked")
+	}
+	android.AssertBoolEquals(t, "common hidden from make", true, common.Module().(*Module).Properties.HideFromMake)
+
+	for _, name := range []string{"foo_test", "bar_test"} {
+		test := result.ModuleForTests("main_test", "android_arm64_armv8-a_"+name)
+		android.AssertPathsRelativeToTopEquals(t, name+" compiled sources",
+			[]string{name + ".cpp"}, android.Paths{test.Rule("cc").Input})
+		if test.MaybeOutputWithBasename("common.o").Rule != nil {
+			t.Errorf("expected %s not to compile common.cpp again", name)
+		}
+
+		ld := test.Rule("ld")
+		android.AssertStringEquals(t, name+" binary", name, ld.Output.Base())
+		android.AssertStringDoesContain(t, name+" links the common library", ld.Args["libFlags"],
+			commonLib.Output.String())
+		android.AssertStringEquals(t, name+" test config binary", name,
+			test.Rule("autogenTestConfig").Args["outputFileName"])
+
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, test.Module())[0]
+		android.AssertStringEquals(t, name+" make suffix", "_"+name, entries.SubName)
+		android.AssertStringEquals(t, name+" test suites", "general-tests", entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"][0])
+	}
+}
+
+func TestTestPerSrcSrcsErrors(t *testing.T) {
+	t.Parallel()
+	prepareForCcTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`test_per_src_srcs: requires srcs to list the sources shared by the tests`)).
+		RunTestWithBp(t, `
+			cc_test {
+				name: "main_test",
+				test_per_src_srcs: ["foo_test.cpp"],
+				gtest: false,
+			}
+		`)
+}
+
 func TestDataLibsRelativeInstallPath(t *testing.T) {
 	t.Parallel()
 	bp := `
@@ -2838,7 +2904,7 @@ func TestLlndkLibrary(t *testing.T) {
 		m := result.ModuleForTests(module, variant).Module()
 		f := result.ModuleProvider(m, FlagExporterInfoProvider).(FlagExporterInfo)
 		android.AssertPathsRelativeToTopEquals(t, "exported include dirs for "+module+"["+variant+"]",
-			expectedDirs, f.IncludeDirs)
+			expectedDirs, f.IncludeDirs.ToList())
 	}
 
 	checkExportedIncludeDirs("libllndk", "android_arm64_armv8-a_shared", "include")
@@ -2849,6 +2915,62 @@ func TestLlndkLibrary(t *testing.T) {
 	checkExportedIncludeDirs("libllndk_with_override_headers", "android_vendor.29_arm64_armv8-a_shared", "include_llndk")
 }
 
+func TestLlndkConsistencyCheck(t *testing.T) {
+	t.Parallel()
+	result := prepareForCcTest.RunTestWithBp(t, `
+	cc_library {
+		name: "libllndk",
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+		},
+	}
+	`)
+
+	libllndk := result.ModuleForTests("libllndk", "android_arm64_armv8-a_shared")
+	link := libllndk.Rule("ld")
+	check := libllndk.Rule("checkLlndkSymbols")
+	android.AssertPathRelativeToTopEquals(t, "check output",
+		"out/soong/.intermediates/libllndk/android_arm64_armv8-a_shared/check_llndk_symbols/libllndk.so.timestamp", check.Output)
+	android.AssertPathRelativeToTopEquals(t, "checked file", android.PathRelativeToTop(link.Output), check.Input)
+	android.AssertStringDoesContain(t, "symbol list", check.Args["symbolList"], "gen/llndk_check/abi_symbol_list.txt")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(link.Validations), android.PathRelativeToTop(check.Output))
+
+	symbolList := libllndk.Output("llndk_check/abi_symbol_list.txt")
+	android.AssertStringEquals(t, "symbol list api level", "current", symbolList.Args["apiLevel"])
+	android.AssertStringEquals(t, "symbol list flags", "--llndk", symbolList.Args["flags"])
+
+	// The vendor variant is the LLNDK variant, it is not checked against itself.
+	vendor := result.ModuleForTests("libllndk", "android_vendor.29_arm64_armv8-a_shared")
+	if check := vendor.MaybeRule("checkLlndkSymbols"); check.Rule != nil {
+		t.Errorf("unexpected llndk consistency check of the vendor variant")
+	}
+
+	android.AssertStringEquals(t, "include dirs", "", check.Args["includeDirs"])
+
+	// The missing include directory is passed on to the check, which reports it together with the
+	// symbol problems of the library.
+	result = android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("include/foo.h", nil),
+	).RunTestWithBp(t, `
+	cc_library {
+		name: "libllndk",
+		export_include_dirs: ["include_impl"],
+		export_system_include_dirs: ["include_system"],
+		llndk: {
+			symbol_file: "libllndk.map.txt",
+			override_export_include_dirs: ["include", "include_missing"],
+		},
+	}
+	`)
+
+	check = result.ModuleForTests("libllndk", "android_arm64_armv8-a_shared").Rule("checkLlndkSymbols")
+	android.AssertStringEquals(t, "include dirs",
+		"--include-dir include --include-dir include_missing --include-dir include_system", check.Args["includeDirs"])
+	android.AssertStringListContains(t, "check implicits", check.Implicits.Strings(), "include/foo.h")
+}
+
 func TestLlndkHeaders(t *testing.T) {
 	t.Parallel()
 	ctx := testCc(t, `
@@ -3032,6 +3154,51 @@ func TestExcludeRuntimeLibs(t *testing.T) {
 	checkRuntimeLibs(t, nil, module)
 }
 
+func TestVendorSystemSharedLibs(t *testing.T) {
+	t.Parallel()
+	ctx := testCc(t, `
+		cc_library {
+			name: "libvendor_available",
+			vendor_available: true,
+			product_available: true,
+			target: {
+				vendor: {
+					system_shared_libs: ["libc"],
+				},
+			},
+		}
+	`)
+
+	checkSystemSharedLibs := func(variant string, expected []string) {
+		t.Helper()
+		module := ctx.ModuleForTests("libvendor_available", variant).Module().(*Module)
+		android.AssertDeepEquals(t, variant+" system_shared_libs", expected, module.Properties.AndroidMkSystemSharedLibs)
+	}
+
+	checkSystemSharedLibs(coreVariant, []string{"libc", "libm", "libdl"})
+	checkSystemSharedLibs(vendorVariant, []string{"libc"})
+	checkSystemSharedLibs(productVariant, []string{"libc", "libm", "libdl"})
+}
+
+func TestVendorSystemSharedLibsUnavailable(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `"libsystem_only" is not available to the vendor image`, `
+		cc_library {
+			name: "libsystem_only",
+		}
+
+		cc_library {
+			name: "libvendor_available",
+			vendor_available: true,
+			target: {
+				vendor: {
+					system_shared_libs: ["libc", "libsystem_only"],
+				},
+			},
+		}
+	`)
+}
+
 func TestRuntimeLibsNoVndk(t *testing.T) {
 	t.Parallel()
 	ctx := testCcNoVndk(t, runtimeLibAndroidBp)
@@ -4141,6 +4308,39 @@ func TestFuzzTarget(t *testing.T) {
 	ctx.ModuleForTests("fuzz_smoke_test", variant).Rule("cc")
 }
 
+func TestFuzzTargetCoverageBuild(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_fuzz {
+			name: "fuzz_coverage_test",
+			srcs: ["foo.c"],
+		}`
+
+	for _, coverageBuild := range []bool{false, true} {
+		env := map[string]string{}
+		zipFileName := "fuzz-target-arm64.zip"
+		if coverageBuild {
+			env["FUZZ_COVERAGE_BUILD"] = "true"
+			zipFileName = "fuzz-coverage-target-arm64.zip"
+		}
+		ctx := android.GroupFixturePreparers(
+			prepareForCcTest,
+			android.FixtureMergeEnv(env),
+			android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+				ctx.RegisterSingletonType("cc_fuzz_packaging", fuzzPackagingFactory)
+			}),
+		).RunTestWithBp(t, bp)
+
+		variant := "android_arm64_armv8-a_fuzzer"
+		cFlags := ctx.ModuleForTests("fuzz_coverage_test", variant).Rule("cc").Args["cFlags"]
+		android.AssertStringContainsEquals(t, "cFlags", cFlags, "-fsanitize=fuzzer-no-link", !coverageBuild)
+		android.AssertStringContainsEquals(t, "cFlags", cFlags, "-fprofile-instr-generate", coverageBuild)
+		android.AssertStringContainsEquals(t, "cFlags", cFlags, "-fcoverage-mapping", coverageBuild)
+
+		ctx.SingletonForTests("cc_fuzz_packaging").Output(zipFileName)
+	}
+}
+
 func assertString(t *testing.T, got, expected string) {
 	t.Helper()
 	if got != expected {
@@ -4557,14 +4757,14 @@ func TestIncludeDirsExporting(t *testing.T) {
 	expectedIncludeDirs := func(expectedPaths string) exportedChecker {
 		return func(t *testing.T, name string, exported FlagExporterInfo) {
 			t.Helper()
-			checkPaths(t, fmt.Sprintf("%s: include dirs", name), expectedPaths, exported.IncludeDirs)
+			checkPaths(t, fmt.Sprintf("%s: include dirs", name), expectedPaths, exported.IncludeDirs.ToList())
 		}
 	}
 
 	expectedSystemIncludeDirs := func(expectedPaths string) exportedChecker {
 		return func(t *testing.T, name string, exported FlagExporterInfo) {
 			t.Helper()
-			checkPaths(t, fmt.Sprintf("%s: system include dirs", name), expectedPaths, exported.SystemIncludeDirs)
+			checkPaths(t, fmt.Sprintf("%s: system include dirs", name), expectedPaths, exported.SystemIncludeDirs.ToList())
 		}
 	}
 
@@ -4794,6 +4994,104 @@ func TestIncludeDirsExporting(t *testing.T) {
 			`),
 		)
 	})
+
+	t.Run("ensure generated_headers_strict exports only the declared generated headers", func(t *testing.T) {
+		ctx := testCc(t, genRuleModules+`
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			generated_headers: ["genrule_foo", "genrule_bar"],
+			export_generated_headers: ["genrule_foo"],
+			generated_headers_strict: true,
+		}
+		`)
+		foo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+		// Only the shim directory of the headers of export_generated_headers is reexported.
+		checkIncludeDirs(t, ctx, foo.Module(),
+			expectedIncludeDirs(`
+				.intermediates/libfoo/android_arm64_armv8-a_shared/generated_headers_strict/exported
+			`),
+			expectedSystemIncludeDirs(``),
+			expectedGeneratedHeaders(`
+				.intermediates/libfoo/android_arm64_armv8-a_shared/generated_headers_strict/exported/foo/generated_header.h
+			`),
+			expectedOrderOnlyDeps(`
+				.intermediates/libfoo/android_arm64_armv8-a_shared/generated_headers_strict/exported/foo/generated_header.h
+			`),
+		)
+
+		copy := foo.Output("generated_headers_strict/private/bar/generated_header.h")
+		android.AssertPathRelativeToTopEquals(t, "copied header", "out/soong/.intermediates/genrule_bar/gen/generated_headers/bar/generated_header.h", copy.Input)
+
+		cFlags := android.StringRelativeToTop(ctx.Config(), foo.Rule("cc").Args["cFlags"])
+		android.AssertStringDoesContain(t, "cFlags", cFlags, "-Iout/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/generated_headers_strict/exported")
+		android.AssertStringDoesContain(t, "cFlags", cFlags, "-Iout/soong/.intermediates/libfoo/android_arm64_armv8-a_shared/generated_headers_strict/private")
+		android.AssertStringDoesNotContain(t, "cFlags", cFlags, ".intermediates/genrule_foo/gen/generated_headers")
+	})
+}
+
+func TestIncludeDirsReexportOrder(t *testing.T) {
+	t.Parallel()
+	ctx := testCc(t, `
+		cc_library_headers {
+			name: "libtop",
+			export_include_dirs: ["top"],
+			header_libs: ["liba", "libb"],
+			export_header_lib_headers: ["liba", "libb"],
+		}
+
+		cc_library_headers {
+			name: "liba",
+			export_include_dirs: ["a"],
+			header_libs: ["libcommon"],
+			export_header_lib_headers: ["libcommon"],
+		}
+
+		cc_library_headers {
+			name: "libb",
+			export_include_dirs: ["b"],
+			header_libs: ["libcommon"],
+			export_header_lib_headers: ["libcommon"],
+		}
+
+		cc_library_headers {
+			name: "libcommon",
+			export_include_dirs: ["common"],
+		}
+	`)
+
+	top := ctx.ModuleForTests("libtop", "android_arm64_armv8-a").Module()
+	exported := ctx.ModuleProvider(top, FlagExporterInfoProvider).(FlagExporterInfo)
+	// The include directories of a module come first, followed by the ones reexported from each
+	// dependency in order, keeping only the first copy of the ones reexported more than once.
+	expected := []string{"top", "a", "common", "b"}
+	android.AssertArrayString(t, "include dirs", expected, android.NormalizePathsForTesting(exported.IncludeDirs.ToList()))
+}
+
+func TestGeneratedHeadersStrictConflict(t *testing.T) {
+	t.Parallel()
+	testCcError(t, `generated header "foo.h" is declared by both "genrule_foo" and "genrule_bar"`, `
+		genrule {
+			name: "genrule_foo",
+			cmd: "generate-foo",
+			out: ["foo/foo.h"],
+			export_include_dirs: ["foo"],
+		}
+
+		genrule {
+			name: "genrule_bar",
+			cmd: "generate-bar",
+			out: ["bar/foo.h"],
+			export_include_dirs: ["bar"],
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			generated_headers: ["genrule_foo", "genrule_bar"],
+			generated_headers_strict: true,
+		}
+	`)
 }
 
 func TestIncludeDirectoryOrdering(t *testing.T) {
@@ -5228,3 +5526,96 @@ func TestDclaLibraryInApex(t *testing.T) {
 	expectedOutputFiles := []string{"outputbase/execroot/__main__/foo.so"}
 	android.AssertDeepEquals(t, "output files", expectedOutputFiles, outputFiles.Strings())
 }
+
+func TestHostAndDeviceVariantPairs(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library {
+			name: "libfoo",
+			host_supported: true,
+		}
+
+		cc_library {
+			name: "libdevice",
+		}
+	`
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+	buildOS := result.Config.BuildOS.String()
+
+	variants := []struct {
+		device string
+		host   string
+	}{
+		{device: "android_arm64_armv8-a_shared", host: buildOS + "_x86_64_shared"},
+		{device: "android_arm_armv7-a-neon_shared", host: buildOS + "_x86_shared"},
+		{device: "android_arm64_armv8-a_static", host: buildOS + "_x86_64_static"},
+	}
+	for _, v := range variants {
+		device := result.Module("libfoo", v.device)
+		host := result.Module("libfoo", v.host)
+		android.AssertSame(t, "host variant of "+v.device, host, result.HostVariantOf(device))
+		android.AssertSame(t, "device variant of "+v.host, device, result.DeviceVariantOf(host))
+	}
+
+	libdevice := result.Module("libdevice", "android_arm64_armv8-a_shared")
+	if host := result.HostVariantOf(libdevice); host != nil {
+		t.Errorf("expected no host variant of libdevice, found %q", result.ModuleSubDir(host))
+	}
+}
+
+func TestExperimentVariants(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.PrepareForTestWithExperiments,
+		android.FixtureAddExperiment(android.ExperimentConfig{
+			Name:   "foo",
+			Paths:  []string{"exp"},
+			Cflags: []string{"-fexperiment"},
+		}),
+		android.FixtureAddTextFile("exp/Android.bp", `
+			cc_binary {
+				name: "bin",
+				srcs: ["bin.c"],
+				static_libs: ["libexp", "libother"],
+			}
+
+			cc_library_static {
+				name: "libexp",
+				srcs: ["exp.c"],
+			}
+		`),
+	).RunTestWithBp(t, `
+		cc_library_static {
+			name: "libother",
+			srcs: ["other.c"],
+		}
+	`)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	bin := result.ModuleForTests("bin", variant)
+	android.AssertStringDoesNotContain(t, "normal variant cflags", bin.Rule("cc").Args["cFlags"], "-fexperiment")
+	android.AssertBoolEquals(t, "normal variant hidden from make", false,
+		bin.Module().(*Module).Properties.HideFromMake)
+
+	binExperiment := result.ModuleForTests("bin", experimentVariant)
+	android.AssertStringDoesContain(t, "experiment variant cflags", binExperiment.Rule("cc").Args["cFlags"], "-fexperiment")
+	android.AssertBoolEquals(t, "experiment variant hidden from make", true,
+		binExperiment.Module().(*Module).Properties.HideFromMake)
+	android.AssertBoolEquals(t, "experiment variant prevents install", true,
+		binExperiment.Module().(*Module).Properties.PreventInstall)
+
+	libExperiment := result.ModuleForTests("libexp", variant+"_static_experiment-foo")
+	android.AssertStringDoesContain(t, "libexp experiment variant cflags",
+		libExperiment.Rule("cc").Args["cFlags"], "-fexperiment")
+
+	// The experiment variant of bin links the experiment variant of libexp, and the normal variant
+	// of libother which is not in the paths of the experiment.
+	libFlags := binExperiment.Rule("ld").Args["libFlags"]
+	android.AssertStringDoesContain(t, "experiment variant links libexp", libFlags,
+		libExperiment.Output("libexp.a").Output.String())
+	android.AssertStringDoesContain(t, "experiment variant links libother", libFlags,
+		result.ModuleForTests("libother", variant+"_static").Output("libother.a").Output.String())
+}
@@ -100,6 +100,11 @@ type BaseCompilerProperties struct {
 	// of genrule modules.
 	Generated_headers []string `android:"arch_variant,variant_prepend"`
 
+	// if set to true, only the headers that the generated_headers modules declare as outputs can be
+	// included, instead of any file in their include directories.  The declared headers are copied
+	// to a directory of this module, which is added to the include path instead.
+	Generated_headers_strict *bool
+
 	// pass -frtti instead of -fno-rtti
 	Rtti *bool
 
@@ -266,6 +271,10 @@ func (compiler *baseCompiler) includeBuildDirectory() bool {
 	return includeBuildDirectory(compiler.Properties.Include_build_directory)
 }
 
+func (compiler *baseCompiler) generatedHeadersStrict() bool {
+	return Bool(compiler.Properties.Generated_headers_strict)
+}
+
 func (compiler *baseCompiler) compilerInit(ctx BaseModuleContext) {}
 
 func (compiler *baseCompiler) compilerDeps(ctx DepsContext, deps Deps) Deps {
@@ -509,6 +518,10 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags, deps
 
 	flags.Global.CommonFlags = append(flags.Global.CommonFlags, tc.ToolchainCflags())
 
+	if ctx.Device() && config.FramePointersForArch(ctx.Config(), ctx.Arch().ArchType) {
+		flags.Global.CommonFlags = append(flags.Global.CommonFlags, "-fno-omit-frame-pointer")
+	}
+
 	cStd := parseCStd(compiler.Properties.C_std)
 	cppStd := parseCppStd(compiler.Properties.Cpp_std)
 
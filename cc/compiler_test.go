@@ -15,8 +15,13 @@
 package cc
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
 	"testing"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 )
 
@@ -44,3 +49,101 @@ func TestIsThirdParty(t *testing.T) {
 		}
 	}
 }
+
+func TestFramePointers(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			host_supported: true,
+		}`
+
+	for _, framePointers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("FramePointers=%t", framePointers), func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.FramePointers = proptools.BoolPtr(framePointers)
+				}),
+			).RunTestWithBp(t, bp)
+
+			checkFlags := func(variant string, expected bool) {
+				t.Helper()
+				cFlags := result.ModuleForTests("libfoo", variant).Rule("cc").Args["cFlags"]
+				android.AssertBoolEquals(t, variant+" keeps frame pointers", expected,
+					strings.Contains(cFlags, "-fno-omit-frame-pointer"))
+			}
+			checkFlags("android_arm64_armv8-a_shared", framePointers)
+			// 32-bit arm code always omits the frame pointers.
+			checkFlags("android_arm_armv7-a-neon_shared", false)
+			checkFlags("linux_glibc_x86_64_shared", false)
+		})
+	}
+}
+
+func TestIncludeDirsInSubpackage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		bp       string
+		preparer android.FixturePreparer
+		error    string
+	}{
+		{
+			name: "local_include_dirs",
+			bp: `
+				cc_library {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					local_include_dirs: ["include"],
+				}`,
+			error: `"include" is in the package "foo/include", not in the package of the module`,
+		},
+		{
+			name: "export_include_dirs",
+			bp: `
+				cc_library {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					export_include_dirs: ["include"],
+				}`,
+			error: `"include" is in the package "foo/include", not in the package of the module`,
+		},
+		{
+			name: "granted",
+			bp: `
+				cc_library {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					local_include_dirs: ["include"],
+					export_include_dirs: ["include"],
+				}`,
+			preparer: android.FixtureAddTextFile("foo/include/Android.bp", `
+				package {
+					allow_external_srcs_from: ["//foo"],
+				}`),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			errorHandler := android.FixtureExpectsNoErrors
+			if tc.error != "" {
+				errorHandler = android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.error))
+			}
+			preparer := tc.preparer
+			if preparer == nil {
+				preparer = android.FixtureAddTextFile("foo/include/Android.bp", "")
+			}
+			result := android.GroupFixturePreparers(
+				prepareForCcTest,
+				preparer,
+				android.FixtureAddTextFile("foo/Android.bp", tc.bp),
+			).ExtendWithErrorHandler(errorHandler).RunTest(t)
+
+			if tc.error == "" {
+				cFlags := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("cc").Args["cFlags"]
+				android.AssertStringDoesContain(t, "cFlags", cFlags, "-Ifoo/include")
+			}
+		})
+	}
+}
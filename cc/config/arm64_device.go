@@ -199,6 +199,12 @@ func (toolchainArm64) LibclangRuntimeLibraryArch() string {
 	return "aarch64"
 }
 
+// Arm64StackProtectorEnabled returns true if the stack protector of deviceGlobalCflags is left
+// enabled for the given arm64 arch variant.
+func Arm64StackProtectorEnabled(archVariant string) bool {
+	return !android.InList("-fno-stack-protector", arm64ArchVariantCflags[archVariant])
+}
+
 func arm64ToolchainFactory(arch android.Arch) Toolchain {
 	switch arch.ArchVariant {
 	case "armv8-a":
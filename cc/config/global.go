@@ -467,6 +467,17 @@ func init() {
 
 var HostPrebuiltTag = exportedVars.ExportVariableConfigMethod("HostPrebuiltTag", android.Config.PrebuiltOS)
 
+// FramePointersForArch returns true if the product keeps the frame pointers in the native code
+// compiled for a device architecture.  They are only kept when the product sets FramePointers,
+// as they cost a register and a few instructions in every function, which most products do not
+// want to pay outside of profiling builds.  32-bit arm code is always compiled with
+// -fomit-frame-pointer (see armCflags): arm and thumb functions keep the frame pointer in
+// different registers, r11 and r7, so the frame chain cannot be followed reliably through mixed
+// code, and the register is scarce with only 16 of them.
+func FramePointersForArch(config android.Config, arch android.ArchType) bool {
+	return config.FramePointers() && arch != android.Arm
+}
+
 func ClangPath(ctx android.PathContext, file string) android.SourcePath {
 	type clangToolKey string
 
@@ -130,7 +130,7 @@ func (fuzzBin *fuzzBinary) linkerDeps(ctx DepsContext, deps Deps) Deps {
 		// mutation based on signals in strcmp, memcpy, etc. This is only needed for
 		// fuzz targets, not generic HWASAN-ified binaries or libraries.
 		if module, ok := ctx.Module().(*Module); ok {
-			if module.IsSanitizerEnabled(Hwasan) {
+			if module.IsSanitizerEnabled(Hwasan) && !fuzz.IsCoverageBuild(ctx.Config()) {
 				deps.StaticLibs = append(deps.StaticLibs, config.LibFuzzerRuntimeInterceptors(ctx.toolchain()))
 			}
 		}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"android/soong/android"
+	"android/soong/genrule"
+)
+
+// A module with generated_headers_strict: true does not add the include directories of its
+// generated_headers dependencies to its include path, as they can contain any file written by the
+// genrule, including files that are not declared as outputs.  Instead, the declared outputs of
+// each genrule are copied to a shim directory of the module, at their path relative to the
+// include directory of the genrule they are in, and the shim directory is added to the include
+// path.  A header that the genrule does not declare, and that would only show up in the depfile
+// of a compile, cannot be included and fails the compile.
+//
+// The headers of the genrules in export_generated_headers are copied to a separate shim directory,
+// which is the only one that is reexported, so that the headers of the other genrules do not leak
+// to the modules that depend on this one.
+
+type generatedHeadersShim struct {
+	// exportedDir and privateDir hold the headers of the genrules that are reexported and of the
+	// other genrules.
+	exportedDir android.OutputPath
+	privateDir  android.OutputPath
+
+	// owners maps the path of each header in the shim directories to the genrule it comes from.
+	owners map[string]string
+	// copied is the set of copies of headers in the shim directories.
+	copied map[string]bool
+}
+
+func newGeneratedHeadersShim(ctx android.ModuleContext) *generatedHeadersShim {
+	dir := android.PathForModuleOut(ctx, "generated_headers_strict").OutputPath
+	return &generatedHeadersShim{
+		exportedDir: dir.Join(ctx, "exported"),
+		privateDir:  dir.Join(ctx, "private"),
+		owners:      make(map[string]string),
+		copied:      make(map[string]bool),
+	}
+}
+
+// add copies the headers that the genrule named depName declares as outputs in its include
+// directories to the exported or the private shim directory, and returns the copies and the shim
+// directory.
+func (s *generatedHeadersShim) add(ctx android.ModuleContext, depName string,
+	gen genrule.SourceFileGenerator, exported bool) (android.Paths, android.Path) {
+
+	dir := s.privateDir
+	if exported {
+		dir = s.exportedDir
+	}
+
+	var copies android.Paths
+	for _, genDir := range gen.GeneratedHeaderDirs() {
+		for _, header := range gen.GeneratedSourceFiles() {
+			rel, isRel := android.MaybeRel(ctx, genDir.String(), header.String())
+			if !isRel {
+				continue
+			}
+			// Both shim directories are in the include path, so a header can only come from one
+			// genrule.
+			if owner, exists := s.owners[rel]; exists && owner != depName {
+				ctx.PropertyErrorf("generated_headers", "generated header %q is declared by both %q and %q",
+					rel, owner, depName)
+				continue
+			}
+			s.owners[rel] = depName
+
+			// The genrule may be in both generated_sources and generated_headers.
+			copy := dir.Join(ctx, rel)
+			if !s.copied[copy.String()] {
+				s.copied[copy.String()] = true
+				ctx.Build(pctx, android.BuildParams{
+					Rule:        android.Cp,
+					Description: "generated header " + rel,
+					Input:       header,
+					Output:      copy,
+				})
+			}
+			copies = append(copies, copy)
+		}
+	}
+	return copies, dir
+}
@@ -15,17 +15,87 @@
 package cc
 
 import (
+	"sort"
+	"strings"
+
 	"android/soong/android"
 )
 
+type kernelHeadersProperties struct {
+	// List of the kernel versions that headers are provided for, in the form "<version>=<headers>".
+	// <headers> is either a directory of sanitized headers relative to the module directory, or
+	// ":module" for a genrule that generates them, e.g. "android13-5.15=android13-5.15/include" or
+	// "android14-6.1=:kernel_headers_android14-6.1".  The version whose headers are exported is
+	// selected by the KernelHeadersVersion product variable.  If no versions are listed the headers
+	// come from TARGET_BOARD_KERNEL_HEADERS and TARGET_PRODUCT_KERNEL_HEADERS instead.
+	Versions []string
+}
+
 type kernelHeadersDecorator struct {
 	*libraryDecorator
+
+	properties kernelHeadersProperties
+}
+
+func (stub *kernelHeadersDecorator) linkerProps() []interface{} {
+	return append(stub.libraryDecorator.linkerProps(), &stub.properties)
+}
+
+// selectedVersion returns the headers listed in the versions property for the kernel version
+// selected by the product config, and whether they were found.
+func (stub *kernelHeadersDecorator) selectedVersion(ctx android.BaseModuleContext) (string, bool) {
+	selected := ctx.DeviceConfig().KernelHeadersVersion()
+	var available []string
+	for _, entry := range stub.properties.Versions {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			ctx.PropertyErrorf("versions", "invalid entry %q, expected <version>=<headers>", entry)
+			return "", false
+		}
+		if parts[0] == selected {
+			return parts[1], true
+		}
+		available = append(available, parts[0])
+	}
+	sort.Strings(available)
+	if selected == "" {
+		ctx.PropertyErrorf("versions", "no kernel headers version is selected, set KernelHeadersVersion to one of: %s",
+			strings.Join(available, ", "))
+	} else {
+		ctx.PropertyErrorf("versions", "kernel headers version %q is not available, available versions are: %s",
+			selected, strings.Join(available, ", "))
+	}
+	return "", false
+}
+
+func (stub *kernelHeadersDecorator) linkerDeps(ctx DepsContext, deps Deps) Deps {
+	deps = stub.libraryDecorator.linkerDeps(ctx, deps)
+	if ctx.Device() && len(stub.properties.Versions) > 0 {
+		if headers, ok := stub.selectedVersion(ctx); ok {
+			if gen := android.SrcIsModule(headers); gen != "" {
+				deps.GeneratedHeaders = append(deps.GeneratedHeaders, gen)
+				deps.ReexportGeneratedHeaders = append(deps.ReexportGeneratedHeaders, gen)
+			}
+		}
+	}
+	return deps
 }
 
 func (stub *kernelHeadersDecorator) link(ctx ModuleContext, flags Flags, deps PathDeps, objs Objects) android.Path {
 	if ctx.Device() {
 		f := &stub.libraryDecorator.flagExporter
-		f.reexportSystemDirs(android.PathsForSource(ctx, ctx.DeviceConfig().DeviceKernelHeaderDirs())...)
+		if len(stub.properties.Versions) > 0 {
+			// Errors were already reported when the dependencies were added.
+			if headers, ok := stub.selectedVersion(ctx); ok && android.SrcIsModule(headers) == "" {
+				f.reexportDirs(android.PathForModuleSrc(ctx, headers))
+			}
+			// The headers of a genrule version were added by linkerDeps.
+			f.reexportTransitiveDirs(deps.ReexportedDirs)
+			f.reexportDeps(deps.ReexportedDeps...)
+			f.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
+		} else {
+			f.reexportSystemDirs(android.PathsForSource(ctx, ctx.DeviceConfig().DeviceKernelHeaderDirs())...)
+		}
 		f.setProvider(ctx)
 	}
 	return stub.libraryDecorator.linkStatic(ctx, flags, deps, objs)
@@ -36,6 +106,11 @@ func (stub *kernelHeadersDecorator) link(ctx ModuleContext, flags Flags, deps Pa
 // a makefile for compilation. See
 // https://android.googlesource.com/platform/build/+/master/core/config.mk
 // for more details on them.
+//
+// Alternatively, kernel_headers can list sanitized headers for several kernel
+// versions in the versions property, and exports the headers of the version
+// selected by the KernelHeadersVersion product variable to the modules that
+// depend on it via header_libs.
 func kernelHeadersFactory() android.Module {
 	module, library := NewLibrary(android.HostAndDeviceSupported)
 	library.HeaderOnly()
@@ -0,0 +1,88 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"regexp"
+	"testing"
+
+	"android/soong/android"
+)
+
+const kernelHeadersVersionsBp = `
+	kernel_headers {
+		name: "kernel_uapi_headers",
+		versions: [
+			"android13-5.15=android13-5.15/include",
+			"android14-6.1=android14-6.1/include",
+		],
+	}
+
+	cc_library_static {
+		name: "libdriver",
+		srcs: ["driver.c"],
+		header_libs: ["kernel_uapi_headers"],
+	}
+`
+
+func prepareForKernelHeadersTest(version string) android.FixturePreparer {
+	return android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.MockFS{
+			"driver.c":                               nil,
+			"android13-5.15/include/linux/android.h": nil,
+			"android14-6.1/include/linux/android.h":  nil,
+		}.AddToFixture(),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.KernelHeadersVersion = StringPtr(version)
+		}),
+	)
+}
+
+func TestKernelHeadersVersions(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		version  string
+		included string
+		excluded string
+	}{
+		{
+			version:  "android13-5.15",
+			included: "-Iandroid13-5.15/include",
+			excluded: "-Iandroid14-6.1/include",
+		},
+		{
+			version:  "android14-6.1",
+			included: "-Iandroid14-6.1/include",
+			excluded: "-Iandroid13-5.15/include",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			result := prepareForKernelHeadersTest(tc.version).RunTestWithBp(t, kernelHeadersVersionsBp)
+			cflags := result.ModuleForTests("libdriver", "android_arm64_armv8-a_static").Rule("cc").Args["cFlags"]
+			android.AssertStringDoesContain(t, "libdriver cflags", cflags, tc.included)
+			android.AssertStringDoesNotContain(t, "libdriver cflags", cflags, tc.excluded)
+		})
+	}
+}
+
+func TestKernelHeadersMissingVersion(t *testing.T) {
+	t.Parallel()
+	prepareForKernelHeadersTest("android15-6.6").
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			regexp.QuoteMeta(`kernel headers version "android15-6.6" is not available, available versions are: android13-5.15, android14-6.1`))).
+		RunTestWithBp(t, kernelHeadersVersionsBp)
+}
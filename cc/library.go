@@ -199,7 +199,8 @@ type FlagExporterProperties struct {
 			// list of exported include directories, like
 			// export_include_dirs, that will be applied to
 			// vendor or product variant of this library.
-			// This will overwrite any other declarations.
+			// This will overwrite any other declarations, e.g. to hide the headers of
+			// platform-only APIs from vendor or product modules. The directories must exist.
 			Override_export_include_dirs []string
 		}
 	}
@@ -697,11 +698,15 @@ func LibraryHostSharedFactory() android.Module {
 type flagExporter struct {
 	Properties FlagExporterProperties
 
-	dirs       android.Paths // Include directories to be included with -I
-	systemDirs android.Paths // System include directories to be included with -isystem
-	flags      []string      // Exported raw flags.
-	deps       android.Paths
-	headers    android.Paths
+	// Include directories to be included with -I, and system include directories to be included
+	// with -isystem, in the order they were exported. Each group of directories is a DepSet so that
+	// the directories reexported from the dependencies are not copied.
+	dirs       []*android.DepSet
+	systemDirs []*android.DepSet
+
+	flags   []string // Exported raw flags.
+	deps    android.Paths
+	headers android.Paths
 }
 
 // exportedIncludes returns the effective include paths for this module and
@@ -717,11 +722,32 @@ func (f *flagExporter) exportedIncludes(ctx ModuleContext) android.Paths {
 	return android.PathsForModuleSrc(ctx, f.Properties.Export_include_dirs)
 }
 
+// checkImageExportIncludeDirs verifies that the directories of the
+// target.vendor.override_export_include_dirs or target.product.override_export_include_dirs
+// property of the current image variant exist.
+func (f *flagExporter) checkImageExportIncludeDirs(ctx ModuleContext) {
+	check := func(image string, dirs []string) {
+		property := "target." + image + ".override_export_include_dirs"
+		for _, dir := range dirs {
+			if path := android.ExistentPathForSource(ctx, ctx.ModuleDir(), dir); !path.Valid() {
+				ctx.PropertyErrorf(property, "directory %q does not exist", dir)
+			}
+		}
+	}
+	if ctx.inVendor() {
+		check("vendor", f.Properties.Target.Vendor.Override_export_include_dirs)
+	}
+	if ctx.inProduct() {
+		check("product", f.Properties.Target.Product.Override_export_include_dirs)
+	}
+}
+
 // exportIncludes registers the include directories and system include directories to be exported
 // transitively to modules depending on this module.
 func (f *flagExporter) exportIncludes(ctx ModuleContext) {
-	f.dirs = append(f.dirs, f.exportedIncludes(ctx)...)
-	f.systemDirs = append(f.systemDirs, android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
+	f.checkImageExportIncludeDirs(ctx)
+	f.reexportDirs(f.exportedIncludes(ctx)...)
+	f.reexportSystemDirs(android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
 }
 
 func (f *flagExporter) exportExtraFlags(ctx ModuleContext) {
@@ -732,20 +758,53 @@ func (f *flagExporter) exportExtraFlags(ctx ModuleContext) {
 // exported transitively both as system include directories to modules depending on this module.
 func (f *flagExporter) exportIncludesAsSystem(ctx ModuleContext) {
 	// all dirs are force exported as system
-	f.systemDirs = append(f.systemDirs, f.exportedIncludes(ctx)...)
-	f.systemDirs = append(f.systemDirs, android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
+	f.checkImageExportIncludeDirs(ctx)
+	f.reexportSystemDirs(f.exportedIncludes(ctx)...)
+	f.reexportSystemDirs(android.PathsForModuleSrc(ctx, f.Properties.Export_system_include_dirs)...)
 }
 
 // reexportDirs registers the given directories as include directories to be exported transitively
 // to modules depending on this module.
 func (f *flagExporter) reexportDirs(dirs ...android.Path) {
-	f.dirs = append(f.dirs, dirs...)
+	if len(dirs) > 0 {
+		f.dirs = append(f.dirs, android.NewDepSet(android.PREORDER, dirs, nil))
+	}
 }
 
 // reexportSystemDirs registers the given directories as system include directories
 // to be exported transitively to modules depending on this module.
 func (f *flagExporter) reexportSystemDirs(dirs ...android.Path) {
-	f.systemDirs = append(f.systemDirs, dirs...)
+	if len(dirs) > 0 {
+		f.systemDirs = append(f.systemDirs, android.NewDepSet(android.PREORDER, dirs, nil))
+	}
+}
+
+// reexportTransitiveDirs registers the include directories reexported from the dependencies, see
+// PathDeps.ReexportedDirs, to be exported transitively to modules depending on this module.
+func (f *flagExporter) reexportTransitiveDirs(dirs *android.DepSet) {
+	if dirs != nil {
+		f.dirs = append(f.dirs, dirs)
+	}
+}
+
+// reexportTransitiveSystemDirs registers the system include directories reexported from the
+// dependencies, see PathDeps.ReexportedSystemDirs, to be exported transitively to modules
+// depending on this module.
+func (f *flagExporter) reexportTransitiveSystemDirs(dirs *android.DepSet) {
+	if dirs != nil {
+		f.systemDirs = append(f.systemDirs, dirs)
+	}
+}
+
+// exportedDirs returns the include directories exported by this module, with duplicates removed.
+func (f *flagExporter) exportedDirs() android.Paths {
+	return android.NewDepSet(android.PREORDER, nil, f.dirs).ToList()
+}
+
+// exportedSystemDirs returns the system include directories exported by this module, with
+// duplicates removed.
+func (f *flagExporter) exportedSystemDirs() android.Paths {
+	return android.NewDepSet(android.PREORDER, nil, f.systemDirs).ToList()
 }
 
 // reexportFlags registers the flags to be exported transitively to modules depending on this
@@ -771,9 +830,9 @@ func (f *flagExporter) addExportedGeneratedHeaders(headers ...android.Path) {
 func (f *flagExporter) setProvider(ctx android.ModuleContext) {
 	ctx.SetProvider(FlagExporterInfoProvider, FlagExporterInfo{
 		// Comes from Export_include_dirs property, and those of exported transitive deps
-		IncludeDirs: android.FirstUniquePaths(f.dirs),
+		IncludeDirs: android.NewDepSet(android.PREORDER, nil, f.dirs),
 		// Comes from Export_system_include_dirs property, and those of exported transitive deps
-		SystemIncludeDirs: android.FirstUniquePaths(f.systemDirs),
+		SystemIncludeDirs: android.NewDepSet(android.PREORDER, nil, f.systemDirs),
 		// Used in very few places as a one-off way of adding extra defines.
 		Flags: f.flags,
 		// Used sparingly, for extra files that need to be explicitly exported to dependers,
@@ -924,6 +983,9 @@ func (handler *ccLibraryBazelHandler) generateSharedBazelBuildActions(ctx androi
 
 	if len(ccInfo.AbiDiffFiles) > 0 {
 		handler.module.linker.(*libraryDecorator).sAbiDiff = android.PathsForBazelOut(ctx, ccInfo.AbiDiffFiles)
+		ctx.SetProvider(android.ValidationsProvider, android.ValidationsInfo{
+			Validations: handler.module.linker.(*libraryDecorator).sAbiDiff,
+		})
 	}
 
 	ctx.SetProvider(SharedLibraryInfoProvider, SharedLibraryInfo{
@@ -1095,7 +1157,7 @@ func (l *libraryDecorator) collectHeadersForSnapshot(ctx android.ModuleContext)
 	// can't be globbed, and they should be manually collected.
 	// So, we first filter out intermediate directories (which contains generated headers)
 	// from exported directories, and then glob headers under remaining directories.
-	ret = append(ret, GlobHeadersForSnapshot(ctx, append(android.CopyOfPaths(l.flagExporter.dirs), l.flagExporter.systemDirs...))...)
+	ret = append(ret, GlobHeadersForSnapshot(ctx, append(l.flagExporter.exportedDirs(), l.flagExporter.exportedSystemDirs()...))...)
 
 	// Collect generated headers
 	ret = append(ret, GlobGeneratedHeadersForSnapshot(ctx, append(android.CopyOfPaths(l.flagExporter.headers), l.flagExporter.deps...))...)
@@ -1780,9 +1842,16 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	linkerDeps = append(linkerDeps, deps.EarlySharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.SharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.LateSharedLibsDeps...)
+
+	validations := objs.tidyDepFiles
+	if !library.buildStubs() {
+		validations = append(library.maxPageSizeValidations(ctx, outputFile), validations...)
+		validations = append(validations, library.llndkConsistencyValidations(ctx, outputFile)...)
+		validations = append(validations, checkReplacingPrebuiltAbi(ctx, tocFile)...)
+	}
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs,
 		deps.StaticLibs, deps.LateStaticLibs, deps.WholeStaticLibs,
-		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, objs.tidyDepFiles)
+		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, validations)
 
 	objs.coverageFiles = append(objs.coverageFiles, deps.StaticLibObjs.coverageFiles...)
 	objs.coverageFiles = append(objs.coverageFiles, deps.WholeStaticLibObjs.coverageFiles...)
@@ -1791,6 +1860,9 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 
 	library.coverageOutputFile = transformCoverageFilesToZip(ctx, objs, library.getLibName(ctx))
 	library.linkSAbiDumpFiles(ctx, objs, fileName, unstrippedOutputFile)
+	if len(library.sAbiDiff) > 0 {
+		ctx.SetProvider(android.ValidationsProvider, android.ValidationsInfo{Validations: library.sAbiDiff})
+	}
 
 	var transitiveStaticLibrariesForOrdering *android.DepSet
 	if static := ctx.GetDirectDepsWithTag(staticVariantTag); len(static) > 0 {
@@ -2109,8 +2181,8 @@ func (library *libraryDecorator) link(ctx ModuleContext,
 	// Export include paths and flags to be propagated up the tree.
 	library.exportIncludes(ctx)
 	library.exportExtraFlags(ctx)
-	library.reexportDirs(deps.ReexportedDirs...)
-	library.reexportSystemDirs(deps.ReexportedSystemDirs...)
+	library.reexportTransitiveDirs(deps.ReexportedDirs)
+	library.reexportTransitiveSystemDirs(deps.ReexportedSystemDirs)
 	library.reexportFlags(deps.ReexportedFlags...)
 	library.reexportDeps(deps.ReexportedDeps...)
 	library.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
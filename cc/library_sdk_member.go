@@ -523,7 +523,7 @@ func (p *nativeLibInfoProperties) PopulateFromVariant(ctx android.SdkMemberConte
 	// Separate out the generated include dirs (which are arch specific) from the
 	// include dirs (which may not be).
 	exportedIncludeDirs, exportedGeneratedIncludeDirs := android.FilterPathListPredicate(
-		exportedInfo.IncludeDirs, isGeneratedHeaderDirectory)
+		exportedInfo.IncludeDirs.ToList(), isGeneratedHeaderDirectory)
 
 	target := ccModule.Target()
 	p.archSubDir = target.Arch.ArchType.String()
@@ -535,10 +535,7 @@ func (p *nativeLibInfoProperties) PopulateFromVariant(ctx android.SdkMemberConte
 	p.ExportedIncludeDirs = android.FirstUniquePaths(exportedIncludeDirs)
 	p.ExportedGeneratedIncludeDirs = android.FirstUniquePaths(exportedGeneratedIncludeDirs)
 
-	// Take a copy before filtering out duplicates to avoid changing the slice owned by the
-	// ccModule.
-	dirs := append(android.Paths(nil), exportedInfo.SystemIncludeDirs...)
-	p.ExportedSystemIncludeDirs = android.FirstUniquePaths(dirs)
+	p.ExportedSystemIncludeDirs = exportedInfo.SystemIncludeDirs.ToList()
 
 	p.ExportedFlags = exportedInfo.Flags
 	if ccModule.linker != nil {
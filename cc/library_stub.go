@@ -123,11 +123,11 @@ func (d *apiLibraryDecorator) Name(basename string) string {
 func (d *apiLibraryDecorator) exportIncludes(ctx ModuleContext) {
 	exporterProps := d.flagExporter.Properties
 	for _, dir := range exporterProps.Export_include_dirs {
-		d.dirs = append(d.dirs, android.MaybeExistentPathForSource(ctx, ctx.ModuleDir(), dir))
+		d.reexportDirs(android.MaybeExistentPathForSource(ctx, ctx.ModuleDir(), dir))
 	}
 	// system headers
 	for _, dir := range exporterProps.Export_system_include_dirs {
-		d.systemDirs = append(d.systemDirs, android.MaybeExistentPathForSource(ctx, ctx.ModuleDir(), dir))
+		d.reexportSystemDirs(android.MaybeExistentPathForSource(ctx, ctx.ModuleDir(), dir))
 	}
 }
 
@@ -206,8 +206,8 @@ func (d *apiLibraryDecorator) link(ctx ModuleContext, flags Flags, deps PathDeps
 
 	// Flags reexported from dependencies. (e.g. vndk_prebuilt_shared)
 	d.exportIncludes(ctx)
-	d.libraryDecorator.reexportDirs(deps.ReexportedDirs...)
-	d.libraryDecorator.reexportSystemDirs(deps.ReexportedSystemDirs...)
+	d.libraryDecorator.reexportTransitiveDirs(deps.ReexportedDirs)
+	d.libraryDecorator.reexportTransitiveSystemDirs(deps.ReexportedSystemDirs)
 	d.libraryDecorator.reexportFlags(deps.ReexportedFlags...)
 	d.libraryDecorator.reexportDeps(deps.ReexportedDeps...)
 	d.libraryDecorator.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
@@ -15,7 +15,10 @@
 package cc
 
 import (
+	"fmt"
 	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -287,8 +290,8 @@ cc_library {
 	android.AssertDeepEquals(t, "output files", expectedOutputFiles, outputFiles.Strings())
 
 	flagExporter := ctx.ModuleProvider(staticFoo, FlagExporterInfoProvider).(FlagExporterInfo)
-	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs)
-	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs)
+	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs.ToList())
+	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs.ToList())
 	android.AssertPathsRelativeToTopEquals(t, "exported headers", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.GeneratedHeaders)
 	android.AssertPathsRelativeToTopEquals(t, "deps", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.Deps)
 
@@ -302,8 +305,8 @@ cc_library {
 
 	android.AssertStringEquals(t, "unstripped shared library", "outputbase/execroot/__main__/foo_unstripped.so", sharedFoo.(*Module).linker.unstrippedOutputFilePath().String())
 	flagExporter = ctx.ModuleProvider(sharedFoo, FlagExporterInfoProvider).(FlagExporterInfo)
-	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs)
-	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs)
+	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs.ToList())
+	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs.ToList())
 	android.AssertPathsRelativeToTopEquals(t, "exported headers", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.GeneratedHeaders)
 	android.AssertPathsRelativeToTopEquals(t, "deps", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.Deps)
 }
@@ -356,8 +359,8 @@ cc_library {
 	android.AssertPathsRelativeToTopEquals(t, "output files", expectedOutputFiles, outputFiles)
 
 	flagExporter := ctx.ModuleProvider(staticFoo, FlagExporterInfoProvider).(FlagExporterInfo)
-	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs)
-	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs)
+	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs.ToList())
+	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs.ToList())
 	android.AssertPathsRelativeToTopEquals(t, "exported headers", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.GeneratedHeaders)
 	android.AssertPathsRelativeToTopEquals(t, "deps", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.Deps)
 
@@ -371,8 +374,8 @@ cc_library {
 
 	android.AssertStringEquals(t, "unstripped shared library", "outputbase/execroot/__main__/foo_unstripped.so", sharedFoo.(*Module).linker.unstrippedOutputFilePath().String())
 	flagExporter = ctx.ModuleProvider(sharedFoo, FlagExporterInfoProvider).(FlagExporterInfo)
-	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs)
-	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs)
+	android.AssertPathsRelativeToTopEquals(t, "exported include dirs", []string{"outputbase/execroot/__main__/include"}, flagExporter.IncludeDirs.ToList())
+	android.AssertPathsRelativeToTopEquals(t, "exported system include dirs", []string{"outputbase/execroot/__main__/system_include"}, flagExporter.SystemIncludeDirs.ToList())
 	android.AssertPathsRelativeToTopEquals(t, "exported headers", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.GeneratedHeaders)
 	android.AssertPathsRelativeToTopEquals(t, "deps", []string{"outputbase/execroot/__main__/foo.h"}, flagExporter.Deps)
 }
@@ -413,6 +416,102 @@ func TestLibraryDynamicList(t *testing.T) {
 
 }
 
+func TestLibraryHideStlSymbols(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			stl: "libc++_static",
+			hide_stl_symbols: true,
+			version_script: "foo.map.txt",
+			export_include_dirs: ["include"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["foo.cpp"],
+			stl: "libc++_static",
+			hide_stl_symbols: true,
+			allow_stl_in_exported_headers: true,
+			export_include_dirs: ["include"],
+		}`
+	result := android.GroupFixturePreparers(
+		PrepareForIntegrationTestWithCc,
+		android.FixtureAddTextFile("include/foo.h", "#include <string>\n"),
+	).RunTestWithBp(t, bp)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+	versionScript := libfoo.Output("hidden_stl_symbols.map")
+	android.AssertStringDoesContain(t, "hidden STL symbols version script",
+		android.ContentFromFileRuleForTests(t, versionScript), "      std::*;\n")
+
+	ld := libfoo.Rule("ld")
+	ldFlags := strings.Fields(ld.Args["ldFlags"])
+	android.AssertStringListContainsAllOf(t, "libfoo ldflags", ldFlags, []string{
+		"-Wl,--version-script,foo.map.txt",
+		"-Wl,--version-script," + versionScript.Output.String(),
+	})
+
+	checkHeaders := libfoo.Rule("check_exported_stl_headers")
+	android.AssertPathsRelativeToTopEquals(t, "checked headers", []string{"include/foo.h"}, checkHeaders.Implicits)
+	android.AssertStringDoesContain(t, "check command", checkHeaders.RuleParams.Command, "string|string_view|vector")
+	android.AssertStringListContainsAllOf(t, "libfoo implicits", ld.Implicits.Strings(), []string{
+		versionScript.Output.String(),
+		checkHeaders.Output.String(),
+	})
+
+	// The static variant does not link the STL.
+	if p := result.ModuleForTests("libfoo", "android_arm64_armv8-a_static").MaybeOutput("hidden_stl_symbols.map"); p.Rule != nil {
+		t.Errorf("unexpected hidden STL symbols version script in the static variant")
+	}
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared")
+	if p := libbar.MaybeRule("check_exported_stl_headers"); p.Rule != nil {
+		t.Errorf("unexpected check of the exported headers with allow_stl_in_exported_headers: true")
+	}
+	android.AssertStringDoesContain(t, "libbar ldflags", libbar.Rule("ld").Args["ldFlags"], "hidden_stl_symbols.map")
+}
+
+func TestLibraryHideStlSymbolsErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		bp            string
+		expectedError string
+	}{
+		{
+			name: "shared stl",
+			bp: `
+				cc_library_shared {
+					name: "libfoo",
+					srcs: ["foo.cpp"],
+					stl: "libc++",
+					hide_stl_symbols: true,
+				}`,
+			expectedError: `hide_stl_symbols: requires stl: "libc++_static", found "libc++"`,
+		},
+		{
+			name: "binary",
+			bp: `
+				cc_binary {
+					name: "foo",
+					srcs: ["foo.cpp"],
+					stl: "libc++_static",
+					hide_stl_symbols: true,
+				}`,
+			expectedError: `hide_stl_symbols: only supported for shared libraries`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			PrepareForIntegrationTestWithCc.
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.expectedError))).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
+
 func TestCcLibrarySharedWithBazelValidations(t *testing.T) {
 	t.Parallel()
 	bp := `
@@ -588,3 +687,358 @@ func TestWholeStaticLibPrebuilts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for baz.o",
 		libtransitiveWithSrcs.Args["arObjs"], bazObj.Output.String())
 }
+
+func TestStaticLibraryArchiveRspFiles(t *testing.T) {
+	t.Parallel()
+	var libs, modules []string
+	for i := 0; i < 800; i++ {
+		name := fmt.Sprintf("libwhole_static_prebuilt_with_a_long_name_%04d", i)
+		libs = append(libs, fmt.Sprintf("%q", name))
+		modules = append(modules, fmt.Sprintf(`
+		cc_prebuilt_library_static {
+			name: %q,
+			host_supported: true,
+			srcs: ["prebuilts/a_directory_with_a_long_name_for_static_libraries/%s.a"],
+		}`, name, name))
+	}
+	bp := fmt.Sprintf(`
+		cc_library_static {
+			name: "libfew",
+			host_supported: true,
+			srcs: ["foo.c"],
+			whole_static_libs: [%s],
+		}
+
+		cc_library_static {
+			name: "libmany",
+			host_supported: true,
+			srcs: ["foo.c"],
+			whole_static_libs: [%s],
+		}
+		%s
+	`, libs[0], strings.Join(libs, ", "), strings.Join(modules, "\n"))
+
+	checkArchives := func(t *testing.T, ctx *android.TestResult, variant string) {
+		// The objects are always passed to ar in the .rsp file of the rule.
+		few := ctx.ModuleForTests("libfew", variant).Rule("arWithLibs")
+		android.AssertStringEquals(t, "few rsp file", "${out}.rsp", few.RuleParams.Rspfile)
+		android.AssertStringDoesContain(t, "few command", few.RuleParams.Command, "@${out}.rsp")
+
+		// A short list of static libraries is passed on the command line.
+		android.AssertStringEquals(t, "few arLibs",
+			"prebuilts/a_directory_with_a_long_name_for_static_libraries/libwhole_static_prebuilt_with_a_long_name_0000.a",
+			few.Args["arLibs"])
+
+		// A long list of static libraries is passed in a second .rsp file.
+		many := ctx.ModuleForTests("libmany", variant).Rule("arWithLibs")
+		libsRspFile := "out/soong/.intermediates/libmany/" + variant + "/libmany.a.libs.rsp"
+		android.AssertStringEquals(t, "many arLibs", "@"+libsRspFile,
+			android.StringRelativeToTop(ctx.Config, many.Args["arLibs"]))
+		android.AssertPathsRelativeToTopEquals(t, "many implicits", []string{libsRspFile},
+			many.Implicits[:1])
+		android.AssertStringDoesContain(t, "many command", many.ExpandedCommand(),
+			"prebuilts/a_directory_with_a_long_name_for_static_libraries/libwhole_static_prebuilt_with_a_long_name_0799.a")
+	}
+
+	ctx := PrepareForIntegrationTestWithCc.RunTestWithBp(t, bp)
+	for _, variant := range []string{"android_arm64_armv8-a_static", "linux_glibc_x86_64_static"} {
+		t.Run(variant, func(t *testing.T) {
+			checkArchives(t, ctx, variant)
+		})
+	}
+
+	t.Run("darwin", func(t *testing.T) {
+		ctx := android.GroupFixturePreparers(
+			PrepareForIntegrationTestWithCc,
+			android.FixtureModifyConfig(func(config android.Config) {
+				delete(config.Targets, config.BuildOS)
+				config.BuildOS = android.Darwin
+				config.Targets[android.Darwin] = []android.Target{
+					{Os: android.Darwin, Arch: android.Arch{ArchType: android.X86_64}, NativeBridge: android.NativeBridgeDisabled},
+				}
+				config.BuildOSTarget = config.Targets[android.Darwin][0]
+				config.BuildOSCommonTarget = android.Target{Os: android.Darwin, Arch: android.Arch{ArchType: android.Common}}
+			}),
+		).RunTestWithBp(t, bp)
+
+		// llvm-ar reads response files with @ on Darwin as well.
+		checkArchives(t, ctx, "darwin_x86_64_static")
+	})
+}
+
+func TestLibraryMaxPageSize(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			srcs: ["foo.c"],
+			max_page_size: "16384",
+		}
+
+		cc_binary {
+			name: "bin",
+			srcs: ["foo.c"],
+			host_supported: true,
+			max_page_size: "4096",
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.DeviceMaxPageSizeSupported = StringPtr("16384")
+		}),
+	).RunTestWithBp(t, bp)
+
+	checkMaxPageSize := func(t *testing.T, name, variant, file, expected string, hasFlag bool) {
+		t.Helper()
+		m := result.ModuleForTests(name, variant)
+		link := m.Rule("ld")
+		flag := "-Wl,-z,max-page-size=" + expected
+		if hasFlag {
+			android.AssertStringDoesContain(t, "ldflags", link.Args["ldFlags"], flag)
+		} else {
+			android.AssertStringDoesNotContain(t, "ldflags", link.Args["ldFlags"], flag)
+		}
+
+		check := m.Output("check_max_page_size/" + file + ".timestamp")
+		android.AssertStringEquals(t, "max page size", expected, check.Args["maxPageSize"])
+		android.AssertPathRelativeToTopEquals(t, "checked file", android.PathRelativeToTop(link.Output), check.Input)
+		android.AssertPathsRelativeToTopEquals(t, "link validations",
+			[]string{android.PathRelativeToTop(check.Output)}, link.Validations)
+	}
+
+	// The default is set by the arm64 toolchain, not by the module.
+	checkMaxPageSize(t, "libfoo", "android_arm64_armv8-a_shared", "libfoo.so", "16384", false)
+	checkMaxPageSize(t, "libbar", "android_arm64_armv8-a_shared", "libbar.so", "16384", true)
+	checkMaxPageSize(t, "bin", "android_arm64_armv8-a", "bin", "4096", true)
+
+	bin := result.ModuleForTests("bin", "linux_glibc_x86_64")
+	android.AssertStringDoesNotContain(t, "host ldflags", bin.Rule("ld").Args["ldFlags"], "-Wl,-z,max-page-size=")
+	if check := bin.MaybeOutput("check_max_page_size/bin.timestamp"); check.Rule != nil {
+		t.Errorf("unexpected max page size check of host binary")
+	}
+}
+
+func TestLibraryMaxPageSizeInvalid(t *testing.T) {
+	t.Parallel()
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`max_page_size: must be one of \["4096" "16384"\], got "8192"`)).
+		RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c"],
+			max_page_size: "8192",
+		}
+	`)
+}
+
+func TestLibraryBuildId(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name          string
+		buildId       string
+		productStyle  string
+		expectedFlag  string
+		expectedError string
+	}{
+		{name: "default"},
+		{name: "none", buildId: "none", expectedFlag: "-Wl,--build-id=none"},
+		{name: "md5", buildId: "md5", expectedFlag: "-Wl,--build-id=md5"},
+		{name: "sha1", buildId: "sha1", expectedFlag: "-Wl,--build-id=sha1"},
+		{name: "uuid", buildId: "uuid", expectedFlag: "-Wl,--build-id=uuid"},
+		{name: "hex", buildId: "0x0123456789abcdef", expectedFlag: "-Wl,--build-id=0x0123456789abcdef"},
+		{name: "product default", productStyle: "sha1", expectedFlag: "-Wl,--build-id=sha1"},
+		{name: "property overrides product default", buildId: "none", productStyle: "sha1", expectedFlag: "-Wl,--build-id=none"},
+		{
+			name:          "odd hex digits",
+			buildId:       "0x123",
+			expectedError: `build_id: must be 0x followed by an even number of hex digits, got "0x123"`,
+		},
+		{
+			name:          "invalid hex digits",
+			buildId:       "0xfoo1",
+			expectedError: `build_id: must be 0x followed by an even number of hex digits, got "0xfoo1"`,
+		},
+		{
+			name:          "empty hex",
+			buildId:       "0x",
+			expectedError: `build_id: must be 0x followed by an even number of hex digits, got "0x"`,
+		},
+		{
+			name:          "unknown style",
+			buildId:       "fast",
+			expectedError: `build_id: must be one of \["none" "md5" "sha1" "uuid"\] or a 0x prefixed hex id, got "fast"`,
+		},
+		{
+			name:          "invalid product default",
+			productStyle:  "0x1",
+			expectedError: `invalid DefaultBuildIdStyle product variable: must be 0x followed by an even number of hex digits, got "0x1"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			buildId := ""
+			if tc.buildId != "" {
+				buildId = fmt.Sprintf("build_id: %q,", tc.buildId)
+			}
+			bp := fmt.Sprintf(`
+				cc_library_shared {
+					name: "libfoo",
+					srcs: ["foo.c"],
+					%s
+				}
+			`, buildId)
+
+			preparer := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					if tc.productStyle != "" {
+						variables.DefaultBuildIdStyle = StringPtr(tc.productStyle)
+					}
+				}),
+			)
+			if tc.expectedError != "" {
+				preparer.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.expectedError)).
+					RunTestWithBp(t, bp)
+				return
+			}
+			result := preparer.RunTestWithBp(t, bp)
+
+			m := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared")
+			link := m.Rule("ld")
+			ldFlags := link.Args["ldFlags"]
+			// The global ldflags of the device toolchain select an md5 build-id.
+			toolchainFlag := strings.Index(ldFlags, "-Wl,--build-id=md5")
+			if toolchainFlag < 0 {
+				t.Errorf("expected the toolchain build-id flag in ldflags %q", ldFlags)
+			}
+			if tc.expectedFlag == "" {
+				android.AssertIntEquals(t, "build-id flags", 1, strings.Count(ldFlags, "--build-id"))
+			} else if strings.LastIndex(ldFlags, tc.expectedFlag) <= toolchainFlag {
+				// The linker uses the last build-id flag.
+				t.Errorf("expected %q after the toolchain build-id flag in ldflags %q", tc.expectedFlag, ldFlags)
+			}
+
+			// The stripped library is stripped from the linked one without removing its build-id.
+			strip := m.Output("libfoo.so")
+			android.AssertPathRelativeToTopEquals(t, "strip input", android.PathRelativeToTop(link.Output), strip.Input)
+			android.AssertStringDoesNotContain(t, "strip args", strip.Args["args"], "--remove-build-id")
+		})
+	}
+}
+
+func TestLibraryBuildIdHost(t *testing.T) {
+	t.Parallel()
+	result := prepareForCcTest.RunTestWithBp(t, `
+		cc_library_shared {
+			name: "libfoo",
+			host_supported: true,
+			srcs: ["foo.c"],
+		}
+
+		cc_library_shared {
+			name: "libbar",
+			host_supported: true,
+			srcs: ["foo.c"],
+			build_id: "sha1",
+		}
+	`)
+
+	// The glibc host toolchain does not select a build-id.
+	ldFlags := result.ModuleForTests("libfoo", "linux_glibc_x86_64_shared").Rule("ld").Args["ldFlags"]
+	android.AssertStringDoesNotContain(t, "libfoo ldflags", ldFlags, "--build-id")
+
+	ldFlags = result.ModuleForTests("libbar", "linux_glibc_x86_64_shared").Rule("ld").Args["ldFlags"]
+	android.AssertStringDoesContain(t, "libbar ldflags", ldFlags, "-Wl,--build-id=sha1")
+}
+
+func TestLibraryImageExportIncludeDirs(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_library {
+			name: "libfoo",
+			vendor_available: true,
+			product_available: true,
+			export_include_dirs: ["include"],
+			target: {
+				vendor: {
+					override_export_include_dirs: ["include_vendor"],
+				},
+			},
+		}
+
+		cc_library {
+			name: "libcore",
+			srcs: ["foo.c"],
+			shared_libs: ["libfoo"],
+		}
+
+		cc_library {
+			name: "libvendor",
+			srcs: ["foo.c"],
+			vendor: true,
+			shared_libs: ["libfoo"],
+		}
+
+		cc_library {
+			name: "libproduct",
+			srcs: ["foo.c"],
+			product_specific: true,
+			shared_libs: ["libfoo"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddFile("include/foo.h", nil),
+		android.FixtureAddFile("include_vendor/foo.h", nil),
+	).RunTestWithBp(t, bp)
+
+	checkIncludeDirs := func(t *testing.T, variant string, expected string) {
+		t.Helper()
+		foo := result.ModuleForTests("libfoo", variant).Module()
+		exported := result.ModuleProvider(foo, FlagExporterInfoProvider).(FlagExporterInfo)
+		android.AssertArrayString(t, variant+" exported include dirs", []string{expected},
+			android.NormalizePathsForTesting(exported.IncludeDirs.ToList()))
+	}
+	checkIncludeDirs(t, coreVariant, "include")
+	checkIncludeDirs(t, vendorVariant, "include_vendor")
+	checkIncludeDirs(t, productVariant, "include")
+
+	checkCFlags := func(t *testing.T, name, variant string, includes, excludes string) {
+		t.Helper()
+		cFlags := strings.Fields(result.ModuleForTests(name, variant).Rule("cc").Args["cFlags"])
+		android.AssertStringListContains(t, name+" cFlags", cFlags, "-I"+includes)
+		android.AssertStringListDoesNotContain(t, name+" cFlags", cFlags, "-I"+excludes)
+	}
+	checkCFlags(t, "libcore", coreVariant, "include", "include_vendor")
+	checkCFlags(t, "libvendor", vendorVariant, "include_vendor", "include")
+	checkCFlags(t, "libproduct", productVariant, "include", "include_vendor")
+}
+
+func TestLibraryImageExportIncludeDirsErrors(t *testing.T) {
+	t.Parallel()
+	prepareForCcTest.ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`target.product.override_export_include_dirs: directory "include_missing" does not exist`)).
+		RunTestWithBp(t, `
+		cc_library {
+			name: "libfoo",
+			vendor_available: true,
+			product_available: true,
+			target: {
+				product: {
+					override_export_include_dirs: ["include_missing"],
+				},
+			},
+		}
+	`)
+}
@@ -154,6 +154,10 @@ type LinkableInterface interface {
 	// IsStubs returns true if the this is a stubs library.
 	IsStubs() bool
 
+	// HasStubsVariants returns true if this library has stubs variants that its dependencies across
+	// APEX boundaries link against.
+	HasStubsVariants() bool
+
 	// IsLlndk returns true for both LLNDK (public) and LLNDK-private libs.
 	IsLlndk() bool
 
@@ -398,10 +402,13 @@ var HeaderLibraryInfoProvider = blueprint.NewProvider(HeaderLibraryInfo{})
 
 // FlagExporterInfo is a provider to propagate transitive library information
 // pertaining to exported include paths and flags.
+//
+// The include directories are DepSets so that the modules that reexport them do not need to copy
+// them; they are only flattened by the modules that use them.
 type FlagExporterInfo struct {
-	IncludeDirs       android.Paths // Include directories to be included with -I
-	SystemIncludeDirs android.Paths // System include directories to be included with -isystem
-	Flags             []string      // Exported raw flags.
+	IncludeDirs       *android.DepSet // Include directories to be included with -I
+	SystemIncludeDirs *android.DepSet // System include directories to be included with -isystem
+	Flags             []string        // Exported raw flags.
 	Deps              android.Paths
 	GeneratedHeaders  android.Paths
 }
@@ -416,8 +423,8 @@ func flagExporterInfoFromCcInfo(ctx android.ModuleContext, ccInfo cquery.CcInfo)
 	headers := android.PathsForBazelOut(ctx, ccInfo.Headers)
 
 	return FlagExporterInfo{
-		IncludeDirs:       android.FirstUniquePaths(includes),
-		SystemIncludeDirs: android.FirstUniquePaths(systemIncludes),
+		IncludeDirs:       android.NewDepSet(android.PREORDER, includes, nil),
+		SystemIncludeDirs: android.NewDepSet(android.PREORDER, systemIncludes, nil),
 		GeneratedHeaders:  headers,
 		// necessary to ensure generated headers are considered implicit deps of dependent actions
 		Deps: headers,
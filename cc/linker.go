@@ -15,8 +15,10 @@
 package cc
 
 import (
+	"encoding/hex"
 	"fmt"
 	"path/filepath"
+	"strings"
 
 	"android/soong/android"
 	"android/soong/cc/config"
@@ -132,6 +134,12 @@ type BaseLinkerProperties struct {
 			// vendor or product variant of the C/C++ module.
 			Exclude_runtime_libs []string
 
+			// list of system libraries that will be dynamically linked to the vendor or
+			// product variant of the C/C++ module.  Overrides system_shared_libs when set;
+			// set to [] to prevent the variant linking against the defaults.  Every entry
+			// must be available to the vendor or product image, e.g. an LLNDK or VNDK library.
+			System_shared_libs []string
+
 			// version script for vendor or product variant
 			Version_script *string `android:"arch_variant"`
 		} `android:"arch_variant"`
@@ -222,6 +230,25 @@ type BaseLinkerProperties struct {
 	// Generate compact dynamic relocation table, default true.
 	Pack_relocations *bool `android:"arch_variant"`
 
+	// The max page size the ELF segments of the module are aligned to, either "4096" or "16384".
+	// Defaults to the max page size supported by the device.  The alignment of the built shared
+	// library or binary is checked against it.
+	Max_page_size *string `android:"arch_variant"`
+
+	// The GNU build-id of the shared library or binary, either "none" to omit it, "md5", "sha1" or
+	// "uuid" to select how it is generated, or a fixed id written as "0x" followed by an even
+	// number of hex digits.  Defaults to the DefaultBuildIdStyle of the product, or to the
+	// build-id flag of the toolchain, which is "md5" for the device, linux_bionic and the arm64
+	// linux host, while the other hosts pass no build-id flag.  The stripped output is stripped
+	// from the linked one without --remove-build-id, so both carry the same id.
+	// Ignored on darwin and windows.
+	Build_id *string `android:"arch_variant"`
+
+	// Derive the order of the hot symbols of the module from its afdo or pgo profile and pass it to
+	// the linker as --symbol-ordering-file, to lay them out together for faster startup.  Ignored
+	// if no profile is available for the module.
+	Generate_symbol_ordering_from_profile *bool `android:"arch_variant"`
+
 	// local file name to pass to the linker as --version-script
 	Version_script *string `android:"path,arch_variant"`
 
@@ -262,6 +289,80 @@ type baseLinker struct {
 	sanitize *sanitize
 }
 
+var validMaxPageSizes = []string{"4096", "16384"}
+
+// maxPageSize returns the max page size the ELF segments of the module are aligned to, or "" if it
+// is not known because the toolchain of the module doesn't set it.
+func (linker *baseLinker) maxPageSize(ctx android.BaseModuleContext) string {
+	if maxPageSize := linker.Properties.Max_page_size; maxPageSize != nil {
+		if !android.InList(*maxPageSize, validMaxPageSizes) {
+			ctx.PropertyErrorf("max_page_size", "must be one of %q, got %q", validMaxPageSizes, *maxPageSize)
+			return ""
+		}
+		return *maxPageSize
+	}
+	// Only the arm and arm64 toolchains link with the max page size supported by the device.
+	switch ctx.Arch().ArchType {
+	case android.Arm, android.Arm64:
+		return ctx.Config().MaxPageSizeSupported()
+	}
+	return ""
+}
+
+// maxPageSizeValidations returns the validations that check the alignment of the load segments
+// of the shared library or binary in against the max page size of the module.
+func (linker *baseLinker) maxPageSizeValidations(ctx ModuleContext, in android.Path) android.Paths {
+	if !ctx.Device() {
+		return nil
+	}
+	maxPageSize := linker.maxPageSize(ctx)
+	if maxPageSize == "" {
+		return nil
+	}
+	timestamp := android.PathForModuleOut(ctx, "check_max_page_size", in.Base()+".timestamp")
+	transformBinaryToMaxPageSizeCheck(ctx, in, maxPageSize, timestamp)
+	return android.Paths{timestamp}
+}
+
+var validBuildIdStyles = []string{"none", "md5", "sha1", "uuid"}
+
+// validateBuildId returns an error if buildId is not a valid value of the build_id property.
+func validateBuildId(buildId string) error {
+	if android.InList(buildId, validBuildIdStyles) {
+		return nil
+	}
+	if digits := strings.TrimPrefix(buildId, "0x"); digits != buildId {
+		if _, err := hex.DecodeString(digits); err != nil || digits == "" {
+			return fmt.Errorf("must be 0x followed by an even number of hex digits, got %q", buildId)
+		}
+		return nil
+	}
+	return fmt.Errorf("must be one of %q or a 0x prefixed hex id, got %q", validBuildIdStyles, buildId)
+}
+
+// buildIdFlag returns the linker flag that selects the build-id of the module, or "" to keep the
+// build-id of the toolchain.
+func (linker *baseLinker) buildIdFlag(ctx ModuleContext) string {
+	if ctx.Darwin() || ctx.Windows() {
+		return ""
+	}
+	if buildId := linker.Properties.Build_id; buildId != nil {
+		if err := validateBuildId(*buildId); err != nil {
+			ctx.PropertyErrorf("build_id", "%s", err)
+			return ""
+		}
+		return "-Wl,--build-id=" + *buildId
+	}
+	if buildId := ctx.Config().DefaultBuildIdStyle(); buildId != "" {
+		if err := validateBuildId(buildId); err != nil {
+			ctx.ModuleErrorf("invalid DefaultBuildIdStyle product variable: %s", err)
+			return ""
+		}
+		return "-Wl,--build-id=" + buildId
+	}
+	return ""
+}
+
 func (linker *baseLinker) appendLdflags(flags []string) {
 	linker.Properties.Ldflags = append(linker.Properties.Ldflags, flags...)
 }
@@ -369,6 +470,11 @@ func (linker *baseLinker) linkerDeps(ctx DepsContext, deps Deps) Deps {
 	}
 
 	deps.SystemSharedLibs = linker.Properties.System_shared_libs
+	if ctx.inVendor() && linker.Properties.Target.Vendor.System_shared_libs != nil {
+		deps.SystemSharedLibs = linker.Properties.Target.Vendor.System_shared_libs
+	} else if ctx.inProduct() && linker.Properties.Target.Product.System_shared_libs != nil {
+		deps.SystemSharedLibs = linker.Properties.Target.Product.System_shared_libs
+	}
 	if deps.SystemSharedLibs == nil {
 		// Provide a default system_shared_libs if it is unspecified. Note: If an
 		// empty list [] is specified, it implies that the module declines the
@@ -505,6 +611,28 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 		}
 	}
 
+	// Invalid values are reported by maxPageSize when the alignment is checked.
+	if maxPageSize := linker.Properties.Max_page_size; maxPageSize != nil && ctx.Device() &&
+		android.InList(*maxPageSize, validMaxPageSizes) {
+		flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,-z,max-page-size="+*maxPageSize)
+	}
+
+	// The flag overrides the build-id style in the global ldflags of the toolchain.
+	if buildIdFlag := linker.buildIdFlag(ctx); buildIdFlag != "" {
+		flags.Local.LdFlags = append(flags.Local.LdFlags, buildIdFlag)
+	}
+
+	if Bool(linker.Properties.Generate_symbol_ordering_from_profile) && linker.useClangLld(ctx) {
+		if profile, profileKind := symbolOrderingProfile(ctx); profile != nil {
+			symbolOrderingFile := android.PathForModuleOut(ctx, "symbol_ordering.txt")
+			transformProfileToSymbolOrderingFile(ctx, profile, profileKind, symbolOrderingFile)
+			flags.Local.LdFlags = append(flags.Local.LdFlags,
+				"-Wl,--symbol-ordering-file,"+symbolOrderingFile.String(),
+				"-Wl,--no-warn-symbol-ordering")
+			flags.LdFlagsDeps = append(flags.LdFlagsDeps, symbolOrderingFile)
+		}
+	}
+
 	CheckBadLinkerFlags(ctx, "ldflags", linker.Properties.Ldflags)
 
 	flags.Local.LdFlags = append(flags.Local.LdFlags, proptools.NinjaAndShellEscapeList(linker.Properties.Ldflags)...)
@@ -525,17 +653,15 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	// Version_script is not needed when linking stubs lib where the version
 	// script is created from the symbol map file.
 	if !linker.dynamicProperties.BuildStubs {
-		versionScript := ctx.ExpandOptionalSource(
-			linker.Properties.Version_script, "version_script")
+		versionScript := android.OptionalPathForModuleSrcOrModule(ctx,
+			linker.Properties.Version_script)
 
 		if ctx.inVendor() && linker.Properties.Target.Vendor.Version_script != nil {
-			versionScript = ctx.ExpandOptionalSource(
-				linker.Properties.Target.Vendor.Version_script,
-				"target.vendor.version_script")
+			versionScript = android.OptionalPathForModuleSrcOrModule(ctx,
+				linker.Properties.Target.Vendor.Version_script)
 		} else if ctx.inProduct() && linker.Properties.Target.Product.Version_script != nil {
-			versionScript = ctx.ExpandOptionalSource(
-				linker.Properties.Target.Product.Version_script,
-				"target.product.version_script")
+			versionScript = android.OptionalPathForModuleSrcOrModule(ctx,
+				linker.Properties.Target.Product.Version_script)
 		}
 
 		if versionScript.Valid() {
@@ -581,6 +707,27 @@ func (linker *baseLinker) linkerFlags(ctx ModuleContext, flags Flags) Flags {
 	return flags
 }
 
+// symbolOrderingProfile returns the afdo or pgo profile of the module to derive the order of its
+// hot symbols from and the llvm-profdata flag for the kind of the profile, or nil if the module has
+// no profile.
+func symbolOrderingProfile(ctx ModuleContext) (android.Path, string) {
+	c, ok := ctx.Module().(*Module)
+	if !ok {
+		return nil, ""
+	}
+	if c.afdo != nil {
+		if path := c.afdo.Properties.FdoProfilePath; path != nil {
+			return android.PathForSource(ctx, *path), "--sample"
+		}
+	}
+	if c.pgo != nil && c.pgo.Properties.PgoCompile {
+		if profile := c.pgo.Properties.getPgoProfileFile(ctx); profile.Valid() {
+			return profile.Path(), "--instr"
+		}
+	}
+	return nil, ""
+}
+
 // RpathFlags returns the rpath linker flags for current target to search the following directories relative
 // to the binary:
 //
@@ -14,6 +14,12 @@
 
 package cc
 
+import (
+	"path/filepath"
+
+	"android/soong/android"
+)
+
 var (
 	llndkLibrarySuffix = ".llndk"
 	llndkHeadersSuffix = ".llndk"
@@ -55,3 +61,67 @@ type llndkLibraryProperties struct {
 	// llndk.symbol_file.
 	Llndk_headers *bool
 }
+
+// llndkConsistencyValidations checks the llndk properties of the implementation variant of an LLNDK
+// library against the library, so that drift between them is caught without building the
+// vendor variants.  The returned validations of the linked library in check that the include
+// directories exported to the LLNDK variant exist, and that each symbol listed in
+// llndk.symbol_file is both defined by the implementation and declared by one of the headers in
+// those directories.  All the problems of a library are reported together in a single error.
+func (library *libraryDecorator) llndkConsistencyValidations(ctx ModuleContext, in android.Path) android.Paths {
+	if !ctx.Module().(*Module).HasLlndkStubs() || ctx.IsLlndk() || ctx.useVndk() || !ctx.Device() ||
+		ctx.inRamdisk() || ctx.inVendorRamdisk() || ctx.inRecovery() || ctx.isSdkVariant() ||
+		!ctx.isForPlatform() || library.buildStubs() {
+		return nil
+	}
+	// Without a VNDK version there are no LLNDK variants for the implementation to drift from.
+	if ctx.DeviceConfig().VndkVersion() == "" {
+		return nil
+	}
+
+	// The include directories of the LLNDK variant, as computed by link for the LLNDK variant.
+	llndkDirs := library.flagExporter.Properties.Export_include_dirs
+	if override := library.Properties.Llndk.Override_export_include_dirs; override != nil {
+		llndkDirs = override
+	}
+	llndkDirs = append(append(android.CopyOf(llndkDirs),
+		library.flagExporter.Properties.Export_system_include_dirs...),
+		library.Properties.Llndk.Export_preprocessed_headers...)
+
+	// Missing directories are passed on to the check so that they are reported together with the
+	// symbol problems of the library.
+	var includeDirs []string
+	var headers android.Paths
+	for _, dir := range android.FirstUniqueStrings(llndkDirs) {
+		includeDirs = append(includeDirs, android.MaybeExistentPathForSource(ctx, ctx.ModuleDir(), dir).String())
+		if path := android.ExistentPathForSource(ctx, ctx.ModuleDir(), dir); path.Valid() {
+			headers = append(headers, ctx.GlobFiles(filepath.Join(path.String(), "**/*"), nil)...)
+		}
+	}
+
+	// Generate the list of the symbols of the LLNDK variant the same way the LLNDK variant does.
+	symbolFile := android.PathForModuleSrc(ctx, String(library.Properties.Llndk.Symbol_file))
+	symbolList := android.PathForModuleGen(ctx, "llndk_check", "abi_symbol_list.txt")
+	apiLevelsJson := android.GetApiLevelsJson(ctx)
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        genStubSrc,
+		Description: "generate llndk symbol list " + symbolFile.Rel(),
+		Outputs: []android.WritablePath{
+			android.PathForModuleGen(ctx, "llndk_check", "stub.c"),
+			android.PathForModuleGen(ctx, "llndk_check", "stub.map"),
+			symbolList,
+		},
+		Input:     symbolFile,
+		Implicits: []android.Path{apiLevelsJson},
+		Args: map[string]string{
+			"arch":     ctx.Arch().ArchType.String(),
+			"apiLevel": android.FutureApiLevel.String(),
+			"apiMap":   apiLevelsJson.String(),
+			"flags":    "--llndk",
+		},
+	})
+
+	timestamp := android.PathForModuleOut(ctx, "check_llndk_symbols", in.Base()+".timestamp")
+	transformSharedLibraryToLlndkSymbolsCheck(ctx, in, symbolList, includeDirs, headers, timestamp)
+	return android.Paths{timestamp}
+}
@@ -143,7 +143,7 @@ func (m *headerModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	if len(m.installPaths) == 0 {
-		ctx.ModuleErrorf("srcs %q matched zero files", m.properties.Srcs)
+		ctx.PropertyErrorf("srcs", "%q matched zero files", m.properties.Srcs)
 	}
 }
 
@@ -258,7 +258,7 @@ func (m *versionedHeaderModule) GenerateAndroidBuildActions(ctx android.ModuleCo
 	}
 
 	if len(m.installPaths) == 0 {
-		ctx.ModuleErrorf("glob %q matched zero files", String(m.properties.From))
+		ctx.PropertyErrorf("from", "glob %q matched zero files", String(m.properties.From))
 	}
 
 	processHeadersWithVersioner(ctx, fromSrcPath, toOutputPath, srcFiles, installPaths)
@@ -393,7 +393,7 @@ func (m *preprocessedHeadersModule) GenerateAndroidBuildActions(ctx android.Modu
 	}
 
 	if len(m.installPaths) == 0 {
-		ctx.ModuleErrorf("srcs %q matched zero files", m.properties.Srcs)
+		ctx.PropertyErrorf("srcs", "%q matched zero files", m.properties.Srcs)
 	}
 }
 
@@ -16,6 +16,7 @@ package cc
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"android/soong/android"
@@ -170,3 +171,64 @@ func TestCcObjectOutputFile(t *testing.T) {
 	}
 
 }
+
+func TestCcObjectPartialLinkRspFile(t *testing.T) {
+	var srcs []string
+	for i := 0; i < 2000; i++ {
+		srcs = append(srcs, fmt.Sprintf(`"a_source_file_with_a_long_name_%04d.c"`, i))
+	}
+	bp := fmt.Sprintf(`
+		cc_object {
+			name: "few",
+			host_supported: true,
+			srcs: ["bar.c", "baz.c"],
+		}
+
+		cc_object {
+			name: "many",
+			host_supported: true,
+			srcs: [%s],
+		}
+	`, strings.Join(srcs, ", "))
+
+	ctx := PrepareForIntegrationTestWithCc.RunTestWithBp(t, bp)
+
+	for _, variant := range []string{"android_arm64_armv8-a", "linux_glibc_x86_64"} {
+		t.Run(variant, func(t *testing.T) {
+			// A short list of objects is passed on the command line.
+			few := ctx.ModuleForTests("few", variant)
+			android.AssertPathRelativeToTopEquals(t, "few output",
+				"out/soong/.intermediates/few/"+variant+"/few.o", few.Rule("partialLd").Output)
+			android.AssertBoolEquals(t, "few uses a rsp file", false, few.MaybeRule("partialLdRsp").Rule != nil)
+
+			// A long list of objects is passed in a .rsp file.
+			many := ctx.ModuleForTests("many", variant).Rule("partialLdRsp")
+			android.AssertIntEquals(t, "many inputs", 2000, len(many.Inputs))
+			android.AssertStringEquals(t, "many objListFlag",
+				"@out/soong/.intermediates/many/"+variant+"/many.o.rsp",
+				android.StringRelativeToTop(ctx.Config, many.Args["objListFlag"]))
+		})
+	}
+
+	t.Run("darwin", func(t *testing.T) {
+		ctx := android.GroupFixturePreparers(
+			PrepareForIntegrationTestWithCc,
+			android.FixtureModifyConfig(func(config android.Config) {
+				delete(config.Targets, config.BuildOS)
+				config.BuildOS = android.Darwin
+				config.Targets[android.Darwin] = []android.Target{
+					{Os: android.Darwin, Arch: android.Arch{ArchType: android.X86_64}, NativeBridge: android.NativeBridgeDisabled},
+				}
+				config.BuildOSTarget = config.Targets[android.Darwin][0]
+				config.BuildOSCommonTarget = android.Target{Os: android.Darwin, Arch: android.Arch{ArchType: android.Common}}
+			}),
+		).RunTestWithBp(t, bp)
+
+		// The Darwin linker reads the list of objects with -filelist.
+		many := ctx.ModuleForTests("many", "darwin_x86_64").Rule("partialLdRsp")
+		android.AssertIntEquals(t, "many inputs", 2000, len(many.Inputs))
+		android.AssertStringEquals(t, "many objListFlag",
+			"-Wl,-filelist,out/soong/.intermediates/many/darwin_x86_64/many.o.rsp",
+			android.StringRelativeToTop(ctx.Config, many.Args["objListFlag"]))
+	})
+}
@@ -179,12 +179,12 @@ func (props *PgoProperties) isPGO(ctx BaseModuleContext) bool {
 			missing = append(missing, "profile_file property")
 		}
 		missingProps := strings.Join(missing, ", ")
-		ctx.ModuleErrorf("PGO specification is missing properties: " + missingProps)
+		ctx.PropertyErrorf("pgo", "PGO specification is missing properties: %s", missingProps)
 	}
 
 	// Benchmark property is mandatory for instrumentation PGO.
 	if isInstrumentation && !benchmarksPresent {
-		ctx.ModuleErrorf("Instrumentation PGO specification is missing benchmark property")
+		ctx.PropertyErrorf("pgo", "Instrumentation PGO specification is missing benchmark property")
 	}
 
 	return true
@@ -17,13 +17,29 @@ package cc
 import (
 	"path/filepath"
 
+	"github.com/google/blueprint"
+
 	"android/soong/android"
 	"android/soong/bazel"
 	"android/soong/bazel/cquery"
 )
 
+var (
+	// A rule for comparing the exported symbols of a prebuilt shared library with the source
+	// library it replaces.
+	checkPrebuiltAbi = pctx.AndroidStaticRule("checkPrebuiltAbi",
+		blueprint.RuleParams{
+			Command: "rm -f $out && $checkPrebuiltAbiCmd --name $name --source-toc $sourceToc " +
+				"$prebuiltFlags && touch $out",
+			CommandDeps: []string{"$checkPrebuiltAbiCmd"},
+		},
+		"name", "sourceToc", "prebuiltFlags")
+)
+
 func init() {
 	RegisterPrebuiltBuildComponents(android.InitRegistrationContext)
+
+	pctx.HostBinToolVariable("checkPrebuiltAbiCmd", "check_prebuilt_abi")
 }
 
 func RegisterPrebuiltBuildComponents(ctx android.RegistrationContext) {
@@ -86,9 +102,17 @@ type prebuiltLibraryInterface interface {
 	disablePrebuilt()
 }
 
+type prebuiltLibraryProperties struct {
+	// If true and the source module this prebuilt shared library replaces exists in the tree,
+	// verify that the prebuilt exports the same symbols as the library built from source.
+	Check_abi_against_source *bool
+}
+
 type prebuiltLibraryLinker struct {
 	*libraryDecorator
 	prebuiltLinker
+
+	libraryProperties prebuiltLibraryProperties
 }
 
 var _ prebuiltLinkerInterface = (*prebuiltLibraryLinker)(nil)
@@ -112,8 +136,8 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 	flags Flags, deps PathDeps, objs Objects) android.Path {
 
 	p.libraryDecorator.flagExporter.exportIncludes(ctx)
-	p.libraryDecorator.flagExporter.reexportDirs(deps.ReexportedDirs...)
-	p.libraryDecorator.flagExporter.reexportSystemDirs(deps.ReexportedSystemDirs...)
+	p.libraryDecorator.flagExporter.reexportTransitiveDirs(deps.ReexportedDirs)
+	p.libraryDecorator.flagExporter.reexportTransitiveSystemDirs(deps.ReexportedSystemDirs)
 	p.libraryDecorator.flagExporter.reexportFlags(deps.ReexportedFlags...)
 	p.libraryDecorator.flagExporter.reexportDeps(deps.ReexportedDeps...)
 	p.libraryDecorator.flagExporter.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
@@ -188,12 +212,18 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 				})
 			}
 
+			var validations android.Paths
+			if ctx.Config().CheckPrebuiltMaxPageSize() {
+				validations = p.maxPageSizeValidations(ctx, in)
+			}
+
 			ctx.Build(pctx, android.BuildParams{
 				Rule:        android.Cp,
 				Description: "prebuilt shared library",
 				Implicits:   implicits,
 				Input:       in,
 				Output:      outputFile,
+				Validations: validations,
 				Args: map[string]string{
 					"cpFlags": "-L",
 				},
@@ -236,6 +266,56 @@ func (p *prebuiltLibraryLinker) link(ctx ModuleContext,
 	return nil
 }
 
+// checkReplacingPrebuiltAbi is called by a shared library built from source to verify that a
+// prebuilt replacing it with check_abi_against_source set exports the same symbols.  It is run
+// from the source module because only the source module has a dependency on the prebuilt, and
+// returns the validations to attach to the source library's link.  If the prebuilt has no table
+// of contents its symbols are read from the library with llvm-nm.
+func checkReplacingPrebuiltAbi(ctx ModuleContext, sourceToc android.Path) android.Paths {
+	var validations android.Paths
+	ctx.VisitDirectDepsWithTag(android.PrebuiltDepTag, func(dep android.Module) {
+		ccDep, ok := dep.(*Module)
+		if !ok {
+			return
+		}
+		prebuilt, ok := ccDep.linker.(*prebuiltLibraryLinker)
+		if !ok || !prebuilt.shared() || !Bool(prebuilt.libraryProperties.Check_abi_against_source) {
+			return
+		}
+		if !ctx.OtherModuleHasProvider(dep, SharedLibraryInfoProvider) {
+			return
+		}
+		sharedLibraryInfo := ctx.OtherModuleProvider(dep, SharedLibraryInfoProvider).(SharedLibraryInfo)
+
+		var prebuiltFile android.Path
+		var prebuiltFlags string
+		if sharedLibraryInfo.TableOfContents.Valid() {
+			prebuiltFile = sharedLibraryInfo.TableOfContents.Path()
+			prebuiltFlags = "--prebuilt-toc " + prebuiltFile.String()
+		} else if sharedLibraryInfo.SharedLibrary != nil {
+			prebuiltFile = sharedLibraryInfo.SharedLibrary
+			prebuiltFlags = "--nm ${config.ClangBin}/llvm-nm --prebuilt-lib " + prebuiltFile.String()
+		} else {
+			return
+		}
+
+		timestamp := android.PathForModuleOut(ctx, "check_prebuilt_abi.timestamp")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        checkPrebuiltAbi,
+			Description: "check prebuilt abi " + ctx.ModuleName(),
+			Inputs:      android.Paths{sourceToc, prebuiltFile},
+			Output:      timestamp,
+			Args: map[string]string{
+				"name":          ctx.OtherModuleName(dep),
+				"sourceToc":     sourceToc.String(),
+				"prebuiltFlags": prebuiltFlags,
+			},
+		})
+		validations = append(validations, timestamp)
+	})
+	return validations
+}
+
 func (p *prebuiltLibraryLinker) prebuiltSrcs(ctx android.BaseModuleContext) []string {
 	sanitize := ctx.Module().(*Module).sanitize
 	srcs := p.properties.Srcs
@@ -281,7 +361,7 @@ func NewPrebuiltLibrary(hod android.HostOrDeviceSupported, srcsProperty string)
 	module.linker = prebuilt
 	module.library = prebuilt
 
-	module.AddProperties(&prebuilt.properties)
+	module.AddProperties(&prebuilt.properties, &prebuilt.libraryProperties)
 
 	if srcsProperty == "" {
 		android.InitPrebuiltModuleWithoutSrcs(module)
@@ -721,12 +801,18 @@ func (p *prebuiltBinaryLinker) link(ctx ModuleContext,
 				in = stripped
 			}
 
+			var validations android.Paths
+			if ctx.Config().CheckPrebuiltMaxPageSize() {
+				validations = p.maxPageSizeValidations(ctx, in)
+			}
+
 			// Copy binaries to a name matching the final installed name
 			ctx.Build(pctx, android.BuildParams{
 				Rule:        android.CpExecutable,
 				Description: "prebuilt",
 				Output:      outputFile,
 				Input:       in,
+				Validations: validations,
 			})
 		}
 
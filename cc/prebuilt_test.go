@@ -15,6 +15,7 @@
 package cc
 
 import (
+	"fmt"
 	"runtime"
 	"testing"
 
@@ -22,6 +23,7 @@ import (
 	"android/soong/bazel/cquery"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 var prepareForPrebuiltTest = android.GroupFixturePreparers(
@@ -193,6 +195,66 @@ func TestPrebuiltLibraryShared(t *testing.T) {
 	assertString(t, shared.OutputFile().Path().Base(), "libtest.so")
 }
 
+func TestPrebuiltLibrarySharedCheckAbiAgainstSource(t *testing.T) {
+	bp := `
+		cc_library_shared {
+			name: "libchecked",
+		}
+
+		cc_prebuilt_library_shared {
+			name: "libchecked",
+			srcs: ["libchecked.so"],
+			check_abi_against_source: true,
+		}
+
+		cc_library_shared {
+			name: "libunchecked",
+		}
+
+		cc_prebuilt_library_shared {
+			name: "libunchecked",
+			srcs: ["libunchecked.so"],
+		}
+
+		cc_prebuilt_library_shared {
+			name: "libprebuiltonly",
+			srcs: ["libprebuiltonly.so"],
+			check_abi_against_source: true,
+		}
+	`
+	ctx := testPrebuilt(t, bp, map[string][]byte{
+		"libchecked.so":      nil,
+		"libunchecked.so":    nil,
+		"libprebuiltonly.so": nil,
+	})
+
+	const variant = "android_arm64_armv8-a_shared"
+
+	checked := ctx.ModuleForTests("libchecked", variant)
+	check := checked.Rule("checkPrebuiltAbi")
+	android.AssertStringEquals(t, "name", "prebuilt_libchecked", check.Args["name"])
+	android.AssertStringPathRelativeToTopEquals(t, "source toc", ctx.Config(),
+		"out/soong/.intermediates/libchecked/android_arm64_armv8-a_shared/libchecked.so.toc",
+		check.Args["sourceToc"])
+	android.AssertStringEquals(t, "prebuilt flags",
+		"--prebuilt-toc out/soong/.intermediates/prebuilt_libchecked/android_arm64_armv8-a_shared/libchecked.so.toc",
+		android.StringRelativeToTop(ctx.Config(), check.Args["prebuiltFlags"]))
+
+	// The check is a validation of the source library's link rather than a checkbuild target.
+	link := checked.Rule("ld")
+	android.AssertStringListContains(t, "link validations",
+		android.PathsRelativeToTop(link.Validations), android.PathRelativeToTop(check.Output))
+
+	unchecked := ctx.ModuleForTests("libunchecked", variant).MaybeRule("checkPrebuiltAbi")
+	android.AssertBoolEquals(t, "libunchecked has abi check", false, unchecked.Rule != nil)
+
+	// Without a source module there is nothing to compare against, and the check is skipped.
+	for _, m := range ctx.ModuleVariantsForTests("libprebuiltonly") {
+		prebuiltOnly := ctx.ModuleForTests("libprebuiltonly", m).MaybeRule("checkPrebuiltAbi")
+		android.AssertBoolEquals(t, "libprebuiltonly has abi check", false, prebuiltOnly.Rule != nil)
+	}
+}
+
 func TestPrebuiltLibraryStatic(t *testing.T) {
 	ctx := testPrebuilt(t, `
 	cc_prebuilt_library_static {
@@ -818,3 +880,51 @@ cc_prebuilt_binary {
 	}
 	android.AssertStringEquals(t, "output file", expectedOut, out.String())
 }
+
+func TestPrebuiltMaxPageSize(t *testing.T) {
+	const bp = `
+		cc_prebuilt_library_shared {
+			name: "liba",
+			srcs: ["liba.so"],
+			max_page_size: "16384",
+		}
+
+		cc_prebuilt_binary {
+			name: "bin",
+			srcs: ["bin"],
+		}
+	`
+	fs := map[string][]byte{
+		"liba.so": nil,
+		"bin":     nil,
+	}
+
+	for _, check := range []bool{false, true} {
+		t.Run(fmt.Sprintf("check_%t", check), func(t *testing.T) {
+			ctx := testPrebuilt(t, bp, fs, android.FixtureModifyProductVariables(
+				func(variables android.FixtureProductVariables) {
+					variables.DeviceMaxPageSizeSupported = StringPtr("4096")
+					variables.CheckPrebuiltMaxPageSize = proptools.BoolPtr(check)
+				}))
+
+			liba := ctx.ModuleForTests("liba", "android_arm64_armv8-a_shared")
+			bin := ctx.ModuleForTests("bin", "android_arm64_armv8-a")
+			libaCheck := liba.MaybeOutput("check_max_page_size/liba.so.timestamp")
+			binCheck := bin.MaybeOutput("check_max_page_size/bin.timestamp")
+
+			if !check {
+				android.AssertBoolEquals(t, "liba checked", false, libaCheck.Rule != nil)
+				android.AssertBoolEquals(t, "bin checked", false, binCheck.Rule != nil)
+				return
+			}
+
+			android.AssertStringEquals(t, "liba max page size", "16384", libaCheck.Args["maxPageSize"])
+			android.AssertPathsRelativeToTopEquals(t, "liba validations",
+				[]string{android.PathRelativeToTop(libaCheck.Output)}, liba.Output("liba.so").Validations)
+
+			android.AssertStringEquals(t, "bin max page size", "4096", binCheck.Args["maxPageSize"])
+			android.AssertPathsRelativeToTopEquals(t, "bin validations",
+				[]string{android.PathRelativeToTop(binCheck.Output)}, bin.Output("bin").Validations)
+		})
+	}
+}
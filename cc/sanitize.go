@@ -25,6 +25,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/cc/config"
+	"android/soong/fuzz"
 	"android/soong/snapshot"
 )
 
@@ -84,6 +85,9 @@ var (
 		"export_memory_stats=0", "max_malloc_fill_size=131072", "malloc_fill_byte=0"}
 	memtagStackCommonFlags = []string{"-march=armv8-a+memtag", "-mllvm", "-dom-tree-reachability-max-bbs-to-explore=128"}
 
+	// Replace -fsanitize=fuzzer-no-link for fuzz targets when FUZZ_COVERAGE_BUILD is set.
+	fuzzCoverageCflags = []string{"-fprofile-instr-generate", "-fcoverage-mapping"}
+
 	hostOnlySanitizeFlags   = []string{"-fno-sanitize-recover=all"}
 	deviceOnlySanitizeFlags = []string{"-fsanitize-trap=all", "-ftrap-function=abort"}
 )
@@ -297,6 +301,11 @@ type SanitizeUserProps struct {
 	Config struct {
 		// Enables CFI support flags for assembly-heavy libraries
 		Cfi_assembly_support *bool `android:"arch_variant"`
+
+		// List of C and C++ source files to compile without CFI when cfi is enabled, for example
+		// because they contain inline assembly.  Assembly sources are always compiled without CFI,
+		// the module is still linked with CFI.
+		Cfi_exclude_srcs []string `android:"path,arch_variant"`
 	} `android:"arch_variant"`
 
 	// List of sanitizers to pass to -fsanitize-recover
@@ -808,7 +817,13 @@ func (s *sanitize) flags(ctx ModuleContext, flags Flags) Flags {
 	}
 
 	if Bool(sanProps.Fuzzer) {
-		flags.Local.CFlags = append(flags.Local.CFlags, "-fsanitize=fuzzer-no-link")
+		if fuzz.IsCoverageBuild(ctx.Config()) {
+			// Fuzz corpus distillation measures the coverage of the corpus instead of fuzzing it.
+			flags.Local.CFlags = append(flags.Local.CFlags, fuzzCoverageCflags...)
+			flags.Local.LdFlags = append(flags.Local.LdFlags, "-fprofile-instr-generate")
+		} else {
+			flags.Local.CFlags = append(flags.Local.CFlags, "-fsanitize=fuzzer-no-link")
+		}
 
 		// TODO(b/131771163): LTO and Fuzzer support is mutually incompatible.
 		_, flags.Local.LdFlags = removeFromList("-flto", flags.Local.LdFlags)
@@ -843,17 +858,20 @@ func (s *sanitize) flags(ctx ModuleContext, flags Flags) Flags {
 			flags.RequiredInstructionSet = "thumb"
 		}
 
-		flags.Local.CFlags = append(flags.Local.CFlags, cfiCflags...)
-		flags.Local.AsFlags = append(flags.Local.AsFlags, cfiAsflags...)
+		flags.cfiCFlags = append(flags.cfiCFlags, cfiCflags...)
+		flags.cfiAsFlags = append(flags.cfiAsFlags, cfiAsflags...)
 		if Bool(s.Properties.Sanitize.Config.Cfi_assembly_support) {
-			flags.Local.CFlags = append(flags.Local.CFlags, cfiAssemblySupportFlag)
+			flags.cfiCFlags = append(flags.cfiCFlags, cfiAssemblySupportFlag)
 		}
 		// Only append the default visibility flag if -fvisibility has not already been set
 		// to hidden.
 		if !inList("-fvisibility=hidden", flags.Local.CFlags) {
-			flags.Local.CFlags = append(flags.Local.CFlags, "-fvisibility=default")
+			flags.cfiCFlags = append(flags.cfiCFlags, "-fvisibility=default")
 		}
+		flags.Local.CFlags = append(flags.Local.CFlags, flags.cfiCFlags...)
+		flags.Local.AsFlags = append(flags.Local.AsFlags, flags.cfiAsFlags...)
 		flags.Local.LdFlags = append(flags.Local.LdFlags, cfiLdflags...)
+		flags.cfiExcludeSrcs = android.PathsForModuleSrc(ctx, s.Properties.Sanitize.Config.Cfi_exclude_srcs)
 
 		if ctx.staticBinary() {
 			_, flags.Local.CFlags = removeFromList("-fsanitize-cfi-cross-dso", flags.Local.CFlags)
@@ -1536,7 +1554,7 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 			sanitizers = append(sanitizers, "memtag-stack")
 		}
 
-		if Bool(sanProps.Fuzzer) {
+		if Bool(sanProps.Fuzzer) && !fuzz.IsCoverageBuild(mctx.Config()) {
 			sanitizers = append(sanitizers, "fuzzer-no-link")
 		}
 
@@ -1598,7 +1616,7 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 				runtimeSharedLibrary = config.ScudoRuntimeLibrary(toolchain)
 			}
 		} else if len(diagSanitizers) > 0 || c.sanitize.Properties.UbsanRuntimeDep ||
-			Bool(sanProps.Fuzzer) ||
+			(Bool(sanProps.Fuzzer) && !fuzz.IsCoverageBuild(mctx.Config())) ||
 			Bool(sanProps.Undefined) ||
 			Bool(sanProps.All_undefined) {
 			if toolchain.Musl() || (c.staticBinary() && toolchain.Bionic()) {
@@ -1246,3 +1246,62 @@ func TestCfi(t *testing.T) {
 		t.Errorf("non-CFI variant of baz not expected to contain CFI flags ")
 	}
 }
+
+func TestCfiExcludeSrcs(t *testing.T) {
+	t.Parallel()
+
+	bp := `
+	cc_library_static {
+		name: "libstatic_cfi",
+		srcs: ["foo.c", "bar.c", "baz.S"],
+		sanitize: {
+			cfi: true,
+			config: {
+				cfi_exclude_srcs: ["bar.c"],
+			},
+		},
+	}
+
+	cc_library_shared {
+		name: "libshared_cfi",
+		srcs: ["foo.c", "baz.S"],
+		sanitize: {
+			cfi: true,
+		},
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.MockFS{
+			"foo.c": nil,
+			"bar.c": nil,
+			"baz.S": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	compileFlags := func(module android.TestingModule, obj string) string {
+		flags := module.Output(obj).Args["cFlags"]
+		// Flags shared by several sources are replaced with a module variable.
+		if strings.HasPrefix(flags, "$") {
+			flags = module.VariablesForTestsRelativeToTop()[strings.TrimPrefix(flags, "$")]
+		}
+		return flags
+	}
+
+	static := result.ModuleForTests("libstatic_cfi", "android_arm64_armv8-a_static_cfi")
+
+	fooFlags := compileFlags(static, "obj/foo.o")
+	android.AssertStringDoesContain(t, "foo.c cflags", fooFlags, "-fsanitize-cfi-cross-dso")
+	android.AssertStringDoesNotContain(t, "foo.c cflags", fooFlags, "-fno-sanitize=cfi")
+
+	barFlags := compileFlags(static, "obj/bar.o")
+	android.AssertStringDoesNotContain(t, "excluded bar.c cflags", barFlags, "-fsanitize-cfi-cross-dso")
+	android.AssertStringDoesContain(t, "excluded bar.c cflags", barFlags, "-fno-sanitize=cfi")
+
+	bazFlags := compileFlags(static, "obj/baz.o")
+	android.AssertStringDoesNotContain(t, "baz.S asflags", bazFlags, "-fvisibility=default")
+
+	shared := result.ModuleForTests("libshared_cfi", "android_arm64_armv8-a_shared_cfi")
+	android.AssertStringDoesNotContain(t, "shared baz.S asflags", compileFlags(shared, "obj/baz.o"), "-fvisibility=default")
+	android.AssertStringDoesContain(t, "shared ldflags", shared.Rule("ld").Args["ldFlags"], "-fsanitize=cfi")
+}
@@ -472,8 +472,8 @@ func (p *snapshotLibraryDecorator) link(ctx ModuleContext, flags Flags, deps Pat
 	p.libraryDecorator.reexportFlags(p.properties.Export_flags...)
 
 	// Flags reexported from dependencies. (e.g. vndk_prebuilt_shared)
-	p.libraryDecorator.reexportDirs(deps.ReexportedDirs...)
-	p.libraryDecorator.reexportSystemDirs(deps.ReexportedSystemDirs...)
+	p.libraryDecorator.reexportTransitiveDirs(deps.ReexportedDirs)
+	p.libraryDecorator.reexportTransitiveSystemDirs(deps.ReexportedSystemDirs)
 	p.libraryDecorator.reexportFlags(deps.ReexportedFlags...)
 	p.libraryDecorator.reexportDeps(deps.ReexportedDeps...)
 	p.libraryDecorator.addExportedGeneratedHeaders(deps.ReexportedGeneratedHeaders...)
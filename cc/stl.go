@@ -16,8 +16,12 @@ package cc
 
 import (
 	"fmt"
+	"path/filepath"
+
+	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	"android/soong/cc/config"
 )
 
 func getNdkStlFamily(m LinkableInterface) string {
@@ -57,6 +61,17 @@ type StlProperties struct {
 	// default.
 	Stl *string `android:"arch_variant"`
 
+	// Hide the symbols of the statically linked STL in a shared library, with a generated
+	// version script that is passed to the linker in addition to the version_script property, so
+	// that the STL of the library does not become part of its ABI.  Requires
+	// stl: "libc++_static".
+	Hide_stl_symbols *bool `android:"arch_variant"`
+
+	// Allow the exported headers of a shared library with hide_stl_symbols: true to include the
+	// headers of STL types.  By default the build fails if they do, as the STL types in the API of
+	// the library would not be compatible with the STL of its users.
+	Allow_stl_in_exported_headers *bool `android:"arch_variant"`
+
 	SelectedStl string `blueprint:"mutated"`
 }
 
@@ -247,5 +262,86 @@ func (stl *stl) flags(ctx ModuleContext, flags Flags) Flags {
 		panic(fmt.Errorf("Unknown stl: %q", stl.Properties.SelectedStl))
 	}
 
+	if Bool(stl.Properties.Hide_stl_symbols) {
+		flags = stl.hideStlSymbols(ctx, flags)
+	}
+
 	return flags
 }
+
+// hiddenStlSymbolsVersionScript localizes the symbols of libc++ and libc++abi.
+const hiddenStlSymbolsVersionScript = `{
+  local:
+    extern "C++" {
+      std::*;
+      __cxxabiv1::*;
+    };
+    __cxa_*;
+    __gxx_personality_v0;
+};
+`
+
+// stlTypeHeadersPattern matches the includes of the headers of the STL types that are commonly
+// used in APIs.
+const stlTypeHeadersPattern = `^[[:space:]]*#[[:space:]]*include[[:space:]]*<(string|string_view|vector|map|set|` +
+	`unordered_map|unordered_set|memory|functional|optional|variant)>`
+
+// hideStlSymbols adds the flags for hide_stl_symbols: true.
+func (stl *stl) hideStlSymbols(ctx ModuleContext, flags Flags) Flags {
+	library, _ := ctx.Module().(*Module).linker.(*libraryDecorator)
+	if library == nil {
+		ctx.PropertyErrorf("hide_stl_symbols", "only supported for shared libraries")
+		return flags
+	} else if !library.shared() {
+		return flags
+	}
+	switch stl.Properties.SelectedStl {
+	case "libc++_static", "ndk_libc++_static":
+	default:
+		ctx.PropertyErrorf("hide_stl_symbols", `requires stl: "libc++_static", found %q`, stl.Properties.SelectedStl)
+		return flags
+	}
+	if ctx.Darwin() || ctx.Windows() {
+		ctx.PropertyErrorf("hide_stl_symbols", "only supported for ELF files")
+		return flags
+	}
+
+	versionScript := android.PathForModuleOut(ctx, "hidden_stl_symbols.map")
+	android.WriteFileRule(ctx, versionScript, hiddenStlSymbolsVersionScript)
+	flags.Local.LdFlags = append(flags.Local.LdFlags, config.VersionScriptFlagPrefix+versionScript.String())
+	flags.LdFlagsDeps = append(flags.LdFlagsDeps, versionScript)
+
+	if !Bool(stl.Properties.Allow_stl_in_exported_headers) {
+		if stamp := checkExportedStlHeaders(ctx, library.flagExporter.exportedIncludes(ctx)); stamp != nil {
+			flags.LdFlagsDeps = append(flags.LdFlagsDeps, stamp)
+		}
+	}
+
+	return flags
+}
+
+// checkExportedStlHeaders returns a stamp file that is built by a rule that fails if the headers in
+// the exported include directories include the headers of STL types, or nil if there are no
+// exported headers.
+func checkExportedStlHeaders(ctx ModuleContext, dirs android.Paths) android.Path {
+	var headers android.Paths
+	for _, dir := range dirs {
+		headers = append(headers, ctx.GlobFiles(filepath.Join(dir.String(), "**/*.h"), nil)...)
+	}
+	if len(headers) == 0 {
+		return nil
+	}
+
+	stamp := android.PathForModuleOut(ctx, "hidden_stl_symbols", "exported_headers.stamp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		Text("if grep -lE").
+		Flag(proptools.ShellEscape(stlTypeHeadersPattern)).
+		Inputs(headers).
+		Textf(`; then echo "error: %s: the exported headers above include STL headers, whose types are not `+
+			`compatible with hide_stl_symbols: true; remove them from the exported headers, or set `+
+			`allow_stl_in_exported_headers: true" >&2; exit 1; fi`, ctx.ModuleName())
+	rule.Command().Text("touch").Output(stamp)
+	rule.Build("check_exported_stl_headers", "check exported headers of "+ctx.ModuleName()+" for STL types")
+	return stamp
+}
@@ -77,6 +77,15 @@ type TestBinaryProperties struct {
 	// global state that can not be torn down and reset between each test suite.
 	Test_per_src *bool
 
+	// list of source files that are each built into a separate test binary, like the srcs of a
+	// test_per_src test.  The sources in srcs are compiled once into a static library that is
+	// linked into every one of these test binaries instead of being recompiled for each of them.
+	Test_per_src_srcs []string `android:"path,arch_variant"`
+
+	// Set by the test_per_src mutator on the variation that compiles the sources in srcs that are
+	// shared by the test_per_src_srcs tests.
+	TestPerSrcCommon bool `blueprint:"mutated"`
+
 	// Disables the creation of a test-specific directory when used with
 	// relative_install_path. Useful if several tests need to be in the same
 	// directory, but test_per_src doesn't work.
@@ -175,19 +184,30 @@ func BenchmarkHostFactory() android.Module {
 type testPerSrc interface {
 	testPerSrc() bool
 	srcs() []string
+	testPerSrcSrcs() []string
 	isAllTestsVariation() bool
+	isCommonSrcsVariation() bool
 	setSrc(string, string)
+	setCommonSrcs()
 	unsetSrc()
 }
 
+// testPerSrcCommonVariation is the name of the variation that compiles the sources shared by the
+// test_per_src_srcs tests of a module.
+const testPerSrcCommonVariation = "test_per_src_common"
+
 func (test *testBinary) testPerSrc() bool {
-	return Bool(test.Properties.Test_per_src)
+	return Bool(test.Properties.Test_per_src) || len(test.Properties.Test_per_src_srcs) > 0
 }
 
 func (test *testBinary) srcs() []string {
 	return test.baseCompiler.Properties.Srcs
 }
 
+func (test *testBinary) testPerSrcSrcs() []string {
+	return test.Properties.Test_per_src_srcs
+}
+
 func (test *testBinary) dataPaths() []android.DataPath {
 	return test.data
 }
@@ -197,11 +217,20 @@ func (test *testBinary) isAllTestsVariation() bool {
 	return stem != nil && *stem == ""
 }
 
+func (test *testBinary) isCommonSrcsVariation() bool {
+	return test.Properties.TestPerSrcCommon
+}
+
 func (test *testBinary) setSrc(name, src string) {
 	test.baseCompiler.Properties.Srcs = []string{src}
 	test.binaryDecorator.Properties.Stem = StringPtr(name)
 }
 
+func (test *testBinary) setCommonSrcs() {
+	test.Properties.TestPerSrcCommon = true
+	test.binaryDecorator.Properties.Stem = StringPtr(testPerSrcCommonVariation)
+}
+
 func (test *testBinary) unsetSrc() {
 	test.baseCompiler.Properties.Srcs = nil
 	test.binaryDecorator.Properties.Stem = StringPtr("")
@@ -216,16 +245,17 @@ var _ testPerSrc = (*testBinary)(nil)
 func TestPerSrcMutator(mctx android.BottomUpMutatorContext) {
 	if m, ok := mctx.Module().(*Module); ok {
 		if test, ok := m.linker.(testPerSrc); ok {
+			if len(test.testPerSrcSrcs()) > 0 {
+				testPerSrcWithCommonSrcs(mctx, test)
+				return
+			}
 			numTests := len(test.srcs())
 			if test.testPerSrc() && numTests > 0 {
 				if duplicate, found := android.CheckDuplicate(test.srcs()); found {
 					mctx.PropertyErrorf("srcs", "found a duplicate entry %q", duplicate)
 					return
 				}
-				testNames := make([]string, numTests)
-				for i, src := range test.srcs() {
-					testNames[i] = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
-				}
+				testNames := testPerSrcNames(test.srcs())
 				// In addition to creating one variation per test source file,
 				// create an additional "all tests" variation named "", and have it
 				// depends on all other test_per_src variations. This is useful to
@@ -252,6 +282,55 @@ func TestPerSrcMutator(mctx android.BottomUpMutatorContext) {
 	}
 }
 
+// testPerSrcWithCommonSrcs splits a test with test_per_src_srcs into one variation per test
+// source file, plus a variation that compiles srcs once into a static library that the test
+// variations link against, and the "all tests" variation described in TestPerSrcMutator.
+func testPerSrcWithCommonSrcs(mctx android.BottomUpMutatorContext, test testPerSrc) {
+	if len(test.srcs()) == 0 {
+		mctx.PropertyErrorf("test_per_src_srcs", "requires srcs to list the sources shared by the tests")
+		return
+	}
+	if duplicate, found := android.CheckDuplicate(test.testPerSrcSrcs()); found {
+		mctx.PropertyErrorf("test_per_src_srcs", "found a duplicate entry %q", duplicate)
+		return
+	}
+	testNames := testPerSrcNames(test.testPerSrcSrcs())
+	if duplicate, found := android.CheckDuplicate(append(testNames, testPerSrcCommonVariation)); found {
+		mctx.PropertyErrorf("test_per_src_srcs", "found a duplicate test name %q", duplicate)
+		return
+	}
+	numTests := len(testNames)
+	tests := mctx.CreateLocalVariations(append(testNames, testPerSrcCommonVariation, "")...)
+
+	common := tests[numTests]
+	common.(*Module).linker.(testPerSrc).setCommonSrcs()
+	// The common variation only produces the static library linked into the tests.
+	common.(*Module).Properties.PreventInstall = true
+	common.(*Module).Properties.HideFromMake = true
+
+	allTests := tests[numTests+1]
+	allTests.(*Module).linker.(testPerSrc).unsetSrc()
+	allTests.(*Module).Properties.PreventInstall = true
+	allTests.(*Module).Properties.HideFromMake = true
+
+	for i, src := range test.testPerSrcSrcs() {
+		tests[i].(*Module).linker.(testPerSrc).setSrc(testNames[i], src)
+		mctx.AddInterVariantDependency(testPerSrcCommonDepTag, tests[i], common)
+		mctx.AddInterVariantDependency(testPerSrcDepTag, allTests, tests[i])
+	}
+	mctx.AliasVariation("")
+}
+
+// testPerSrcNames returns the names of the tests built from each of srcs, which are the base
+// names of the source files without their extension.
+func testPerSrcNames(srcs []string) []string {
+	testNames := make([]string, len(srcs))
+	for i, src := range srcs {
+		testNames[i] = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	return testNames
+}
+
 type testDecorator struct {
 	LinkerProperties    TestLinkerProperties
 	InstallerProperties TestInstallerProperties
@@ -357,7 +436,26 @@ func (test *testBinary) installerProps() []interface{} {
 	return append(test.baseInstaller.installerProps(), test.testDecorator.installerProps()...)
 }
 
+func (test *testBinary) link(ctx ModuleContext,
+	flags Flags, deps PathDeps, objs Objects) android.Path {
+
+	if !test.isCommonSrcsVariation() {
+		return test.binaryDecorator.link(ctx, flags, deps, objs)
+	}
+
+	// Archive the sources shared by the test_per_src_srcs tests, the test variations link the
+	// archive as a whole static library so that the tests it registers are kept.
+	outputFile := android.PathForModuleOut(ctx, ctx.ModuleName()+"_"+testPerSrcCommonVariation+staticLibraryExtension)
+	builderFlags := flagsToBuilderFlags(flags)
+	transformObjToStaticLib(ctx, objs.objFiles, nil, builderFlags, outputFile, nil, objs.tidyDepFiles)
+	return outputFile
+}
+
 func (test *testBinary) install(ctx ModuleContext, file android.Path) {
+	if test.isCommonSrcsVariation() {
+		return
+	}
+
 	dataSrcPaths := android.PathsForModuleSrc(ctx, test.Properties.Data)
 
 	for _, dataSrcPath := range dataSrcPaths {
@@ -393,7 +491,14 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 	testInstallBase := getTestInstallBase(useVendor)
 	configs := getTradefedConfigOptions(ctx, &test.Properties, test.isolated(ctx))
 
+	// Each test_per_src test gets its own test config that runs its binary.
+	var outputFileName string
+	if test.testPerSrc() {
+		outputFileName = file.Base()
+	}
+
 	test.testConfig = tradefed.AutoGenTestConfig(ctx, tradefed.AutoGenTestConfigOptions{
+		OutputFileName:         outputFileName,
 		TestConfigProp:         test.Properties.Test_config,
 		TestConfigTemplateProp: test.Properties.Test_config_template,
 		TestSuites:             test.testDecorator.InstallerProperties.Test_suites,
@@ -419,6 +524,13 @@ func (test *testBinary) install(ctx ModuleContext, file android.Path) {
 		test.Properties.Test_options.Unit_test = proptools.BoolPtr(true)
 	}
 	test.binaryDecorator.baseInstaller.install(ctx, file)
+
+	ctx.SetProvider(tradefed.BaseTestProviderKey, tradefed.BaseTestProviderData{
+		OutputFile:     file,
+		TestConfig:     test.testConfig,
+		Data:           test.data,
+		AndroidMkClass: "NATIVE_TESTS",
+	})
 }
 
 func getTestInstallBase(useVendor bool) string {
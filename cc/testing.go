@@ -17,6 +17,7 @@ package cc
 import (
 	"encoding/json"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	"android/soong/android"
@@ -42,6 +43,7 @@ func RegisterRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("ndk_prebuilt_static_stl", NdkPrebuiltStaticStlFactory)
 	ctx.RegisterModuleType("ndk_library", NdkLibraryFactory)
 	ctx.RegisterModuleType("ndk_headers", ndkHeadersFactory)
+	ctx.RegisterModuleType("kernel_headers", kernelHeadersFactory)
 }
 
 func GatherRequiredDepsForTest(oses ...android.OsType) string {
@@ -795,6 +797,18 @@ func AssertExcludeFromRecoverySnapshotIs(t *testing.T, ctx *android.TestContext,
 	}
 }
 
+// ModulesMissingProfileFileForTests returns the sorted entries of the modules without a profile
+// that are reported to Make in SOONG_MODULES_MISSING_PGO_PROFILE_FILE.
+func ModulesMissingProfileFileForTests(config android.Config) []string {
+	var missing []string
+	getNamedMapForConfig(config, modulesMissingProfileFileKey).Range(func(key, value interface{}) bool {
+		missing = append(missing, key.(string))
+		return true
+	})
+	sort.Strings(missing)
+	return missing
+}
+
 func checkOverrides(t *testing.T, ctx *android.TestContext, singleton android.TestingSingleton, jsonPath string, expected []string) {
 	t.Helper()
 	out := singleton.MaybeOutput(jsonPath)
@@ -38,6 +38,13 @@ type TidyProperties struct {
 
 	// Checks that should be treated as errors.
 	Tidy_checks_as_errors []string
+
+	// A baseline of known clang-tidy findings.  When set, the findings of the checks that are
+	// treated as errors only fail the build if they are not in the baseline.  Findings are
+	// matched by check, file and the content of the source line, so moving a line does not
+	// invalidate its finding.  Build with TIDY_BASELINE_UPDATE=true to write an updated baseline
+	// to the tidy-baseline-update dist goal instead.
+	Tidy_baseline *string `android:"path"`
 }
 
 type tidyFeature struct {
@@ -165,6 +172,12 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 		}
 	}
 	tidyChecks = tidyChecks + config.TidyGlobalNoChecks()
+	// The checks that the product enables as errors for the module directory are enabled even if
+	// the module disables them.
+	pathChecksAsErrors := ctx.Config().TidyChecksAsErrorsForDir(ctx.ModuleDir())
+	if len(pathChecksAsErrors) > 0 {
+		tidyChecks = tidyChecks + "," + strings.Join(esc(ctx, "tidy_checks", pathChecksAsErrors), ",")
+	}
 	if ctx.Windows() {
 		// https://b.corp.google.com/issues/120614316
 		// mingw32 has cert-dcl16-c warning in NO_ERROR,
@@ -191,11 +204,20 @@ func (tidy *tidyFeature) flags(ctx ModuleContext, flags Flags) Flags {
 	// Default clang-tidy flags does not contain -warning-as-errors.
 	// If a module has tidy_checks_as_errors, add the list to -warnings-as-errors
 	// and then append the TidyGlobalNoErrorChecks.
-	if len(tidy.Properties.Tidy_checks_as_errors) > 0 {
-		tidyChecksAsErrors := "-warnings-as-errors=" +
-			strings.Join(esc(ctx, "tidy_checks_as_errors", tidy.Properties.Tidy_checks_as_errors), ",") +
-			config.TidyGlobalNoErrorChecks()
-		flags.TidyFlags = append(flags.TidyFlags, tidyChecksAsErrors)
+	checksAsErrors := append(esc(ctx, "tidy_checks_as_errors", tidy.Properties.Tidy_checks_as_errors),
+		esc(ctx, "tidy_checks", pathChecksAsErrors)...)
+	if len(checksAsErrors) > 0 {
+		if tidy.Properties.Tidy_baseline != nil {
+			// The findings are filtered against the baseline after clang-tidy has run, so
+			// clang-tidy must only report them as warnings.
+			flags.TidyBaseline = android.OptionalPathForPath(
+				android.PathForModuleSrc(ctx, *tidy.Properties.Tidy_baseline))
+			flags.TidyChecksAsErrors = strings.Join(checksAsErrors, ",") + config.TidyGlobalNoErrorChecks()
+		} else {
+			tidyChecksAsErrors := "-warnings-as-errors=" + strings.Join(checksAsErrors, ",") +
+				config.TidyGlobalNoErrorChecks()
+			flags.TidyFlags = append(flags.TidyFlags, tidyChecksAsErrors)
+		}
 	}
 	return flags
 }
@@ -209,7 +231,10 @@ func TidyPhonySingleton() android.Singleton {
 	return &tidyPhonySingleton{}
 }
 
-type tidyPhonySingleton struct{}
+type tidyPhonySingleton struct {
+	// The zip of the updated clang-tidy baselines, with TIDY_BASELINE_UPDATE=true.
+	tidyBaselinesZip android.WritablePath
+}
 
 // Given a final module, add its tidy/obj phony targets to tidy/objModulesInDirGroup.
 func collectTidyObjModuleTargets(ctx android.SingletonContext, module android.Module,
@@ -270,8 +295,54 @@ func (m *tidyPhonySingleton) GenerateBuildActions(ctx android.SingletonContext)
 	}
 	generateObjTidyPhonyTargets(ctx, suffix, "obj", objModulesInDirGroup)
 	generateObjTidyPhonyTargets(ctx, suffix, "tidy", tidyModulesInDirGroup)
+
+	m.generateTidyBaselineUpdates(ctx)
 }
 
+// Merge the updated clang-tidy baselines of all the variants of the modules that share a baseline,
+// and zip them at the paths of the baselines in the source tree, for the tidy-baseline-update dist
+// goal.
+func (m *tidyPhonySingleton) generateTidyBaselineUpdates(ctx android.SingletonContext) {
+	updatesByBaseline := make(map[string]android.Paths)
+	ctx.VisitAllModules(func(module android.Module) {
+		if c, ok := module.(*Module); ok && len(c.tidyBaselineUpdates) > 0 {
+			baseline := c.tidyBaseline.String()
+			updatesByBaseline[baseline] = append(updatesByBaseline[baseline], c.tidyBaselineUpdates...)
+		}
+	})
+	if len(updatesByBaseline) == 0 {
+		return
+	}
+
+	dir := android.PathForOutput(ctx, "tidy_baselines")
+	m.tidyBaselinesZip = android.PathForOutput(ctx, "tidy-baselines.zip")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	var baselines android.Paths
+	for _, baseline := range android.SortedKeys(updatesByBaseline) {
+		merged := dir.Join(ctx, baseline)
+		rule.Command().BuiltTool("tidy_baseline").Text("merge").
+			FlagWithOutput("-o ", merged).
+			Inputs(android.SortedUniquePaths(updatesByBaseline[baseline]))
+		baselines = append(baselines, merged)
+	}
+	rule.Command().BuiltTool("soong_zip").
+		FlagWithOutput("-o ", m.tidyBaselinesZip).
+		FlagWithArg("-C ", dir.String()).
+		FlagForEachInput("-f ", baselines)
+	rule.Build("tidy_baselines_zip", "clang-tidy baselines")
+
+	ctx.Phony("tidy-baseline-update", m.tidyBaselinesZip)
+}
+
+func (m *tidyPhonySingleton) MakeVars(ctx android.MakeVarsContext) {
+	if m.tidyBaselinesZip != nil {
+		ctx.DistForGoal("tidy-baseline-update", m.tidyBaselinesZip)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*tidyPhonySingleton)(nil)
+
 // The name for an obj/tidy module variant group phony target is Name_group-obj/tidy,
 func objTidyModuleGroupName(module android.Module, group string, suffix string) string {
 	if group == "" {
@@ -244,3 +244,103 @@ func TestWithTidy(t *testing.T) {
 		})
 	}
 }
+
+func TestTidyChecksAsErrorsForPath(t *testing.T) {
+	// The checks that the product enables as errors for a path prefix are enabled and treated as
+	// errors in the modules under the prefix.
+	bp := `
+		cc_library_shared {
+			name: "lib%s",
+			srcs: ["foo.c"],
+			tidy_checks: ["-*"],
+			tidy_checks_as_errors: ["local-check"],
+		}`
+	ctx := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureAddTextFile("foo/Android.bp", fmt.Sprintf(bp, "foo")),
+		android.FixtureAddTextFile("foo/bar/Android.bp", fmt.Sprintf(bp, "bar")),
+		android.FixtureAddTextFile("foobar/Android.bp", fmt.Sprintf(bp, "foobar")),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.TidyChecksAsErrorsForPath = map[string][]string{
+				"foo":      {"foo-check"},
+				"foo/bar/": {"bar-*", "foo-check"},
+			}
+		}),
+	).RunTest(t)
+
+	testCases := []struct {
+		libName  string
+		checks   string
+		asErrors string
+	}{
+		{"libfoo", "-checks='-*',${config.TidyGlobalNoChecks},foo-check",
+			"-warnings-as-errors=local-check,foo-check,${config.TidyGlobalNoErrorChecks}"},
+		{"libbar", "-checks='-*',${config.TidyGlobalNoChecks},foo-check,'bar-*'",
+			"-warnings-as-errors=local-check,foo-check,'bar-*',${config.TidyGlobalNoErrorChecks}"},
+		{"libfoobar", "-checks='-*',${config.TidyGlobalNoChecks}",
+			"-warnings-as-errors=local-check,${config.TidyGlobalNoErrorChecks}"},
+	}
+	for _, test := range testCases {
+		t.Run(test.libName, func(t *testing.T) {
+			flags := ctx.ModuleForTests(test.libName, "android_arm64_armv8-a_shared").Rule("clangTidy").Args["tidyFlags"]
+			splitFlags := strings.Split(flags, " ")
+			android.AssertStringListContains(t, "checks", splitFlags, test.checks)
+			android.AssertStringListContains(t, "warnings as errors", splitFlags, test.asErrors)
+		})
+	}
+}
+
+func TestTidyBaseline(t *testing.T) {
+	// With a tidy_baseline, the checks that are errors are not errors for clang-tidy, the findings
+	// are filtered against the baseline instead.
+	bp := `
+		cc_library_shared {
+			name: "libfoo",
+			srcs: ["foo.c", "bar.c"],
+			tidy_checks_as_errors: ["xyz-*"],
+			tidy_baseline: "tidy_baseline.txt",
+		}`
+	for _, update := range []bool{false, true} {
+		t.Run(fmt.Sprintf("update=%t", update), func(t *testing.T) {
+			testEnv := map[string]string{"WITH_TIDY": "true"}
+			if update {
+				testEnv["TIDY_BASELINE_UPDATE"] = "true"
+			}
+			ctx := android.GroupFixturePreparers(
+				prepareForCcTest,
+				android.FixtureAddFile("tidy_baseline.txt", nil),
+				android.FixtureMergeEnv(testEnv),
+			).RunTestWithBp(t, bp)
+
+			variant := "android_arm64_armv8-a_shared"
+			libfoo := ctx.ModuleForTests("libfoo", variant)
+			tidyFlags := libfoo.Rule("clangTidy").Args["tidyFlags"]
+			android.AssertStringDoesNotContain(t, "tidyFlags", tidyFlags, "-warnings-as-errors")
+
+			filter := libfoo.Rule("tidyBaselineFilter")
+			android.AssertPathsRelativeToTopEquals(t, "inputs", []string{
+				"out/soong/.intermediates/libfoo/" + variant + "/obj/foo.tidy",
+				"out/soong/.intermediates/libfoo/" + variant + "/obj/bar.tidy",
+			}, filter.Inputs)
+			android.AssertPathRelativeToTopEquals(t, "baseline", "tidy_baseline.txt", filter.Implicit)
+			android.AssertStringEquals(t, "baseline arg", "tidy_baseline.txt", filter.Args["baseline"])
+			android.AssertStringEquals(t, "checksAsErrors arg", "'xyz-*',${config.TidyGlobalNoErrorChecks}",
+				filter.Args["checksAsErrors"])
+
+			updateFile := "out/soong/.intermediates/libfoo/" + variant + "/tidy_baseline.update"
+			if update {
+				android.AssertStringEquals(t, "updateFlags arg", "--update "+updateFile,
+					android.StringRelativeToTop(ctx.Config, filter.Args["updateFlags"]))
+			} else {
+				android.AssertStringEquals(t, "updateFlags arg", "", filter.Args["updateFlags"])
+			}
+
+			// The library depends on the filtered findings instead of the .tidy files.
+			validations := libfoo.Rule("ld").Validations.Strings()
+			android.AssertStringListContains(t, "validations", validations,
+				"out/soong/.intermediates/libfoo/"+variant+"/tidy_baseline.tidy")
+			android.AssertStringListDoesNotContain(t, "validations", validations,
+				"out/soong/.intermediates/libfoo/"+variant+"/obj/foo.tidy")
+		})
+	}
+}
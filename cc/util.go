@@ -68,11 +68,20 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 		needTidyFiles: in.NeedTidyFiles,
 		sAbiDump:      in.SAbiDump,
 		emitXrefs:     in.EmitXrefs,
+		splitDwarf:    in.SplitDwarf,
+
+		tidyBaseline:       in.TidyBaseline,
+		tidyChecksAsErrors: in.TidyChecksAsErrors,
 
 		systemIncludeFlags: strings.Join(in.SystemIncludeFlags, " "),
 
 		assemblerWithCpp: in.AssemblerWithCpp,
 
+		cfi:               len(in.cfiCFlags) > 0,
+		cfiExcludeSrcs:    in.cfiExcludeSrcs,
+		noCfiLocalCFlags:  strings.Join(append(removeFirstOccurrences(in.Local.CFlags, in.cfiCFlags), "-fno-sanitize=cfi"), " "),
+		noCfiLocalAsFlags: strings.Join(removeFirstOccurrences(in.Local.AsFlags, in.cfiAsFlags), " "),
+
 		proto:            in.proto,
 		protoC:           in.protoC,
 		protoOptionsFile: in.protoOptionsFile,
@@ -82,6 +91,21 @@ func flagsToBuilderFlags(in Flags) builderFlags {
 	}
 }
 
+// removeFirstOccurrences returns a copy of list with the first occurrence of each element of remove
+// removed, so that flags added by more than one feature are kept for the others.
+func removeFirstOccurrences(list []string, remove []string) []string {
+	ret := append([]string(nil), list...)
+	for _, r := range remove {
+		for i, s := range ret {
+			if s == r {
+				ret = append(ret[:i], ret[i+1:]...)
+				break
+			}
+		}
+	}
+	return ret
+}
+
 func flagsToStripFlags(in Flags) StripFlags {
 	return StripFlags{Toolchain: in.Toolchain}
 }
@@ -272,10 +272,10 @@ var ccSnapshotAction snapshot.GenerateSnapshotAction = func(s snapshot.SnapshotS
 
 			// library flags
 			prop.ExportedFlags = exporterInfo.Flags
-			for _, dir := range exporterInfo.IncludeDirs {
+			for _, dir := range exporterInfo.IncludeDirs.ToList() {
 				prop.ExportedDirs = append(prop.ExportedDirs, filepath.Join("include", dir.String()))
 			}
-			for _, dir := range exporterInfo.SystemIncludeDirs {
+			for _, dir := range exporterInfo.SystemIncludeDirs.ToList() {
 				prop.ExportedSystemDirs = append(prop.ExportedSystemDirs, filepath.Join("include", dir.String()))
 			}
 
@@ -727,8 +727,8 @@ func (c *vndkSnapshotSingleton) GenerateBuildActions(ctx android.SingletonContex
 		if ctx.Config().VndkSnapshotBuildArtifacts() {
 			exportedInfo := ctx.ModuleProvider(m, FlagExporterInfoProvider).(FlagExporterInfo)
 			prop.ExportedFlags = exportedInfo.Flags
-			prop.ExportedDirs = exportedInfo.IncludeDirs.Strings()
-			prop.ExportedSystemDirs = exportedInfo.SystemIncludeDirs.Strings()
+			prop.ExportedDirs = exportedInfo.IncludeDirs.ToList().Strings()
+			prop.ExportedSystemDirs = exportedInfo.SystemIncludeDirs.ToList().Strings()
 			prop.RelativeInstallPath = m.RelativeInstallPath()
 		}
 
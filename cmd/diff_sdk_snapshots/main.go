@@ -0,0 +1,279 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// diff_sdk_snapshots compares two snapshots of an sdk or module_exports module and writes a report
+// of the members that were added, removed or changed, and of the files that were added, removed or
+// changed.  The members are compared by parsing the Android.bp files of the snapshots, so the
+// report lists the properties that changed for each member instead of differences in the lines of
+// the Android.bp files.
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/blueprint/parser"
+)
+
+var (
+	oldSnapshot = flag.String("old", "", "the previous version of the snapshot")
+	newSnapshot = flag.String("new", "", "the new version of the snapshot")
+	output      = flag.String("o", "", "output file for the report")
+)
+
+// The Android.bp file at the root of a snapshot, whose modules are the members of the snapshot.
+const snapshotBp = "Android.bp"
+
+// member is a module in the Android.bp file of a snapshot.
+type member struct {
+	moduleType string
+
+	// properties maps the name of each property, with the names of the enclosing properties
+	// separated by a ".", to its value.
+	properties map[string]string
+}
+
+// snapshot is the contents of a snapshot zip file.
+type snapshot struct {
+	// members maps the name of each member to the member.
+	members map[string]*member
+
+	// files maps the name of each file other than the Android.bp file to its CRC-32 checksum.
+	files map[string]uint32
+}
+
+// readSnapshot reads the snapshot in the zip file at path.
+func readSnapshot(path string) (*snapshot, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	s := &snapshot{
+		members: make(map[string]*member),
+		files:   make(map[string]uint32),
+	}
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if f.Name != snapshotBp {
+			s.files[f.Name] = f.CRC32
+			continue
+		}
+
+		bp, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		err = s.parseMembers(path+"!/"+snapshotBp, bp)
+		bp.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// parseMembers parses the members of the snapshot from its Android.bp file.
+func (s *snapshot) parseMembers(filename string, r io.Reader) error {
+	file, errs := parser.Parse(filename, r, parser.NewScope(nil))
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	for _, def := range file.Defs {
+		module, ok := def.(*parser.Module)
+		if !ok {
+			continue
+		}
+		m := &member{
+			moduleType: module.Type,
+			properties: make(map[string]string),
+		}
+		flattenProperties("", &module.Map, m.properties)
+		name, err := strconv.Unquote(m.properties["name"])
+		if err != nil {
+			return fmt.Errorf("%s: %s module without a name", module.Pos(), module.Type)
+		}
+		delete(m.properties, "name")
+		s.members[name] = m
+	}
+	return nil
+}
+
+// flattenProperties adds the properties in m to properties, naming the properties that are nested
+// in other properties with the names of the enclosing properties.
+func flattenProperties(prefix string, m *parser.Map, properties map[string]string) {
+	for _, property := range m.Properties {
+		name := prefix + property.Name
+		if nested, ok := property.Value.(*parser.Map); ok {
+			flattenProperties(name+".", nested, properties)
+		} else {
+			properties[name] = valueString(property.Value)
+		}
+	}
+}
+
+// valueString returns a string for the value of a property, which does not depend on its position
+// in the Android.bp file.
+func valueString(value parser.Expression) string {
+	switch v := value.(type) {
+	case *parser.String:
+		return strconv.Quote(v.Value)
+	case *parser.Bool:
+		return strconv.FormatBool(v.Value)
+	case *parser.Int64:
+		return strconv.FormatInt(v.Value, 10)
+	case *parser.Variable:
+		return v.Name
+	case *parser.List:
+		var values []string
+		for _, element := range v.Values {
+			values = append(values, valueString(element))
+		}
+		return "[" + strings.Join(values, ", ") + "]"
+	case *parser.Map:
+		var properties []string
+		for _, property := range v.Properties {
+			properties = append(properties, property.Name+": "+valueString(property.Value))
+		}
+		return "{" + strings.Join(properties, ", ") + "}"
+	case *parser.Operator:
+		return valueString(v.Args[0]) + " " + string(v.Operator) + " " + valueString(v.Args[1])
+	default:
+		panic(fmt.Errorf("unexpected value %#v", value))
+	}
+}
+
+// diffSnapshots returns the report of the differences between the old and the new snapshot.
+func diffSnapshots(old, new *snapshot) string {
+	var report strings.Builder
+
+	var added, removed, changed []string
+	for _, name := range sortedKeys(new.members) {
+		if _, exists := old.members[name]; !exists {
+			added = append(added, fmt.Sprintf("%s (%s)", name, new.members[name].moduleType))
+		}
+	}
+	for _, name := range sortedKeys(old.members) {
+		oldMember := old.members[name]
+		newMember, exists := new.members[name]
+		if !exists {
+			removed = append(removed, fmt.Sprintf("%s (%s)", name, oldMember.moduleType))
+			continue
+		}
+		if changes := diffMembers(oldMember, newMember); len(changes) > 0 {
+			changed = append(changed, fmt.Sprintf("%s (%s):\n    %s", name, newMember.moduleType,
+				strings.Join(changes, "\n    ")))
+		}
+	}
+	writeSection(&report, "Added members", added)
+	writeSection(&report, "Removed members", removed)
+	writeSection(&report, "Changed members", changed)
+
+	added, removed, changed = nil, nil, nil
+	for _, name := range sortedKeys(new.files) {
+		if _, exists := old.files[name]; !exists {
+			added = append(added, name)
+		}
+	}
+	for _, name := range sortedKeys(old.files) {
+		newChecksum, exists := new.files[name]
+		if !exists {
+			removed = append(removed, name)
+		} else if newChecksum != old.files[name] {
+			changed = append(changed, name)
+		}
+	}
+	writeSection(&report, "Added files", added)
+	writeSection(&report, "Removed files", removed)
+	writeSection(&report, "Changed files", changed)
+
+	if report.Len() == 0 {
+		return "No differences\n"
+	}
+	return report.String()
+}
+
+// diffMembers returns the differences between two versions of a member.
+func diffMembers(old, new *member) []string {
+	var changes []string
+	if old.moduleType != new.moduleType {
+		changes = append(changes, fmt.Sprintf("module type changed from %s to %s", old.moduleType, new.moduleType))
+	}
+	for _, name := range sortedKeys(new.properties) {
+		if _, exists := old.properties[name]; !exists {
+			changes = append(changes, fmt.Sprintf("%s added: %s", name, new.properties[name]))
+		}
+	}
+	for _, name := range sortedKeys(old.properties) {
+		newValue, exists := new.properties[name]
+		if !exists {
+			changes = append(changes, fmt.Sprintf("%s removed: %s", name, old.properties[name]))
+		} else if newValue != old.properties[name] {
+			changes = append(changes, fmt.Sprintf("%s changed: %s -> %s", name, old.properties[name], newValue))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+func writeSection(w io.Writer, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:\n", title)
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %s\n", line)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func main() {
+	flag.Parse()
+	if *oldSnapshot == "" || *newSnapshot == "" || *output == "" {
+		fmt.Fprintln(os.Stderr, "usage: diff_sdk_snapshots --old <old.zip> --new <new.zip> -o <report.txt>")
+		os.Exit(1)
+	}
+
+	old, err := readSnapshot(*oldSnapshot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff_sdk_snapshots:", err)
+		os.Exit(1)
+	}
+	new, err := readSnapshot(*newSnapshot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "diff_sdk_snapshots:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, []byte(diffSnapshots(old, new)), 0666); err != nil {
+		fmt.Fprintln(os.Stderr, "diff_sdk_snapshots:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSnapshot(t *testing.T, name string, files map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for _, name := range sortedKeys(files) {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	oldPath := writeSnapshot(t, "old.zip", map[string]string{
+		"Android.bp": `
+			java_import {
+				name: "myjavalib",
+				prefer: false,
+				jars: ["java/myjavalib.jar"],
+			}
+
+			cc_prebuilt_library_shared {
+				name: "mynativelib",
+				arch: {
+					arm64: {
+						srcs: ["arm64/lib/mynativelib.so"],
+					},
+				},
+			}
+
+			prebuilt_etc {
+				name: "myetc",
+				src: "etc/myetc",
+			}
+		`,
+		"java/myjavalib.jar":        "old jar",
+		"arm64/lib/mynativelib.so":  "native lib",
+		"etc/myetc":                 "etc",
+		"include/mynativelib/foo.h": "old header",
+	})
+	newPath := writeSnapshot(t, "new.zip", map[string]string{
+		"Android.bp": `
+			java_sdk_library_import {
+				name: "myjavalib",
+				prefer: false,
+				jars: ["java/myjavalib.jar"],
+				min_sdk_version: "30",
+			}
+
+			cc_prebuilt_library_shared {
+				name: "mynativelib",
+				arch: {
+					arm64: {
+						srcs: ["arm64/lib/mynativelib.so"],
+					},
+					x86_64: {
+						srcs: ["x86_64/lib/mynativelib.so"],
+					},
+				},
+			}
+
+			cc_prebuilt_binary {
+				name: "mybinary",
+				srcs: ["bin/mybinary"],
+			}
+		`,
+		"java/myjavalib.jar":        "new jar",
+		"arm64/lib/mynativelib.so":  "native lib",
+		"x86_64/lib/mynativelib.so": "native lib",
+		"bin/mybinary":              "binary",
+		"include/mynativelib/foo.h": "old header",
+	})
+
+	old, err := readSnapshot(oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := readSnapshot(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Added members:
+  mybinary (cc_prebuilt_binary)
+Removed members:
+  myetc (prebuilt_etc)
+Changed members:
+  myjavalib (java_sdk_library_import):
+    min_sdk_version added: "30"
+    module type changed from java_import to java_sdk_library_import
+  mynativelib (cc_prebuilt_library_shared):
+    arch.x86_64.srcs added: ["x86_64/lib/mynativelib.so"]
+Added files:
+  bin/mybinary
+  x86_64/lib/mynativelib.so
+Removed files:
+  etc/myetc
+Changed files:
+  java/myjavalib.jar
+`
+	if got := diffSnapshots(old, new); got != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := diffSnapshots(new, new); got != "No differences\n" {
+		t.Errorf("expected no differences, got:\n%s", got)
+	}
+}
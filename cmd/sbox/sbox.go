@@ -26,6 +26,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -292,6 +293,20 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 		return "", err
 	}
 
+	// Input tracing relies on strace, which is only available on Linux.
+	strictInputs := command.GetStrictInputs() && runtime.GOOS == "linux"
+	tracePath := joinPath(tempDir, inputTraceFile)
+	if strictInputs {
+		absTracePath, err := filepath.Abs(tracePath)
+		if err != nil {
+			return "", err
+		}
+		cmd, err = wrapWithInputTracing(cmd, absTracePath)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	buf := &bytes.Buffer{}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = buf
@@ -342,6 +357,17 @@ func runCommand(command *sbox_proto.Command, tempDir string, commandIndex int) (
 		return "", err
 	}
 
+	if strictInputs {
+		commandDir, err := filepath.Abs(cmd.Dir)
+		if err != nil {
+			return "", err
+		}
+		err = checkStrictInputs(command, tracePath, commandDir, tempDir)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	// the created files match the declared files; now move them
 	err = moveFiles(command.CopyAfter, tempDir, "", writeType(writeIfChanged))
 	if err != nil {
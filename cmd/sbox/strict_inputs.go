@@ -0,0 +1,174 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"android/soong/cmd/sbox/sbox_proto"
+)
+
+const inputTraceFile = "sbox_inputs.trace"
+
+// traceOpenRegexp matches successful open and openat calls in strace output, for example:
+//
+//	1234  openat(AT_FDCWD, "frameworks/base/foo.txt", O_RDONLY|O_CLOEXEC) = 3
+var traceOpenRegexp = regexp.MustCompile(`open(?:at)?\((?:[^,"]+, )?"((?:[^"\\]|\\.)*)", ([A-Z_|]+)[^)]*\)\s+= (\d+)`)
+
+// wrapWithInputTracing returns a copy of cmd that runs under strace, recording every file opened by
+// the command and its children into tracePath.  strace is run from the host's PATH, which soong_ui
+// allows in ui/build/paths/config.go.
+func wrapWithInputTracing(cmd *exec.Cmd, tracePath string) (*exec.Cmd, error) {
+	strace, err := exec.LookPath("strace")
+	if err != nil {
+		return nil, fmt.Errorf("strace was not found in PATH, it is required to track the inputs of "+
+			"genrules with strict_sandbox or in GenruleStrictSandboxPaths, install strace on the "+
+			"build host or disable strict_sandbox: %w", err)
+	}
+	args := []string{"-f", "-qq", "-e", "trace=open,openat", "-o", tracePath, "--"}
+	args = append(args, cmd.Args...)
+	return exec.Command(strace, args...), nil
+}
+
+// tracedInputs parses strace output and returns the paths of the files that were successfully
+// opened for reading, relative to the directory the command was run in.
+func tracedInputs(r io.Reader) ([]string, error) {
+	seen := make(map[string]bool)
+	var inputs []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		match := traceOpenRegexp.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		path, flags := match[1], strings.Split(match[2], "|")
+		if inList("O_WRONLY", flags) || inList("O_RDWR", flags) || inList("O_DIRECTORY", flags) {
+			continue
+		}
+		if !seen[path] {
+			seen[path] = true
+			inputs = append(inputs, path)
+		}
+	}
+	return inputs, scanner.Err()
+}
+
+// undeclaredInputs returns the files read by the command that are inside the current directory,
+// outside the sandbox, and not declared as inputs or tools in the manifest or allowlisted.
+func undeclaredInputs(command *sbox_proto.Command, read []string, commandDir, sandboxDir string) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	declared := make(map[string]bool)
+	for _, copyPair := range command.CopyBefore {
+		declared[filepath.Clean(copyPair.GetFrom())] = true
+	}
+	for _, rspFile := range command.RspFiles {
+		declared[filepath.Clean(rspFile.GetFile())] = true
+	}
+	for _, input := range command.GetDeclaredInputs() {
+		declared[filepath.Clean(input)] = true
+	}
+	for _, input := range command.GetAllowedUndeclaredInputs() {
+		declared[filepath.Clean(input)] = true
+	}
+
+	absSandboxDir, err := filepath.Abs(sandboxDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var undeclared []string
+	for _, path := range read {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(commandDir, path)
+		}
+		if strings.HasPrefix(path, absSandboxDir+"/") {
+			continue
+		}
+		rel, err := filepath.Rel(cwd, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			// Files outside the source tree, for example from the host's /usr, are not tracked.
+			continue
+		}
+		if declared[rel] {
+			continue
+		}
+		if info, err := os.Stat(rel); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		undeclared = append(undeclared, rel)
+	}
+	sort.Strings(undeclared)
+	return undeclared, nil
+}
+
+// undeclaredInputsError returns an error listing the undeclared inputs, and the srcs entry that
+// would declare each one.
+func undeclaredInputsError(undeclared []string, moduleDir string) error {
+	errorMessage := "command read files that were not declared as inputs, add them to srcs:\n"
+	for _, path := range undeclared {
+		if rel, err := filepath.Rel(moduleDir, path); moduleDir != "" && err == nil && !strings.HasPrefix(rel, "../") {
+			errorMessage += fmt.Sprintf("  %s: add %q to srcs\n", path, rel)
+		} else {
+			errorMessage += fmt.Sprintf("  %s: outside the module directory, add it to a filegroup and add the filegroup to srcs\n", path)
+		}
+	}
+	return errors.New(errorMessage)
+}
+
+// checkStrictInputs verifies that a command that ran with strict_inputs only read declared inputs.
+func checkStrictInputs(command *sbox_proto.Command, tracePath, commandDir, sandboxDir string) error {
+	trace, err := os.Open(tracePath)
+	if err != nil {
+		return fmt.Errorf("failed to read input trace: %w", err)
+	}
+	defer trace.Close()
+
+	read, err := tracedInputs(trace)
+	if err != nil {
+		return fmt.Errorf("failed to parse input trace %q: %w", tracePath, err)
+	}
+
+	undeclared, err := undeclaredInputs(command, read, commandDir, sandboxDir)
+	if err != nil {
+		return err
+	}
+	if len(undeclared) > 0 {
+		return undeclaredInputsError(undeclared, command.GetModuleDir())
+	}
+	return nil
+}
+
+func inList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/cmd/sbox/sbox_proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func Test_tracedInputs(t *testing.T) {
+	trace := strings.Join([]string{
+		`100 openat(AT_FDCWD, "a/src.txt", O_RDONLY|O_CLOEXEC) = 3`,
+		`100 openat(AT_FDCWD, "a/src.txt", O_RDONLY) = 4`,
+		`100 open("/usr/lib/libc.so", O_RDONLY|O_CLOEXEC) = 3`,
+		`101 openat(AT_FDCWD, "a/missing.txt", O_RDONLY) = -1 ENOENT (No such file or directory)`,
+		`101 openat(AT_FDCWD, "out/gen/out.txt", O_WRONLY|O_CREAT|O_TRUNC, 0666) = 5`,
+		`101 openat(AT_FDCWD, "a", O_RDONLY|O_NONBLOCK|O_CLOEXEC|O_DIRECTORY) = 6`,
+		`101 +++ exited with 0 +++`,
+	}, "\n")
+
+	got, err := tracedInputs(strings.NewReader(trace))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"a/src.txt", "/usr/lib/libc.so"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tracedInputs() = %q, want %q", got, want)
+	}
+}
+
+func Test_undeclaredInputs(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "testUndeclaredInputs")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, file := range []string{"a/declared.txt", "a/undeclared.txt", "a/allowed.txt", "b/other.txt", "sandbox/tool"} {
+		path := filepath.Join(tempDir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("failed to create dir: %s", err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatalf("failed to write %s: %s", path, err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	command := &sbox_proto.Command{
+		StrictInputs:            proto.Bool(true),
+		DeclaredInputs:          []string{"a/declared.txt"},
+		AllowedUndeclaredInputs: []string{"a/allowed.txt"},
+		ModuleDir:               proto.String("a"),
+	}
+	read := []string{
+		"a/declared.txt",
+		"a/undeclared.txt",
+		"a/allowed.txt",
+		filepath.Join(tempDir, "b/other.txt"),
+		"sandbox/tool",
+		"/usr/lib/libc.so",
+	}
+
+	got, err := undeclaredInputs(command, read, tempDir, "sandbox")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"a/undeclared.txt", "b/other.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("undeclaredInputs() = %q, want %q", got, want)
+	}
+
+	message := undeclaredInputsError(got, command.GetModuleDir()).Error()
+	if !strings.Contains(message, `a/undeclared.txt: add "undeclared.txt" to srcs`) {
+		t.Errorf("expected srcs suggestion for a/undeclared.txt, got:\n%s", message)
+	}
+	if !strings.Contains(message, "b/other.txt: outside the module directory") {
+		t.Errorf("expected filegroup suggestion for b/other.txt, got:\n%s", message)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// size_attribution reads the list of installed files written by the size_attribution singleton of
+// Soong, which maps every installed file to its module, team, partition and the file whose size is
+// attributed to it, and writes the same list with the size of every file, and the total size per
+// team and partition.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+var (
+	filesCsv   = flag.String("files", "", "list of installed files written by Soong")
+	summaryCsv = flag.String("summary", "", "output file for the total size per team and partition")
+	outputCsv  = flag.String("o", "", "output file for the size of every installed file")
+)
+
+var filesHeader = []string{"file", "module", "team", "partition", "source"}
+
+// attribute returns the size of every file in files, which is in the format of filesHeader, and
+// the total size per team and partition, as CSV records with a header.
+func attribute(files [][]string, size func(path string) (int64, error)) (sizes, summary [][]string, err error) {
+	if len(files) == 0 || !equal(files[0], filesHeader) {
+		return nil, nil, fmt.Errorf("expected header %q", filesHeader)
+	}
+
+	type key struct{ team, partition string }
+	totals := make(map[key]int64)
+
+	sizes = [][]string{{"file", "module", "team", "partition", "size"}}
+	for _, record := range files[1:] {
+		file, module, team, partition, source := record[0], record[1], record[2], record[3], record[4]
+		s, err := size(source)
+		if err != nil {
+			return nil, nil, err
+		}
+		sizes = append(sizes, []string{file, module, team, partition, strconv.FormatInt(s, 10)})
+		totals[key{team, partition}] += s
+	}
+
+	var keys []key
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].team != keys[j].team {
+			return keys[i].team < keys[j].team
+		}
+		return keys[i].partition < keys[j].partition
+	})
+	summary = [][]string{{"team", "partition", "size"}}
+	for _, k := range keys {
+		summary = append(summary, []string{k.team, k.partition, strconv.FormatInt(totals[k], 10)})
+	}
+	return sizes, summary, nil
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fileSize returns the size of a file, or of the symlink itself if the file is a symlink.
+func fileSize(path string) (int64, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func readCsv(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return csv.NewReader(f).ReadAll()
+}
+
+func writeCsv(path string, records [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := csv.NewWriter(f).WriteAll(records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func main() {
+	flag.Parse()
+	if *filesCsv == "" || *summaryCsv == "" || *outputCsv == "" {
+		fmt.Fprintln(os.Stderr, "usage: size_attribution --files <files.csv> --summary <summary.csv> -o <output.csv>")
+		os.Exit(1)
+	}
+
+	files, err := readCsv(*filesCsv)
+	if err == nil {
+		var sizes, summary [][]string
+		sizes, summary, err = attribute(files, fileSize)
+		if err == nil {
+			err = writeCsv(*outputCsv, sizes)
+		}
+		if err == nil {
+			err = writeCsv(*summaryCsv, summary)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "size_attribution:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAttribute(t *testing.T) {
+	files := [][]string{
+		filesHeader,
+		{"/system/bin/foo", "foo", "team_a", "system", "out/foo"},
+		{"/system/apex/com.android.bar.apex!/lib64/libbar.so", "libbar", "team_b", "system", "out/libbar.so"},
+		{"/system/apex/com.android.bar.apex!/bin/baz", "baz", "team_a", "system", "out/baz"},
+		{"/vendor/bin/qux", "qux", "team_a", "vendor", "out/qux"},
+	}
+	sizes := map[string]int64{
+		"out/foo":       100,
+		"out/libbar.so": 20,
+		"out/baz":       3,
+		"out/qux":       4000,
+	}
+
+	gotSizes, gotSummary, err := attribute(files, func(path string) (int64, error) {
+		if size, ok := sizes[path]; ok {
+			return size, nil
+		}
+		return 0, fmt.Errorf("unexpected path %q", path)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedSizes := [][]string{
+		{"file", "module", "team", "partition", "size"},
+		{"/system/bin/foo", "foo", "team_a", "system", "100"},
+		{"/system/apex/com.android.bar.apex!/lib64/libbar.so", "libbar", "team_b", "system", "20"},
+		{"/system/apex/com.android.bar.apex!/bin/baz", "baz", "team_a", "system", "3"},
+		{"/vendor/bin/qux", "qux", "team_a", "vendor", "4000"},
+	}
+	if !reflect.DeepEqual(gotSizes, expectedSizes) {
+		t.Errorf("incorrect sizes\nexpected: %q\n     got: %q", expectedSizes, gotSizes)
+	}
+
+	expectedSummary := [][]string{
+		{"team", "partition", "size"},
+		{"team_a", "system", "103"},
+		{"team_a", "vendor", "4000"},
+		{"team_b", "system", "20"},
+	}
+	if !reflect.DeepEqual(gotSummary, expectedSummary) {
+		t.Errorf("incorrect summary\nexpected: %q\n     got: %q", expectedSummary, gotSummary)
+	}
+}
+
+func TestAttributeErrors(t *testing.T) {
+	size := func(path string) (int64, error) {
+		return 0, fmt.Errorf("%s: no such file", path)
+	}
+
+	if _, _, err := attribute([][]string{{"file", "size"}}, size); err == nil {
+		t.Errorf("expected an error for an unexpected header")
+	}
+	if _, _, err := attribute([][]string{filesHeader, {"/system/bin/foo", "foo", "", "system", "out/foo"}}, size); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
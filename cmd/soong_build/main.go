@@ -72,6 +72,7 @@ func init() {
 	flag.StringVar(&cmdlineArgs.ModuleGraphFile, "module_graph_file", "", "JSON module graph file to output")
 	flag.StringVar(&cmdlineArgs.ModuleActionsFile, "module_actions_file", "", "JSON file to output inputs/outputs of actions of modules")
 	flag.StringVar(&cmdlineArgs.DocFile, "soong_docs", "", "build documentation file to output")
+	flag.StringVar(&cmdlineArgs.SoongQueryFile, "soong_query_result", "", "file to write the result of the query in SOONG_QUERY to")
 	flag.StringVar(&cmdlineArgs.BazelQueryViewDir, "bazel_queryview_dir", "", "path to the bazel queryview directory relative to --top")
 	flag.StringVar(&cmdlineArgs.BazelApiBp2buildDir, "bazel_api_bp2build_dir", "", "path to the bazel api_bp2build directory relative to --top")
 	flag.StringVar(&cmdlineArgs.Bp2buildMarker, "bp2build_marker", "", "If set, run bp2build, touch the specified marker file then exit")
@@ -85,6 +86,7 @@ func init() {
 	flag.BoolVar(&cmdlineArgs.BazelModeDev, "bazel-mode-dev", false, "use bazel for analysis of a large number of modules (less stable)")
 	flag.BoolVar(&cmdlineArgs.UseBazelProxy, "use-bazel-proxy", false, "communicate with bazel using unix socket proxy instead of spawning subprocesses")
 	flag.BoolVar(&cmdlineArgs.BuildFromTextStub, "build-from-text-stub", false, "build Java stubs from API text files instead of source files")
+	flag.StringVar(&cmdlineArgs.ProgressFile, "progress_file", "", "status file to report the analysis progress to")
 
 	// Flags that probably shouldn't be flags of soong_build, but we haven't found
 	// the time to remove them yet
@@ -288,6 +290,30 @@ func writeMetrics(configuration android.Config, eventHandler *metrics.EventHandl
 	maybeQuit(err, "error writing soong_build metrics %s", metricsFile)
 }
 
+// writeModuleTypeCounts writes the number of modules of each module type for the disk space check
+// of soong_ui before the next build.
+// checkIntermediatesLayout fails the build if the intermediates directory was created with a
+// different layout, see SOONG_HASHED_INTERMEDIATES.
+func checkIntermediatesLayout(configuration android.Config) {
+	layoutFile := filepath.Join(configuration.SoongOutDir(), ".intermediates_layout")
+	err := android.CheckIntermediatesLayout(configuration, layoutFile)
+	maybeQuit(err, "")
+}
+
+func writeModuleTypeCounts(configuration android.Config) {
+	countsFile := filepath.Join(configuration.SoongOutDir(), "module_type_counts.txt")
+	err := android.WriteModuleTypeCounts(configuration, countsFile)
+	maybeQuit(err, "error writing module type counts %s", countsFile)
+}
+
+// writeLazyProductVariableAccesses writes the number of accesses to each lazily decoded section of
+// the product variables, to find the sections that are not used by the build.
+func writeLazyProductVariableAccesses(configuration android.Config) {
+	accessesFile := filepath.Join(configuration.SoongOutDir(), "lazy_product_variables.txt")
+	err := android.WriteLazyProductVariableAccesses(configuration, accessesFile)
+	maybeQuit(err, "error writing lazy product variable accesses %s", accessesFile)
+}
+
 func writeJsonModuleGraphAndActions(ctx *android.Context, cmdArgs android.CmdArgs) {
 	graphFile, graphErr := os.Create(shared.JoinPath(topDir, cmdArgs.ModuleGraphFile))
 	maybeQuit(graphErr, "graph err")
@@ -329,10 +355,15 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 	switch ctx.Config().BuildMode {
 	case android.GenerateModuleGraph:
 		stopBefore = bootstrap.StopBeforeWriteNinja
-	case android.GenerateQueryView, android.GenerateDocFile:
+	case android.GenerateQueryView, android.GenerateDocFile, android.GenerateSoongQuery:
 		stopBefore = bootstrap.StopBeforePrepareBuildActions
 	default:
-		stopBefore = bootstrap.DoEverything
+		if ctx.Config().Getenv(android.PartialNinjaTargetsEnv) != "" {
+			// The partial ninja file is written below instead of the full one.
+			stopBefore = bootstrap.StopBeforeWriteNinja
+		} else {
+			stopBefore = bootstrap.DoEverything
+		}
 	}
 
 	ninjaDeps := bootstrap.RunBlueprint(cmdlineArgs.Args, stopBefore, ctx.Context, ctx.Config())
@@ -360,9 +391,28 @@ func runSoongOnlyBuild(ctx *android.Context, extraNinjaDeps []string) string {
 		maybeQuit(err, "error building Soong documentation")
 		writeDepFile(cmdlineArgs.DocFile, ctx.EventHandler, ninjaDeps)
 		return cmdlineArgs.DocFile
+	case android.GenerateSoongQuery:
+		result, err := android.RunSoongQuery(ctx)
+		maybeQuit(err, "error running %s", android.SoongQueryEnv)
+		err = os.WriteFile(shared.JoinPath(topDir, cmdlineArgs.SoongQueryFile), []byte(result), 0666)
+		maybeQuit(err, "error writing %s", cmdlineArgs.SoongQueryFile)
+		fmt.Print(result)
+		writeDepFile(cmdlineArgs.SoongQueryFile, ctx.EventHandler, ninjaDeps)
+		return cmdlineArgs.SoongQueryFile
 	default:
-		// The actual output (build.ninja) was written in the RunBlueprint() call
-		// above
+		if targets := ctx.Config().Getenv(android.PartialNinjaTargetsEnv); targets != "" {
+			// Only the build statements needed for the targets are written, and the output
+			// file includes them.
+			partialFile := filepath.Join(ctx.Config().SoongOutDir(), "partial.ninja")
+			err := android.WritePartialNinjaFile(ctx.Config(), shared.JoinPath(topDir, partialFile),
+				strings.Fields(targets), ctx.Context.WriteBuildFile)
+			maybeQuit(err, "error writing %s", partialFile)
+			err = os.WriteFile(shared.JoinPath(topDir, cmdlineArgs.OutFile),
+				[]byte(fmt.Sprintf("include %s\n", partialFile)), 0666)
+			maybeQuit(err, "error writing %s", cmdlineArgs.OutFile)
+		}
+		// Otherwise the actual output (build.ninja) was written in the RunBlueprint()
+		// call above
 		writeDepFile(cmdlineArgs.OutFile, ctx.EventHandler, ninjaDeps)
 		return cmdlineArgs.OutFile
 	}
@@ -432,6 +482,7 @@ func main() {
 		finalOutputFile = runApiBp2build(ctx, extraNinjaDeps)
 		writeMetrics(configuration, ctx.EventHandler, metricsDir)
 	default:
+		checkIntermediatesLayout(configuration)
 		ctx.Register()
 		if configuration.IsMixedBuildsEnabled() {
 			finalOutputFile = runMixedModeBuild(ctx, extraNinjaDeps)
@@ -442,7 +493,10 @@ func main() {
 			writeNinjaHint(ctx)
 		}
 		writeMetrics(configuration, ctx.EventHandler, metricsDir)
+		writeModuleTypeCounts(configuration)
+		writeLazyProductVariableAccesses(configuration)
 	}
+	maybeQuit(configuration.FinishAnalysisProgress(), "")
 	writeUsedEnvironmentFile(configuration)
 
 	// Touch the output file so that it's the newest file created by soong_build.
@@ -57,12 +57,10 @@ func RegisterPrebuiltEtcBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("prebuilt_etc_cacerts", PrebuiltEtcCaCertsFactory)
 	ctx.RegisterModuleType("prebuilt_root", PrebuiltRootFactory)
 	ctx.RegisterModuleType("prebuilt_root_host", PrebuiltRootHostFactory)
-	ctx.RegisterModuleType("prebuilt_usr_share", PrebuiltUserShareFactory)
-	ctx.RegisterModuleType("prebuilt_usr_share_host", PrebuiltUserShareHostFactory)
-	ctx.RegisterModuleType("prebuilt_font", PrebuiltFontFactory)
-	ctx.RegisterModuleType("prebuilt_firmware", PrebuiltFirmwareFactory)
-	ctx.RegisterModuleType("prebuilt_dsp", PrebuiltDSPFactory)
-	ctx.RegisterModuleType("prebuilt_rfsa", PrebuiltRFSAFactory)
+	for _, t := range prebuiltEtcTypes {
+		RegisterPrebuiltEtcType(ctx, t)
+	}
+	ctx.RegisterModuleType("prebuilt_product_type", PrebuiltProductTypeFactory)
 
 	ctx.RegisterModuleType("prebuilt_defaults", defaultsFactory)
 
@@ -153,6 +151,13 @@ type PrebuiltEtc struct {
 	socInstallDirBase      string
 	installDirPath         android.InstallPath
 	additionalDependencies *android.Paths
+
+	// The extensions of src and the partitions allowed by the PrebuiltEtcType of the module.
+	allowedExtensions []string
+	allowedPartitions []string
+
+	// The properties of a prebuilt_product_type module.
+	productTypeProperties *prebuiltProductTypeProperties
 }
 
 type Defaults struct {
@@ -341,6 +346,14 @@ func (p *PrebuiltEtc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ctx.PropertyErrorf("sub_dir", "relative_install_path is set. Cannot set sub_dir")
 	}
 
+	p.checkPrebuiltEtcType(ctx)
+	if p.productTypeProperties != nil {
+		p.installDirBase = p.productTypeInstallDir(ctx)
+		if ctx.Failed() {
+			return
+		}
+	}
+
 	// If soc install dir was specified and SOC specific is set, set the installDirPath to the
 	// specified socInstallDirBase.
 	installBaseDir := p.installDirBase
@@ -492,34 +505,18 @@ func PrebuiltRootHostFactory() android.Module {
 // prebuilt_usr_share is for a prebuilt artifact that is installed in
 // <partition>/usr/share/<sub_dir> directory.
 func PrebuiltUserShareFactory() android.Module {
-	module := &PrebuiltEtc{}
-	InitPrebuiltEtcModule(module, "usr/share")
-	// This module is device-only
-	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
-	android.InitDefaultableModule(module)
-	android.InitBazelModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_usr_share")
 }
 
 // prebuild_usr_share_host is for a host prebuilt artifact that is installed in
 // $(HOST_OUT)/usr/share/<sub_dir> directory.
 func PrebuiltUserShareHostFactory() android.Module {
-	module := &PrebuiltEtc{}
-	InitPrebuiltEtcModule(module, "usr/share")
-	// This module is host-only
-	android.InitAndroidArchModule(module, android.HostSupported, android.MultilibCommon)
-	android.InitDefaultableModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_usr_share_host")
 }
 
 // prebuilt_font installs a font in <partition>/fonts directory.
 func PrebuiltFontFactory() android.Module {
-	module := &PrebuiltEtc{}
-	InitPrebuiltEtcModule(module, "fonts")
-	// This module is device-only
-	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
-	android.InitDefaultableModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_font")
 }
 
 // prebuilt_firmware installs a firmware file to <partition>/etc/firmware directory for system
@@ -527,40 +524,20 @@ func PrebuiltFontFactory() android.Module {
 // If soc_specific property is set to true, the firmware file is installed to the
 // vendor <partition>/firmware directory for vendor image.
 func PrebuiltFirmwareFactory() android.Module {
-	module := &PrebuiltEtc{}
-	module.socInstallDirBase = "firmware"
-	InitPrebuiltEtcModule(module, "etc/firmware")
-	// This module is device-only
-	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
-	android.InitDefaultableModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_firmware")
 }
 
 // prebuilt_dsp installs a DSP related file to <partition>/etc/dsp directory for system image.
 // If soc_specific property is set to true, the DSP related file is installed to the
 // vendor <partition>/dsp directory for vendor image.
 func PrebuiltDSPFactory() android.Module {
-	module := &PrebuiltEtc{}
-	module.socInstallDirBase = "dsp"
-	InitPrebuiltEtcModule(module, "etc/dsp")
-	// This module is device-only
-	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
-	android.InitDefaultableModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_dsp")
 }
 
 // prebuilt_rfsa installs a firmware file that will be available through Qualcomm's RFSA
 // to the <partition>/lib/rfsa directory.
 func PrebuiltRFSAFactory() android.Module {
-	module := &PrebuiltEtc{}
-	// Ideally these would go in /vendor/dsp, but the /vendor/lib/rfsa paths are hardcoded in too
-	// many places outside of the application processor.  They could be moved to /vendor/dsp once
-	// that is cleaned up.
-	InitPrebuiltEtcModule(module, "lib/rfsa")
-	// This module is device-only
-	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
-	android.InitDefaultableModule(module)
-	return module
+	return prebuiltEtcTypeFactory("prebuilt_rfsa")
 }
 
 // Copy file into the snapshot
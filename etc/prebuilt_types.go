@@ -0,0 +1,198 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etc
+
+// This file implements the module types that install a prebuilt file into a fixed directory,
+// like prebuilt_font.  Each of them is a row of prebuiltEtcTypes, so a new install directory
+// only needs a new row.
+//
+// An install directory that is only needed by a product can instead be added to the
+// PrebuiltProductTypes product variable, which maps the name of a type to its install
+// directory, and used with the product_type property of a prebuilt_product_type module.  The
+// install directories of product types must be below one of productTypeAllowedDirs.
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/soong/android"
+)
+
+// PrebuiltEtcType describes a module type that installs a prebuilt file into a fixed directory.
+type PrebuiltEtcType struct {
+	// Name is the name of the module type, e.g. "prebuilt_font".
+	Name string
+
+	// InstallDir is the install directory, relative to the partition for device module types or
+	// to $(HOST_OUT) for host module types.
+	InstallDir string
+
+	// SocInstallDir is the install directory when soc_specific is set, if it is different from
+	// InstallDir.
+	SocInstallDir string
+
+	// Host is true for a host module type.
+	Host bool
+
+	// Extensions is the list of extensions, with the leading dot, that the src of the modules
+	// may have.  Any extension is allowed if it is empty.
+	Extensions []string
+
+	// Partitions is the list of partitions, e.g. "vendor", that the modules may be installed in.
+	// Any partition is allowed if it is empty.
+	Partitions []string
+
+	// Bazel is true if the modules can be converted by bp2build.
+	Bazel bool
+}
+
+var prebuiltEtcTypes = []PrebuiltEtcType{
+	// prebuilt_usr_share installs a file in <partition>/usr/share/<sub_dir>.
+	{Name: "prebuilt_usr_share", InstallDir: "usr/share", Bazel: true},
+
+	// prebuilt_usr_share_host installs a host file in $(HOST_OUT)/usr/share/<sub_dir>.
+	{Name: "prebuilt_usr_share_host", InstallDir: "usr/share", Host: true},
+
+	// prebuilt_font installs a font in <partition>/fonts.
+	{Name: "prebuilt_font", InstallDir: "fonts"},
+
+	// prebuilt_firmware installs a firmware file in <partition>/etc/firmware for the system
+	// image, or in <partition>/firmware for the vendor image if soc_specific is set.
+	{Name: "prebuilt_firmware", InstallDir: "etc/firmware", SocInstallDir: "firmware"},
+
+	// prebuilt_dsp installs a DSP related file in <partition>/etc/dsp for the system image, or in
+	// <partition>/dsp for the vendor image if soc_specific is set.
+	{Name: "prebuilt_dsp", InstallDir: "etc/dsp", SocInstallDir: "dsp"},
+
+	// prebuilt_rfsa installs a firmware file that will be available through Qualcomm's RFSA in
+	// <partition>/lib/rfsa.  Ideally these would go in /vendor/dsp, but the /vendor/lib/rfsa paths
+	// are hardcoded in too many places outside of the application processor.  They could be moved
+	// to /vendor/dsp once that is cleaned up.
+	{Name: "prebuilt_rfsa", InstallDir: "lib/rfsa"},
+}
+
+// RegisterPrebuiltEtcType registers a module type that installs a prebuilt file as described by t.
+func RegisterPrebuiltEtcType(ctx android.RegistrationContext, t PrebuiltEtcType) {
+	ctx.RegisterModuleType(t.Name, func() android.Module {
+		return newPrebuiltEtcTypeModule(t)
+	})
+}
+
+func prebuiltEtcTypeFactory(name string) android.Module {
+	for _, t := range prebuiltEtcTypes {
+		if t.Name == name {
+			return newPrebuiltEtcTypeModule(t)
+		}
+	}
+	panic("unknown prebuilt etc type " + name)
+}
+
+func newPrebuiltEtcTypeModule(t PrebuiltEtcType) *PrebuiltEtc {
+	module := &PrebuiltEtc{}
+	module.socInstallDirBase = t.SocInstallDir
+	module.allowedExtensions = t.Extensions
+	module.allowedPartitions = t.Partitions
+	InitPrebuiltEtcModule(module, t.InstallDir)
+	if t.Host {
+		// This module is host-only
+		android.InitAndroidArchModule(module, android.HostSupported, android.MultilibCommon)
+	} else {
+		// This module is device-only
+		android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
+	}
+	android.InitDefaultableModule(module)
+	if t.Bazel {
+		android.InitBazelModule(module)
+	}
+	return module
+}
+
+// checkPrebuiltEtcType reports an error if the src or the partition of the module are not allowed
+// by its type.
+func (p *PrebuiltEtc) checkPrebuiltEtcType(ctx android.ModuleContext) {
+	if len(p.allowedExtensions) > 0 && p.sourceFilePath != nil {
+		if ext := filepath.Ext(p.sourceFilePath.Base()); !android.InList(ext, p.allowedExtensions) {
+			ctx.PropertyErrorf("src", "%q has extension %q, %s only allows %s", p.sourceFilePath.Rel(),
+				ext, ctx.ModuleType(), strings.Join(p.allowedExtensions, ", "))
+		}
+	}
+	if len(p.allowedPartitions) > 0 {
+		if partition := p.PartitionTag(ctx.DeviceConfig()); !android.InList(partition, p.allowedPartitions) {
+			ctx.ModuleErrorf("cannot be installed in the %s partition, %s is only allowed in %s",
+				partition, ctx.ModuleType(), strings.Join(p.allowedPartitions, ", "))
+		}
+	}
+}
+
+// The directories that the install directories of the product types must be below.
+var productTypeAllowedDirs = []string{
+	"etc",
+	"firmware",
+	"fonts",
+	"lib/rfsa",
+	"usr/share",
+}
+
+type prebuiltProductTypeProperties struct {
+	// The name of the product type, from the PrebuiltProductTypes product variable, whose install
+	// directory the file is installed in.
+	Product_type *string
+}
+
+// prebuilt_product_type installs a file in the install directory of a type defined by the product
+// with the PrebuiltProductTypes product variable.
+func PrebuiltProductTypeFactory() android.Module {
+	module := &PrebuiltEtc{}
+	module.productTypeProperties = &prebuiltProductTypeProperties{}
+	InitPrebuiltEtcModule(module, "")
+	module.AddProperties(module.productTypeProperties)
+	// This module is device-only
+	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibFirst)
+	android.InitDefaultableModule(module)
+	return module
+}
+
+// productTypeInstallDir returns the install directory of the product type of the module.
+func (p *PrebuiltEtc) productTypeInstallDir(ctx android.ModuleContext) string {
+	productType := android.String(p.productTypeProperties.Product_type)
+	types := ctx.Config().PrebuiltProductTypes()
+	dir, ok := types[productType]
+	if !ok {
+		var names []string
+		for name := range types {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		ctx.PropertyErrorf("product_type", "unknown product type %q, the product defines %q",
+			productType, names)
+		return ""
+	}
+	if filepath.IsAbs(dir) || filepath.Clean(dir) != dir || !underAnyDir(dir, productTypeAllowedDirs) {
+		ctx.PropertyErrorf("product_type", "install directory %q of product type %q must be below one of %q",
+			dir, productType, productTypeAllowedDirs)
+		return ""
+	}
+	return dir
+}
+
+func underAnyDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etc
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForPrebuiltEtcTypeTest = android.GroupFixturePreparers(
+	prepareForPrebuiltEtcTest,
+	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		RegisterPrebuiltEtcType(ctx, PrebuiltEtcType{
+			Name:       "prebuilt_alsa",
+			InstallDir: "usr/share/alsa",
+			Extensions: []string{".conf"},
+			Partitions: []string{"vendor"},
+		})
+	}),
+	android.FixtureMergeMockFs(android.MockFS{
+		"foo.txt": nil,
+	}),
+)
+
+func TestPrebuiltEtcType(t *testing.T) {
+	result := prepareForPrebuiltEtcTypeTest.RunTestWithBp(t, `
+		prebuilt_alsa {
+			name: "foo.conf",
+			src: "foo.conf",
+			sub_dir: "cards",
+			vendor: true,
+			dist: {
+				targets: ["droidcore"],
+			},
+		}
+	`)
+
+	p := result.Module("foo.conf", "android_arm64_armv8-a").(*PrebuiltEtc)
+	expected := "out/soong/target/product/test_device/vendor/usr/share/alsa/cards"
+	android.AssertPathRelativeToTopEquals(t, "install dir", expected, p.installDirPath)
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, p)[0]
+	android.AssertStringEquals(t, "LOCAL_MODULE_PATH", expected,
+		android.StringRelativeToTop(result.Config, entries.EntryMap["LOCAL_MODULE_PATH"][0]))
+	android.AssertDeepEquals(t, "LOCAL_SOONG_MODULE_TYPE", []string{"prebuilt_alsa"},
+		entries.EntryMap["LOCAL_SOONG_MODULE_TYPE"])
+
+	dist := android.StringRelativeToTop(result.Config, strings.Join(entries.GetDistForGoals(p), "\n"))
+	android.AssertStringDoesContain(t, "dist", dist,
+		"$(call dist-for-goals,droidcore,out/soong/.intermediates/foo.conf/android_arm64_armv8-a/foo.conf:foo.conf)")
+}
+
+func TestPrebuiltEtcTypeErrors(t *testing.T) {
+	prepareForPrebuiltEtcTypeTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			`src: "foo.txt" has extension ".txt", prebuilt_alsa only allows .conf`,
+			`cannot be installed in the system partition, prebuilt_alsa is only allowed in vendor`,
+		})).
+		RunTestWithBp(t, `
+			prebuilt_alsa {
+				name: "foo.txt",
+				src: "foo.txt",
+				vendor: true,
+			}
+
+			prebuilt_alsa {
+				name: "bar.conf",
+				src: "bar.conf",
+			}
+		`)
+}
+
+func TestPrebuiltProductType(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForPrebuiltEtcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.PrebuiltProductTypes = map[string]string{
+				"alsa": "usr/share/alsa",
+			}
+		}),
+	).RunTestWithBp(t, `
+		prebuilt_product_type {
+			name: "foo.conf",
+			src: "foo.conf",
+			product_type: "alsa",
+			sub_dir: "cards",
+		}
+	`)
+
+	p := result.Module("foo.conf", "android_arm64_armv8-a").(*PrebuiltEtc)
+	expected := "out/soong/target/product/test_device/system/usr/share/alsa/cards"
+	android.AssertPathRelativeToTopEquals(t, "install dir", expected, p.installDirPath)
+}
+
+func TestPrebuiltProductTypeErrors(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForPrebuiltEtcTest,
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.PrebuiltProductTypes = map[string]string{
+				"alsa":   "usr/share/alsa",
+				"bin":    "bin",
+				"escape": "etc/../bin",
+			}
+		}),
+	).ExtendWithErrorHandler(android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+		`product_type: install directory "bin" of product type "bin" must be below one of`,
+		`product_type: install directory "etc/../bin" of product type "escape" must be below one of`,
+		`product_type: unknown product type "missing", the product defines \["alsa" "bin" "escape"\]`,
+	})).RunTestWithBp(t, `
+		prebuilt_product_type {
+			name: "foo.conf",
+			src: "foo.conf",
+			product_type: "bin",
+		}
+
+		prebuilt_product_type {
+			name: "bar.conf",
+			src: "bar.conf",
+			product_type: "escape",
+		}
+
+		prebuilt_product_type {
+			name: "baz.conf",
+			src: "baz.conf",
+			product_type: "missing",
+		}
+	`)
+}
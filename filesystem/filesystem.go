@@ -176,6 +176,8 @@ func (f *filesystem) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	f.installDir = android.PathForModuleInstall(ctx, "etc")
 	ctx.InstallFile(f.installDir, f.installFileName(), f.output)
+
+	f.WriteRequiredDepsReport(ctx, f.gatherFilteredPackagingSpecs(ctx))
 }
 
 // root zip will contain extra files/dirs that are not from the `deps` property.
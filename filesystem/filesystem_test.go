@@ -15,6 +15,7 @@
 package filesystem
 
 import (
+	"fmt"
 	"os"
 	"testing"
 
@@ -130,6 +131,63 @@ func TestFileSystemGathersItemsOnlyInSystemPartition(t *testing.T) {
 	android.AssertDeepEquals(t, "entries should have foo only", []string{"components/foo"}, module.entries)
 }
 
+func TestFileSystemIncludesRequiredDeps(t *testing.T) {
+	bp := `
+		android_filesystem {
+			name: "myfilesystem",
+			multilib: {
+				first: {
+					deps: ["foo"],
+				},
+			},
+			include_required_deps: %t,
+		}
+
+		cc_binary {
+			name: "foo",
+			required: ["foo.conf"],
+			target_required: ["bar.conf"],
+			host_required: ["baz.conf"],
+		}
+
+		prebuilt_etc {
+			name: "foo.conf",
+			src: "foo.conf",
+			// A cycle of required modules is allowed.
+			required: ["foo"],
+		}
+
+		prebuilt_etc {
+			name: "bar.conf",
+			src: "bar.conf",
+		}
+
+		prebuilt_etc {
+			name: "baz.conf",
+			src: "baz.conf",
+		}
+	`
+
+	for _, includeRequiredDeps := range []bool{false, true} {
+		result := fixture.RunTestWithBp(t, fmt.Sprintf(bp, includeRequiredDeps))
+
+		m := result.ModuleForTests("myfilesystem", "android_common")
+		module := m.Module().(*filesystem)
+		android.AssertStringListContains(t, "entries should have foo", module.entries, "bin/foo")
+		android.AssertStringListContainsEquals(t, "entries should have foo.conf", module.entries, "etc/foo.conf", includeRequiredDeps)
+		android.AssertStringListContainsEquals(t, "entries should have bar.conf", module.entries, "etc/bar.conf", includeRequiredDeps)
+		android.AssertStringListDoesNotContain(t, "entries should not have baz.conf", module.entries, "etc/baz.conf")
+
+		if includeRequiredDeps {
+			report := android.ContentFromFileRuleForTests(t, m.Output("required_deps.txt"))
+			android.AssertStringEquals(t, "required deps report",
+				"etc/bar.conf: bar.conf required by foo\netc/foo.conf: foo.conf required by foo", report)
+		} else {
+			android.AssertBoolEquals(t, "required deps report", true, m.MaybeOutput("required_deps.txt").Rule == nil)
+		}
+	}
+}
+
 func TestAvbGenVbmetaImage(t *testing.T) {
 	result := fixture.RunTestWithBp(t, `
 		avb_gen_vbmeta_image {
@@ -397,6 +397,14 @@ func GetFramework(ctx android.LoadHookContext, lang Lang) Framework {
 	return UnknownFramework
 }
 
+// IsCoverageBuild returns true if FUZZ_COVERAGE_BUILD is set to true, in which case cc and rust fuzz
+// targets are built with coverage instrumentation instead of the fuzzing sanitizer, e.g. to
+// distill fuzz corpora, and their packages are named so that they can be distributed alongside the
+// fuzzing ones.
+func IsCoverageBuild(config android.Config) bool {
+	return config.IsEnvTrue("FUZZ_COVERAGE_BUILD")
+}
+
 func IsValidFrameworkForModule(targetFramework Framework, lang Lang, moduleFrameworks *FuzzFrameworks) bool {
 	if targetFramework == UnknownFramework {
 		return false
@@ -528,13 +536,16 @@ func (s *FuzzPackager) CreateFuzzPackage(ctx android.SingletonContext, archDirs
 		arch := archOs.Arch
 		hostOrTarget := archOs.HostOrTarget
 		builder := android.NewRuleBuilder(pctx, ctx)
-		zipFileName := "fuzz-" + hostOrTarget + "-" + arch + ".zip"
+		zipFilePrefix := "fuzz-"
 		if fuzzType == Rust {
-			zipFileName = "fuzz-rust-" + hostOrTarget + "-" + arch + ".zip"
+			zipFilePrefix = "fuzz-rust-"
 		}
 		if fuzzType == Java {
-			zipFileName = "fuzz-java-" + hostOrTarget + "-" + arch + ".zip"
+			zipFilePrefix = "fuzz-java-"
+		} else if IsCoverageBuild(ctx.Config()) {
+			zipFilePrefix += "coverage-"
 		}
+		zipFileName := zipFilePrefix + hostOrTarget + "-" + arch + ".zip"
 
 		outputFile := android.PathForOutput(ctx, zipFileName)
 
@@ -126,6 +126,9 @@ type generatorProperties struct {
 	//  $(location): the path to the first entry in tools or tool_files.
 	//  $(location <label>): the path to the tool, tool_file, input or output with name <label>. Use $(location) if <label> refers to a rule that outputs exactly one file.
 	//  $(locations <label>): the paths to the tools, tool_files, inputs or outputs with name <label>. Use $(locations) if <label> refers to a rule that outputs two or more files.
+	//  $(dir <label>): the directory of the single tool, tool_file, input or output with name <label>.
+	//  $(basename <label>): the file name, without its directory, of the single tool, tool_file, input or output with name <label>.
+	//  $(ext_stripped <label>): the path without its extension of the single tool, tool_file, input or output with name <label>.
 	//  $(in): one or more input files.
 	//  $(out): a single output file.
 	//  $(depfile): a file to which dependencies will be written, if the depfile property is set to true.
@@ -151,6 +154,15 @@ type generatorProperties struct {
 
 	// input files to exclude
 	Exclude_srcs []string `android:"path,arch_variant"`
+
+	// Track the files read by the command and fail if it reads any file that is not listed in
+	// srcs, tools or tool_files.  Also enabled for modules in directories listed in the
+	// GenruleStrictSandboxPaths product variable.
+	Strict_sandbox *bool
+
+	// Files relative to the module directory that the command may read without listing them in
+	// srcs when strict_sandbox is enabled.  Only intended for known offenders that are being fixed.
+	Strict_sandbox_allowlist []string
 }
 
 type Module struct {
@@ -241,6 +253,24 @@ func (g *Module) OutputFiles(tag string) (android.Paths, error) {
 var _ android.SourceFileProducer = (*Module)(nil)
 var _ android.OutputFileProducer = (*Module)(nil)
 
+// cmdPathFunctions are the functions that can be used in cmd as $(<function> <label>) and that
+// take the single path of the label.  They are evaluated on the path in the sandbox, so the result
+// is rewritten by sbox like the path itself.
+var cmdPathFunctions = map[string]func(path string) string{
+	"location": func(path string) string { return path },
+	// $(dir <label>) is the directory that contains the file.
+	"dir": filepath.Dir,
+	// $(basename <label>) is the name of the file without its directory.
+	"basename": filepath.Base,
+	// $(ext_stripped <label>) is the path of the file without its extension.
+	"ext_stripped": func(path string) string { return strings.TrimSuffix(path, filepath.Ext(path)) },
+}
+
+// cmdFunctionNames returns the sorted names of the functions that can be used in cmd.
+func cmdFunctionNames() []string {
+	return android.SortedUniqueStrings(append(android.SortedKeys(cmdPathFunctions), "locations"))
+}
+
 func toolDepsMutator(ctx android.BottomUpMutatorContext) {
 	if g, ok := ctx.Module().(*Module); ok {
 		for _, tool := range g.properties.Tools {
@@ -277,6 +307,14 @@ func (g *Module) ProcessBazelQueryResponse(ctx android.ModuleContext) {
 	}
 }
 
+// strictSandbox returns true if the genrule's command should fail when it reads undeclared inputs.
+func (g *Module) strictSandbox(ctx android.ModuleContext) bool {
+	if g.properties.Strict_sandbox != nil {
+		return *g.properties.Strict_sandbox
+	}
+	return ctx.Config().GenruleStrictSandboxEnabledForPath(ctx.ModuleDir())
+}
+
 // generateCommonBuildActions contains build action generation logic
 // common to both the mixed build case and the legacy case of genrule processing.
 // To fully support genrule in mixed builds, the contents of this function should
@@ -453,6 +491,13 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 
 		// Use a RuleBuilder to create a rule that runs the command inside an sbox sandbox.
 		rule := android.NewRuleBuilder(pctx, ctx).Sbox(task.genDir, manifestPath).SandboxTools()
+		if g.strictSandbox(ctx) {
+			var allowlist []string
+			for _, path := range g.properties.Strict_sandbox_allowlist {
+				allowlist = append(allowlist, filepath.Join(ctx.ModuleDir(), path))
+			}
+			rule.StrictInputs(ctx.ModuleDir(), allowlist)
+		}
 		cmd := rule.Command()
 
 		for _, out := range task.out {
@@ -501,34 +546,32 @@ func (g *Module) generateCommonBuildActions(ctx android.ModuleContext) {
 			case "genDir":
 				return proptools.ShellEscape(cmd.PathForOutput(task.genDir)), nil
 			default:
-				if strings.HasPrefix(name, "location ") {
-					label := strings.TrimSpace(strings.TrimPrefix(name, "location "))
-					if loc, ok := locationLabels[label]; ok {
-						paths := loc.Paths(cmd)
-						if len(paths) == 0 {
-							return reportError("label %q has no files", label)
-						} else if len(paths) > 1 {
-							return reportError("label %q has multiple files, use $(locations %s) to reference it",
-								label, label)
-						}
-						return proptools.ShellEscape(paths[0]), nil
-					} else {
-						return reportError("unknown location label %q is not in srcs, out, tools or tool_files.", label)
-					}
-				} else if strings.HasPrefix(name, "locations ") {
-					label := strings.TrimSpace(strings.TrimPrefix(name, "locations "))
-					if loc, ok := locationLabels[label]; ok {
-						paths := loc.Paths(cmd)
-						if len(paths) == 0 {
-							return reportError("label %q has no files", label)
-						}
-						return proptools.ShellEscape(strings.Join(paths, " ")), nil
-					} else {
-						return reportError("unknown locations label %q is not in srcs, out, tools or tool_files.", label)
-					}
-				} else {
+				fields := strings.SplitN(name, " ", 2)
+				if len(fields) == 1 {
 					return reportError("unknown variable '$(%s)'", name)
 				}
+				function, label := fields[0], strings.TrimSpace(fields[1])
+				pathFunc, ok := cmdPathFunctions[function]
+				if !ok && function != "locations" {
+					return reportError("unknown function %q in '$(%s)', valid functions are %s",
+						function, name, strings.Join(cmdFunctionNames(), ", "))
+				}
+				loc, ok := locationLabels[label]
+				if !ok {
+					return reportError("unknown %s label %q is not in srcs, out, tools or tool_files.", function, label)
+				}
+				paths := loc.Paths(cmd)
+				if len(paths) == 0 {
+					return reportError("label %q has no files", label)
+				}
+				if function == "locations" {
+					return proptools.ShellEscape(strings.Join(paths, " ")), nil
+				}
+				if len(paths) > 1 {
+					return reportError("label %q has multiple files, use $(locations %s) to reference it",
+						label, label)
+				}
+				return proptools.ShellEscape(pathFunc(paths[0])), nil
 			}
 		})
 
@@ -284,6 +284,35 @@ func TestGenruleCmd(t *testing.T) {
 			`,
 			expect: "echo foo > __SBOX_SANDBOX_DIR__/out/out2",
 		},
+		{
+			name: "dir",
+			prop: `
+				tools: ["tool"],
+				srcs: ["in1"],
+				out: ["sub/out.h"],
+				cmd: "$(location tool) -C $(dir tool) -I $(dir in1) -o $(dir sub/out.h)",
+			`,
+			expect: "__SBOX_SANDBOX_DIR__/tools/out/bin/tool -C __SBOX_SANDBOX_DIR__/tools/out/bin -I . -o __SBOX_SANDBOX_DIR__/out/sub",
+		},
+		{
+			name: "basename",
+			prop: `
+				tool_files: ["tool_file1"],
+				srcs: [":1in"],
+				out: ["sub/out.h"],
+				cmd: "$(location) --name=$(basename :1in) --tool=$(basename tool_file1) > $(out) && echo $(basename sub/out.h)",
+			`,
+			expect: "__SBOX_SANDBOX_DIR__/tools/src/tool_file1 --name=in1 --tool=tool_file1 > __SBOX_SANDBOX_DIR__/out/sub/out.h && echo out.h",
+		},
+		{
+			name: "ext_stripped",
+			prop: `
+				srcs: ["in1.txt"],
+				out: ["sub/out.h"],
+				cmd: "cp $(location in1.txt) $(ext_stripped sub/out.h).tmp && mv $(ext_stripped sub/out.h).tmp $(out) # $(ext_stripped in1.txt)",
+			`,
+			expect: "cp in1.txt __SBOX_SANDBOX_DIR__/out/sub/out.tmp && mv __SBOX_SANDBOX_DIR__/out/sub/out.tmp __SBOX_SANDBOX_DIR__/out/sub/out.h # in1",
+		},
 		{
 			name: "depfile",
 			prop: `
@@ -388,6 +417,32 @@ func TestGenruleCmd(t *testing.T) {
 			`,
 			err: `unknown variable '$(foo)'`,
 		},
+		{
+			name: "error function",
+			prop: `
+					out: ["out"],
+					srcs: ["in1"],
+					cmd: "echo $(dirname in1) > $(out)",
+			`,
+			err: `unknown function "dirname" in '$(dirname in1)', valid functions are basename, dir, ext_stripped, location, locations`,
+		},
+		{
+			name: "error function unknown label",
+			prop: `
+					out: ["out"],
+					cmd: "echo $(dir missing) > $(out)",
+			`,
+			err: `unknown dir label "missing" is not in srcs, out, tools or tool_files.`,
+		},
+		{
+			name: "error function multiple files",
+			prop: `
+					out: ["out"],
+					srcs: [":ins"],
+					cmd: "echo $(basename :ins) > $(out)",
+			`,
+			err: `label ":ins" has multiple files, use $(locations :ins) to reference it`,
+		},
 		{
 			name: "error depfile",
 			prop: `
@@ -540,6 +595,73 @@ func TestGenruleHashInputs(t *testing.T) {
 	}
 }
 
+func TestGenruleStrictSandbox(t *testing.T) {
+	bp := `
+			genrule {
+				name: "strict",
+				srcs: ["in1.txt"],
+				tools: ["tool"],
+				out: ["out"],
+				cmd: "$(location) $(in) > $(out)",
+				strict_sandbox: true,
+				strict_sandbox_allowlist: ["in2.txt"],
+			}
+			genrule {
+				name: "not_strict",
+				srcs: ["in1.txt"],
+				out: ["out"],
+				cmd: "cat $(in) > $(out)",
+			}
+		`
+
+	t.Run("property", func(t *testing.T) {
+		result := prepareForGenRuleTest.RunTestWithBp(t, testGenruleBp()+bp)
+
+		manifest := android.RuleBuilderSboxProtoForTests(t,
+			result.ModuleForTests("strict", "").Output("genrule.sbox.textproto"))
+		command := manifest.Commands[0]
+		android.AssertBoolEquals(t, "strict_inputs", true, command.GetStrictInputs())
+		android.AssertStringListContains(t, "declared_inputs", command.GetDeclaredInputs(), "in1.txt")
+		android.AssertIntEquals(t, "declared_inputs count", 2, len(command.GetDeclaredInputs()))
+		android.AssertDeepEquals(t, "allowed_undeclared_inputs", []string{"in2.txt"},
+			command.GetAllowedUndeclaredInputs())
+		android.AssertStringEquals(t, "module_dir", ".", command.GetModuleDir())
+
+		manifest = android.RuleBuilderSboxProtoForTests(t,
+			result.ModuleForTests("not_strict", "").Output("genrule.sbox.textproto"))
+		android.AssertBoolEquals(t, "strict_inputs", false, manifest.Commands[0].GetStrictInputs())
+	})
+
+	t.Run("product path", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForGenRuleTest,
+			android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+				variables.GenruleStrictSandboxPaths = []string{"."}
+			}),
+		).RunTestWithBp(t, testGenruleBp()+bp)
+
+		manifest := android.RuleBuilderSboxProtoForTests(t,
+			result.ModuleForTests("not_strict", "").Output("genrule.sbox.textproto"))
+		android.AssertBoolEquals(t, "strict_inputs", true, manifest.Commands[0].GetStrictInputs())
+		android.AssertDeepEquals(t, "declared_inputs", []string{"in1.txt"},
+			manifest.Commands[0].GetDeclaredInputs())
+	})
+
+	t.Run("darwin host", func(t *testing.T) {
+		result := android.GroupFixturePreparers(
+			prepareForGenRuleTest,
+			android.FixtureModifyConfig(func(config android.Config) {
+				config.BuildOS = android.Darwin
+			}),
+		).RunTestWithBp(t, testGenruleBp()+bp)
+
+		manifest := android.RuleBuilderSboxProtoForTests(t,
+			result.ModuleForTests("strict", "").Output("genrule.sbox.textproto"))
+		android.AssertBoolEquals(t, "strict_inputs", false, manifest.Commands[0].GetStrictInputs())
+		android.AssertIntEquals(t, "declared_inputs count", 0, len(manifest.Commands[0].GetDeclaredInputs()))
+	})
+}
+
 func TestGenSrcs(t *testing.T) {
 	testcases := []struct {
 		name string
@@ -790,6 +912,29 @@ func TestGenruleOutputFiles(t *testing.T) {
 		result.ModuleForTests("gen_all", "").Module().(*useSource).srcs)
 }
 
+func TestGenruleCmdFunctionsWithOutputTag(t *testing.T) {
+	bp := `
+				genrule {
+					name: "gen",
+					out: ["foo", "sub/bar.h"],
+					cmd: "echo foo > $(location foo) && echo bar > $(location sub/bar.h)",
+				}
+				genrule {
+					name: "use_gen",
+					srcs: [":gen{sub/bar.h}"],
+					out: ["out"],
+					cmd: "cp $(location :gen{sub/bar.h}) -I $(dir :gen{sub/bar.h}) $(basename :gen{sub/bar.h}) $(ext_stripped :gen{sub/bar.h}) > $(out)",
+				}
+			`
+
+	result := prepareForGenRuleTest.RunTestWithBp(t, testGenruleBp()+bp)
+	gen := result.Module("use_gen", "").(*Module)
+	android.AssertStringEquals(t, "raw commands",
+		"cp out/soong/.intermediates/gen/gen/sub/bar.h -I out/soong/.intermediates/gen/gen/sub bar.h "+
+			"out/soong/.intermediates/gen/gen/sub/bar > __SBOX_SANDBOX_DIR__/out/out",
+		android.StringRelativeToTop(result.Config, gen.rawCommands[0]))
+}
+
 func TestGenSrcsWithNonRootAndroidBpOutputFiles(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForGenRuleTest,
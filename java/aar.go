@@ -467,6 +467,11 @@ func aaptLibs(ctx android.ModuleContext, sdkContext android.SdkContext, classLoa
 			if exportPackage != nil {
 				sharedLibs = append(sharedLibs, exportPackage)
 			}
+		case appFeatureBaseAppTag:
+			// Features link against the resource table of their base app.
+			if info, ok := ctx.OtherModuleProvider(module, AppPackageInfoProvider).(AppPackageInfo); ok {
+				sharedLibs = append(sharedLibs, info.ResourcePackage)
+			}
 		case staticLibTag:
 			if exportPackage != nil {
 				transitiveStaticLibs = append(transitiveStaticLibs, aarDep.ExportedStaticPackages()...)
@@ -521,6 +521,7 @@ func (jd *Javadoc) AndroidMkEntries() []android.AndroidMkEntries {
 	return []android.AndroidMkEntries{android.AndroidMkEntries{
 		Class:      "JAVA_LIBRARIES",
 		OutputFile: android.OptionalPathForPath(jd.stubsSrcJar),
+		DistFiles:  android.MakeDefaultDistFiles(jd.distDocZip),
 		Include:    "$(BUILD_SYSTEM)/soong_droiddoc_prebuilt.mk",
 		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
 			func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
@@ -539,6 +540,7 @@ func (ddoc *Droiddoc) AndroidMkEntries() []android.AndroidMkEntries {
 	return []android.AndroidMkEntries{android.AndroidMkEntries{
 		Class:      "JAVA_LIBRARIES",
 		OutputFile: android.OptionalPathForPath(ddoc.Javadoc.docZip),
+		DistFiles:  android.MakeDefaultDistFiles(ddoc.Javadoc.distDocZip),
 		Include:    "$(BUILD_SYSTEM)/soong_droiddoc_prebuilt.mk",
 		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
 			func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
@@ -722,6 +724,26 @@ func (r *RuntimeResourceOverlay) AndroidMkEntries() []android.AndroidMkEntries {
 	}}
 }
 
+func (f *AndroidAppFeature) AndroidMkEntries() []android.AndroidMkEntries {
+	return []android.AndroidMkEntries{android.AndroidMkEntries{
+		Class:      "APPS",
+		Disabled:   f.outputFile == nil,
+		OutputFile: android.OptionalPathForPath(f.outputFile),
+		Include:    "$(BUILD_SYSTEM)/soong_app_prebuilt.mk",
+		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+				entries.SetString("LOCAL_CERTIFICATE", f.certificate.AndroidMkString())
+				if f.IsSkipInstall() {
+					entries.SetBool("LOCAL_UNINSTALLABLE_MODULE", true)
+				} else {
+					entries.SetPath("LOCAL_MODULE_PATH", f.installDir)
+					entries.SetString("LOCAL_INSTALLED_MODULE_STEM", f.outputFile.Base())
+				}
+			},
+		},
+	}}
+}
+
 func (apkSet *AndroidAppSet) AndroidMkEntries() []android.AndroidMkEntries {
 	return []android.AndroidMkEntries{
 		android.AndroidMkEntries{
@@ -543,15 +543,27 @@ func (a *AndroidApp) JNISymbolsInstalls(installPath string) android.RuleBuilderI
 
 // Reads and prepends a main cert from the default cert dir if it hasn't been set already, i.e. it
 // isn't a cert module reference. Also checks and enforces system cert restriction if applicable.
+//
+// The certificate property follows the precedence of android.PathForModuleSrcOrModule: a ":module"
+// reference refers to the android_app_certificate module, any other value refers to the files in
+// the default cert dir, and is an error if the files don't exist or the value is also the name of a
+// module.
 func processMainCert(m android.ModuleBase, certPropValue string, certificates []Certificate,
 	ctx android.ModuleContext) (mainCertificate Certificate, allCertificates []Certificate) {
 	if android.SrcIsModule(certPropValue) == "" {
 		var mainCert Certificate
 		if certPropValue != "" {
 			defaultDir := ctx.Config().DefaultAppCertificateDir(ctx)
+			pem := defaultDir.Join(ctx, certPropValue+".x509.pem")
+			key := defaultDir.Join(ctx, certPropValue+".pk8")
+			if android.CheckSrcOrModuleReference(ctx, certPropValue, pem) {
+				// Report missing certificate files.
+				pem = android.PathForSource(ctx, pem.String())
+				key = android.PathForSource(ctx, key.String())
+			}
 			mainCert = Certificate{
-				Pem: defaultDir.Join(ctx, certPropValue+".x509.pem"),
-				Key: defaultDir.Join(ctx, certPropValue+".pk8"),
+				Pem: pem,
+				Key: key,
 			}
 		} else {
 			pem, key := ctx.Config().DefaultAppCertificate(ctx)
@@ -687,11 +699,13 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	if v4SigningRequested {
 		v4SignatureFile = android.PathForModuleOut(ctx, a.installApkName+".apk.idsig")
 	}
-	var lineageFile android.Path
+	lineageFile, rotationMinSdkVersion := mainCertificateLineage(ctx, a.getCertString(ctx))
 	if lineage := String(a.overridableAppProperties.Lineage); lineage != "" {
 		lineageFile = android.PathForModuleSrc(ctx, lineage)
 	}
-	rotationMinSdkVersion := String(a.overridableAppProperties.RotationMinSdkVersion)
+	if v := String(a.overridableAppProperties.RotationMinSdkVersion); v != "" {
+		rotationMinSdkVersion = v
+	}
 
 	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, Bool(a.dexProperties.Optimize.Shrink_resources))
 	a.outputFile = packageFile
@@ -737,8 +751,9 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
 
 	// Install the app package.
-	if (Bool(a.Module.properties.Installable) || ctx.Host()) && apexInfo.IsForPlatform() &&
-		!a.appProperties.PreventInstall {
+	installed := (Bool(a.Module.properties.Installable) || ctx.Host()) && apexInfo.IsForPlatform() &&
+		!a.appProperties.PreventInstall
+	if installed {
 
 		var extraInstalledPaths android.Paths
 		for _, extra := range a.extraOutputFiles {
@@ -748,9 +763,44 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 		ctx.InstallFile(a.installDir, a.outputFile.Base(), a.outputFile, extraInstalledPaths...)
 	}
 
+	ctx.SetProvider(AppPackageInfoProvider, AppPackageInfo{
+		ResourcePackage:       a.exportPackage,
+		Certificates:          certificates,
+		Lineage:               lineageFile,
+		RotationMinSdkVersion: rotationMinSdkVersion,
+		InstallApkName:        a.installApkName,
+		InstallDir:            a.installDir,
+		Installed:             installed,
+	})
+
 	a.buildAppDependencyInfo(ctx)
 }
 
+// AppPackageInfo is provided by android_app modules for the android_app_feature modules that build
+// the feature splits of the app.
+type AppPackageInfo struct {
+	// The package with the resource table of the app, which the features link against.
+	ResourcePackage android.Path
+
+	// The certificates that the app is signed with, the main certificate first.
+	Certificates []Certificate
+
+	// The signing certificate lineage file of the app, if any.
+	Lineage android.Path
+
+	// The --rotation-min-sdk-version of the app, if any.
+	RotationMinSdkVersion string
+
+	// The name of the installed APK of the app, without the extension.
+	InstallApkName string
+
+	// The directory that the app is installed in, and whether it is installed.
+	InstallDir android.InstallPath
+	Installed  bool
+}
+
+var AppPackageInfoProvider = blueprint.NewProvider(AppPackageInfo{})
+
 type appDepsInterface interface {
 	SdkVersion(ctx android.EarlyModuleContext) android.SdkSpec
 	MinSdkVersion(ctx android.EarlyModuleContext) android.ApiLevel
@@ -832,6 +882,21 @@ func collectAppDeps(ctx android.ModuleContext, app appDepsInterface,
 	return jniLibs, prebuiltJniPackages, certificates
 }
 
+// mainCertificateLineage returns the signing certificate lineage and rotation min sdk version of
+// the android_app_certificate module that the certificate property refers to, if any.
+func mainCertificateLineage(ctx android.ModuleContext, certPropValue string) (lineage android.Path, rotationMinSdkVersion string) {
+	certModule := android.SrcIsModule(certPropValue)
+	if certModule == "" {
+		return nil, ""
+	}
+	ctx.VisitDirectDepsWithTag(certificateTag, func(module android.Module) {
+		if dep, ok := module.(*AndroidAppCertificate); ok && ctx.OtherModuleName(module) == certModule {
+			lineage, rotationMinSdkVersion = dep.lineage, dep.rotationMinSdkVersion
+		}
+	})
+	return lineage, rotationMinSdkVersion
+}
+
 func (a *AndroidApp) WalkPayloadDeps(ctx android.ModuleContext, do android.PayloadDepsCallback) {
 	ctx.WalkDeps(func(child, parent android.Module) bool {
 		isExternal := !a.DepIsInSameApex(ctx, child)
@@ -1043,6 +1108,17 @@ func (a *AndroidTest) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	a.testConfig = a.FixTestConfig(ctx, testConfig)
 	a.extraTestConfigs = android.PathsForModuleSrc(ctx, a.testProperties.Test_options.Extra_test_configs)
 	a.data = android.PathsForModuleSrc(ctx, a.testProperties.Data)
+
+	var data []android.DataPath
+	for _, path := range a.data {
+		data = append(data, android.DataPath{SrcPath: path})
+	}
+	ctx.SetProvider(tradefed.BaseTestProviderKey, tradefed.BaseTestProviderData{
+		OutputFile:     a.outputFile,
+		TestConfig:     a.testConfig,
+		Data:           data,
+		AndroidMkClass: "APPS",
+	})
 }
 
 func (a *AndroidTest) FixTestConfig(ctx android.ModuleContext, testConfig android.Path) android.Path {
@@ -1136,10 +1212,70 @@ type appTestHelperAppProperties struct {
 	Per_testcase_directory *bool
 }
 
+type appTestHelperAppTargetSdkProperties struct {
+	// list of target sdk versions, e.g. "29" or "current", to build additional variants of the app
+	// for, to test behavior changes between them.  Each variant is an android_test_helper_app module
+	// named <name>_targetsdk_<version> with the same properties as this module except for
+	// target_sdk_version, and is installed into the same test suites.
+	Target_sdk_versions []string
+}
+
 type AndroidTestHelperApp struct {
 	AndroidApp
 
 	appTestHelperAppProperties appTestHelperAppProperties
+
+	targetSdkProperties appTestHelperAppTargetSdkProperties
+}
+
+// targetSdkVariantName returns the name of the variant of the module with the target sdk version.
+func targetSdkVariantName(name, targetSdkVersion string) string {
+	return name + "_targetsdk_" + targetSdkVersion
+}
+
+// createTargetSdkVariants creates an android_test_helper_app module for each of the
+// target_sdk_versions, with the properties of this module after its defaults have been applied,
+// including their arch variants.
+func (a *AndroidTestHelperApp) createTargetSdkVariants(ctx android.DefaultableHookContext) {
+	// The properties that are not inherited by CreateModule from the ModuleBase, excluding
+	// target_sdk_versions so that the variants do not create variants themselves.
+	props := android.PropertiesWithArchVariants(a,
+		&a.Module.properties,
+		&a.Module.protoProperties,
+		&a.Module.usesLibraryProperties,
+		&a.Module.deviceProperties,
+		&a.Module.overridableDeviceProperties,
+		&a.Module.dexer.dexProperties,
+		&a.Module.dexpreoptProperties,
+		&a.Module.linter.properties,
+		&a.aaptProperties,
+		&a.appProperties,
+		&a.appTestHelperAppProperties,
+		&a.overridableAppProperties,
+	)
+
+	seen := make(map[string]bool)
+	for _, version := range a.targetSdkProperties.Target_sdk_versions {
+		if seen[version] {
+			ctx.PropertyErrorf("target_sdk_versions", "duplicate target sdk version %q", version)
+			continue
+		}
+		seen[version] = true
+		if _, err := android.ApiLevelFromUser(ctx, version); err != nil {
+			ctx.PropertyErrorf("target_sdk_versions", "%s", err)
+			continue
+		}
+
+		ctx.CreateModule(AndroidTestHelperAppFactory, append(props, &struct {
+			Name               *string
+			Stem               *string
+			Target_sdk_version *string
+		}{
+			Name:               proptools.StringPtr(targetSdkVariantName(ctx.ModuleName(), version)),
+			Stem:               proptools.StringPtr(targetSdkVariantName(a.Stem(), version)),
+			Target_sdk_version: proptools.StringPtr(version),
+		})...)
+	}
 }
 
 func (a *AndroidTestHelperApp) InstallInTestcases() bool {
@@ -1166,11 +1302,15 @@ func AndroidTestHelperAppFactory() android.Module {
 		&module.aaptProperties,
 		&module.appProperties,
 		&module.appTestHelperAppProperties,
-		&module.overridableAppProperties)
+		&module.overridableAppProperties,
+		&module.targetSdkProperties)
 
 	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
 	android.InitDefaultableModule(module)
 	android.InitApexModule(module)
+	module.SetDefaultableHook(func(ctx android.DefaultableHookContext) {
+		module.createTargetSdkVariants(ctx)
+	})
 	return module
 }
 
@@ -1180,11 +1320,22 @@ type AndroidAppCertificate struct {
 
 	properties  AndroidAppCertificateProperties
 	Certificate Certificate
+
+	lineage               android.Path
+	rotationMinSdkVersion string
 }
 
 type AndroidAppCertificateProperties struct {
 	// Name of the certificate files.  Extensions .x509.pem and .pk8 will be added to the name.
 	Certificate *string
+
+	// Name of the signing certificate lineage file or filegroup module, used to sign the apps that
+	// use this certificate as their main certificate and do not set lineage themselves.
+	Lineage *string `android:"path"`
+
+	// The --rotation-min-sdk-version of apksig for the apps that use this certificate as their main
+	// certificate and do not set rotationMinSdkVersion themselves.
+	RotationMinSdkVersion *string
 }
 
 // android_app_certificate modules can be referenced by the certificates property of android_app modules to select
@@ -1203,6 +1354,10 @@ func (c *AndroidAppCertificate) GenerateAndroidBuildActions(ctx android.ModuleCo
 		Pem: android.PathForModuleSrc(ctx, cert+".x509.pem"),
 		Key: android.PathForModuleSrc(ctx, cert+".pk8"),
 	}
+	if lineage := String(c.properties.Lineage); lineage != "" {
+		c.lineage = android.PathForModuleSrc(ctx, lineage)
+	}
+	c.rotationMinSdkVersion = String(c.properties.RotationMinSdkVersion)
 }
 
 type OverrideAndroidApp struct {
@@ -45,6 +45,14 @@ var (
 		}, []string{"flags", "certificates"}, []string{"implicits", "outCommaList"})
 )
 
+// verifyApkLineage checks that a signed apk verifies with apksigner, which fails if the
+// certificate it is signed with is not the last certificate of its signing certificate lineage.
+var verifyApkLineage = pctx.AndroidStaticRule("verifyApkLineage",
+	blueprint.RuleParams{
+		Command:     `${config.ApksignerCmd} verify --print-certs $in > $out`,
+		CommandDeps: []string{"${config.ApksignerCmd}"},
+	})
+
 var combineApk = pctx.AndroidStaticRule("combineApk",
 	blueprint.RuleParams{
 		Command:     `${config.MergeZipsCmd} $out $in`,
@@ -95,9 +103,21 @@ func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, u
 		flags = append(flags, "--enable-v4")
 	}
 
+	var validations android.Paths
 	if lineageFile != nil {
 		flags = append(flags, "--lineage", lineageFile.String())
 		deps = append(deps, lineageFile)
+
+		// Verify the signed apk to catch a lineage that does not match the certificates.
+		verified := android.PathForModuleOut(ctx, "lineage", signedApk.Base()+".verified")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        verifyApkLineage,
+			Description: "verify lineage",
+			Input:       signedApk,
+			Implicit:    lineageFile,
+			Output:      verified,
+		})
+		validations = append(validations, verified)
 	}
 
 	if rotationMinSdkVersion != "" {
@@ -120,6 +140,7 @@ func SignAppPackage(ctx android.ModuleContext, signedApk android.WritablePath, u
 		Outputs:     outputFiles,
 		Input:       unsignedApk,
 		Implicits:   deps,
+		Validations: validations,
 		Args:        args,
 	})
 }
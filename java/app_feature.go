@@ -0,0 +1,264 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+// This file contains the module implementation for android_app_feature, which builds a dynamic
+// feature split of an android_app.
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+func init() {
+	RegisterAppFeatureBuildComponents(android.InitRegistrationContext)
+}
+
+func RegisterAppFeatureBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("android_app_feature", AndroidAppFeatureFactory)
+	ctx.RegisterSingletonType("app_feature_package_ids", appFeaturePackageIdsSingletonFactory)
+}
+
+var appFeatureBaseAppTag = dependencyTag{name: "app-feature-base-app"}
+
+const (
+	// The package id of the resources of the base app.
+	baseAppPackageId = 0x7f
+	// The package id of the resources of a feature without a package_id.
+	defaultAppFeaturePackageId = 0x7e
+	// The package ids at or below this one are reserved for the framework and shared libraries.
+	maxReservedPackageId = 0x01
+)
+
+type AndroidAppFeatureProperties struct {
+	// the android_app module that this module is a feature split of.  The feature links against the
+	// resources of the base app, is signed with its certificate and is installed next to it.
+	Base_app *string
+
+	// the name of the feature split, which must match the split attribute of the manifest.
+	// Defaults to the name of the module.
+	Feature_name *string
+
+	// the package id of the resources of the feature, for example "0x7e".  The base app uses 0x7f,
+	// and each feature of the same base app must use a distinct package id below it.  Defaults to
+	// 0x7e.
+	Package_id *string
+
+	// If not blank, set to the version of the sdk to compile against.
+	// Defaults to compiling against the current platform.
+	Sdk_version *string
+
+	// if not blank, set the minimum version of the sdk that the compiled artifacts will run against.
+	// Defaults to sdk_version if not set.
+	Min_sdk_version *string
+
+	// list of android_library modules whose resources are extracted and linked against statically
+	Static_libs []string
+}
+
+type AndroidAppFeature struct {
+	android.ModuleBase
+	android.DefaultableModuleBase
+	aapt
+
+	properties AndroidAppFeatureProperties
+
+	certificate Certificate
+
+	outputFile android.Path
+	installDir android.InstallPath
+}
+
+func (f *AndroidAppFeature) featureName() string {
+	return proptools.StringDefault(f.properties.Feature_name, f.Name())
+}
+
+// packageId returns the package id of the resources of the feature, or an error if it is not a
+// valid package id for a feature.
+func (f *AndroidAppFeature) packageId() (uint64, error) {
+	if f.properties.Package_id == nil {
+		return defaultAppFeaturePackageId, nil
+	}
+	id, err := strconv.ParseUint(*f.properties.Package_id, 0, 8)
+	if err != nil {
+		return 0, fmt.Errorf("invalid package id %q: %s", *f.properties.Package_id, err)
+	}
+	if id <= maxReservedPackageId || id >= baseAppPackageId {
+		return 0, fmt.Errorf("package id %#x is outside of the range of feature package ids, %#x to %#x",
+			id, maxReservedPackageId+1, baseAppPackageId-1)
+	}
+	return id, nil
+}
+
+func (f *AndroidAppFeature) DepsMutator(ctx android.BottomUpMutatorContext) {
+	sdkDep := decodeSdkDep(ctx, android.SdkContext(f))
+	if sdkDep.hasFrameworkLibs() {
+		f.aapt.deps(ctx, sdkDep)
+	}
+
+	if base := String(f.properties.Base_app); base != "" {
+		ctx.AddVariationDependencies(nil, appFeatureBaseAppTag, base)
+	} else {
+		ctx.PropertyErrorf("base_app", "is required")
+	}
+	ctx.AddVariationDependencies(nil, staticLibTag, f.properties.Static_libs...)
+}
+
+func (f *AndroidAppFeature) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	var base AppPackageInfo
+	hasBase := false
+	ctx.VisitDirectDepsWithTag(appFeatureBaseAppTag, func(m android.Module) {
+		if info, ok := ctx.OtherModuleProvider(m, AppPackageInfoProvider).(AppPackageInfo); ok {
+			base = info
+			hasBase = true
+		} else {
+			ctx.PropertyErrorf("base_app", "%q is not an android_app", ctx.OtherModuleName(m))
+		}
+	})
+
+	packageId, err := f.packageId()
+	if err != nil {
+		ctx.PropertyErrorf("package_id", "%s", err)
+	}
+
+	if !hasBase || ctx.Failed() {
+		return
+	}
+
+	// Compile and link the resources of the feature with their own package id, against the resource
+	// table of the base app.
+	f.aapt.hasNoCode = true
+	aaptLinkFlags := []string{
+		fmt.Sprintf("--package-id %#x", packageId),
+		"--allow-reserved-package-id",
+	}
+	f.aapt.buildActions(ctx, f, nil, nil, false, aaptLinkFlags...)
+
+	// Sign the feature with the certificates of the base app, as all the splits of an app must be
+	// signed with the same certificates.
+	if len(base.Certificates) > 0 {
+		f.certificate = base.Certificates[0]
+	}
+	signed := android.PathForModuleOut(ctx, "signed", base.InstallApkName+"_"+f.featureName()+".apk")
+	SignAppPackage(ctx, signed, f.aapt.exportPackage, base.Certificates, nil, base.Lineage,
+		base.RotationMinSdkVersion)
+	f.outputFile = signed
+
+	// Install the feature next to the base app.
+	if base.Installed {
+		f.installDir = base.InstallDir
+		ctx.InstallFile(f.installDir, f.outputFile.Base(), f.outputFile)
+	} else {
+		f.SkipInstall()
+	}
+}
+
+func (f *AndroidAppFeature) SdkVersion(ctx android.EarlyModuleContext) android.SdkSpec {
+	return android.SdkSpecFrom(ctx, String(f.properties.Sdk_version))
+}
+
+func (f *AndroidAppFeature) SystemModules() string {
+	return ""
+}
+
+func (f *AndroidAppFeature) MinSdkVersion(ctx android.EarlyModuleContext) android.ApiLevel {
+	if f.properties.Min_sdk_version != nil {
+		return android.ApiLevelFrom(ctx, *f.properties.Min_sdk_version)
+	}
+	return f.SdkVersion(ctx).ApiLevel
+}
+
+func (f *AndroidAppFeature) ReplaceMaxSdkVersionPlaceholder(ctx android.EarlyModuleContext) android.ApiLevel {
+	return android.SdkSpecPrivate.ApiLevel
+}
+
+func (f *AndroidAppFeature) TargetSdkVersion(ctx android.EarlyModuleContext) android.ApiLevel {
+	return f.SdkVersion(ctx).ApiLevel
+}
+
+func (f *AndroidAppFeature) Certificate() Certificate {
+	return f.certificate
+}
+
+func (f *AndroidAppFeature) OutputFile() android.Path {
+	return f.outputFile
+}
+
+// android_app_feature builds a dynamic feature split APK of an android_app from resources and a
+// manifest with the split attribute set to the name of the feature.
+func AndroidAppFeatureFactory() android.Module {
+	module := &AndroidAppFeature{}
+	module.AddProperties(
+		&module.properties,
+		&module.aaptProperties)
+
+	android.InitAndroidMultiTargetsArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	android.InitDefaultableModule(module)
+	return module
+}
+
+func appFeaturePackageIdsSingletonFactory() android.Singleton {
+	return &appFeaturePackageIdsSingleton{}
+}
+
+// appFeaturePackageIdsSingleton checks that the features of a base app use distinct package ids, as
+// the resource ids of the features would collide otherwise.
+type appFeaturePackageIdsSingleton struct{}
+
+func (s *appFeaturePackageIdsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	type packageIdOwner struct {
+		baseApp   string
+		packageId uint64
+	}
+	owners := make(map[packageIdOwner][]android.Module)
+	var keys []packageIdOwner
+	ctx.VisitAllModules(func(module android.Module) {
+		feature, ok := module.(*AndroidAppFeature)
+		if !ok || !feature.Enabled() {
+			return
+		}
+		packageId, err := feature.packageId()
+		if err != nil {
+			// Reported by the feature itself.
+			return
+		}
+		key := packageIdOwner{String(feature.properties.Base_app), packageId}
+		if _, exists := owners[key]; !exists {
+			keys = append(keys, key)
+		}
+		owners[key] = append(owners[key], module)
+	})
+
+	for _, key := range keys {
+		features := owners[key]
+		if len(features) < 2 {
+			continue
+		}
+		var names []string
+		for _, feature := range features {
+			names = append(names, ctx.ModuleName(feature))
+		}
+		sort.Strings(names)
+		for _, feature := range features {
+			ctx.ModuleErrorf(feature, "package_id %#x is used by more than one feature of %q: %q",
+				key.packageId, key.baseApp, names)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestAndroidAppFeature(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureModifyConfig(android.SetKatiEnabledForTests),
+		android.MockFS{
+			"base/res/values/strings.xml":     nil,
+			"feature/res/values/strings.xml":  nil,
+			"feature/AndroidManifest.xml":     nil,
+			"feature2/res/values/strings.xml": nil,
+			"feature2/AndroidManifest.xml":    nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		android_app {
+			name: "Base",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			certificate: "platform",
+			resource_dirs: ["base/res"],
+		}
+
+		android_app_feature {
+			name: "feature",
+			base_app: "Base",
+			sdk_version: "current",
+			manifest: "feature/AndroidManifest.xml",
+			resource_dirs: ["feature/res"],
+		}
+
+		android_app_feature {
+			name: "feature2",
+			base_app: "Base",
+			feature_name: "extra",
+			package_id: "0x7d",
+			sdk_version: "current",
+			manifest: "feature2/AndroidManifest.xml",
+			resource_dirs: ["feature2/res"],
+		}
+	`)
+
+	baseResources := "out/soong/.intermediates/Base/android_common/package-res.apk"
+	for _, tc := range []struct {
+		name      string
+		packageId string
+		apk       string
+	}{
+		{name: "feature", packageId: "0x7e", apk: "Base_feature.apk"},
+		{name: "feature2", packageId: "0x7d", apk: "Base_extra.apk"},
+	} {
+		m := result.ModuleForTests(tc.name, "android_common")
+
+		// The feature links against the resource table of the base app with its own package id.
+		link := m.Output("package-res.apk")
+		android.AssertStringDoesContain(t, tc.name+" package id", link.Args["flags"],
+			"--package-id "+tc.packageId+" --allow-reserved-package-id")
+		android.AssertStringDoesContain(t, tc.name+" base resources", link.Args["flags"],
+			"-I "+baseResources)
+		android.AssertStringListContains(t, tc.name+" base resources dep",
+			android.PathsRelativeToTop(link.Implicits), baseResources)
+
+		// The feature is signed with the certificate of the base app.
+		signed := m.Output("signed/" + tc.apk)
+		android.AssertStringEquals(t, tc.name+" certificates",
+			"build/make/target/product/security/platform.x509.pem build/make/target/product/security/platform.pk8",
+			signed.Args["certificates"])
+
+		// The feature is installed next to the base app.
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, m.Module())[0]
+		android.AssertStringPathsRelativeToTopEquals(t, tc.name+" LOCAL_MODULE_PATH", result.Config,
+			[]string{"out/target/product/test_device/system/app/Base"}, entries.EntryMap["LOCAL_MODULE_PATH"])
+		android.AssertStringEquals(t, tc.name+" LOCAL_INSTALLED_MODULE_STEM", tc.apk,
+			entries.EntryMap["LOCAL_INSTALLED_MODULE_STEM"][0])
+		android.AssertStringEquals(t, tc.name+" LOCAL_CERTIFICATE",
+			"build/make/target/product/security/platform.x509.pem", entries.EntryMap["LOCAL_CERTIFICATE"][0])
+	}
+}
+
+func TestAndroidAppFeatureErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bp            string
+		expectedError string
+	}{
+		{
+			name: "base package id",
+			bp: `
+				android_app_feature {
+					name: "feature",
+					base_app: "Base",
+					package_id: "0x7f",
+				}
+			`,
+			expectedError: `package_id: package id 0x7f is outside of the range of feature package ids, 0x2 to 0x7e`,
+		},
+		{
+			name: "invalid package id",
+			bp: `
+				android_app_feature {
+					name: "feature",
+					base_app: "Base",
+					package_id: "feature",
+				}
+			`,
+			expectedError: `package_id: invalid package id "feature"`,
+		},
+		{
+			name: "shared package id",
+			bp: `
+				android_app_feature {
+					name: "feature",
+					base_app: "Base",
+				}
+
+				android_app_feature {
+					name: "feature2",
+					base_app: "Base",
+					package_id: "0x7e",
+				}
+			`,
+			expectedError: `package_id 0x7e is used by more than one feature of "Base": \["feature" "feature2"\]`,
+		},
+		{
+			name: "base is not an app",
+			bp: `
+				android_app_feature {
+					name: "feature",
+					base_app: "lib",
+				}
+
+				android_library {
+					name: "lib",
+					sdk_version: "current",
+				}
+			`,
+			expectedError: `base_app: "lib" is not an android_app`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				PrepareForTestWithJavaDefaultModules,
+				android.FixtureAddTextFile("base/Android.bp", `
+					android_app {
+						name: "Base",
+						srcs: ["a.java"],
+						sdk_version: "current",
+					}
+				`),
+			).
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(tc.expectedError)).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
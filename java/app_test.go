@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -1621,6 +1622,49 @@ func TestCertificates(t *testing.T) {
 			expectedCertSigningFlags: "--lineage lineage.bin --rotation-min-sdk-version 32",
 			expectedCertificate:      "cert/new_cert",
 		},
+		{
+			name: "cert signing flags from certificate module",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					certificate: ":new_certificate",
+					sdk_version: "current",
+				}
+
+				android_app_certificate {
+					name: "new_certificate",
+					certificate: "cert/new_cert",
+					lineage: "cert/lineage.bin",
+					rotationMinSdkVersion: "32",
+				}
+			`,
+			certificateOverride:      "",
+			expectedCertSigningFlags: "--lineage cert/lineage.bin --rotation-min-sdk-version 32",
+			expectedCertificate:      "cert/new_cert",
+		},
+		{
+			name: "app signing flags override certificate module",
+			bp: `
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					certificate: ":new_certificate",
+					lineage: "lineage.bin",
+					sdk_version: "current",
+				}
+
+				android_app_certificate {
+					name: "new_certificate",
+					certificate: "cert/new_cert",
+					lineage: "cert/lineage.bin",
+					rotationMinSdkVersion: "32",
+				}
+			`,
+			certificateOverride:      "",
+			expectedCertSigningFlags: "--lineage lineage.bin --rotation-min-sdk-version 32",
+			expectedCertificate:      "cert/new_cert",
+		},
 		{
 			name: "missing with AllowMissingDependencies",
 			bp: `
@@ -1674,6 +1718,118 @@ func TestCertificates(t *testing.T) {
 	}
 }
 
+func TestCertificateFileOrModule(t *testing.T) {
+	testCases := []struct {
+		name                string
+		certificate         string
+		preparer            android.FixturePreparer
+		expectedCertificate string
+		expectedError       string
+	}{
+		{
+			name:                "file",
+			certificate:         "expiredkey",
+			expectedCertificate: "build/make/target/product/security/expiredkey",
+		},
+		{
+			name:          "module",
+			certificate:   "new_certificate",
+			expectedError: `"new_certificate" is not a file but is the name of a module, use ":new_certificate" to refer to the module`,
+		},
+		{
+			name:        "file and module",
+			certificate: "new_certificate",
+			preparer: android.FixtureAddFile(
+				"build/make/target/product/security/new_certificate.x509.pem", nil),
+			expectedError: `"new_certificate" refers to both the file "build/make/target/product/security/new_certificate.x509.pem" and the module "new_certificate"`,
+		},
+		{
+			name:          "neither file nor module",
+			certificate:   "missing",
+			preparer:      android.PrepareForTestDisallowNonExistentPaths,
+			expectedError: `source path "build/make/target/product/security/missing.x509.pem" does not exist`,
+		},
+		{
+			name:        "explicit module",
+			certificate: ":new_certificate",
+			preparer: android.FixtureAddFile(
+				"build/make/target/product/security/new_certificate.x509.pem", nil),
+			expectedCertificate: "cert/new_cert",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			errorHandler := android.FixtureExpectsNoErrors
+			if test.expectedError != "" {
+				errorHandler = android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(test.expectedError))
+			}
+			preparer := test.preparer
+			if preparer == nil {
+				preparer = android.NullFixturePreparer
+			}
+			result := android.GroupFixturePreparers(
+				PrepareForTestWithJavaDefaultModules,
+				preparer,
+			).ExtendWithErrorHandler(errorHandler).RunTestWithBp(t, fmt.Sprintf(`
+				android_app {
+					name: "foo",
+					srcs: ["a.java"],
+					certificate: %q,
+					sdk_version: "current",
+				}
+
+				android_app_certificate {
+					name: "new_certificate",
+					certificate: "cert/new_cert",
+				}
+			`, test.certificate))
+
+			if test.expectedError == "" {
+				certificate := result.ModuleForTests("foo", "android_common").Module().(*AndroidApp).certificate
+				android.AssertPathRelativeToTopEquals(t, "certificates pem", test.expectedCertificate+".x509.pem", certificate.Pem)
+			}
+		})
+	}
+}
+
+func TestCertificateLineageValidation(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			certificate: ":new_certificate",
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+
+		android_app_certificate {
+			name: "new_certificate",
+			certificate: "cert/new_cert",
+			lineage: "cert/lineage.bin",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	signapk := foo.Output("foo.apk")
+	android.AssertPathsRelativeToTopEquals(t, "validations",
+		[]string{"out/soong/.intermediates/foo/android_common/lineage/foo.apk.verified"}, signapk.Validations)
+	android.AssertStringListContains(t, "implicits", signapk.Implicits.Strings(), "cert/lineage.bin")
+
+	verify := foo.Rule("verifyApkLineage")
+	android.AssertPathRelativeToTopEquals(t, "verified apk", "out/soong/.intermediates/foo/android_common/foo.apk", verify.Input)
+	android.AssertPathRelativeToTopEquals(t, "lineage", "cert/lineage.bin", verify.Implicit)
+
+	// Apps without a lineage are not verified.
+	bar := result.ModuleForTests("bar", "android_common")
+	android.AssertDeepEquals(t, "validations", android.Paths(nil), bar.Output("bar.apk").Validations)
+}
+
 func TestRequestV4SigningFlag(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -3547,3 +3703,61 @@ func TestTargetSdkVersionMtsTests(t *testing.T) {
 		android.AssertStringDoesContain(t, testCase.desc, manifestFixerArgs, "--targetSdkVersion  "+testCase.targetSdkVersionExpected)
 	}
 }
+
+func TestAndroidTestHelperAppTargetSdkVersions(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+	).RunTestWithBp(t, `
+		android_test_helper_app {
+			name: "helper",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			target_sdk_version: "28",
+			target_sdk_versions: ["29", "30"],
+			test_suites: ["cts"],
+			target: {
+				android: {
+					srcs: ["b.java"],
+				},
+			},
+		}
+	`)
+
+	checkVariant := func(name, targetSdkVersion string) {
+		t.Helper()
+		m := result.ModuleForTests(name, "android_common")
+		manifestFixerArgs := m.Output("manifest_fixer/AndroidManifest.xml").Args["args"]
+		android.AssertStringDoesContain(t, name+" manifest fixer args", manifestFixerArgs, "--targetSdkVersion  "+targetSdkVersion)
+		m.Output(name + ".apk")
+		android.AssertPathsRelativeToTopEquals(t, name+" srcs", []string{"a.java", "b.java"}, m.Rule("javac").Inputs)
+
+		entries := android.AndroidMkEntriesForTest(t, result.TestContext, m.Module())[0]
+		android.AssertDeepEquals(t, name+" test suites", []string{"cts"}, entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"])
+	}
+	checkVariant("helper", "28")
+	checkVariant("helper_targetsdk_29", "29")
+	checkVariant("helper_targetsdk_30", "30")
+
+	creator, created := result.ModuleForTests("helper_targetsdk_29", "android_common").CreatedBy()
+	android.AssertBoolEquals(t, "helper_targetsdk_29 created", true, created)
+	android.AssertStringEquals(t, "helper_targetsdk_29 creator", "helper", creator.Module)
+
+	android.AssertDeepEquals(t, "variants of an unlisted target sdk version", []string(nil),
+		result.ModuleVariantsForTests("helper_targetsdk_28"))
+}
+
+func TestAndroidTestHelperAppTargetSdkVersionsErrors(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsErrorsMatchingInAnyOrder(
+		`target_sdk_versions: duplicate target sdk version "29"`,
+		`target_sdk_versions: .*"S29"`,
+	)).RunTestWithBp(t, `
+		android_test_helper_app {
+			name: "helper",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			target_sdk_versions: ["29", "29", "S29"],
+		}
+	`)
+}
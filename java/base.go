@@ -184,6 +184,13 @@ type CommonProperties struct {
 
 	// A list of java_library instances that provide additional hiddenapi annotations for the library.
 	Hiddenapi_additional_annotations []string
+
+	// Boolean build flags exposed to the sources of the module as constants of a generated
+	// BuildFlags class in the package set by build_flags_package.
+	Build_flags []BuildFlagProperties
+
+	// The Java package of the BuildFlags class generated for build_flags.
+	Build_flags_package *string
 }
 
 // Properties that are specific to device modules. Host module factories should not add these when
@@ -272,6 +279,7 @@ type DeviceProperties struct {
 
 	HiddenAPIPackageProperties
 	HiddenAPIFlagFileProperties
+	HiddenAPIPackageOverrideProperties
 }
 
 // Device properties that can be overridden by overriding module (e.g. override_android_app)
@@ -581,6 +589,9 @@ func (j *Module) provideHiddenAPIPropertyInfo(ctx android.ModuleContext) {
 	// Populate with package rules from the properties.
 	hiddenAPIInfo.extractPackageRulesFromProperties(&j.deviceProperties.HiddenAPIPackageProperties)
 
+	// Populate with package overrides from the properties.
+	hiddenAPIInfo.extractPackageOverridesFromProperties(ctx, &j.deviceProperties.HiddenAPIPackageOverrideProperties)
+
 	ctx.SetProvider(hiddenAPIPropertyInfoProvider, hiddenAPIInfo)
 }
 
@@ -599,6 +610,11 @@ func (j *Module) OutputFiles(tag string) (android.Paths, error) {
 			return android.Paths{j.dexer.proguardDictionary.Path()}, nil
 		}
 		return nil, fmt.Errorf("%q was requested, but no output file was found.", tag)
+	case ".dexjar":
+		if j.dexJarFile.Valid() {
+			return android.Paths{j.dexJarFile.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no dex jar was found, set compile_dex: true", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -779,7 +795,7 @@ func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 
 	android.ProtoDeps(ctx, &j.protoProperties)
 	if j.hasSrcExt(".proto") {
-		protoDeps(ctx, &j.protoProperties)
+		protoDeps(ctx, &j.protoProperties, j.properties.Libs, j.properties.Static_libs)
 	}
 
 	if j.hasSrcExt(".kt") {
@@ -1067,6 +1083,9 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 
 	nonGeneratedSrcJars := srcFiles.FilterByExt(".srcjar")
 	srcFiles = j.genSources(ctx, srcFiles, flags)
+	if buildFlagsSrcJar := j.genBuildFlags(ctx, srcFiles); buildFlagsSrcJar != nil {
+		srcFiles = append(srcFiles, buildFlagsSrcJar)
+	}
 
 	// Collect javac flags only after computing the full set of srcFiles to
 	// ensure that the --patch-module lookup paths are complete.
@@ -2008,6 +2027,7 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 
 	sdkLinkType, _ := j.getSdkLinkType(ctx, ctx.ModuleName())
 
+	systemModulesName := ""
 	j.collectTransitiveHeaderJars(ctx)
 	ctx.VisitDirectDeps(func(module android.Module) {
 		otherName := ctx.OtherModuleName(module)
@@ -2142,6 +2162,7 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				sm := module.(SystemModulesProvider)
 				outputDir, outputDeps := sm.OutputDirAndDeps()
 				deps.systemModules = &systemModules{outputDir, outputDeps}
+				systemModulesName = otherName
 
 			case instrumentationForTag:
 				ctx.PropertyErrorf("instrumentation_for", "dependency %q of type %q does not provide JavaInfo so is unsuitable for use with this property", ctx.OtherModuleName(module), ctx.OtherModuleType(module))
@@ -2151,6 +2172,19 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 		addCLCFromDep(ctx, module, j.classLoaderContexts)
 	})
 
+	if ctx.Device() {
+		sdkVersion := j.SdkVersion(ctx)
+		if ctx.DeviceSpecific() || ctx.SocSpecific() {
+			sdkVersion = sdkVersion.ForVendorPartition(ctx)
+		}
+		ctx.SetProvider(SdkResolutionInfoProvider, SdkResolutionInfo{
+			SdkVersion:        sdkVersion.Raw,
+			SdkKind:           sdkVersion.Kind,
+			SystemModules:     systemModulesName,
+			BootclasspathJars: android.Paths(deps.bootClasspath),
+		})
+	}
+
 	return deps
 }
 
@@ -706,6 +706,11 @@ func (b *BootclasspathFragmentModule) generateHiddenAPIBuildActions(ctx android.
 		// from this to resolve any references from their code to classes provided by this fragment
 		// and the fragments this depends upon.
 		TransitiveStubDexJarsByScope: input.transitiveStubDexJarsByScope(),
+
+		// The monolithic hidden API processing also needs the package overrides and the additional
+		// flags files of the contents.
+		packageOverrides:     input.packageOverrides,
+		additionalFlagsFiles: input.additionalFlagsFiles,
 	}
 
 	// The monolithic hidden API processing also needs access to all the output files produced by
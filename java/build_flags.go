@@ -0,0 +1,140 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// Build flags are boolean constants of a BuildFlags class that soong generates from soong config
+// variables or product variables, so that java modules can check build time configuration without
+// a genrule per module.  The class is written on its own and zipped into a srcjar that is only
+// updated when the values of the flags change.
+
+const buildFlagsClass = "BuildFlags"
+
+type BuildFlagProperties struct {
+	// The name of the constant in the generated BuildFlags class.
+	Name *string
+
+	// The variable that sets the value of the flag, either a soong config variable in the form
+	// "<namespace>.<variable>" or the name of a boolean product variable, e.g. "Debuggable".  It is
+	// an error if the soong config variable is not set.
+	Value_var *string
+}
+
+var javaIdentifierRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// buildFlagValue returns the value of the variable named by the value_var property of a build flag,
+// or an error if there is no such variable.
+func buildFlagValue(ctx android.ModuleContext, valueVar string) (bool, error) {
+	if i := strings.Index(valueVar, "."); i >= 0 {
+		namespace, variable := valueVar[:i], valueVar[i+1:]
+		vendorConfig := ctx.Config().VendorConfig(namespace)
+		if !vendorConfig.IsSet(variable) {
+			return false, fmt.Errorf("soong config variable %q is not set in namespace %q", variable, namespace)
+		}
+		return vendorConfig.Bool(variable), nil
+	}
+	value, ok := ctx.Config().BoolProductVariable(valueVar)
+	if !ok {
+		return false, fmt.Errorf("%q is neither a soong config variable nor a boolean product variable", valueVar)
+	}
+	return value, nil
+}
+
+// genBuildFlags generates the BuildFlags class for the build_flags property and returns the srcjar
+// containing it, or nil if the module has no build flags.
+func (j *Module) genBuildFlags(ctx android.ModuleContext, srcFiles android.Paths) android.Path {
+	if len(j.properties.Build_flags) == 0 {
+		return nil
+	}
+
+	pkg := proptools.String(j.properties.Build_flags_package)
+	if pkg == "" {
+		ctx.PropertyErrorf("build_flags_package", "is required when build_flags is set")
+		return nil
+	}
+	for _, part := range strings.Split(pkg, ".") {
+		if !javaIdentifierRegexp.MatchString(part) {
+			ctx.PropertyErrorf("build_flags_package", "%q is not a valid Java package", pkg)
+			return nil
+		}
+	}
+
+	classFile := filepath.Join(append(strings.Split(pkg, "."), buildFlagsClass+".java")...)
+	for _, src := range srcFiles.FilterByExt(".java") {
+		if src.Base() == buildFlagsClass+".java" && strings.HasSuffix("/"+src.String(), "/"+classFile) {
+			ctx.PropertyErrorf("build_flags", "generated class %s.%s conflicts with %s",
+				pkg, buildFlagsClass, src)
+			return nil
+		}
+	}
+
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "// Generated by the build system from the build_flags of %s, do not edit.\n", ctx.ModuleName())
+	fmt.Fprintf(sb, "package %s;\n\n", pkg)
+	fmt.Fprintf(sb, "public final class %s {\n", buildFlagsClass)
+	fmt.Fprintf(sb, "    private %s() {}\n", buildFlagsClass)
+
+	seen := make(map[string]bool)
+	for _, flag := range j.properties.Build_flags {
+		name := proptools.String(flag.Name)
+		valueVar := proptools.String(flag.Value_var)
+		if !javaIdentifierRegexp.MatchString(name) {
+			ctx.PropertyErrorf("build_flags", "name %q is not a valid Java identifier", name)
+			continue
+		}
+		if seen[name] {
+			ctx.PropertyErrorf("build_flags", "duplicate flag %q", name)
+			continue
+		}
+		seen[name] = true
+		value, err := buildFlagValue(ctx, valueVar)
+		if err != nil {
+			ctx.PropertyErrorf("build_flags", "value_var of flag %q: %s", name, err)
+			continue
+		}
+		fmt.Fprintf(sb, "\n    public static final boolean %s = %t;\n", name, value)
+	}
+	sb.WriteString("}")
+
+	genDir := android.PathForModuleGen(ctx, "build_flags", "src")
+	javaFile := genDir.Join(ctx, classFile)
+	android.WriteFileRule(ctx, javaFile, sb.String())
+
+	// Zip the class with -write_if_changed so that the module is only recompiled when the values of
+	// its flags change.
+	srcJar := android.PathForModuleGen(ctx, "build_flags", "build_flags.srcjar")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("soong_zip").
+		Flag("-srcjar").
+		Flag("-write_if_changed").
+		FlagWithOutput("-o ", srcJar).
+		FlagWithArg("-C ", genDir.String()).
+		FlagWithInput("-f ", javaFile)
+	rule.Restat()
+	rule.Build("build_flags", "build flags")
+
+	return srcJar
+}
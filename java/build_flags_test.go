@@ -0,0 +1,177 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"android/soong/android"
+)
+
+var buildFlagsTestBp = `
+	java_library {
+		name: "foo",
+		srcs: ["a.java"],
+		build_flags_package: "com.android.foo",
+		build_flags: [
+			{
+				name: "ENABLE_BAR",
+				value_var: "foo_namespace.enable_bar",
+			},
+			{
+				name: "DEBUGGABLE",
+				value_var: "Debuggable",
+			},
+		],
+	}
+`
+
+func TestBuildFlags(t *testing.T) {
+	testCases := []struct {
+		name       string
+		enableBar  string
+		debuggable bool
+	}{
+		{
+			name:       "true",
+			enableBar:  "true",
+			debuggable: true,
+		},
+		{
+			name:       "false",
+			enableBar:  "false",
+			debuggable: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForJavaTest,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.VendorVars = map[string]map[string]string{
+						"foo_namespace": {
+							"enable_bar": tc.enableBar,
+						},
+					}
+					variables.Debuggable = &tc.debuggable
+				}),
+			).RunTestWithBp(t, buildFlagsTestBp)
+
+			foo := result.ModuleForTests("foo", "android_common")
+			content := android.ContentFromFileRuleForTests(t, foo.Output("build_flags/src/com/android/foo/BuildFlags.java"))
+			android.AssertStringDoesContain(t, "package", content, "package com.android.foo;\n")
+			android.AssertStringDoesContain(t, "class", content, "public final class BuildFlags {\n")
+			android.AssertStringDoesContain(t, "ENABLE_BAR", content,
+				"public static final boolean ENABLE_BAR = "+tc.enableBar+";\n")
+			android.AssertStringDoesContain(t, "DEBUGGABLE", content,
+				"public static final boolean DEBUGGABLE = "+strconv.FormatBool(tc.debuggable)+";\n")
+
+			// The srcjar is only rewritten when the contents of the class change, and the module is
+			// compiled with it.
+			srcJar := foo.Output("build_flags/build_flags.srcjar")
+			android.AssertStringDoesContain(t, "srcjar command", srcJar.RuleParams.Command, "-write_if_changed")
+			android.AssertBoolEquals(t, "srcjar restat", true, srcJar.RuleParams.Restat)
+			android.AssertPathsRelativeToTopEquals(t, "foo compiled srcjars",
+				[]string{"out/soong/.intermediates/foo/android_common/gen/build_flags/build_flags.srcjar"},
+				foo.Module().(*Library).compiledSrcJars)
+		})
+	}
+}
+
+func TestBuildFlagsErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		bp    string
+		error string
+	}{
+		{
+			name: "collision",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java", "com/android/foo/BuildFlags.java"],
+					build_flags_package: "com.android.foo",
+					build_flags: [{name: "ENABLE_BAR", value_var: "foo_namespace.enable_bar"}],
+				}`,
+			error: `generated class com.android.foo.BuildFlags conflicts with com/android/foo/BuildFlags.java`,
+		},
+		{
+			name: "missing package",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					build_flags: [{name: "ENABLE_BAR", value_var: "foo_namespace.enable_bar"}],
+				}`,
+			error: `build_flags_package: is required when build_flags is set`,
+		},
+		{
+			name: "unknown variable",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					build_flags_package: "com.android.foo",
+					build_flags: [{name: "ENABLE_BAR", value_var: "Enable_bar"}],
+				}`,
+			error: `value_var of flag "ENABLE_BAR": "Enable_bar" is neither a soong config variable nor a boolean product variable`,
+		},
+		{
+			name: "unset soong config variable",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					build_flags_package: "com.android.foo",
+					build_flags: [{name: "ENABLE_BAZ", value_var: "foo_namespace.enable_baz"}],
+				}`,
+			error: `value_var of flag "ENABLE_BAZ": soong config variable "enable_baz" is not set in namespace "foo_namespace"`,
+		},
+		{
+			name: "duplicate",
+			bp: `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					build_flags_package: "com.android.foo",
+					build_flags: [
+						{name: "ENABLE_BAR", value_var: "foo_namespace.enable_bar"},
+						{name: "ENABLE_BAR", value_var: "Debuggable"},
+					],
+				}`,
+			error: `duplicate flag "ENABLE_BAR"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				prepareForJavaTest,
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.VendorVars = map[string]map[string]string{
+						"foo_namespace": {
+							"enable_bar": "true",
+						},
+					}
+				}),
+			).
+				ExtendWithErrorHandler(android.FixtureExpectsOneErrorPattern(regexp.QuoteMeta(tc.error))).
+				RunTestWithBp(t, tc.bp)
+		})
+	}
+}
@@ -53,6 +53,23 @@ var (
 		"core-oj",
 		"core-libart",
 	}
+
+	// ProtoRuntimeLibraries maps each java proto.type to the runtime library that the code
+	// generated for it is compiled against.  Modules get a static dependency on the runtime of
+	// their proto.type, and must not depend on the runtime of another proto.type.
+	ProtoRuntimeLibraries = map[string]string{
+		"lite":  "libprotobuf-java-lite",
+		"full":  "libprotobuf-java-full",
+		"nano":  "libprotobuf-java-nano",
+		"micro": "libprotobuf-java-micro",
+	}
+
+	// RemovedProtoTypes maps the java proto.types that are no longer supported to the proto.type
+	// that modules should migrate to.
+	RemovedProtoTypes = map[string]string{
+		"nano":  "lite",
+		"micro": "lite",
+	}
 )
 
 var (
@@ -208,6 +225,7 @@ func init() {
 	hostBinToolVariableWithBuildToolsPrebuilt("AidlCmd", "aidl")
 	hostBinToolVariableWithBuildToolsPrebuilt("ZipAlign", "zipalign")
 
+	pctx.HostBinToolVariable("ApksignerCmd", "apksigner")
 	hostJavaToolVariableWithSdkToolsPrebuilt("SignapkCmd", "signapk")
 	// TODO(ccross): this should come from the signapk dependencies, but we don't have any way
 	// to express host JNI dependencies yet.
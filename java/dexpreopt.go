@@ -15,6 +15,7 @@
 package java
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -143,7 +144,8 @@ type DexpreoptProperties struct {
 
 		// If set, provides the path to profile relative to the Android.bp file.  If not set,
 		// defaults to searching for a file that matches the name of this module in the default
-		// profile location set by PRODUCT_DEX_PREOPT_PROFILE_DIR, or empty if not found.
+		// profile location set by PRODUCT_DEX_PREOPT_PROFILE_DIR, or empty if not found.  For
+		// system server jars a missing profile is skipped with a warning.
 		Profile *string `android:"path"`
 	}
 
@@ -331,12 +333,18 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 	} else if BoolDefault(d.dexpreoptProperties.Dex_preopt.Profile_guided, true) && !forPrebuiltApex(ctx) {
 		// If dex_preopt.profile_guided is not set, default it based on the existence of the
 		// dexprepot.profile option or the profile class listing.
-		if String(d.dexpreoptProperties.Dex_preopt.Profile) != "" {
-			profileClassListing = android.OptionalPathForPath(
-				android.PathForModuleSrc(ctx, String(d.dexpreoptProperties.Dex_preopt.Profile)))
-			profileBootListing = android.ExistentPathForSource(ctx,
-				ctx.ModuleDir(), String(d.dexpreoptProperties.Dex_preopt.Profile)+"-boot")
-			profileIsTextListing = true
+		if profile := String(d.dexpreoptProperties.Dex_preopt.Profile); profile != "" {
+			if isSystemServerJar && android.SrcIsModule(profile) == "" &&
+				!android.ExistentPathForSource(ctx, ctx.ModuleDir(), profile).Valid() {
+				// A missing profile only costs a system server jar its profile guided layout, so
+				// compile it without one rather than failing the build.
+				fmt.Printf("%s: warning: module %s's dex_preopt.profile %q does not exist, dexpreopting without a profile\n",
+					ctx.ModuleDir(), ctx.ModuleName(), profile)
+			} else {
+				profileClassListing = android.OptionalPathForPath(android.PathForModuleSrc(ctx, profile))
+				profileBootListing = android.ExistentPathForSource(ctx, ctx.ModuleDir(), profile+"-boot")
+				profileIsTextListing = true
+			}
 		} else if global.ProfileDir != "" {
 			profileClassListing = android.ExistentPathForSource(ctx,
 				global.ProfileDir, moduleName(ctx)+".prof")
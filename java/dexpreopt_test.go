@@ -463,3 +463,53 @@ func TestGenerateProfileEvenIfDexpreoptIsDisabled(t *testing.T) {
 
 	android.AssertArrayString(t, "outputs", expected, dexpreopt.AllOutputs())
 }
+
+func TestDexpreoptSystemServerJarProfile(t *testing.T) {
+	preparers := android.GroupFixturePreparers(
+		PrepareForTestWithDexpreopt,
+		dexpreopt.FixtureSetSystemServerJars("platform:service-foo"),
+		android.FixtureAddFile("art-profile", nil),
+	)
+
+	result := preparers.RunTestWithBp(t, `
+		java_library {
+			name: "service-foo",
+			installable: true,
+			dex_preopt: {
+				profile: "art-profile",
+			},
+			srcs: ["a.java"],
+		}`)
+
+	cmd := result.ModuleForTests("service-foo", "android_common").Rule("dexpreopt").RuleParams.Command
+
+	// The text profile is converted to a binary profile with profman, which is passed to dex2oat.
+	android.AssertStringDoesContain(t, "profman", cmd, "--create-profile-from=art-profile")
+	android.AssertStringDoesContain(t, "dex2oat", cmd,
+		"--profile-file=out/soong/.intermediates/service-foo/android_common/dexpreopt/profile.prof")
+	android.AssertStringDoesContain(t, "dex2oat", cmd, "--compiler-filter=speed-profile")
+}
+
+func TestDexpreoptSystemServerJarMissingProfile(t *testing.T) {
+	preparers := android.GroupFixturePreparers(
+		PrepareForTestWithDexpreopt,
+		dexpreopt.FixtureSetSystemServerJars("platform:service-foo"),
+	)
+
+	result := preparers.RunTestWithBp(t, `
+		java_library {
+			name: "service-foo",
+			installable: true,
+			dex_preopt: {
+				profile: "missing-profile",
+			},
+			srcs: ["a.java"],
+		}`)
+
+	cmd := result.ModuleForTests("service-foo", "android_common").Rule("dexpreopt").RuleParams.Command
+
+	android.AssertStringDoesNotContain(t, "profman", cmd, "--create-profile-from=")
+	android.AssertStringDoesNotContain(t, "dex2oat", cmd, "--profile-file=")
+	android.AssertStringDoesContain(t, "dex2oat", cmd, "--compiler-filter=speed")
+	android.AssertStringDoesNotContain(t, "dex2oat", cmd, "--compiler-filter=speed-profile")
+}
@@ -98,6 +98,24 @@ type JavadocProperties struct {
 
 	// names of the output files used in args that will be generated
 	Out []string
+
+	// If set to true, check the links in the generated html docs, reporting the links to missing
+	// pages, e.g. of classes that are excluded from the docs, and to missing anchors in
+	// link_check/report.txt.  The check runs whenever the docs are dist'ed.
+	Link_check *bool
+
+	// If set to true, broken links fail the link check instead of only being reported.
+	Link_check_errors_fatal *bool
+
+	Docs_dist struct {
+		// The api scope of the docs in the name of the dist'ed docs zip, e.g. "public" or
+		// "system".  Defaults to the kind of sdk_version.
+		Api_scope *string
+
+		// The version of the documented module in the name of the dist'ed docs zip.  Defaults to
+		// the platform sdk version.
+		Version *string
+	}
 }
 
 type ApiToCheck struct {
@@ -205,6 +223,9 @@ type Javadoc struct {
 
 	docZip      android.WritablePath
 	stubsSrcJar android.WritablePath
+
+	// The copy of docZip that is dist'ed, see distDocZip.
+	distDocZip android.WritablePath
 }
 
 func (j *Javadoc) OutputFiles(tag string) (android.Paths, error) {
@@ -265,7 +286,7 @@ func (j *Javadoc) addDeps(ctx android.BottomUpMutatorContext) {
 		sdkDep := decodeSdkDep(ctx, android.SdkContext(j))
 		if sdkDep.useModule {
 			ctx.AddVariationDependencies(nil, bootClasspathTag, sdkDep.bootclasspath...)
-			ctx.AddVariationDependencies(nil, systemModulesTag, sdkDep.systemModules)
+			addSystemModulesDependency(ctx, android.SdkContext(j), sdkDep.systemModules)
 			ctx.AddVariationDependencies(nil, java9LibTag, sdkDep.java9Classpath...)
 			ctx.AddVariationDependencies(nil, sdkLibTag, sdkDep.classpath...)
 		}
@@ -545,6 +566,49 @@ func (j *Javadoc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	zipSyncCleanupCmd(rule, srcJarDir)
 
 	rule.Build("javadoc", "javadoc")
+
+	j.buildDistDocZip(ctx)
+}
+
+// distDocZipName returns the name of the dist'ed docs zip, which includes the api scope and the
+// version of the docs so that the published bundles have stable URLs.
+func (j *Javadoc) distDocZipName(ctx android.ModuleContext) string {
+	apiScope := String(j.properties.Docs_dist.Api_scope)
+	if apiScope == "" {
+		apiScope = j.SdkVersion(ctx).Kind.String()
+	}
+	version := String(j.properties.Docs_dist.Version)
+	if version == "" {
+		version = ctx.Config().PlatformSdkVersion().String()
+	}
+	return fmt.Sprintf("%s-%s-%s-docs.zip", ctx.ModuleName(), apiScope, version)
+}
+
+// buildDistDocZip copies the docs zip to the dist'ed docs zip, checking the links in the docs
+// first if link_check is set.
+func (j *Javadoc) buildDistDocZip(ctx android.ModuleContext) {
+	var validations android.Paths
+	if Bool(j.properties.Link_check) {
+		report := android.PathForModuleOut(ctx, "link_check", "report.txt")
+		rule := android.NewRuleBuilder(pctx, ctx)
+		cmd := rule.Command().
+			BuiltTool("check_doc_links").
+			FlagWithOutput("--report ", report)
+		if Bool(j.properties.Link_check_errors_fatal) {
+			cmd.Flag("--fail-on-errors")
+		}
+		cmd.Input(j.docZip)
+		rule.Build("link_check", "link check "+ctx.ModuleName())
+		validations = append(validations, report)
+	}
+
+	j.distDocZip = android.PathForModuleOut(ctx, "dist", j.distDocZipName(ctx))
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cp,
+		Input:       j.docZip,
+		Output:      j.distDocZip,
+		Validations: validations,
+	})
 }
 
 // Droiddoc
@@ -828,6 +892,8 @@ func (d *Droiddoc) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	zipSyncCleanupCmd(rule, srcJarDir)
 
 	rule.Build("javadoc", desc)
+
+	d.Javadoc.buildDistDocZip(ctx)
 }
 
 // Exported Droiddoc Directory
@@ -145,3 +145,112 @@ func TestDroiddocArgsAndFlagsCausesError(t *testing.T) {
 		}
 		`)
 }
+
+func TestDroiddocLinkCheck(t *testing.T) {
+	ctx, _ := testJavaWithFS(t, `
+		droiddoc {
+		    name: "foo-doc",
+		    srcs: ["foo-doc/a.java"],
+		    custom_template: "droiddoc-templates-sdk",
+		    link_check: true,
+		}
+
+		droiddoc {
+		    name: "bar-doc",
+		    srcs: ["bar-doc/a.java"],
+		    custom_template: "droiddoc-templates-sdk",
+		    link_check: true,
+		    link_check_errors_fatal: true,
+		}
+
+		droiddoc {
+		    name: "baz-doc",
+		    srcs: ["baz-doc/a.java"],
+		    custom_template: "droiddoc-templates-sdk",
+		}
+
+		droiddoc_exported_dir {
+		    name: "droiddoc-templates-sdk",
+		    path: ".",
+		}
+		`,
+		map[string][]byte{
+			"foo-doc/a.java": nil,
+			"bar-doc/a.java": nil,
+			"baz-doc/a.java": nil,
+		})
+
+	checkLinkCheck := func(t *testing.T, name string, fatal bool) {
+		t.Helper()
+		m := ctx.ModuleForTests(name, "android_common")
+		docZip := m.Output(name + "-docs.zip")
+		linkCheck := m.Rule("link_check")
+		if !inList(android.PathRelativeToTop(docZip.Output), android.PathsRelativeToTop(linkCheck.Implicits)) {
+			t.Errorf("expected the link check of %s to depend on %s, got %v", name, docZip.Output, linkCheck.Implicits)
+		}
+		android.AssertStringDoesContain(t, "link check command", linkCheck.RuleParams.Command,
+			"--report out/soong/.intermediates/"+name+"/android_common/link_check/report.txt")
+		android.AssertStringContainsEquals(t, "link check command", linkCheck.RuleParams.Command,
+			"--fail-on-errors", fatal)
+
+		// The link check runs when the docs are dist'ed.
+		dist := m.Output("dist/" + name + "-private-30-docs.zip")
+		android.AssertPathsRelativeToTopEquals(t, "dist validations",
+			[]string{"out/soong/.intermediates/" + name + "/android_common/link_check/report.txt"}, dist.Validations)
+	}
+
+	checkLinkCheck(t, "foo-doc", false)
+	checkLinkCheck(t, "bar-doc", true)
+
+	baz := ctx.ModuleForTests("baz-doc", "android_common")
+	if linkCheck := baz.MaybeRule("link_check"); linkCheck.Rule != nil {
+		t.Errorf("unexpected link check of baz-doc")
+	}
+	android.AssertIntEquals(t, "baz-doc dist validations", 0,
+		len(baz.Output("dist/baz-doc-private-30-docs.zip").Validations))
+}
+
+func TestDroiddocDistName(t *testing.T) {
+	ctx, _ := testJavaWithFS(t, `
+		droiddoc {
+		    name: "foo-doc",
+		    srcs: ["foo-doc/a.java"],
+		    sdk_version: "system_current",
+		    custom_template: "droiddoc-templates-sdk",
+		}
+
+		droiddoc {
+		    name: "bar-doc",
+		    srcs: ["bar-doc/a.java"],
+		    sdk_version: "system_current",
+		    custom_template: "droiddoc-templates-sdk",
+		    docs_dist: {
+		        api_scope: "module-lib",
+		        version: "1.2.3",
+		    },
+		}
+
+		droiddoc_exported_dir {
+		    name: "droiddoc-templates-sdk",
+		    path: ".",
+		}
+		`,
+		map[string][]byte{
+			"foo-doc/a.java": nil,
+			"bar-doc/a.java": nil,
+		})
+
+	for name, expected := range map[string]string{
+		"foo-doc": "foo-doc-system-30-docs.zip",
+		"bar-doc": "bar-doc-module-lib-1.2.3-docs.zip",
+	} {
+		m := ctx.ModuleForTests(name, "android_common")
+		dist := m.Output("dist/" + expected)
+		android.AssertPathRelativeToTopEquals(t, "dist input",
+			android.PathRelativeToTop(m.Output(name+"-docs.zip").Output), dist.Input)
+
+		entries := android.AndroidMkEntriesForTest(t, ctx, m.Module())[0]
+		android.AssertPathsRelativeToTopEquals(t, "default dist files",
+			[]string{android.PathRelativeToTop(dist.Output)}, entries.DistFiles[android.DefaultDistTag])
+	}
+}
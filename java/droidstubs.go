@@ -840,6 +840,31 @@ func (d *Droidstubs) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 		rule.Build("nullabilityWarningsCheck", "nullability warnings check")
 	}
+
+	var validations android.Paths
+	for _, check := range []android.WritablePath{d.apiLintTimestamp, d.checkCurrentApiTimestamp, d.checkNullabilityWarningsTimestamp} {
+		if check != nil {
+			validations = append(validations, check)
+		}
+	}
+	if len(validations) > 0 {
+		ctx.SetProvider(android.ValidationsProvider, android.ValidationsInfo{Validations: validations})
+	}
+
+	// Make rules that check and dist the API files consume them as interface outputs.
+	interfaceOutputs := make(map[string]android.Path)
+	if d.apiFile != nil {
+		interfaceOutputs[ctx.ModuleName()+".api.txt"] = d.apiFile
+	}
+	if d.removedApiFile != nil {
+		interfaceOutputs[ctx.ModuleName()+".removed_api.txt"] = d.removedApiFile
+	}
+	if d.annotationsZip != nil {
+		interfaceOutputs[ctx.ModuleName()+".annotations.zip"] = d.annotationsZip
+	}
+	if len(interfaceOutputs) > 0 {
+		ctx.SetProvider(android.InterfaceOutputsProvider, android.InterfaceOutputsInfo{Outputs: interfaceOutputs})
+	}
 }
 
 var _ android.ApiProvider = (*Droidstubs)(nil)
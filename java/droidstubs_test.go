@@ -100,6 +100,31 @@ func TestDroidstubs(t *testing.T) {
 	}
 }
 
+func TestDroidstubsInterfaceOutputs(t *testing.T) {
+	ctx, _ := testJavaWithFS(t, `
+		droidstubs {
+			name: "foo-stubs",
+			srcs: ["foo-doc/a.java"],
+			api_filename: "foo_api.txt",
+			removed_api_filename: "foo_removed.txt",
+		}
+		`,
+		map[string][]byte{
+			"foo-doc/a.java": nil,
+		})
+
+	m := ctx.ModuleForTests("foo-stubs", "android_common")
+	info := ctx.ModuleProvider(m.Module(), android.InterfaceOutputsProvider).(android.InterfaceOutputsInfo)
+	outputs := make(map[string]string)
+	for name, path := range info.Outputs {
+		outputs[name] = android.PathRelativeToTop(path)
+	}
+	android.AssertDeepEquals(t, "interface outputs", map[string]string{
+		"foo-stubs.api.txt":         "out/soong/.intermediates/foo-stubs/android_common/metalava/foo_api.txt",
+		"foo-stubs.removed_api.txt": "out/soong/.intermediates/foo-stubs/android_common/metalava/foo_removed.txt",
+	}, outputs)
+}
+
 // runs a test for droidstubs with a customizable sdkType argument and returns
 // the list of jar patterns that is passed as `--android-jar-pattern`
 func getAndroidJarPatternsForDroidstubs(t *testing.T, sdkType string) []string {
@@ -15,12 +15,16 @@
 package java
 
 import (
+	"bytes"
+	"encoding/csv"
 	"fmt"
+	"sort"
 	"strings"
 
 	"android/soong/android"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 // Contains support for processing hiddenAPI in a modular fashion.
@@ -542,6 +546,177 @@ func (s FlagFilesByCategory) sort() {
 	}
 }
 
+// HiddenAPIPackageOverrideProperties contains overrides of the hidden API flags of the members of
+// packages, and of single members, that a java module on the bootclasspath can specify instead of
+// editing the flag files of the bootclasspath_fragment or platform_bootclasspath that it is part
+// of.
+type HiddenAPIPackageOverrideProperties struct {
+	Hidden_api struct {
+		// Gives a flag to all the members of all the classes in the packages with a prefix that are
+		// not otherwise flagged.
+		Package_overrides []HiddenAPIPackageOverrideProperty
+
+		// Files that give flags to members, each line of which is the signature of a member, a comma
+		// and the name of the flag file property whose flag is given to it, e.g.
+		// max_target_o_low_priority. The removed and unsupported_packages properties are not
+		// allowed. Giving a member different flags, in the files of one module or of two modules, is
+		// an error.
+		Additional_flags_files []string `android:"path"`
+	}
+}
+
+type HiddenAPIPackageOverrideProperty struct {
+	// The package prefix, e.g. android.foo, which matches the package and all its sub-packages.
+	Prefix *string
+
+	// The name of the flag file property whose flag is given to the members, e.g.
+	// max_target_o_low_priority. The removed and unsupported_packages properties are not allowed.
+	Flag *string
+
+	// The bug that justifies the override. It is recorded in the package-overrides.csv file.
+	Bug *string
+}
+
+// hiddenAPIPackageOverride is an override of the flags of the members of a package prefix by a
+// module.
+type hiddenAPIPackageOverride struct {
+	module   string
+	prefix   string
+	category *hiddenAPIFlagFileCategory
+	bug      string
+}
+
+type hiddenAPIPackageOverrides []hiddenAPIPackageOverride
+
+// hiddenAPIPackageOverrideCategory returns the category of flag file with the property name flag
+// that a package can be overridden with, or nil if there is none.
+func hiddenAPIPackageOverrideCategory(flag string) *hiddenAPIFlagFileCategory {
+	if flag == hiddenAPIRemovedFlagFileCategory.PropertyName || flag == "unsupported_packages" {
+		return nil
+	}
+	for _, category := range HiddenAPIFlagFileCategories {
+		if category.PropertyName == flag {
+			return category
+		}
+	}
+	return nil
+}
+
+// normalize returns the overrides sorted by prefix and module without duplicates, and reports an
+// error if two modules override the same package prefix with different flags.
+func (o hiddenAPIPackageOverrides) normalize(ctx android.ModuleContext) hiddenAPIPackageOverrides {
+	sorted := append(hiddenAPIPackageOverrides(nil), o...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].prefix != sorted[j].prefix {
+			return sorted[i].prefix < sorted[j].prefix
+		}
+		return sorted[i].module < sorted[j].module
+	})
+
+	var result hiddenAPIPackageOverrides
+	for _, override := range sorted {
+		if len(result) > 0 {
+			last := result[len(result)-1]
+			if last == override {
+				continue
+			}
+			if last.prefix == override.prefix && last.category != override.category {
+				ctx.ModuleErrorf("hidden_api.package_overrides: %q is overridden with %s by %q and with %s by %q",
+					override.prefix, last.category.PropertyName, last.module, override.category.PropertyName, override.module)
+			}
+		}
+		result = append(result, override)
+	}
+	return result
+}
+
+// hiddenAPIAdditionalFlagsFile is a file of the flags given to members by a module.
+type hiddenAPIAdditionalFlagsFile struct {
+	module string
+	path   android.Path
+}
+
+type hiddenAPIAdditionalFlagsFiles []hiddenAPIAdditionalFlagsFile
+
+// normalize returns the files sorted by module and path without duplicates.
+func (f hiddenAPIAdditionalFlagsFiles) normalize() hiddenAPIAdditionalFlagsFiles {
+	sorted := append(hiddenAPIAdditionalFlagsFiles(nil), f...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].module != sorted[j].module {
+			return sorted[i].module < sorted[j].module
+		}
+		return sorted[i].path.String() < sorted[j].path.String()
+	})
+
+	var result hiddenAPIAdditionalFlagsFiles
+	for _, file := range sorted {
+		if len(result) > 0 && result[len(result)-1] == file {
+			continue
+		}
+		result = append(result, file)
+	}
+	return result
+}
+
+// buildRuleToMergeAdditionalFlagsFiles creates a rule that merges the additionalFlagsFiles into a
+// file of signatures per flag in the dir directory of the module, and fails if a member is given
+// different flags, naming the modules that give them. It returns the files of signatures.
+func buildRuleToMergeAdditionalFlagsFiles(ctx android.ModuleContext, name, dir string, additionalFlagsFiles hiddenAPIAdditionalFlagsFiles) FlagFilesByCategory {
+	if len(additionalFlagsFiles) == 0 {
+		return nil
+	}
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	command := rule.Command().BuiltTool("merge_hiddenapi_additional_flags")
+	for _, file := range additionalFlagsFiles {
+		command.FlagWithInput("--module-flags "+file.module+":", file.path)
+	}
+
+	signatureFilesByCategory := FlagFilesByCategory{}
+	for _, category := range HiddenAPIFlagFileCategories {
+		if hiddenAPIPackageOverrideCategory(category.PropertyName) == nil {
+			continue
+		}
+		signatureFile := android.PathForModuleOut(ctx, dir, "additional-flags", category.PropertyName+".txt")
+		command.FlagWithOutput("--output "+category.PropertyName+":", signatureFile)
+		signatureFilesByCategory[category] = android.Paths{signatureFile}
+	}
+	rule.Build(name, "hidden API additional flags")
+
+	return signatureFilesByCategory
+}
+
+// buildRuleToGeneratePackageOverrides creates rules that write the package prefixes overridden by
+// packageOverrides to a file per flag in the dir directory of the module, and a
+// package-overrides.csv file that records the module and the bug of each override. It returns
+// the files of package prefixes.
+func buildRuleToGeneratePackageOverrides(ctx android.ModuleContext, dir string, packageOverrides hiddenAPIPackageOverrides) FlagFilesByCategory {
+	if len(packageOverrides) == 0 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write([]string{"prefix", "flag", "module", "bug"})
+	prefixesByCategory := make(map[*hiddenAPIFlagFileCategory][]string)
+	for _, override := range packageOverrides {
+		w.Write([]string{override.prefix, override.category.PropertyName, override.module, override.bug})
+		prefixesByCategory[override.category] = append(prefixesByCategory[override.category], override.prefix)
+	}
+	w.Flush()
+	android.WriteFileRule(ctx, android.PathForModuleOut(ctx, dir, "package-overrides.csv"), buf.String())
+
+	prefixFilesByCategory := FlagFilesByCategory{}
+	for _, category := range HiddenAPIFlagFileCategories {
+		if prefixes, ok := prefixesByCategory[category]; ok {
+			prefixFile := android.PathForModuleOut(ctx, dir, "package-overrides", category.PropertyName+".txt")
+			android.WriteFileRule(ctx, prefixFile, strings.Join(android.FirstUniqueStrings(prefixes), "\n"))
+			prefixFilesByCategory[category] = android.Paths{prefixFile}
+		}
+	}
+	return prefixFilesByCategory
+}
+
 // HiddenAPIInfo contains information provided by the hidden API processing.
 //
 // That includes paths resolved from HiddenAPIFlagFileProperties and also generated by hidden API
@@ -555,6 +730,12 @@ type HiddenAPIInfo struct {
 	// this fragment and the fragments on which this depends.
 	TransitiveStubDexJarsByScope StubDexJarsByModule
 
+	// The overrides of the flags of packages by the contents of this fragment.
+	packageOverrides hiddenAPIPackageOverrides
+
+	// The additional flags files of the contents of this fragment.
+	additionalFlagsFiles hiddenAPIAdditionalFlagsFiles
+
 	// The output from the hidden API processing needs to be made available to other modules.
 	HiddenAPIFlagOutput
 }
@@ -739,6 +920,12 @@ type HiddenAPIPropertyInfo struct {
 
 	// See HiddenAPIFlagFileProperties.Split_packages
 	SplitPackages []string
+
+	// See HiddenAPIPackageOverrideProperties.Package_overrides
+	packageOverrides hiddenAPIPackageOverrides
+
+	// See HiddenAPIPackageOverrideProperties.Additional_flags_files
+	additionalFlagsFiles hiddenAPIAdditionalFlagsFiles
 }
 
 var hiddenAPIPropertyInfoProvider = blueprint.NewProvider(HiddenAPIPropertyInfo{})
@@ -767,6 +954,40 @@ func (i *HiddenAPIPropertyInfo) extractPackageRulesFromProperties(p *HiddenAPIPa
 	i.SplitPackages = p.Hidden_api.Split_packages
 }
 
+// extractPackageOverridesFromProperties extracts the package overrides that are specified in the
+// supplied properties and stores them in this struct.
+func (i *HiddenAPIPropertyInfo) extractPackageOverridesFromProperties(ctx android.ModuleContext, p *HiddenAPIPackageOverrideProperties) {
+	for _, override := range p.Hidden_api.Package_overrides {
+		prefix := proptools.String(override.Prefix)
+		flag := proptools.String(override.Flag)
+		bug := proptools.String(override.Bug)
+		category := hiddenAPIPackageOverrideCategory(flag)
+		if prefix == "" {
+			ctx.PropertyErrorf("hidden_api.package_overrides", "prefix must be set")
+			continue
+		} else if category == nil {
+			ctx.PropertyErrorf("hidden_api.package_overrides", "%q cannot be overridden with unknown flag %q", prefix, flag)
+			continue
+		} else if bug == "" {
+			ctx.PropertyErrorf("hidden_api.package_overrides", "%q must have a bug that justifies the override", prefix)
+			continue
+		}
+		i.packageOverrides = append(i.packageOverrides, hiddenAPIPackageOverride{
+			module:   ctx.ModuleName(),
+			prefix:   prefix,
+			category: category,
+			bug:      bug,
+		})
+	}
+
+	for _, path := range android.PathsForModuleSrc(ctx, p.Hidden_api.Additional_flags_files) {
+		i.additionalFlagsFiles = append(i.additionalFlagsFiles, hiddenAPIAdditionalFlagsFile{
+			module: ctx.ModuleName(),
+			path:   path,
+		})
+	}
+}
+
 func (i *HiddenAPIPropertyInfo) gatherPropertyInfo(ctx android.ModuleContext, contents []android.Module) {
 	for _, module := range contents {
 		if ctx.OtherModuleHasProvider(module, hiddenAPIPropertyInfoProvider) {
@@ -775,6 +996,8 @@ func (i *HiddenAPIPropertyInfo) gatherPropertyInfo(ctx android.ModuleContext, co
 			i.PackagePrefixes = append(i.PackagePrefixes, info.PackagePrefixes...)
 			i.SinglePackages = append(i.SinglePackages, info.SinglePackages...)
 			i.SplitPackages = append(i.SplitPackages, info.SplitPackages...)
+			i.packageOverrides = append(i.packageOverrides, info.packageOverrides...)
+			i.additionalFlagsFiles = append(i.additionalFlagsFiles, info.additionalFlagsFiles...)
 		}
 	}
 
@@ -783,6 +1006,8 @@ func (i *HiddenAPIPropertyInfo) gatherPropertyInfo(ctx android.ModuleContext, co
 	i.PackagePrefixes = android.SortedUniqueStrings(i.PackagePrefixes)
 	i.SinglePackages = android.SortedUniqueStrings(i.SinglePackages)
 	i.SplitPackages = android.SortedUniqueStrings(i.SplitPackages)
+	i.packageOverrides = i.packageOverrides.normalize(ctx)
+	i.additionalFlagsFiles = i.additionalFlagsFiles.normalize()
 }
 
 // HiddenAPIFlagInput encapsulates information obtained from a module and its dependencies that are
@@ -967,9 +1192,13 @@ func pathForValidation(ctx android.PathContext, path android.WritablePath) andro
 //
 // hiddenAPIInfo is a struct containing paths to files that augment the information provided by
 // the annotationFlags.
+//
+// packagePrefixFilesByCategory contains the files of package prefixes whose members that are not
+// otherwise flagged are given the flag of the category.
 func buildRuleToGenerateHiddenApiFlags(ctx android.BuilderContext, name, desc string,
 	outputPath android.WritablePath, baseFlagsPath android.Path, annotationFlagPaths android.Paths,
-	flagFilesByCategory FlagFilesByCategory, flagSubsets SignatureCsvSubsets, generatedRemovedDexSignatures android.OptionalPath) {
+	flagFilesByCategory FlagFilesByCategory, packagePrefixFilesByCategory FlagFilesByCategory,
+	flagSubsets SignatureCsvSubsets, generatedRemovedDexSignatures android.OptionalPath) {
 
 	// Create the rule that will generate the flag files.
 	tempPath := tempPathForRestat(ctx, outputPath)
@@ -988,6 +1217,14 @@ func buildRuleToGenerateHiddenApiFlags(ctx android.BuilderContext, name, desc st
 		}
 	}
 
+	// Add the options for the package prefixes overridden by modules.
+	for _, category := range HiddenAPIFlagFileCategories {
+		for _, path := range packagePrefixFilesByCategory[category] {
+			category.commandMutator(command, path)
+			command.Flag("--package-prefixes ")
+		}
+	}
+
 	// If available then pass the automatically generated file containing dex signatures of removed
 	// API members to the rule so they can be marked as removed.
 	if generatedRemovedDexSignatures.Valid() {
@@ -1183,7 +1420,11 @@ func hiddenAPIFlagRulesForBootclasspathFragment(ctx android.ModuleContext, bootD
 	// Generate the all-flags.csv which are the flags that will, in future, be encoded into the dex
 	// files.
 	allFlagsCSV := android.PathForModuleOut(ctx, hiddenApiSubDir, "all-flags.csv")
-	buildRuleToGenerateHiddenApiFlags(ctx, "modularHiddenApiAllFlags"+suffix, "modular hiddenapi all flags"+suffix, allFlagsCSV, stubFlagsCSV, android.Paths{annotationFlagsCSV}, input.FlagFilesByCategory, nil, removedDexSignatures)
+	packagePrefixFiles := buildRuleToGeneratePackageOverrides(ctx, hiddenApiSubDir, input.packageOverrides)
+	flagFilesByCategory := FlagFilesByCategory{}
+	flagFilesByCategory.append(input.FlagFilesByCategory)
+	flagFilesByCategory.append(buildRuleToMergeAdditionalFlagsFiles(ctx, "modularHiddenApiAdditionalFlags"+suffix, hiddenApiSubDir, input.additionalFlagsFiles))
+	buildRuleToGenerateHiddenApiFlags(ctx, "modularHiddenApiAllFlags"+suffix, "modular hiddenapi all flags"+suffix, allFlagsCSV, stubFlagsCSV, android.Paths{annotationFlagsCSV}, flagFilesByCategory, packagePrefixFiles, nil, removedDexSignatures)
 
 	// Generate the filtered-stub-flags.csv file which contains the filtered stub flags that will be
 	// compared against the monolithic stub flags.
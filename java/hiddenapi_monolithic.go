@@ -48,6 +48,12 @@ type MonolithicHiddenAPIInfo struct {
 
 	// The classes jars from the libraries on the platform bootclasspath.
 	ClassesJars android.Paths
+
+	// The overrides of the flags of packages by the libraries on the platform bootclasspath.
+	packageOverrides hiddenAPIPackageOverrides
+
+	// The additional flags files of the libraries on the platform bootclasspath.
+	additionalFlagsFiles hiddenAPIAdditionalFlagsFiles
 }
 
 // newMonolithicHiddenAPIInfo creates a new MonolithicHiddenAPIInfo from the flagFilesByCategory
@@ -64,6 +70,11 @@ func newMonolithicHiddenAPIInfo(ctx android.ModuleContext, flagFilesByCategory F
 		case *ClasspathLibraryElement:
 			classesJars := retrieveClassesJarsFromModule(e.Module())
 			monolithicInfo.ClassesJars = append(monolithicInfo.ClassesJars, classesJars...)
+			if ctx.OtherModuleHasProvider(e.Module(), hiddenAPIPropertyInfoProvider) {
+				info := ctx.OtherModuleProvider(e.Module(), hiddenAPIPropertyInfoProvider).(HiddenAPIPropertyInfo)
+				monolithicInfo.packageOverrides = append(monolithicInfo.packageOverrides, info.packageOverrides...)
+				monolithicInfo.additionalFlagsFiles = append(monolithicInfo.additionalFlagsFiles, info.additionalFlagsFiles...)
+			}
 
 		case *ClasspathFragmentElement:
 			fragment := e.Module()
@@ -76,6 +87,10 @@ func newMonolithicHiddenAPIInfo(ctx android.ModuleContext, flagFilesByCategory F
 		}
 	}
 
+	// Two fragments, or a fragment and a library, could override the same package differently.
+	monolithicInfo.packageOverrides = monolithicInfo.packageOverrides.normalize(ctx)
+	monolithicInfo.additionalFlagsFiles = monolithicInfo.additionalFlagsFiles.normalize()
+
 	return monolithicInfo
 }
 
@@ -88,6 +103,8 @@ func (i *MonolithicHiddenAPIInfo) append(other *HiddenAPIInfo) {
 
 	i.StubFlagSubsets = append(i.StubFlagSubsets, other.StubFlagSubset())
 	i.FlagSubsets = append(i.FlagSubsets, other.FlagSubset())
+	i.packageOverrides = append(i.packageOverrides, other.packageOverrides...)
+	i.additionalFlagsFiles = append(i.additionalFlagsFiles, other.additionalFlagsFiles...)
 }
 
 var MonolithicHiddenAPIInfoProvider = blueprint.NewProvider(MonolithicHiddenAPIInfo{})
@@ -75,6 +75,7 @@ func registerJavaBuildComponents(ctx android.RegistrationContext) {
 
 	ctx.RegisterSingletonType("logtags", LogtagsSingleton)
 	ctx.RegisterSingletonType("kythe_java_extract", kytheExtractJavaFactory)
+	ctx.RegisterSingletonType("java_sdk_resolution", sdkResolutionReportSingletonFactory)
 }
 
 func RegisterJavaSdkMemberTypes() {
@@ -463,7 +464,7 @@ func sdkDeps(ctx android.BottomUpMutatorContext, sdkContext android.SdkContext,
 		}
 	}
 	if sdkDep.systemModules != "" {
-		ctx.AddVariationDependencies(nil, systemModulesTag, sdkDep.systemModules)
+		addSystemModulesDependency(ctx, sdkContext, sdkDep.systemModules)
 	}
 }
 
@@ -2249,6 +2250,11 @@ func (j *Import) OutputFiles(tag string) (android.Paths, error) {
 	switch tag {
 	case "", ".jar":
 		return android.Paths{j.combinedClasspathFile}, nil
+	case ".dexjar":
+		if j.dexJarFile.Valid() {
+			return android.Paths{j.dexJarFile.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no dex jar was found", tag)
 	default:
 		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
 	}
@@ -2411,6 +2417,10 @@ type DexImportProperties struct {
 
 	// set the name of the output
 	Stem *string
+
+	// Whether to store the dex files of the jar uncompressed and page aligned.  Defaults to whether
+	// the dex files of a module installed in the same location are stored uncompressed.
+	Uncompress_dex *bool
 }
 
 type DexImport struct {
@@ -2475,7 +2485,8 @@ func (j *DexImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 
 	j.dexpreopter.installPath = j.dexpreopter.getInstallPath(
 		ctx, android.PathForModuleInstall(ctx, "framework", j.Stem()+".jar"))
-	j.dexpreopter.uncompressedDex = shouldUncompressDex(ctx, &j.dexpreopter)
+	j.dexpreopter.uncompressedDex = proptools.BoolDefault(j.properties.Uncompress_dex,
+		shouldUncompressDex(ctx, &j.dexpreopter))
 
 	inputJar := ctx.ExpandSource(j.properties.Jars[0], "jars")
 	dexOutputFile := android.PathForModuleOut(ctx, ctx.ModuleName()+".jar")
@@ -2497,6 +2508,7 @@ func (j *DexImport) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		rule.Command().
 			BuiltTool("zipalign").
 			Flag("-f").
+			Flag("-p").
 			Text("4").
 			Input(temporary).
 			Output(dexOutputFile)
@@ -2526,6 +2538,20 @@ func (j *DexImport) DexJarBuildPath() OptionalDexJarPath {
 	return j.dexJarFile
 }
 
+func (j *DexImport) OutputFiles(tag string) (android.Paths, error) {
+	switch tag {
+	case ".dexjar":
+		if j.dexJarFile.Valid() {
+			return android.Paths{j.dexJarFile.Path()}, nil
+		}
+		return nil, fmt.Errorf("%q was requested, but no dex jar was found", tag)
+	default:
+		return nil, fmt.Errorf("unsupported module reference tag %q", tag)
+	}
+}
+
+var _ android.OutputFileProducer = (*DexImport)(nil)
+
 var _ android.ApexModule = (*DexImport)(nil)
 
 // Implements android.ApexModule
@@ -942,6 +942,132 @@ func TestGeneratedSources(t *testing.T) {
 	}
 }
 
+func TestDexJarOutputTag(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			compile_dex: true,
+		}
+
+		genrule {
+			name: "gen",
+			srcs: [":foo{.dexjar}"],
+			cmd: "cp $(in) $(out)",
+			out: ["foo.jar"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	foo.Rule("d8")
+	dexJar := foo.Module().(*Library).DexJarBuildPath().Path()
+
+	gen := result.ModuleForTests("gen", "").Rule("generator")
+	android.AssertDeepEquals(t, "genrule inputs", []string{dexJar.String()}, gen.Inputs.Strings())
+}
+
+func TestDexJarOutputTagImports(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_import {
+			name: "foo",
+			jars: ["a.jar"],
+			sdk_version: "current",
+			compile_dex: true,
+		}
+
+		dex_import {
+			name: "bar",
+			jars: ["b.jar"],
+		}
+
+		genrule {
+			name: "gen",
+			srcs: [
+				":foo{.dexjar}",
+				":bar{.dexjar}",
+			],
+			cmd: "cp $(in) $(genDir)",
+			out: ["foo.jar", "bar.jar"],
+		}
+	`)
+
+	fooDexJar := result.ModuleForTests("foo", "android_common").Module().(*Import).DexJarBuildPath().Path()
+	barDexJar := result.ModuleForTests("bar", "android_common").Module().(*DexImport).DexJarBuildPath().Path()
+
+	gen := result.ModuleForTests("gen", "").Rule("generator")
+	android.AssertDeepEquals(t, "genrule inputs", []string{fooDexJar.String(), barDexJar.String()},
+		gen.Inputs.Strings())
+}
+
+func TestDexJarOutputTagWithoutCompileDex(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	android.AssertBoolEquals(t, "has d8 rule", false, foo.MaybeRule("d8").Rule != nil)
+
+	_, err := foo.Module().(android.OutputFileProducer).OutputFiles(".dexjar")
+	android.AssertErrorMessageEquals(t, "dexjar error",
+		`".dexjar" was requested, but no dex jar was found, set compile_dex: true`, err)
+}
+
+func TestDexJarOutputTagUncompressDex(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			compile_dex: true,
+			uncompress_dex: true,
+		}
+
+		java_import {
+			name: "bar",
+			jars: ["a.jar"],
+			sdk_version: "current",
+			compile_dex: true,
+			uncompress_dex: true,
+		}
+
+		dex_import {
+			name: "baz",
+			jars: ["b.jar"],
+			uncompress_dex: true,
+		}
+
+		dex_import {
+			name: "qux",
+			jars: ["b.jar"],
+			uncompress_dex: false,
+		}
+	`)
+
+	dexJar := func(name string) string {
+		module := result.ModuleForTests(name, "android_common").Module()
+		paths, err := module.(android.OutputFileProducer).OutputFiles(".dexjar")
+		android.FailIfErrored(t, []error{err})
+		return android.PathRelativeToTop(paths[0])
+	}
+
+	// The dex files of libraries with uncompress_dex: true are stored uncompressed and aligned.
+	for _, name := range []string{"foo", "bar"} {
+		module := result.ModuleForTests(name, "android_common")
+		android.AssertStringDoesContain(t, name+" d8 zip flags", module.Rule("d8").Args["zipFlags"], "-L 0")
+		android.AssertPathRelativeToTopEquals(t, name+" dexjar", dexJar(name), module.Rule("zipalign").Output)
+	}
+
+	baz := result.ModuleForTests("baz", "android_common").Rule("uncompress_dex")
+	android.AssertStringDoesContain(t, "baz uncompress_dex command", baz.RuleParams.Command, "zipalign -f -p 4 ")
+	android.AssertPathRelativeToTopEquals(t, "baz dexjar", dexJar("baz"), baz.Output)
+
+	qux := result.ModuleForTests("qux", "android_common")
+	android.AssertBoolEquals(t, "qux uncompresses dex", false, qux.MaybeRule("uncompress_dex").Rule != nil)
+	android.AssertStringEquals(t, "qux dexjar", "out/soong/.intermediates/qux/android_common/qux.jar", dexJar("qux"))
+}
+
 func TestTurbine(t *testing.T) {
 	result := android.GroupFixturePreparers(
 		prepareForJavaTest, FixtureWithPrebuiltApis(map[string][]string{"14": {"foo"}})).
@@ -2275,3 +2401,30 @@ java_test_host {
 		t.Errorf("Expected args[\"extraConfigs\"] to equal %q, was %q", expected, args["extraConfigs"])
 	}
 }
+
+func TestHostAndDeviceVariantPairs(t *testing.T) {
+	result := PrepareForTestWithJavaDefaultModules.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			host_supported: true,
+		}
+
+		java_library_host {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+
+	device := result.Module("foo", "android_common")
+	host := result.Module("foo", buildOS+"_common")
+	android.AssertSame(t, "host variant of foo", host, result.HostVariantOf(device))
+	android.AssertSame(t, "device variant of foo", device, result.DeviceVariantOf(host))
+
+	bar := result.Module("bar", buildOS+"_common")
+	if device := result.DeviceVariantOf(bar); device != nil {
+		t.Errorf("expected no device variant of bar, found %q", result.ModuleSubDir(device))
+	}
+}
@@ -323,7 +323,11 @@ func (b *platformBootclasspathModule) generateHiddenAPIBuildActions(ctx android.
 	allAnnotationFlagFiles := android.Paths{annotationFlags}
 	allAnnotationFlagFiles = append(allAnnotationFlagFiles, monolithicInfo.AnnotationFlagsPaths...)
 	allFlags := hiddenAPISingletonPaths(ctx).flags
-	buildRuleToGenerateHiddenApiFlags(ctx, "hiddenAPIFlagsFile", "monolithic hidden API flags", allFlags, stubFlags, allAnnotationFlagFiles, monolithicInfo.FlagsFilesByCategory, monolithicInfo.FlagSubsets, android.OptionalPath{})
+	packagePrefixFiles := buildRuleToGeneratePackageOverrides(ctx, "hiddenapi-monolithic", monolithicInfo.packageOverrides)
+	flagFilesByCategory := FlagFilesByCategory{}
+	flagFilesByCategory.append(monolithicInfo.FlagsFilesByCategory)
+	flagFilesByCategory.append(buildRuleToMergeAdditionalFlagsFiles(ctx, "hiddenAPIAdditionalFlags", "hiddenapi-monolithic", monolithicInfo.additionalFlagsFiles))
+	buildRuleToGenerateHiddenApiFlags(ctx, "hiddenAPIFlagsFile", "monolithic hidden API flags", allFlags, stubFlags, allAnnotationFlagFiles, flagFilesByCategory, packagePrefixFiles, monolithicInfo.FlagSubsets, android.OptionalPath{})
 
 	// Generate an intermediate monolithic hiddenapi-metadata.csv file directly from the annotations
 	// in the source code.
@@ -15,6 +15,7 @@
 package java
 
 import (
+	"fmt"
 	"testing"
 
 	"android/soong/android"
@@ -357,3 +358,138 @@ func TestPlatformBootclasspath_HiddenAPIMonolithicFiles(t *testing.T) {
 		out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/index-from-classes.csv
 	`, rule)
 }
+
+func TestPlatformBootclasspath_HiddenAPIPackageOverrides(t *testing.T) {
+	preparer := android.GroupFixturePreparers(
+		hiddenApiFixtureFactory,
+		FixtureConfigureBootJars("platform:foo", "platform:bar"),
+	)
+
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			compile_dex: true,
+			hidden_api: {
+				package_overrides: [
+					{
+						prefix: "foo.internal",
+						flag: "max_target_o_low_priority",
+						bug: "b/123",
+					},
+					{
+						prefix: "shared",
+						flag: "blocked",
+						bug: "b/456",
+					},
+				],
+			},
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			compile_dex: true,
+			hidden_api: {
+				package_overrides: [
+					{
+						prefix: "shared",
+						flag: "%s",
+						bug: "b/789",
+					},
+				],
+			},
+		}
+
+		platform_bootclasspath {
+			name: "myplatform-bootclasspath",
+		}
+	`
+
+	t.Run("merge", func(t *testing.T) {
+		result := preparer.RunTestWithBp(t, fmt.Sprintf(bp, "blocked"))
+		platformBootclasspath := result.ModuleForTests("myplatform-bootclasspath", "android_common")
+
+		// The overrides of all the libraries are recorded with their bugs.
+		csv := platformBootclasspath.Output("hiddenapi-monolithic/package-overrides.csv")
+		android.AssertStringEquals(t, "package-overrides.csv", ""+
+			"prefix,flag,module,bug\n"+
+			"foo.internal,max_target_o_low_priority,foo,b/123\n"+
+			"shared,blocked,bar,b/789\n"+
+			"shared,blocked,foo,b/456\n",
+			android.ContentFromFileRuleForTests(t, csv))
+
+		blocked := platformBootclasspath.Output("hiddenapi-monolithic/package-overrides/blocked.txt")
+		android.AssertStringEquals(t, "blocked.txt", "shared", android.ContentFromFileRuleForTests(t, blocked))
+		maxTargetO := platformBootclasspath.Output("hiddenapi-monolithic/package-overrides/max_target_o_low_priority.txt")
+		android.AssertStringEquals(t, "max_target_o_low_priority.txt", "foo.internal", android.ContentFromFileRuleForTests(t, maxTargetO))
+
+		// The package prefixes are passed to the generation of the monolithic flags.
+		rule := platformBootclasspath.Output("out/soong/hiddenapi/hiddenapi-flags.csv")
+		android.AssertStringDoesContain(t, "hiddenapi-flags.csv command", rule.RuleParams.Command,
+			"--blocked out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/package-overrides/blocked.txt --package-prefixes")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		preparer.
+			ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`module "myplatform-bootclasspath".*: hidden_api.package_overrides: "shared" is overridden with max_target_q by "bar" and with blocked by "foo"`)).
+			RunTestWithBp(t, fmt.Sprintf(bp, "max_target_q"))
+	})
+
+	t.Run("invalid flag", func(t *testing.T) {
+		preparer.
+			ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+				`module "bar".*: hidden_api.package_overrides: "shared" cannot be overridden with unknown flag "removed"`)).
+			RunTestWithBp(t, fmt.Sprintf(bp, "removed"))
+	})
+}
+
+func TestPlatformBootclasspath_HiddenAPIAdditionalFlagsFiles(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		hiddenApiFixtureFactory,
+		FixtureConfigureBootJars("platform:foo", "platform:bar"),
+		android.FixtureMergeMockFs(android.MockFS{
+			"foo-flags.csv": nil,
+			"bar-flags.csv": nil,
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			compile_dex: true,
+			hidden_api: {
+				additional_flags_files: ["foo-flags.csv"],
+			},
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.java"],
+			compile_dex: true,
+			hidden_api: {
+				additional_flags_files: ["bar-flags.csv"],
+			},
+		}
+
+		platform_bootclasspath {
+			name: "myplatform-bootclasspath",
+		}
+	`)
+	platformBootclasspath := result.ModuleForTests("myplatform-bootclasspath", "android_common")
+
+	// The files of all the libraries are merged, with the modules that provide them so that
+	// conflicts can name them.
+	merge := platformBootclasspath.Output("hiddenapi-monolithic/additional-flags/blocked.txt")
+	command := android.StringRelativeToTop(result.Config, merge.RuleParams.Command)
+	android.AssertStringDoesContain(t, "merge command", command,
+		"--module-flags bar:bar-flags.csv --module-flags foo:foo-flags.csv")
+	android.AssertStringDoesContain(t, "merge command", command,
+		"--output blocked:out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/additional-flags/blocked.txt")
+	android.AssertStringDoesNotContain(t, "merge command", command, "--output removed:")
+
+	// The merged signatures are passed to the generation of the monolithic flags.
+	rule := platformBootclasspath.Output("out/soong/hiddenapi/hiddenapi-flags.csv")
+	android.AssertStringDoesContain(t, "hiddenapi-flags.csv command", rule.RuleParams.Command,
+		"--blocked out/soong/.intermediates/myplatform-bootclasspath/android_common/hiddenapi-monolithic/additional-flags/blocked.txt")
+}
@@ -106,6 +106,11 @@ func (p *platformCompatConfig) GenerateAndroidBuildActions(ctx android.ModuleCon
 	p.installDirPath = android.PathForModuleInstall(ctx, "etc", "compatconfig")
 	rule.Build(configFileName, "Extract compat/compat_config.xml and install it")
 
+	ctx.SetProvider(android.InterfaceOutputsProvider, android.InterfaceOutputsInfo{
+		Outputs: map[string]android.Path{
+			p.Name() + ".compat_config.xml": p.configFile,
+		},
+	})
 }
 
 func (p *platformCompatConfig) AndroidMkEntries() []android.AndroidMkEntries {
@@ -41,4 +41,9 @@ func TestPlatformCompatConfig(t *testing.T) {
 		"out/soong/.intermediates/myconfig2/myconfig2_meta.xml",
 		"out/soong/.intermediates/myconfig3/myconfig3_meta.xml",
 	)
+
+	myconfig1 := result.ModuleForTests("myconfig1", "").Module()
+	info := result.ModuleProvider(myconfig1, android.InterfaceOutputsProvider).(android.InterfaceOutputsInfo)
+	android.AssertPathRelativeToTopEquals(t, "interface output",
+		"out/soong/.intermediates/myconfig1/myconfig1.xml", info.Outputs["myconfig1.compat_config.xml"])
 }
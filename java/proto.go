@@ -20,6 +20,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/bazel"
+	"android/soong/java/config"
 
 	"github.com/google/blueprint/proptools"
 )
@@ -79,26 +80,51 @@ func genProto(ctx android.ModuleContext, protoFiles android.Paths, flags android
 	return srcJarFiles
 }
 
-func protoDeps(ctx android.BottomUpMutatorContext, p *android.ProtoProperties) {
+func protoDeps(ctx android.BottomUpMutatorContext, p *android.ProtoProperties, libs, staticLibs []string) {
 	const unspecifiedProtobufPluginType = ""
 	if String(p.Proto.Plugin) == "" {
-		switch String(p.Proto.Type) {
+		typ := String(p.Proto.Type)
+		if migrateTo, removed := config.RemovedProtoTypes[typ]; removed {
+			ctx.PropertyErrorf("proto.type", "%q java protos are no longer supported, use %q instead",
+				typ, migrateTo)
+			return
+		}
+
+		switch typ {
 		case "stream": // does not require additional dependencies
-		case "micro":
-			ctx.AddVariationDependencies(nil, staticLibTag, "libprotobuf-java-micro")
-		case "nano":
-			ctx.AddVariationDependencies(nil, staticLibTag, "libprotobuf-java-nano")
-		case "lite", unspecifiedProtobufPluginType:
-			ctx.AddVariationDependencies(nil, staticLibTag, "libprotobuf-java-lite")
+			return
+		case unspecifiedProtobufPluginType:
+			typ = protoTypeDefault
 		case "full":
-			if ctx.Host() {
-				ctx.AddVariationDependencies(nil, staticLibTag, "libprotobuf-java-full")
-			} else {
+			if !ctx.Host() {
 				ctx.PropertyErrorf("proto.type", "full java protos only supported on the host")
+				return
 			}
-		default:
-			ctx.PropertyErrorf("proto.type", "unknown proto type %q",
-				String(p.Proto.Type))
+		}
+
+		runtime, ok := config.ProtoRuntimeLibraries[typ]
+		if !ok {
+			ctx.PropertyErrorf("proto.type", "unknown proto type %q", typ)
+			return
+		}
+		checkProtoRuntimeConflicts(ctx, typ, "libs", libs)
+		checkProtoRuntimeConflicts(ctx, typ, "static_libs", staticLibs)
+		ctx.AddVariationDependencies(nil, staticLibTag, runtime)
+	}
+}
+
+// checkProtoRuntimeConflicts reports the libraries in a property that are the runtime of a java
+// proto.type other than typ, as the code generated for typ fails at runtime when it is linked
+// against them.
+func checkProtoRuntimeConflicts(ctx android.BottomUpMutatorContext, typ, property string, libs []string) {
+	for _, otherTyp := range android.SortedKeys(config.ProtoRuntimeLibraries) {
+		otherRuntime := config.ProtoRuntimeLibraries[otherTyp]
+		if otherTyp == typ || otherRuntime == config.ProtoRuntimeLibraries[typ] {
+			continue
+		}
+		if android.InList(otherRuntime, libs) {
+			ctx.PropertyErrorf(property, "%q is the runtime of %q java protos, it conflicts with the "+
+				"%q runtime added for proto.type %q", otherRuntime, otherTyp, config.ProtoRuntimeLibraries[typ], typ)
 		}
 	}
 }
@@ -114,12 +140,6 @@ func protoFlags(ctx android.ModuleContext, j *CommonProperties, p *android.Proto
 		case "stream":
 			flags.proto.OutTypeFlag = "--javastream_out"
 			typeToPlugin = "javastream"
-		case "micro":
-			flags.proto.OutTypeFlag = "--javamicro_out"
-			typeToPlugin = "javamicro"
-		case "nano":
-			flags.proto.OutTypeFlag = "--javanano_out"
-			typeToPlugin = "javanano"
 		case "lite", "":
 			flags.proto.OutTypeFlag = "--java_out"
 			flags.proto.OutParams = append(flags.proto.OutParams, "lite")
@@ -155,15 +175,15 @@ func bp2buildProto(ctx android.Bp2buildMutatorContext, m *Module, protoSrcs baze
 	}
 
 	typ := proptools.StringDefault(protoInfo.Type, protoTypeDefault)
+	if migrateTo, removed := config.RemovedProtoTypes[typ]; removed {
+		ctx.PropertyErrorf("proto.type", "%q java protos are no longer supported, use %q instead",
+			typ, migrateTo)
+		return nil
+	}
+
 	var rule_class string
 	suffix := "_java_proto"
 	switch typ {
-	case "nano":
-		suffix += "_nano"
-		rule_class = "java_nano_proto_library"
-	case "micro":
-		suffix += "_micro"
-		rule_class = "java_micro_proto_library"
 	case "lite":
 		suffix += "_lite"
 		rule_class = "java_lite_proto_library"
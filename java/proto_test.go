@@ -15,10 +15,13 @@
 package java
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
 	"android/soong/android"
+
+	"github.com/google/blueprint"
 )
 
 const protoModules = `
@@ -51,3 +54,79 @@ func TestProtoStream(t *testing.T) {
 		t.Errorf("expected '--javastream_out' in %q", cmd)
 	}
 }
+
+func TestProtoRuntime(t *testing.T) {
+	bp := `
+		java_library_static {
+			name: "libprotobuf-java-full",
+		}
+
+		java_library {
+			name: "java-lite-protos",
+			srcs: ["a.proto"],
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		PrepareForIntegrationTestWithJava,
+	).RunTestWithBp(t, protoModules+bp)
+
+	module := result.ModuleForTests("java-lite-protos", "android_common").Module()
+	var deps []string
+	result.VisitDirectDeps(module, func(m blueprint.Module) {
+		deps = append(deps, m.Name())
+	})
+	android.AssertStringListContains(t, "deps", deps, "libprotobuf-java-lite")
+	android.AssertStringListDoesNotContain(t, "deps", deps, "libprotobuf-java-full")
+}
+
+func TestProtoRuntimeErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		bp            string
+		expectedError string
+	}{
+		{
+			name: "conflicting runtime",
+			bp: `
+				java_library {
+					name: "java-lite-protos",
+					srcs: ["a.proto"],
+					proto: {
+						type: "lite",
+					},
+					static_libs: ["libprotobuf-java-full"],
+				}
+			`,
+			expectedError: `static_libs: "libprotobuf-java-full" is the runtime of "full" java protos, ` +
+				`it conflicts with the "libprotobuf-java-lite" runtime added for proto.type "lite"`,
+		},
+		{
+			name: "removed type",
+			bp: `
+				java_library {
+					name: "java-nano-protos",
+					srcs: ["a.proto"],
+					proto: {
+						type: "nano",
+					},
+				}
+			`,
+			expectedError: `proto.type: "nano" java protos are no longer supported, use "lite" instead`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			android.GroupFixturePreparers(
+				PrepareForIntegrationTestWithJava,
+			).
+				ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(tc.expectedError))).
+				RunTestWithBp(t, protoModules+`
+					java_library_static {
+						name: "libprotobuf-java-full",
+					}
+				`+tc.bp)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// The sdk resolution report lists, for every device java module, the system modules and the
+// bootclasspath jars that its sdk_version resolved to.  It is written to
+// out/soong/java_sdk_resolution.json when SOONG_JAVA_SDK_RESOLUTION_REPORT is set to true.
+
+const SdkResolutionReportEnv = "SOONG_JAVA_SDK_RESOLUTION_REPORT"
+
+// SdkResolutionInfo records what the sdk_version of a device java module resolved to.
+type SdkResolutionInfo struct {
+	// The sdk_version property of the module, after any adjustment for the vendor partition.
+	SdkVersion string
+
+	// The scope of the API the module is compiled against, e.g. public, system or module-lib.
+	SdkKind android.SdkKind
+
+	// The name of the system modules module the module is compiled against, or an empty string if
+	// it does not use system modules.
+	SystemModules string
+
+	// The jars on the bootclasspath of the module.
+	BootclasspathJars android.Paths
+}
+
+var SdkResolutionInfoProvider = blueprint.NewProvider(SdkResolutionInfo{})
+
+// systemModulesSelectedBy describes the properties that selected the system modules of a module,
+// for use in error messages.
+func systemModulesSelectedBy(ctx android.EarlyModuleContext, sdkContext android.SdkContext) string {
+	sdkVersion := sdkContext.SdkVersion(ctx)
+	if sdkVersion.Kind == android.SdkNone {
+		return `sdk_version: "none" -> system_modules property`
+	}
+	selectedBy := fmt.Sprintf("sdk_version: %q", sdkVersion.Raw)
+	if sdkVersion.Raw == "" {
+		selectedBy = "default sdk_version"
+	}
+	if ctx.DeviceSpecific() || ctx.SocSpecific() {
+		if vendor := sdkVersion.ForVendorPartition(ctx); vendor.Raw != sdkVersion.Raw {
+			selectedBy += fmt.Sprintf(" -> vendor partition sdk_version: %q", vendor.Raw)
+		}
+	}
+	return selectedBy
+}
+
+// addSystemModulesDependency adds a dependency on the system modules selected by decodeSdkDep,
+// reporting the properties that selected them if they do not exist.
+func addSystemModulesDependency(ctx android.BottomUpMutatorContext, sdkContext android.SdkContext, systemModules string) {
+	if !ctx.OtherModuleExists(systemModules) && !ctx.Config().AllowMissingDependencies() {
+		ctx.ModuleErrorf("%s -> system modules %q, which do not exist",
+			systemModulesSelectedBy(ctx, sdkContext), systemModules)
+		return
+	}
+	ctx.AddVariationDependencies(nil, systemModulesTag, systemModules)
+}
+
+func sdkResolutionReportSingletonFactory() android.Singleton {
+	return &sdkResolutionReportSingleton{}
+}
+
+type sdkResolutionReportSingleton struct{}
+
+type sdkResolutionReportModule struct {
+	Name              string   `json:"name"`
+	Variant           string   `json:"variant"`
+	SdkVersion        string   `json:"sdk_version"`
+	SdkKind           string   `json:"sdk_kind"`
+	SystemModules     string   `json:"system_modules"`
+	BootclasspathJars []string `json:"bootclasspath_jars"`
+}
+
+func (s *sdkResolutionReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ctx.Config().IsEnvTrue(SdkResolutionReportEnv) {
+		return
+	}
+
+	report := []sdkResolutionReportModule{}
+	ctx.VisitAllModules(func(module android.Module) {
+		if !ctx.ModuleHasProvider(module, SdkResolutionInfoProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, SdkResolutionInfoProvider).(SdkResolutionInfo)
+		report = append(report, sdkResolutionReportModule{
+			Name:              ctx.ModuleName(module),
+			Variant:           ctx.ModuleSubDir(module),
+			SdkVersion:        info.SdkVersion,
+			SdkKind:           info.SdkKind.String(),
+			SystemModules:     info.SystemModules,
+			BootclasspathJars: info.BootclasspathJars.Strings(),
+		})
+	})
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Name != report[j].Name {
+			return report[i].Name < report[j].Name
+		}
+		return report[i].Variant < report[j].Variant
+	})
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		ctx.Errorf("failed to marshal java sdk resolution report: %s", err)
+		return
+	}
+	android.WriteFileRule(ctx, android.PathForOutput(ctx, "java_sdk_resolution.json"), string(data))
+}
@@ -0,0 +1,119 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"regexp"
+	"testing"
+
+	"android/soong/android"
+)
+
+var prepareForSdkResolutionTest = android.GroupFixturePreparers(
+	prepareForJavaTest,
+	FixtureWithPrebuiltApis(map[string][]string{
+		"30":      {},
+		"current": {},
+	}),
+)
+
+func TestSdkResolutionInfo(t *testing.T) {
+	testCases := []struct {
+		name              string
+		sdkVersion        string
+		sdkKind           android.SdkKind
+		systemModules     string
+		bootclasspathJars []string
+	}{
+		{
+			name:              "core_current",
+			sdkVersion:        "core_current",
+			sdkKind:           android.SdkCore,
+			systemModules:     "core-public-stubs-system-modules",
+			bootclasspathJars: []string{"core.current.stubs", "core-lambda-stubs"},
+		},
+		{
+			name:              "module_current",
+			sdkVersion:        "module_current",
+			sdkKind:           android.SdkModule,
+			systemModules:     "core-module-lib-stubs-system-modules",
+			bootclasspathJars: []string{"android_module_lib_stubs_current", "core-lambda-stubs"},
+		},
+		{
+			name:          "numbered",
+			sdkVersion:    "30",
+			sdkKind:       android.SdkPublic,
+			systemModules: "sdk_public_30_system_modules",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := prepareForSdkResolutionTest.RunTestWithBp(t, `
+				java_library {
+					name: "foo",
+					srcs: ["a.java"],
+					sdk_version: "`+tc.sdkVersion+`",
+				}
+			`)
+
+			foo := result.ModuleForTests("foo", "android_common").Module()
+			info := result.ModuleProvider(foo, SdkResolutionInfoProvider).(SdkResolutionInfo)
+
+			android.AssertStringEquals(t, "sdk version", tc.sdkVersion, info.SdkVersion)
+			android.AssertStringEquals(t, "sdk kind", tc.sdkKind.String(), info.SdkKind.String())
+			android.AssertStringEquals(t, "system modules", tc.systemModules, info.SystemModules)
+
+			var bootclasspathJars []string
+			for _, jar := range tc.bootclasspathJars {
+				bootclasspathJars = append(bootclasspathJars, defaultModuleToPath(jar))
+			}
+			android.AssertPathsRelativeToTopEquals(t, "bootclasspath jars", bootclasspathJars, info.BootclasspathJars)
+		})
+	}
+}
+
+func TestSdkResolutionMissingSystemModules(t *testing.T) {
+	prepareForSdkResolutionTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(
+			`sdk_version: "none" -> system_modules property -> system modules "missing-system-modules", which do not exist`))).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+				sdk_version: "none",
+				system_modules: "missing-system-modules",
+			}
+		`)
+}
+
+func TestSdkResolutionReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForSdkResolutionTest,
+		android.FixtureMergeEnv(map[string]string{
+			SdkResolutionReportEnv: "true",
+		}),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "module_current",
+		}
+	`)
+
+	report := android.ContentFromFileRuleForTests(t, result.SingletonForTests("java_sdk_resolution").Output("java_sdk_resolution.json"))
+	android.AssertStringDoesContain(t, "report", report, `"name": "foo",`)
+	android.AssertStringDoesContain(t, "report", report, `"system_modules": "core-module-lib-stubs-system-modules",`)
+}
@@ -19,6 +19,7 @@ import (
 	"strings"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
 )
@@ -46,35 +47,50 @@ func RegisterSystemModulesBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("java_system_modules_import", systemModulesImportFactory)
 }
 
+// The commands that create the system modules from the module-info.java in ${workDir} and the
+// jars, shared by the rules that differ in how the module-info.java is created.
+const moduleInfoToSystemModulesCommand = `${config.JavacCmd} --system=none --patch-module=java.base=${classpath} ${workDir}/module-info.java && ` +
+	`${config.SoongZipCmd} -jar -o ${workDir}/classes.jar -C ${workDir} -f ${workDir}/module-info.class && ` +
+	`${config.MergeZipsCmd} -j ${workDir}/module.jar ${workDir}/classes.jar $in && ` +
+	// Note: The version of the java.base module created must match the version
+	// of the jlink tool which consumes it.
+	`${config.JmodCmd} create --module-version ${config.JlinkVersion} --target-platform android ` +
+	`  --class-path ${workDir}/module.jar ${workDir}/jmod/java.base.jmod && ` +
+	`${config.JlinkCmd} --module-path ${workDir}/jmod --add-modules java.base --output ${outDir} ` +
+	// Note: The system-modules jlink plugin is disabled because (a) it is not
+	// useful on Android, and (b) it causes errors with later versions of jlink
+	// when the jdk.internal.module is absent from java.base (as it is here).
+	`  --disable-plugin system-modules && ` +
+	`cp ${config.JrtFsJar} ${outDir}/lib/`
+
+var moduleInfoToSystemModulesCommandDeps = []string{
+	"${config.JavacCmd}",
+	"${config.SoongZipCmd}",
+	"${config.MergeZipsCmd}",
+	"${config.JmodCmd}",
+	"${config.JlinkCmd}",
+	"${config.JrtFsJar}",
+}
+
 var (
 	jarsTosystemModules = pctx.AndroidStaticRule("jarsTosystemModules", blueprint.RuleParams{
 		Command: `rm -rf ${outDir} ${workDir} && mkdir -p ${workDir}/jmod && ` +
 			`${moduleInfoJavaPath} java.base $in > ${workDir}/module-info.java && ` +
-			`${config.JavacCmd} --system=none --patch-module=java.base=${classpath} ${workDir}/module-info.java && ` +
-			`${config.SoongZipCmd} -jar -o ${workDir}/classes.jar -C ${workDir} -f ${workDir}/module-info.class && ` +
-			`${config.MergeZipsCmd} -j ${workDir}/module.jar ${workDir}/classes.jar $in && ` +
-			// Note: The version of the java.base module created must match the version
-			// of the jlink tool which consumes it.
-			`${config.JmodCmd} create --module-version ${config.JlinkVersion} --target-platform android ` +
-			`  --class-path ${workDir}/module.jar ${workDir}/jmod/java.base.jmod && ` +
-			`${config.JlinkCmd} --module-path ${workDir}/jmod --add-modules java.base --output ${outDir} ` +
-			// Note: The system-modules jlink plugin is disabled because (a) it is not
-			// useful on Android, and (b) it causes errors with later versions of jlink
-			// when the jdk.internal.module is absent from java.base (as it is here).
-			`  --disable-plugin system-modules && ` +
-			`cp ${config.JrtFsJar} ${outDir}/lib/`,
-		CommandDeps: []string{
-			"${moduleInfoJavaPath}",
-			"${config.JavacCmd}",
-			"${config.SoongZipCmd}",
-			"${config.MergeZipsCmd}",
-			"${config.JmodCmd}",
-			"${config.JlinkCmd}",
-			"${config.JrtFsJar}",
-		},
+			moduleInfoToSystemModulesCommand,
+		CommandDeps: append([]string{"${moduleInfoJavaPath}"}, moduleInfoToSystemModulesCommandDeps...),
 	},
 		"classpath", "outDir", "workDir")
 
+	// A rule for creating the system modules from a module-info.java generated by
+	// generateSystemModulesModuleInfo.
+	moduleInfoTosystemModules = pctx.AndroidStaticRule("moduleInfoTosystemModules", blueprint.RuleParams{
+		Command: `rm -rf ${outDir} ${workDir} && mkdir -p ${workDir}/jmod && ` +
+			`cp ${moduleInfo} ${workDir}/module-info.java && ` +
+			moduleInfoToSystemModulesCommand,
+		CommandDeps: moduleInfoToSystemModulesCommandDeps,
+	},
+		"classpath", "outDir", "workDir", "moduleInfo")
+
 	// Dependency tag that causes the added dependencies to be added as java_header_libs
 	// to the sdk/module_exports/snapshot. Dependencies that are added automatically via this tag are
 	// not automatically exported.
@@ -82,6 +98,12 @@ var (
 )
 
 func TransformJarsToSystemModules(ctx android.ModuleContext, jars android.Paths) (android.Path, android.Paths) {
+	return transformJarsToSystemModules(ctx, jars, nil)
+}
+
+// transformJarsToSystemModules creates the system modules from the jars, with the module-info.java
+// exporting all the packages in the jars unless moduleInfo is not nil.
+func transformJarsToSystemModules(ctx android.ModuleContext, jars android.Paths, moduleInfo android.Path) (android.Path, android.Paths) {
 	outDir := android.PathForModuleOut(ctx, "system")
 	workDir := android.PathForModuleOut(ctx, "modules")
 	outputFile := android.PathForModuleOut(ctx, "system/lib/modules")
@@ -91,7 +113,7 @@ func TransformJarsToSystemModules(ctx android.ModuleContext, jars android.Paths)
 		android.PathForModuleOut(ctx, "system/release"),
 	}
 
-	ctx.Build(pctx, android.BuildParams{
+	params := android.BuildParams{
 		Rule:        jarsTosystemModules,
 		Description: "system modules",
 		Outputs:     outputs,
@@ -101,7 +123,13 @@ func TransformJarsToSystemModules(ctx android.ModuleContext, jars android.Paths)
 			"workDir":   workDir.String(),
 			"outDir":    outDir.String(),
 		},
-	})
+	}
+	if moduleInfo != nil {
+		params.Rule = moduleInfoTosystemModules
+		params.Implicit = moduleInfo
+		params.Args["moduleInfo"] = moduleInfo.String()
+	}
+	ctx.Build(pctx, params)
 
 	return outDir, outputs.Paths()
 }
@@ -142,6 +170,16 @@ type SystemModules struct {
 type SystemModulesProperties struct {
 	// List of java library modules that should be included in the system modules
 	Libs []string
+
+	// If true, the module-info.java of the system modules is generated from the packages of each
+	// of the libs, which fails the build if a package is in more than one of the libs and is not
+	// listed in split_packages.  Otherwise the module-info.java exports all the packages of the
+	// libs without checking them.  Defaults to false.
+	Generate_module_info *bool
+
+	// The packages that are allowed to be split between more than one of the libs.  Only used if
+	// generate_module_info is true.
+	Split_packages []string
 }
 
 func (system *SystemModules) HeaderJars() android.Paths {
@@ -157,15 +195,65 @@ func (system *SystemModules) OutputDirAndDeps() (android.Path, android.Paths) {
 
 func (system *SystemModules) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	var jars android.Paths
+	var libPackages []systemModulesLibPackages
 
 	ctx.VisitDirectDepsWithTag(systemModulesLibsTag, func(module android.Module) {
 		dep, _ := ctx.OtherModuleProvider(module, JavaInfoProvider).(JavaInfo)
 		jars = append(jars, dep.HeaderJars...)
+		libPackages = append(libPackages, systemModulesLibPackages{
+			name: ctx.OtherModuleName(module),
+			jars: dep.HeaderJars,
+		})
 	})
 
 	system.headerJars = jars
 
-	system.outputDir, system.outputDeps = TransformJarsToSystemModules(ctx, jars)
+	var moduleInfo android.Path
+	if proptools.Bool(system.properties.Generate_module_info) {
+		moduleInfo = generateSystemModulesModuleInfo(ctx, libPackages, system.properties.Split_packages)
+	} else if len(system.properties.Split_packages) > 0 {
+		ctx.PropertyErrorf("split_packages", "can only be set if generate_module_info is true")
+	}
+
+	system.outputDir, system.outputDeps = transformJarsToSystemModules(ctx, jars, moduleInfo)
+}
+
+// systemModulesLibPackages is a library of a java_system_modules whose packages are listed for
+// the generated module-info.java.
+type systemModulesLibPackages struct {
+	name string
+	jars android.Paths
+}
+
+// generateSystemModulesModuleInfo generates a module-info.java for the java.base module that
+// exports the packages of the libs, listing the packages of each lib separately so that a package
+// that is in more than one of the libs and not in splitPackages fails the build.
+func generateSystemModulesModuleInfo(ctx android.ModuleContext, libs []systemModulesLibPackages,
+	splitPackages []string) android.Path {
+
+	moduleInfo := android.PathForModuleOut(ctx, "module-info", "module-info.java")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	var packagesFlags []string
+	for _, lib := range libs {
+		packages := android.PathForModuleOut(ctx, "module-info", "packages", lib.name+".txt")
+		rule.Command().
+			BuiltTool("system_modules_packages").
+			Text("list").
+			FlagWithOutput("--out ", packages).
+			Inputs(lib.jars)
+		packagesFlags = append(packagesFlags, lib.name+"="+packages.String())
+	}
+	rule.Command().
+		BuiltTool("system_modules_packages").
+		Text("module-info").
+		FlagWithArg("--module ", "java.base").
+		FlagForEachArg("--split-package ", android.SortedUniqueStrings(splitPackages)).
+		FlagWithOutput("--out ", moduleInfo).
+		Flags(packagesFlags)
+	rule.Build("system_modules_module_info", "generate module-info.java")
+
+	return moduleInfo
 }
 
 // ComponentDepsMutator is called before prebuilt modules without a corresponding source module are
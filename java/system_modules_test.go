@@ -111,3 +111,69 @@ func TestJavaSystemModulesMixSourceAndPrebuilt(t *testing.T) {
 	expectedPrebuiltPaths := getModuleHeaderJarsAsRelativeToTopPaths(result, "prebuilt_system-module1", "prebuilt_system-module2")
 	android.AssertArrayString(t, "prebuilt system modules inputs", expectedPrebuiltPaths, prebuiltInputs.RelativeToTop().Strings())
 }
+
+func TestJavaSystemModulesGenerateModuleInfo(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureAddTextFile("Android.bp", `
+			java_system_modules {
+				name: "system-modules",
+				libs: ["system-module1", "system-module2"],
+				generate_module_info: true,
+				split_packages: ["java.lang", "java.util"],
+			}
+			java_library {
+				name: "system-module1",
+				srcs: ["a.java"],
+				sdk_version: "none",
+				system_modules: "none",
+			}
+			java_library {
+				name: "system-module2",
+				srcs: ["b.java"],
+				sdk_version: "none",
+				system_modules: "none",
+			}
+		`),
+	).RunTest(t)
+
+	systemModules := result.ModuleForTests("system-modules", "android_common")
+	moduleInfo := systemModules.Rule("system_modules_module_info")
+	headerJars := getModuleHeaderJarsAsRelativeToTopPaths(result, "system-module1", "system-module2")
+	android.AssertStringListContainsAllOf(t, "module-info inputs", moduleInfo.Implicits.Strings(), headerJars)
+
+	packagesDir := "out/soong/.intermediates/system-modules/android_common/module-info/packages/"
+	command := moduleInfo.RuleParams.Command
+	android.AssertStringDoesContain(t, "system-module1 packages", command,
+		"system_modules_packages list --out "+packagesDir+"system-module1.txt "+headerJars[0])
+	android.AssertStringDoesContain(t, "system-module2 packages", command,
+		"system_modules_packages list --out "+packagesDir+"system-module2.txt "+headerJars[1])
+	android.AssertStringDoesContain(t, "module-info", command,
+		"system_modules_packages module-info --module java.base --split-package java.lang --split-package java.util "+
+			"--out out/soong/.intermediates/system-modules/android_common/module-info/module-info.java "+
+			"system-module1="+packagesDir+"system-module1.txt system-module2="+packagesDir+"system-module2.txt")
+
+	// The system modules are created from the generated module-info.java.
+	if rule := systemModules.MaybeRule("jarsTosystemModules"); rule.Rule != nil {
+		t.Errorf("unexpected jarsTosystemModules rule with a generated module-info.java")
+	}
+	rule := systemModules.Rule("moduleInfoTosystemModules")
+	android.AssertArrayString(t, "system modules inputs", headerJars, rule.Inputs.Strings())
+	android.AssertPathRelativeToTopEquals(t, "system modules module-info",
+		"out/soong/.intermediates/system-modules/android_common/module-info/module-info.java", rule.Implicit)
+	android.AssertStringEquals(t, "system modules module-info arg",
+		"out/soong/.intermediates/system-modules/android_common/module-info/module-info.java", rule.Args["moduleInfo"])
+}
+
+func TestJavaSystemModulesSplitPackagesWithoutGenerateModuleInfo(t *testing.T) {
+	android.GroupFixturePreparers(prepareForJavaTest, addSourceSystemModules).
+		ExtendWithErrorHandler(android.FixtureExpectsOneErrorPattern(
+			`split_packages: can only be set if generate_module_info is true`)).
+		RunTestWithBp(t, `
+			java_system_modules {
+				name: "other-system-modules",
+				libs: ["system-module1"],
+				split_packages: ["java.lang"],
+			}
+		`)
+}
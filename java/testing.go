@@ -336,6 +336,7 @@ func FixtureUseLegacyCorePlatformApi(moduleNames ...string) android.FixturePrepa
 func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	RegisterAARBuildComponents(ctx)
 	RegisterAppBuildComponents(ctx)
+	RegisterAppFeatureBuildComponents(ctx)
 	RegisterAppImportBuildComponents(ctx)
 	RegisterAppSetBuildComponents(ctx)
 	registerBootclasspathBuildComponents(ctx)
@@ -21,16 +21,28 @@ import (
 	"android/soong/cc"
 
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 )
 
 const afdoFlagFormat = "-Zprofile-sample-use=%s"
 
+type afdoRdep struct {
+	VariationName *string
+	ProfilePath   *string
+}
+
+type afdoMutatedProperties struct {
+	// The afdo-enabled modules that statically link this rlib, and their profiles.
+	AfdoRDeps []afdoRdep `blueprint:"mutated"`
+}
+
 type afdo struct {
-	Properties cc.AfdoProperties
+	Properties        cc.AfdoProperties
+	MutatedProperties afdoMutatedProperties
 }
 
 func (afdo *afdo) props() []interface{} {
-	return []interface{}{&afdo.Properties}
+	return []interface{}{&afdo.Properties, &afdo.MutatedProperties}
 }
 
 func (afdo *afdo) addDep(ctx BaseModuleContext, actx android.BottomUpMutatorContext) {
@@ -39,7 +51,7 @@ func (afdo *afdo) addDep(ctx BaseModuleContext, actx android.BottomUpMutatorCont
 		return
 	}
 
-	if mod, ok := ctx.Module().(*Module); ok && mod.Enabled() {
+	if mod, ok := ctx.Module().(*Module); ok && mod.Enabled() && afdoBuildsMachineCode(mod) {
 		fdoProfileName, err := actx.DeviceConfig().AfdoProfile(actx.ModuleName())
 		if err != nil {
 			ctx.ModuleErrorf("%s", err.Error())
@@ -66,16 +78,140 @@ func (afdo *afdo) flags(ctx android.ModuleContext, flags Flags, deps PathDeps) (
 		return flags, deps
 	}
 
+	// Profile use and profile generation instrumentation cannot coexist.
+	if mod, ok := ctx.Module().(*Module); ok && mod.profileGen() {
+		return flags, deps
+	}
+
+	if path := afdo.Properties.FdoProfilePath; path != nil {
+		profile := android.PathForSource(ctx, *path)
+		profileUseFlag := fmt.Sprintf(afdoFlagFormat, profile.String())
+		flags.RustFlags = append(flags.RustFlags, profileUseFlag)
+
+		deps.AfdoProfiles = append(deps.AfdoProfiles, profile)
+	}
+
+	return flags, deps
+}
+
+// afdoBuildsMachineCode returns true if the variant of the module is compiled by rustc, which
+// excludes the source variants of source providers and prebuilts.
+func afdoBuildsMachineCode(mod *Module) bool {
+	if mod.compiler == nil || mod.IsPrebuilt() {
+		return false
+	}
+	if library, ok := mod.compiler.(libraryInterface); ok && library.source() {
+		return false
+	}
+	return true
+}
+
+// afdoProfile returns the afdo profile of the module, from its fdo_profile in AFDO_PROFILES or
+// else from the profile directories set by the product.
+func afdoProfile(ctx android.BaseModuleContext) android.OptionalPath {
+	var profile android.OptionalPath
 	ctx.VisitDirectDepsWithTag(cc.FdoProfileTag, func(m android.Module) {
 		if ctx.OtherModuleHasProvider(m, cc.FdoProfileProvider) {
 			info := ctx.OtherModuleProvider(m, cc.FdoProfileProvider).(cc.FdoProfileInfo)
-			path := info.Path
-			profileUseFlag := fmt.Sprintf(afdoFlagFormat, path.String())
-			flags.RustFlags = append(flags.RustFlags, profileUseFlag)
+			profile = android.OptionalPathForPath(info.Path)
+		}
+	})
+
+	if !profile.Valid() {
+		profile = cc.AfdoProfileFromProfileDirs(ctx)
+	}
+	return profile
+}
+
+// afdoDepsMutator sets the profile of the afdo-enabled binaries and libraries, which is looked up by
+// the name of the module, and propagates it down to the rlibs that the binaries, dylibs and shared
+// libraries statically link, where most of their hot code lives.
+func afdoDepsMutator(mctx android.TopDownMutatorContext) {
+	mod, ok := mctx.Module().(*Module)
+	if !ok || mod.afdo == nil || !mod.afdo.Properties.Afdo || !mod.Enabled() || mctx.Host() {
+		return
+	}
+
+	if !afdoBuildsMachineCode(mod) {
+		return
+	}
 
-			deps.AfdoProfiles = append(deps.AfdoProfiles, path)
+	profile := afdoProfile(mctx)
+	if !profile.Valid() {
+		return
+	}
+	path := proptools.StringPtr(profile.Path().String())
+	mod.afdo.Properties.FdoProfilePath = path
+
+	if mod.Rlib() {
+		return
+	}
+
+	mctx.WalkDeps(func(dep android.Module, parent android.Module) bool {
+		// Only rlibs are linked statically, do not recurse down dylibs or proc macros.
+		if mctx.OtherModuleDependencyTag(dep) != rlibDepTag {
+			return false
+		}
+
+		depMod, ok := dep.(*Module)
+		if !ok || depMod.afdo == nil || depMod.IsPrebuilt() {
+			// Prebuilt rlibs cannot be rebuilt with the profile.
+			return false
 		}
+		depMod.afdo.MutatedProperties.AfdoRDeps = append(
+			depMod.afdo.MutatedProperties.AfdoRDeps,
+			afdoRdep{
+				VariationName: proptools.StringPtr(afdoVariationName(mod.Name())),
+				ProfilePath:   path,
+			},
+		)
+
+		return true
 	})
+}
 
-	return flags, deps
+// afdoMutator creates a variant of an rlib for each afdo-enabled module that statically links it,
+// built with the profile of that module.
+func afdoMutator(mctx android.BottomUpMutatorContext) {
+	mod, ok := mctx.Module().(*Module)
+	if !ok || mod.afdo == nil {
+		return
+	}
+
+	if !mod.Rlib() && mod.afdo.Properties.FdoProfilePath != nil {
+		mctx.SetDependencyVariation(afdoVariationName(mod.Name()))
+		return
+	}
+
+	variationNames := []string{""}
+	variationNameToProfilePath := make(map[string]*string)
+	for _, afdoRDep := range mod.afdo.MutatedProperties.AfdoRDeps {
+		variationName := *afdoRDep.VariationName
+		// An afdo-enabled module reaches the same rlib through every path that leads to it, only
+		// one variant is needed for each of them.
+		if _, exists := variationNameToProfilePath[variationName]; !exists {
+			variationNames = append(variationNames, variationName)
+			variationNameToProfilePath[variationName] = afdoRDep.ProfilePath
+		}
+	}
+
+	if len(variationNames) > 1 {
+		modules := mctx.CreateVariations(variationNames...)
+		for i, name := range variationNames {
+			if name == "" {
+				continue
+			}
+			variation := modules[i].(*Module)
+			variation.Properties.PreventInstall = true
+			variation.Properties.HideFromMake = true
+			variation.afdo.Properties.Afdo = true
+			variation.afdo.Properties.FdoProfilePath = variationNameToProfilePath[name]
+		}
+	}
+}
+
+// afdoVariationName returns the name of the variation of the rlibs built with the profile of the
+// given afdo-enabled module.
+func afdoVariationName(owner string) string {
+	return "afdo-" + owner
 }
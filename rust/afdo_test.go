@@ -96,17 +96,237 @@ func TestAfdoEnabledWithMultiArchs(t *testing.T) {
 		rustMockedFiles.AddToFixture(),
 	).RunTestWithBp(t, bp)
 
-	fooArm := result.ModuleForTests("foo", "android_arm_armv7-a-neon").Rule("rustc")
-	fooArm64 := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	// Both the arm and arm64 variants must exist for the per-variant checks below to be meaningful.
+	result.ModuleForTests("foo", "android_arm_armv7-a-neon")
+	result.ModuleForTests("foo", "android_arm64_armv8-a")
 
-	expectedCFlagArm := fmt.Sprintf(afdoFlagFormat, "afdo_profiles_package/foo_arm.afdo")
-	expectedCFlagArm64 := fmt.Sprintf(afdoFlagFormat, "afdo_profiles_package/foo_arm64.afdo")
+	result.ForEachDeviceVariant(t, "foo", func(t *testing.T, variant string, m android.TestingModule) {
+		rustcFlags := m.Rule("rustc").Args["rustcFlags"]
+		expectedCFlag := fmt.Sprintf(afdoFlagFormat, "afdo_profiles_package/foo_"+m.Target().Arch.ArchType.String()+".afdo")
+		if !strings.Contains(rustcFlags, expectedCFlag) {
+			t.Errorf("Expected 'foo' to enable afdo, but did not find %q in cflags %q", expectedCFlag, rustcFlags)
+		}
+	})
+}
 
-	if !strings.Contains(fooArm.Args["rustcFlags"], expectedCFlagArm) {
-		t.Errorf("Expected 'fooArm' to enable afdo, but did not find %q in cflags %q", expectedCFlagArm, fooArm.Args["rustcFlags"])
+func TestAfdoProfileDirs(t *testing.T) {
+	bp := `
+	rust_binary {
+		name: "foo",
+		srcs: ["foo.rs"],
+		afdo: true,
 	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("vendor/afdo_profiles/foo.afdo", ""),
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/foo.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfileDirs = []string{"vendor/afdo_profiles"}
+		}),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+
+	expectedCFlag := fmt.Sprintf(afdoFlagFormat, "vendor/afdo_profiles/foo.afdo")
+	android.AssertStringDoesContain(t, "rustcFlags", foo.Args["rustcFlags"], expectedCFlag)
+	android.AssertStringDoesNotContain(t, "rustcFlags", foo.Args["rustcFlags"], "toolchain/pgo-profiles")
+}
+
+func TestAfdoProfileDirsWithMultiArchs(t *testing.T) {
+	bp := `
+	rust_binary {
+		name: "foo",
+		srcs: ["foo.rs"],
+		afdo: true,
+		compile_multilib: "both",
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("vendor/afdo_profiles/foo.afdo", ""),
+		android.FixtureAddTextFile("vendor/afdo_profiles/foo_arm.afdo", ""),
+		android.FixtureAddTextFile("vendor/afdo_profiles/foo_arm64.afdo", ""),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfileDirs = []string{"vendor/afdo_profiles"}
+		}),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	// Both the arm and arm64 variants must exist for the per-variant checks below to be meaningful.
+	result.ModuleForTests("foo", "android_arm_armv7-a-neon")
+	result.ModuleForTests("foo", "android_arm64_armv8-a")
+
+	result.ForEachDeviceVariant(t, "foo", func(t *testing.T, variant string, m android.TestingModule) {
+		rustcFlags := m.Rule("rustc").Args["rustcFlags"]
+		expectedCFlag := fmt.Sprintf(afdoFlagFormat, "vendor/afdo_profiles/foo_"+m.Target().Arch.ArchType.String()+".afdo")
+		android.AssertStringDoesContain(t, "rustcFlags", rustcFlags, expectedCFlag)
+	})
+}
+
+func TestAfdoProfileDirsMissingProfile(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("foo/Android.bp", `
+			rust_binary {
+				name: "foo",
+				srcs: ["foo.rs"],
+				afdo: true,
+			}
+		`),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.AfdoProfileDirs = []string{"vendor/afdo_profiles"}
+		}),
+		rustMockedFiles.AddToFixture(),
+	).RunTest(t)
 
-	if !strings.Contains(fooArm64.Args["rustcFlags"], expectedCFlagArm64) {
-		t.Errorf("Expected 'fooArm64' to enable afdo, but did not find %q in cflags %q", expectedCFlagArm64, fooArm64.Args["rustcFlags"])
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "rustcFlags", foo.Args["rustcFlags"], "-Zprofile-sample-use")
+	android.AssertArrayString(t, "modules missing profiles", []string{"foo.afdo:foo/Android.bp:foo"},
+		cc.ModulesMissingProfileFileForTests(result.Config))
+}
+
+func TestAfdoProfileDirsUnset(t *testing.T) {
+	bp := `
+	rust_binary {
+		name: "foo",
+		srcs: ["foo.rs"],
+		afdo: true,
 	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/foo.afdo", ""),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	// Without PRODUCT_AFDO_PROFILE_DIRS no directory is searched, and no module is missing a profile.
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "rustcFlags", foo.Args["rustcFlags"], "-Zprofile-sample-use")
+	android.AssertArrayString(t, "modules missing profiles", nil,
+		cc.ModulesMissingProfileFileForTests(result.Config))
+}
+
+func TestAfdoDeps(t *testing.T) {
+	bp := `
+	rust_binary {
+		name: "foo",
+		srcs: ["foo.rs"],
+		rlibs: ["libbar"],
+		afdo: true,
+	}
+
+	rust_binary {
+		name: "qux",
+		srcs: ["qux.rs"],
+		rlibs: ["libbar"],
+		afdo: true,
+	}
+
+	rust_library {
+		name: "libbar",
+		srcs: ["bar.rs"],
+		crate_name: "bar",
+		rlibs: ["libbaz"],
+	}
+
+	rust_library {
+		name: "libbaz",
+		srcs: ["baz.rs"],
+		crate_name: "baz",
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/foo.afdo", ""),
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/qux.afdo", ""),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	rlibVariant := "android_arm64_armv8-a_rlib_dylib-std"
+	fooProfileFlag := fmt.Sprintf(afdoFlagFormat, "toolchain/pgo-profiles/sampling/foo.afdo")
+	quxProfileFlag := fmt.Sprintf(afdoFlagFormat, "toolchain/pgo-profiles/sampling/qux.afdo")
+
+	for _, lib := range []string{"libbar", "libbaz"} {
+		plain := result.ModuleForTests(lib, rlibVariant).Rule("rustc").Args["rustcFlags"]
+		android.AssertStringDoesNotContain(t, lib+" rustcFlags", plain, "-Zprofile-sample-use")
+
+		fooVariant := result.ModuleForTests(lib, rlibVariant+"_afdo-foo").Rule("rustc").Args["rustcFlags"]
+		android.AssertStringDoesContain(t, lib+" afdo-foo rustcFlags", fooVariant, fooProfileFlag)
+
+		quxVariant := result.ModuleForTests(lib, rlibVariant+"_afdo-qux").Rule("rustc").Args["rustcFlags"]
+		android.AssertStringDoesContain(t, lib+" afdo-qux rustcFlags", quxVariant, quxProfileFlag)
+	}
+
+	// The binaries link the variants of the rlibs built with their profiles.
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	if !android.SuffixInList(foo.Implicits.Strings(), "/libbar/"+rlibVariant+"_afdo-foo/libbar.rlib") {
+		t.Errorf("Expected 'foo' to link the afdo-foo variant of libbar, implicits %q", foo.Implicits.Strings())
+	}
+}
+
+func TestAfdoFFIShared(t *testing.T) {
+	bp := `
+	rust_ffi_shared {
+		name: "libfoo",
+		srcs: ["foo.rs"],
+		crate_name: "foo",
+		afdo: true,
+	}
+
+	rust_ffi_shared {
+		name: "libbar",
+		srcs: ["bar.rs"],
+		crate_name: "bar",
+		afdo: true,
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libfoo.afdo", ""),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	libfoo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Rule("rustc")
+	expectedCFlag := fmt.Sprintf(afdoFlagFormat, "toolchain/pgo-profiles/sampling/libfoo.afdo")
+	android.AssertStringDoesContain(t, "libfoo rustcFlags", libfoo.Args["rustcFlags"], expectedCFlag)
+
+	// libbar has no profile, it is built without one like a binary would be.
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_shared").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "libbar rustcFlags", libbar.Args["rustcFlags"], "-Zprofile-sample-use")
+}
+
+func TestAfdoLibrary(t *testing.T) {
+	bp := `
+	rust_library {
+		name: "libfoo",
+		srcs: ["foo.rs"],
+		crate_name: "foo",
+		host_supported: true,
+		afdo: true,
+	}
+`
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		cc.PrepareForTestWithFdoProfile,
+		android.FixtureAddTextFile("toolchain/pgo-profiles/sampling/libfoo.afdo", ""),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, bp)
+
+	expectedCFlag := fmt.Sprintf(afdoFlagFormat, "toolchain/pgo-profiles/sampling/libfoo.afdo")
+	for _, variant := range []string{"android_arm64_armv8-a_rlib_dylib-std", "android_arm64_armv8-a_dylib"} {
+		rustcFlags := result.ModuleForTests("libfoo", variant).Rule("rustc").Args["rustcFlags"]
+		android.AssertStringDoesContain(t, variant+" rustcFlags", rustcFlags, expectedCFlag)
+	}
+
+	// Host variants are never built with afdo.
+	host := result.ModuleForTests("libfoo", "linux_glibc_x86_64_rlib_rlib-std").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "host rustcFlags", host.Args["rustcFlags"], "-Zprofile-sample-use")
 }
@@ -101,6 +101,9 @@ func (test *testDecorator) AndroidMk(ctx AndroidMkContext, ret *android.AndroidM
 	ctx.SubAndroidMk(ret, test.binaryDecorator)
 
 	ret.Class = "NATIVE_TESTS"
+	if test.testPerSrc() {
+		ret.SubName = "_" + *test.Properties.TestPerSrcName
+	}
 	ret.ExtraEntries = append(ret.ExtraEntries,
 		func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
 			entries.AddCompatibilityTestSuites(test.Properties.Test_suites...)
@@ -29,6 +29,18 @@ type BinaryCompilerProperties struct {
 	// Static executables currently only support for bionic targets. Non-bionic targets will not produce a fully static
 	// binary, but will still implicitly imply prefer_rlib true.
 	Static_executable *bool `android:"arch_variant"`
+
+	// List of crate names that may be linked into this binary at more than one version.  Other
+	// crates that appear at multiple versions in the transitive dependencies are reported, see
+	// RUST_CRATE_VERSION_CONFLICTS.
+	Allowed_crate_version_conflicts []string
+
+	// List of the features, as "<module>:<feature>", that the rust libraries in the transitive
+	// dependencies of this binary must be compiled with.  Unlike cargo, which unifies the features
+	// requested by each root crate, a rust library is compiled once with its own features for all
+	// the binaries that link it, so the build fails if one of them is compiled without a feature
+	// listed here.
+	Dependency_features []string
 }
 
 type binaryInterface interface {
@@ -137,9 +149,9 @@ func (binary *binaryDecorator) compile(ctx ModuleContext, flags Flags, deps Path
 	outputFile := android.PathForModuleOut(ctx, fileName)
 	ret := buildOutput{outputFile: outputFile}
 
-	flags.RustFlags = append(flags.RustFlags, deps.depFlags...)
+	flags.RustFlags = append(flags.RustFlags, deps.depFlags.ToList()...)
 	flags.LinkFlags = append(flags.LinkFlags, deps.depLinkFlags...)
-	flags.LinkFlags = append(flags.LinkFlags, deps.linkObjects.Strings()...)
+	flags.LinkFlags = append(flags.LinkFlags, deps.linkObjects.ToList().Strings()...)
 
 	if binary.stripper.NeedsStrip(ctx) {
 		strippedOutputFile := outputFile
@@ -150,6 +162,9 @@ func (binary *binaryDecorator) compile(ctx ModuleContext, flags Flags, deps Path
 	}
 	binary.baseCompiler.unstrippedOutputFile = outputFile
 
+	checkCrateVersionConflicts(ctx, binary.Properties.Allowed_crate_version_conflicts)
+	checkDependencyFeatures(ctx, binary.Properties.Dependency_features)
+
 	ret.kytheFile = TransformSrcToBinary(ctx, srcPath, deps, flags, outputFile).kytheFile
 	return ret
 }
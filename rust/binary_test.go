@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"android/soong/android"
+	"android/soong/snapshot"
 )
 
 // Test that rustlibs default linkage is correct for binaries.
@@ -212,3 +213,43 @@ func TestStrippedBinary(t *testing.T) {
 		t.Errorf("unstripped binary exists, so stripped binary has incorrectly been generated")
 	}
 }
+
+// Test that host binaries are given prebuilt module definitions in host snapshots.
+func TestBinaryHostSnapshotPrebuiltBp(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithRustDefaultModules,
+		snapshot.PrepareForTestWithHostSnapshot,
+	).RunTestWithBp(t, `
+		rust_library_host_dylib {
+			name: "libdylib",
+			srcs: ["foo.rs"],
+			crate_name: "dylib",
+		}
+
+		rust_binary_host {
+			name: "fizz-buzz",
+			dylibs: ["libdylib"],
+			srcs: ["foo.rs"],
+		}
+
+		host_snapshot {
+			name: "test-host-snapshot",
+			deps: ["fizz-buzz"],
+		}
+	`)
+
+	snapshotModule := result.ModuleForTests("test-host-snapshot", result.Config.BuildOS.String()+"_common")
+	installDir := android.AndroidMkEntriesForTest(t, result.TestContext, snapshotModule.Module())[0].EntryMap["LOCAL_MODULE_PATH"][0]
+
+	// The binary is given a prebuilt_build_tool definition that depends on the dylibs installed
+	// with it.
+	bp := android.ContentFromFileRuleForTests(t, snapshotModule.Output("Android.bp"))
+	android.AssertStringDoesContain(t, "Android.bp", bp, `prebuilt_build_tool {
+    name: "fizz-buzz",
+    src: "`+installDir+`/bin/fizz-buzz",
+    deps: [
+`)
+	android.AssertStringDoesContain(t, "Android.bp", bp, `        "`+installDir+`/lib64/libdylib.dylib.so",
+`)
+}
@@ -27,21 +27,12 @@ import (
 var (
 	_     = pctx.SourcePathVariable("rustcCmd", "${config.RustBin}/rustc")
 	_     = pctx.SourcePathVariable("mkcraterspCmd", "build/soong/scripts/mkcratersp.py")
-	rustc = pctx.AndroidStaticRule("rustc",
-		blueprint.RuleParams{
-			Command: "$envVars $rustcCmd " +
-				"-C linker=$mkcraterspCmd " +
-				"--emit link -o $out --emit dep-info=$out.d.raw $in ${libFlags} $rustcFlags" +
-				" && grep \"^$out:\" $out.d.raw > $out.d",
-			CommandDeps: []string{"$rustcCmd", "$mkcraterspCmd"},
-			// Rustc deps-info writes out make compatible dep files: https://github.com/rust-lang/rust/issues/7633
-			// Rustc emits unneeded dependency lines for the .d and input .rs files.
-			// Those extra lines cause ninja warning:
-			//     "warning: depfile has multiple output paths"
-			// For ninja, we keep/grep only the dependency rule for the rust $out file.
-			Deps:    blueprint.DepsGCC,
-			Depfile: "$out.d",
-		},
+	rustc = pctx.AndroidStaticRule("rustc", rustcRuleParams("$rustcCmd"),
+		"rustcFlags", "libFlags", "envVars")
+
+	// rustcSecondary compiles the crates that are built with the secondary rust toolchain.
+	_              = pctx.SourcePathVariable("rustcSecondaryCmd", "${config.RustSecondaryBin}/rustc")
+	rustcSecondary = pctx.AndroidStaticRule("rustcSecondary", rustcRuleParams("$rustcSecondaryCmd"),
 		"rustcFlags", "libFlags", "envVars")
 	rustLink = pctx.AndroidStaticRule("rustLink",
 		blueprint.RuleParams{
@@ -115,6 +106,24 @@ var (
 		"rustcFlags", "libFlags", "envVars")
 )
 
+// rustcRuleParams returns the parameters of a rule that compiles a crate with the given rustc.
+func rustcRuleParams(rustcCmd string) blueprint.RuleParams {
+	return blueprint.RuleParams{
+		Command: "$envVars " + rustcCmd + " " +
+			"-C linker=$mkcraterspCmd " +
+			"--emit link -o $out --emit dep-info=$out.d.raw $in ${libFlags} $rustcFlags" +
+			" && grep \"^$out:\" $out.d.raw > $out.d",
+		CommandDeps: []string{rustcCmd, "$mkcraterspCmd"},
+		// Rustc deps-info writes out make compatible dep files: https://github.com/rust-lang/rust/issues/7633
+		// Rustc emits unneeded dependency lines for the .d and input .rs files.
+		// Those extra lines cause ninja warning:
+		//     "warning: depfile has multiple output paths"
+		// For ninja, we keep/grep only the dependency rule for the rust $out file.
+		Deps:    blueprint.DepsGCC,
+		Depfile: "$out.d",
+	}
+}
+
 type buildOutput struct {
 	outputFile android.Path
 	kytheFile  android.Path
@@ -182,7 +191,7 @@ func makeLibFlags(deps PathDeps) []string {
 		libFlags = append(libFlags, "--extern "+proc_macro.CrateName+"="+proc_macro.Path.String())
 	}
 
-	for _, path := range deps.linkDirs {
+	for _, path := range deps.linkDirs.ToList() {
 		libFlags = append(libFlags, "-L "+path)
 	}
 
@@ -291,7 +300,7 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 	linkImplicits = append(linkImplicits, deps.CrtBegin...)
 	linkImplicits = append(linkImplicits, deps.CrtEnd...)
 
-	linkOrderOnly = append(linkOrderOnly, deps.linkObjects...)
+	linkOrderOnly = append(linkOrderOnly, deps.linkObjects.ToList()...)
 
 	if len(deps.SrcDeps) > 0 {
 		moduleGenDir := ctx.RustModule().compiler.CargoOutDir()
@@ -317,7 +326,7 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		implicits = append(implicits, outputs.Paths()...)
 	}
 
-	envVars = append(envVars, "ANDROID_RUST_VERSION="+config.GetRustVersion(ctx))
+	envVars = append(envVars, "ANDROID_RUST_VERSION="+ctx.RustModule().rustToolchainVersion(ctx))
 
 	if ctx.RustModule().compiler.CargoEnvCompat() {
 		if _, ok := ctx.RustModule().compiler.(*binaryDecorator); ok {
@@ -357,8 +366,12 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		rustcOutputFile = android.PathForModuleOut(ctx, outputFile.Base()+".rsp")
 	}
 
+	rule := rustc
+	if ctx.RustModule().usesSecondaryRustToolchain() {
+		rule = rustcSecondary
+	}
 	ctx.Build(pctx, android.BuildParams{
-		Rule:        rustc,
+		Rule:        rule,
 		Description: "rustc " + main.Rel(),
 		Output:      rustcOutputFile,
 		Inputs:      inputs,
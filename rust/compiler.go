@@ -23,6 +23,7 @@ import (
 	"github.com/google/blueprint/proptools"
 
 	"android/soong/android"
+	cc_config "android/soong/cc/config"
 	"android/soong/rust/config"
 )
 
@@ -173,6 +174,23 @@ type BaseCompilerProperties struct {
 
 	// If cargo_env_compat is true, sets the CARGO_PKG_VERSION env var to this value.
 	Cargo_pkg_version *string
+
+	// Whether to keep the frame pointers when the product keeps them in native device code, like
+	// cc modules do.  Defaults to true.
+	Force_frame_pointers *bool `android:"arch_variant"`
+
+	// Prebuilt rlib files that are not described by any module, passed to rustc as
+	// --extern <crate_name>=<path>.  This is only meant for bringing up new targets and is
+	// restricted to a few directories by neverallow rules.
+	Extern_rlibs []ExternRlibProperties `android:"arch_variant"`
+}
+
+type ExternRlibProperties struct {
+	// The name of the crate as used by the sources of the module.
+	Crate_name *string
+
+	// The rlib file, or a ":module{.tag}" reference to a module that produces it.
+	Path *string `android:"path"`
 }
 
 type baseCompiler struct {
@@ -250,6 +268,10 @@ func (compiler *baseCompiler) cfgsToFlags() []string {
 	return flags
 }
 
+func (compiler *baseCompiler) features() []string {
+	return compiler.Properties.Features
+}
+
 func (compiler *baseCompiler) featuresToFlags() []string {
 	flags := []string{}
 	for _, feature := range compiler.Properties.Features {
@@ -314,6 +336,10 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags) Flag
 	flags.GlobalRustFlags = append(flags.GlobalRustFlags, config.GlobalRustFlags...)
 	flags.GlobalRustFlags = append(flags.GlobalRustFlags, ctx.toolchain().ToolchainRustFlags())
 	flags.GlobalLinkFlags = append(flags.GlobalLinkFlags, ctx.toolchain().ToolchainLinkFlags())
+	if ctx.Device() && proptools.BoolDefault(compiler.Properties.Force_frame_pointers, true) &&
+		cc_config.FramePointersForArch(ctx.Config(), ctx.Arch().ArchType) {
+		flags.GlobalRustFlags = append(flags.GlobalRustFlags, "-C force-frame-pointers=yes")
+	}
 	flags.EmitXrefs = ctx.Config().EmitXrefRules()
 
 	if ctx.Host() && !ctx.Windows() {
@@ -349,6 +375,23 @@ func (compiler *baseCompiler) CargoPkgVersion() string {
 	return String(compiler.Properties.Cargo_pkg_version)
 }
 
+// externRlibs returns the rlibs of the extern_rlibs property.
+func (compiler *baseCompiler) externRlibs(ctx android.ModuleContext) RustLibraries {
+	var rlibs RustLibraries
+	for _, rlib := range compiler.Properties.Extern_rlibs {
+		crateName := String(rlib.Crate_name)
+		if crateName == "" || String(rlib.Path) == "" {
+			ctx.PropertyErrorf("extern_rlibs", "crate_name and path are required")
+			continue
+		}
+		rlibs = append(rlibs, RustLibrary{
+			Path:      android.PathForModuleSrc(ctx, String(rlib.Path)),
+			CrateName: crateName,
+		})
+	}
+	return rlibs
+}
+
 func (compiler *baseCompiler) unstrippedOutputFilePath() android.Path {
 	return compiler.unstrippedOutputFile
 }
@@ -374,6 +417,9 @@ func (compiler *baseCompiler) compilerDeps(ctx DepsContext, deps Deps) Deps {
 			if ctx.Host() && !ctx.Target().HostCross && ctx.Target().Os != android.LinuxBionic {
 				stdlib = "prebuilt_" + stdlib
 			}
+			if ctx.RustModule().usesSecondaryRustToolchain() {
+				stdlib += config.SecondaryStdlibSuffix
+			}
 			deps.Stdlibs = append(deps.Stdlibs, stdlib)
 		}
 	}
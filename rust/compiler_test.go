@@ -15,9 +15,13 @@
 package rust
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 )
 
@@ -157,6 +161,69 @@ func TestCargoCompat(t *testing.T) {
 	}
 }
 
+func TestExternRlibs(t *testing.T) {
+	ctx := testRust(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			extern_rlibs: [
+				{
+					crate_name: "foo",
+					path: "prebuilt/libfoo.rlib",
+				},
+				{
+					crate_name: "bar",
+					path: ":libbar_gen",
+				},
+			],
+		}
+		genrule {
+			name: "libbar_gen",
+			cmd: "touch $(out)",
+			out: ["libbar.rlib"],
+		}`)
+
+	fizz := ctx.ModuleForTests("fizz", "android_arm64_armv8-a").Rule("rustc")
+
+	if !strings.Contains(fizz.Args["libFlags"], "--extern foo=prebuilt/libfoo.rlib") {
+		t.Errorf("expected '--extern foo=prebuilt/libfoo.rlib' in libFlags, actual libFlags: %#v", fizz.Args["libFlags"])
+	}
+	if !android.InList("prebuilt/libfoo.rlib", fizz.Implicits.Strings()) {
+		t.Errorf("expected prebuilt/libfoo.rlib in implicits, actual implicits: %#v", fizz.Implicits.Strings())
+	}
+
+	// A module reference resolves to the output file of the module.
+	if !regexp.MustCompile(`--extern bar=\S*/libbar_gen/\S*/libbar\.rlib`).MatchString(fizz.Args["libFlags"]) {
+		t.Errorf("expected '--extern bar=<libbar_gen output>' in libFlags, actual libFlags: %#v", fizz.Args["libFlags"])
+	}
+	if !android.SuffixInList(fizz.Implicits.Strings(), "/libbar.rlib") {
+		t.Errorf("expected the output of libbar_gen in implicits, actual implicits: %#v", fizz.Implicits.Strings())
+	}
+}
+
+func TestExternRlibsNeverallow(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.PrepareForTestWithNeverallowRules(nil),
+		android.FixtureAddTextFile("external/fizz/Android.bp", `
+			rust_binary {
+				name: "fizz",
+				srcs: ["foo.rs"],
+				extern_rlibs: [
+					{
+						crate_name: "foo",
+						path: "libfoo.rlib",
+					},
+				],
+			}`),
+	).
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`(?s)module "fizz".*violates neverallow requirements.*extern_rlibs can only be used to bring up new targets in allowed directories`)).
+		RunTest(t)
+}
+
 func TestInstallDir(t *testing.T) {
 	ctx := testRust(t, `
 		rust_library_dylib {
@@ -337,3 +404,43 @@ func TestManualLinkageRejection(t *testing.T) {
 		}
 	`)
 }
+
+func TestFramePointers(t *testing.T) {
+	bp := `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			host_supported: true,
+		}
+		rust_library {
+			name: "libbar",
+			srcs: ["foo.rs"],
+			crate_name: "bar",
+			force_frame_pointers: false,
+		}`
+
+	for _, framePointers := range []bool{false, true} {
+		t.Run(fmt.Sprintf("FramePointers=%t", framePointers), func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForRustTest,
+				rustMockedFiles.AddToFixture(),
+				android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+					variables.FramePointers = proptools.BoolPtr(framePointers)
+				}),
+			).RunTestWithBp(t, bp)
+
+			checkFlags := func(name, variant string, expected bool) {
+				t.Helper()
+				flags := result.ModuleForTests(name, variant).Rule("rustc").Args["rustcFlags"]
+				android.AssertBoolEquals(t, name+" "+variant+" forces frame pointers", expected,
+					strings.Contains(flags, "-C force-frame-pointers=yes"))
+			}
+			checkFlags("libfoo", "android_arm64_armv8-a_dylib", framePointers)
+			// 32-bit arm code always omits the frame pointers, like cc.
+			checkFlags("libfoo", "android_arm_armv7-a-neon_dylib", false)
+			checkFlags("libfoo", "linux_glibc_x86_64_dylib", false)
+			checkFlags("libbar", "android_arm64_armv8-a_dylib", false)
+		})
+	}
+}
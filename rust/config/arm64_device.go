@@ -18,6 +18,7 @@ import (
 	"strings"
 
 	"android/soong/android"
+	cc_config "android/soong/cc/config"
 )
 
 var (
@@ -91,6 +92,10 @@ func Arm64ToolchainFactory(arch android.Arch) Toolchain {
 	}
 
 	toolchainRustFlags = append(toolchainRustFlags, deviceGlobalRustFlags...)
+	if !cc_config.Arm64StackProtectorEnabled(archVariant) {
+		// Keep the stack protector in sync with cc, which disables it for some arch variants.
+		_, toolchainRustFlags = android.RemoveFromList(stackProtectorRustFlag, toolchainRustFlags)
+	}
 
 	for _, feature := range arch.ArchFeatures {
 		toolchainRustFlags = append(toolchainRustFlags, Arm64ArchFeatureRustFlags[feature]...)
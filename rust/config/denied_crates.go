@@ -0,0 +1,33 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// DeniedCrate is a crate that may not be linked into device code, directly or transitively.
+type DeniedCrate struct {
+	// CrateName is the crate_name of the denied crate.
+	CrateName string
+
+	// Version, if set, only denies the crate when its cargo_pkg_version matches, for example to ban
+	// an old vendored copy of a crate.
+	Version string
+
+	// ExemptPaths are the directories, and their subdirectories, of the device modules that are still
+	// allowed to depend on the crate.
+	ExemptPaths []string
+}
+
+// DeviceDeniedCrates lists the crates that device modules may not depend on.  Host modules are not
+// affected, so the crates remain available to host tools.
+var DeviceDeniedCrates = []DeniedCrate{}
@@ -31,6 +31,10 @@ var (
 		"libstd",
 	}
 
+	// The sysroot crates built with the secondary rust toolchain have the name of the crates
+	// built with the default toolchain followed by this suffix.
+	SecondaryStdlibSuffix = ".rust_secondary"
+
 	// Mapping between Soong internal arch types and std::env constants.
 	// Required as Rust uses aarch64 when Soong uses arm64.
 	StdEnvArch = map[android.ArchType]string{
@@ -57,11 +61,15 @@ var (
 		"-Zdylib-lto",
 	}
 
+	stackProtectorRustFlag = "-Z stack-protector=strong"
+
 	deviceGlobalRustFlags = []string{
 		"-C panic=abort",
 		"-Z link-native-libraries=no",
 		// Generate additional debug info for AutoFDO
 		"-Z debug-info-for-profiling",
+		// Match the -fstack-protector-strong of cc's deviceGlobalCflags
+		stackProtectorRustFlag,
 	}
 
 	deviceGlobalLinkFlags = []string{
@@ -102,6 +110,10 @@ func init() {
 	pctx.StaticVariable("RustPath", "${RustBase}/${HostPrebuiltTag}/${RustVersion}")
 	pctx.StaticVariable("RustBin", "${RustPath}/bin")
 
+	pctx.VariableConfigMethod("RustSecondaryVersion", android.Config.RustSecondaryVersion)
+	pctx.StaticVariable("RustSecondaryPath", "${RustBase}/${HostPrebuiltTag}/${RustSecondaryVersion}")
+	pctx.StaticVariable("RustSecondaryBin", "${RustSecondaryPath}/bin")
+
 	pctx.ImportAs("cc_config", "android/soong/cc/config")
 	pctx.StaticVariable("RustLinker", "${cc_config.ClangBin}/clang++")
 	pctx.StaticVariable("RustLinkerArgs", "-Wl,--as-needed")
@@ -17,6 +17,7 @@ package rust
 import (
 	"github.com/google/blueprint"
 
+	"android/soong/android"
 	"android/soong/cc"
 )
 
@@ -59,10 +60,34 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 	}
 
 	if cov.Properties.CoverageEnabled {
+		cov.linkCoverage = true
 		flags.Coverage = true
-		coverage := ctx.GetDirectDepWithTag(CovLibraryName, cc.CoverageDepTag).(cc.LinkableInterface)
 		flags.RustFlags = append(flags.RustFlags,
 			"-C instrument-coverage", "-g")
+
+		if cc.EnableContinuousCoverage(ctx) {
+			flags.RustFlags = append(flags.RustFlags, "-C llvm-args=--runtime-counter-relocation")
+		}
+	}
+
+	// Even if this module is not instrumented, the rlibs that it contains may be, e.g. when
+	// native_coverage is false for this module or its path is excluded from coverage, and then it
+	// needs to be linked with the profile runtime. The rlibs contain the rlibs that they depend on.
+	if !cov.linkCoverage {
+		ctx.VisitDirectDepsWithTag(rlibDepTag, func(m android.Module) {
+			if dep, ok := m.(*Module); ok && dep.coverage != nil && dep.coverage.linkCoverage {
+				cov.linkCoverage = true
+			}
+		})
+	}
+
+	if cov.linkCoverage {
+		coverage, ok := ctx.GetDirectDepWithTag(CovLibraryName, cc.CoverageDepTag).(cc.LinkableInterface)
+		if !ok {
+			ctx.ModuleErrorf("links rlibs built with coverage but does not depend on %q, "+
+				"native_coverage must not be false for modules that link covered rlibs", CovLibraryName)
+			return flags, deps
+		}
 		flags.LinkFlags = append(flags.LinkFlags,
 			profileInstrFlag, "-g", coverage.OutputFile().Path().String(), "-Wl,--wrap,open")
 		deps.LibDeps = append(deps.LibDeps, coverage.OutputFile().Path())
@@ -74,7 +99,6 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 		}
 
 		if cc.EnableContinuousCoverage(ctx) {
-			flags.RustFlags = append(flags.RustFlags, "-C llvm-args=--runtime-counter-relocation")
 			flags.LinkFlags = append(flags.LinkFlags, "-Wl,-mllvm,-runtime-counter-relocation")
 		}
 	}
@@ -18,6 +18,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 )
 
@@ -112,3 +115,99 @@ func TestCoverageDeps(t *testing.T) {
 		t.Fatalf("missing expected coverage 'libprofile-clang-extras' dependency in linkFlags: %#v", fizz.Args["linkFlags"])
 	}
 }
+
+// Test that the modules that are not instrumented link the profile runtime only when they contain
+// rlibs that are.
+func TestCoverageRuntimeOfUninstrumentedModules(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	bp := `
+		rust_library_rlib {
+			name: "libfoo_cov",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+		}
+		rust_library_rlib {
+			name: "libbar_nocov",
+			srcs: ["foo.rs"],
+			crate_name: "bar",
+			native_coverage: false,
+		}
+		rust_binary {
+			name: "fizz_cov",
+			srcs: ["foo.rs"],
+			rlibs: ["libbar_nocov"],
+		}
+		rust_binary {
+			name: "buzz_nocov",
+			srcs: ["foo.rs"],
+			rlibs: ["libfoo_cov"],
+			native_coverage: false,
+		}
+	`
+	ctx := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureModifyProductVariables(
+			func(variables android.FixtureProductVariables) {
+				variables.ClangCoverage = proptools.BoolPtr(true)
+				variables.Native_coverage = proptools.BoolPtr(true)
+				variables.NativeCoveragePaths = []string{"*"}
+				variables.NativeCoverageExcludePaths = []string{"excluded"}
+			},
+		),
+		android.MockFS{
+			"excluded/Android.bp": []byte(`
+				rust_binary {
+					name: "excluded_bin",
+					srcs: ["foo.rs"],
+					rlibs: ["libfoo_cov"],
+				}
+				rust_binary {
+					name: "excluded_bin_nocov_rlibs",
+					srcs: ["foo.rs"],
+					rlibs: ["libbar_nocov"],
+				}
+			`),
+			"excluded/foo.rs": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, bp).TestContext
+
+	const runtimeLib = "libprofile-clang-extras.a"
+
+	// A module in an excluded path is not instrumented, but links the runtime for its covered rlibs.
+	excludedBin := ctx.ModuleForTests("excluded_bin", "android_arm64_armv8-a_cov")
+	android.AssertStringDoesNotContain(t, "excluded_bin rustcFlags",
+		excludedBin.Rule("rustc").Args["rustcFlags"], "-C instrument-coverage")
+	android.AssertStringDoesContain(t, "excluded_bin linkFlags",
+		excludedBin.Rule("rustLink").Args["linkFlags"], profileInstrFlag)
+	android.AssertStringDoesContain(t, "excluded_bin linkFlags",
+		excludedBin.Rule("rustLink").Args["linkFlags"], runtimeLib)
+
+	// Without covered rlibs, it needs no runtime either.
+	excludedBinNoCovRlibs := ctx.ModuleForTests("excluded_bin_nocov_rlibs", "android_arm64_armv8-a_cov")
+	android.AssertStringDoesNotContain(t, "excluded_bin_nocov_rlibs linkFlags",
+		excludedBinNoCovRlibs.Rule("rustLink").Args["linkFlags"], runtimeLib)
+
+	// An instrumented binary links the runtime even if its rlibs are not instrumented.
+	fizzCov := ctx.ModuleForTests("fizz_cov", "android_arm64_armv8-a_cov")
+	android.AssertStringDoesContain(t, "fizz_cov rustcFlags",
+		fizzCov.Rule("rustc").Args["rustcFlags"], "-C instrument-coverage")
+	android.AssertStringDoesContain(t, "fizz_cov linkFlags",
+		fizzCov.Rule("rustLink").Args["linkFlags"], runtimeLib)
+
+	// A binary with native_coverage: false uses the rlibs that are not instrumented, and neither
+	// needs nor depends on the runtime.
+	buzzNoCov := ctx.ModuleForTests("buzz_nocov", "android_arm64_armv8-a")
+	android.AssertStringDoesNotContain(t, "buzz_nocov rustcFlags",
+		buzzNoCov.Rule("rustc").Args["rustcFlags"], "-C instrument-coverage")
+	android.AssertStringDoesNotContain(t, "buzz_nocov linkFlags",
+		buzzNoCov.Rule("rustLink").Args["linkFlags"], runtimeLib)
+	ctx.VisitDirectDeps(buzzNoCov.Module(), func(dep blueprint.Module) {
+		if ctx.ModuleName(dep) == CovLibraryName {
+			t.Errorf("expected buzz_nocov not to depend on %q", CovLibraryName)
+		}
+		if ctx.ModuleName(dep) == "libfoo_cov" && dep.(*Module).coverage.Properties.CoverageEnabled {
+			t.Errorf("expected buzz_nocov to link the libfoo_cov variant without coverage")
+		}
+	})
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+func init() {
+	android.RegisterSingletonType("rust_crate_version_conflicts", crateVersionConflictsSingletonFactory)
+}
+
+// CrateVersionConflictsEnv selects how crates linked into a binary at more than one version are
+// reported.  When set to "error" they fail the build, otherwise they are listed in
+// out/soong/rust_crate_version_conflicts.txt, built by `m rust-crate-version-conflicts`.
+const CrateVersionConflictsEnv = "RUST_CRATE_VERSION_CONFLICTS"
+
+type crateVersionConflictsInfo struct {
+	conflicts []string
+}
+
+var crateVersionConflictsProvider = blueprint.NewProvider(crateVersionConflictsInfo{})
+
+// crateVersionConflicts returns a description of each crate, other than the allowed ones, that
+// appears at more than one cargo_pkg_version in the transitive rlibs and dylibs of the module.
+func crateVersionConflicts(ctx ModuleContext, allowed []string) []string {
+	type crateVersion struct {
+		version string
+		chain   []string
+	}
+	versions := make(map[string][]crateVersion)
+	walkLibraryDeps(ctx, func(dep *Module, chain []string) bool {
+		if dep.compiler == nil {
+			return true
+		}
+		name, version := dep.CrateName(), dep.compiler.CargoPkgVersion()
+		if version == "" || android.InList(name, allowed) {
+			return true
+		}
+		for _, v := range versions[name] {
+			if v.version == version {
+				return true
+			}
+		}
+		versions[name] = append(versions[name], crateVersion{version, chain})
+		return true
+	})
+
+	var conflicts []string
+	for _, name := range android.SortedKeys(versions) {
+		crateVersions := versions[name]
+		if len(crateVersions) < 2 {
+			continue
+		}
+		sort.Slice(crateVersions, func(i, j int) bool {
+			return crateVersions[i].version < crateVersions[j].version
+		})
+		conflict := fmt.Sprintf("crate %q is linked at %d versions:", name, len(crateVersions))
+		for _, v := range crateVersions {
+			conflict += fmt.Sprintf("\n    %s: %s", v.version, strings.Join(v.chain, " -> "))
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
+
+// checkCrateVersionConflicts reports the crates that are linked into the module at more than one
+// version, which usually means two copies of a vendored crate doubling the code size.
+func checkCrateVersionConflicts(ctx ModuleContext, allowed []string) {
+	conflicts := crateVersionConflicts(ctx, allowed)
+	if len(conflicts) == 0 {
+		return
+	}
+	if ctx.Config().Getenv(CrateVersionConflictsEnv) == "error" {
+		for _, conflict := range conflicts {
+			ctx.PropertyErrorf("allowed_crate_version_conflicts",
+				"%s\nRemove one of the copies, or add the crate to allowed_crate_version_conflicts if both are needed.",
+				conflict)
+		}
+		return
+	}
+	ctx.SetProvider(crateVersionConflictsProvider, crateVersionConflictsInfo{conflicts: conflicts})
+}
+
+func crateVersionConflictsSingletonFactory() android.Singleton {
+	return &crateVersionConflictsSingleton{}
+}
+
+// crateVersionConflictsSingleton writes the crate version conflicts of all the rust binaries to a
+// report instead of printing them during analysis.
+type crateVersionConflictsSingleton struct{}
+
+func (c *crateVersionConflictsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var lines []string
+	ctx.VisitAllModules(func(module android.Module) {
+		if !ctx.ModuleHasProvider(module, crateVersionConflictsProvider) {
+			return
+		}
+		info := ctx.ModuleProvider(module, crateVersionConflictsProvider).(crateVersionConflictsInfo)
+		for _, conflict := range info.conflicts {
+			lines = append(lines, fmt.Sprintf("%s: module %s (%s): %s",
+				ctx.ModuleDir(module), ctx.ModuleName(module), ctx.ModuleSubDir(module), conflict))
+		}
+	})
+	sort.Strings(lines)
+
+	report := android.PathForOutput(ctx, "rust_crate_version_conflicts.txt")
+	android.WriteFileRule(ctx, report, strings.Join(lines, "\n"))
+	ctx.Phony("rust-crate-version-conflicts", report)
+}
@@ -0,0 +1,100 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"regexp"
+	"testing"
+
+	"android/soong/android"
+)
+
+var crateVersionsTestBp = `
+	rust_library_rlib {
+		name: "libfoo_1",
+		srcs: ["foo.rs"],
+		crate_name: "foo",
+		cargo_pkg_version: "1.0.0",
+	}
+	rust_library_rlib {
+		name: "libfoo_2",
+		srcs: ["foo.rs"],
+		crate_name: "foo",
+		cargo_pkg_version: "2.0.0",
+	}
+	rust_library_rlib {
+		name: "liba",
+		srcs: ["foo.rs"],
+		crate_name: "a",
+		cargo_pkg_version: "1.0.0",
+		rlibs: ["libfoo_1"],
+	}
+`
+
+func testRustCrateVersions(t *testing.T, bp string, errorHandler android.FixtureErrorHandler) {
+	skipTestIfOsNotSupported(t)
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureMergeEnv(map[string]string{
+			CrateVersionConflictsEnv: "error",
+		}),
+	).
+		ExtendWithErrorHandler(errorHandler).
+		RunTestWithBp(t, crateVersionsTestBp+bp)
+}
+
+func TestCrateVersionConflicts(t *testing.T) {
+	testRustCrateVersions(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba", "libfoo_2"],
+		}`,
+		android.FixtureExpectsOneErrorPattern(regexp.QuoteMeta(`crate "foo" is linked at 2 versions:
+    1.0.0: fizz -> liba -> libfoo_1
+    2.0.0: fizz -> libfoo_2
+Remove one of the copies, or add the crate to allowed_crate_version_conflicts if both are needed.`)))
+}
+
+func TestCrateVersionConflictsAllowed(t *testing.T) {
+	testRustCrateVersions(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba", "libfoo_2"],
+			allowed_crate_version_conflicts: ["foo"],
+		}`,
+		android.FixtureExpectsNoErrors)
+}
+
+func TestCrateVersionConflictsReport(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, crateVersionsTestBp+`
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba", "libfoo_2"],
+		}`)
+
+	report := result.SingletonForTests("rust_crate_version_conflicts").Output("rust_crate_version_conflicts.txt")
+	content := android.ContentFromFileRuleForTests(t, report)
+	android.AssertStringDoesContain(t, "report", content, `module fizz (android_arm64_armv8-a): crate "foo" is linked at 2 versions:
+    1.0.0: fizz -> liba -> libfoo_1
+    2.0.0: fizz -> libfoo_2`)
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+
+	"android/soong/android"
+	"android/soong/rust/config"
+)
+
+var deviceDeniedCratesKey = android.NewOnceKey("rustDeviceDeniedCrates")
+
+func deviceDeniedCrates(c android.Config) []config.DeniedCrate {
+	return c.Once(deviceDeniedCratesKey, func() interface{} {
+		// No test crates were set by PrepareForTestWithDeviceDeniedCrates, use the global list.
+		return config.DeviceDeniedCrates
+	}).([]config.DeniedCrate)
+}
+
+// PrepareForTestWithDeviceDeniedCrates replaces the global list of crates denied on device for a
+// test.
+func PrepareForTestWithDeviceDeniedCrates(crates []config.DeniedCrate) android.FixturePreparer {
+	return android.FixtureModifyConfig(func(c android.Config) {
+		c.Once(deviceDeniedCratesKey, func() interface{} { return crates })
+	})
+}
+
+// deniedCrateMatches returns the denied crate entry that dep matches, if any.
+func deniedCrateMatches(crates []config.DeniedCrate, dep *Module) *config.DeniedCrate {
+	if dep.compiler == nil {
+		return nil
+	}
+	for i, crate := range crates {
+		if crate.CrateName != dep.CrateName() {
+			continue
+		}
+		if crate.Version != "" && crate.Version != dep.compiler.CargoPkgVersion() {
+			continue
+		}
+		return &crates[i]
+	}
+	return nil
+}
+
+// deniedCrateExempt returns whether modules in dir may depend on the denied crate.
+func deniedCrateExempt(crate *config.DeniedCrate, dir string) bool {
+	for _, path := range crate.ExemptPaths {
+		if path = strings.TrimSuffix(path, "/"); dir == path || strings.HasPrefix(dir, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// deniedCratesMutator reports device modules that depend, directly or through the rlibs and dylibs
+// they link, on a crate in the device denylist.  It runs after the dependencies have been added so
+// that the whole chain from the consumer to the denied crate can be reported.
+func deniedCratesMutator(ctx android.BottomUpMutatorContext) {
+	mod, ok := ctx.Module().(*Module)
+	if !ok || !mod.Enabled() || !ctx.Device() {
+		return
+	}
+	crates := deviceDeniedCrates(ctx.Config())
+	if len(crates) == 0 {
+		return
+	}
+
+	walkLibraryDeps(ctx, func(dep *Module, chain []string) bool {
+		crate := deniedCrateMatches(crates, dep)
+		if crate == nil {
+			return true
+		}
+		if deniedCrateExempt(crate, ctx.ModuleDir()) {
+			return false
+		}
+
+		version := ""
+		if crate.Version != "" {
+			version = " version " + crate.Version
+		}
+		ctx.ModuleErrorf("depends on crate %q%s, which is not allowed in device code:\n    %s",
+			crate.CrateName, version, strings.Join(chain, " -> "))
+		return false
+	})
+}
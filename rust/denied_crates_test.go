@@ -0,0 +1,112 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"regexp"
+	"testing"
+
+	"android/soong/android"
+	"android/soong/rust/config"
+)
+
+var deniedCratesTestBp = `
+	rust_library_rlib {
+		name: "libbanned",
+		srcs: ["foo.rs"],
+		crate_name: "banned",
+		cargo_pkg_version: "0.9.0",
+	}
+	rust_library_rlib {
+		name: "liba",
+		srcs: ["foo.rs"],
+		crate_name: "a",
+		rlibs: ["libbanned"],
+	}
+`
+
+func testRustDeniedCrates(t *testing.T, bp string, fs android.MockFS, errorHandler android.FixtureErrorHandler) {
+	skipTestIfOsNotSupported(t)
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		fs.AddToFixture(),
+		PrepareForTestWithDeviceDeniedCrates([]config.DeniedCrate{
+			{
+				CrateName:   "banned",
+				Version:     "0.9.0",
+				ExemptPaths: []string{"exempt/"},
+			},
+			{
+				CrateName: "a",
+				Version:   "2.0.0",
+			},
+		}),
+	).
+		ExtendWithErrorHandler(errorHandler).
+		RunTestWithBp(t, bp)
+}
+
+func TestDeniedCratesTransitive(t *testing.T) {
+	testRustDeniedCrates(t, deniedCratesTestBp+`
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rustlibs: ["liba"],
+		}`,
+		nil,
+		android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			regexp.QuoteMeta(`depends on crate "banned" version 0.9.0, which is not allowed in device code:
+    fizz -> liba -> libbanned`),
+			regexp.QuoteMeta(`depends on crate "banned" version 0.9.0, which is not allowed in device code:
+    liba -> libbanned`),
+		}))
+}
+
+func TestDeniedCratesExemptPath(t *testing.T) {
+	testRustDeniedCrates(t, deniedCratesTestBp,
+		android.MockFS{
+			"exempt/foo.rs": nil,
+			"exempt/Android.bp": []byte(`
+				rust_binary {
+					name: "fizz",
+					srcs: ["foo.rs"],
+					rlibs: ["libbanned"],
+				}
+			`),
+		},
+		android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			// liba is not exempt, but the exempt binary that uses libbanned directly is allowed.
+			regexp.QuoteMeta(`depends on crate "banned" version 0.9.0, which is not allowed in device code:
+    liba -> libbanned`),
+		}))
+}
+
+func TestDeniedCratesHostOnly(t *testing.T) {
+	testRustDeniedCrates(t, `
+		rust_library_host_rlib {
+			name: "libbanned",
+			srcs: ["foo.rs"],
+			crate_name: "banned",
+			cargo_pkg_version: "0.9.0",
+		}
+		rust_binary_host {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["libbanned"],
+		}`,
+		nil,
+		android.FixtureExpectsNoErrors)
+}
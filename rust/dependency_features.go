@@ -0,0 +1,65 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+
+	"android/soong/android"
+)
+
+// checkDependencyFeatures reports the rust libraries in the transitive dependencies of the module
+// that are compiled without the features that the module requires of them, as
+// "<module>:<feature>" entries of the dependency_features property.
+func checkDependencyFeatures(ctx ModuleContext, dependencyFeatures []string) {
+	requested := make(map[string][]string)
+	for _, entry := range dependencyFeatures {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			ctx.PropertyErrorf("dependency_features", "%q is not of the form \"<module>:<feature>\"", entry)
+			continue
+		}
+		requested[parts[0]] = append(requested[parts[0]], parts[1])
+	}
+	if len(requested) == 0 {
+		return
+	}
+
+	found := make(map[string]bool)
+	walkLibraryDeps(ctx, func(dep *Module, chain []string) bool {
+		name := chain[len(chain)-1]
+		features, ok := requested[name]
+		if !ok || dep.compiler == nil || found[name] {
+			return true
+		}
+		found[name] = true
+
+		compiled := dep.compiler.features()
+		if missing := android.RemoveListFromList(android.FirstUniqueStrings(features), compiled); len(missing) > 0 {
+			ctx.PropertyErrorf("dependency_features",
+				"%s (%s) is compiled with the features %q, without the requested features %q\n"+
+					"The library is compiled once for all the modules that link it, so define a separate "+
+					"rust_library of the crate with the features this module needs, and depend on it instead.",
+				name, strings.Join(chain, " -> "), compiled, missing)
+		}
+		return true
+	})
+
+	for _, name := range android.SortedKeys(requested) {
+		if !found[name] {
+			ctx.PropertyErrorf("dependency_features", "%q is not a rust library dependency of this module", name)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"regexp"
+	"testing"
+
+	"android/soong/android"
+)
+
+var dependencyFeaturesTestBp = `
+	rust_library_rlib {
+		name: "libfoo",
+		srcs: ["foo.rs"],
+		crate_name: "foo",
+		features: ["std", "serde"],
+	}
+	rust_library_rlib {
+		name: "liba",
+		srcs: ["foo.rs"],
+		crate_name: "a",
+		rlibs: ["libfoo"],
+	}
+`
+
+func testRustDependencyFeatures(t *testing.T, bp string, errorHandler android.FixtureErrorHandler) {
+	skipTestIfOsNotSupported(t)
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+	).
+		ExtendWithErrorHandler(errorHandler).
+		RunTestWithBp(t, dependencyFeaturesTestBp+bp)
+}
+
+func TestDependencyFeatures(t *testing.T) {
+	testRustDependencyFeatures(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba"],
+			dependency_features: ["libfoo:std", "libfoo:serde"],
+		}`,
+		android.FixtureExpectsNoErrors)
+}
+
+func TestDependencyFeaturesConflict(t *testing.T) {
+	testRustDependencyFeatures(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba"],
+			dependency_features: ["libfoo:std"],
+		}
+		rust_binary {
+			name: "buzz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba"],
+			dependency_features: ["libfoo:std", "libfoo:alloc"],
+		}`,
+		android.FixtureExpectsOneErrorPattern(regexp.QuoteMeta(
+			`libfoo (buzz -> liba -> libfoo) is compiled with the features ["std" "serde"], `+
+				`without the requested features ["alloc"]`)))
+}
+
+func TestDependencyFeaturesErrors(t *testing.T) {
+	testRustDependencyFeatures(t, `
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["liba"],
+			dependency_features: ["libbar:std", "std"],
+		}`,
+		android.FixtureExpectsAllErrorsToMatchAPattern([]string{
+			regexp.QuoteMeta(`"std" is not of the form "<module>:<feature>"`),
+			regexp.QuoteMeta(`"libbar" is not a rust library dependency of this module`),
+		}))
+}
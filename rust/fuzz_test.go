@@ -61,3 +61,26 @@ func TestRustFuzz(t *testing.T) {
 		t.Errorf("rust_fuzz dependent library does not contain the expected flags (sancov-module, cfg fuzzing, hwaddress sanitizer).")
 	}
 }
+
+func TestRustFuzzCoverageBuild(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	ctx := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureMergeEnv(map[string]string{
+			"FUZZ_COVERAGE_BUILD": "true",
+		}),
+	).RunTestWithBp(t, `
+			rust_fuzz {
+				name: "fuzz_libtest",
+				srcs: ["foo.rs"],
+			}
+	`)
+
+	fuzz_libtest := ctx.ModuleForTests("fuzz_libtest", "android_arm64_armv8-a_fuzzer").Rule("rustc")
+	rustcFlags := fuzz_libtest.Args["rustcFlags"]
+	android.AssertStringDoesContain(t, "rustcFlags", rustcFlags, "-C instrument-coverage")
+	android.AssertStringDoesContain(t, "rustcFlags", rustcFlags, "--cfg fuzzing")
+	android.AssertStringDoesNotContain(t, "rustcFlags", rustcFlags, "-C passes='sancov-module'")
+	android.AssertStringDoesNotContain(t, "rustcFlags", rustcFlags, "-Z sanitizer=hwaddress")
+}
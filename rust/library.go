@@ -22,6 +22,8 @@ import (
 	"android/soong/android"
 	"android/soong/cc"
 	"android/soong/snapshot"
+
+	"github.com/google/blueprint/proptools"
 )
 
 var (
@@ -60,6 +62,24 @@ type LibraryCompilerProperties struct {
 
 	// Whether this library is part of the Rust toolchain sysroot.
 	Sysroot *bool
+
+	// the crate types to build for a rust_library, any of "rlib", "dylib" and "staticlib".  Defaults
+	// to ["rlib", "dylib"].  Adding "staticlib" builds an additional static variant that cc modules
+	// can link against, so a single module can serve both Rust and C consumers.
+	Crate_types []string `android:"arch_variant"`
+
+	// Generate stubs to make the shared library variant accessible to APEXes, like the stubs of cc
+	// libraries.  The stubs are C, and are built from the symbol file by a cc_library_shared module
+	// named <name>.stubs that is created for this library.
+	Stubs struct {
+		// Relative path to the symbol map. The symbol map provides the list of
+		// symbols that are exported for stubs variant of this library.
+		Symbol_file *string `android:"path"`
+
+		// List versions to generate stubs libs for. The version name "current" is always
+		// implicitly added.
+		Versions []string
+	}
 }
 
 type LibraryMutatedProperties struct {
@@ -121,6 +141,12 @@ type libraryInterface interface {
 	buildShared() bool
 	buildStatic() bool
 
+	// Returns the crate types selected by the crate_types property, or nil if it is not set
+	crateTypes() []string
+
+	// Returns true if the stubs property is set, see createStubsLibrary
+	hasStubsVariants() bool
+
 	// Sets a particular variant type
 	setRlib()
 	setDylib()
@@ -177,11 +203,13 @@ func (library *libraryDecorator) source() bool {
 }
 
 func (library *libraryDecorator) buildRlib() bool {
-	return library.MutatedProperties.BuildRlib && BoolDefault(library.Properties.Rlib.Enabled, true)
+	return library.MutatedProperties.BuildRlib && BoolDefault(library.Properties.Rlib.Enabled, true) &&
+		library.crateTypeSelected("rlib", true)
 }
 
 func (library *libraryDecorator) buildDylib() bool {
-	return library.MutatedProperties.BuildDylib && BoolDefault(library.Properties.Dylib.Enabled, true)
+	return library.MutatedProperties.BuildDylib && BoolDefault(library.Properties.Dylib.Enabled, true) &&
+		library.crateTypeSelected("dylib", true)
 }
 
 func (library *libraryDecorator) buildShared() bool {
@@ -189,7 +217,87 @@ func (library *libraryDecorator) buildShared() bool {
 }
 
 func (library *libraryDecorator) buildStatic() bool {
-	return library.MutatedProperties.BuildStatic && BoolDefault(library.Properties.Static.Enabled, true)
+	buildStatic := library.MutatedProperties.BuildStatic ||
+		(library.buildsRustCrates() && library.crateTypeSelected("staticlib", false))
+	return buildStatic && BoolDefault(library.Properties.Static.Enabled, true)
+}
+
+// buildsRustCrates returns true for the module types that build rlib or dylib crates, which are the
+// only ones that support the crate_types property.
+func (library *libraryDecorator) buildsRustCrates() bool {
+	return library.MutatedProperties.BuildRlib || library.MutatedProperties.BuildDylib
+}
+
+func (library *libraryDecorator) crateTypes() []string {
+	return library.Properties.Crate_types
+}
+
+func (library *libraryDecorator) hasStubsVariants() bool {
+	// Just having stubs.symbol_file is enough to create a stub variant, as for cc libraries.
+	return library.Properties.Stubs.Symbol_file != nil || len(library.Properties.Stubs.Versions) > 0
+}
+
+// stubsLibraryName returns the name of the cc library that builds the stubs of the library name.
+func stubsLibraryName(name string) string {
+	return name + ".stubs"
+}
+
+// createStubsLibrary creates the cc_library_shared module that builds the stubs variants of a
+// library with stubs, so that they are generated by ndkstubgen and versioned exactly like the stubs
+// of cc libraries.  The shared variant of the library depends on it, and provides its
+// SharedLibraryStubsInfo to the modules that link against the library across APEX boundaries.
+func (library *libraryDecorator) createStubsLibrary(ctx android.DefaultableHookContext, module *Module) {
+	if !library.hasStubsVariants() {
+		return
+	}
+	if !library.buildShared() || module.hod == android.HostSupported {
+		ctx.PropertyErrorf("stubs", "stubs are only supported by libraries with a device shared variant")
+		return
+	}
+
+	stem := ctx.ModuleName()
+	if s := String(library.baseCompiler.Properties.Stem); s != "" {
+		stem = s
+	}
+
+	props := struct {
+		Name   *string
+		Stem   *string
+		Suffix *string
+		Stubs  struct {
+			Symbol_file *string
+			Versions    []string
+		}
+		Apex_available     []string
+		Min_sdk_version    *string
+		Stl                *string
+		System_shared_libs []string
+		Installable        *bool
+		Visibility         []string
+	}{
+		Name:               proptools.StringPtr(stubsLibraryName(ctx.ModuleName())),
+		Stem:               proptools.StringPtr(stem),
+		Suffix:             library.baseCompiler.Properties.Suffix,
+		Apex_available:     module.ApexProperties.Apex_available,
+		Min_sdk_version:    module.Properties.Min_sdk_version,
+		Stl:                proptools.StringPtr("none"),
+		System_shared_libs: []string{},
+		// Only the stubs variants are used, the implementation is the rust library itself.
+		Installable: proptools.BoolPtr(false),
+		Visibility:  []string{"//visibility:private"},
+	}
+	props.Stubs.Symbol_file = library.Properties.Stubs.Symbol_file
+	props.Stubs.Versions = library.Properties.Stubs.Versions
+	ctx.CreateModule(cc.LibrarySharedFactory, &props)
+}
+
+// crateTypeSelected returns whether the crate_types property selects the given crate type, or
+// defaultValue if the property is not set.
+func (library *libraryDecorator) crateTypeSelected(crateType string, defaultValue bool) bool {
+	if library.crateTypes() == nil {
+		return defaultValue
+	}
+	return android.InList(crateType, library.crateTypes())
 }
 
 func (library *libraryDecorator) setRlib() {
@@ -418,6 +526,9 @@ func NewRustLibrary(hod android.HostOrDeviceSupported) (*Module, *libraryDecorat
 	}
 
 	module.compiler = library
+	module.SetDefaultableHook(func(ctx android.DefaultableHookContext) {
+		library.createStubsLibrary(ctx, module)
+	})
 
 	return module, library
 }
@@ -518,9 +629,9 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 	}
 	library.baseCompiler.unstrippedOutputFile = outputFile
 
-	flags.RustFlags = append(flags.RustFlags, deps.depFlags...)
+	flags.RustFlags = append(flags.RustFlags, deps.depFlags.ToList()...)
 	flags.LinkFlags = append(flags.LinkFlags, deps.depLinkFlags...)
-	flags.LinkFlags = append(flags.LinkFlags, deps.linkObjects.Strings()...)
+	flags.LinkFlags = append(flags.LinkFlags, deps.linkObjects.ToList().Strings()...)
 
 	if library.dylib() {
 		// We need prefer-dynamic for now to avoid linking in the static stdlib. See:
@@ -541,14 +652,14 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 	}
 
 	if library.rlib() || library.dylib() {
-		library.flagExporter.exportLinkDirs(deps.linkDirs...)
-		library.flagExporter.exportLinkObjects(deps.linkObjects...)
+		library.flagExporter.exportTransitiveLinkDirs(deps.linkDirs)
+		library.flagExporter.exportTransitiveLinkObjects(deps.linkObjects)
 		library.flagExporter.exportLibDeps(deps.LibDeps...)
 	}
 
 	if library.static() || library.shared() {
 		ctx.SetProvider(cc.FlagExporterInfoProvider, cc.FlagExporterInfo{
-			IncludeDirs: library.includeDirs,
+			IncludeDirs: android.NewDepSet(android.PREORDER, library.includeDirs, nil),
 		})
 	}
 
@@ -564,6 +675,13 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 			SharedLibrary:   outputFile,
 			Target:          ctx.Target(),
 		})
+
+		// Let the modules that link against this library across APEX boundaries choose between the
+		// stubs variants of the library created by createStubsLibrary.
+		if stubs := ctx.GetDirectDepsWithTag(stubsLibraryDepTag); len(stubs) > 0 {
+			ctx.SetProvider(cc.SharedLibraryStubsProvider,
+				ctx.OtherModuleProvider(stubs[0], cc.SharedLibraryStubsProvider))
+		}
 	}
 
 	if library.static() {
@@ -643,7 +761,11 @@ func validateLibraryStem(ctx BaseModuleContext, filename string, crate_name stri
 
 	// Libraries are expected to begin with "lib" followed by the crate_name
 	if !strings.HasPrefix(filename, "lib"+crate_name) {
-		ctx.ModuleErrorf("Invalid name or stem property; library filenames must start with lib<crate_name>")
+		property := "stem"
+		if filename == ctx.ModuleName() {
+			property = "name"
+		}
+		ctx.PropertyErrorf(property, "library filenames must start with lib<crate_name>")
 	}
 }
 
@@ -660,6 +782,21 @@ func LibraryMutator(mctx android.BottomUpMutatorContext) {
 		return
 	}
 
+	if crateTypes := library.crateTypes(); crateTypes != nil {
+		if l, ok := m.compiler.(*libraryDecorator); !ok || !l.buildsRustCrates() || m.sourceProvider != nil {
+			mctx.PropertyErrorf("crate_types", "crate_types is only supported by rust_library modules")
+		}
+		for _, crateType := range crateTypes {
+			if !android.InList(crateType, []string{"rlib", "dylib", "staticlib"}) {
+				mctx.PropertyErrorf("crate_types", "unsupported crate type %q, expected rlib, dylib or staticlib", crateType)
+			}
+		}
+	}
+
+	// The static variant of a rust_library created by cc's linkage mutator for the "staticlib" crate
+	// type only builds the static library.
+	ccVariant := library.static() || library.shared()
+
 	var variants []string
 	// The source variant is used for SourceProvider modules. The other variants (i.e. rlib and dylib)
 	// depend on this variant. It must be the first variant to be declared.
@@ -668,10 +805,10 @@ func LibraryMutator(mctx android.BottomUpMutatorContext) {
 		variants = append(variants, "source")
 		sourceVariant = true
 	}
-	if library.buildRlib() {
+	if library.buildRlib() && !ccVariant {
 		variants = append(variants, rlibVariation)
 	}
-	if library.buildDylib() {
+	if library.buildDylib() && !ccVariant {
 		variants = append(variants, dylibVariation)
 	}
 
@@ -115,20 +115,20 @@ func TestValidateLibraryStem(t *testing.T) {
 				crate_name: "foo-bar"
 			}`)
 
-	testRustError(t, "Invalid name or stem property; library filenames must start with lib<crate_name>", `
+	testRustError(t, "name: library filenames must start with lib<crate_name>", `
 			rust_library_host {
 				name: "foobar",
 				srcs: ["foo.rs"],
 				crate_name: "foo_bar"
 			}`)
-	testRustError(t, "Invalid name or stem property; library filenames must start with lib<crate_name>", `
+	testRustError(t, `Android.bp:4:9: module "foobar" .*: stem: library filenames must start with lib<crate_name>`, `
 			rust_library_host {
 				name: "foobar",
 				stem: "libfoo",
 				srcs: ["foo.rs"],
 				crate_name: "foo_bar"
 			}`)
-	testRustError(t, "Invalid name or stem property; library filenames must start with lib<crate_name>", `
+	testRustError(t, "stem: library filenames must start with lib<crate_name>", `
 			rust_library_host {
 				name: "foobar",
 				stem: "foo_bar",
@@ -196,6 +196,109 @@ func TestStaticLibraryLinkage(t *testing.T) {
 	}
 }
 
+// Test that a rust_library with the staticlib crate type can be used by Rust and C consumers at the
+// same time.
+func TestLibraryStaticlibCrateType(t *testing.T) {
+	ctx := testRust(t, `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			crate_types: ["rlib", "staticlib"],
+		}
+		rust_binary {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rlibs: ["libfoo"],
+		}
+		cc_binary {
+			name: "buzz",
+			static_libs: ["libfoo"],
+		}`)
+
+	for _, variant := range ctx.ModuleVariantsForTests("libfoo") {
+		if strings.Contains(variant, "dylib") && !strings.Contains(variant, "dylib-std") {
+			t.Errorf("unexpected dylib variant %q for crate_types without dylib", variant)
+		}
+	}
+
+	libfooStatic := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_static")
+	if crateType := "crate-type=staticlib"; !strings.Contains(libfooStatic.Rule("rustc").Args["rustcFlags"], crateType) {
+		t.Errorf("missing %q for static variant, rustcFlags: %#v", crateType, libfooStatic.Rule("rustc").Args["rustcFlags"])
+	}
+	if libfooStatic.MaybeOutput("libfoo.rlib").Rule != nil {
+		t.Errorf("static variant of libfoo should not build an rlib")
+	}
+
+	fizz := ctx.ModuleForTests("fizz", "android_arm64_armv8-a").Rule("rustc")
+	if !android.SuffixInList(fizz.Implicits.Strings(), "libfoo.rlib") {
+		t.Errorf("rust_binary should link libfoo as an rlib, implicits: %#v", fizz.Implicits.Strings())
+	}
+
+	buzz := ctx.ModuleForTests("buzz", "android_arm64_armv8-a").Rule("ld")
+	if !android.SuffixInList(buzz.Implicits.Strings(), "libfoo.a") {
+		t.Errorf("cc_binary should link libfoo as a static library, implicits: %#v", buzz.Implicits.Strings())
+	}
+	if android.SuffixInList(buzz.Implicits.Strings(), "libfoo.rlib") {
+		t.Errorf("cc_binary should not link libfoo's rlib, implicits: %#v", buzz.Implicits.Strings())
+	}
+}
+
+func TestLibraryCrateTypesErrors(t *testing.T) {
+	testRustError(t, `unsupported crate type "cdylib", expected rlib, dylib or staticlib`, `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			crate_types: ["rlib", "cdylib"],
+		}`)
+	testRustError(t, "crate_types is only supported by rust_library modules", `
+		rust_ffi {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			crate_types: ["staticlib"],
+		}`)
+}
+
+func TestLibraryStubs(t *testing.T) {
+	ctx := testRust(t, `
+		rust_ffi_shared {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			stubs: {
+				symbol_file: "liblog.map.txt",
+				versions: ["29"],
+			},
+		}`)
+
+	// The stubs are built by the created cc library, from the symbol file of the rust library.
+	for _, version := range []string{"29", "current"} {
+		stubs := ctx.ModuleForTests("libfoo.stubs", "android_arm64_armv8-a_shared_"+version)
+		android.AssertPathRelativeToTopEquals(t, "stubs of version "+version, "liblog.map.txt",
+			stubs.Rule("genStubSrc").Input)
+		stubs.Output("libfoo.so")
+	}
+
+	libfoo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_shared").Module()
+	if !libfoo.(*Module).HasStubsVariants() {
+		t.Errorf("libfoo should have stubs variants")
+	}
+}
+
+func TestLibraryStubsErrors(t *testing.T) {
+	testRustError(t, "stubs are only supported by libraries with a device shared variant", `
+		rust_ffi_static {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			stubs: {
+				symbol_file: "liblog.map.txt",
+			},
+		}`)
+}
+
 // Test that variants pull in the right type of rustlib autodep
 func TestAutoDeps(t *testing.T) {
 
@@ -0,0 +1,153 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+// The environment variable that selects the benchmarks to build instrumented modules for, as a
+// comma separated list, or "all" for all the modules with profile_gen set.  It is shared with the
+// instrumentation PGO of cc modules.
+const pgoInstrumentEnvVar = "ANDROID_PGO_INSTRUMENT"
+
+const (
+	profileGenerateRustFlag = "-Cprofile-generate="
+	profileGenerateLinkFlag = "-fprofile-generate="
+)
+
+type PgoProperties struct {
+	Pgo struct {
+		// Build the module with profile generation instrumentation, to collect the profiles that
+		// are used to optimize it, when ANDROID_PGO_INSTRUMENT lists one of its benchmarks or
+		// "all". The profiles are written to the directory set by the RustPgoProfileGenDir
+		// product variable, /data/local/tmp by default.
+		Profile_gen *bool
+
+		// The benchmarks that the profiles of this module are collected with.
+		Benchmarks []string
+	}
+
+	// Whether the module is built with profile generation instrumentation.
+	ShouldProfileModule bool `blueprint:"mutated"`
+}
+
+type pgoInfo struct {
+	// Whether the module, or the rlibs and static libraries that it links, are built with
+	// profile generation instrumentation, and its dependents must be linked with the profile
+	// runtime.
+	InstrLink bool
+}
+
+var pgoInfoProvider = blueprint.NewProvider(pgoInfo{})
+
+type pgo struct {
+	Properties PgoProperties
+}
+
+func (pgo *pgo) props() []interface{} {
+	return []interface{}{&pgo.Properties}
+}
+
+func (pgo *pgo) begin(ctx BaseModuleContext) {
+	// Profile generation is not supported outside of Android.
+	if ctx.Host() || !proptools.Bool(pgo.Properties.Pgo.Profile_gen) {
+		return
+	}
+
+	benchmarks := strings.Split(ctx.Config().Getenv(pgoInstrumentEnvVar), ",")
+	selected := android.InList("all", benchmarks) || android.InList("ALL", benchmarks)
+	for _, b := range pgo.Properties.Pgo.Benchmarks {
+		if android.InList(b, benchmarks) {
+			selected = true
+		}
+	}
+	pgo.Properties.ShouldProfileModule = selected
+}
+
+func (pgo *pgo) deps(ctx DepsContext, deps Deps) Deps {
+	// no_std modules are missing libprofiler_builtins which provides the profile runtime, so we need
+	// to add it as a dependency, unless coverage already does.
+	if pgo.Properties.ShouldProfileModule {
+		if rustModule, ok := ctx.Module().(*Module); ok && rustModule.compiler.noStdlibs() &&
+			!rustModule.coverage.Properties.NeedCoverageVariant {
+			ctx.AddVariationDependencies([]blueprint.Variation{{Mutator: "rust_libraries", Variation: "rlib"}}, rlibDepTag, ProfilerBuiltins)
+		}
+	}
+
+	return deps
+}
+
+func (pgo *pgo) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags, PathDeps) {
+	if ctx.Host() {
+		return flags, deps
+	}
+
+	// The module must be linked with the profile runtime if any of the rlibs or static libraries
+	// that it links are instrumented.  Instrumented and non-instrumented variants of shared
+	// libraries cannot be mixed, as their profiles would be incomplete, so an instrumented module
+	// must not link a shared library that has profile_gen set but is not instrumented.
+	instrLink := pgo.Properties.ShouldProfileModule
+	ctx.VisitDirectDeps(func(m android.Module) {
+		dep, ok := m.(*Module)
+		if !ok || dep.pgo == nil {
+			return
+		}
+		depTag := ctx.OtherModuleDependencyTag(m)
+		switch {
+		case depTag == rlibDepTag || cc.IsStaticDepTag(depTag):
+			if ctx.OtherModuleProvider(m, pgoInfoProvider).(pgoInfo).InstrLink {
+				instrLink = true
+			}
+		case depTag == dylibDepTag || cc.IsSharedDepTag(depTag):
+			if pgo.Properties.ShouldProfileModule && proptools.Bool(dep.pgo.Properties.Pgo.Profile_gen) &&
+				!dep.pgo.Properties.ShouldProfileModule {
+				ctx.ModuleErrorf("instrumented for profile generation, but links %q which is not, add "+
+					"the benchmarks in %s to its pgo.benchmarks",
+					ctx.OtherModuleName(m), pgoInstrumentEnvVar)
+			}
+		}
+	})
+
+	profileDir := ctx.DeviceConfig().RustPgoProfileGenDir()
+	if pgo.Properties.ShouldProfileModule {
+		flags.RustFlags = append(flags.RustFlags, profileGenerateRustFlag+profileDir)
+
+		// no_std modules are missing libprofiler_builtins which provides the profile runtime, so we
+		// need to add it as a dependency, unless coverage already did.
+		if rustModule, ok := ctx.Module().(*Module); ok && rustModule.compiler.noStdlibs() && !flags.Coverage {
+			profiler_builtins := ctx.GetDirectDepWithTag(ProfilerBuiltins, rlibDepTag).(*Module)
+			deps.RLibs = append(deps.RLibs, RustLibrary{Path: profiler_builtins.OutputFile().Path(), CrateName: profiler_builtins.CrateName()})
+		}
+	}
+	if instrLink {
+		flags.LinkFlags = append(flags.LinkFlags, profileGenerateLinkFlag+profileDir)
+	}
+	ctx.SetProvider(pgoInfoProvider, pgoInfo{InstrLink: instrLink})
+
+	return flags, deps
+}
+
+// profileGen returns true if the module is built with profile generation instrumentation, in which
+// case it must not use a profile.
+func (mod *Module) profileGen() bool {
+	return mod.pgo != nil && mod.pgo.Properties.ShouldProfileModule
+}
@@ -0,0 +1,164 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+const pgoTestBp = `
+	rust_binary {
+		name: "foo",
+		srcs: ["foo.rs"],
+		rlibs: ["libbar"],
+		pgo: {
+			profile_gen: true,
+			benchmarks: ["benchmark"],
+		},
+	}
+
+	rust_binary {
+		name: "baz",
+		srcs: ["baz.rs"],
+		rlibs: ["libbar"],
+	}
+
+	rust_library {
+		name: "libbar",
+		srcs: ["bar.rs"],
+		crate_name: "bar",
+		pgo: {
+			profile_gen: true,
+			benchmarks: ["benchmark"],
+		},
+	}
+`
+
+func TestPgoProfileGen(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{pgoInstrumentEnvVar: "benchmark"}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.RustPgoProfileGenDir = proptools.StringPtr("/data/local/tmp/pgo")
+		}),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, pgoTestBp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesContain(t, "foo rustcFlags", foo.Args["rustcFlags"], "-Cprofile-generate=/data/local/tmp/pgo")
+	android.AssertStringDoesContain(t, "foo linkFlags", foo.Args["linkFlags"], "-fprofile-generate=/data/local/tmp/pgo")
+
+	libbar := result.ModuleForTests("libbar", "android_arm64_armv8-a_rlib_dylib-std").Rule("rustc")
+	android.AssertStringDoesContain(t, "libbar rustcFlags", libbar.Args["rustcFlags"], "-Cprofile-generate=/data/local/tmp/pgo")
+
+	// baz is not instrumented, but it links the instrumented libbar and needs the profile runtime.
+	baz := result.ModuleForTests("baz", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "baz rustcFlags", baz.Args["rustcFlags"], "-Cprofile-generate")
+	android.AssertStringDoesContain(t, "baz linkFlags", baz.Args["linkFlags"], "-fprofile-generate=/data/local/tmp/pgo")
+}
+
+func TestPgoProfileGenTransitive(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{pgoInstrumentEnvVar: "benchmark"}),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, pgoTestBp+`
+		rust_binary {
+			name: "qux",
+			srcs: ["qux.rs"],
+			rlibs: ["libmid"],
+		}
+
+		rust_library {
+			name: "libmid",
+			srcs: ["mid.rs"],
+			crate_name: "mid",
+			rlibs: ["libbar"],
+		}
+	`)
+
+	// libmid is not instrumented, but it publishes that it links the instrumented libbar, so qux
+	// needs the profile runtime.
+	libmid := result.ModuleForTests("libmid", "android_arm64_armv8-a_rlib_dylib-std")
+	android.AssertStringDoesNotContain(t, "libmid rustcFlags", libmid.Rule("rustc").Args["rustcFlags"], "-Cprofile-generate")
+	android.AssertBoolEquals(t, "libmid InstrLink", true,
+		result.ModuleProvider(libmid.Module(), pgoInfoProvider).(pgoInfo).InstrLink)
+
+	qux := result.ModuleForTests("qux", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesContain(t, "qux linkFlags", qux.Args["linkFlags"], "-fprofile-generate=/data/local/tmp")
+}
+
+func TestPgoProfileGenDisabled(t *testing.T) {
+	for _, env := range []string{"", "other_benchmark"} {
+		t.Run(env, func(t *testing.T) {
+			result := android.GroupFixturePreparers(
+				prepareForRustTest,
+				android.FixtureMergeEnv(map[string]string{pgoInstrumentEnvVar: env}),
+				rustMockedFiles.AddToFixture(),
+			).RunTestWithBp(t, pgoTestBp)
+
+			for _, module := range []string{"foo", "baz"} {
+				rustc := result.ModuleForTests(module, "android_arm64_armv8-a").Rule("rustc")
+				android.AssertStringDoesNotContain(t, module+" rustcFlags", rustc.Args["rustcFlags"], "profile-generate")
+				android.AssertStringDoesNotContain(t, module+" linkFlags", rustc.Args["linkFlags"], "profile-generate")
+			}
+		})
+	}
+}
+
+func TestPgoProfileGenAll(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{pgoInstrumentEnvVar: "all"}),
+		rustMockedFiles.AddToFixture(),
+	).RunTestWithBp(t, pgoTestBp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesContain(t, "foo rustcFlags", foo.Args["rustcFlags"], "-Cprofile-generate=/data/local/tmp")
+}
+
+func TestPgoProfileGenMixedSharedLibs(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{pgoInstrumentEnvVar: "benchmark"}),
+		rustMockedFiles.AddToFixture(),
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		`instrumented for profile generation, but links "libqux" which is not`,
+	)).RunTestWithBp(t, `
+		rust_binary {
+			name: "foo",
+			srcs: ["foo.rs"],
+			dylibs: ["libqux"],
+			pgo: {
+				profile_gen: true,
+				benchmarks: ["benchmark"],
+			},
+		}
+
+		rust_library {
+			name: "libqux",
+			srcs: ["qux.rs"],
+			crate_name: "qux",
+			pgo: {
+				profile_gen: true,
+				benchmarks: ["other_benchmark"],
+			},
+		}
+	`)
+}
@@ -38,10 +38,16 @@ func init() {
 	android.PreDepsMutators(func(ctx android.RegisterMutatorsContext) {
 		ctx.BottomUp("rust_libraries", LibraryMutator).Parallel()
 		ctx.BottomUp("rust_stdlinkage", LibstdMutator).Parallel()
+		ctx.BottomUp("rust_toolchain_deps", RustToolchainDepsMutator).Parallel()
+		ctx.BottomUp("rust_toolchain", RustToolchainMutator).Parallel()
+		ctx.BottomUp("rust_test_per_src", TestPerSrcMutator).Parallel()
 		ctx.BottomUp("rust_begin", BeginMutator).Parallel()
 	})
 	android.PostDepsMutators(func(ctx android.RegisterMutatorsContext) {
 		ctx.BottomUp("rust_sanitizers", rustSanitizerRuntimeMutator).Parallel()
+		ctx.BottomUp("rust_denied_crates", deniedCratesMutator).Parallel()
+		ctx.TopDown("rust_afdo_deps", afdoDepsMutator)
+		ctx.BottomUp("rust_afdo", afdoMutator).Parallel()
 	})
 	pctx.Import("android/soong/rust/config")
 	pctx.ImportAs("cc_config", "android/soong/cc/config")
@@ -77,6 +83,9 @@ type BaseProperties struct {
 	// appended before SubName.
 	RustSubName string `blueprint:"mutated"`
 
+	// Set by RustToolchainMutator on the variants that are built with the secondary rust toolchain.
+	SecondaryRustToolchain bool `blueprint:"mutated"`
+
 	// Set by imageMutator
 	CoreVariantNeeded          bool     `blueprint:"mutated"`
 	VendorRamdiskVariantNeeded bool     `blueprint:"mutated"`
@@ -129,6 +138,9 @@ type BaseProperties struct {
 	HideFromMake   bool `blueprint:"mutated"`
 	PreventInstall bool `blueprint:"mutated"`
 
+	// The name of the experiment that this variant is built for, or "" for the normal variant.
+	Experiment string `blueprint:"mutated"`
+
 	Installable *bool
 }
 
@@ -148,6 +160,7 @@ type Module struct {
 	compiler         compiler
 	coverage         *coverage
 	clippy           *clippy
+	pgo              *pgo
 	sanitize         *sanitize
 	cachedToolchain  config.Toolchain
 	sourceProvider   SourceProvider
@@ -184,6 +197,22 @@ func (mod *Module) HiddenFromMake() bool {
 	return mod.Properties.HideFromMake
 }
 
+func (mod *Module) ExperimentSupported() bool {
+	return mod.compiler != nil && !mod.IsPrebuilt()
+}
+
+func (mod *Module) SetExperiment(name string) {
+	mod.Properties.Experiment = name
+	mod.Properties.PreventInstall = true
+	mod.Properties.HideFromMake = true
+}
+
+func (mod *Module) Experiment() string {
+	return mod.Properties.Experiment
+}
+
+var _ android.ExperimentModule = (*Module)(nil)
+
 func (mod *Module) SanitizePropDefined() bool {
 	// Because compiler is not set for some Rust modules where sanitize might be set, check that compiler is also not
 	// nil since we need compiler to actually sanitize.
@@ -224,8 +253,10 @@ func (mod *Module) SelectedStl() string {
 
 func (mod *Module) NonCcVariants() bool {
 	if mod.compiler != nil {
-		if _, ok := mod.compiler.(libraryInterface); ok {
-			return false
+		if library, ok := mod.compiler.(libraryInterface); ok {
+			// A rust_library that also builds a staticlib needs a variant for its rlib and dylib
+			// variants alongside the static variant.
+			return library.buildRlib() || library.buildDylib()
 		}
 	}
 	panic(fmt.Errorf("NonCcVariants called on non-library module: %q", mod.BaseModuleName()))
@@ -428,13 +459,14 @@ type PathDeps struct {
 	AfdoProfiles    android.Paths
 
 	// depFlags and depLinkFlags are rustc and linker (clang) flags.
-	depFlags     []string
+	depFlags     *android.StringsDepSet
 	depLinkFlags []string
 
 	// linkDirs are link paths passed via -L to rustc. linkObjects are objects passed directly to the linker.
-	// Both of these are exported and propagate to dependencies.
-	linkDirs    []string
-	linkObjects android.Paths
+	// Both of these are exported and propagate to dependencies, so they are kept as DepSets that are
+	// only flattened when the flags are built.
+	linkDirs    *android.StringsDepSet
+	linkObjects *android.DepSet
 
 	// Used by bindgen modules which call clang
 	depClangFlags         []string
@@ -466,6 +498,7 @@ type compiler interface {
 	compile(ctx ModuleContext, flags Flags, deps PathDeps) buildOutput
 	compilerDeps(ctx DepsContext, deps Deps) Deps
 	crateName() string
+	features() []string
 	rustdoc(ctx ModuleContext, flags Flags, deps PathDeps) android.OptionalPath
 
 	// Output directory in which source-generated code from dependencies is
@@ -493,6 +526,8 @@ type compiler interface {
 
 	unstrippedOutputFilePath() android.Path
 	strippedOutputFilePath() android.OptionalPath
+
+	externRlibs(ctx android.ModuleContext) RustLibraries
 }
 
 type exportedFlagsProducer interface {
@@ -508,6 +543,9 @@ type flagExporter struct {
 	linkDirs    []string
 	linkObjects android.Paths
 	libDeps     android.Paths
+
+	transitiveLinkDirs    []*android.StringsDepSet
+	transitiveLinkObjects []*android.DepSet
 }
 
 func (flagExporter *flagExporter) exportLinkDirs(dirs ...string) {
@@ -518,14 +556,30 @@ func (flagExporter *flagExporter) exportLinkObjects(flags ...android.Path) {
 	flagExporter.linkObjects = android.FirstUniquePaths(append(flagExporter.linkObjects, flags...))
 }
 
+// exportTransitiveLinkDirs exports the link dirs of the dependencies, after the link dirs exported
+// by exportLinkDirs.
+func (flagExporter *flagExporter) exportTransitiveLinkDirs(dirs *android.StringsDepSet) {
+	if dirs != nil {
+		flagExporter.transitiveLinkDirs = append(flagExporter.transitiveLinkDirs, dirs)
+	}
+}
+
+// exportTransitiveLinkObjects exports the link objects of the dependencies, after the link objects
+// exported by exportLinkObjects.
+func (flagExporter *flagExporter) exportTransitiveLinkObjects(objects *android.DepSet) {
+	if objects != nil {
+		flagExporter.transitiveLinkObjects = append(flagExporter.transitiveLinkObjects, objects)
+	}
+}
+
 func (flagExporter *flagExporter) exportLibDeps(paths ...android.Path) {
 	flagExporter.libDeps = android.FirstUniquePaths(append(flagExporter.libDeps, paths...))
 }
 
 func (flagExporter *flagExporter) setProvider(ctx ModuleContext) {
 	ctx.SetProvider(FlagExporterInfoProvider, FlagExporterInfo{
-		LinkDirs:    flagExporter.linkDirs,
-		LinkObjects: flagExporter.linkObjects,
+		LinkDirs:    android.NewStringsDepSet(android.PREORDER, flagExporter.linkDirs, flagExporter.transitiveLinkDirs),
+		LinkObjects: android.NewDepSet(android.PREORDER, flagExporter.linkObjects, flagExporter.transitiveLinkObjects),
 		LibDeps:     flagExporter.libDeps,
 	})
 }
@@ -537,9 +591,9 @@ func NewFlagExporter() *flagExporter {
 }
 
 type FlagExporterInfo struct {
-	Flags       []string
-	LinkDirs    []string // TODO: this should be android.Paths
-	LinkObjects android.Paths
+	Flags       *android.StringsDepSet
+	LinkDirs    *android.StringsDepSet // TODO: this should be android.Paths
+	LinkObjects *android.DepSet
 	LibDeps     android.Paths
 }
 
@@ -738,6 +792,13 @@ func (mod *Module) IsStubs() bool {
 	return false
 }
 
+func (mod *Module) HasStubsVariants() bool {
+	if lib, ok := mod.compiler.(libraryInterface); ok {
+		return lib.hasStubsVariants()
+	}
+	return false
+}
+
 func (mod *Module) installable(apexInfo android.ApexInfo) bool {
 	if !proptools.BoolDefault(mod.Installable(), mod.EverInstallable()) {
 		return false
@@ -774,6 +835,9 @@ func (mod *Module) Init() android.Module {
 	if mod.clippy != nil {
 		mod.AddProperties(mod.clippy.props()...)
 	}
+	if mod.pgo != nil {
+		mod.AddProperties(mod.pgo.props()...)
+	}
 	if mod.sourceProvider != nil {
 		mod.AddProperties(mod.sourceProvider.SourceProviderProps()...)
 	}
@@ -799,6 +863,7 @@ func newModule(hod android.HostOrDeviceSupported, multilib android.Multilib) *Mo
 	module.afdo = &afdo{}
 	module.coverage = &coverage{}
 	module.clippy = &clippy{}
+	module.pgo = &pgo{}
 	module.sanitize = &sanitize{}
 	return module
 }
@@ -938,9 +1003,16 @@ func (mod *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 	if mod.clippy != nil {
 		flags, deps = mod.clippy.flags(ctx, flags, deps)
 	}
+	if mod.pgo != nil {
+		flags, deps = mod.pgo.flags(ctx, flags, deps)
+	}
 	if mod.sanitize != nil {
 		flags, deps = mod.sanitize.flags(ctx, flags, deps)
 	}
+	if mod.Properties.Experiment != "" {
+		flags.RustFlags = append(flags.RustFlags,
+			ctx.Config().Experiment(mod.Properties.Experiment).Rustflags...)
+	}
 
 	// SourceProvider needs to call GenerateSource() before compiler calls
 	// compile() so it can provide the source. A SourceProvider has
@@ -1023,6 +1095,10 @@ func (mod *Module) deps(ctx DepsContext) Deps {
 		deps = mod.coverage.deps(ctx, deps)
 	}
 
+	if mod.pgo != nil {
+		deps = mod.pgo.deps(ctx, deps)
+	}
+
 	if mod.sanitize != nil {
 		deps = mod.sanitize.deps(ctx, deps)
 	}
@@ -1075,6 +1151,19 @@ var (
 	dataBinDepTag       = dependencyTag{name: "data bin"}
 )
 
+// stubsLibraryDependencyTag is the dependency from the shared variant of a library with stubs to
+// the cc library that builds its stubs variants, see createStubsLibrary.
+type stubsLibraryDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+// The stubs library is not part of the APEX, the library itself is.
+func (stubsLibraryDependencyTag) ExcludeFromApexContents() {}
+
+var _ android.ExcludeFromApexContentsTag = stubsLibraryDependencyTag{}
+
+var stubsLibraryDepTag = stubsLibraryDependencyTag{}
+
 func IsDylibDepTag(depTag blueprint.DependencyTag) bool {
 	tag, ok := depTag.(dependencyTag)
 	return ok && tag == dylibDepTag
@@ -1085,6 +1174,32 @@ func IsRlibDepTag(depTag blueprint.DependencyTag) bool {
 	return ok && tag == rlibDepTag
 }
 
+// walkLibraryDeps calls visit for each transitive rlib and dylib dependency of the module, with the
+// chain of module names from the module to the dependency.  Each dependency is visited once, and
+// visit returns whether to continue into the dependencies of the dependency.
+func walkLibraryDeps(ctx android.BaseModuleContext, visit func(dep *Module, chain []string) bool) {
+	parents := make(map[android.Module]android.Module)
+	ctx.WalkDeps(func(child, parent android.Module) bool {
+		dep, ok := child.(*Module)
+		if !ok {
+			return false
+		}
+		if tag, ok := ctx.OtherModuleDependencyTag(child).(dependencyTag); !ok || !tag.library {
+			return false
+		}
+		if _, visited := parents[child]; visited {
+			return false
+		}
+		parents[child] = parent
+
+		chain := []string{ctx.OtherModuleName(child)}
+		for m := parent; m != ctx.Module(); m = parents[m] {
+			chain = append([]string{ctx.OtherModuleName(m)}, chain...)
+		}
+		return visit(dep, append([]string{ctx.ModuleName()}, chain...))
+	})
+}
+
 type autoDep struct {
 	variation string
 	depTag    dependencyTag
@@ -1105,6 +1220,9 @@ func (mod *Module) begin(ctx BaseModuleContext) {
 	if mod.coverage != nil {
 		mod.coverage.begin(ctx)
 	}
+	if mod.pgo != nil {
+		mod.pgo.begin(ctx)
+	}
 	if mod.sanitize != nil {
 		mod.sanitize.begin(ctx)
 	}
@@ -1139,6 +1257,21 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	directSrcProvidersDeps := []*Module{}
 	directSrcDeps := [](android.SourceFileProducer){}
 
+	// The flags, link dirs and link objects of the dependencies, in the order they are visited. The
+	// direct ones are wrapped in their own DepSets to keep that order when they are flattened.
+	var depFlags []*android.StringsDepSet
+	var linkDirs []*android.StringsDepSet
+	var linkObjects []*android.DepSet
+	addDepFlags := func(flags ...string) {
+		depFlags = append(depFlags, android.NewStringsDepSet(android.PREORDER, flags, nil))
+	}
+	addLinkDirs := func(dirs ...string) {
+		linkDirs = append(linkDirs, android.NewStringsDepSet(android.PREORDER, dirs, nil))
+	}
+	addLinkObjects := func(objects ...android.Path) {
+		linkObjects = append(linkObjects, android.NewDepSet(android.PREORDER, objects, nil))
+	}
+
 	// For the dependency from platform to apex, use the latest stubs
 	mod.apexSdkVersion = android.FutureApiLevel
 	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
@@ -1203,10 +1336,24 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 		if _, exists := skipModuleList[depName]; exists {
 			return
 		}
-		if rustDep, ok := dep.(*Module); ok && !rustDep.CcLibraryInterface() {
+		if depTag == android.ExperimentBaselineTag {
+			return
+		}
+		if rustDep, ok := dep.(*Module); ok && !rustDep.Static() && !rustDep.Shared() {
 			//Handle Rust Modules
 			makeLibName := rustMakeLibName(ctx, mod, rustDep, depName+rustDep.Properties.RustSubName)
 
+			if depTag == dylibDepTag || depTag == rlibDepTag || depTag == procMacroDepTag {
+				// Crates built with different versions of rustc cannot be linked together.
+				version := mod.rustToolchainVersion(ctx)
+				depVersion := rustDep.rustToolchainVersion(ctx)
+				if version != depVersion {
+					ctx.ModuleErrorf("cannot depend on %q built with rustc %s from a crate built with rustc %s",
+						depName, depVersion, version)
+					return
+				}
+			}
+
 			switch depTag {
 			case dylibDepTag:
 				dylib, ok := rustDep.compiler.(libraryInterface)
@@ -1253,9 +1400,15 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			//Append the dependencies exportedDirs, except for proc-macros which target a different arch/OS
 			if depTag != procMacroDepTag {
 				exportedInfo := ctx.OtherModuleProvider(dep, FlagExporterInfoProvider).(FlagExporterInfo)
-				depPaths.linkDirs = append(depPaths.linkDirs, exportedInfo.LinkDirs...)
-				depPaths.depFlags = append(depPaths.depFlags, exportedInfo.Flags...)
-				depPaths.linkObjects = append(depPaths.linkObjects, exportedInfo.LinkObjects...)
+				if exportedInfo.LinkDirs != nil {
+					linkDirs = append(linkDirs, exportedInfo.LinkDirs)
+				}
+				if exportedInfo.Flags != nil {
+					depFlags = append(depFlags, exportedInfo.Flags)
+				}
+				if exportedInfo.LinkObjects != nil {
+					linkObjects = append(linkObjects, exportedInfo.LinkObjects)
+				}
 				depPaths.LibDeps = append(depPaths.LibDeps, exportedInfo.LibDeps...)
 			}
 
@@ -1299,7 +1452,7 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 						// final linkage, pass the args directly to the linker to handle these cases.
 						depPaths.depLinkFlags = append(depPaths.depLinkFlags, []string{"-Wl,--whole-archive", linkObject.Path().String(), "-Wl,--no-whole-archive"}...)
 					} else if libName, ok := libNameFromFilePath(linkObject.Path()); ok {
-						depPaths.depFlags = append(depPaths.depFlags, "-lstatic="+libName)
+						addDepFlags("-lstatic=" + libName)
 						depPaths.WholeStaticLibs = append(depPaths.WholeStaticLibs, linkObject.Path())
 					} else {
 						ctx.ModuleErrorf("'%q' cannot be listed as a whole_static_library in Rust modules unless the output is prefixed by 'lib'", depName, ctx.ModuleName())
@@ -1308,12 +1461,12 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 
 				// Add this to linkObjects to pass the library directly to the linker as well. This propagates
 				// to dependencies to avoid having to redeclare static libraries for dependents of the dylib variant.
-				depPaths.linkObjects = append(depPaths.linkObjects, linkObject.AsPaths()...)
-				depPaths.linkDirs = append(depPaths.linkDirs, linkPath)
+				addLinkObjects(linkObject.AsPaths()...)
+				addLinkDirs(linkPath)
 
 				exportedInfo := ctx.OtherModuleProvider(dep, cc.FlagExporterInfoProvider).(cc.FlagExporterInfo)
-				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs...)
-				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs...)
+				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs.ToList()...)
+				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs.ToList()...)
 				depPaths.depClangFlags = append(depPaths.depClangFlags, exportedInfo.Flags...)
 				depPaths.depGeneratedHeaders = append(depPaths.depGeneratedHeaders, exportedInfo.GeneratedHeaders...)
 				directStaticLibDeps = append(directStaticLibDeps, ccDep)
@@ -1333,10 +1486,10 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				linkObject = android.OptionalPathForPath(sharedLibraryInfo.SharedLibrary)
 				linkPath = linkPathFromFilePath(linkObject.Path())
 
-				depPaths.linkDirs = append(depPaths.linkDirs, linkPath)
-				depPaths.linkObjects = append(depPaths.linkObjects, linkObject.AsPaths()...)
-				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs...)
-				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs...)
+				addLinkDirs(linkPath)
+				addLinkObjects(linkObject.AsPaths()...)
+				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs.ToList()...)
+				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs.ToList()...)
 				depPaths.depClangFlags = append(depPaths.depClangFlags, exportedInfo.Flags...)
 				depPaths.depGeneratedHeaders = append(depPaths.depGeneratedHeaders, exportedInfo.GeneratedHeaders...)
 				directSharedLibDeps = append(directSharedLibDeps, sharedLibraryInfo)
@@ -1348,8 +1501,8 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				exportDep = true
 			case cc.IsHeaderDepTag(depTag):
 				exportedInfo := ctx.OtherModuleProvider(dep, cc.FlagExporterInfoProvider).(cc.FlagExporterInfo)
-				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs...)
-				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs...)
+				depPaths.depIncludePaths = append(depPaths.depIncludePaths, exportedInfo.IncludeDirs.ToList()...)
+				depPaths.depSystemIncludePaths = append(depPaths.depSystemIncludePaths, exportedInfo.SystemIncludeDirs.ToList()...)
 				depPaths.depGeneratedHeaders = append(depPaths.depGeneratedHeaders, exportedInfo.GeneratedHeaders...)
 			case depTag == cc.CrtBeginDepTag:
 				depPaths.CrtBegin = append(depPaths.CrtBegin, linkObject.Path())
@@ -1418,15 +1571,25 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	}
 
 	depPaths.RLibs = append(depPaths.RLibs, rlibDepFiles...)
+	if mod.compiler != nil {
+		// Extern rlibs bypass module resolution, but are otherwise passed to rustc and exported to
+		// dependent modules like the rlibs of dependencies.
+		for _, rlib := range mod.compiler.externRlibs(ctx) {
+			depPaths.RLibs = append(depPaths.RLibs, rlib)
+			if lib, ok := mod.compiler.(exportedFlagsProducer); ok {
+				lib.exportLinkDirs(linkPathFromFilePath(rlib.Path))
+			}
+		}
+	}
 	depPaths.DyLibs = append(depPaths.DyLibs, dylibDepFiles...)
 	depPaths.LibDeps = append(depPaths.LibDeps, libDepFiles...)
 	depPaths.ProcMacros = append(depPaths.ProcMacros, procMacroDepFiles...)
 	depPaths.SrcDeps = append(depPaths.SrcDeps, srcProviderDepFiles...)
 
 	// Dedup exported flags from dependencies
-	depPaths.linkDirs = android.FirstUniqueStrings(depPaths.linkDirs)
-	depPaths.linkObjects = android.FirstUniquePaths(depPaths.linkObjects)
-	depPaths.depFlags = android.FirstUniqueStrings(depPaths.depFlags)
+	depPaths.linkDirs = android.NewStringsDepSet(android.PREORDER, nil, linkDirs)
+	depPaths.linkObjects = android.NewDepSet(android.PREORDER, nil, linkObjects)
+	depPaths.depFlags = android.NewStringsDepSet(android.PREORDER, nil, depFlags)
 	depPaths.depClangFlags = android.FirstUniqueStrings(depPaths.depClangFlags)
 	depPaths.depIncludePaths = android.FirstUniquePaths(depPaths.depIncludePaths)
 	depPaths.depSystemIncludePaths = android.FirstUniquePaths(depPaths.depSystemIncludePaths)
@@ -1474,6 +1637,10 @@ func (mod *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 
 	deps := mod.deps(ctx)
 	var commonDepVariations []blueprint.Variation
+	if mod.usesSecondaryRustToolchain() {
+		commonDepVariations = append(commonDepVariations,
+			blueprint.Variation{Mutator: "rust_toolchain", Variation: secondaryRustToolchainVariation})
+	}
 	var snapshotInfo *cc.SnapshotInfo
 
 	apiImportInfo := cc.GetApiImports(mod, actx)
@@ -1571,6 +1738,15 @@ func (mod *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 		}
 	}
 
+	// The stubs of a library are only built for the core image of the device, see
+	// createStubsLibrary.
+	if lib, ok := mod.compiler.(libraryInterface); ok && lib.shared() && lib.hasStubsVariants() &&
+		ctx.Os() == android.Android && !mod.UseVndk() && !mod.InRamdisk() && !mod.InVendorRamdisk() && !mod.InRecovery() {
+		actx.AddVariationDependencies([]blueprint.Variation{
+			{Mutator: "link", Variation: "shared"},
+		}, stubsLibraryDepTag, stubsLibraryName(mod.BaseModuleName()))
+	}
+
 	for _, lib := range deps.WholeStaticLibs {
 		depTag := cc.StaticDepTag(true)
 		lib = cc.GetReplaceModuleName(lib, cc.GetSnapshot(mod, &snapshotInfo, actx).StaticLibs)
@@ -1616,7 +1792,12 @@ func (mod *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 	actx.AddVariationDependencies(nil, dataBinDepTag, deps.DataBins...)
 
 	// proc_macros are compiler plugins, and so we need the host arch variant as a dependendcy.
-	actx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), procMacroDepTag, deps.ProcMacros...)
+	procMacroVariations := ctx.Config().BuildOSTarget.Variations()
+	if mod.usesSecondaryRustToolchain() {
+		procMacroVariations = append(procMacroVariations,
+			blueprint.Variation{Mutator: "rust_toolchain", Variation: secondaryRustToolchainVariation})
+	}
+	actx.AddFarVariationDependencies(procMacroVariations, procMacroDepTag, deps.ProcMacros...)
 
 	mod.afdo.addDep(ctx, actx)
 }
@@ -1707,6 +1888,12 @@ func (mod *Module) ShouldSupportSdkVersion(ctx android.BaseModuleContext, sdkVer
 func (mod *Module) DepIsInSameApex(ctx android.BaseModuleContext, dep android.Module) bool {
 	depTag := ctx.OtherModuleDependencyTag(dep)
 
+	if rustm, ok := dep.(*Module); ok && rustm.HasStubsVariants() {
+		if cc.IsSharedDepTag(depTag) || cc.IsRuntimeDepTag(depTag) {
+			// dynamic or runtime dep to a library with stubs crosses APEX boundary
+			return false
+		}
+	}
 	if ccm, ok := dep.(*cc.Module); ok {
 		if ccm.HasStubsVariants() {
 			if cc.IsSharedDepTag(depTag) {
@@ -1730,7 +1917,7 @@ func (mod *Module) DepIsInSameApex(ctx android.BaseModuleContext, dep android.Mo
 		}
 	}
 
-	if depTag == procMacroDepTag || depTag == customBindgenDepTag {
+	if depTag == procMacroDepTag || depTag == customBindgenDepTag || depTag == stubsLibraryDepTag {
 		return false
 	}
 
@@ -480,6 +480,62 @@ func TestLibrarySizes(t *testing.T) {
 	m.Output("libwaldo.dylib.so.bloaty.csv")
 }
 
+func TestExperimentVariants(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.PrepareForTestWithExperiments,
+		android.FixtureAddExperiment(android.ExperimentConfig{
+			Name:      "foo",
+			Paths:     []string{"exp"},
+			Rustflags: []string{"-Cexperiment"},
+		}),
+		android.FixtureAddTextFile("exp/Android.bp", `
+			rust_binary {
+				name: "bin",
+				srcs: ["foo.rs"],
+				dylibs: ["libexp", "libother"],
+			}
+
+			rust_library_dylib {
+				name: "libexp",
+				srcs: ["foo.rs"],
+				crate_name: "exp",
+			}
+		`),
+	).RunTestWithBp(t, `
+		rust_library_dylib {
+			name: "libother",
+			srcs: ["foo.rs"],
+			crate_name: "other",
+		}
+	`)
+
+	const variant = "android_arm64_armv8-a"
+	const experimentVariant = variant + "_experiment-foo"
+
+	bin := result.ModuleForTests("bin", variant)
+	android.AssertStringDoesNotContain(t, "normal variant rustcFlags",
+		bin.Rule("rustc").Args["rustcFlags"], "-Cexperiment")
+
+	binExperiment := result.ModuleForTests("bin", experimentVariant)
+	rustc := binExperiment.Rule("rustc")
+	android.AssertStringDoesContain(t, "experiment variant rustcFlags", rustc.Args["rustcFlags"], "-Cexperiment")
+	android.AssertBoolEquals(t, "experiment variant hidden from make", true,
+		binExperiment.Module().(*Module).Properties.HideFromMake)
+
+	// The experiment variant of bin links the experiment variant of libexp, and the normal variant
+	// of libother which is not in the paths of the experiment.
+	libExperiment := result.ModuleForTests("libexp", variant+"_dylib_experiment-foo").Rule("rustc")
+	android.AssertStringDoesContain(t, "libexp experiment variant rustcFlags",
+		libExperiment.Args["rustcFlags"], "-Cexperiment")
+	android.AssertStringDoesContain(t, "experiment variant links libexp", rustc.Args["libFlags"],
+		"--extern exp="+libExperiment.Output.String())
+	libOther := result.ModuleForTests("libother", variant+"_dylib").Rule("rustc")
+	android.AssertStringDoesContain(t, "experiment variant links libother", rustc.Args["libFlags"],
+		"--extern other="+libOther.Output.String())
+}
+
 func assertString(t *testing.T, got, expected string) {
 	t.Helper()
 	if got != expected {
@@ -23,6 +23,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/cc"
+	"android/soong/fuzz"
 	"android/soong/rust/config"
 )
 
@@ -39,6 +40,9 @@ type SanitizeProperties struct {
 		Fuzzer      *bool `android:"arch_variant"`
 		Never       *bool `android:"arch_variant"`
 
+		// shadow-call-stack sanitizer, only available on arm64.
+		Scs *bool `android:"arch_variant"`
+
 		// Sanitizers to run in the diagnostic mode (as opposed to the release mode).
 		// Replaces abort() on error with a human-readable error message.
 		// Address and Thread sanitizers always run in diagnostic mode.
@@ -73,6 +77,20 @@ var fuzzerFlags = []string{
 	"-C lto=no",
 }
 
+// Replace fuzzerFlags and the address sanitizer of fuzz targets when FUZZ_COVERAGE_BUILD is set.
+var fuzzerCoverageFlags = []string{
+	"-C instrument-coverage",
+
+	"--cfg fuzzing",
+
+	// See https://github.com/rust-fuzz/cargo-fuzz/pull/193
+	"-C link-dead-code",
+}
+
+var scsFlags = []string{
+	"-Z sanitizer=shadow-call-stack",
+}
+
 var asanFlags = []string{
 	"-Z sanitizer=address",
 }
@@ -209,9 +227,14 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		s.Memtag_heap = nil
 	}
 
+	// SCS is only implemented on AArch64 devices, the riscv64 backend doesn't support it yet.
+	if ctx.Arch().ArchType != android.Arm64 || ctx.Os() != android.Android {
+		s.Scs = nil
+	}
+
 	// TODO:(b/178369775)
 	// For now sanitizing is only supported on devices
-	if ctx.Os() == android.Android && (Bool(s.Hwaddress) || Bool(s.Address) || Bool(s.Memtag_heap) || Bool(s.Fuzzer)) {
+	if ctx.Os() == android.Android && (Bool(s.Hwaddress) || Bool(s.Address) || Bool(s.Memtag_heap) || Bool(s.Fuzzer) || Bool(s.Scs)) {
 		sanitize.Properties.SanitizerEnabled = true
 	}
 }
@@ -224,7 +247,11 @@ func (sanitize *sanitize) flags(ctx ModuleContext, flags Flags, deps PathDeps) (
 	if !sanitize.Properties.SanitizerEnabled {
 		return flags, deps
 	}
-	if Bool(sanitize.Properties.Sanitize.Fuzzer) {
+	if Bool(sanitize.Properties.Sanitize.Fuzzer) && fuzz.IsCoverageBuild(ctx.Config()) {
+		// Fuzz corpus distillation measures the coverage of the corpus instead of fuzzing it.
+		flags.RustFlags = append(flags.RustFlags, fuzzerCoverageFlags...)
+		flags.LinkFlags = append(flags.LinkFlags, "-fprofile-instr-generate")
+	} else if Bool(sanitize.Properties.Sanitize.Fuzzer) {
 		flags.RustFlags = append(flags.RustFlags, fuzzerFlags...)
 		if ctx.Arch().ArchType == android.Arm64 && ctx.Os().Bionic() {
 			flags.RustFlags = append(flags.RustFlags, hwasanFlags...)
@@ -236,6 +263,9 @@ func (sanitize *sanitize) flags(ctx ModuleContext, flags Flags, deps PathDeps) (
 	} else if Bool(sanitize.Properties.Sanitize.Address) {
 		flags.RustFlags = append(flags.RustFlags, asanFlags...)
 	}
+	if Bool(sanitize.Properties.Sanitize.Scs) {
+		flags.RustFlags = append(flags.RustFlags, scsFlags...)
+	}
 	return flags, deps
 }
 
@@ -273,14 +303,17 @@ func rustSanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 		var depTag blueprint.DependencyTag
 		var deps []string
 
+		// Coverage builds of fuzz targets don't use the sanitizer of the fuzzing engine.
+		fuzzer := mod.IsSanitizerEnabled(cc.Fuzzer) && !fuzz.IsCoverageBuild(mctx.Config())
+
 		if mod.IsSanitizerEnabled(cc.Asan) ||
-			(mod.IsSanitizerEnabled(cc.Fuzzer) && (mctx.Arch().ArchType != android.Arm64 || !mctx.Os().Bionic())) {
+			(fuzzer && (mctx.Arch().ArchType != android.Arm64 || !mctx.Os().Bionic())) {
 			variations = append(variations,
 				blueprint.Variation{Mutator: "link", Variation: "shared"})
 			depTag = cc.SharedDepTag()
 			deps = []string{config.LibclangRuntimeLibrary(mod.toolchain(mctx), "asan")}
 		} else if mod.IsSanitizerEnabled(cc.Hwasan) ||
-			(mod.IsSanitizerEnabled(cc.Fuzzer) && mctx.Arch().ArchType == android.Arm64 && mctx.Os().Bionic()) {
+			(fuzzer && mctx.Arch().ArchType == android.Arm64 && mctx.Os().Bionic()) {
 			// TODO(b/204776996): HWASan for static Rust binaries isn't supported yet.
 			if binary, ok := mod.compiler.(binaryInterface); ok {
 				if binary.staticallyLinked() {
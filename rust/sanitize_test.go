@@ -363,3 +363,83 @@ func TestSanitizeMemtagHeapWithSanitizeDeviceDiag(t *testing.T) {
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_disable", variant), Sync)
 	checkHasMemtagNote(t, ctx.ModuleForTests("unset_test_override_default_sync", variant), Sync)
 }
+
+func TestSanitizeShadowCallStack(t *testing.T) {
+	ctx := testRust(t, `
+		rust_binary_host {
+			name: "foo_host",
+			srcs: ["foo.rs"],
+			sanitize: { scs: true },
+		}
+		rust_binary {
+			name: "foo",
+			srcs: ["foo.rs"],
+			compile_multilib: "both",
+			sanitize: { scs: true },
+		}
+		rust_binary {
+			name: "default",
+			srcs: ["foo.rs"],
+		}
+		rust_binary {
+			name: "never",
+			srcs: ["foo.rs"],
+			sanitize: { scs: true, never: true },
+		}`)
+
+	scsFlag := "-Z sanitizer=shadow-call-stack"
+
+	fooArm64 := ctx.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesContain(t, "arm64 flags", fooArm64.Args["rustcFlags"], scsFlag)
+
+	fooArm := ctx.ModuleForTests("foo", "android_arm_armv7-a-neon").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "arm flags", fooArm.Args["rustcFlags"], scsFlag)
+
+	fooHost := ctx.ModuleForTests("foo_host", "linux_glibc_x86_64").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "host flags", fooHost.Args["rustcFlags"], scsFlag)
+
+	// Like in cc, shadow call stack has to be enabled explicitly.
+	defaultScs := ctx.ModuleForTests("default", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "default flags", defaultScs.Args["rustcFlags"], scsFlag)
+
+	never := ctx.ModuleForTests("never", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "never flags", never.Args["rustcFlags"], scsFlag)
+}
+
+func TestStackProtector(t *testing.T) {
+	bp := `
+		rust_binary_host {
+			name: "foo_host",
+			srcs: ["foo.rs"],
+		}
+		rust_binary {
+			name: "foo",
+			srcs: ["foo.rs"],
+			compile_multilib: "both",
+		}`
+
+	stackProtectorFlag := "-Z stack-protector=strong"
+
+	ctx := testRust(t, bp)
+
+	fooArm64 := ctx.ModuleForTests("foo", "android_arm64_armv8-a").Rule("rustc")
+	android.AssertStringDoesContain(t, "arm64 flags", fooArm64.Args["rustcFlags"], stackProtectorFlag)
+
+	fooArm := ctx.ModuleForTests("foo", "android_arm_armv7-a-neon").Rule("rustc")
+	android.AssertStringDoesContain(t, "arm flags", fooArm.Args["rustcFlags"], stackProtectorFlag)
+
+	fooHost := ctx.ModuleForTests("foo_host", "linux_glibc_x86_64").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "host flags", fooHost.Args["rustcFlags"], stackProtectorFlag)
+
+	// cc disables the stack protector for armv9-a, which uses branch protection instead.
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureModifyConfig(func(config android.Config) {
+			config.Targets[android.Android][0].Arch.ArchVariant = "armv9-a"
+		}),
+	).RunTestWithBp(t, bp)
+
+	fooArmv9 := result.ModuleForTests("foo", "android_arm64_armv9-a").Rule("rustc")
+	android.AssertStringDoesNotContain(t, "armv9-a flags", fooArmv9.Args["rustcFlags"], stackProtectorFlag)
+}
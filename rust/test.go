@@ -16,6 +16,8 @@ package rust
 
 import (
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/google/blueprint/proptools"
 
@@ -61,11 +63,43 @@ type TestProperties struct {
 	Test_harness *bool
 
 	// Test options.
-	Test_options android.CommonTestOptions
+	Test_options TestOptions
 
 	// Add RootTargetPreparer to auto generated test config. This guarantees the test to run
 	// with root permission.
 	Require_root *bool
+
+	// list of crate root files that are each built into a separate test binary.  The crate in
+	// srcs is compiled once into an rlib named by crate_name, which every one of these tests can
+	// use through an extern crate declaration instead of recompiling it.
+	Test_per_src_srcs []string `android:"path,arch_variant"`
+
+	// Set by the test_per_src mutator to the name of the test that a variation of a
+	// test_per_src_srcs test builds, to testPerSrcCommonVariation for the variation that builds
+	// the shared rlib and to "" for the variation that depends on all the tests.
+	TestPerSrcName *string `blueprint:"mutated"`
+}
+
+// Test option struct.
+type TestOptions struct {
+	android.CommonTestOptions
+
+	// Add RootTargetPreparer to auto generated test config. This guarantees the test to run
+	// with root permission.
+	Require_root *bool
+
+	// Add ShippingApiLevelModuleController to auto generated test config. If the device properties
+	// for the shipping api level is less than the min_shipping_api_level, skip this module.
+	Min_shipping_api_level *int64
+
+	// Add MinApiLevelModuleController with ro.build.version.sdk property. If the api level of the
+	// device is less than the test_min_api_level, skip this module.
+	Test_min_api_level *int64
+
+	// Add MinApiLevelModuleController with ro.vndk.version property. If ro.vndk.version has an
+	// integer value and the value is less than the min_vndk_version, skip this module.
+	// It is not allowed to define min_shipping_api_level at the same time with this property.
+	Min_vndk_version *int64
 }
 
 // A test module is a binary module with extra --test compiler flag
@@ -91,6 +125,123 @@ func (test *testDecorator) testHarness() bool {
 	return BoolDefault(test.Properties.Test_harness, true)
 }
 
+// testPerSrcCommonVariation is the name of the variation that compiles the crate shared by the
+// test_per_src_srcs tests of a module.
+const testPerSrcCommonVariation = "test_per_src_common"
+
+func (test *testDecorator) testPerSrc() bool {
+	return test.Properties.TestPerSrcName != nil
+}
+
+func (test *testDecorator) isAllTestsVariation() bool {
+	return test.testPerSrc() && *test.Properties.TestPerSrcName == ""
+}
+
+func (test *testDecorator) isCommonSrcsVariation() bool {
+	return test.testPerSrc() && *test.Properties.TestPerSrcName == testPerSrcCommonVariation
+}
+
+// TestPerSrcMutator splits a rust_test with test_per_src_srcs into one variation per test crate
+// root, a variation that compiles srcs once into the rlib that the tests use, and an "all tests"
+// variation named "" that depends on every test, like the test_per_src mutator of cc.
+func TestPerSrcMutator(mctx android.BottomUpMutatorContext) {
+	mod, ok := mctx.Module().(*Module)
+	if !ok {
+		return
+	}
+	test, ok := mod.compiler.(*testDecorator)
+	if !ok || len(test.Properties.Test_per_src_srcs) == 0 {
+		return
+	}
+
+	if test.crateName() == "" {
+		mctx.PropertyErrorf("test_per_src_srcs", "requires crate_name to name the crate shared by the tests")
+		return
+	}
+	if duplicate, found := android.CheckDuplicate(test.Properties.Test_per_src_srcs); found {
+		mctx.PropertyErrorf("test_per_src_srcs", "found a duplicate entry %q", duplicate)
+		return
+	}
+	testNames := make([]string, len(test.Properties.Test_per_src_srcs))
+	for i, src := range test.Properties.Test_per_src_srcs {
+		testNames[i] = strings.TrimSuffix(filepath.Base(src), filepath.Ext(src))
+	}
+	if duplicate, found := android.CheckDuplicate(append(testNames, testPerSrcCommonVariation)); found {
+		mctx.PropertyErrorf("test_per_src_srcs", "found a duplicate test name %q", duplicate)
+		return
+	}
+
+	numTests := len(testNames)
+	variations := append(testNames, testPerSrcCommonVariation, "")
+	tests := mctx.CreateLocalVariations(variations...)
+	for i, variation := range variations {
+		m := tests[i].(*Module)
+		m.compiler.(*testDecorator).Properties.TestPerSrcName = proptools.StringPtr(variation)
+		if i >= numTests {
+			// Neither the common variation nor the "all tests" variation is a test.
+			m.Properties.PreventInstall = true
+			m.Properties.HideFromMake = true
+		}
+	}
+
+	common, allTests := tests[numTests], tests[numTests+1]
+	for i, src := range test.Properties.Test_per_src_srcs {
+		perSrcTest := tests[i].(*Module).compiler.(*testDecorator)
+		perSrcTest.baseCompiler.Properties.Srcs = []string{src}
+		perSrcTest.baseCompiler.Properties.Stem = proptools.StringPtr(testNames[i])
+		mctx.AddInterVariantDependency(testPerSrcDepTag, tests[i], common)
+		mctx.AddInterVariantDependency(testPerSrcDepTag, allTests, tests[i])
+	}
+	mctx.AliasVariation("")
+}
+
+// Disabled returns true for the "all tests" variation of a test_per_src_srcs test, which does not
+// compile anything.
+func (test *testDecorator) Disabled() bool {
+	return test.isAllTestsVariation()
+}
+
+func (test *testDecorator) crateName() string {
+	if test.testPerSrc() && !test.isCommonSrcsVariation() {
+		// The tests must not reuse the crate name of the rlib that they link.
+		return strings.ReplaceAll(*test.Properties.TestPerSrcName, "-", "_")
+	}
+	return test.binaryDecorator.crateName()
+}
+
+// externRlibs adds the rlib compiled from the srcs of a test_per_src_srcs test to the rlibs of
+// each of its tests.
+func (test *testDecorator) externRlibs(ctx android.ModuleContext) RustLibraries {
+	rlibs := test.binaryDecorator.externRlibs(ctx)
+	if test.testPerSrc() && !test.isCommonSrcsVariation() {
+		ctx.VisitDirectDepsWithTag(testPerSrcDepTag, func(dep android.Module) {
+			if common, ok := dep.(*Module); ok && common.OutputFile().Valid() {
+				rlibs = append(rlibs, RustLibrary{Path: common.UnstrippedOutputFile(), CrateName: common.CrateName()})
+			}
+		})
+	}
+	return rlibs
+}
+
+func (test *testDecorator) compile(ctx ModuleContext, flags Flags, deps PathDeps) buildOutput {
+	if !test.isCommonSrcsVariation() {
+		return test.binaryDecorator.compile(ctx, flags, deps)
+	}
+
+	fileName := "lib" + test.crateName() + ctx.toolchain().RlibSuffix()
+	srcPath, _ := srcPathFromModuleSrcs(ctx, test.baseCompiler.Properties.Srcs)
+	outputFile := android.PathForModuleOut(ctx, fileName)
+	test.baseCompiler.unstrippedOutputFile = outputFile
+
+	flags.RustFlags = append(flags.RustFlags, deps.depFlags.ToList()...)
+	flags.RustFlags = append(flags.RustFlags, "-C metadata="+ctx.ModuleName())
+
+	return buildOutput{
+		outputFile: outputFile,
+		kytheFile:  TransformSrctoRlib(ctx, srcPath, deps, flags, outputFile).kytheFile,
+	}
+}
+
 func NewRustTest(hod android.HostOrDeviceSupported) (*Module, *testDecorator) {
 	// Build both 32 and 64 targets for device tests.
 	// Cannot build both for host tests yet if the test depends on
@@ -116,21 +267,25 @@ func (test *testDecorator) compilerProps() []interface{} {
 }
 
 func (test *testDecorator) install(ctx ModuleContext) {
+	if test.isCommonSrcsVariation() {
+		return
+	}
+
 	testInstallBase := "/data/local/tests/unrestricted"
 	if ctx.RustModule().InVendor() || ctx.RustModule().UseVndk() {
 		testInstallBase = "/data/local/tests/vendor"
 	}
 
-	var configs []tradefed.Config
-	if Bool(test.Properties.Require_root) {
-		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
-	} else {
-		var options []tradefed.Option
-		options = append(options, tradefed.Option{Name: "force-root", Value: "false"})
-		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", options})
+	configs := test.tradefedConfigs(ctx)
+
+	// Each test_per_src_srcs test gets its own test config that runs its binary.
+	var outputFileName string
+	if test.testPerSrc() {
+		outputFileName = ctx.RustModule().OutputFile().Path().Base()
 	}
 
 	test.testConfig = tradefed.AutoGenTestConfig(ctx, tradefed.AutoGenTestConfigOptions{
+		OutputFileName:         outputFileName,
 		TestConfigProp:         test.Properties.Test_config,
 		TestConfigTemplateProp: test.Properties.Test_config_template,
 		TestSuites:             test.Properties.Test_suites,
@@ -147,7 +302,8 @@ func (test *testDecorator) install(ctx ModuleContext) {
 		depName := ctx.OtherModuleName(dep)
 		linkableDep, ok := dep.(cc.LinkableInterface)
 		if !ok {
-			ctx.ModuleErrorf("data_lib %q is not a linkable module", depName)
+			ctx.PropertyErrorf("data_libs", "%q is not a linkable module", depName)
+			return
 		}
 		if linkableDep.OutputFile().Valid() {
 			// Copy the output in "lib[64]" so that it's compatible with
@@ -166,7 +322,8 @@ func (test *testDecorator) install(ctx ModuleContext) {
 		depName := ctx.OtherModuleName(dep)
 		linkableDep, ok := dep.(cc.LinkableInterface)
 		if !ok {
-			ctx.ModuleErrorf("data_bin %q is not a linkable module", depName)
+			ctx.PropertyErrorf("data_bins", "%q is not a linkable module", depName)
+			return
 		}
 		if linkableDep.OutputFile().Valid() {
 			test.data = append(test.data,
@@ -192,8 +349,46 @@ func (test *testDecorator) install(ctx ModuleContext) {
 	test.binaryDecorator.install(ctx)
 }
 
+// tradefedConfigs returns the configs that the test options add to the auto generated test config.
+func (test *testDecorator) tradefedConfigs(ctx ModuleContext) []tradefed.Config {
+	var configs []tradefed.Config
+	testOptions := test.Properties.Test_options
+	if Bool(test.Properties.Require_root) || Bool(testOptions.Require_root) {
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", nil})
+	} else {
+		var options []tradefed.Option
+		options = append(options, tradefed.Option{Name: "force-root", Value: "false"})
+		configs = append(configs, tradefed.Object{"target_preparer", "com.android.tradefed.targetprep.RootTargetPreparer", options})
+	}
+	if testOptions.Min_shipping_api_level != nil {
+		if testOptions.Min_vndk_version != nil {
+			ctx.PropertyErrorf("test_options.min_shipping_api_level", "must not be set at the same time as 'min_vndk_version'.")
+		}
+		var controllerOptions []tradefed.Option
+		controllerOptions = append(controllerOptions, tradefed.Option{Name: "min-api-level", Value: strconv.FormatInt(*testOptions.Min_shipping_api_level, 10)})
+		configs = append(configs, tradefed.Object{"module_controller", "com.android.tradefed.testtype.suite.module.ShippingApiLevelModuleController", controllerOptions})
+	}
+	if testOptions.Test_min_api_level != nil {
+		var controllerOptions []tradefed.Option
+		controllerOptions = append(controllerOptions, tradefed.Option{Name: "min-api-level", Value: strconv.FormatInt(*testOptions.Test_min_api_level, 10)})
+		controllerOptions = append(controllerOptions, tradefed.Option{Name: "api-level-prop", Value: "ro.build.version.sdk"})
+		configs = append(configs, tradefed.Object{"module_controller", "com.android.tradefed.testtype.suite.module.MinApiLevelModuleController", controllerOptions})
+	}
+	if testOptions.Min_vndk_version != nil {
+		var controllerOptions []tradefed.Option
+		controllerOptions = append(controllerOptions, tradefed.Option{Name: "min-api-level", Value: strconv.FormatInt(*testOptions.Min_vndk_version, 10)})
+		controllerOptions = append(controllerOptions, tradefed.Option{Name: "api-level-prop", Value: "ro.vndk.version"})
+		configs = append(configs, tradefed.Object{"module_controller", "com.android.tradefed.testtype.suite.module.MinApiLevelModuleController", controllerOptions})
+	}
+	return configs
+}
+
 func (test *testDecorator) compilerFlags(ctx ModuleContext, flags Flags) Flags {
 	flags = test.binaryDecorator.compilerFlags(ctx, flags)
+	if test.isCommonSrcsVariation() {
+		// The shared crate is a plain rlib, the tests that use it are built with the harness.
+		return flags
+	}
 	if test.testHarness() {
 		flags.RustFlags = append(flags.RustFlags, "--test")
 	}
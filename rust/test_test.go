@@ -75,6 +75,125 @@ func TestRustTestLinkage(t *testing.T) {
 	}
 }
 
+func TestRustTestOptions(t *testing.T) {
+	ctx := testRust(t, `
+		rust_test {
+			name: "my_test",
+			srcs: ["foo.rs"],
+			test_options: {
+				require_root: true,
+				min_shipping_api_level: 30,
+				test_min_api_level: 29,
+			},
+		}
+
+		rust_test {
+			name: "my_vndk_test",
+			srcs: ["foo.rs"],
+			test_options: {
+				min_vndk_version: 31,
+			},
+		}`)
+
+	autogen := ctx.ModuleForTests("my_test", "android_arm64_armv8-a").Rule("autogenTestConfig")
+	extraConfigs := autogen.Args["extraConfigs"]
+	android.AssertStringDoesContain(t, "root preparer", extraConfigs,
+		`<target_preparer class="com.android.tradefed.targetprep.RootTargetPreparer">\n`)
+	android.AssertStringDoesNotContain(t, "force-root", extraConfigs, `name="force-root"`)
+	android.AssertStringDoesContain(t, "shipping api level controller", extraConfigs,
+		`<object type="module_controller" class="com.android.tradefed.testtype.suite.module.ShippingApiLevelModuleController">`)
+	android.AssertStringDoesContain(t, "min shipping api level", extraConfigs, `<option name="min-api-level" value="30" />`)
+	android.AssertStringDoesContain(t, "min api level controller", extraConfigs,
+		`<object type="module_controller" class="com.android.tradefed.testtype.suite.module.MinApiLevelModuleController">`)
+	android.AssertStringDoesContain(t, "test min api level", extraConfigs, `<option name="min-api-level" value="29" />`)
+	android.AssertStringDoesContain(t, "api level prop", extraConfigs, `<option name="api-level-prop" value="ro.build.version.sdk" />`)
+
+	autogen = ctx.ModuleForTests("my_vndk_test", "android_arm64_armv8-a").Rule("autogenTestConfig")
+	extraConfigs = autogen.Args["extraConfigs"]
+	android.AssertStringDoesContain(t, "no root", extraConfigs, `<option name="force-root" value="false" />`)
+	android.AssertStringDoesNotContain(t, "no shipping api level controller", extraConfigs, "ShippingApiLevelModuleController")
+	android.AssertStringDoesContain(t, "min vndk version", extraConfigs, `<option name="min-api-level" value="31" />`)
+	android.AssertStringDoesContain(t, "vndk version prop", extraConfigs, `<option name="api-level-prop" value="ro.vndk.version" />`)
+}
+
+func TestRustTestOptionsErrors(t *testing.T) {
+	testRustError(t, `test_options.min_shipping_api_level: must not be set at the same time as 'min_vndk_version'`, `
+		rust_test {
+			name: "my_test",
+			srcs: ["foo.rs"],
+			test_options: {
+				min_shipping_api_level: 30,
+				min_vndk_version: 30,
+			},
+		}`)
+}
+
+func TestRustTestPerSrc(t *testing.T) {
+	skipTestIfOsNotSupported(t)
+	ctx := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.MockFS{
+			"common.rs":   nil,
+			"foo_test.rs": nil,
+			"bar_test.rs": nil,
+		}.AddToFixture(),
+	).RunTestWithBp(t, `
+		rust_test {
+			name: "my_test",
+			crate_name: "my_test_common",
+			srcs: ["common.rs"],
+			test_per_src_srcs: ["foo_test.rs", "bar_test.rs"],
+			test_suites: ["general-tests"],
+		}`).TestContext
+
+	common := ctx.ModuleForTests("my_test", "android_arm64_armv8-a_test_per_src_common")
+	commonRustc := common.Rule("rustc")
+	android.AssertPathsRelativeToTopEquals(t, "common inputs", []string{"common.rs"}, commonRustc.Inputs)
+	android.AssertStringDoesContain(t, "common crate type", commonRustc.Args["rustcFlags"], "--crate-type=rlib")
+	android.AssertStringDoesNotContain(t, "common test harness", commonRustc.Args["rustcFlags"], "--test")
+	android.AssertBoolEquals(t, "common hidden from make", true, common.Module().(*Module).Properties.HideFromMake)
+	commonRlib := common.Output("libmy_test_common.rlib").Output
+
+	for _, name := range []string{"foo_test", "bar_test"} {
+		test := ctx.ModuleForTests("my_test", "android_arm64_armv8-a_"+name)
+		rustc := test.Rule("rustc")
+		android.AssertPathsRelativeToTopEquals(t, name+" inputs", []string{name + ".rs"}, rustc.Inputs)
+		android.AssertStringDoesContain(t, name+" crate name", rustc.Args["rustcFlags"], "--crate-name="+name)
+		android.AssertStringDoesContain(t, name+" test harness", rustc.Args["rustcFlags"], "--test")
+		android.AssertStringDoesContain(t, name+" common crate", rustc.Args["libFlags"],
+			"--extern my_test_common="+commonRlib.String())
+		android.AssertStringEquals(t, name+" output", name, rustc.Output.Base())
+
+		autogen := test.Rule("autogenTestConfig")
+		android.AssertStringEquals(t, name+" test config binary", name, autogen.Args["outputFileName"])
+
+		entries := android.AndroidMkEntriesForTest(t, ctx, test.Module())[0]
+		android.AssertStringEquals(t, name+" make suffix", "_"+name, entries.SubName)
+		android.AssertStringEquals(t, name+" test suites", "general-tests", entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"][0])
+	}
+
+	if ctx.ModuleForTests("my_test", "android_arm64_armv8-a").MaybeRule("rustc").Rule != nil {
+		t.Errorf("expected the all tests variation of my_test not to compile anything")
+	}
+}
+
+func TestRustTestPerSrcErrors(t *testing.T) {
+	testRustError(t, `test_per_src_srcs: requires crate_name to name the crate shared by the tests`, `
+		rust_test {
+			name: "my_test",
+			srcs: ["common.rs"],
+			test_per_src_srcs: ["foo_test.rs"],
+		}`)
+	testRustError(t, `test_per_src_srcs: found a duplicate test name "foo_test"`, `
+		rust_test {
+			name: "my_test",
+			crate_name: "my_test_common",
+			srcs: ["common.rs"],
+			test_per_src_srcs: ["foo_test.rs", "tests/foo_test.rs"],
+		}`)
+}
+
 func TestDataLibs(t *testing.T) {
 	bp := `
 		cc_library {
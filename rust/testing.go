@@ -192,12 +192,19 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 		// rust mutators
 		ctx.BottomUp("rust_libraries", LibraryMutator).Parallel()
 		ctx.BottomUp("rust_stdlinkage", LibstdMutator).Parallel()
+		ctx.BottomUp("rust_toolchain_deps", RustToolchainDepsMutator).Parallel()
+		ctx.BottomUp("rust_toolchain", RustToolchainMutator).Parallel()
+		ctx.BottomUp("rust_test_per_src", TestPerSrcMutator).Parallel()
 		ctx.BottomUp("rust_begin", BeginMutator).Parallel()
 	})
+	ctx.RegisterSingletonType("rust_crate_version_conflicts", crateVersionConflictsSingletonFactory)
 	ctx.RegisterSingletonType("rust_project_generator", rustProjectGeneratorSingleton)
 	ctx.RegisterSingletonType("kythe_rust_extract", kytheExtractRustFactory)
 	ctx.PostDepsMutators(func(ctx android.RegisterMutatorsContext) {
 		ctx.BottomUp("rust_sanitizers", rustSanitizerRuntimeMutator).Parallel()
+		ctx.BottomUp("rust_denied_crates", deniedCratesMutator).Parallel()
+		ctx.TopDown("rust_afdo_deps", afdoDepsMutator)
+		ctx.BottomUp("rust_afdo", afdoMutator).Parallel()
 	})
 	registerRustSnapshotModules(ctx)
 }
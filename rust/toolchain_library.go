@@ -18,6 +18,7 @@ package rust
 
 import (
 	"path"
+	"sync"
 
 	"android/soong/android"
 	"android/soong/rust/config"
@@ -26,6 +27,13 @@ import (
 // This module is used to compile the rust toolchain libraries
 // When RUST_PREBUILTS_VERSION is set, the library will generated
 // from the given Rust version.
+//
+// When a secondary rust toolchain is configured with RustSecondaryVersion, the modules in the
+// RustSecondaryVersionPaths are built with it instead, against the sysroot crates of that
+// version, which are rust_toolchain_library modules with secondary_toolchain set and the name
+// of the default sysroot crate followed by config.SecondaryStdlibSuffix.  The crates that they
+// depend on, directly or transitively, are built again with it in a variant created by
+// RustToolchainMutator.
 func init() {
 	android.RegisterModuleType("rust_toolchain_library",
 		rustToolchainLibraryFactory)
@@ -38,6 +46,10 @@ func init() {
 type toolchainLibraryProperties struct {
 	// path to the toolchain source, relative to the top of the toolchain source
 	Toolchain_src *string `android:"arch_variant"`
+
+	// if set to true, the library is built from the source of the secondary rust toolchain, with that
+	// toolchain.  The library is disabled if there is no secondary toolchain.
+	Secondary_toolchain *bool
 }
 
 type toolchainLibraryDecorator struct {
@@ -82,7 +94,15 @@ func initToolchainLibrary(module *Module, library *libraryDecorator) android.Mod
 
 func rustSetToolchainSource(ctx android.LoadHookContext) {
 	if toolchainLib, ok := ctx.Module().(*Module).compiler.(*toolchainLibraryDecorator); ok {
-		prefix := "linux-x86/" + GetRustPrebuiltVersion(ctx)
+		version := GetRustPrebuiltVersion(ctx)
+		if Bool(toolchainLib.Properties.Secondary_toolchain) {
+			version = ctx.Config().RustSecondaryVersion()
+			if version == "" {
+				ctx.Module().Disable()
+				return
+			}
+		}
+		prefix := "linux-x86/" + version
 		newSrcs := []string{path.Join(prefix, android.String(toolchainLib.Properties.Toolchain_src))}
 
 		type props struct {
@@ -101,3 +121,161 @@ func rustSetToolchainSource(ctx android.LoadHookContext) {
 func GetRustPrebuiltVersion(ctx android.LoadHookContext) string {
 	return ctx.AConfig().GetenvWithDefault("RUST_PREBUILTS_VERSION", config.RustDefaultVersion)
 }
+
+// secondaryRustToolchainVariation is the variation of the rust modules that are built with the
+// secondary rust toolchain.
+const secondaryRustToolchainVariation = "rust_secondary"
+
+var secondaryRustToolchainGraphKey = android.NewOnceKey("secondaryRustToolchainGraph")
+
+// secondaryRustToolchainGraph records the rust crates that each rust module depends on, by name, to
+// find the crates that the modules built with the secondary rust toolchain depend on before the
+// dependencies are added.
+type secondaryRustToolchainGraph struct {
+	lock sync.Mutex
+	// deps maps the name of each crate that can be rebuilt with the secondary toolchain to the
+	// names of the crates it depends on.
+	deps map[string][]string
+	// roots are the names of the crates that are only built with the secondary toolchain.
+	roots map[string][]string
+
+	once   sync.Once
+	needed map[string]bool
+}
+
+func getSecondaryRustToolchainGraph(config android.Config) *secondaryRustToolchainGraph {
+	return config.Once(secondaryRustToolchainGraphKey, func() interface{} {
+		return &secondaryRustToolchainGraph{
+			deps:  make(map[string][]string),
+			roots: make(map[string][]string),
+		}
+	}).(*secondaryRustToolchainGraph)
+}
+
+// neededBySecondary returns true if a crate that is built with the secondary toolchain depends on
+// the crate, directly or transitively.  It must only be called once all the crates are recorded.
+func (g *secondaryRustToolchainGraph) neededBySecondary(name string) bool {
+	g.once.Do(func() {
+		g.needed = make(map[string]bool)
+		var queue []string
+		for _, deps := range g.roots {
+			queue = append(queue, deps...)
+		}
+		for len(queue) > 0 {
+			dep := queue[0]
+			queue = queue[1:]
+			// Only the crates that can be rebuilt are recorded in deps.
+			if deps, ok := g.deps[dep]; ok && !g.needed[dep] {
+				g.needed[dep] = true
+				queue = append(queue, deps...)
+			}
+		}
+	})
+	return g.needed[name]
+}
+
+// RustToolchainDepsMutator records the rust crates that each rust module depends on for
+// RustToolchainMutator.
+func RustToolchainDepsMutator(mctx android.BottomUpMutatorContext) {
+	if mctx.Config().RustSecondaryVersion() == "" {
+		return
+	}
+	m, ok := mctx.Module().(*Module)
+	if !ok || m.compiler == nil {
+		return
+	}
+
+	deps := m.deps(&depsContext{BottomUpMutatorContext: mctx})
+	var crates []string
+	crates = append(crates, deps.Rlibs...)
+	crates = append(crates, deps.Dylibs...)
+	crates = append(crates, deps.Rustlibs...)
+	crates = append(crates, deps.ProcMacros...)
+
+	graph := getSecondaryRustToolchainGraph(mctx.Config())
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+	name := mctx.ModuleName()
+	switch {
+	case m.secondaryRustToolchainOnly(mctx):
+		graph.roots[name] = android.FirstUniqueStrings(append(graph.roots[name], crates...))
+	case m.rebuildableWithSecondaryRustToolchain():
+		graph.deps[name] = android.FirstUniqueStrings(append(graph.deps[name], crates...))
+	}
+}
+
+// RustToolchainMutator creates the variants of the rust modules that are built with the secondary
+// rust toolchain.  The modules in the RustSecondaryVersionPaths and the secondary sysroot crates
+// only have that variant.  The other crates that can be compiled from source and that they depend
+// on get a second variant for the modules built with the secondary toolchain to depend on, which
+// is neither installed nor exported to Make.  The other crates only have the default variant,
+// which is aliased so that depending on prebuilts and the default sysroot crates from the
+// secondary toolchain is reported by depsToPaths instead of failing on a missing variant.
+func RustToolchainMutator(mctx android.BottomUpMutatorContext) {
+	if mctx.Config().RustSecondaryVersion() == "" {
+		return
+	}
+	m, ok := mctx.Module().(*Module)
+	if !ok || m.compiler == nil {
+		return
+	}
+
+	switch {
+	case m.secondaryRustToolchainOnly(mctx):
+		modules := mctx.CreateLocalVariations(secondaryRustToolchainVariation)
+		modules[0].(*Module).Properties.SecondaryRustToolchain = true
+		// Crates built with the default toolchain that depend on this one are reported by
+		// depsToPaths.
+		mctx.AliasVariation(secondaryRustToolchainVariation)
+	case m.rebuildableWithSecondaryRustToolchain() &&
+		getSecondaryRustToolchainGraph(mctx.Config()).neededBySecondary(mctx.ModuleName()):
+		modules := mctx.CreateLocalVariations("", secondaryRustToolchainVariation)
+		secondary := modules[1].(*Module)
+		secondary.Properties.SecondaryRustToolchain = true
+		secondary.Properties.PreventInstall = true
+		secondary.Properties.HideFromMake = true
+	default:
+		mctx.CreateLocalVariations("")
+		mctx.CreateAliasVariation(secondaryRustToolchainVariation, "")
+	}
+}
+
+// secondaryRustToolchainOnly returns true if the module is only built with the secondary rust
+// toolchain, because it is a secondary sysroot crate or it is in the RustSecondaryVersionPaths.
+func (mod *Module) secondaryRustToolchainOnly(ctx android.BaseModuleContext) bool {
+	if toolchainLib, ok := mod.compiler.(*toolchainLibraryDecorator); ok {
+		return Bool(toolchainLib.Properties.Secondary_toolchain)
+	}
+	return ctx.Config().RustSecondaryVersionEnabledForPath(ctx.ModuleDir())
+}
+
+// rebuildableWithSecondaryRustToolchain returns true if the module is compiled from source that
+// does not depend on the version of the toolchain, unlike prebuilts, the default sysroot crates and
+// the generated sources of source providers.
+func (mod *Module) rebuildableWithSecondaryRustToolchain() bool {
+	if mod.IsPrebuilt() {
+		return false
+	}
+	if _, ok := mod.compiler.(*toolchainLibraryDecorator); ok {
+		return false
+	}
+	if library, ok := mod.compiler.(libraryInterface); ok && library.source() {
+		return false
+	}
+	return true
+}
+
+// usesSecondaryRustToolchain returns true if the variant of the module is built with the secondary
+// rust toolchain.
+func (mod *Module) usesSecondaryRustToolchain() bool {
+	return mod.Properties.SecondaryRustToolchain
+}
+
+// rustToolchainVersion returns the version of the rust toolchain that the variant of the module is
+// built with.
+func (mod *Module) rustToolchainVersion(ctx android.PathContext) string {
+	if mod.usesSecondaryRustToolchain() {
+		return ctx.Config().RustSecondaryVersion()
+	}
+	return config.GetRustVersion(ctx)
+}
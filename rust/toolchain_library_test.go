@@ -0,0 +1,167 @@
+// Copyright 2023 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/rust/config"
+)
+
+var prepareForSecondaryRustToolchainTest = android.GroupFixturePreparers(
+	prepareForRustTest,
+	rustMockedFiles.AddToFixture(),
+	android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+		variables.RustSecondaryVersion = proptools.StringPtr("1.67.0")
+		variables.RustSecondaryVersionPaths = []string{"secondary"}
+	}),
+	android.FixtureAddTextFile("secondary/Android.bp", `
+		rust_prebuilt_library {
+			name: "libstd.rust_secondary",
+			crate_name: "std",
+			rlib: {
+				srcs: ["libstd.rlib"],
+			},
+			dylib: {
+				srcs: ["libstd.so"],
+			},
+			host_supported: true,
+			sysroot: true,
+		}
+
+		rust_library {
+			name: "libsecondary",
+			srcs: ["lib.rs"],
+			crate_name: "secondary",
+			rustlibs: ["libbar"],
+		}
+	`),
+	android.FixtureAddTextFile("bar/Android.bp", `
+		rust_library {
+			name: "libbar",
+			srcs: ["lib.rs"],
+			crate_name: "bar",
+			rustlibs: ["libbaz"],
+		}
+
+		rust_library {
+			name: "libbaz",
+			srcs: ["lib.rs"],
+			crate_name: "baz",
+		}
+	`),
+	android.FixtureMergeMockFs(android.MockFS{
+		"secondary/lib.rs":      nil,
+		"secondary/libstd.rlib": nil,
+		"secondary/libstd.so":   nil,
+		"bar/lib.rs":            nil,
+	}),
+)
+
+// Test that the modules in the paths of the secondary rust toolchain are built with it, against its
+// sysroot crates.
+func TestSecondaryRustToolchain(t *testing.T) {
+	result := prepareForSecondaryRustToolchainTest.RunTestWithBp(t, `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+		}
+	`)
+
+	const secondaryVariant = "android_arm64_armv8-a_dylib_" + secondaryRustToolchainVariation
+
+	secondary := result.ModuleForTests("libsecondary", secondaryVariant)
+	if !android.InList("libstd.rust_secondary", secondary.Module().(*Module).Properties.AndroidMkDylibs) {
+		t.Errorf("libsecondary does not link the libstd of the secondary toolchain")
+	}
+	rustc := secondary.Rule("rustcSecondary")
+	android.AssertStringDoesContain(t, "secondary rustc", rustc.RuleParams.Command, "${config.RustSecondaryBin}/rustc")
+	android.AssertStringDoesContain(t, "secondary envVars", rustc.Args["envVars"], "ANDROID_RUST_VERSION=1.67.0")
+
+	foo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_dylib")
+	if !android.InList("libstd", foo.Module().(*Module).Properties.AndroidMkDylibs) {
+		t.Errorf("libfoo does not link the libstd of the default toolchain")
+	}
+	rustc = foo.Rule("rustc")
+	android.AssertStringDoesContain(t, "default envVars", rustc.Args["envVars"], "ANDROID_RUST_VERSION="+config.RustDefaultVersion)
+}
+
+// Test that the crates that the modules built with the secondary rust toolchain depend on, directly
+// or transitively, are built again with it, in a variant that is not exported to Make, that the
+// other crates are not, and that the modules in its paths only have that variant.
+func TestSecondaryRustToolchainVariants(t *testing.T) {
+	result := prepareForSecondaryRustToolchainTest.RunTestWithBp(t, `
+		rust_library {
+			name: "libunrelated",
+			srcs: ["foo.rs"],
+			crate_name: "unrelated",
+			rustlibs: ["libbaz"],
+		}
+	`)
+
+	const variant = "android_arm64_armv8-a_dylib"
+	const secondaryVariant = variant + "_" + secondaryRustToolchainVariation
+
+	variants := result.ModuleVariantsForTests("libbar")
+	android.AssertStringListContains(t, "libbar variants", variants, variant)
+	android.AssertStringListContains(t, "libbar variants", variants, secondaryVariant)
+	android.AssertStringListDoesNotContain(t, "libsecondary variants",
+		result.ModuleVariantsForTests("libsecondary"), variant)
+	android.AssertStringListContains(t, "libbaz variants",
+		result.ModuleVariantsForTests("libbaz"), secondaryVariant)
+	android.AssertStringListDoesNotContain(t, "libunrelated variants",
+		result.ModuleVariantsForTests("libunrelated"), secondaryVariant)
+
+	bar := result.ModuleForTests("libbar", secondaryVariant)
+	barModule := bar.Module().(*Module)
+	if !android.InList("libstd.rust_secondary", barModule.Properties.AndroidMkDylibs) {
+		t.Errorf("the secondary variant of libbar does not link the libstd of the secondary toolchain")
+	}
+	android.AssertBoolEquals(t, "secondary variant hidden from make", true, barModule.Properties.HideFromMake)
+	rustc := bar.Rule("rustcSecondary")
+	android.AssertStringDoesContain(t, "secondary envVars", rustc.Args["envVars"], "ANDROID_RUST_VERSION=1.67.0")
+
+	barDefault := result.ModuleForTests("libbar", variant)
+	android.AssertBoolEquals(t, "default variant hidden from make", false,
+		barDefault.Module().(*Module).Properties.HideFromMake)
+	barDefault.Rule("rustc")
+
+	secondary := result.ModuleForTests("libsecondary", secondaryVariant)
+	android.AssertStringDoesContain(t, "libsecondary links the secondary variant of libbar",
+		secondary.Rule("rustcSecondary").Args["libFlags"], "bar/libbar/"+secondaryVariant+"/")
+}
+
+// Test that crates built with the default and the secondary rust toolchains cannot be linked
+// together.
+func TestSecondaryRustToolchainCrossLink(t *testing.T) {
+	prepareForSecondaryRustToolchainTest.
+		ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(regexp.QuoteMeta(
+			fmt.Sprintf(`cannot depend on "libsecondary" built with rustc 1.67.0 from a crate built with rustc %s`,
+				config.RustDefaultVersion)))).
+		RunTestWithBp(t, `
+			rust_library {
+				name: "libfoo",
+				srcs: ["foo.rs"],
+				crate_name: "foo",
+				rustlibs: ["libsecondary"],
+			}
+		`)
+}
@@ -0,0 +1,138 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks the links between the html pages of a docs zip."""
+
+import argparse
+import html.parser
+import posixpath
+import sys
+import urllib.parse
+import zipfile
+
+
+class _PageParser(html.parser.HTMLParser):
+  """Collects the link targets and the anchors of an html page."""
+
+  def __init__(self):
+    super().__init__()
+    self.links = []
+    self.anchors = set()
+
+  def handle_starttag(self, tag, attrs):
+    attrs = dict(attrs)
+    if attrs.get('id'):
+      self.anchors.add(attrs['id'])
+    if tag == 'a':
+      if attrs.get('name'):
+        self.anchors.add(attrs['name'])
+      if attrs.get('href'):
+        self.links.append(attrs['href'])
+
+
+def parse_page(contents):
+  """Returns the link targets and the anchors of the html page contents."""
+  parser = _PageParser()
+  parser.feed(contents)
+  parser.close()
+  return parser.links, parser.anchors
+
+
+def resolve_link(page, link):
+  """Returns the (path, fragment) in the docs that a link in page points to.
+
+  Returns None for the links that point outside the docs, e.g. to other sites.
+  Absolute paths are resolved against the root of the docs, which is served at
+  a stable URL.
+  """
+  url = urllib.parse.urlsplit(link)
+  if url.scheme or url.netloc:
+    return None
+  if not url.path:
+    return page, url.fragment
+  if url.path.startswith('/'):
+    path = url.path.lstrip('/')
+  else:
+    path = posixpath.join(posixpath.dirname(page), url.path)
+  path = posixpath.normpath(urllib.parse.unquote(path))
+  if path.startswith('../'):
+    return None
+  if url.path.endswith('/'):
+    path = posixpath.join(path, 'index.html')
+  return path, url.fragment
+
+
+def check_links(pages, files):
+  """Returns the broken links of the docs.
+
+  pages maps the path of each html page to its contents, and files is the set
+  of the paths of all the files in the docs.
+  """
+  parsed = {path: parse_page(contents) for path, contents in pages.items()}
+  errors = []
+  for page in sorted(parsed):
+    for link in parsed[page][0]:
+      target = resolve_link(page, link)
+      if target is None:
+        continue
+      path, fragment = target
+      if path not in files:
+        errors.append('%s: broken link to %s: %s does not exist, is it excluded from the docs?' %
+                      (page, link, path))
+      elif fragment and path in parsed and fragment not in parsed[path][1]:
+        errors.append('%s: broken link to %s: %s has no anchor %s' % (page, link, path, fragment))
+  return errors
+
+
+def read_docs(docs_zip):
+  """Returns the html pages and the paths of the files in the docs zip."""
+  pages = {}
+  files = set()
+  with zipfile.ZipFile(docs_zip) as z:
+    for info in z.infolist():
+      if info.is_dir():
+        continue
+      files.add(info.filename)
+      if info.filename.endswith('.html'):
+        pages[info.filename] = z.read(info).decode('utf-8', errors='replace')
+  return pages, files
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--report', required=True, help='the file to write the broken links to')
+  parser.add_argument('--fail-on-errors', action='store_true',
+                      help='exit with an error if there are broken links')
+  parser.add_argument('docs_zip', help='the docs zip to check')
+  args = parser.parse_args()
+
+  pages, files = read_docs(args.docs_zip)
+  errors = check_links(pages, files)
+  with open(args.report, 'w') as f:
+    for error in errors:
+      f.write(error + '\n')
+
+  if errors and args.fail_on_errors:
+    print('error: %s has %d broken links, see %s:' % (args.docs_zip, len(errors), args.report),
+          file=sys.stderr)
+    for error in errors[:10]:
+      print('  ' + error, file=sys.stderr)
+    return 1
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,70 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_doc_links.py."""
+
+import unittest
+
+import check_doc_links
+
+
+class CheckDocLinksTest(unittest.TestCase):
+  """Unit tests for check_doc_links."""
+
+  def test_parse_page(self):
+    links, anchors = check_doc_links.parse_page(
+        '<h2 id="summary">Summary</h2><a name="foo()"></a>'
+        '<a href="Bar.html#baz()">baz</a><a>no link</a>')
+    self.assertEqual(links, ['Bar.html#baz()'])
+    self.assertEqual(anchors, {'summary', 'foo()'})
+
+  def test_resolve_link(self):
+    page = 'reference/android/app/Foo.html'
+    self.assertEqual(check_doc_links.resolve_link(page, 'Bar.html'),
+                     ('reference/android/app/Bar.html', ''))
+    self.assertEqual(check_doc_links.resolve_link(page, '../os/Baz.html#qux'),
+                     ('reference/android/os/Baz.html', 'qux'))
+    self.assertEqual(check_doc_links.resolve_link(page, '#summary'), (page, 'summary'))
+    self.assertEqual(check_doc_links.resolve_link(page, '/reference/packages.html'),
+                     ('reference/packages.html', ''))
+    self.assertEqual(check_doc_links.resolve_link(page, '/reference/'),
+                     ('reference/index.html', ''))
+    self.assertIsNone(check_doc_links.resolve_link(page, 'https://developer.android.com/'))
+    self.assertIsNone(check_doc_links.resolve_link(page, 'mailto:docs@example.com'))
+    self.assertIsNone(check_doc_links.resolve_link(page, '../../../../outside.html'))
+
+  def test_check_links(self):
+    pages = {
+        'reference/Foo.html': '<a href="Bar.html#bar()">bar</a><a href="#foo()">foo</a>'
+                              '<a name="foo()"></a><a href="Excluded.html">excluded</a>'
+                              '<a href="Bar.html#missing()">missing</a>',
+        'reference/Bar.html': '<a name="bar()"></a><a href="images/bar.png">image</a>',
+    }
+    files = set(pages) | {'reference/images/bar.png'}
+    self.assertEqual(check_doc_links.check_links(pages, files), [
+        'reference/Foo.html: broken link to Excluded.html: reference/Excluded.html does not exist, '
+        'is it excluded from the docs?',
+        'reference/Foo.html: broken link to Bar.html#missing(): reference/Bar.html has no anchor '
+        'missing()',
+    ])
+
+  def test_check_links_clean(self):
+    pages = {'index.html': '<a href="reference/Foo.html">Foo</a>', 'reference/Foo.html': ''}
+    self.assertEqual(check_doc_links.check_links(pages, set(pages)), [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
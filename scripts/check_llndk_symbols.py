@@ -0,0 +1,125 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks the llndk.symbol_file of a library against the library and its LLNDK headers.
+
+Each symbol listed in the symbol file must be defined by the implementation library, and declared
+by one of the headers in the include directories exported to the LLNDK variant of the library.
+All the problems of the library are reported together.
+"""
+
+import argparse
+import os
+import re
+import subprocess
+import sys
+
+
+def defined_symbols(nm_output):
+  """Returns the symbols in the output of llvm-nm -D --defined-only --format=just-symbols.
+
+  The versions of versioned symbols, e.g. foo@@LIBFOO, are dropped.
+  """
+  symbols = set()
+  for line in nm_output.splitlines():
+    line = line.strip()
+    if line:
+      symbols.add(line.split('@', 1)[0])
+  return symbols
+
+
+def listed_symbols(symbol_list):
+  """Returns the symbols in a symbol list generated by ndkstubgen."""
+  symbols = set()
+  for line in symbol_list.splitlines():
+    line = line.strip()
+    # Skip the [abi_symbol_list] section header.
+    if line and not line.startswith('['):
+      symbols.add(line)
+  return symbols
+
+
+def undefined(listed, defined):
+  """Returns the sorted symbols that are listed but not defined."""
+  return sorted(listed - defined)
+
+
+def undeclared(listed, headers):
+  """Returns the sorted symbols that are listed but not named by any of the header contents.
+
+  Mangled C++ symbols are not checked, as the headers name them unmangled.
+  """
+  identifiers = set()
+  for header in headers:
+    identifiers.update(re.findall(r'[A-Za-z_][A-Za-z0-9_]*', header))
+  return sorted(s for s in listed if not s.startswith('_Z') and s not in identifiers)
+
+
+def read_headers(include_dirs):
+  """Returns the contents of the files in the existing include_dirs."""
+  headers = []
+  for include_dir in include_dirs:
+    for root, _, files in os.walk(include_dir):
+      for name in files:
+        with open(os.path.join(root, name), errors='ignore') as f:
+          headers.append(f.read())
+  return headers
+
+
+def problems(include_dirs, listed, defined, headers):
+  """Returns the descriptions of the inconsistencies between the symbol file and the library."""
+  result = []
+  for include_dir in include_dirs:
+    if not os.path.isdir(include_dir):
+      result.append('exported include directory %s does not exist' % include_dir)
+  for symbol in undefined(listed, defined):
+    result.append('symbol %s is not defined by the library' % symbol)
+  for symbol in undeclared(listed, headers):
+    result.append('symbol %s is not declared by the exported headers' % symbol)
+  return result
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--nm', required=True, help='path to llvm-nm')
+  parser.add_argument('--symbol-list', required=True,
+                      help='the symbol list generated from the llndk.symbol_file')
+  parser.add_argument('--include-dir', action='append', default=[], dest='include_dirs',
+                      help='an include directory exported to the LLNDK variant of the library')
+  parser.add_argument('file', help='the implementation shared library to check')
+  args = parser.parse_args()
+
+  nm_output = subprocess.check_output(
+      [args.nm, '-D', '--defined-only', '--format=just-symbols', args.file], text=True)
+  with open(args.symbol_list) as f:
+    listed = listed_symbols(f.read())
+
+  found = problems(args.include_dirs, listed, defined_symbols(nm_output),
+                   read_headers(args.include_dirs))
+  if not found:
+    return 0
+
+  print('error: the llndk.symbol_file of %s does not match the library:' % args.file,
+        file=sys.stderr)
+  for problem in found:
+    print('  ' + problem, file=sys.stderr)
+  print('Fix the symbol file, the llndk include directories or the library, or mark the symbols '
+        'with the appropriate tags if they are not in the LLNDK.', file=sys.stderr)
+  return 1
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,101 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_llndk_symbols.py."""
+
+import os
+import tempfile
+import unittest
+
+import check_llndk_symbols
+
+NM_OUTPUT = """\
+foo@@LIBFOO
+bar@@LIBFOO
+baz@LIBFOO_OLD
+__cxa_finalize
+"""
+
+SYMBOL_LIST = """\
+[abi_symbol_list]
+foo
+bar
+qux
+"""
+
+HEADER = """\
+int foo(void);
+extern int bar;
+int baz(int qux_arg);
+"""
+
+
+class CheckLlndkSymbolsTest(unittest.TestCase):
+  """Unit tests for check_llndk_symbols."""
+
+  def test_defined_symbols(self):
+    self.assertEqual(check_llndk_symbols.defined_symbols(NM_OUTPUT),
+                     {'foo', 'bar', 'baz', '__cxa_finalize'})
+
+  def test_listed_symbols(self):
+    self.assertEqual(check_llndk_symbols.listed_symbols(SYMBOL_LIST), {'foo', 'bar', 'qux'})
+
+  def test_extra_symbol(self):
+    defined = check_llndk_symbols.defined_symbols(NM_OUTPUT)
+    listed = check_llndk_symbols.listed_symbols(SYMBOL_LIST)
+    self.assertEqual(check_llndk_symbols.undefined(listed, defined), ['qux'])
+    self.assertEqual(check_llndk_symbols.undefined({'foo', 'baz'}, defined), [])
+
+
+  def test_undeclared(self):
+    listed = check_llndk_symbols.listed_symbols(SYMBOL_LIST)
+    # qux_arg does not declare qux.
+    self.assertEqual(check_llndk_symbols.undeclared(listed, [HEADER]), ['qux'])
+    self.assertEqual(check_llndk_symbols.undeclared({'foo', '_ZN3foo3barEv'}, [HEADER]), [])
+
+  def test_problems(self):
+    with tempfile.TemporaryDirectory() as tmp:
+      include = os.path.join(tmp, 'include')
+      os.makedirs(os.path.join(include, 'sub'))
+      with open(os.path.join(include, 'sub', 'foo.h'), 'w') as f:
+        f.write(HEADER)
+      missing = os.path.join(tmp, 'include_missing')
+      include_dirs = [include, missing]
+
+      defined = check_llndk_symbols.defined_symbols(NM_OUTPUT)
+      headers = check_llndk_symbols.read_headers(include_dirs)
+
+      # An extra symbol that is neither defined nor declared, and a missing header directory,
+      # are all reported.
+      listed = check_llndk_symbols.listed_symbols(SYMBOL_LIST)
+      self.assertEqual(check_llndk_symbols.problems(include_dirs, listed, defined, headers), [
+          'exported include directory %s does not exist' % missing,
+          'symbol qux is not defined by the library',
+          'symbol qux is not declared by the exported headers',
+      ])
+
+      # A defined symbol that is missing from the headers.
+      self.assertEqual(check_llndk_symbols.problems([include], {'foo', '__cxa_finalize'}, defined,
+                                                    headers), [
+          'symbol __cxa_finalize is not declared by the exported headers',
+      ])
+
+      self.assertEqual(check_llndk_symbols.problems([include], {'foo', 'bar'}, defined, headers),
+                       [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
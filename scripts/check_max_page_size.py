@@ -0,0 +1,66 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks that the load segments of an ELF file are aligned to the declared max page size."""
+
+import argparse
+import subprocess
+import sys
+
+
+def load_segment_alignments(program_headers):
+  """Returns the alignments of the LOAD segments in the output of llvm-readelf -lW."""
+  alignments = []
+  for line in program_headers.splitlines():
+    fields = line.split()
+    # "Type Offset VirtAddr PhysAddr FileSiz MemSiz Flg Align", where Flg may contain spaces.
+    if fields and fields[0] == 'LOAD':
+      alignments.append(int(fields[-1], 16))
+  return alignments
+
+
+def misaligned(alignments, max_page_size):
+  """Returns the sorted alignments that differ from max_page_size."""
+  return sorted(set(a for a in alignments if a != max_page_size))
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--readelf', required=True, help='path to llvm-readelf')
+  parser.add_argument('--max-page-size', required=True, type=int,
+                      help='the max page size the file is declared for')
+  parser.add_argument('file', help='the ELF file to check')
+  args = parser.parse_args()
+
+  program_headers = subprocess.check_output([args.readelf, '-lW', args.file], text=True)
+  alignments = load_segment_alignments(program_headers)
+  if not alignments:
+    print('error: %s has no load segments' % args.file, file=sys.stderr)
+    return 1
+
+  actual = misaligned(alignments, args.max_page_size)
+  if not actual:
+    return 0
+
+  print('error: %s has load segments aligned to %s, expected %d' %
+        (args.file, ', '.join(str(a) for a in actual), args.max_page_size), file=sys.stderr)
+  print('Link it with -Wl,-z,max-page-size=%d, or set max_page_size to the alignment it was built '
+        'for.' % args.max_page_size, file=sys.stderr)
+  return 1
+
+
+if __name__ == '__main__':
+  sys.exit(main())
@@ -0,0 +1,53 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_max_page_size.py."""
+
+import unittest
+
+import check_max_page_size
+
+PROGRAM_HEADERS = """\
+
+Elf file type is DYN (Shared object file)
+Entry point 0x0
+There are 4 program headers, starting at offset 64
+
+Program Headers:
+  Type           Offset   VirtAddr           PhysAddr           FileSiz  MemSiz   Flg Align
+  PHDR           0x000040 0x0000000000000040 0x0000000000000040 0x0000e0 0x0000e0 R   0x8
+  LOAD           0x000000 0x0000000000000000 0x0000000000000000 0x0005f4 0x0005f4 R   0x4000
+  LOAD           0x0005f4 0x00000000000045f4 0x00000000000045f4 0x000140 0x000140 R E 0x4000
+  LOAD           0x000738 0x000000000000c738 0x000000000000c738 0x000168 0x000168 RW  0x1000
+"""
+
+
+class CheckMaxPageSizeTest(unittest.TestCase):
+  """Unit tests for check_max_page_size."""
+
+  def test_load_segment_alignments(self):
+    self.assertEqual(check_max_page_size.load_segment_alignments(PROGRAM_HEADERS),
+                     [16384, 16384, 4096])
+
+  def test_misaligned(self):
+    alignments = check_max_page_size.load_segment_alignments(PROGRAM_HEADERS)
+    self.assertEqual(check_max_page_size.misaligned(alignments, 16384), [4096])
+    self.assertEqual(check_max_page_size.misaligned(alignments, 4096), [16384])
+    self.assertEqual(check_max_page_size.misaligned([4096, 4096], 4096), [])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -0,0 +1,107 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Checks that a notice file fits in its size budget.
+
+Writes a report with the size of the notice file and the largest license
+texts that contribute to it, and fails if the notice file is larger than the
+budget.
+"""
+
+import argparse
+import os
+import sys
+
+# The number of contributors listed in the report.
+TOP_CONTRIBUTORS = 10
+
+
+def parse_contributors(contents):
+  """Returns a map of each license text to the sorted modules that use it.
+
+  Each line of the contents is a module and a license text separated by a tab.
+  """
+  contributors = {}
+  for line in contents.splitlines():
+    if not line.strip():
+      continue
+    module, license_text = line.split('\t', 1)
+    contributors.setdefault(license_text, set()).add(module)
+  return {text: sorted(modules) for text, modules in contributors.items()}
+
+
+def top_contributors(contributors, sizes, count=TOP_CONTRIBUTORS):
+  """Returns (size, license text, modules) of the largest license texts.
+
+  sizes maps each license text to its size in bytes.  The license texts are
+  ordered by decreasing size, then by path.
+  """
+  entries = [(sizes.get(text, 0), text, modules)
+             for text, modules in contributors.items()]
+  entries.sort(key=lambda e: (-e[0], e[1]))
+  return entries[:count]
+
+
+def format_report(notice, size, budget, top):
+  """Returns the report of the size of the notice file."""
+  lines = ['%s: %d bytes, budget %d bytes' % (notice, size, budget), '',
+           'top contributors:']
+  for text_size, text, modules in top:
+    used_by = ', '.join(modules[:5])
+    if len(modules) > 5:
+      used_by += ' and %d more' % (len(modules) - 5)
+    lines.append('  %10d  %s (used by %s)' % (text_size, text, used_by))
+  return '\n'.join(lines) + '\n'
+
+
+def file_size(path):
+  """Returns the size of the file, or 0 if it does not exist."""
+  try:
+    return os.path.getsize(path)
+  except OSError:
+    return 0
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--notice', required=True, help='the notice file to check')
+  parser.add_argument('--budget', required=True, type=int,
+                      help='the maximum size of the notice file in bytes')
+  parser.add_argument('--contributors', required=True,
+                      help='the file listing the module and license text of each contributor')
+  parser.add_argument('-o', '--output', required=True, help='the report to write')
+  args = parser.parse_args()
+
+  with open(args.contributors) as f:
+    contributors = parse_contributors(f.read())
+  sizes = {text: file_size(text) for text in contributors}
+  size = file_size(args.notice)
+  report = format_report(args.notice, size, args.budget, top_contributors(contributors, sizes))
+
+  if size > args.budget:
+    print('error: %s is %d bytes, which exceeds its budget of %d bytes by %d bytes.\n'
+          'Reduce the license texts of the largest contributors or raise the size_budget of '
+          'the gen_notice module.\n%s' % (args.notice, size, args.budget, size - args.budget, report),
+          file=sys.stderr)
+    return 1
+
+  with open(args.output, 'w') as f:
+    f.write(report)
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
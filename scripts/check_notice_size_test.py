@@ -0,0 +1,109 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_notice_size.py."""
+
+import contextlib
+import io
+import os
+import sys
+import tempfile
+import unittest
+from unittest import mock
+
+import check_notice_size
+
+CONTRIBUTORS = """\
+libfoo\texternal/foo/LICENSE
+libbar\texternal/bar/NOTICE
+libfoo2\texternal/foo/LICENSE
+
+libbaz\texternal/baz/LICENSE
+"""
+
+
+class CheckNoticeSizeTest(unittest.TestCase):
+  """Unit tests for check_notice_size."""
+
+  def test_parse_contributors(self):
+    self.assertEqual(check_notice_size.parse_contributors(CONTRIBUTORS), {
+        'external/foo/LICENSE': ['libfoo', 'libfoo2'],
+        'external/bar/NOTICE': ['libbar'],
+        'external/baz/LICENSE': ['libbaz'],
+    })
+
+  def test_top_contributors(self):
+    contributors = check_notice_size.parse_contributors(CONTRIBUTORS)
+    sizes = {
+        'external/foo/LICENSE': 100,
+        'external/bar/NOTICE': 3000,
+        'external/baz/LICENSE': 100,
+    }
+    self.assertEqual(check_notice_size.top_contributors(contributors, sizes, count=2), [
+        (3000, 'external/bar/NOTICE', ['libbar']),
+        (100, 'external/baz/LICENSE', ['libbaz']),
+    ])
+
+  def test_format_report(self):
+    modules = ['lib%d' % i for i in range(7)]
+    report = check_notice_size.format_report('NOTICE.xml.gz', 5000, 4096, [
+        (3000, 'external/bar/NOTICE', ['libbar']),
+        (100, 'external/foo/LICENSE', modules),
+    ])
+    self.assertEqual(report, (
+        'NOTICE.xml.gz: 5000 bytes, budget 4096 bytes\n'
+        '\n'
+        'top contributors:\n'
+        '        3000  external/bar/NOTICE (used by libbar)\n'
+        '         100  external/foo/LICENSE (used by lib0, lib1, lib2, lib3, lib4 and 2 more)\n'))
+
+  def run_main(self, budget):
+    """Runs the check of a notice with a large license text, returns the exit code and stderr."""
+    with tempfile.TemporaryDirectory() as tmp:
+      large_license = os.path.join(tmp, 'LARGE_LICENSE')
+      with open(large_license, 'w') as f:
+        f.write('x' * 8192)
+      notice = os.path.join(tmp, 'NOTICE.txt')
+      with open(notice, 'w') as f:
+        f.write('x' * 8300)
+      contributors = os.path.join(tmp, 'contributors.txt')
+      with open(contributors, 'w') as f:
+        f.write('libfoo\t%s\n' % large_license)
+      report = os.path.join(tmp, 'report.txt')
+
+      stderr = io.StringIO()
+      argv = ['check_notice_size', '--notice', notice, '--budget', str(budget),
+              '--contributors', contributors, '-o', report]
+      with mock.patch.object(sys, 'argv', argv), contextlib.redirect_stderr(stderr):
+        code = check_notice_size.main()
+      self.assertEqual(os.path.exists(report), code == 0)
+      return code, stderr.getvalue().replace(tmp + '/', '')
+
+  def test_main_within_budget(self):
+    code, stderr = self.run_main(10000)
+    self.assertEqual(code, 0)
+    self.assertEqual(stderr, '')
+
+  def test_main_exceeds_budget(self):
+    code, stderr = self.run_main(4096)
+    self.assertEqual(code, 1)
+    self.assertIn('error: NOTICE.txt is 8300 bytes, which exceeds its budget of 4096 bytes by 4204 bytes.',
+                  stderr)
+    self.assertIn('        8192  LARGE_LICENSE (used by libfoo)', stderr)
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
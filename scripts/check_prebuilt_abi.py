@@ -0,0 +1,111 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Compares the exported symbols of a prebuilt shared library with its source module.
+
+The inputs are the table of contents files generated by toc.sh for the source and prebuilt
+libraries.  When the prebuilt has no table of contents, its exported symbols are read with
+llvm-nm instead.
+"""
+
+import argparse
+import subprocess
+import sys
+
+
+def exported_symbols(toc):
+  """Returns the set of symbols defined and exported in a toc.sh table of contents."""
+  symbols = set()
+  for line in toc.splitlines():
+    fields = line.split()
+    if len(fields) >= 6 and fields[0].endswith(':') and fields[0][:-1].isdigit():
+      # ELF: "Num: Type Bind Vis Ndx Name"
+      typ, bind, ndx, name = fields[1], fields[2], fields[4], fields[5]
+      if ndx == 'UND' or bind not in ('GLOBAL', 'WEAK'):
+        continue
+      if typ in ('SECTION', 'FILE'):
+        continue
+      symbols.add(name.split('@', 1)[0])
+    elif len(fields) == 2 and len(fields[1]) == 1 and fields[1].isalpha():
+      # Mach-O and PE: "Name Type"
+      if fields[1] not in ('U', 'u'):
+        symbols.add(fields[0])
+  return symbols
+
+
+def nm_symbols(nm_output):
+  """Returns the symbols in the output of llvm-nm -D -g --defined-only --format=just-symbols.
+
+  The versions of versioned symbols, e.g. foo@@LIBFOO, are dropped.
+  """
+  symbols = set()
+  for line in nm_output.splitlines():
+    line = line.strip()
+    if line:
+      symbols.add(line.split('@', 1)[0])
+  return symbols
+
+
+def compare(source, prebuilt):
+  """Returns the symbols missing from and added to the prebuilt compared to the source."""
+  return sorted(source - prebuilt), sorted(prebuilt - source)
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--name', required=True, help='name of the library')
+  parser.add_argument('--source-toc', required=True, help='toc of the library built from source')
+  prebuilt_group = parser.add_mutually_exclusive_group(required=True)
+  prebuilt_group.add_argument('--prebuilt-toc', help='toc of the prebuilt library')
+  prebuilt_group.add_argument('--prebuilt-lib',
+                              help='the prebuilt library, read with --nm if it has no toc')
+  parser.add_argument('--nm', help='path to llvm-nm, required with --prebuilt-lib')
+  args = parser.parse_args()
+
+  with open(args.source_toc) as f:
+    source = exported_symbols(f.read())
+  if args.prebuilt_toc:
+    with open(args.prebuilt_toc) as f:
+      prebuilt = exported_symbols(f.read())
+  else:
+    if not args.nm:
+      parser.error('--nm is required with --prebuilt-lib')
+    nm_output = subprocess.check_output(
+        [args.nm, '-D', '-g', '--defined-only', '--format=just-symbols', args.prebuilt_lib],
+        text=True)
+    prebuilt = nm_symbols(nm_output)
+
+  missing, added = compare(source, prebuilt)
+  if not missing and not added:
+    return 0
+
+  print('error: prebuilt %s does not export the same symbols as the source module it replaces'
+        % args.name, file=sys.stderr)
+  if missing:
+    print('  missing from the prebuilt:', file=sys.stderr)
+    for symbol in missing:
+      print('    ' + symbol, file=sys.stderr)
+  if added:
+    print('  added in the prebuilt:', file=sys.stderr)
+    for symbol in added:
+      print('    ' + symbol, file=sys.stderr)
+  print('Update the prebuilt, or remove check_abi_against_source if the difference is intended.',
+        file=sys.stderr)
+  return 1
+
+
+if __name__ == '__main__':
+  sys.exit(main())
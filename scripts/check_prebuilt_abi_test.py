@@ -0,0 +1,77 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for check_prebuilt_abi.py."""
+
+import unittest
+
+import check_prebuilt_abi
+
+SOURCE_TOC = """\
+ 0x000000000000000e (SONAME)             Library soname: [libfoo.so]
+Symbol table '.dynsym' contains 5 entries:
+Num:   Type Bind Vis Ndx Name
+0:   NOTYPE LOCAL DEFAULT UND
+1:   FUNC GLOBAL DEFAULT UND __cxa_finalize@LIBC (2)
+2:   FUNC GLOBAL DEFAULT 12 foo_init
+3:   FUNC WEAK DEFAULT 12 foo_run
+4:   OBJECT GLOBAL DEFAULT 20 foo_version
+"""
+
+
+class CheckPrebuiltAbiTest(unittest.TestCase):
+  """Unit tests for check_prebuilt_abi."""
+
+  def test_exported_symbols_elf(self):
+    self.assertEqual(check_prebuilt_abi.exported_symbols(SOURCE_TOC),
+                     {'foo_init', 'foo_run', 'foo_version'})
+
+  def test_exported_symbols_macho(self):
+    toc = 'LC_ID_DYLIB\n_foo_init T\n_foo_version D\n_malloc U\n'
+    self.assertEqual(check_prebuilt_abi.exported_symbols(toc), {'_foo_init', '_foo_version'})
+
+  def test_exported_symbols_versioned(self):
+    toc = '1:   FUNC GLOBAL DEFAULT 12 foo_init@@LIBFOO\n'
+    self.assertEqual(check_prebuilt_abi.exported_symbols(toc), {'foo_init'})
+
+  def test_nm_symbols(self):
+    nm_output = 'foo_init@@LIBFOO\nfoo_run\n\nfoo_version\n'
+    self.assertEqual(check_prebuilt_abi.nm_symbols(nm_output),
+                     {'foo_init', 'foo_run', 'foo_version'})
+
+  def test_matching(self):
+    # Different symbol table indices and soname details don't matter.
+    prebuilt = SOURCE_TOC.replace('2:   FUNC', '7:   FUNC').replace('(SONAME)', '(SONAME) ')
+    self.assertEqual(check_prebuilt_abi.compare(check_prebuilt_abi.exported_symbols(SOURCE_TOC),
+                                                check_prebuilt_abi.exported_symbols(prebuilt)),
+                     ([], []))
+
+  def test_matching_nm(self):
+    prebuilt = check_prebuilt_abi.nm_symbols('foo_init\nfoo_run\nfoo_version\n')
+    self.assertEqual(check_prebuilt_abi.compare(check_prebuilt_abi.exported_symbols(SOURCE_TOC),
+                                                prebuilt),
+                     ([], []))
+
+  def test_mismatching(self):
+    prebuilt = SOURCE_TOC.replace('foo_run', 'foo_run2').replace(
+        '4:   OBJECT GLOBAL DEFAULT 20 foo_version', '4:   OBJECT GLOBAL DEFAULT UND foo_version')
+    self.assertEqual(check_prebuilt_abi.compare(check_prebuilt_abi.exported_symbols(SOURCE_TOC),
+                                                check_prebuilt_abi.exported_symbols(prebuilt)),
+                     (['foo_run', 'foo_version'], ['foo_run2']))
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
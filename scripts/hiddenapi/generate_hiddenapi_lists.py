@@ -66,6 +66,11 @@ FLAG_IGNORE_CONFLICTS = 'ignore-conflicts'
 # apis within a given set of packages should be assign the given flag.
 FLAG_PACKAGES = 'packages'
 
+# Option specified after one of FLAGS_API_LIST to express that all
+# apis within a given set of packages and their sub-packages should be
+# assign the given flag.
+FLAG_PACKAGE_PREFIXES = 'package-prefixes'
+
 # Option specified after one of FLAGS_API_LIST to indicate an extra
 # tag that should be added to the matching APIs.
 FLAG_TAG = 'tag'
@@ -147,6 +152,15 @@ def get_args():
         'is a list of packages. All members in those packages will be given '
         'the flag. Must follow a list of entries and applies to the preceding '
         'such list.')
+    parser.add_argument(
+        '--' + FLAG_PACKAGE_PREFIXES,
+        dest='ordered_flags',
+        nargs=0,
+        action=StoreOrderedOptions,
+        help='Indicates that the previous list of entries '
+        'is a list of package prefixes. All members in those packages and '
+        'their sub-packages will be given the flag. Must follow a list of '
+        'entries and applies to the preceding such list.')
     parser.add_argument(
         '--' + FLAG_TAG,
         dest='ordered_flags',
@@ -208,6 +222,24 @@ def extract_package(signature):
     return package_name.replace('/', '.')
 
 
+def has_package_prefix(package_name, prefixes):
+    """Checks whether a package matches one of a set of package prefixes.
+
+    Args:
+        package_name (string): the name of the package, e.g. android.foo.bar.
+        prefixes (set): package prefixes, e.g. android.foo, that match the
+          package and all its sub-packages.
+
+    Returns:
+        True if the package matches one of the prefixes.
+    """
+    while package_name:
+        if package_name in prefixes:
+            return True
+        package_name = package_name.rpartition('.')[0]
+    return False
+
+
 class FlagsDict:
 
     def __init__(self):
@@ -336,21 +368,23 @@ class FlagsDict:
 
 
 FlagFile = namedtuple('FlagFile',
-                      ('flag', 'file', 'ignore_conflicts', 'packages', 'tag'))
+                      ('flag', 'file', 'ignore_conflicts', 'packages',
+                       'package_prefixes', 'tag'))
 
 
 def parse_ordered_flags(ordered_flags):
     r = []
-    currentflag, file, ignore_conflicts, packages, tag = None, None, False, \
-        False, None
+    currentflag, file, ignore_conflicts, packages, package_prefixes, tag = \
+        None, None, False, False, False, None
     for flag_value in ordered_flags:
         flag, value = flag_value[0], flag_value[1]
         if flag in ALL_FLAGS_SET:
             if currentflag:
                 r.append(
                     FlagFile(currentflag, file, ignore_conflicts, packages,
-                             tag))
-                ignore_conflicts, packages, tag = False, False, None
+                             package_prefixes, tag))
+                ignore_conflicts, packages, package_prefixes, tag = \
+                    False, False, False, None
             currentflag = flag
             file = value
         else:
@@ -362,11 +396,15 @@ def parse_ordered_flags(ordered_flags):
                 ignore_conflicts = True
             elif flag == FLAG_PACKAGES:
                 packages = True
+            elif flag == FLAG_PACKAGE_PREFIXES:
+                package_prefixes = True
             elif flag == FLAG_TAG:
                 tag = value[0]
 
     if currentflag:
-        r.append(FlagFile(currentflag, file, ignore_conflicts, packages, tag))
+        r.append(
+            FlagFile(currentflag, file, ignore_conflicts, packages,
+                     package_prefixes, tag))
     return r
 
 
@@ -392,7 +430,8 @@ def main(argv): #pylint: disable=unused-argument
 
     # (2) Merge text files with a known flag into the dictionary.
     for info in flagfiles:
-        if (not info.ignore_conflicts) and (not info.packages):
+        if (not info.ignore_conflicts) and (not info.packages) and \
+                (not info.package_prefixes):
             flags.assign_flag(info.flag, read_lines(info.file), info.file,
                               info.tag)
 
@@ -418,6 +457,16 @@ def main(argv): #pylint: disable=unused-argument
             valid_entries = flags.filter_apis(should_add_signature_to_list)
             flags.assign_flag(info.flag, valid_entries, info.file, info.tag)
 
+    # All members in the specified packages and their sub-packages will be
+    # assigned the appropriate flag.
+    for info in flagfiles:
+        if info.package_prefixes:
+            prefixes_needing_list = set(read_lines(info.file))
+            should_add_signature_to_list = lambda sig, lists: has_package_prefix(
+                extract_package(sig), prefixes_needing_list) and not lists #pylint: disable=cell-var-from-loop
+            valid_entries = flags.filter_apis(should_add_signature_to_list)
+            flags.assign_flag(info.flag, valid_entries, info.file, info.tag)
+
     # Mark all remaining entries as blocked.
     flags.assign_flag(FLAG_BLOCKED, flags.filter_apis(HAS_NO_API_LIST_ASSIGNED))
 
@@ -112,6 +112,15 @@ class TestHiddenapiListGeneration(unittest.TestCase):
         expected_package = 'com.foo_bar.baz'
         self.assertEqual(extract_package(signature), expected_package)
 
+    def test_has_package_prefix(self):
+        prefixes = set(['com.foo', 'com.bar.baz'])
+        self.assertTrue(has_package_prefix('com.foo', prefixes))
+        self.assertTrue(has_package_prefix('com.foo.bar', prefixes))
+        self.assertTrue(has_package_prefix('com.bar.baz.qux', prefixes))
+        self.assertFalse(has_package_prefix('com.foobar', prefixes))
+        self.assertFalse(has_package_prefix('com.bar', prefixes))
+        self.assertFalse(has_package_prefix('', prefixes))
+
 
 if __name__ == '__main__':
     unittest.main(verbosity=2)
@@ -0,0 +1,117 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Merge the additional hidden API flags files of the bootclasspath modules.
+
+Each line of an additional flags file is the signature of a member followed by
+a comma and the name of the flag that the member is given, e.g.
+max_target_o_low_priority. The signatures that are given each flag are written
+to the output file of that flag. Giving a member different flags is an error
+that names the modules that do it.
+"""
+
+import argparse
+import sys
+
+
+def read_lines(path):
+    with open(path, 'r', encoding='utf8') as f:
+        lines = [line.strip() for line in f]
+    return [line for line in lines if line and not line.startswith('#')]
+
+
+def merge_flags(module_flags, known_flags):
+    """Merges the flags given to members by modules.
+
+    Args:
+        module_flags: a list of (module, source, lines) tuples, where lines are
+          the lines of the additional flags file source of module.
+        known_flags: the set of flags that members can be given.
+
+    Returns:
+        A tuple of a dict from each flag to the set of signatures that are
+        given it, and a list of errors.
+    """
+    signature_flags = {}
+    errors = []
+    for module, source, lines in module_flags:
+        for line in lines:
+            signature, _, flag = line.rpartition(',')
+            if not signature:
+                errors.append(f'{source}: {line!r} of module {module!r} is '
+                              f'not a signature followed by a flag')
+                continue
+            if flag not in known_flags:
+                errors.append(f'{source}: {signature} is given unknown flag '
+                              f'{flag!r} by module {module!r}')
+                continue
+            if signature in signature_flags:
+                other_flag, other_module = signature_flags[signature]
+                if other_flag != flag:
+                    errors.append(
+                        f'{signature} is given {other_flag} by module '
+                        f'{other_module!r} and {flag} by module {module!r}')
+                continue
+            signature_flags[signature] = (flag, module)
+
+    signatures_by_flag = {flag: set() for flag in known_flags}
+    for signature, (flag, _) in signature_flags.items():
+        signatures_by_flag[flag].add(signature)
+    return signatures_by_flag, errors
+
+
+def parse_pair(arg):
+    first, sep, second = arg.partition(':')
+    if not sep:
+        raise argparse.ArgumentTypeError(f'{arg!r} is not <name>:<path>')
+    return first, second
+
+
+def main(args):
+    args_parser = argparse.ArgumentParser(
+        description='Merge the additional hidden API flags files of modules.')
+    args_parser.add_argument(
+        '--module-flags',
+        action='append',
+        type=parse_pair,
+        default=[],
+        help='<module>:<path> of an additional flags file of a module.')
+    args_parser.add_argument(
+        '--output',
+        action='append',
+        type=parse_pair,
+        default=[],
+        help='<flag>:<path> of the file to which the signatures that are '
+        'given the flag are written.')
+    args = args_parser.parse_args(args)
+
+    module_flags = [(module, path, read_lines(path))
+                    for module, path in args.module_flags]
+    outputs = dict(args.output)
+    signatures_by_flag, errors = merge_flags(module_flags, set(outputs))
+    if errors:
+        for error in errors:
+            print(error, file=sys.stderr)
+        sys.exit(1)
+
+    for flag, path in outputs.items():
+        with open(path, 'w', encoding='utf8') as f:
+            for signature in sorted(signatures_by_flag[flag]):
+                f.write(signature)
+                f.write('\n')
+
+
+if __name__ == '__main__':
+    main(sys.argv[1:])
@@ -0,0 +1,78 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the 'License');
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an 'AS IS' BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+"""Unit tests for merge_additional_flags.py."""
+import unittest
+
+from merge_additional_flags import merge_flags
+
+KNOWN_FLAGS = {'blocked', 'max_target_o_low_priority'}
+
+
+class TestMergeAdditionalFlags(unittest.TestCase):
+
+    def test_merge(self):
+        signatures_by_flag, errors = merge_flags([
+            ('foo', 'foo.csv', [
+                'La/Foo;->foo()V,blocked',
+                'La/Shared;->bar()V,max_target_o_low_priority',
+            ]),
+            ('bar', 'bar.csv', [
+                'La/Bar;->bar()V,max_target_o_low_priority',
+                'La/Shared;->bar()V,max_target_o_low_priority',
+            ]),
+        ], KNOWN_FLAGS)
+        self.assertEqual([], errors)
+        self.assertEqual(
+            {
+                'blocked': {'La/Foo;->foo()V'},
+                'max_target_o_low_priority': {
+                    'La/Bar;->bar()V',
+                    'La/Shared;->bar()V',
+                },
+            }, signatures_by_flag)
+
+    def test_conflict(self):
+        _, errors = merge_flags([
+            ('foo', 'foo.csv', ['La/Shared;->bar()V,blocked']),
+            ('bar', 'bar.csv',
+             ['La/Shared;->bar()V,max_target_o_low_priority']),
+        ], KNOWN_FLAGS)
+        self.assertEqual([
+            "La/Shared;->bar()V is given blocked by module 'foo' and "
+            "max_target_o_low_priority by module 'bar'"
+        ], errors)
+
+    def test_unknown_flag(self):
+        _, errors = merge_flags([
+            ('foo', 'foo.csv', ['La/Foo;->foo()V,removed']),
+        ], KNOWN_FLAGS)
+        self.assertEqual([
+            "foo.csv: La/Foo;->foo()V is given unknown flag 'removed' by "
+            "module 'foo'"
+        ], errors)
+
+    def test_missing_flag(self):
+        _, errors = merge_flags([
+            ('foo', 'foo.csv', ['La/Foo;->foo()V']),
+        ], KNOWN_FLAGS)
+        self.assertEqual([
+            "foo.csv: 'La/Foo;->foo()V' of module 'foo' is not a signature "
+            "followed by a flag"
+        ], errors)
+
+
+if __name__ == '__main__':
+    unittest.main(verbosity=2)
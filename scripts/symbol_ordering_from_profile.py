@@ -0,0 +1,115 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Prints the hot symbols of an afdo or pgo profile, hottest first, for lld --symbol-ordering-file."""
+
+import argparse
+import subprocess
+import sys
+
+
+def sample_function_counts(text):
+  """Returns the (function, total samples) of the top level functions of a text sample profile.
+
+  The top level functions are the unindented lines, in the format name:total_samples:head_samples.
+  The indented lines are the samples in the body of the functions, and the functions inlined into
+  them.
+  """
+  counts = []
+  for line in text.splitlines():
+    if not line or line[0].isspace():
+      continue
+    parts = line.rsplit(':', 2)
+    if len(parts) != 3 or not parts[1].isdigit():
+      continue
+    counts.append((parts[0], int(parts[1])))
+  return counts
+
+
+def instr_function_counts(text):
+  """Returns the (function, sum of the counters) of the functions of a text instrumented profile.
+
+  Each function is a block of lines separated from the next one by an empty line: the name of the
+  function, its hash, its number of counters and then the values of the counters. The lines that
+  start with # are comments, and the lines that start with : are flags of the profile.
+  """
+  counts = []
+  for block in text.split('\n\n'):
+    lines = [l.strip() for l in block.splitlines()
+             if l.strip() and not l.startswith('#') and not l.startswith(':')]
+    if len(lines) < 3 or not lines[2].isdigit():
+      continue
+    num_counters = int(lines[2])
+    values = lines[3:3 + num_counters]
+    if not all(v.isdigit() for v in values):
+      continue
+    counts.append((lines[0], sum(int(v) for v in values)))
+  return counts
+
+
+def symbol_name(function):
+  """Returns the symbol of a function in a profile.
+
+  The functions with internal linkage are prefixed with the name of their source file and a ; or
+  a :, which mangled names never contain.
+  """
+  for separator in (';', ':'):
+    function = function.rsplit(separator, 1)[-1]
+  return function
+
+
+def hot_symbols(counts):
+  """Returns the symbols of the functions with a non zero count, hottest first.
+
+  Functions with the same count keep the order of the profile, and a symbol is only listed once,
+  at the position of its hottest function.
+  """
+  hot = sorted((c for c in counts if c[1] > 0), key=lambda c: c[1], reverse=True)
+  symbols = []
+  seen = set()
+  for function, _ in hot:
+    symbol = symbol_name(function)
+    if symbol not in seen:
+      seen.add(symbol)
+      symbols.append(symbol)
+  return symbols
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  parser.add_argument('--llvm-profdata', required=True, help='path to llvm-profdata')
+  kind = parser.add_mutually_exclusive_group(required=True)
+  kind.add_argument('--sample', dest='kind', action='store_const', const='sample',
+                    help='the profile is an afdo sample profile')
+  kind.add_argument('--instr', dest='kind', action='store_const', const='instr',
+                    help='the profile is a pgo instrumented profile')
+  parser.add_argument('profile', help='the profile to derive the symbol ordering from')
+  args = parser.parse_args()
+
+  text = subprocess.check_output(
+      [args.llvm_profdata, 'merge', '--' + args.kind, '--text', '-o', '-', args.profile], text=True)
+  if args.kind == 'sample':
+    counts = sample_function_counts(text)
+  else:
+    counts = instr_function_counts(text)
+
+  for symbol in hot_symbols(counts):
+    print(symbol)
+  return 0
+
+
+if __name__ == '__main__':
+  sys.exit(main())
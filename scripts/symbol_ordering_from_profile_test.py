@@ -0,0 +1,106 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for symbol_ordering_from_profile.py."""
+
+import unittest
+
+import symbol_ordering_from_profile
+
+SAMPLE_PROFILE = """\
+_Z3foov:1000:10
+ 1: 100
+ 2: 200 _Z3barv:50
+ 3: _Z3bazv:300:0
+  1: 300
+main:5000:1
+ 1: 5000
+_Z4coldv:0:0
+_Z3barv:200:20
+ 1: 200
+"""
+
+INSTR_PROFILE = """\
+# IR level Instrumentation Flag
+:ir
+_Z3foov
+# Func Hash:
+1234
+# Num Counters:
+2
+# Counter Values:
+100
+50
+
+main
+# Func Hash:
+5678
+# Num Counters:
+1
+# Counter Values:
+1000
+
+foo.c;_ZL6helperv
+# Func Hash:
+9
+# Num Counters:
+1
+# Counter Values:
+10
+
+_Z4coldv
+# Func Hash:
+10
+# Num Counters:
+1
+# Counter Values:
+0
+"""
+
+
+class SymbolOrderingFromProfileTest(unittest.TestCase):
+  """Unit tests for symbol_ordering_from_profile."""
+
+  def test_sample_function_counts(self):
+    self.assertEqual(symbol_ordering_from_profile.sample_function_counts(SAMPLE_PROFILE),
+                     [('_Z3foov', 1000), ('main', 5000), ('_Z4coldv', 0), ('_Z3barv', 200)])
+
+  def test_instr_function_counts(self):
+    self.assertEqual(symbol_ordering_from_profile.instr_function_counts(INSTR_PROFILE),
+                     [('_Z3foov', 150), ('main', 1000), ('foo.c;_ZL6helperv', 10),
+                      ('_Z4coldv', 0)])
+
+  def test_symbol_name(self):
+    self.assertEqual(symbol_ordering_from_profile.symbol_name('_Z3foov'), '_Z3foov')
+    self.assertEqual(symbol_ordering_from_profile.symbol_name('foo.c;_ZL6helperv'), '_ZL6helperv')
+    self.assertEqual(symbol_ordering_from_profile.symbol_name('dir/foo.c:helper'), 'helper')
+
+  def test_hot_symbols(self):
+    self.assertEqual(symbol_ordering_from_profile.hot_symbols(
+        symbol_ordering_from_profile.sample_function_counts(SAMPLE_PROFILE)),
+                     ['main', '_Z3foov', '_Z3barv'])
+    self.assertEqual(symbol_ordering_from_profile.hot_symbols(
+        symbol_ordering_from_profile.instr_function_counts(INSTR_PROFILE)),
+                     ['main', '_Z3foov', '_ZL6helperv'])
+
+  def test_hot_symbols_duplicates(self):
+    self.assertEqual(symbol_ordering_from_profile.hot_symbols(
+        [('a.c;helper', 1), ('b', 5), ('b.c;helper', 10), ('c', 5)]),
+                     ['helper', 'b', 'c'])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
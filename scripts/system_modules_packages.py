@@ -0,0 +1,128 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Generates the module-info.java of java_system_modules from the packages of its libs.
+
+The list command writes the packages of the classes in the jars of a lib, and the module-info
+command writes a module-info.java that exports the packages of all the libs, failing if a package
+is in more than one of the libs and is not listed as a split package.
+"""
+
+import argparse
+import posixpath
+import sys
+import zipfile
+
+
+def jar_packages(names):
+  """Returns the sorted packages of the classes in the list of jar entry names."""
+  packages = set()
+  for name in names:
+    if not name.endswith('.class'):
+      continue
+    package = posixpath.dirname(name)
+    # Classes in the default package and the multi-release versions cannot be exported.
+    if not package or package.startswith('META-INF/'):
+      continue
+    packages.add(package.replace('/', '.'))
+  return sorted(packages)
+
+
+def split_packages_errors(lib_packages, allowed_split_packages):
+  """Returns the errors for the packages that are in more than one of the libs.
+
+  lib_packages is a list of (lib, packages) tuples, and allowed_split_packages is the set of the
+  packages that are allowed to be in more than one of the libs.
+  """
+  libs_of_package = {}
+  for lib, packages in lib_packages:
+    for package in packages:
+      libs_of_package.setdefault(package, []).append(lib)
+  errors = []
+  for package in sorted(libs_of_package):
+    libs = libs_of_package[package]
+    if len(libs) > 1 and package not in allowed_split_packages:
+      errors.append('package %s is in more than one of the libs: %s' % (package, ', '.join(libs)))
+  return errors
+
+
+def module_info(module, lib_packages):
+  """Returns a module-info.java for the module that exports the packages of all the libs."""
+  packages = sorted({package for _, packages in lib_packages for package in packages})
+  lines = ['module %s {' % module]
+  lines.extend('    exports %s;' % package for package in packages)
+  lines.append('}')
+  return '\n'.join(lines) + '\n'
+
+
+def list_main(args):
+  packages = set()
+  for jar in args.jars:
+    with zipfile.ZipFile(jar) as z:
+      packages.update(jar_packages(z.namelist()))
+  with open(args.out, 'w') as f:
+    for package in sorted(packages):
+      f.write(package + '\n')
+  return 0
+
+
+def module_info_main(args):
+  lib_packages = []
+  for lib_file in args.libs:
+    lib, sep, packages_file = lib_file.partition('=')
+    if not sep:
+      print('error: expected <lib>=<packages file>, got %s' % lib_file, file=sys.stderr)
+      return 1
+    with open(packages_file) as f:
+      lib_packages.append((lib, [line.strip() for line in f if line.strip()]))
+
+  errors = split_packages_errors(lib_packages, set(args.split_packages))
+  if errors:
+    for error in errors:
+      print('error: ' + error, file=sys.stderr)
+    print('Move the classes so that each package is in a single lib, or add the packages to the '
+          'split_packages property of the java_system_modules.', file=sys.stderr)
+    return 1
+
+  with open(args.out, 'w') as f:
+    f.write(module_info(args.module, lib_packages))
+  return 0
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  subparsers = parser.add_subparsers(dest='command', required=True)
+
+  list_parser = subparsers.add_parser('list', help='list the packages of the jars of a lib')
+  list_parser.add_argument('--out', required=True, help='the file to write the packages to')
+  list_parser.add_argument('jars', nargs='*', help='the jars of the lib')
+  list_parser.set_defaults(func=list_main)
+
+  module_info_parser = subparsers.add_parser('module-info', help='generate the module-info.java')
+  module_info_parser.add_argument('--module', required=True, help='the name of the module')
+  module_info_parser.add_argument('--split-package', dest='split_packages', action='append',
+                                  default=[], help='a package allowed in more than one lib')
+  module_info_parser.add_argument('--out', required=True, help='the module-info.java to write')
+  module_info_parser.add_argument('libs', nargs='*',
+                                  help='the <lib>=<packages file> of each of the libs')
+  module_info_parser.set_defaults(func=module_info_main)
+
+  args = parser.parse_args()
+  return args.func(args)
+
+
+if __name__ == '__main__':
+  sys.exit(main())
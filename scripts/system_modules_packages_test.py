@@ -0,0 +1,68 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for system_modules_packages.py."""
+
+import unittest
+
+import system_modules_packages
+
+
+class SystemModulesPackagesTest(unittest.TestCase):
+  """Unit tests for system_modules_packages."""
+
+  def test_jar_packages(self):
+    names = [
+        'META-INF/MANIFEST.MF',
+        'META-INF/versions/9/java/lang/Foo.class',
+        'java/lang/Object.class',
+        'java/lang/String.class',
+        'java/lang/',
+        'java/util/concurrent/Future.class',
+        'Default.class',
+        'res/image.png',
+    ]
+    self.assertEqual(system_modules_packages.jar_packages(names),
+                     ['java.lang', 'java.util.concurrent'])
+
+  def test_split_packages(self):
+    lib_packages = [
+        ('core-oj', ['java.lang', 'java.util', 'sun.misc']),
+        ('core-libart', ['java.lang', 'dalvik.system']),
+        ('okhttp', ['sun.misc', 'com.android.okhttp']),
+    ]
+    self.assertEqual(system_modules_packages.split_packages_errors(lib_packages, set()), [
+        'package java.lang is in more than one of the libs: core-oj, core-libart',
+        'package sun.misc is in more than one of the libs: core-oj, okhttp',
+    ])
+    self.assertEqual(
+        system_modules_packages.split_packages_errors(lib_packages, {'java.lang', 'sun.misc'}), [])
+
+  def test_module_info(self):
+    lib_packages = [
+        ('core-oj', ['java.util', 'java.lang']),
+        ('core-libart', ['java.lang', 'dalvik.system']),
+    ]
+    self.assertEqual(system_modules_packages.module_info('java.base', lib_packages),
+                     'module java.base {\n'
+                     '    exports dalvik.system;\n'
+                     '    exports java.lang;\n'
+                     '    exports java.util;\n'
+                     '}\n')
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
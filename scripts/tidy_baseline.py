@@ -0,0 +1,178 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Filters clang-tidy findings against a baseline of known findings.
+
+A finding is identified by a fingerprint made of its check, its file and a hash of the source line
+it was reported on with the whitespace normalized, so that the fingerprint does not change when
+the line moves within the file. Only the findings of the checks that are treated as errors and
+that are not in the baseline fail the build.
+"""
+
+import argparse
+import fnmatch
+import hashlib
+import re
+import sys
+
+# file:line:column: severity: message [check,...]
+_DIAGNOSTIC = re.compile(
+    r'^(?P<file>[^:\s]+):(?P<line>\d+):\d+: (?:warning|error): .* \[(?P<checks>[^\]\s]+)\]$')
+
+
+class Finding():
+  """A clang-tidy finding."""
+
+  def __init__(self, check, file, line, text, content):
+    self.check = check
+    self.file = file
+    self.line = line
+    # The diagnostic as printed by clang-tidy.
+    self.text = text
+    # The source line that the finding was reported on.
+    self.content = content
+
+  def fingerprint(self):
+    normalized = ' '.join(self.content.split())
+    digest = hashlib.sha1(normalized.encode('utf-8')).hexdigest()[:16]
+    return '%s:%s:%s' % (self.check, self.file, digest)
+
+
+def read_line(path, line, cache):
+  """Returns the line with the 1-based index line in the file at path, or '' if it is missing."""
+  if path not in cache:
+    try:
+      with open(path, encoding='utf-8', errors='replace') as f:
+        cache[path] = f.read().splitlines()
+    except OSError:
+      cache[path] = []
+  lines = cache[path]
+  return lines[line - 1] if 0 < line <= len(lines) else ''
+
+
+def parse_findings(output, read=read_line):
+  """Returns the findings in the output of clang-tidy."""
+  cache = {}
+  findings = []
+  for text in output.splitlines():
+    match = _DIAGNOSTIC.match(text)
+    if not match:
+      continue
+    path = match.group('file')
+    line = int(match.group('line'))
+    content = read(path, line, cache)
+    for check in match.group('checks').split(','):
+      # Strip the ",-warnings-as-errors" suffix of the checks that clang-tidy promoted itself.
+      if check == '-warnings-as-errors':
+        continue
+      findings.append(Finding(check, path, line, text, content))
+  return findings
+
+
+def matches_checks(check, patterns):
+  """Returns true if check is enabled by the clang-tidy style list of glob patterns."""
+  enabled = False
+  for pattern in patterns:
+    if pattern.startswith('-'):
+      if fnmatch.fnmatchcase(check, pattern[1:]):
+        enabled = False
+    elif fnmatch.fnmatchcase(check, pattern):
+      enabled = True
+  return enabled
+
+
+def read_baseline(path):
+  """Returns the set of fingerprints in the baseline file at path."""
+  with open(path, encoding='utf-8') as f:
+    return set(line.strip() for line in f if line.strip() and not line.startswith('#'))
+
+
+def write_baseline(path, fingerprints):
+  with open(path, 'w', encoding='utf-8') as f:
+    f.write('# Generated by tidy_baseline, do not edit.\n')
+    for fingerprint in sorted(set(fingerprints)):
+      f.write(fingerprint + '\n')
+
+
+def new_findings(findings, baseline, checks_as_errors):
+  """Returns the findings of the checks that are errors that are not in the baseline."""
+  return [f for f in findings
+          if matches_checks(f.check, checks_as_errors) and f.fingerprint() not in baseline]
+
+
+def filter_main(args):
+  findings = []
+  for tidy_file in args.tidy_files:
+    with open(tidy_file, encoding='utf-8', errors='replace') as f:
+      findings.extend(parse_findings(f.read()))
+
+  checks_as_errors = [c for c in args.checks_as_errors.split(',') if c]
+  baseline = read_baseline(args.baseline)
+  new = new_findings(findings, baseline, checks_as_errors)
+
+  with open(args.output, 'w', encoding='utf-8') as f:
+    for finding in new:
+      f.write(finding.text + '\n')
+
+  if args.update:
+    write_baseline(args.update, [f.fingerprint() for f in findings
+                                 if matches_checks(f.check, checks_as_errors)])
+    return 0
+
+  if not new:
+    return 0
+  for finding in new:
+    print('error: new clang-tidy finding: %s' % finding.text, file=sys.stderr)
+  print('error: %d clang-tidy findings are not in the baseline %s. Fix them, or rebuild with '
+        'TIDY_BASELINE_UPDATE=true to write an updated baseline to dist.' %
+        (len(new), args.baseline), file=sys.stderr)
+  return 1
+
+
+def merge_main(args):
+  fingerprints = set()
+  for baseline in args.baselines:
+    fingerprints |= read_baseline(baseline)
+  write_baseline(args.output, fingerprints)
+  return 0
+
+
+def main():
+  parser = argparse.ArgumentParser(description=__doc__)
+  subparsers = parser.add_subparsers(dest='command', required=True)
+
+  filter_parser = subparsers.add_parser('filter', help='fail on findings not in the baseline')
+  filter_parser.add_argument('--baseline', required=True, help='the baseline of known findings')
+  filter_parser.add_argument('--checks-as-errors', default='',
+                             help='comma separated list of the checks that are errors')
+  filter_parser.add_argument('--update', help='write a baseline of all the findings to this file '
+                             'instead of failing on new findings')
+  filter_parser.add_argument('-o', dest='output', required=True,
+                             help='output file for the new findings')
+  filter_parser.add_argument('tidy_files', nargs='*', help='the outputs of clang-tidy')
+  filter_parser.set_defaults(func=filter_main)
+
+  merge_parser = subparsers.add_parser('merge', help='merge baselines')
+  merge_parser.add_argument('-o', dest='output', required=True, help='output baseline')
+  merge_parser.add_argument('baselines', nargs='*', help='the baselines to merge')
+  merge_parser.set_defaults(func=merge_main)
+
+  args = parser.parse_args()
+  return args.func(args)
+
+
+if __name__ == '__main__':
+  sys.exit(main())
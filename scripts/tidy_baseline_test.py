@@ -0,0 +1,84 @@
+#!/usr/bin/env python
+#
+# Copyright (C) 2023 The Android Open Source Project
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#      http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+"""Unit tests for tidy_baseline.py."""
+
+import unittest
+
+import tidy_baseline
+
+SOURCES = {
+    'foo.cpp': ['int main() {', '  int* p = NULL;', '  return 0;', '}'],
+    # foo.cpp with a line inserted before the finding and different indentation.
+    'moved/foo.cpp': ['// comment', 'int main() {', '    int*  p = NULL;', '  return 0;', '}'],
+}
+
+OUTPUT = """\
+foo.cpp:2:12: warning: use nullptr [modernize-use-nullptr]
+  int* p = NULL;
+           ^~~~
+foo.cpp:1:5: error: function 'main' is bad [readability-bad,-warnings-as-errors]
+2 warnings generated.
+"""
+
+
+def read(path, line, _):
+  lines = SOURCES.get(path, [])
+  return lines[line - 1] if 0 < line <= len(lines) else ''
+
+
+class TidyBaselineTest(unittest.TestCase):
+  """Unit tests for tidy_baseline."""
+
+  def test_parse_findings(self):
+    findings = tidy_baseline.parse_findings(OUTPUT, read)
+    self.assertEqual([(f.check, f.file, f.line) for f in findings], [
+        ('modernize-use-nullptr', 'foo.cpp', 2),
+        ('readability-bad', 'foo.cpp', 1),
+    ])
+    self.assertEqual(findings[0].content, '  int* p = NULL;')
+
+  def test_fingerprint_ignores_line_moves_and_whitespace(self):
+    [finding, _] = tidy_baseline.parse_findings(OUTPUT, read)
+    moved = tidy_baseline.parse_findings(
+        'moved/foo.cpp:3:14: warning: use nullptr [modernize-use-nullptr]\n', read)[0]
+    moved.file = 'foo.cpp'
+    self.assertEqual(finding.fingerprint(), moved.fingerprint())
+
+    changed = tidy_baseline.Finding('modernize-use-nullptr', 'foo.cpp', 2, '', 'int* q = NULL;')
+    self.assertNotEqual(finding.fingerprint(), changed.fingerprint())
+
+  def test_matches_checks(self):
+    self.assertTrue(tidy_baseline.matches_checks('modernize-use-nullptr', ['modernize-*']))
+    self.assertFalse(tidy_baseline.matches_checks('modernize-use-nullptr',
+                                                  ['modernize-*', '-modernize-use-nullptr']))
+    self.assertFalse(tidy_baseline.matches_checks('readability-bad', ['modernize-*']))
+    self.assertFalse(tidy_baseline.matches_checks('readability-bad', []))
+
+  def test_new_findings(self):
+    findings = tidy_baseline.parse_findings(OUTPUT, read)
+    baseline = {findings[0].fingerprint()}
+
+    new = tidy_baseline.new_findings(findings, baseline, ['modernize-*', 'readability-*'])
+    self.assertEqual([f.check for f in new], ['readability-bad'])
+
+    # Findings of checks that are not errors are never new.
+    new = tidy_baseline.new_findings(findings, set(), ['modernize-*'])
+    self.assertEqual([f.check for f in new], ['modernize-use-nullptr'])
+
+
+if __name__ == '__main__':
+  unittest.main(verbosity=2)
@@ -30,6 +30,7 @@ import (
 func init() {
 	pctx.Import("android/soong/android")
 	pctx.Import("android/soong/java/config")
+	pctx.HostBinToolVariable("diffSdkSnapshotsCmd", "diff_sdk_snapshots")
 
 	registerSdkBuildComponents(android.InitRegistrationContext)
 }
@@ -75,6 +76,9 @@ type sdk struct {
 
 	infoFile android.OptionalPath
 
+	// The report of the differences from the snapshot in the generate_diff_against property, if set.
+	diffFile android.OptionalPath
+
 	// The builder, preserved for testing.
 	builderForTests *snapshotBuilder
 }
@@ -98,6 +102,11 @@ type sdkProperties struct {
 	//   dropped. Adding a rule to members that have //visibility:private will
 	//   cause the //visibility:private to be discarded.
 	Prebuilt_visibility []string
+
+	// A previous version of the snapshot of this module, against which the current snapshot is
+	// compared. If set then a report of the members and files that were added, removed or changed
+	// is generated as <name>-current.diff.txt and copied to dist alongside the snapshot.
+	Generate_diff_against *string `android:"path"`
 }
 
 // sdk defines an SDK which is a logical group of modules (e.g. native libs, headers, java libs, etc.)
@@ -202,10 +211,15 @@ func (s *sdk) AndroidMkEntries() []android.AndroidMkEntries {
 		return []android.AndroidMkEntries{}
 	}
 
+	distFiles := android.Paths{s.snapshotFile.Path(), s.infoFile.Path()}
+	if s.diffFile.Valid() {
+		distFiles = append(distFiles, s.diffFile.Path())
+	}
+
 	return []android.AndroidMkEntries{android.AndroidMkEntries{
 		Class:      "FAKE",
 		OutputFile: s.snapshotFile,
-		DistFiles:  android.MakeDefaultDistFiles(s.snapshotFile.Path(), s.infoFile.Path()),
+		DistFiles:  android.MakeDefaultDistFiles(distFiles...),
 		Include:    "$(BUILD_PHONY_PACKAGE)",
 		ExtraFooters: []android.AndroidMkExtraFootersFunc{
 			func(w io.Writer, name, prefix, moduleDir string) {
@@ -495,3 +495,40 @@ java_sdk_library_import {
 	})
 
 }
+
+func TestSnapshot_GenerateDiffAgainst(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForSdkTestWithJava,
+		android.FixtureAddFile("prebuilts/mysdk/mysdk-old.zip", nil),
+	).RunTestWithBp(t, `
+		sdk {
+			name: "mysdk",
+			java_header_libs: ["myjavalib"],
+			generate_diff_against: "prebuilts/mysdk/mysdk-old.zip",
+		}
+
+		java_library {
+			name: "myjavalib",
+			srcs: ["Test.java"],
+			system_modules: "none",
+			sdk_version: "none",
+			compile_dex: true,
+			host_supported: true,
+		}
+	`)
+
+	mysdk := result.ModuleForTests("mysdk", "common_os")
+	diffRule := mysdk.Rule("SnapshotDiff")
+	android.AssertPathRelativeToTopEquals(t, "new snapshot", "out/soong/.intermediates/mysdk/common_os/mysdk-current.zip", diffRule.Input)
+	android.AssertPathRelativeToTopEquals(t, "old snapshot", "prebuilts/mysdk/mysdk-old.zip", diffRule.Implicit)
+	android.AssertPathRelativeToTopEquals(t, "report", "out/soong/.intermediates/mysdk/common_os/mysdk-current.diff.txt", diffRule.Output)
+	android.AssertStringEquals(t, "old arg", "prebuilts/mysdk/mysdk-old.zip", diffRule.Args["old"])
+
+	// The report is copied to dist alongside the snapshot.
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, mysdk.Module())[0]
+	android.AssertPathsRelativeToTopEquals(t, "dist files", []string{
+		"out/soong/mainline-sdks/mysdk-current.zip",
+		"out/soong/mainline-sdks/mysdk-current.info",
+		"out/soong/.intermediates/mysdk/common_os/mysdk-current.diff.txt",
+	}, entries.DistFiles[android.DefaultDistTag])
+}
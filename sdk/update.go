@@ -78,6 +78,15 @@ var (
 				"${config.MergeZipsCmd}",
 			},
 		})
+
+	snapshotDiff = pctx.AndroidStaticRule("SnapshotDiff",
+		blueprint.RuleParams{
+			Command: `${diffSdkSnapshotsCmd} --old $old --new $in -o $out`,
+			CommandDeps: []string{
+				"${diffSdkSnapshotsCmd}",
+			},
+		},
+		"old")
 )
 
 const (
@@ -522,6 +531,22 @@ be unnecessary as every module in the sdk already has its own licenses property.
 		})
 	}
 
+	if s.properties.Generate_diff_against != nil {
+		oldZipFile := android.PathForModuleSrc(ctx, *s.properties.Generate_diff_against)
+		diffFile := android.PathForModuleOut(ctx, fmt.Sprintf("%s%s.diff.txt", ctx.ModuleName(), snapshotFileSuffix))
+		ctx.Build(pctx, android.BuildParams{
+			Description: "Comparing snapshot for " + ctx.ModuleName() + " against " + oldZipFile.String(),
+			Rule:        snapshotDiff,
+			Input:       outputZipFile,
+			Implicit:    oldZipFile,
+			Output:      diffFile,
+			Args: map[string]string{
+				"old": oldZipFile.String(),
+			},
+		})
+		s.diffFile = android.OptionalPathForPath(diffFile)
+	}
+
 	modules := s.generateInfoData(ctx, memberVariantDeps)
 
 	// Output the modules information as pretty printed JSON.
@@ -9,6 +9,7 @@ import (
 
 	"android/soong/android"
 	"android/soong/cc"
+	"android/soong/snapshot"
 )
 
 func TestMain(m *testing.M) {
@@ -255,3 +256,35 @@ func TestShTestHost_dataDeviceModulesAutogenTradefedConfig(t *testing.T) {
 		t.Errorf("foo extraConfings %v does not contain %q", autogen.Args["extraConfigs"], expectedBinAutogenConfig)
 	}
 }
+
+func TestShBinaryHostSnapshotPrebuiltBp(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForShTest,
+		snapshot.PrepareForTestWithHostSnapshot,
+	).RunTestWithBp(t, `
+		sh_binary_host {
+			name: "foo",
+			src: "test.sh",
+		}
+
+		host_snapshot {
+			name: "test-host-snapshot",
+			deps: ["foo"],
+		}
+	`)
+
+	snapshotModule := result.ModuleForTests("test-host-snapshot", result.Config.BuildOS.String()+"_common")
+	installDir := android.AndroidMkEntriesForTest(t, result.TestContext, snapshotModule.Module())[0].EntryMap["LOCAL_MODULE_PATH"][0]
+
+	// The sh host binary is given a prebuilt sh_binary_host definition in the snapshot.
+	bp := snapshotModule.Output("Android.bp")
+	android.AssertStringEquals(t, "Android.bp", `sh_binary_host {
+    name: "foo",
+    src: "`+installDir+`/bin/foo",
+    filename: "foo",
+}
+`, android.ContentFromFileRuleForTests(t, bp))
+
+	meta := snapshotModule.Output("test-host-snapshot_meta.zip")
+	android.AssertStringListContains(t, "meta zip inputs", meta.Inputs.Strings(), bp.Output.String())
+}
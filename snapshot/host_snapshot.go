@@ -41,6 +41,10 @@ import (
 //
 // To determine which tools to include in the host snapshot see
 // host_fake_snapshot.go.
+//
+// Rust and sh host binaries are also given prebuilt module definitions in an
+// Android.bp file in the snapshot, referencing the snapshotted binary and,
+// for rust binaries, the runtime dylibs it was packaged with.
 
 func init() {
 	registerHostBuildComponents(android.InitRegistrationContext)
@@ -50,6 +54,10 @@ func registerHostBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("host_snapshot", hostSnapshotFactory)
 }
 
+// PrepareForTestWithHostSnapshot registers the host_snapshot module type, for the tests of the
+// modules whose host tools are given prebuilt module definitions in the snapshot.
+var PrepareForTestWithHostSnapshot = android.FixtureRegisterWithContext(registerHostBuildComponents)
+
 // Relative installation path
 type RelativeInstallPath interface {
 	RelativeInstallPath() string
@@ -95,6 +103,7 @@ func (f *hostSnapshot) installFileName() string {
 func (f *hostSnapshot) CreateMetaData(ctx android.ModuleContext, fileName string) android.OutputPath {
 	var jsonData []SnapshotJsonFlags
 	var metaPaths android.Paths
+	var prebuiltBps []string
 
 	installedNotices := make(map[string]bool)
 	metaZipFile := android.PathForModuleOut(ctx, fileName).OutputPath
@@ -105,6 +114,9 @@ func (f *hostSnapshot) CreateMetaData(ctx android.ModuleContext, fileName string
 		if desc != nil {
 			jsonData = append(jsonData, *desc)
 		}
+		if bp := f.hostPrebuiltBp(ctx, dep); bp != "" {
+			prebuiltBps = append(prebuiltBps, bp)
+		}
 		for _, notice := range dep.EffectiveLicenseFiles() {
 			if _, ok := installedNotices[notice.String()]; !ok {
 				installedNotices[notice.String()] = true
@@ -130,6 +142,12 @@ func (f *hostSnapshot) CreateMetaData(ctx android.ModuleContext, fileName string
 
 	jsonZipFile := android.PathForModuleOut(ctx, "host_snapshot.json").OutputPath
 	metaPaths = append(metaPaths, jsonZipFile)
+	if len(prebuiltBps) > 0 {
+		sort.Strings(prebuiltBps)
+		bpFile := android.PathForModuleOut(ctx, "Android.bp").OutputPath
+		android.WriteFileRuleVerbatim(ctx, bpFile, strings.Join(prebuiltBps, "\n"))
+		metaPaths = append(metaPaths, bpFile)
+	}
 	rspFile := android.PathForModuleOut(ctx, "host_snapshot.rsp").OutputPath
 	android.WriteFileRule(ctx, jsonZipFile, string(marsh))
 
@@ -191,6 +209,64 @@ func (f *hostSnapshot) AndroidMkEntries() []android.AndroidMkEntries {
 	}}
 }
 
+// hostPrebuiltModuleTypes maps the types of the host tools that are given prebuilt module
+// definitions in the snapshot's Android.bp to the type of the prebuilt module.
+var hostPrebuiltModuleTypes = map[string]string{
+	"rust_binary_host": "prebuilt_build_tool",
+	"sh_binary_host":   "sh_binary_host",
+}
+
+// hostPrebuiltBp returns the prebuilt module definition of a host tool, or "" if the tool's type
+// is not in hostPrebuiltModuleTypes.  Paths are relative to the root of the snapshot, where the
+// packaged files are placed under the install directory.
+func (f *hostSnapshot) hostPrebuiltBp(ctx android.ModuleContext, m android.Module) string {
+	prebuiltType, ok := hostPrebuiltModuleTypes[ctx.OtherModuleType(m)]
+	path := hostToolPath(m)
+	if !ok || !path.Valid() {
+		return ""
+	}
+
+	// The tool is one of the module's own packaging specs, anything else packaged with it is a
+	// runtime dependency.
+	var src string
+	own := make(map[string]bool)
+	for _, spec := range m.PackagingSpecs() {
+		own[spec.RelPathInPackage()] = true
+		if spec.FileName() == path.Path().Base() {
+			src = spec.RelPathInPackage()
+		}
+	}
+	if src == "" {
+		return ""
+	}
+	var runtimeLibs []string
+	for _, spec := range m.TransitivePackagingSpecs() {
+		if !own[spec.RelPathInPackage()] {
+			runtimeLibs = append(runtimeLibs, filepath.Join(f.installDir.String(), spec.RelPathInPackage()))
+		}
+	}
+	runtimeLibs = android.SortedUniqueStrings(runtimeLibs)
+
+	bp := &strings.Builder{}
+	fmt.Fprintf(bp, "%s {\n", prebuiltType)
+	fmt.Fprintf(bp, "    name: %q,\n", ctx.OtherModuleName(m))
+	fmt.Fprintf(bp, "    src: %q,\n", filepath.Join(f.installDir.String(), src))
+	switch prebuiltType {
+	case "prebuilt_build_tool":
+		if len(runtimeLibs) > 0 {
+			fmt.Fprintf(bp, "    deps: [\n")
+			for _, lib := range runtimeLibs {
+				fmt.Fprintf(bp, "        %q,\n", lib)
+			}
+			fmt.Fprintf(bp, "    ],\n")
+		}
+	case "sh_binary_host":
+		fmt.Fprintf(bp, "    filename: %q,\n", filepath.Base(src))
+	}
+	fmt.Fprintf(bp, "}\n")
+	return bp.String()
+}
+
 // Get host tools path and relative install string helpers
 func hostToolPath(m android.Module) android.OptionalPath {
 	if provider, ok := m.(android.HostToolProvider); ok {
@@ -132,6 +132,33 @@ func autogenTemplate(ctx android.ModuleContext, name string, output android.Writ
 	})
 }
 
+var extendTestConfig = pctx.StaticRule("extendTestConfig", blueprint.RuleParams{
+	Command: "sed 's&</configuration>&'${extraConfigs}'\\n</configuration>&' $in > $out",
+}, "extraConfigs")
+
+// ExtendTestConfig writes a copy of the test config base with configs added at the end of its
+// configuration element to output.  Options at the top level of a configuration apply to every
+// object in it that has an option of the same name, e.g. the include-filter option of the test
+// runner.
+func ExtendTestConfig(ctx android.ModuleContext, base android.Path, output android.WritablePath, configs []Config) {
+	var configStrings []string
+	for _, config := range configs {
+		configStrings = append(configStrings, test_xml_indent+config.Config())
+	}
+	extraConfigs := strings.Join(configStrings, "\\n")
+	extraConfigs = proptools.NinjaAndShellEscape(extraConfigs)
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        extendTestConfig,
+		Description: "extend test config",
+		Input:       base,
+		Output:      output,
+		Args: map[string]string{
+			"extraConfigs": extraConfigs,
+		},
+	})
+}
+
 // AutoGenTestConfigOptions is used so that we can supply many optional
 // arguments to the AutoGenTestConfig function.
 type AutoGenTestConfigOptions struct {
@@ -0,0 +1,40 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tradefed
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// BaseTestProviderData is provided by test modules whose build artifacts can be reused by a
+// test_module_config module to run the test with different filters and options.
+type BaseTestProviderData struct {
+	// The file the test is installed from, e.g. the apk of an android_test or the binary of a
+	// cc_test.
+	OutputFile android.Path
+
+	// The tradefed config of the test.
+	TestConfig android.Path
+
+	// The data files installed alongside the test.
+	Data []android.DataPath
+
+	// The Android.mk class the test is installed with, e.g. APPS or NATIVE_TESTS.
+	AndroidMkClass string
+}
+
+var BaseTestProviderKey = blueprint.NewProvider(BaseTestProviderData{})
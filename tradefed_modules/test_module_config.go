@@ -0,0 +1,158 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tradefed_modules
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/tradefed"
+)
+
+func init() {
+	RegisterTestModuleConfigBuildComponents(android.InitRegistrationContext)
+}
+
+func RegisterTestModuleConfigBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterModuleType("test_module_config", TestModuleConfigFactory)
+}
+
+type testModuleConfigProperties struct {
+	// The android_test or cc_test module whose build artifacts are run with the filters and
+	// options of this module.  The base module is not rebuilt.
+	Base *string
+
+	// The tests to run, passed to the test runner as include-filter options, e.g.
+	// "android.foo.FooTest" or "android.foo.FooTest#testBar".
+	Include_filters []string
+
+	// The tests to skip, passed to the test runner as exclude-filter options.
+	Exclude_filters []string
+
+	// Extra options passed to the test runner, in the form "<name>=<value>".
+	Options []string
+
+	// list of compatibility suites (for example "cts", "vts") that this module is installed into
+	// under its own name.
+	Test_suites []string
+}
+
+// testModuleConfigModule runs the test of another module with different filters and options, e.g.
+// to run a subset of the test in presubmit.
+type testModuleConfigModule struct {
+	android.ModuleBase
+
+	properties testModuleConfigProperties
+
+	base       tradefed.BaseTestProviderData
+	testConfig android.Path
+}
+
+type testModuleConfigDependencyTag struct {
+	blueprint.BaseDependencyTag
+}
+
+var testModuleConfigTag = testModuleConfigDependencyTag{}
+
+// test_module_config runs an existing android_test or cc_test with its own include_filters,
+// exclude_filters and options.  It reuses the build artifacts of the base test, wraps its test
+// config with the extra options and installs them into test suites under its own name.
+func TestModuleConfigFactory() android.Module {
+	module := &testModuleConfigModule{}
+	module.AddProperties(&module.properties)
+	android.InitAndroidArchModule(module, android.DeviceSupported, android.MultilibCommon)
+	return module
+}
+
+func (m *testModuleConfigModule) DepsMutator(ctx android.BottomUpMutatorContext) {
+	base := proptools.String(m.properties.Base)
+	if base == "" {
+		ctx.PropertyErrorf("base", "is required")
+		return
+	}
+	// An android_test only has a common variant, while a cc_test has a variant per arch of which
+	// the primary one is run.
+	variations := ctx.Config().AndroidCommonTarget.Variations()
+	if !ctx.OtherModuleFarDependencyVariantExists(variations, base) {
+		variations = ctx.Config().AndroidFirstDeviceTarget.Variations()
+	}
+	ctx.AddFarVariationDependencies(variations, testModuleConfigTag, base)
+}
+
+func (m *testModuleConfigModule) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	ctx.VisitDirectDepsWithTag(testModuleConfigTag, func(dep android.Module) {
+		if !ctx.OtherModuleHasProvider(dep, tradefed.BaseTestProviderKey) {
+			ctx.PropertyErrorf("base", "%q is not an android_test or cc_test module", ctx.OtherModuleName(dep))
+			return
+		}
+		m.base = ctx.OtherModuleProvider(dep, tradefed.BaseTestProviderKey).(tradefed.BaseTestProviderData)
+		if m.base.TestConfig == nil {
+			ctx.PropertyErrorf("base", "%q does not have a test config", ctx.OtherModuleName(dep))
+		}
+	})
+	if m.base.OutputFile == nil || m.base.TestConfig == nil {
+		return
+	}
+
+	var configs []tradefed.Config
+	for _, filter := range m.properties.Include_filters {
+		configs = append(configs, tradefed.Option{Name: "include-filter", Value: filter})
+	}
+	for _, filter := range m.properties.Exclude_filters {
+		configs = append(configs, tradefed.Option{Name: "exclude-filter", Value: filter})
+	}
+	for _, option := range m.properties.Options {
+		parts := strings.SplitN(option, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			ctx.PropertyErrorf("options", "invalid option %q, expected <name>=<value>", option)
+			continue
+		}
+		configs = append(configs, tradefed.Option{Name: parts[0], Value: parts[1]})
+	}
+
+	testConfig := android.PathForModuleOut(ctx, ctx.ModuleName()+".config")
+	tradefed.ExtendTestConfig(ctx, m.base.TestConfig, testConfig, configs)
+	m.testConfig = testConfig
+}
+
+func (m *testModuleConfigModule) AndroidMkEntries() []android.AndroidMkEntries {
+	if m.testConfig == nil {
+		return []android.AndroidMkEntries{{
+			Disabled: true,
+		}}
+	}
+	return []android.AndroidMkEntries{{
+		Class:      m.base.AndroidMkClass,
+		OutputFile: android.OptionalPathForPath(m.base.OutputFile),
+		ExtraEntries: []android.AndroidMkExtraEntriesFunc{
+			func(ctx android.AndroidMkExtraEntriesContext, entries *android.AndroidMkEntries) {
+				// The artifacts of the base module are installed under the name of this module, but
+				// keep their file name so that the test config of the base module still finds them.
+				entries.SetString("LOCAL_INSTALLED_MODULE_STEM", m.base.OutputFile.Base())
+				entries.SetString("LOCAL_MODULE_TAGS", "tests")
+				if m.base.AndroidMkClass == "APPS" {
+					// The apk was already signed by the base module.
+					entries.SetString("LOCAL_CERTIFICATE", "PRESIGNED")
+				}
+				entries.SetPath("LOCAL_FULL_TEST_CONFIG", m.testConfig)
+				entries.AddCompatibilityTestSuites(m.properties.Test_suites...)
+				entries.AddStrings("LOCAL_TEST_DATA", android.AndroidMkDataPaths(m.base.Data)...)
+			},
+		},
+	}}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tradefed_modules
+
+import (
+	"testing"
+
+	"android/soong/android"
+	"android/soong/cc"
+	"android/soong/java"
+	"android/soong/tradefed"
+)
+
+var prepareForTestModuleConfigTest = android.GroupFixturePreparers(
+	java.PrepareForTestWithJavaDefaultModules,
+	cc.PrepareForTestWithCcDefaultModules,
+	android.PrepareForTestWithAndroidMk,
+	android.FixtureRegisterWithContext(RegisterTestModuleConfigBuildComponents),
+)
+
+func TestTestModuleConfigAndroidTest(t *testing.T) {
+	result := prepareForTestModuleConfigTest.RunTestWithBp(t, `
+		android_test {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+		}
+
+		test_module_config {
+			name: "foo_presubmit",
+			base: "foo",
+			include_filters: ["android.foo.FooTest"],
+			exclude_filters: ["android.foo.FooTest#testFlaky"],
+			options: ["timeout=10m"],
+			test_suites: ["general-tests"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common").Module()
+	base := result.ModuleProvider(foo, tradefed.BaseTestProviderKey).(tradefed.BaseTestProviderData)
+
+	m := result.ModuleForTests("foo_presubmit", "android_common")
+	if m.MaybeRule("javac").Rule != nil {
+		t.Errorf("expected foo_presubmit not to compile foo again")
+	}
+
+	config := m.Output("foo_presubmit.config")
+	android.AssertStringEquals(t, "base test config", base.TestConfig.String(), config.Input.String())
+	extraConfigs := config.Args["extraConfigs"]
+	android.AssertStringDoesContain(t, "extra configs", extraConfigs, `name="include-filter" value="android.foo.FooTest"`)
+	android.AssertStringDoesContain(t, "extra configs", extraConfigs, `name="exclude-filter" value="android.foo.FooTest#testFlaky"`)
+	android.AssertStringDoesContain(t, "extra configs", extraConfigs, `name="timeout" value="10m"`)
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, m.Module())[0]
+	android.AssertStringEquals(t, "class", "APPS", entries.Class)
+	android.AssertStringEquals(t, "output file", base.OutputFile.String(), entries.OutputFile.Path().String())
+	android.AssertStringPathsRelativeToTopEquals(t, "test config", result.Config,
+		[]string{"out/soong/.intermediates/foo_presubmit/android_common/foo_presubmit.config"},
+		entries.EntryMap["LOCAL_FULL_TEST_CONFIG"])
+	android.AssertDeepEquals(t, "certificate", []string{"PRESIGNED"}, entries.EntryMap["LOCAL_CERTIFICATE"])
+	android.AssertDeepEquals(t, "test suites", []string{"general-tests"}, entries.EntryMap["LOCAL_COMPATIBILITY_SUITE"])
+}
+
+func TestTestModuleConfigCcTest(t *testing.T) {
+	result := prepareForTestModuleConfigTest.RunTestWithBp(t, `
+		cc_test {
+			name: "bar",
+			srcs: ["bar.cpp"],
+			gtest: false,
+		}
+
+		test_module_config {
+			name: "bar_presubmit",
+			base: "bar",
+			include_filters: ["BarTest.*"],
+		}
+	`)
+
+	bar := result.ModuleForTests("bar", "android_arm64_armv8-a").Module()
+	base := result.ModuleProvider(bar, tradefed.BaseTestProviderKey).(tradefed.BaseTestProviderData)
+
+	m := result.ModuleForTests("bar_presubmit", "android_common")
+	if m.MaybeRule("cc").Rule != nil || m.MaybeRule("ld").Rule != nil {
+		t.Errorf("expected bar_presubmit not to build bar again")
+	}
+
+	config := m.Output("bar_presubmit.config")
+	android.AssertStringEquals(t, "base test config", base.TestConfig.String(), config.Input.String())
+
+	entries := android.AndroidMkEntriesForTest(t, result.TestContext, m.Module())[0]
+	android.AssertStringEquals(t, "class", "NATIVE_TESTS", entries.Class)
+	android.AssertStringEquals(t, "output file", base.OutputFile.String(), entries.OutputFile.Path().String())
+}
+
+func TestTestModuleConfigInvalidBase(t *testing.T) {
+	prepareForTestModuleConfigTest.
+		ExtendWithErrorHandler(android.FixtureExpectsOneErrorPattern(`"bar" is not an android_test or cc_test module`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "bar",
+				srcs: ["a.java"],
+				sdk_version: "current",
+			}
+
+			test_module_config {
+				name: "bar_presubmit",
+				base: "bar",
+			}
+		`)
+}
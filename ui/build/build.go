@@ -300,7 +300,9 @@ func Build(ctx Context, config Config) {
 	if what&RunKati != 0 {
 		genKatiSuffix(ctx, config)
 		runKatiCleanSpec(ctx, config)
+		checkDuplicateMakeModules(ctx, config)
 		runKatiBuild(ctx, config)
+		checkInterfaceOutputs(ctx, config)
 		runKatiPackage(ctx, config)
 
 		ioutil.WriteFile(config.LastKatiSuffixFile(), []byte(config.KatiSuffix()), 0666) // a+rw
@@ -326,6 +328,7 @@ func Build(ctx Context, config Config) {
 		if what&RunKati != 0 {
 			installCleanIfNecessary(ctx, config)
 		}
+		checkDiskSpace(ctx, config)
 		runNinjaForBuild(ctx, config)
 	}
 
@@ -78,6 +78,7 @@ type configImpl struct {
 	queryview         bool
 	reportMkMetrics   bool // Collect and report mk2bp migration progress metrics.
 	soongDocs         bool
+	soongQuery        bool // Answer the query in SOONG_QUERY over the module graph.
 	multitreeBuild    bool // This is a multitree build.
 	skipConfig        bool
 	skipKati          bool
@@ -105,6 +106,9 @@ type configImpl struct {
 	brokenUsesNetwork  bool
 	brokenNinjaEnvVars []string
 
+	brokenDuplicateMakeModules          bool
+	brokenDuplicateMakeModulesAllowlist []string
+
 	pathReplaced bool
 
 	bazelProdMode    bool
@@ -925,6 +929,8 @@ func (c *configImpl) parseArgs(ctx Context, args []string) {
 			c.queryview = true
 		} else if arg == "soong_docs" {
 			c.soongDocs = true
+		} else if arg == "soong_query" {
+			c.soongQuery = true
 		} else {
 			if arg == "checkbuild" {
 				c.checkbuild = true
@@ -1020,7 +1026,7 @@ func (c *configImpl) SoongBuildInvocationNeeded() bool {
 		return true
 	}
 
-	if !c.JsonModuleGraph() && !c.Bp2Build() && !c.Queryview() && !c.SoongDocs() && !c.ApiBp2build() {
+	if !c.JsonModuleGraph() && !c.Bp2Build() && !c.Queryview() && !c.SoongDocs() && !c.SoongQuery() && !c.ApiBp2build() {
 		// Command line was empty, the default Ninja target is built
 		return true
 	}
@@ -1111,6 +1117,10 @@ func (c *configImpl) SoongDocsHtml() string {
 	return shared.JoinPath(c.SoongOutDir(), "docs/soong_build.html")
 }
 
+func (c *configImpl) SoongQueryResultFile() string {
+	return shared.JoinPath(c.SoongOutDir(), "query_result.txt")
+}
+
 func (c *configImpl) QueryviewMarkerFile() string {
 	return shared.JoinPath(c.SoongOutDir(), "queryview.marker")
 }
@@ -1172,6 +1182,10 @@ func (c *configImpl) SoongDocs() bool {
 	return c.soongDocs
 }
 
+func (c *configImpl) SoongQuery() bool {
+	return c.soongQuery
+}
+
 func (c *configImpl) IsVerbose() bool {
 	return c.verbose
 }
@@ -1551,6 +1565,11 @@ func (c *configImpl) SoongNinjaFile() string {
 	return filepath.Join(c.SoongOutDir(), "build.ninja")
 }
 
+// SoongBuildProgressFile returns the status file that soong_build reports its analysis progress to.
+func (c *configImpl) SoongBuildProgressFile() string {
+	return filepath.Join(c.SoongOutDir(), ".soong_build_progress")
+}
+
 func (c *configImpl) CombinedNinjaFile() string {
 	if c.katiSuffix == "" {
 		return filepath.Join(c.OutDir(), "combined.ninja")
@@ -1641,6 +1660,22 @@ func (c *configImpl) BuildBrokenNinjaUsesEnvVars() []string {
 	return c.brokenNinjaEnvVars
 }
 
+func (c *configImpl) SetBuildBrokenDuplicateMakeModules(val bool) {
+	c.brokenDuplicateMakeModules = val
+}
+
+func (c *configImpl) BuildBrokenDuplicateMakeModules() bool {
+	return c.brokenDuplicateMakeModules
+}
+
+func (c *configImpl) SetBuildBrokenDuplicateMakeModulesAllowlist(val []string) {
+	c.brokenDuplicateMakeModulesAllowlist = val
+}
+
+func (c *configImpl) BuildBrokenDuplicateMakeModulesAllowlist() []string {
+	return c.brokenDuplicateMakeModulesAllowlist
+}
+
 func (c *configImpl) SetTargetDeviceDir(dir string) {
 	c.targetDeviceDir = dir
 }
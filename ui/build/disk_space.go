@@ -0,0 +1,338 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Builds that run out of disk space fail hours in with confusing errors from whichever action
+// happened to be writing at the time.  Before running ninja, soong_ui estimates the size of a
+// fully built out directory from the number of modules of each module type that soong_build
+// recorded in the previous build, and aborts if the filesystem holding the out directory cannot
+// hold the part of the estimate that is not built yet plus a safety margin.
+//
+// When the free space is not enough to build the whole out directory from scratch, the size of the
+// existing out directory is needed.  Walking a large out directory takes a long time, so its size
+// is cached along with the free space at the time of the walk.  Later builds use the cached size,
+// less any space that was freed since, as a lower bound of the size of the out directory, and only
+// walk it again when the lower bound is not enough to pass the check.
+//
+// The check is controlled by the following environment variables:
+//
+//   SKIP_DISK_SPACE_CHECK=true skips the check entirely.
+//   DISK_SPACE_MARGIN_GB sets the margin, 10GB by default.
+//   DISK_SPACE_COEFFICIENTS overrides the estimated size of each module of a module type, as a
+//     comma separated list of <module type>=<MB> entries.  The "default" entry applies to the
+//     module types without a coefficient.
+
+const (
+	// moduleTypeCountsFilename is the file in the soong out directory that soong_build writes the
+	// module counts of each module type to.
+	moduleTypeCountsFilename = "module_type_counts.txt"
+
+	// outDirSizeCacheFilename is the file in the soong out directory that caches the size of the
+	// out directory and the free space when it was computed.
+	outDirSizeCacheFilename = "out_dir_size_cache.txt"
+
+	skipDiskSpaceCheckEnvVar    = "SKIP_DISK_SPACE_CHECK"
+	diskSpaceMarginEnvVar       = "DISK_SPACE_MARGIN_GB"
+	diskSpaceCoefficientsEnvVar = "DISK_SPACE_COEFFICIENTS"
+
+	defaultDiskSpaceMarginGB = 10
+
+	// defaultCoefficientKey is the key of the coefficient for module types without one.
+	defaultCoefficientKey = "default"
+
+	mb = uint64(1) << 20
+	gb = uint64(1) << 30
+)
+
+// defaultDiskSpaceCoefficients are the estimated sizes in MB of the outputs of a module of each
+// module type, including all of its variants and intermediates.
+var defaultDiskSpaceCoefficients = map[string]uint64{
+	defaultCoefficientKey: 2,
+
+	"android_app":          40,
+	"android_test":         40,
+	"apex":                 200,
+	"cc_binary":            20,
+	"cc_library":           40,
+	"cc_library_shared":    25,
+	"cc_library_static":    15,
+	"cc_test":              30,
+	"droidstubs":           100,
+	"java_library":         20,
+	"java_sdk_library":     150,
+	"java_test_host":       20,
+	"rust_binary":          30,
+	"rust_library":         40,
+	"rust_test":            40,
+	"tradefed_binary_host": 20,
+}
+
+// diskSpaceEstimate is the estimated disk space that a build requires.
+type diskSpaceEstimate struct {
+	// outDirSize is the estimated size in bytes of the fully built out directory.
+	outDirSize uint64
+
+	// margin is the free space in bytes that must remain after the build.
+	margin uint64
+}
+
+// parseModuleTypeCounts parses the "<module type> <count>" lines written by soong_build.
+func parseModuleTypeCounts(r io.Reader) (map[string]int, error) {
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid module type count %q", line)
+		}
+		count, err := strconv.Atoi(fields[1])
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid module type count %q", line)
+		}
+		counts[fields[0]] += count
+	}
+	return counts, scanner.Err()
+}
+
+// parseDiskSpaceCoefficients parses a comma separated list of <module type>=<MB> entries, and
+// returns the coefficients in MB merged over the defaults.
+func parseDiskSpaceCoefficients(s string, defaults map[string]uint64) (map[string]uint64, error) {
+	coefficients := make(map[string]uint64, len(defaults))
+	for moduleType, coefficient := range defaults {
+		coefficients[moduleType] = coefficient
+	}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid disk space coefficient %q, expected <module type>=<MB>", entry)
+		}
+		coefficient, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid disk space coefficient %q, expected <module type>=<MB>", entry)
+		}
+		coefficients[parts[0]] = coefficient
+	}
+	return coefficients, nil
+}
+
+// estimateOutDirSize returns the estimated size in bytes of a fully built out directory for the
+// given module counts, using the coefficients in MB of each module type.
+func estimateOutDirSize(counts map[string]int, coefficients map[string]uint64) uint64 {
+	var size uint64
+	for moduleType, count := range counts {
+		coefficient, ok := coefficients[moduleType]
+		if !ok {
+			coefficient = coefficients[defaultCoefficientKey]
+		}
+		size += uint64(count) * coefficient * mb
+	}
+	return size
+}
+
+// requiredFreeSpace returns the free space in bytes needed to complete the out directory that
+// currently takes outDirSize bytes.  The outputs that already exist are assumed to be rewritten
+// in place, so only the part of the estimate that is not built yet is required on top of the
+// margin.
+func (e diskSpaceEstimate) requiredFreeSpace(outDirSize uint64) uint64 {
+	if outDirSize >= e.outDirSize {
+		return e.margin
+	}
+	return e.outDirSize - outDirSize + e.margin
+}
+
+// diskSpaceChecker checks the disk space available to the out directory, with the filesystem
+// queries injectable for tests.
+type diskSpaceChecker struct {
+	// freeSpace returns the free space in bytes of the filesystem that holds dir.
+	freeSpace func(dir string) (uint64, error)
+
+	// dirSize returns the total size in bytes of the files in dir.
+	dirSize func(dir string) (uint64, error)
+}
+
+var defaultDiskSpaceChecker = diskSpaceChecker{
+	freeSpace: detectFreeSpace,
+	dirSize:   detectDirSize,
+}
+
+func detectFreeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+func detectDirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files may be removed while walking a live out directory.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return size, err
+}
+
+// checkDiskSpace aborts the build if the filesystem holding the out directory does not have
+// enough free space to complete the build.
+func checkDiskSpace(ctx Context, config Config) {
+	if err := defaultDiskSpaceChecker.check(ctx, config); err != nil {
+		ctx.Fatalln(err)
+	}
+}
+
+func (c diskSpaceChecker) check(ctx Context, config Config) error {
+	if config.Environment().IsEnvTrue(skipDiskSpaceCheckEnvVar) {
+		ctx.Verbosef("%s is set, skipping the disk space check", skipDiskSpaceCheckEnvVar)
+		return nil
+	}
+
+	countsFile := filepath.Join(config.SoongOutDir(), moduleTypeCountsFilename)
+	f, err := os.Open(countsFile)
+	if os.IsNotExist(err) {
+		// The first build has no module counts to estimate from.
+		ctx.Verbosef("%s does not exist, skipping the disk space check", countsFile)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	counts, err := parseModuleTypeCounts(f)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %s", countsFile, err)
+	}
+
+	coefficientsString, _ := config.Environment().Get(diskSpaceCoefficientsEnvVar)
+	coefficients, err := parseDiskSpaceCoefficients(coefficientsString, defaultDiskSpaceCoefficients)
+	if err != nil {
+		return fmt.Errorf("%s: %s", diskSpaceCoefficientsEnvVar, err)
+	}
+
+	marginGB := defaultDiskSpaceMarginGB
+	if _, ok := config.Environment().Get(diskSpaceMarginEnvVar); ok {
+		var valid bool
+		if marginGB, valid = config.Environment().GetInt(diskSpaceMarginEnvVar); !valid || marginGB < 0 {
+			return fmt.Errorf("%s must be a non-negative number of GB", diskSpaceMarginEnvVar)
+		}
+	}
+
+	estimate := diskSpaceEstimate{
+		outDirSize: estimateOutDirSize(counts, coefficients),
+		margin:     uint64(marginGB) * gb,
+	}
+
+	outDir := config.OutDir()
+	free, err := c.freeSpace(outDir)
+	if err != nil {
+		ctx.Verbosef("failed to detect the free space of %s, skipping the disk space check: %s", outDir, err)
+		return nil
+	}
+
+	// Walking the out directory is expensive, skip it when the free space is enough to build the
+	// whole out directory from scratch.
+	if free >= estimate.requiredFreeSpace(0) {
+		return nil
+	}
+
+	cacheFile := filepath.Join(config.SoongOutDir(), outDirSizeCacheFilename)
+	outDirSize, cached := readOutDirSizeCache(cacheFile, free)
+	if !cached || free < estimate.requiredFreeSpace(outDirSize) {
+		outDirSize, err = c.dirSize(outDir)
+		if err != nil {
+			ctx.Verbosef("failed to compute the size of %s, skipping the disk space check: %s", outDir, err)
+			return nil
+		}
+		if err := writeOutDirSizeCache(cacheFile, outDirSize, free); err != nil {
+			ctx.Verbosef("failed to write %s: %s", cacheFile, err)
+		}
+	}
+
+	if required := estimate.requiredFreeSpace(outDirSize); free < required {
+		return fmt.Errorf("not enough disk space: the filesystem holding %s has %s free, but the build is estimated to "+
+			"need %s (%s for the out directory, of which %s is already built, and a %s margin).\n"+
+			"Free up disk space, or set %s to change the margin or %s=true to skip this check.",
+			outDir, formatDiskSpace(free), formatDiskSpace(required), formatDiskSpace(estimate.outDirSize),
+			formatDiskSpace(outDirSize), formatDiskSpace(estimate.margin),
+			diskSpaceMarginEnvVar, skipDiskSpaceCheckEnvVar)
+	}
+	return nil
+}
+
+// readOutDirSizeCache returns a lower bound of the size of the out directory, which is the cached
+// size less the space that was freed since it was computed, as the freed space may have been part
+// of the out directory.  It returns false if there is no valid cache.
+func readOutDirSizeCache(cacheFile string, free uint64) (uint64, bool) {
+	contents, err := os.ReadFile(cacheFile)
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(contents))
+	if len(fields) != 2 {
+		return 0, false
+	}
+	size, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	cachedFree, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if free > cachedFree {
+		if free-cachedFree > size {
+			return 0, true
+		}
+		return size - (free - cachedFree), true
+	}
+	return size, true
+}
+
+func writeOutDirSizeCache(cacheFile string, size, free uint64) error {
+	return os.WriteFile(cacheFile, []byte(fmt.Sprintf("%d %d\n", size, free)), 0666)
+}
+
+func formatDiskSpace(bytes uint64) string {
+	return fmt.Sprintf("%.1fGB", float64(bytes)/float64(gb))
+}
@@ -0,0 +1,318 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseModuleTypeCounts(t *testing.T) {
+	counts, err := parseModuleTypeCounts(strings.NewReader("cc_library 3\n\njava_library 2\ncc_library 1\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]int{"cc_library": 4, "java_library": 2}; !reflect.DeepEqual(expected, counts) {
+		t.Errorf("expected %v, got %v", expected, counts)
+	}
+
+	for _, invalid := range []string{"cc_library\n", "cc_library three\n", "cc_library -1\n", "cc_library 1 2\n"} {
+		if _, err := parseModuleTypeCounts(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestParseDiskSpaceCoefficients(t *testing.T) {
+	defaults := map[string]uint64{"default": 2, "cc_library": 40}
+
+	coefficients, err := parseDiskSpaceCoefficients("", defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(defaults, coefficients) {
+		t.Errorf("expected %v, got %v", defaults, coefficients)
+	}
+
+	coefficients, err = parseDiskSpaceCoefficients("cc_library=10, apex=300,default=1", defaults)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := map[string]uint64{"default": 1, "cc_library": 10, "apex": 300}; !reflect.DeepEqual(expected, coefficients) {
+		t.Errorf("expected %v, got %v", expected, coefficients)
+	}
+	if defaults["cc_library"] != 40 {
+		t.Errorf("the defaults must not be modified")
+	}
+
+	for _, invalid := range []string{"cc_library", "=10", "cc_library=-1", "cc_library=1GB"} {
+		if _, err := parseDiskSpaceCoefficients(invalid, defaults); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestEstimateDiskSpace(t *testing.T) {
+	coefficients := map[string]uint64{"default": 2, "cc_library": 40, "apex": 200}
+	counts := map[string]int{"cc_library": 10, "apex": 2, "genrule": 50}
+
+	// 10 * 40MB + 2 * 200MB + 50 * 2MB
+	if expected, got := 900*mb, estimateOutDirSize(counts, coefficients); expected != got {
+		t.Errorf("expected an estimate of %d, got %d", expected, got)
+	}
+
+	estimate := diskSpaceEstimate{outDirSize: 900 * mb, margin: 1 * gb}
+	testCases := []struct {
+		name       string
+		outDirSize uint64
+		expected   uint64
+	}{
+		{"empty out dir", 0, 900*mb + gb},
+		{"partially built out dir", 300 * mb, 600*mb + gb},
+		{"fully built out dir", 900 * mb, gb},
+		{"out dir larger than the estimate", 2 * gb, gb},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := estimate.requiredFreeSpace(tt.outDirSize); tt.expected != got {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	testCases := []struct {
+		name       string
+		env        map[string]string
+		free       uint64
+		outDirSize uint64
+		// walks is whether the check must compute the size of the out directory.
+		walks         bool
+		expectedError string
+	}{
+		{
+			name: "enough space to build from scratch",
+			// 100 * 40MB + a 10GB margin
+			free: 4000*mb + 10*gb,
+		},
+		{
+			name:       "enough space to complete the out dir",
+			free:       3000*mb + 10*gb,
+			outDirSize: 1000 * mb,
+			walks:      true,
+		},
+		{
+			name:          "not enough space",
+			free:          2999*mb + 10*gb,
+			outDirSize:    1000 * mb,
+			walks:         true,
+			expectedError: "not enough disk space: the filesystem holding ",
+		},
+		{
+			name:       "smaller margin",
+			env:        map[string]string{"DISK_SPACE_MARGIN_GB": "1"},
+			free:       3000*mb + 1*gb,
+			outDirSize: 1000 * mb,
+			walks:      true,
+		},
+		{
+			name: "coefficients override",
+			env:  map[string]string{"DISK_SPACE_COEFFICIENTS": "cc_library=1"},
+			free: 100*mb + 10*gb,
+		},
+		{
+			name: "skip",
+			env:  map[string]string{"SKIP_DISK_SPACE_CHECK": "true"},
+		},
+		{
+			name:          "invalid margin",
+			env:           map[string]string{"DISK_SPACE_MARGIN_GB": "lots"},
+			expectedError: "DISK_SPACE_MARGIN_GB must be a non-negative number of GB",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			outDir := t.TempDir()
+			soongOutDir := filepath.Join(outDir, "soong")
+			if err := os.MkdirAll(soongOutDir, 0777); err != nil {
+				t.Fatal(err)
+			}
+			countsFile := filepath.Join(soongOutDir, moduleTypeCountsFilename)
+			if err := os.WriteFile(countsFile, []byte("cc_library 100\n"), 0666); err != nil {
+				t.Fatal(err)
+			}
+
+			env := Environment([]string{"OUT_DIR=" + outDir})
+			for k, v := range tt.env {
+				env.Set(k, v)
+			}
+			config := Config{&configImpl{environ: &env}}
+
+			skipped := tt.env["SKIP_DISK_SPACE_CHECK"] == "true"
+			walked := false
+			checker := diskSpaceChecker{
+				freeSpace: func(dir string) (uint64, error) {
+					if skipped {
+						t.Errorf("unexpected free space query with the check skipped")
+					}
+					if dir != outDir {
+						t.Errorf("expected a free space query for %q, got %q", outDir, dir)
+					}
+					return tt.free, nil
+				},
+				dirSize: func(dir string) (uint64, error) {
+					walked = true
+					return tt.outDirSize, nil
+				},
+			}
+
+			err := checker.check(testContext(), config)
+			if tt.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected an error containing %q, got %v", tt.expectedError, err)
+			}
+			if walked != tt.walks {
+				t.Errorf("expected the out dir to be walked: %t, walked: %t", tt.walks, walked)
+			}
+		})
+	}
+}
+
+func TestCheckDiskSpaceWithoutModuleTypeCounts(t *testing.T) {
+	env := Environment([]string{"OUT_DIR=" + t.TempDir()})
+	config := Config{&configImpl{environ: &env}}
+
+	checker := diskSpaceChecker{
+		freeSpace: func(dir string) (uint64, error) {
+			t.Errorf("unexpected free space query without module type counts")
+			return 0, nil
+		},
+	}
+	if err := checker.check(testContext(), config); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestCheckDiskSpaceCachesOutDirSize(t *testing.T) {
+	outDir := t.TempDir()
+	soongOutDir := filepath.Join(outDir, "soong")
+	if err := os.MkdirAll(soongOutDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	countsFile := filepath.Join(soongOutDir, moduleTypeCountsFilename)
+	if err := os.WriteFile(countsFile, []byte("cc_library 100\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	env := Environment([]string{"OUT_DIR=" + outDir})
+	config := Config{&configImpl{environ: &env}}
+
+	// 100 * 40MB are needed for the out directory and 10GB for the margin.
+	var free, outDirSize uint64
+	walks := 0
+	checker := diskSpaceChecker{
+		freeSpace: func(dir string) (uint64, error) { return free, nil },
+		dirSize: func(dir string) (uint64, error) {
+			walks++
+			return outDirSize, nil
+		},
+	}
+	check := func(expectedWalks int, expectedError bool) {
+		t.Helper()
+		walks = 0
+		err := checker.check(testContext(), config)
+		if expectedError != (err != nil) {
+			t.Errorf("expected an error: %t, got %v", expectedError, err)
+		}
+		if walks != expectedWalks {
+			t.Errorf("expected %d walks of the out dir, got %d", expectedWalks, walks)
+		}
+	}
+
+	// The first check walks the out directory.
+	free, outDirSize = 3000*mb+10*gb, 1000*mb
+	check(1, false)
+
+	// The cached size less the freed space is enough to pass the check.
+	free = 3500*mb + 10*gb
+	check(0, false)
+	free, outDirSize = 3000*mb+10*gb, 1500*mb
+	check(0, false)
+
+	// The cached size is not enough to pass the check, so the out directory is walked again.
+	free = 2600*mb + 10*gb
+	check(1, false)
+
+	// The check only fails after walking the out directory.
+	free = 2000*mb + 10*gb
+	check(1, true)
+}
+
+func TestReadOutDirSizeCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), outDirSizeCacheFilename)
+	if _, ok := readOutDirSizeCache(cacheFile, 0); ok {
+		t.Errorf("unexpected cache without a cache file")
+	}
+
+	if err := writeOutDirSizeCache(cacheFile, 1000, 500); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		free, expected uint64
+	}{
+		{free: 500, expected: 1000},
+		{free: 200, expected: 1000},
+		{free: 800, expected: 700},
+		{free: 2000, expected: 0},
+	} {
+		if size, ok := readOutDirSizeCache(cacheFile, tt.free); !ok || size != tt.expected {
+			t.Errorf("expected a cached size of %d with %d free, got %d, %t", tt.expected, tt.free, size, ok)
+		}
+	}
+
+	if err := os.WriteFile(cacheFile, []byte("invalid\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readOutDirSizeCache(cacheFile, 0); ok {
+		t.Errorf("unexpected cache with an invalid cache file")
+	}
+}
+
+func TestDetectDirSize(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	for file, size := range map[string]int{"foo": 10, "a/bar": 20, "a/b/baz": 30} {
+		if err := os.WriteFile(filepath.Join(dir, file), make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size, err := detectDirSize(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if size != 60 {
+		t.Errorf("expected a size of 60, got %d", size)
+	}
+}
@@ -230,6 +230,10 @@ func runMakeProductConfig(ctx Context, config Config) {
 		// Extra environment variables to be exported to ninja
 		"BUILD_BROKEN_NINJA_USES_ENV_VARS",
 
+		// Modules that may be defined both in Android.bp and Android.mk files
+		"BUILD_BROKEN_DUPLICATE_MAKE_MODULES",
+		"BUILD_BROKEN_DUPLICATE_MAKE_MODULES_ALLOWLIST",
+
 		// Used to restrict write access to source tree
 		"BUILD_BROKEN_SRC_DIR_IS_WRITABLE",
 		"BUILD_BROKEN_SRC_DIR_RW_ALLOWLIST",
@@ -299,6 +303,8 @@ func runMakeProductConfig(ctx Context, config Config) {
 	config.SetBuildBrokenDupRules(makeVars["BUILD_BROKEN_DUP_RULES"] == "true")
 	config.SetBuildBrokenUsesNetwork(makeVars["BUILD_BROKEN_USES_NETWORK"] == "true")
 	config.SetBuildBrokenNinjaUsesEnvVars(strings.Fields(makeVars["BUILD_BROKEN_NINJA_USES_ENV_VARS"]))
+	config.SetBuildBrokenDuplicateMakeModules(makeVars["BUILD_BROKEN_DUPLICATE_MAKE_MODULES"] == "true")
+	config.SetBuildBrokenDuplicateMakeModulesAllowlist(strings.Fields(makeVars["BUILD_BROKEN_DUPLICATE_MAKE_MODULES_ALLOWLIST"]))
 	config.SetIncludeTags(strings.Fields(makeVars["PRODUCT_INCLUDE_TAGS"]))
 	config.SetSourceRootDirs(strings.Fields(makeVars["PRODUCT_SOURCE_ROOT_DIRS"]))
 }
@@ -0,0 +1,157 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A module that is defined both in an Android.bp file and, with the same LOCAL_MODULE, in an
+// Android.mk file surfaces as a confusing override in Kati.  Before Kati runs, soong_ui reads the
+// LOCAL_MODULE definitions of the Android.mk files that Kati includes, listed in Android.mk.list,
+// and of the Android-<product>.mk file that Soong writes for the modules it exports to Make, and
+// fails the build for each module name that is defined in both, listing both definitions.
+//
+// The modules in BUILD_BROKEN_DUPLICATE_MAKE_MODULES_ALLOWLIST are not reported, and setting
+// BUILD_BROKEN_DUPLICATE_MAKE_MODULES=true reports the collisions as warnings.
+
+// makeModuleDefinition is an assignment of LOCAL_MODULE in a makefile.
+type makeModuleDefinition struct {
+	name string
+
+	// makefile and line locate the assignment.
+	makefile string
+	line     int
+
+	// localPath is the value of LOCAL_PATH when LOCAL_MODULE was assigned, or "" if it was not
+	// assigned a literal value.
+	localPath string
+}
+
+// makeAssignmentRegexp matches the assignments of LOCAL_MODULE and LOCAL_PATH.
+var makeAssignmentRegexp = regexp.MustCompile(`^\s*(LOCAL_MODULE|LOCAL_PATH)\s*:?=\s*(.*?)\s*$`)
+
+// parseMakeModuleDefinitions returns the assignments of literal module names to LOCAL_MODULE in
+// the contents of makefile.  The names that refer to make variables or functions are skipped, as
+// they can only be known by evaluating the makefile.
+func parseMakeModuleDefinitions(makefile, contents string) []makeModuleDefinition {
+	var definitions []makeModuleDefinition
+	localPath := ""
+	lines := strings.Split(contents, "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := lines[i]
+		// Join the continuation lines.
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(line, "\\") + " " + lines[i]
+		}
+		if comment := strings.Index(line, "#"); comment >= 0 {
+			line = line[:comment]
+		}
+
+		match := makeAssignmentRegexp.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		value := match[2]
+		literal := !strings.Contains(value, "$") && len(strings.Fields(value)) == 1
+		switch match[1] {
+		case "LOCAL_PATH":
+			localPath = ""
+			if literal {
+				localPath = value
+			}
+		case "LOCAL_MODULE":
+			if literal {
+				definitions = append(definitions, makeModuleDefinition{
+					name:      value,
+					makefile:  makefile,
+					line:      lineNumber,
+					localPath: localPath,
+				})
+			}
+		}
+	}
+	return definitions
+}
+
+// duplicateMakeModules returns a description of each module name that is defined both by Soong
+// and in an Android.mk file, except for the names in the allowlist.
+func duplicateMakeModules(soongModules, makeModules []makeModuleDefinition, allowlist []string) []string {
+	// Soong defines a module once per variant, all in the same directory.
+	blueprintFiles := make(map[string]string)
+	for _, m := range soongModules {
+		blueprintFiles[m.name] = filepath.Join(m.localPath, "Android.bp")
+	}
+
+	locations := make(map[string][]string)
+	for _, m := range makeModules {
+		if _, exists := blueprintFiles[m.name]; !exists || inList(m.name, allowlist) {
+			continue
+		}
+		locations[m.name] = append(locations[m.name], fmt.Sprintf("%s:%d", m.makefile, m.line))
+	}
+
+	var duplicates []string
+	for name, makefiles := range locations {
+		duplicates = append(duplicates, fmt.Sprintf("%s: defined in %s and in %s",
+			name, blueprintFiles[name], strings.Join(makefiles, ", ")))
+	}
+	sort.Strings(duplicates)
+	return duplicates
+}
+
+func checkDuplicateMakeModules(ctx Context, config Config) {
+	soongMk, err := os.ReadFile(config.SoongAndroidMk())
+	if err != nil {
+		ctx.Verbosef("Not checking the modules defined in Android.mk files: %s", err)
+		return
+	}
+	soongModules := parseMakeModuleDefinitions(config.SoongAndroidMk(), string(soongMk))
+
+	mkList, err := os.ReadFile(filepath.Join(config.FileListDir(), "Android.mk.list"))
+	if err != nil {
+		ctx.Verbosef("Not checking the modules defined in Android.mk files: %s", err)
+		return
+	}
+	var makeModules []makeModuleDefinition
+	for _, mkFile := range strings.Fields(string(mkList)) {
+		contents, err := os.ReadFile(mkFile)
+		if err != nil {
+			ctx.Verbosef("Not checking %s: %s", mkFile, err)
+			continue
+		}
+		makeModules = append(makeModules, parseMakeModuleDefinitions(mkFile, string(contents))...)
+	}
+
+	duplicates := duplicateMakeModules(soongModules, makeModules, config.BuildBrokenDuplicateMakeModulesAllowlist())
+	if len(duplicates) == 0 {
+		return
+	}
+	message := fmt.Sprintf("%d modules are defined both in Android.bp and Android.mk files:\n    %s\n",
+		len(duplicates), strings.Join(duplicates, "\n    "))
+	if config.BuildBrokenDuplicateMakeModules() {
+		ctx.Printf("Warning: %s", message)
+		return
+	}
+	ctx.Fatalf("%sRemove one of the definitions or rename one of the modules, or add the modules to "+
+		"BUILD_BROKEN_DUPLICATE_MAKE_MODULES_ALLOWLIST.", message)
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMakeModuleDefinitions(t *testing.T) {
+	contents := `
+LOCAL_PATH := $(call my-dir)
+
+include $(CLEAR_VARS)
+LOCAL_MODULE := foo
+LOCAL_SRC_FILES := foo.c
+include $(BUILD_EXECUTABLE)
+
+# LOCAL_MODULE := commented
+include $(CLEAR_VARS)
+LOCAL_MODULE = bar # a comment
+include $(BUILD_EXECUTABLE)
+
+include $(CLEAR_VARS)
+LOCAL_MODULE := \
+    baz
+LOCAL_PATH := vendor/acme
+LOCAL_MODULE := $(my_name)
+LOCAL_MODULE := qux
+LOCAL_MODULE_CLASS := ETC
+`
+	found := parseMakeModuleDefinitions("vendor/acme/Android.mk", contents)
+	expected := []makeModuleDefinition{
+		{name: "foo", makefile: "vendor/acme/Android.mk", line: 5},
+		{name: "bar", makefile: "vendor/acme/Android.mk", line: 11},
+		{name: "baz", makefile: "vendor/acme/Android.mk", line: 15},
+		{name: "qux", makefile: "vendor/acme/Android.mk", line: 19, localPath: "vendor/acme"},
+	}
+	if !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected:\n  %+v\ngot:\n  %+v", expected, found)
+	}
+}
+
+func TestDuplicateMakeModules(t *testing.T) {
+	soongMk := `
+include $(CLEAR_VARS)  # type: cc_binary, name: foo, variant: android_arm64_armv8-a
+LOCAL_PATH := frameworks/foo
+LOCAL_MODULE := foo
+
+include $(CLEAR_VARS)  # type: cc_binary, name: foo, variant: android_x86_64
+LOCAL_PATH := frameworks/foo
+LOCAL_MODULE := foo
+
+include $(CLEAR_VARS)  # type: cc_library, name: libbar, variant: android_arm64_armv8-a_shared
+LOCAL_PATH := frameworks/bar
+LOCAL_MODULE := libbar
+
+include $(CLEAR_VARS)  # type: prebuilt_etc, name: baz, variant: android_arm64_armv8-a
+LOCAL_PATH := frameworks/baz
+LOCAL_MODULE := baz
+`
+	soongModules := parseMakeModuleDefinitions("out/soong/Android-test.mk", soongMk)
+	makeModules := append(
+		parseMakeModuleDefinitions("vendor/acme/Android.mk", `
+LOCAL_MODULE := foo
+LOCAL_MODULE := libbar
+LOCAL_MODULE := other
+`),
+		parseMakeModuleDefinitions("vendor/other/Android.mk", `
+LOCAL_MODULE := foo
+LOCAL_MODULE := baz
+`)...)
+
+	found := duplicateMakeModules(soongModules, makeModules, []string{"libbar"})
+	expected := []string{
+		"baz: defined in frameworks/baz/Android.bp and in vendor/other/Android.mk:3",
+		"foo: defined in frameworks/foo/Android.bp and in vendor/acme/Android.mk:2, vendor/other/Android.mk:2",
+	}
+	if !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected:\n  %q\ngot:\n  %q", expected, found)
+	}
+
+	if found := duplicateMakeModules(soongModules, makeModules, []string{"foo", "libbar", "baz"}); len(found) > 0 {
+		t.Errorf("expected no collisions with the allowlist, got %q", found)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Make rules consume the outputs of Soong modules through the interface outputs that soong_build
+// lists in out/soong/interface_outputs.json, see android/interface_outputs.go.  Make can only warn
+// about the unregistered paths that it looks up with $(call soong-output-path,...), so after Kati
+// has run soong_ui also scans the Android.mk files for hard-coded paths of Soong intermediates that
+// are not registered interface outputs.  Each of them is written to
+// out/soong/unregistered_interface_outputs.txt, and a warning is printed if there are any.
+
+const (
+	interfaceOutputsFilename             = "interface_outputs.json"
+	unregisteredInterfaceOutputsFilename = "unregistered_interface_outputs.txt"
+)
+
+// hardCodedSoongOutputRegexp matches the hard-coded paths of Soong intermediates in makefiles.
+var hardCodedSoongOutputRegexp = regexp.MustCompile(
+	`(?:\$\(SOONG_OUT_DIR\)|\$\(OUT_DIR\)/soong|out/soong)/\.intermediates/[^\s,;:'"]+`)
+
+// makeVariableRegexp matches the references to make variables in a path.
+var makeVariableRegexp = regexp.MustCompile(`\$\([^()]*\)`)
+
+func checkInterfaceOutputs(ctx Context, config Config) {
+	inventory, err := os.ReadFile(filepath.Join(config.SoongOutDir(), interfaceOutputsFilename))
+	if err != nil {
+		ctx.Verbosef("Not checking the Soong outputs consumed by Make: %s", err)
+		return
+	}
+	var outputs []struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(inventory, &outputs); err != nil {
+		ctx.Fatalf("Failed to parse %s: %s", interfaceOutputsFilename, err)
+	}
+	var registered []string
+	for _, output := range outputs {
+		registered = append(registered, output.Path)
+	}
+
+	mkList, err := os.ReadFile(filepath.Join(config.FileListDir(), "Android.mk.list"))
+	if err != nil {
+		ctx.Verbosef("Not checking the Soong outputs consumed by Make: %s", err)
+		return
+	}
+
+	var found []string
+	for _, mkFile := range strings.Fields(string(mkList)) {
+		contents, err := os.ReadFile(mkFile)
+		if err != nil {
+			ctx.Verbosef("Not checking %s: %s", mkFile, err)
+			continue
+		}
+		found = append(found, unregisteredSoongOutputs(registered, mkFile, string(contents))...)
+	}
+
+	report := filepath.Join(config.SoongOutDir(), unregisteredInterfaceOutputsFilename)
+	if err := os.WriteFile(report, []byte(strings.Join(found, "\n")), 0666); err != nil {
+		ctx.Fatalf("Failed to write %s: %s", report, err)
+	}
+	if len(found) > 0 {
+		ctx.Printf("Warning: Make consumes %d hard-coded Soong output paths that are not registered "+
+			"interface outputs, which break silently when they are renamed, see %s\n", len(found), report)
+	}
+}
+
+// unregisteredSoongOutputs returns the hard-coded paths of Soong intermediates in the contents of
+// mkFile that are not in the registered paths, each prefixed with the file and line it is on.  A
+// path that refers to make variables is registered if it matches a registered path with any value
+// of the variables.
+func unregisteredSoongOutputs(registered []string, mkFile, contents string) []string {
+	registeredRels := make(map[string]bool)
+	for _, path := range registered {
+		if rel, ok := intermediatesRel(path); ok {
+			registeredRels[rel] = true
+		}
+	}
+
+	var found []string
+	for i, line := range strings.Split(contents, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, path := range hardCodedSoongOutputRegexp.FindAllString(line, -1) {
+			path = trimUnbalancedParens(path)
+			rel, _ := intermediatesRel(path)
+			if !isRegisteredSoongOutput(registeredRels, rel) {
+				found = append(found, fmt.Sprintf("%s:%d: %s", mkFile, i+1, path))
+			}
+		}
+	}
+	return found
+}
+
+// intermediatesRel returns the part of path after the Soong intermediates directory.
+func intermediatesRel(path string) (string, bool) {
+	const intermediates = "/.intermediates/"
+	if i := strings.Index(path, intermediates); i >= 0 {
+		return path[i+len(intermediates):], true
+	}
+	return "", false
+}
+
+// trimUnbalancedParens removes the closing parentheses of the make function call that a path is
+// an argument of.
+func trimUnbalancedParens(path string) string {
+	for strings.HasSuffix(path, ")") && strings.Count(path, ")") > strings.Count(path, "(") {
+		path = strings.TrimSuffix(path, ")")
+	}
+	return path
+}
+
+func isRegisteredSoongOutput(registeredRels map[string]bool, rel string) bool {
+	if !makeVariableRegexp.MatchString(rel) {
+		return registeredRels[rel]
+	}
+
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range makeVariableRegexp.FindAllStringIndex(rel, -1) {
+		pattern.WriteString(regexp.QuoteMeta(rel[last:loc[0]]))
+		pattern.WriteString(".*")
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(rel[last:]))
+	pattern.WriteString("$")
+	re := regexp.MustCompile(pattern.String())
+	for registeredRel := range registeredRels {
+		if re.MatchString(registeredRel) {
+			return true
+		}
+	}
+	return false
+}
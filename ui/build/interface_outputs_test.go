@@ -0,0 +1,46 @@
+// Copyright 2023 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package build
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnregisteredSoongOutputs(t *testing.T) {
+	registered := []string{
+		"out/soong/.intermediates/frameworks/base/api-stubs-docs/android_common/metalava/api.txt",
+		"out/soong/.intermediates/compat/myconfig/android_common/myconfig.xml",
+	}
+
+	contents := `
+# out/soong/.intermediates/commented/out.txt is ignored.
+$(call dist-for-goals,sdk,$(SOONG_OUT_DIR)/.intermediates/frameworks/base/api-stubs-docs/android_common/metalava/api.txt)
+LOCAL_SRC_FILES := $(OUT_DIR)/soong/.intermediates/compat/$(CONFIG_NAME)/android_common/myconfig.xml
+foo: out/soong/.intermediates/foo/android_arm64_armv8-a/foo.txt out/soong/.intermediates/bar/$(TARGET_ARCH)/bar.txt
+	cp $< $@
+$(call dist-for-goals,sdk,$(SOONG_OUT_DIR)/.intermediates/frameworks/base/api-stubs-docs/android_common/metalava/removed.txt:removed.txt)
+`
+
+	found := unregisteredSoongOutputs(registered, "frameworks/base/Android.mk", contents)
+	expected := []string{
+		"frameworks/base/Android.mk:5: out/soong/.intermediates/foo/android_arm64_armv8-a/foo.txt",
+		"frameworks/base/Android.mk:5: out/soong/.intermediates/bar/$(TARGET_ARCH)/bar.txt",
+		"frameworks/base/Android.mk:7: $(SOONG_OUT_DIR)/.intermediates/frameworks/base/api-stubs-docs/android_common/metalava/removed.txt",
+	}
+	if !reflect.DeepEqual(expected, found) {
+		t.Errorf("expected:\n  %q\ngot:\n  %q", expected, found)
+	}
+}
@@ -103,6 +103,7 @@ var Configuration = map[string]PathConfig{
 	"pstree":  Allowed,
 	"rsync":   Allowed,
 	"sh":      Allowed,
+	"strace":  Allowed,
 	"stubby":  Allowed,
 	"tr":      Allowed,
 	"unzip":   Allowed,
@@ -16,10 +16,13 @@ package build
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"android/soong/bazel"
 	"android/soong/ui/metrics"
@@ -43,6 +46,7 @@ const (
 	queryviewTag         = "queryview"
 	apiBp2buildTag       = "api_bp2build"
 	soongDocsTag         = "soong_docs"
+	soongQueryTag        = "soong_query"
 
 	// bootstrapEpoch is used to determine if an incremental build is incompatible with the current
 	// version of bootstrap and needs cleaning before continuing the build.  Increment this for
@@ -253,6 +257,7 @@ func bootstrapGlobFileList(config Config) []string {
 		config.NamedGlobFile(queryviewTag),
 		config.NamedGlobFile(apiBp2buildTag),
 		config.NamedGlobFile(soongDocsTag),
+		config.NamedGlobFile(soongQueryTag),
 	}
 }
 
@@ -263,7 +268,8 @@ func bootstrapBlueprint(ctx Context, config Config) {
 	// Clean up some files for incremental builds across incompatible changes.
 	bootstrapEpochCleanup(ctx, config)
 
-	mainSoongBuildExtraArgs := []string{"-o", config.SoongNinjaFile()}
+	mainSoongBuildExtraArgs := []string{"-o", config.SoongNinjaFile(),
+		"--progress_file", config.SoongBuildProgressFile()}
 	if config.EmptyNinjaFile() {
 		mainSoongBuildExtraArgs = append(mainSoongBuildExtraArgs, "--empty-ninja-file")
 	}
@@ -347,6 +353,13 @@ func bootstrapBlueprint(ctx Context, config Config) {
 			output:       config.SoongDocsHtml(),
 			specificArgs: []string{"--soong_docs", config.SoongDocsHtml()},
 		},
+		{
+			name:         soongQueryTag,
+			description:  fmt.Sprintf("answering the Soong query at %s", config.SoongQueryResultFile()),
+			config:       config,
+			output:       config.SoongQueryResultFile(),
+			specificArgs: []string{"--soong_query_result", config.SoongQueryResultFile()},
+		},
 	}
 
 	// Figure out which invocations will be run under the debugger:
@@ -508,6 +521,10 @@ func runSoong(ctx Context, config Config) {
 		if config.SoongDocs() {
 			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(soongDocsTag))
 		}
+
+		if config.SoongQuery() {
+			checkEnvironmentFile(soongBuildEnv, config.UsedEnvFile(soongQueryTag))
+		}
 	}()
 
 	runMicrofactory(ctx, config, "bpglob", "github.com/google/blueprint/bootstrap/bpglob",
@@ -582,12 +599,18 @@ func runSoong(ctx Context, config Config) {
 		targets = append(targets, config.SoongDocsHtml())
 	}
 
+	if config.SoongQuery() {
+		targets = append(targets, config.SoongQueryResultFile())
+	}
+
 	if config.SoongBuildInvocationNeeded() {
 		// This build generates <builddir>/build.ninja, which is used later by build/soong/ui/build/build.go#Build().
 		targets = append(targets, config.SoongNinjaFile())
 	}
 
+	stopProgress := watchSoongBuildProgress(ctx, config.SoongBuildProgressFile())
 	ninja("bootstrap", "bootstrap.ninja", targets...)
+	stopProgress()
 
 	distGzipFile(ctx, config, config.SoongNinjaFile(), "soong")
 	distFile(ctx, config, config.SoongVarsFile(), "soong")
@@ -602,6 +625,68 @@ func runSoong(ctx Context, config Config) {
 	}
 }
 
+// watchSoongBuildProgress tails the status file that soong_build reports its analysis progress to
+// and renders the last line of it until the returned function is called.  The file is removed
+// first so the progress of a previous soong_build run is not shown when soong_build is up to date.
+func watchSoongBuildProgress(ctx Context, file string) func() {
+	os.Remove(file)
+
+	done := make(chan bool)
+	stopped := make(chan bool)
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		var offset int64
+		var partial string
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			f, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			var data []byte
+			if _, err := f.Seek(offset, io.SeekStart); err == nil {
+				data, _ = ioutil.ReadAll(f)
+			}
+			f.Close()
+			offset += int64(len(data))
+
+			lines := strings.Split(partial+string(data), "\n")
+			partial = lines[len(lines)-1]
+			if len(lines) > 1 {
+				if msg := soongBuildProgressMessage(lines[len(lines)-2]); msg != "" {
+					ctx.Status.Status(msg)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// soongBuildProgressMessage converts a line of the soong_build status file to a status message.
+func soongBuildProgressMessage(line string) string {
+	fields := strings.Fields(line)
+	switch {
+	case len(fields) == 2 && fields[0] == "phase":
+		return fmt.Sprintf("soong_build: %s", fields[1])
+	case len(fields) == 4 && fields[0] == "modules":
+		return fmt.Sprintf("soong_build: %s, %s modules (%s)", fields[1], fields[2], fields[3])
+	default:
+		return ""
+	}
+}
+
 func runMicrofactory(ctx Context, config Config, name string, pkg string, mapping map[string]string) {
 	ctx.BeginTrace(metrics.RunSoong, name)
 	defer ctx.EndTrace()